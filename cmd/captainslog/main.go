@@ -12,39 +12,162 @@ import (
 	"bytes"
 	"context"
 	"crypto/subtle"
+	"crypto/tls"
 	"embed"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
+	"math"
+	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
 
+	"github.com/ryan-winkler/captainslog-whisper/internal/align"
+	"github.com/ryan-winkler/captainslog-whisper/internal/assets"
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/auth"
+	"github.com/ryan-winkler/captainslog-whisper/internal/backendauth"
+	"github.com/ryan-winkler/captainslog-whisper/internal/bilingual"
+	"github.com/ryan-winkler/captainslog-whisper/internal/chaos"
+	"github.com/ryan-winkler/captainslog-whisper/internal/cluster"
+	"github.com/ryan-winkler/captainslog-whisper/internal/compare"
 	"github.com/ryan-winkler/captainslog-whisper/internal/config"
+	"github.com/ryan-winkler/captainslog-whisper/internal/connpool"
+	"github.com/ryan-winkler/captainslog-whisper/internal/digest"
+	"github.com/ryan-winkler/captainslog-whisper/internal/events"
+	"github.com/ryan-winkler/captainslog-whisper/internal/export"
+	"github.com/ryan-winkler/captainslog-whisper/internal/framing"
+	"github.com/ryan-winkler/captainslog-whisper/internal/history"
 	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+	"github.com/ryan-winkler/captainslog-whisper/internal/jobs"
+	"github.com/ryan-winkler/captainslog-whisper/internal/keywords"
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+	"github.com/ryan-winkler/captainslog-whisper/internal/loadtest"
+	"github.com/ryan-winkler/captainslog-whisper/internal/metrics"
+	"github.com/ryan-winkler/captainslog-whisper/internal/mic"
+	"github.com/ryan-winkler/captainslog-whisper/internal/notify"
+	"github.com/ryan-winkler/captainslog-whisper/internal/paths"
+	"github.com/ryan-winkler/captainslog-whisper/internal/phrases"
+	"github.com/ryan-winkler/captainslog-whisper/internal/pipeline"
+	"github.com/ryan-winkler/captainslog-whisper/internal/preview"
 	"github.com/ryan-winkler/captainslog-whisper/internal/proxy"
 	"github.com/ryan-winkler/captainslog-whisper/internal/ratelimit"
+	"github.com/ryan-winkler/captainslog-whisper/internal/reading"
+	"github.com/ryan-winkler/captainslog-whisper/internal/recordings"
+	"github.com/ryan-winkler/captainslog-whisper/internal/redact"
+	"github.com/ryan-winkler/captainslog-whisper/internal/refine"
+	"github.com/ryan-winkler/captainslog-whisper/internal/related"
+	"github.com/ryan-winkler/captainslog-whisper/internal/reprocess"
+	"github.com/ryan-winkler/captainslog-whisper/internal/retention"
+	"github.com/ryan-winkler/captainslog-whisper/internal/secrets"
+	"github.com/ryan-winkler/captainslog-whisper/internal/selftest"
+	"github.com/ryan-winkler/captainslog-whisper/internal/service"
+	"github.com/ryan-winkler/captainslog-whisper/internal/ssrf"
 	"github.com/ryan-winkler/captainslog-whisper/internal/stardate"
+	"github.com/ryan-winkler/captainslog-whisper/internal/store"
+	"github.com/ryan-winkler/captainslog-whisper/internal/stream"
+	"github.com/ryan-winkler/captainslog-whisper/internal/tailnet"
 	localtls "github.com/ryan-winkler/captainslog-whisper/internal/tls"
+	"github.com/ryan-winkler/captainslog-whisper/internal/tunnel"
+	"github.com/ryan-winkler/captainslog-whisper/internal/update"
+	"github.com/ryan-winkler/captainslog-whisper/internal/usage"
 	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
 	"github.com/ryan-winkler/captainslog-whisper/internal/watcher"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-const version = "0.2.0"
+// Build information, set at build time by the Makefile, Dockerfile and
+// release workflow:
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A plain `go build` in a git checkout leaves them empty; readBuildInfo
+// then takes the commit from the VCS stamp Go embeds.
+var (
+	version   = "0.2.0"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo identifies the running binary, for bug reports.
+type buildInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"` // from the VCS stamp
+	Modified   bool   `json:"modified,omitempty"`    // built from a tree with uncommitted changes
+	BuildDate  string `json:"build_date,omitempty"`
+	GoVersion  string `json:"go_version"`
+	Platform   string `json:"platform"` // GOOS/GOARCH
+}
+
+func readBuildInfo() buildInfo {
+	b := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = s.Value[:min(len(s.Value), 12)]
+			}
+		case "vcs.time":
+			b.CommitTime = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+// String is the one-line form for --version and the startup banner:
+// "0.2.0 (3f2c1a9, built 2026-01-02T10:00:00Z, go1.22.1 linux/amd64)".
+func (b buildInfo) String() string {
+	var parts []string
+	if b.Commit != "" {
+		c := b.Commit
+		if b.Modified {
+			c += "-dirty"
+		}
+		parts = append(parts, c)
+	}
+	if b.BuildDate != "" {
+		parts = append(parts, "built "+b.BuildDate)
+	}
+	parts = append(parts, b.GoVersion+" "+b.Platform)
+	return b.Version + " (" + strings.Join(parts, ", ") + ")"
+}
 
 //go:embed all:web
 var webFS embed.FS
@@ -57,6 +180,10 @@ type runtimeSettings struct {
 	DownloadDir   string `json:"download_dir"`
 	Language      string `json:"language"`
 	Model         string `json:"model"`
+	DraftModel    string `json:"draft_model"` // two-pass dictation's fast model; "" = one pass
+	// Model per language, for transcriptions that name none (see
+	// proxy.WithLanguageModels), e.g. {"en": "distil-large-v3"}
+	LanguageModels map[string]string `json:"language_models"`
 	AutoSave      bool   `json:"auto_save"`
 	AutoCopy      bool   `json:"auto_copy"`
 	Prompt        string `json:"prompt"`
@@ -66,6 +193,7 @@ type runtimeSettings struct {
 	DateFormat    string `json:"date_format"`
 	FileTitle     string `json:"file_title"`
 	WhisperURL    string `json:"whisper_url"`
+	BackendType   string `json:"backend_type"` // auto, openai or whispercpp (see proxy.Backend*)
 	LLMURL        string `json:"llm_url"`
 	LLMModel      string `json:"llm_model"`
 	EnableLLM     bool   `json:"enable_llm"`
@@ -77,22 +205,116 @@ type runtimeSettings struct {
 	DefaultExportFormat string `json:"default_export_format"`
 	// Advanced transcription parameters (feature parity with faster-whisper)
 	WordTimestamps          bool    `json:"word_timestamps"`
+	DisableEnrichment       bool    `json:"disable_enrichment"` // no SRT fallback for segment timings
 	BeamSize                int     `json:"beam_size"`
 	Temperature             float64 `json:"temperature"`
 	ConditionOnPreviousText *bool   `json:"condition_on_previous_text"` // pointer to distinguish false from unset
 	ExportMode              string  `json:"export_mode"`               // "rich" or "pure"
+	// Cue rules of SRT and WebVTT exports: line length, lines per cue, and
+	// the merging and splitting of cues by duration (see export.Subtitles)
+	Subtitles      export.Subtitles `json:"subtitles"`
 	TranscriptDir           string  `json:"transcript_dir"`            // auto-export directory for plain text files
 	TranslateDir            string  `json:"translate_dir"`             // auto-save directory for translation output
 	WatchDir                string  `json:"watch_dir"`                 // folder watcher: auto-transcribe new audio files
+	WatchRecursive          bool    `json:"watch_recursive"`           // folder watcher: also watch subfolders of watch_dir
+	Watchers                []watcher.Config `json:"watchers"`        // more watched folders, each with its own settings (see /api/watchers)
+	// Recording retention (0 = keep forever; see internal/retention)
+	RecordingMaxAgeDays int `json:"recording_max_age_days"`
+	RecordingMaxSizeMB  int `json:"recording_max_size_mb"`
+	// Compress the stored segments of transcripts older than this many
+	// months (0 = never; see store.Archive)
+	ArchiveAfterMonths int `json:"archive_after_months"`
+	// Seconds after a vault save during which DELETE /api/vault/last undoes it (0 = off)
+	UndoWindowSeconds int `json:"undo_window_seconds"`
+	// Frontmatter tags of every saved note (empty = vault.DefaultTags)
+	DefaultTags []string `json:"default_tags"`
+	// What /api/vault/save does with the note's recording: "" (leave it),
+	// "copy" or "move" into AttachmentsDir, with an audio embed in the note
+	AttachAudio    string `json:"attach_audio"`
+	AttachmentsDir string `json:"attachments_dir"` // inside the vault; "" = vault.DefaultAttachmentsDir
+	// Where saves go: "" (a note per transcription) or "daily" (appended
+	// to the Obsidian daily note; see vault.DailyNote). Empty folder and
+	// format follow Obsidian's Daily notes plugin settings.
+	VaultMode       string `json:"vault_mode"`
+	DailyNoteFolder string `json:"daily_note_folder"`
+	DailyNoteFormat string `json:"daily_note_format"` // Moment.js, as in Obsidian
+	DailyNoteEntry  string `json:"daily_note_entry"`  // Go template; "" = vault.DefaultDailyEntry
+	DailyNoteOrder  string `json:"daily_note_order"`  // "bottom" ("" too) or "top": newest entry under the heading
+	DailyNoteTOC    bool   `json:"daily_note_toc"`    // link each entry from a list under the heading
+	// Go template file for whole notes (see vault.NoteData), inside the
+	// vault unless absolute; "" = the built-in frontmatter and text
+	NoteTemplate string `json:"note_template"`
+	// Write each note's segments, word timings and speakers to a JSON file
+	// beside it (see vault.WithSidecar)
+	VaultSidecar bool `json:"vault_sidecar"`
+	// With EnableLLM, ask the LLM for each saved note's title, tags and
+	// summary (see reprocess.Describe)
+	LLMDescribe bool `json:"llm_describe"`
+	// With EnableLLM, save each note with its translation into
+	// TranslateTo, sentence by sentence, for practising a spoken language
+	// (see internal/bilingual)
+	LanguageLearning bool   `json:"language_learning"`
+	TranslateTo      string `json:"translate_to"`     // a language name, e.g. "English"
+	BilingualLayout  string `json:"bilingual_layout"` // "columns" or "interleaved"
+	// With EnableLLM, write a summary note of the day's or week's notes on
+	// this cron schedule ("" = only via /api/digest/run; see internal/digest)
+	DigestSchedule string `json:"digest_schedule"`
+	DigestPeriod   string `json:"digest_period"` // "daily" or "weekly"
+	DigestFolder   string `json:"digest_folder"` // inside the vault; "" = the vault itself
+	// Push notifications to a phone through ntfy or Gotify (see
+	// internal/notify); the token is the CAPTAINSLOG_NOTIFY_TOKEN secret
+	NotifyProvider   string   `json:"notify_provider"`    // "", "ntfy" or "gotify"
+	NotifyURL        string   `json:"notify_url"`         // server; "" = https://ntfy.sh for ntfy
+	NotifyTopic      string   `json:"notify_topic"`       // ntfy only
+	NotifyOn         []string `json:"notify_on"`          // notifyTranscription, notifyFailure, notifyBackend, notifyKeyword
+	NotifyMinSeconds int      `json:"notify_min_seconds"` // watcher transcriptions that took less aren't reported
+	// Words and phrases to watch for: new transcripts mentioning one are
+	// tagged with it and announced (see internal/keywords)
+	WatchKeywords []string `json:"watch_keywords"`
+	// LLM post-processing steps run by /api/pipeline/run (see internal/pipeline)
+	Pipeline []pipeline.Step `json:"pipeline"`
+	// ffmpeg preprocessing of uploads before they reach Whisper (see
+	// internal/audio); ignored when ffmpeg isn't installed
+	PreprocessAudio       bool `json:"preprocess_audio"`        // mono 16 kHz WAV
+	PreprocessNormalize   bool `json:"preprocess_normalize"`    // loudness normalization
+	PreprocessTrimSilence bool `json:"preprocess_trim_silence"` // cut silence at both ends
+	// Recordings longer than ChunkAfterMinutes (0 = never) are transcribed
+	// in overlapping ChunkMinutes pieces, ChunkConcurrency at a time (see
+	// proxy.WithChunking); needs ffmpeg
+	ChunkAfterMinutes int `json:"chunk_after_minutes"`
+	ChunkMinutes      int `json:"chunk_minutes"`
+	ChunkConcurrency  int `json:"chunk_concurrency"`
+	// Show text while recording without a streaming backend: the server
+	// cuts the microphone stream at pauses and transcribes each utterance
+	// (see stream.Dictation). StreamURL, when set, is used instead
+	LiveDictation bool `json:"live_dictation"`
 }
 
 func main() {
 	// --version / -v flag
 	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
-		fmt.Printf("captainslog %s\n", version)
+		fmt.Printf("captainslog %s\n", readBuildInfo())
 		os.Exit(0)
 	}
 
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		os.Exit(runLoadtest(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "vault" {
+		os.Exit(runVault(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(runService(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(runHealthcheck(os.Args[2:]))
+	}
+
+	// Under the Windows service manager, answer it before anything slow:
+	// it gives up on a service that doesn't report within 30 seconds.
+	serviceStop, serviceDone := service.Start()
+
 	// --- CLI flags ---
 	// Priority: CLI flag > environment variable > settings.json > default
 	var (
@@ -106,11 +328,16 @@ func main() {
 		flagEnableTLS  = flag.Bool("enable-tls", false, "Enable auto-TLS for HTTPS")
 		flagStreamURL  = flag.String("stream-url", "", "WebSocket URL for live streaming (e.g. ws://localhost:8765)")
 		flagVersion    = flag.Bool("version", false, "Print version and exit")
+		flagNoUpdateCheck = flag.Bool("no-update-check", false, "Never contact GitHub for update checks (air-gapped installs)")
+		flagPrivacyMode = flag.Bool("privacy-mode", false, "Keep transcript text out of logs and SSE; scrub logged query strings")
+		flagUpdateChannel = flag.String("update-channel", "", "Release channel for update checks: stable or beta")
+		flagPortable   = flag.Bool("portable", false, "Keep settings, recordings, certificates and logs in "+portableDirName+" beside the executable")
+		flagDataDir    = flag.String("data-dir", "", "Keep settings, recordings, certificates and logs in this directory (portable mode)")
 	)
 	flag.Parse()
 
 	if *flagVersion {
-		fmt.Println("captainslog", version)
+		fmt.Println("captainslog", readBuildInfo())
 		return
 	}
 
@@ -130,6 +357,18 @@ func main() {
 	if *flagEnableLLM { cfg.EnableLLM = true }
 	if *flagEnableTLS { cfg.EnableTLS = true }
 	if *flagStreamURL != "" { cfg.StreamURL = *flagStreamURL }
+	if *flagNoUpdateCheck { cfg.UpdateCheck = false }
+	if *flagPrivacyMode { cfg.PrivacyMode = true }
+	if *flagUpdateChannel != "" { cfg.UpdateChannel = *flagUpdateChannel }
+
+	// Portable mode keeps all data in one folder, logs included: a USB
+	// stick or a Windows desktop may have no usable $HOME and no console.
+	dataDir, portable, err := resolveDataDir(*flagPortable, *flagDataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "captainslog: %v\n", err)
+		os.Exit(1)
+	}
+	if portable && cfg.LogDir == "" { cfg.LogDir = filepath.Join(dataDir, "logs") }
 
 	// Build the log writer: stdout always, optionally tee to a rotating file.
 	// WHY stdout? journalctl, docker logs, and most container orchestrators
@@ -160,9 +399,14 @@ func main() {
 		// Text format: human-readable for terminal/journalctl viewing
 		logger = slog.New(slog.NewTextHandler(logWriter, &slog.HandlerOptions{Level: slog.LevelInfo}))
 	}
+	if cfg.PrivacyMode {
+		// Every attribute passes through redact before it's written, so no
+		// log call site can leak transcript text or identifying URLs.
+		logger = slog.New(redact.NewHandler(logger.Handler()))
+	}
 
 	// Validate config
-	for _, u := range []string{cfg.WhisperURL, cfg.LLMURL} {
+	for _, u := range append(proxy.SplitURLs(cfg.WhisperURL), cfg.LLMURL) {
 		if u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
 			logger.Warn("invalid URL in config — must start with http:// or https://", "url", u)
 		}
@@ -172,8 +416,24 @@ func main() {
 	}
 
 	// Config directory for persistent settings (portable via symlink/rclone)
-	configDir := envOrDefault("CAPTAINSLOG_CONFIG_DIR",
-		filepath.Join(os.Getenv("HOME"), ".config", "captainslog"))
+	configDir := dataDir
+	cacheDir := filepath.Join(dataDir, "cache")
+	if portable {
+		logger.Info("portable mode", "data_dir", configDir)
+	} else {
+		if os.Getenv("CAPTAINSLOG_CONFIG_DIR") == "" {
+			// Earlier versions used ~/.config/captainslog on every OS.
+			if moved, err := paths.Migrate(paths.LegacyConfigDir(), configDir); err != nil {
+				logger.Error("could not move settings to the platform config dir, using the old one", "from", paths.LegacyConfigDir(), "to", configDir, "error", err)
+				configDir = paths.LegacyConfigDir()
+			} else if moved {
+				logger.Info("moved settings and data to the platform config dir", "from", paths.LegacyConfigDir(), "to", configDir)
+			}
+		}
+		if dir, err := paths.CacheDir(); err == nil {
+			cacheDir = dir
+		}
+	}
 	os.MkdirAll(configDir, 0755)
 	configFile := filepath.Join(configDir, "settings.json")
 
@@ -182,6 +442,7 @@ func main() {
 		DownloadDir:          envOrDefault("CAPTAINSLOG_DOWNLOAD_DIR", ""),
 		Language:             envOrDefault("CAPTAINSLOG_LANGUAGE", "en"),
 		Model:                envOrDefault("CAPTAINSLOG_MODEL", "large-v3"),
+		DraftModel:           envOrDefault("CAPTAINSLOG_DRAFT_MODEL", ""),
 		AutoSave:             cfg.VaultDir != "",
 		AutoCopy:             true,
 		Prompt:               envOrDefault("CAPTAINSLOG_PROMPT", ""),
@@ -191,6 +452,7 @@ func main() {
 		DateFormat:           envOrDefault("CAPTAINSLOG_DATE_FORMAT", "2006-01-02"),
 		FileTitle:            envOrDefault("CAPTAINSLOG_FILE_TITLE", "Dictation"),
 		WhisperURL:           cfg.WhisperURL,
+		BackendType:          envOrDefault("CAPTAINSLOG_WHISPER_BACKEND", proxy.BackendAuto),
 		LLMURL:               cfg.LLMURL,
 		LLMModel:             envOrDefault("CAPTAINSLOG_LLM_MODEL", "llama3.2"),
 		EnableLLM:            cfg.EnableLLM,
@@ -200,14 +462,65 @@ func main() {
 		HistoryLimit:         envOrIntDefault("CAPTAINSLOG_HISTORY_LIMIT", 5),
 		StreamURL:            cfg.StreamURL,
 		DefaultExportFormat:  envOrDefault("CAPTAINSLOG_EXPORT_FORMAT", ""),
+		Subtitles: export.Subtitles{
+			MaxLineChars: max(envOrIntDefault("CAPTAINSLOG_SUBTITLE_MAX_LINE_CHARS", 0), 0),
+			MaxLines:     max(envOrIntDefault("CAPTAINSLOG_SUBTITLE_MAX_LINES", 0), 0),
+			MinDuration:  max(envOrFloatDefault("CAPTAINSLOG_SUBTITLE_MIN_DURATION", 0), 0),
+			MaxDuration:  max(envOrFloatDefault("CAPTAINSLOG_SUBTITLE_MAX_DURATION", 0), 0),
+		},
 		TranscriptDir:        envOrDefault("CAPTAINSLOG_TRANSCRIPT_DIR", ""),
 		TranslateDir:         envOrDefault("CAPTAINSLOG_TRANSLATE_DIR", ""),
 		WatchDir:             envOrDefault("CAPTAINSLOG_WATCH_DIR", ""),
+		WatchRecursive:       envOrDefault("CAPTAINSLOG_WATCH_RECURSIVE", "") == "true",
+		RecordingMaxAgeDays:  envOrIntDefault("CAPTAINSLOG_RECORDING_MAX_AGE_DAYS", 0),
+		RecordingMaxSizeMB:   envOrIntDefault("CAPTAINSLOG_RECORDING_MAX_SIZE_MB", 0),
+		ArchiveAfterMonths:   max(envOrIntDefault("CAPTAINSLOG_ARCHIVE_AFTER_MONTHS", 0), 0),
+		UndoWindowSeconds:    envOrIntDefault("CAPTAINSLOG_UNDO_WINDOW", 30),
+		DefaultTags:          vault.CleanTags(strings.Split(os.Getenv("CAPTAINSLOG_DEFAULT_TAGS"), ",")),
+		AttachAudio:          envOrDefault("CAPTAINSLOG_ATTACH_AUDIO", ""),
+		AttachmentsDir:       envOrDefault("CAPTAINSLOG_ATTACHMENTS_DIR", ""),
+		VaultMode:            envOrDefault("CAPTAINSLOG_VAULT_MODE", ""),
+		DailyNoteFolder:      envOrDefault("CAPTAINSLOG_DAILY_NOTE_FOLDER", ""),
+		DailyNoteFormat:      envOrDefault("CAPTAINSLOG_DAILY_NOTE_FORMAT", ""),
+		DailyNoteEntry:       envOrDefault("CAPTAINSLOG_DAILY_NOTE_ENTRY", ""),
+		DailyNoteOrder:       envOrDefault("CAPTAINSLOG_DAILY_NOTE_ORDER", ""),
+		DailyNoteTOC:         envOrDefault("CAPTAINSLOG_DAILY_NOTE_TOC", "") == "true",
+		NoteTemplate:         envOrDefault("CAPTAINSLOG_NOTE_TEMPLATE", ""),
+		VaultSidecar:         envOrDefault("CAPTAINSLOG_VAULT_SIDECAR", "") == "true",
+		LLMDescribe:          envOrDefault("CAPTAINSLOG_LLM_DESCRIBE", "") == "true",
+		LanguageLearning:     envOrDefault("CAPTAINSLOG_LANGUAGE_LEARNING", "") == "true",
+		TranslateTo:          envOrDefault("CAPTAINSLOG_TRANSLATE_TO", "English"),
+		BilingualLayout:      envOrDefault("CAPTAINSLOG_BILINGUAL_LAYOUT", bilingual.LayoutColumns),
+		DigestSchedule:       envOrDefault("CAPTAINSLOG_DIGEST_SCHEDULE", ""),
+		DigestPeriod:         envOrDefault("CAPTAINSLOG_DIGEST_PERIOD", digest.PeriodWeekly),
+		DigestFolder:         envOrDefault("CAPTAINSLOG_DIGEST_FOLDER", ""),
+		NotifyProvider:        envOrDefault("CAPTAINSLOG_NOTIFY_PROVIDER", ""),
+		NotifyURL:             envOrDefault("CAPTAINSLOG_NOTIFY_URL", ""),
+		NotifyTopic:           envOrDefault("CAPTAINSLOG_NOTIFY_TOPIC", ""),
+		NotifyOn:              splitList(envOrDefault("CAPTAINSLOG_NOTIFY_ON", strings.Join(notifyEvents, ","))),
+		NotifyMinSeconds:      max(envOrIntDefault("CAPTAINSLOG_NOTIFY_MIN_SECONDS", 60), 0),
+		WatchKeywords:         keywords.Clean(splitList(os.Getenv("CAPTAINSLOG_WATCH_KEYWORDS"))),
+		DisableEnrichment:     envOrDefault("CAPTAINSLOG_DISABLE_ENRICHMENT", "") == "true",
+		PreprocessAudio:       envOrDefault("CAPTAINSLOG_PREPROCESS_AUDIO", "") == "true",
+		PreprocessNormalize:   envOrDefault("CAPTAINSLOG_PREPROCESS_NORMALIZE", "") == "true",
+		PreprocessTrimSilence: envOrDefault("CAPTAINSLOG_PREPROCESS_TRIM_SILENCE", "") == "true",
+		ChunkAfterMinutes:     max(envOrIntDefault("CAPTAINSLOG_CHUNK_AFTER_MINUTES", 20), 0),
+		ChunkMinutes:          max(envOrIntDefault("CAPTAINSLOG_CHUNK_MINUTES", 10), 1),
+		ChunkConcurrency:      max(envOrIntDefault("CAPTAINSLOG_CHUNK_CONCURRENCY", 2), 1),
+		LiveDictation:         envOrDefault("CAPTAINSLOG_LIVE_DICTATION", "") == "true",
 	}
 
 	// Apply CLI history-limit override
 	if *flagHistoryLimit > 0 { settings.HistoryLimit = *flagHistoryLimit }
 
+	if v := os.Getenv("CAPTAINSLOG_LANGUAGE_MODELS"); v != "" {
+		models, err := proxy.ParseLanguageModels(v)
+		if err != nil {
+			logger.Warn("ignoring invalid CAPTAINSLOG_LANGUAGE_MODELS", "error", err)
+		}
+		settings.LanguageModels = models
+	}
+
 	// Load persisted settings from file (env vars override)
 	if data, err := os.ReadFile(configFile); err == nil {
 		// Migrate legacy field names (v0.1 → v1.0)
@@ -242,6 +555,12 @@ func main() {
 			if os.Getenv("CAPTAINSLOG_MODEL") == "" && saved.Model != "" {
 				settings.Model = saved.Model
 			}
+			if os.Getenv("CAPTAINSLOG_DRAFT_MODEL") == "" {
+				settings.DraftModel = saved.DraftModel
+			}
+			if os.Getenv("CAPTAINSLOG_LANGUAGE_MODELS") == "" {
+				settings.LanguageModels = proxy.CleanLanguageModels(saved.LanguageModels)
+			}
 			settings.AutoSave = saved.AutoSave
 			settings.AutoCopy = saved.AutoCopy
 			settings.Prompt = saved.Prompt
@@ -278,49 +597,656 @@ func main() {
 			if saved.TimeFormat != "" {
 				settings.TimeFormat = saved.TimeFormat
 			}
+			if os.Getenv("CAPTAINSLOG_WATCH_RECURSIVE") == "" {
+				settings.WatchRecursive = saved.WatchRecursive
+			}
+			if os.Getenv("CAPTAINSLOG_RECORDING_MAX_AGE_DAYS") == "" {
+				settings.RecordingMaxAgeDays = saved.RecordingMaxAgeDays
+			}
+			if os.Getenv("CAPTAINSLOG_RECORDING_MAX_SIZE_MB") == "" {
+				settings.RecordingMaxSizeMB = saved.RecordingMaxSizeMB
+			}
+			if os.Getenv("CAPTAINSLOG_ARCHIVE_AFTER_MONTHS") == "" {
+				settings.ArchiveAfterMonths = max(saved.ArchiveAfterMonths, 0)
+			}
+			if err := watcher.Validate(saved.Watchers); err != nil {
+				logger.Warn("saved watchers ignored", "error", err)
+			} else {
+				settings.Watchers = saved.Watchers
+			}
+			// WHY check the raw key? 0 turns undo off, so a settings file
+			// from before the setting existed must keep the default.
+			if _, ok := rawMap["undo_window_seconds"]; ok && os.Getenv("CAPTAINSLOG_UNDO_WINDOW") == "" {
+				settings.UndoWindowSeconds = max(saved.UndoWindowSeconds, 0)
+			}
+			if saved.DefaultTags != nil && os.Getenv("CAPTAINSLOG_DEFAULT_TAGS") == "" {
+				settings.DefaultTags = vault.CleanTags(saved.DefaultTags)
+			}
+			if vault.ValidAttachMode(saved.AttachAudio) && os.Getenv("CAPTAINSLOG_ATTACH_AUDIO") == "" {
+				settings.AttachAudio = saved.AttachAudio
+			}
+			if (saved.AttachmentsDir == "" || filepath.IsLocal(saved.AttachmentsDir)) && os.Getenv("CAPTAINSLOG_ATTACHMENTS_DIR") == "" {
+				settings.AttachmentsDir = saved.AttachmentsDir
+			}
+			if vault.ValidMode(saved.VaultMode) && os.Getenv("CAPTAINSLOG_VAULT_MODE") == "" {
+				settings.VaultMode = saved.VaultMode
+			}
+			if (saved.DailyNoteFolder == "" || filepath.IsLocal(saved.DailyNoteFolder)) && os.Getenv("CAPTAINSLOG_DAILY_NOTE_FOLDER") == "" {
+				settings.DailyNoteFolder = saved.DailyNoteFolder
+			}
+			if os.Getenv("CAPTAINSLOG_DAILY_NOTE_FORMAT") == "" {
+				settings.DailyNoteFormat = saved.DailyNoteFormat
+			}
+			if vault.ParseDailyEntry(saved.DailyNoteEntry) == nil && os.Getenv("CAPTAINSLOG_DAILY_NOTE_ENTRY") == "" {
+				settings.DailyNoteEntry = saved.DailyNoteEntry
+			}
+			if vault.ValidDailyOrder(saved.DailyNoteOrder) && os.Getenv("CAPTAINSLOG_DAILY_NOTE_ORDER") == "" {
+				settings.DailyNoteOrder = saved.DailyNoteOrder
+			}
+			if os.Getenv("CAPTAINSLOG_DAILY_NOTE_TOC") == "" {
+				settings.DailyNoteTOC = saved.DailyNoteTOC
+			}
+			if os.Getenv("CAPTAINSLOG_NOTE_TEMPLATE") == "" {
+				settings.NoteTemplate = saved.NoteTemplate
+			}
+			if os.Getenv("CAPTAINSLOG_VAULT_SIDECAR") == "" {
+				settings.VaultSidecar = saved.VaultSidecar
+			}
+			if os.Getenv("CAPTAINSLOG_LIVE_DICTATION") == "" {
+				settings.LiveDictation = saved.LiveDictation
+			}
+			if os.Getenv("CAPTAINSLOG_LLM_DESCRIBE") == "" {
+				settings.LLMDescribe = saved.LLMDescribe
+			}
+			if os.Getenv("CAPTAINSLOG_LANGUAGE_LEARNING") == "" {
+				settings.LanguageLearning = saved.LanguageLearning
+			}
+			if saved.TranslateTo != "" && os.Getenv("CAPTAINSLOG_TRANSLATE_TO") == "" {
+				settings.TranslateTo = saved.TranslateTo
+			}
+			if bilingual.ValidLayout(saved.BilingualLayout) && os.Getenv("CAPTAINSLOG_BILINGUAL_LAYOUT") == "" {
+				settings.BilingualLayout = saved.BilingualLayout
+			}
+			if sub := saved.Subtitles; sub.Validate() == nil {
+				if os.Getenv("CAPTAINSLOG_SUBTITLE_MAX_LINE_CHARS") == "" {
+					settings.Subtitles.MaxLineChars = sub.MaxLineChars
+				}
+				if os.Getenv("CAPTAINSLOG_SUBTITLE_MAX_LINES") == "" {
+					settings.Subtitles.MaxLines = sub.MaxLines
+				}
+				if os.Getenv("CAPTAINSLOG_SUBTITLE_MIN_DURATION") == "" {
+					settings.Subtitles.MinDuration = sub.MinDuration
+				}
+				if os.Getenv("CAPTAINSLOG_SUBTITLE_MAX_DURATION") == "" {
+					settings.Subtitles.MaxDuration = sub.MaxDuration
+				}
+			}
+			if validDigestSchedule(saved.DigestSchedule) && os.Getenv("CAPTAINSLOG_DIGEST_SCHEDULE") == "" {
+				settings.DigestSchedule = saved.DigestSchedule
+			}
+			if digest.ValidPeriod(saved.DigestPeriod) && os.Getenv("CAPTAINSLOG_DIGEST_PERIOD") == "" {
+				settings.DigestPeriod = saved.DigestPeriod
+			}
+			if (saved.DigestFolder == "" || filepath.IsLocal(saved.DigestFolder)) && os.Getenv("CAPTAINSLOG_DIGEST_FOLDER") == "" {
+				settings.DigestFolder = saved.DigestFolder
+			}
+			if notify.ValidProvider(saved.NotifyProvider) && os.Getenv("CAPTAINSLOG_NOTIFY_PROVIDER") == "" {
+				settings.NotifyProvider = saved.NotifyProvider
+			}
+			if os.Getenv("CAPTAINSLOG_NOTIFY_URL") == "" {
+				settings.NotifyURL = saved.NotifyURL
+			}
+			if os.Getenv("CAPTAINSLOG_NOTIFY_TOPIC") == "" {
+				settings.NotifyTopic = saved.NotifyTopic
+			}
+			if saved.NotifyOn != nil && validNotifyOn(saved.NotifyOn) && os.Getenv("CAPTAINSLOG_NOTIFY_ON") == "" {
+				settings.NotifyOn = saved.NotifyOn
+			}
+			if os.Getenv("CAPTAINSLOG_NOTIFY_MIN_SECONDS") == "" {
+				settings.NotifyMinSeconds = max(saved.NotifyMinSeconds, 0)
+			}
+			if saved.WatchKeywords != nil && os.Getenv("CAPTAINSLOG_WATCH_KEYWORDS") == "" {
+				settings.WatchKeywords = keywords.Clean(saved.WatchKeywords)
+			}
+			if os.Getenv("CAPTAINSLOG_DISABLE_ENRICHMENT") == "" {
+				settings.DisableEnrichment = saved.DisableEnrichment
+			}
+			if os.Getenv("CAPTAINSLOG_PREPROCESS_AUDIO") == "" {
+				settings.PreprocessAudio = saved.PreprocessAudio
+			}
+			if os.Getenv("CAPTAINSLOG_PREPROCESS_NORMALIZE") == "" {
+				settings.PreprocessNormalize = saved.PreprocessNormalize
+			}
+			if os.Getenv("CAPTAINSLOG_PREPROCESS_TRIM_SILENCE") == "" {
+				settings.PreprocessTrimSilence = saved.PreprocessTrimSilence
+			}
+			// 0 turns chunking off, so only a present key overrides the default.
+			if _, ok := rawMap["chunk_after_minutes"]; ok && os.Getenv("CAPTAINSLOG_CHUNK_AFTER_MINUTES") == "" {
+				settings.ChunkAfterMinutes = max(saved.ChunkAfterMinutes, 0)
+			}
+			if saved.ChunkMinutes > 0 && os.Getenv("CAPTAINSLOG_CHUNK_MINUTES") == "" {
+				settings.ChunkMinutes = saved.ChunkMinutes
+			}
+			if saved.ChunkConcurrency > 0 && os.Getenv("CAPTAINSLOG_CHUNK_CONCURRENCY") == "" {
+				settings.ChunkConcurrency = saved.ChunkConcurrency
+			}
+			if err := pipeline.Validate(saved.Pipeline); err != nil {
+				// A hand-edited settings.json shouldn't stop the server.
+				logger.Warn("saved pipeline ignored", "error", err)
+			} else {
+				settings.Pipeline = saved.Pipeline
+			}
 			logger.Info("loaded settings from file", "path", configFile)
 		}
 	}
 
-	whisperProxy := proxy.New(cfg.WhisperURL, logger)
+	// Background tasks (update checks, ...) stop when this is cancelled at shutdown.
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
+	// --- Secrets ---
+	// Each credential can come from CAPTAINSLOG_X, CAPTAINSLOG_X_FILE (Docker
+	// secrets) or CAPTAINSLOG_X_COMMAND (pass, op, ...). File secrets are
+	// polled so a rotated secret applies without a restart.
+	authToken, err := secrets.Load("CAPTAINSLOG_AUTH_TOKEN", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	webhookSecret, err := secrets.Load("CAPTAINSLOG_WEBHOOK_SECRET", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	llmAPIKey, err := secrets.Load("CAPTAINSLOG_LLM_API_KEY", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	whisperAPIKey, err := secrets.Load("CAPTAINSLOG_WHISPER_API_KEY", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	// ntfy access token or Gotify application token.
+	notifyToken, err := secrets.Load("CAPTAINSLOG_NOTIFY_TOKEN", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	// Used once, to log the tailnet node in the first time it starts.
+	tailscaleAuthKey, err := secrets.Load("CAPTAINSLOG_TAILSCALE_AUTHKEY", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	// Used once, to connect the rate limiter to Redis; the URL may hold a password.
+	rateRedis, err := secrets.Load("CAPTAINSLOG_RATE_REDIS_URL", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	// Used once, to open the transcript index in PostgreSQL.
+	postgresURL, err := secrets.Load("CAPTAINSLOG_POSTGRES_URL", logger)
+	if err != nil {
+		logger.Error("failed to load secret", "error", err)
+		os.Exit(1)
+	}
+	cfg.AuthToken = authToken.Get()
+	cfg.WebhookSecret = webhookSecret.Get()
+
+	// Named API keys with scopes, for clients that shouldn't hold the
+	// all-powerful auth token (see internal/auth). They need the token:
+	// without one the server is open and a key would restrict nothing.
+	apiKeys, err := auth.Open(filepath.Join(configDir, "api-keys.json"))
+	if err != nil {
+		logger.Error("failed to load API keys", "error", err)
+		os.Exit(1)
+	}
+	if apiKeys.Len() > 0 && cfg.AuthToken == "" {
+		logger.Warn("API keys are ignored without CAPTAINSLOG_AUTH_TOKEN — the server is open to everyone", "keys", apiKeys.Len())
+	}
+	for _, s := range []*secrets.Secret{authToken, webhookSecret, llmAPIKey, whisperAPIKey, notifyToken} {
+		go s.Watch(bgCtx, 5*time.Second)
+	}
+
+	// Transcription counts, audio seconds and upload bytes per caller and
+	// client IP, by day, for /api/usage (see internal/usage).
+	usageTracker, err := usage.Open(filepath.Join(configDir, "usage.json"))
+	if err != nil {
+		logger.Error("failed to load usage totals", "error", err)
+		os.Exit(1)
+	}
+	go usageTracker.Run(bgCtx, time.Minute, logger)
+
+	// Every transcription the server answers, with its text, whether or
+	// not it reaches the vault (see internal/history). nil when
+	// CAPTAINSLOG_HISTORY_LOG_SIZE is 0.
+	var historyLog *history.Log
+	if cfg.HistoryLogSize > 0 {
+		historyLog, err = history.Open(filepath.Join(configDir, "history-log.json"), cfg.HistoryLogSize)
+		if err != nil {
+			logger.Error("failed to load history log", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// --- Outbound events ---
+	// Every integration (webhooks today) subscribes to one bus and receives
+	// the same versioned envelopes — see internal/events for the schema.
+	eventBus := &events.Bus{}
+	var webhook *events.Webhook
+	if cfg.WebhookURL != "" {
+		var urls []string
+		for _, u := range strings.Split(cfg.WebhookURL, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		webhook = events.NewWebhook(urls, cfg.WebhookSecret, logger)
+		webhookSecret.OnChange(webhook.SetSecret)
+	}
+	if webhook != nil {
+		eventBus.Subscribe(webhook.Handle)
+		if cfg.WebhookSecret == "" {
+			logger.Warn("webhooks are unsigned — set CAPTAINSLOG_WEBHOOK_SECRET so receivers can verify deliveries")
+		}
+		logger.Info("webhooks enabled", "urls", len(webhook.URLs()), "schema_version", events.SchemaVersion)
+	}
+
+	// --- Push notifications ---
+	// To a phone, through ntfy or Gotify. Settings are read per message, so
+	// a change in the UI applies at once; /api/notify/test checks them.
+	if !notify.ValidProvider(settings.NotifyProvider) {
+		logger.Warn(`notification provider ignored — must be "ntfy" or "gotify"`, "provider", settings.NotifyProvider)
+		settings.NotifyProvider = ""
+	}
+	if !validNotifyOn(settings.NotifyOn) {
+		logger.Warn("notify_on ignored — unknown event", "notify_on", settings.NotifyOn, "known", notifyEvents)
+		settings.NotifyOn = notifyEvents
+	}
+	notifier := notify.New(func() notify.Config {
+		settings.mu.RLock()
+		defer settings.mu.RUnlock()
+		return notify.Config{
+			Provider: settings.NotifyProvider,
+			URL:      settings.NotifyURL,
+			Topic:    settings.NotifyTopic,
+			Token:    notifyToken.Get(),
+		}
+	}, logger)
+	notifyOn := func(event string) bool {
+		settings.mu.RLock()
+		defer settings.mu.RUnlock()
+		return settings.NotifyProvider != "" && slices.Contains(settings.NotifyOn, event)
+	}
+
+	// --- Watch keywords ---
+	// spotKeywords finds the watch_keywords setting's words in a new
+	// transcript; the caller tags the note with them before or after saving
+	// it. announceKeywords then sends a keyword.matched event per keyword
+	// and one notification for the transcript.
+	spotKeywords := func(text string, segs []store.Segment) []keywords.Hit {
+		settings.mu.RLock()
+		watch := settings.WatchKeywords
+		settings.mu.RUnlock()
+		if len(watch) == 0 {
+			return nil
+		}
+		return keywords.Spot(watch, text, segs)
+	}
+	keywordNames := func(hits []keywords.Hit) []string {
+		var names []string
+		for _, h := range hits {
+			names = append(names, h.Keyword)
+		}
+		return names
+	}
+	announceKeywords := func(source, id, file, filename string, hits []keywords.Hit) {
+		if len(hits) == 0 {
+			return
+		}
+		var lines []string
+		for _, h := range hits {
+			matched := events.KeywordMatched{Keyword: h.Keyword, Count: h.Count, Snippet: h.Snippet, Start: h.Start,
+				TranscriptID: id, SavedTo: file, Filename: filename}
+			line := fmt.Sprintf("%q", h.Keyword)
+			if at := h.Clock(); at != "" {
+				line += " at " + at
+			}
+			if cfg.PrivacyMode {
+				matched.Snippet = ""
+			} else {
+				line += ": " + h.Snippet
+			}
+			lines = append(lines, line)
+			eventBus.Publish(events.New(events.TypeKeywordMatched, source, matched))
+		}
+		logger.Info("watch keywords mentioned", "keywords", keywordNames(hits), "id", id, "source", source)
+		if !notifyOn(notifyKeyword) {
+			return
+		}
+		where := filepath.Base(file)
+		if file == "" {
+			where = filename
+		}
+		title := "Keyword mentioned"
+		if len(hits) > 1 {
+			title = "Keywords mentioned"
+		}
+		if where != "" {
+			title += " in " + where
+		}
+		// No key: every transcript's mentions are worth their own message.
+		notifier.Alert("", notify.Message{Title: title, Body: strings.Join(lines, "\n"), Tags: []string{"mag"}})
+	}
+
+	// --- Crypto policy ---
+	// WHY exit instead of warn? Someone who set a crypto policy is answering
+	// to a scanner or an auditor; silently serving weaker TLS is worse than
+	// not starting.
+	tlsPolicy, err := localtls.ParsePolicy(cfg.CryptoPolicy, cfg.TLSMinVersion, cfg.TLSCipherSuites, cfg.TLSCurves, cfg.TLSKeyType)
+	if err != nil {
+		logger.Error("invalid TLS/crypto policy", "error", err)
+		os.Exit(1)
+	}
+	mtls := cfg.TLSClientAuth != "" && !strings.EqualFold(cfg.TLSClientAuth, localtls.ClientAuthOff)
+	if mtls && !cfg.EnableTLS {
+		logger.Error("client certificate auth needs TLS — set CAPTAINSLOG_ENABLE_TLS=true", "client_auth", cfg.TLSClientAuth)
+		os.Exit(1)
+	}
+
+	// --- Backend URL policy ---
+	// Settings PUT can point the server at any URL, so an exposed instance
+	// could be used to probe the network it runs in. See internal/ssrf.
+	urlPolicy, err := ssrf.New(cfg.URLSchemes, cfg.URLAllowHosts, cfg.BlockLinkLocal)
+	if err != nil {
+		logger.Error("invalid backend URL policy", "error", err)
+		os.Exit(1)
+	}
+	for _, u := range append(proxy.SplitURLs(cfg.WhisperURL), cfg.LLMURL) {
+		if u == "" {
+			continue
+		}
+		if err := urlPolicy.CheckURL(u); err != nil {
+			logger.Warn("configured backend URL is outside the URL policy — requests to it will fail", "url", u, "error", err)
+		}
+	}
+
+	// --- Backend transport ---
+	// Every outbound backend call (Whisper, LLM, watcher) goes through this
+	// RoundTripper so cross-cutting behaviour is configured in one place.
+	// It sits on one shared connection pool (see internal/connpool), which
+	// honours HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	backendTLS, err := localtls.BackendConfig(cfg.BackendCAFile, cfg.BackendTLSInsecure)
+	if err != nil {
+		logger.Error("invalid backend TLS settings", "error", err)
+		os.Exit(1)
+	}
+	if cfg.BackendTLSInsecure {
+		// WHY Warn? Skipping verification lets anyone on the path read
+		// the audio and transcripts. It must not go unnoticed.
+		logger.Warn("backend TLS certificate verification is DISABLED (CAPTAINSLOG_BACKEND_TLS_INSECURE)")
+	}
+	if tlsPolicy.Name == localtls.PolicyFIPS {
+		// The fips policy covers outbound HTTPS too (remote Whisper/LLM).
+		if backendTLS == nil {
+			backendTLS = &tls.Config{}
+		}
+		tlsPolicy.Apply(backendTLS)
+	}
+	poolCfg := connpool.Config{
+		MaxIdleConns:        cfg.BackendMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.BackendMaxIdlePerHost,
+		IdleConnTimeout:     cfg.BackendIdleTimeout,
+		KeepAlive:           cfg.BackendKeepAlive,
+		RefreshInterval:     cfg.BackendDNSRefresh,
+		FailureThreshold:    cfg.BackendFailureFlush,
+		TLS:                 backendTLS,
+	}
+	if urlPolicy.BlockLinkLocal {
+		// Checked on the resolved IP at dial time, so a hostname that
+		// resolves to 169.254.169.254 is caught too.
+		poolCfg.Control = urlPolicy.Control
+	}
+	// One registry for the process lifetime; the proxy is rebuilt when the
+	// Whisper URL changes but keeps counting into the same series.
+	metricsRegistry := metrics.NewRegistry()
+	backendPool := connpool.New(poolCfg).WithMetrics(metricsRegistry)
+	// Backends addressed by hostname (Docker, Tailscale) can move to a new IP
+	// on restart; Watch drops pooled connections to the old one.
+	go backendPool.Watch(bgCtx)
+	var backendTransport http.RoundTripper = backendPool
+	if urlPolicy.Restricted() {
+		backendTransport = urlPolicy.Wrap(backendTransport)
+	}
+	chaosCfg := chaos.Config{
+		Latency:   cfg.ChaosLatency,
+		Jitter:    cfg.ChaosJitter,
+		ErrorRate: cfg.ChaosErrorRate,
+		DropRate:  cfg.ChaosDropRate,
+	}
+	var chaosTransport *chaos.Transport
+	if chaosCfg.Enabled() {
+		// WHY Warn? Chaos mode deliberately breaks backend calls. It must be
+		// impossible to miss in the logs if someone leaves it on by accident.
+		logger.Warn("CHAOS MODE ENABLED — backend calls will be delayed and failed on purpose",
+			"latency", chaosCfg.Latency, "jitter", chaosCfg.Jitter,
+			"error_rate", chaosCfg.ErrorRate, "drop_rate", chaosCfg.DropRate)
+		chaosTransport = chaos.Wrap(backendTransport, chaosCfg, logger)
+		backendTransport = chaosTransport
+	}
+	// Failed backend calls push a notification, once per host per
+	// cooldown: a backend that is down fails every call.
+	backendTransport = notify.Transport(backendTransport, func(req *http.Request, err error) {
+		if !notifyOn(notifyBackend) {
+			return
+		}
+		notifier.Alert("backend "+req.URL.Host, notify.Message{
+			Title:    "Captain's Log: backend call failed",
+			Body:     fmt.Sprintf("%s %s://%s%s: %v", req.Method, req.URL.Scheme, req.URL.Host, req.URL.Path, err),
+			Priority: notify.PriorityHigh,
+			Tags:     []string{"warning"},
+		})
+	})
+	// Whisper and LLM calls additionally carry their backend's credential, if
+	// one is configured. Hosted OpenAI-compatible endpoints and backends
+	// behind forward auth (Authelia, Authentik) need it; local servers
+	// ignore it. Kept separate so one backend's key never leaks to the other.
+	whisperTransport := backendauth.Transport(backendTransport, cfg.WhisperAuthHeader, whisperAPIKey.Get)
+	llmTransport := backendauth.Transport(backendTransport, cfg.LLMAuthHeader, llmAPIKey.Get)
+	// --- Metrics ---
+	startedAt := time.Now()
+
+	// Uploads beyond this many bytes are buffered on disk, not in RAM.
+	spoolMemory := int64(cfg.SpoolMemoryMB) << 20
+	maxUpload := int64(cfg.MaxUploadMB) << 20
+
+	// Which headers cross the proxy. Nothing inbound by default; only
+	// benign response headers outbound (see proxy.DefaultExposeHeaders).
+	var exposeHeaders []string
+	if cfg.ProxyExposeHeaders != "" {
+		exposeHeaders = strings.Split(cfg.ProxyExposeHeaders, ",")
+	}
+	forwardHeaders := strings.Split(cfg.ProxyForwardHeaders, ",")
+
+	if !proxy.ValidStrategy(cfg.WhisperStrategy) {
+		logger.Warn("unknown Whisper strategy, using round-robin", "strategy", cfg.WhisperStrategy)
+		cfg.WhisperStrategy = proxy.StrategyRoundRobin
+	}
+
+	// Requests every backend failed with a transient error are tried
+	// again after a backoff (see proxy.RetryPolicy).
+	retryPolicy := proxy.RetryPolicy{
+		Attempts:   max(cfg.RetryAttempts, 0),
+		Backoff:    cfg.RetryBackoff,
+		MaxBackoff: cfg.RetryMaxBackoff,
+	}
+	for _, s := range strings.Split(cfg.RetryStatuses, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil || code < 400 || code > 599 {
+			logger.Warn("ignoring invalid retry status", "status", s)
+			continue
+		}
+		retryPolicy.Statuses = append(retryPolicy.Statuses, code)
+	}
+	// Shared by every Whisper proxy, so requests queue per backend even
+	// across a rebuilt proxy or a one-off one (see proxy.Limiter).
+	backendLimiter := proxy.NewLimiter(cfg.BackendConcurrency)
+
+	// Uploads are converted before they reach Whisper when the settings
+	// ask for it and ffmpeg is installed.
+	audioConverter := audio.NewConverter(cfg.SpoolDir)
+	if !audioConverter.Available() {
+		logger.Info("ffmpeg not found, audio preprocessing and quality warnings unavailable")
+	}
+	audioOptions := func() audio.Options {
+		settings.mu.RLock()
+		defer settings.mu.RUnlock()
+		return audio.Options{
+			Downmix:     settings.PreprocessAudio,
+			Normalize:   settings.PreprocessNormalize,
+			TrimSilence: settings.PreprocessTrimSilence,
+		}
+	}
+
+	chunking := func() proxy.Chunking {
+		settings.mu.RLock()
+		defer settings.mu.RUnlock()
+		return proxy.Chunking{
+			After:       time.Duration(settings.ChunkAfterMinutes) * time.Minute,
+			Length:      time.Duration(settings.ChunkMinutes) * time.Minute,
+			Overlap:     proxy.DefaultChunkOverlap,
+			Concurrency: settings.ChunkConcurrency,
+		}
+	}
+
+	// url may list several backends; the proxy balances and fails over
+	// between them. Calls that bypass the proxy use the first.
+	newWhisperProxy := func(url, backendType string, extra ...proxy.Option) *proxy.Proxy {
+		opts := []proxy.Option{proxy.WithTransport(whisperTransport), proxy.WithMetrics(metricsRegistry),
+			proxy.WithSpool(spoolMemory, cfg.SpoolDir), proxy.WithMaxUpload(maxUpload), proxy.WithHeaders(forwardHeaders, exposeHeaders),
+			proxy.WithBackendType(backendType), proxy.WithBalancing(cfg.WhisperStrategy, 0), proxy.WithRetry(retryPolicy),
+			proxy.WithCircuitBreaker(proxy.CircuitBreaker{Failures: cfg.BreakerFailures, OpenFor: cfg.BreakerOpenFor}),
+			proxy.WithLimiter(backendLimiter),
+			proxy.WithWordTimestamps(func() bool {
+				settings.mu.RLock()
+				defer settings.mu.RUnlock()
+				return settings.WordTimestamps
+			}),
+			proxy.WithEnrichment(func() bool {
+				settings.mu.RLock()
+				defer settings.mu.RUnlock()
+				return !settings.DisableEnrichment
+			}),
+			proxy.WithLanguageModels(func() map[string]string {
+				settings.mu.RLock()
+				defer settings.mu.RUnlock()
+				return settings.LanguageModels // replaced, never modified, on update
+			}),
+			proxy.WithPreprocess(audioConverter, audioOptions), proxy.WithChunking(chunking)}
+		if cfg.AudioWarnings {
+			opts = append(opts, proxy.WithAudioWarnings(audioConverter))
+		}
+		return proxy.New(url, logger, append(opts, extra...)...)
+	}
+
+	// Replaced (under settings.mu) when the Whisper URL or backend type
+	// changes; handlers fetch it per request so the change applies at once.
+	whisperProxy := newWhisperProxy(cfg.WhisperURL, settings.BackendType)
+	currentWhisperProxy := func() *proxy.Proxy {
+		settings.mu.RLock()
+		defer settings.mu.RUnlock()
+		return whisperProxy
+	}
 
 	mux := http.NewServeMux()
 
 	// --- Auth middleware ---
-	withAuth := func(next http.HandlerFunc) http.HandlerFunc {
+	// authorize checks that the request may act in scope, and answers 401
+	// or 403 if not. The auth token and a verified client certificate may
+	// do anything; API keys only what their scopes allow. It returns who
+	// the caller is, as internal/usage counts them.
+	authorize := func(w http.ResponseWriter, r *http.Request, scope auth.Scope) (string, bool) {
+		if cfg.AuthToken == "" {
+			return "", true
+		}
+		// A client certificate signed by CAPTAINSLOG_TLS_CLIENT_CA is as
+		// good as the token — it's how machines authenticate under mTLS.
+		if cert := localtls.Verified(r); cert != nil {
+			return "cert:" + cert.Subject.CommonName, true
+		}
+		// WHY read the secret per request? A rotated token file takes
+		// effect immediately, and the old token stops working.
+		expected := []byte("Bearer " + authToken.Get())
+		token := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(token, expected) == 1 {
+			return "token", true
+		}
+		key, ok := apiKeys.Authenticate(strings.TrimPrefix(string(token), "Bearer "))
+		if !ok {
+			// WHY 401? Constant-time compare failed — either the token is wrong
+			// or the Authorization header is missing. We don't distinguish to
+			// prevent timing-based token enumeration.
+			httputil.Error(w, r, logger, http.StatusUnauthorized, "unauthorized",
+				"WHY: Bearer token mismatch or missing Authorization header")
+			return "", false
+		}
+		if !key.Allows(scope) {
+			// WHY 403? The key is valid, so saying why leaks nothing.
+			httputil.Error(w, r, logger, http.StatusForbidden, fmt.Sprintf("API key %q lacks the %s scope", key.Name, scope),
+				"WHY: the key's scopes don't cover this endpoint")
+			return "", false
+		}
+		return key.Name, true
+	}
+	// withScope requires scope; withAuth, the default, requires admin.
+	withScope := func(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
 		if cfg.AuthToken == "" {
 			return next
 		}
-		expected := []byte("Bearer " + cfg.AuthToken)
 		return func(w http.ResponseWriter, r *http.Request) {
-			token := []byte(r.Header.Get("Authorization"))
-			if subtle.ConstantTimeCompare(token, expected) != 1 {
-				// WHY 401? Constant-time compare failed — either the token is wrong
-				// or the Authorization header is missing. We don't distinguish to
-				// prevent timing-based token enumeration.
-				httputil.Error(w, r, logger, http.StatusUnauthorized, "unauthorized",
-					"WHY: Bearer token mismatch or missing Authorization header")
-				return
+			if caller, ok := authorize(w, r, scope); ok {
+				next(w, r.WithContext(auth.WithCaller(r.Context(), caller)))
 			}
-			next(w, r)
 		}
 	}
+	withAuth := func(next http.HandlerFunc) http.HandlerFunc {
+		return withScope(auth.ScopeAdmin, next)
+	}
 
 	// --- Security headers ---
+	// Framing is denied everywhere unless CAPTAINSLOG_FRAME_ANCESTORS lists
+	// origins allowed to embed the UI pages (see internal/framing).
+	framePolicy, err := framing.New(cfg.FrameAncestors, cfg.FramePaths)
+	if err != nil {
+		logger.Error("invalid frame embedding settings", "error", err)
+		os.Exit(1)
+	}
+	if framePolicy.Enabled() {
+		logger.Info("UI may be embedded", "origins", framePolicy.Ancestors(), "paths", cfg.FramePaths)
+	}
+	const csp = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self' http://127.0.0.1:* http://localhost:*; media-src 'self' blob:"
 	secure := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("X-Content-Type-Options", "nosniff")
-			w.Header().Set("X-Frame-Options", "DENY")
 			w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
 			w.Header().Set("Permissions-Policy", "microphone=(self)")
-			w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self' http://127.0.0.1:* http://localhost:*; media-src 'self' blob:")
+			framePolicy.Apply(w.Header(), r.URL.Path, csp)
 			next.ServeHTTP(w, r)
 		})
 	}
 
 	// --- Structured access logging (Grafana/Loki compatible JSON) ---
 	accessLog := func(next http.Handler) http.Handler {
-		accessLogger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		var accessHandler slog.Handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
+		if cfg.PrivacyMode {
+			accessHandler = redact.NewHandler(accessHandler)
+		}
+		accessLogger := slog.New(accessHandler)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			settings.mu.RLock()
 			logEnabled := settings.AccessLog
@@ -335,6 +1261,7 @@ func main() {
 			accessLogger.Info("request",
 				"method", r.Method,
 				"path", r.URL.Path,
+				"query", r.URL.RawQuery, // values scrubbed in privacy mode (e.g. /api/open?path=...)
 				"status", rw.status,
 				"duration_ms", time.Since(start).Milliseconds(),
 				"remote", r.RemoteAddr,
@@ -346,7 +1273,26 @@ func main() {
 
 	// --- Rate limiting ---
 	allowIPs := strings.Split(cfg.RateAllow, ",")
-	limiter := ratelimit.New(cfg.RateLimit, time.Minute, allowIPs)
+	limiter := ratelimit.New(cfg.RateLimit, time.Minute, allowIPs).WithMetrics(metricsRegistry)
+	// Behind a load balancer, replicas count each client's requests in one
+	// Redis, so the limit is per client rather than per client per replica.
+	if redisURL := rateRedis.Get(); redisURL != "" {
+		shared, err := ratelimit.NewRedis(redisURL, 0)
+		if err != nil {
+			logger.Error("invalid CAPTAINSLOG_RATE_REDIS_URL", "error", err)
+			os.Exit(1)
+		}
+		if err := shared.Ping(); err != nil {
+			// Non-fatal: the limiter counts locally until Redis answers.
+			logger.Warn("rate limit Redis unreachable — counting per instance until it answers", "addr", shared.Addr(), "error", err)
+		}
+		limiter.WithShared(shared)
+		logger.Info("rate limits shared across instances", "redis", shared.Addr())
+	}
+	// Concurrency and in-flight byte caps for uploads, so one client can't
+	// hold a small host's memory with a few huge, slow uploads.
+	uploadGuard := ratelimit.NewGuard(cfg.MaxUploadsPerIP,
+		int64(cfg.MaxInflightMBPerIP)<<20, int64(cfg.MaxInflightMB)<<20, allowIPs)
 	// Periodic cleanup of stale visitor entries
 	go func() {
 		for {
@@ -355,30 +1301,140 @@ func main() {
 		}
 	}()
 
+	// --- Horizontal scaling ---
+	// Instances with the same CAPTAINSLOG_SHARED_DIR share the job queue,
+	// the transcript index and recordings (see internal/cluster), so more
+	// proxies can sit in front of the same GPUs. Settings stay per instance.
+	instanceID := cluster.InstanceID(cfg.InstanceID)
+	stateDir := configDir
+	if cfg.SharedDir != "" {
+		stateDir = cfg.SharedDir
+		if err := os.MkdirAll(stateDir, 0755); err != nil {
+			logger.Error("failed to create shared dir", "dir", stateDir, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("sharing jobs, transcript index and recordings with other instances", "dir", stateDir, "instance", instanceID)
+	}
+
 	// --- Recordings storage ---
-	recordingsDir := filepath.Join(configDir, "recordings")
+	recordingsDir := filepath.Join(stateDir, "recordings")
 	os.MkdirAll(recordingsDir, 0755)
 
-	// Save a recording
-	mux.HandleFunc("/api/recordings", withAuth(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			// WHY 405? Recording uploads are always POST with multipart body.
-			// GET/PUT/DELETE on this endpoint are meaningless.
-			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
-				"WHY: /api/recordings only accepts POST with multipart file upload")
-			return
+	// --- Transcript index ---
+	// Links each saved vault note to its recording. Rebuildable from the
+	// vault via /api/admin/consistency, so a corrupt index is not fatal.
+	// It is kept in SQLite, unless shared: then it is a JSON file in the
+	// shared directory, or a PostgreSQL table.
+	indexPath := filepath.Join(stateDir, "index.json")
+	var index *store.Store
+	if pgURL := postgresURL.Get(); pgURL != "" {
+		// Kept in PostgreSQL, for its backups and replication; instances
+		// pointed at one database share it.
+		index, err = store.OpenPostgres(pgURL, stateDir)
+		if err != nil {
+			logger.Error("failed to open transcript index in PostgreSQL", "error", err)
+			os.Exit(1)
 		}
-		r.Body = http.MaxBytesReader(w, r.Body, 50<<20) // 50MB limit
-		file, header, err := r.FormFile("file")
+		entries, err := index.Len()
 		if err != nil {
-			// WHY 400? The multipart form must contain a 'file' field.
-			// This fails when the client sends JSON instead of multipart,
-			// or when the file exceeds the 50MB MaxBytesReader limit.
-			httputil.Error(w, r, logger, http.StatusBadRequest, "no file provided",
-				"WHY: r.FormFile('file') failed — missing multipart field or body too large")
-			return
+			logger.Error("failed to read transcript index in PostgreSQL", "error", err)
+			os.Exit(1)
 		}
-		defer file.Close()
+		logger.Info("transcript index in PostgreSQL", "entries", entries)
+		importIndex(index, indexPath, logger)
+	} else if cfg.SharedDir != "" {
+		index, err = store.OpenShared(indexPath)
+		if err != nil {
+			logger.Error("transcript index unreadable — moved aside, starting empty (rebuild with POST /api/admin/consistency)",
+				"error", err, "moved_to", indexPath+".corrupt")
+			os.Rename(indexPath, indexPath+".corrupt")
+			index, _ = store.OpenShared(indexPath)
+		}
+	} else {
+		dbPath := filepath.Join(stateDir, "index.db")
+		index, err = store.OpenSQLite(dbPath)
+		if err != nil {
+			logger.Error("transcript index unreadable — moved aside, starting empty (rebuild with POST /api/admin/consistency)",
+				"error", err, "moved_to", dbPath+".corrupt")
+			os.Rename(dbPath, dbPath+".corrupt")
+			os.Remove(dbPath + "-wal")
+			os.Remove(dbPath + "-shm")
+			if index, err = store.OpenSQLite(dbPath); err != nil {
+				logger.Error("failed to create transcript index", "path", dbPath, "error", err)
+				os.Exit(1)
+			}
+		}
+		importIndex(index, indexPath, logger)
+	}
+
+	// Save a recording
+	recordingLister := recordings.NewLister(recordingsDir)
+	mux.HandleFunc("/api/recordings", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// GET lists the recordings, newest first, with size, duration
+			// (ffprobe, else the linked transcript's) and the transcript
+			// they belong to, if any.
+			recs, err := recordingLister.List(r.Context())
+			if err != nil {
+				httputil.ServerError(w, r, logger, "recordings list failed",
+					"WHY: os.ReadDir failed on the recordings dir — check permissions", err)
+				return
+			}
+			entries, err := index.List()
+			if err != nil {
+				httputil.ServerError(w, r, logger, "recordings list failed",
+					"WHY: the transcript index could not be read to link recordings to notes", err)
+				return
+			}
+			byRecording := map[string]store.Entry{}
+			for _, e := range entries {
+				if e.Recording != "" {
+					byRecording[e.Recording] = e
+				}
+			}
+			type listed struct {
+				recordings.Recording
+				URL          string `json:"url"`
+				Linked       bool   `json:"linked"` // a saved note was transcribed from it
+				TranscriptID string `json:"transcript_id,omitempty"`
+			}
+			out := make([]listed, 0, len(recs))
+			for _, rec := range recs {
+				item := listed{Recording: rec, URL: "/api/recordings/" + url.PathEscape(rec.Name)}
+				if e, ok := byRecording[rec.Name]; ok {
+					item.TranscriptID = e.ID
+					item.Linked = e.VaultFile != ""
+					if item.Duration == 0 {
+						item.Duration = e.Duration
+					}
+				}
+				out = append(out, item)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"recordings": out, "ffprobe": recordingLister.CanProbe()})
+			return
+		}
+		if r.Method != http.MethodPost {
+			// WHY 405? Recordings are listed with GET and uploaded with a
+			// multipart POST; nothing else applies to the collection.
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/recordings accepts GET (list) or POST with multipart file upload")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 50<<20) // 50MB limit
+		// Parse with the spool threshold so large recordings go to temp
+		// files (removed by net/http) instead of the default 32MB in RAM.
+		r.ParseMultipartForm(spoolMemory)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			// WHY 400? The multipart form must contain a 'file' field.
+			// This fails when the client sends JSON instead of multipart,
+			// or when the file exceeds the 50MB MaxBytesReader limit.
+			httputil.Error(w, r, logger, http.StatusBadRequest, "no file provided",
+				"WHY: r.FormFile('file') failed — missing multipart field or body too large")
+			return
+		}
+		defer file.Close()
 
 		// Generate timestamped filename
 		ext := filepath.Ext(header.Filename)
@@ -410,17 +1466,272 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{"filename": filename, "status": "saved"})
 	}))
 
-	// Serve recordings for playback
-	mux.Handle("/api/recordings/", http.StripPrefix("/api/recordings/", http.FileServer(http.Dir(recordingsDir))))
+	// Serve recordings for playback; DELETE /api/recordings/<name> removes one.
+	serveRecordings := http.StripPrefix("/api/recordings/", http.FileServer(http.Dir(recordingsDir)))
+	deleteRecording := withAuth(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/api/recordings/")
+		if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			// WHY 400? Only bare file names inside the recordings directory
+			// can be deleted — no paths, no dotfiles.
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid recording name",
+				"WHY: name must be a file name from /api/recordings, without a path")
+			return
+		}
+		if err := os.Remove(filepath.Join(recordingsDir, name)); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				httputil.Error(w, r, logger, http.StatusNotFound, "recording not found",
+					"WHY: no file with this name in the recordings directory")
+				return
+			}
+			httputil.ServerError(w, r, logger, "recording delete failed",
+				"WHY: os.Remove failed on the recordings dir — check permissions", err)
+			return
+		}
+		if err := index.UnlinkRecordings(name); err != nil {
+			// Non-fatal: the consistency check clears dangling links.
+			logger.Warn("transcript index update failed", "recording", name, "error", err)
+		}
+		logger.Info("recording deleted", "file", name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"deleted": name})
+	})
+	mux.HandleFunc("/api/recordings/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleteRecording(w, r)
+			return
+		}
+		serveRecordings.ServeHTTP(w, r)
+	})
+
+	// --- Recording retention ---
+	// Deletes recordings past the configured age, then the oldest beyond the
+	// size budget. Settings are re-read on every sweep.
+	retentionSweeper := &retention.Sweeper{
+		Dir: recordingsDir,
+		Policy: func() retention.Policy {
+			settings.mu.RLock()
+			defer settings.mu.RUnlock()
+			return retention.Policy{
+				MaxAge:   time.Duration(settings.RecordingMaxAgeDays) * 24 * time.Hour,
+				MaxBytes: int64(settings.RecordingMaxSizeMB) << 20,
+			}
+		},
+		OnRemove: func(names []string) {
+			if err := index.UnlinkRecordings(names...); err != nil {
+				logger.Warn("transcript index update failed", "error", err)
+			}
+		},
+		Logger: logger,
+	}
+	go retentionSweeper.Run(bgCtx, time.Hour)
+
+	// --- Word counts ---
+	// Transcripts indexed before words were counted get theirs from their
+	// notes, once. Daily notes are left out: the note holds the whole day.
+	go func() {
+		entries, err := index.List()
+		if err != nil {
+			logger.Warn("word count backfill failed", "error", err)
+			return
+		}
+		words := map[string]int{}
+		for _, e := range entries {
+			if e.Words > 0 || e.VaultFile == "" || e.DailyNote {
+				continue
+			}
+			doc, err := vault.ReadDocument(e.VaultFile)
+			if err != nil {
+				continue // a missing note is the consistency check's to report
+			}
+			words[e.ID] = store.CountWords(doc.Body)
+		}
+		if len(words) == 0 {
+			return
+		}
+		if err := index.SetWords(words); err != nil {
+			logger.Warn("word count backfill failed", "error", err)
+			return
+		}
+		logger.Info("word counts added to older transcripts", "count", len(words))
+	}()
+
+	// --- Transcript archive ---
+	// Compresses the segments of old transcripts; they stay in the index
+	// and are decompressed when read. The setting is re-read on every run.
+	go func() {
+		t := time.NewTicker(time.Hour)
+		defer t.Stop()
+		for {
+			settings.mu.RLock()
+			months := settings.ArchiveAfterMonths
+			settings.mu.RUnlock()
+			if months > 0 {
+				res, err := index.Archive(time.Now().AddDate(0, -months, 0))
+				if err != nil {
+					logger.Warn("transcript archive incomplete", "error", err)
+				}
+				if res.Archived > 0 {
+					logger.Info("old transcripts archived", "count", res.Archived, "saved_bytes", res.Saved)
+				}
+			}
+			select {
+			case <-bgCtx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	// --- Digests ---
+	// Summary notes of the day's or week's transcripts, on the configured
+	// schedule or via /api/digest/run. Settings are re-read on every run.
+	if !validDigestSchedule(settings.DigestSchedule) {
+		logger.Warn("digest schedule ignored — not a cron expression", "schedule", settings.DigestSchedule)
+		settings.DigestSchedule = ""
+	}
+	if !digest.ValidPeriod(settings.DigestPeriod) {
+		logger.Warn(`digest period ignored — must be "daily" or "weekly"`, "period", settings.DigestPeriod)
+		settings.DigestPeriod = digest.PeriodWeekly
+	}
+	digester := &digest.Digester{
+		Settings: func() digest.Settings {
+			settings.mu.RLock()
+			defer settings.mu.RUnlock()
+			s := digest.Settings{
+				VaultDir: vault.ExpandDir(settings.VaultDir),
+				Folder:   settings.DigestFolder,
+				Schedule: settings.DigestSchedule,
+				Period:   settings.DigestPeriod,
+			}
+			if settings.EnableLLM && settings.LLMURL != "" {
+				s.Client = llm.New(settings.LLMURL, settings.LLMModel, llm.WithTransport(llmTransport))
+			}
+			return s
+		},
+		Notes: func(from, to time.Time) ([]digest.Note, error) {
+			entries, err := index.List()
+			if err != nil {
+				return nil, err
+			}
+			var notes []digest.Note
+			seen := map[string]bool{} // a daily note holds many transcriptions
+			for i := len(entries) - 1; i >= 0; i-- { // oldest first
+				e := entries[i]
+				if e.VaultFile == "" || seen[e.VaultFile] || e.CreatedAt.Before(from) || !e.CreatedAt.Before(to) {
+					continue
+				}
+				seen[e.VaultFile] = true
+				doc, err := vault.ReadDocument(e.VaultFile)
+				if err != nil {
+					// Non-fatal: a note deleted or moved since is left out.
+					logger.Warn("digest: note unreadable", "file", e.VaultFile, "error", err)
+					continue
+				}
+				notes = append(notes, digest.Note{
+					File:  e.VaultFile,
+					Time:  e.CreatedAt.Local(),
+					Title: vault.Unquote(doc.Get("title")),
+					Text:  doc.Body,
+				})
+			}
+			return notes, nil
+		},
+		Logger: logger,
+	}
+	go digester.RunScheduled(bgCtx)
 
 	// --- OpenAI-compatible API ---
-	mux.HandleFunc("/v1/audio/transcriptions", withAuth(whisperProxy.Transcribe))
-	mux.HandleFunc("/v1/audio/translations", withAuth(whisperProxy.Translate))
+	mux.HandleFunc("/v1/audio/transcriptions", withScope(auth.ScopeTranscribe, usageTracker.Wrap(historyLog.Wrap(history.SourceAPI, logger, func(w http.ResponseWriter, r *http.Request) {
+		currentWhisperProxy().Transcribe(w, r)
+	}))))
+	mux.HandleFunc("/v1/audio/translations", withScope(auth.ScopeTranscribe, usageTracker.Wrap(historyLog.Wrap(history.SourceTranslation, logger, func(w http.ResponseWriter, r *http.Request) {
+		currentWhisperProxy().Translate(w, r)
+	}))))
+	// Language detection on a short sample, so the UI can prefill the
+	// language instead of assuming English.
+	mux.HandleFunc("/api/detect-language", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		currentWhisperProxy().DetectLanguage(w, r)
+	}))
+
+	// --- Live streaming relay ---
+	// The browser streams audio to us over WebSocket and we relay it to the
+	// streaming backend, so the backend never has to be reachable from the
+	// browser and gets the same policy and credentials as the Whisper proxy.
+	streamDial := stream.DialOptions{TLS: backendTLS}
+	if urlPolicy.BlockLinkLocal {
+		streamDial.NetDial = (&net.Dialer{Timeout: 10 * time.Second, Control: urlPolicy.Control}).DialContext
+	}
+	streamRelay := &stream.Relay{
+		Backend: func() string {
+			settings.mu.RLock()
+			defer settings.mu.RUnlock()
+			return settings.StreamURL
+		},
+		Check: urlPolicy.CheckURL,
+		Header: func() http.Header {
+			h := http.Header{}
+			if k := whisperAPIKey.Get(); k != "" {
+				h.Set(cfg.WhisperAuthHeader, backendauth.Value(cfg.WhisperAuthHeader, k))
+			}
+			return h
+		},
+		Dial:   streamDial,
+		Logger: logger,
+	}
+	mux.HandleFunc("/v1/audio/transcriptions/stream", withScope(auth.ScopeTranscribe, streamRelay.ServeHTTP))
+
+	// Live dictation for everyone else: the same microphone stream, cut at
+	// pauses here and transcribed an utterance at a time by the ordinary
+	// backend, so any Whisper server gives text while you speak.
+	liveDictation := &stream.Dictation{
+		Transcribe: func(ctx context.Context, wav []byte, previous string) (string, error) {
+			settings.mu.RLock()
+			model := settings.Model
+			lang := settings.Language
+			settings.mu.RUnlock()
+			var buf bytes.Buffer
+			mpWriter := multipart.NewWriter(&buf)
+			part, _ := mpWriter.CreateFormFile("file", "live.wav")
+			part.Write(wav)
+			mpWriter.WriteField("response_format", "json")
+			mpWriter.WriteField("model", model)
+			if lang != "" && lang != "und" {
+				mpWriter.WriteField("language", lang)
+			}
+			if previous != "" {
+				mpWriter.WriteField("prompt", previous)
+			}
+			mpWriter.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &buf).WithContext(ctx)
+			req.Header.Set("Content-Type", mpWriter.FormDataContentType())
+			rec := httptest.NewRecorder()
+			currentWhisperProxy().Transcribe(rec, req)
+			if rec.Code != http.StatusOK {
+				return "", fmt.Errorf("backend returned HTTP %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+			}
+			var result struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				return "", fmt.Errorf("backend returned invalid JSON: %w", err)
+			}
+			return result.Text, nil
+		},
+		Encode: audio.EncodeWAV,
+		VAD: stream.VAD{
+			Threshold:    cfg.LiveThreshold,
+			Silence:      cfg.LiveSilence,
+			MaxUtterance: cfg.LiveMaxLength,
+		},
+		Logger: logger,
+	}
+	mux.HandleFunc("/v1/audio/transcriptions/live", withScope(auth.ScopeTranscribe, liveDictation.ServeHTTP))
 
 	// --- URL transcription (yt-dlp powered) ---
 	// Accepts {"url": "https://..."} and downloads audio via yt-dlp, then transcribes.
 	// Matches Buzz/Whishper/Vibe feature set for URL-based transcription.
-	mux.HandleFunc("/api/transcribe-url", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/transcribe-url", withScope(auth.ScopeTranscribe, usageTracker.Wrap(historyLog.Wrap(history.SourceURL, logger, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
 				"WHY: /api/transcribe-url only accepts POST with JSON body")
@@ -515,10 +1826,10 @@ func main() {
 		mpWriter.Close()
 
 		whisperReq, _ := http.NewRequestWithContext(r.Context(), http.MethodPost,
-			cfg.WhisperURL+"/v1/audio/transcriptions", &buf)
+			primaryURL(cfg.WhisperURL)+"/v1/audio/transcriptions", &buf)
 		whisperReq.Header.Set("Content-Type", mpWriter.FormDataContentType())
 
-		client := &http.Client{Timeout: 600 * time.Second}
+		client := &http.Client{Timeout: 600 * time.Second, Transport: whisperTransport}
 		resp, err := client.Do(whisperReq)
 		if err != nil {
 			httputil.ServerError(w, r, logger, "whisper request failed",
@@ -539,9 +1850,347 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		io.Copy(w, resp.Body)
 		logger.Info("url transcription complete", "url", req.URL)
-	}))
+	}))))
+
+	// transcribeFileWith transcribes the audio file at path through wp, for
+	// its balancing, preprocessing and word timings, and returns the
+	// verbose_json response. The file is streamed from disk into the form.
+	// language "" or "und" leaves detection to the backend.
+	transcribeFileWith := func(ctx context.Context, wp *proxy.Proxy, path, model, language string) ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		pr, pw := io.Pipe()
+		mpWriter := multipart.NewWriter(pw)
+		go func() {
+			mpWriter.WriteField("model", model)
+			mpWriter.WriteField("response_format", "verbose_json")
+			mpWriter.WriteField("word_timestamps", "true")
+			if language != "" && language != "und" {
+				mpWriter.WriteField("language", language)
+			}
+			part, err := mpWriter.CreateFormFile("file", filepath.Base(path))
+			if err == nil {
+				_, err = io.Copy(part, f)
+			}
+			if err == nil {
+				err = mpWriter.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", pr).WithContext(ctx)
+		req.Header.Set("Content-Type", mpWriter.FormDataContentType())
+		rec := httptest.NewRecorder()
+		wp.Transcribe(rec, req)
+		// Unblocks the writer if the proxy stopped reading early.
+		pr.Close()
+		if rec.Code != http.StatusOK {
+			return nil, fmt.Errorf("backend returned HTTP %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+		return rec.Body.Bytes(), nil
+	}
+	// transcribeFile is transcribeFileWith the current Whisper proxy.
+	transcribeFile := func(ctx context.Context, path, model, language string) ([]byte, error) {
+		return transcribeFileWith(ctx, currentWhisperProxy(), path, model, language)
+	}
 
 	// --- Vault save ---
+	// The last save is remembered so /api/vault/last can undo it, and its
+	// outcome (checksum or error) is reported by /healthz.
+	var lastSave vault.UndoLog
+	var saveMonitor vault.SaveMonitor
+	settings.mu.RLock()
+	startVault := vault.ExpandDir(settings.VaultDir)
+	settings.mu.RUnlock()
+	if removed := vault.CleanTemp(startVault, logger); len(removed) > 0 {
+		// Saves interrupted by a crash or power loss. Their notes kept the
+		// old content (or were never created), so nothing else is lost.
+		logger.Warn("removed temp files of interrupted vault saves", "files", removed)
+	}
+	// translateSentences translates text sentence by sentence into the
+	// translate_to setting's language, for the language-learning mode and
+	// flashcards. errNoLLM means the LLM is not enabled.
+	errNoLLM := errors.New("LLM not enabled")
+	// noteDescriber is the LLM that titles, tags and summarizes notes as
+	// they are saved, or nil with llm_describe off.
+	noteDescriber := func() vault.Describer {
+		settings.mu.RLock()
+		describe := settings.LLMDescribe && settings.EnableLLM && settings.LLMURL != ""
+		llmURL, llmModel := settings.LLMURL, settings.LLMModel
+		settings.mu.RUnlock()
+		if !describe {
+			return nil
+		}
+		return reprocess.Describe(llm.New(llmURL, llmModel, llm.WithTransport(llmTransport)))
+	}
+	translateSentences := func(ctx context.Context, text, language string) ([]bilingual.Pair, string, error) {
+		settings.mu.RLock()
+		enabled := settings.EnableLLM && settings.LLMURL != ""
+		llmURL, llmModel, target := settings.LLMURL, settings.LLMModel, settings.TranslateTo
+		settings.mu.RUnlock()
+		if !enabled {
+			return nil, target, errNoLLM
+		}
+		// Bounded like a note description: the save waits for it.
+		ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+		pairs, err := bilingual.Translate(ctx, llm.New(llmURL, llmModel, llm.WithTransport(llmTransport)), text, language, target)
+		return pairs, target, err
+	}
+	// --- Two-pass dictation ---
+	// With draft_model set, the UI dictates with that small model so the
+	// text can be pasted at once. A note saved from a draft is transcribed
+	// again with the model setting in the background, one at a time so
+	// dictation keeps its backend, and the note, its sidecar, index entry
+	// and segments take the refined text. A transcript.refined event and a
+	// notification list what changed.
+	refineSlot := make(chan struct{}, 1)
+	refineTranscript := func(id, file, audioPath, draft, draftModel, language string, added vault.Added) {
+		refineSlot <- struct{}{}
+		defer func() { <-refineSlot }()
+		settings.mu.RLock()
+		model := settings.Model
+		settings.mu.RUnlock()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		body, err := transcribeFile(ctx, audioPath, model, language)
+		if err != nil {
+			logger.Warn("draft transcript not refined", "id", id, "model", model, "error", err)
+			return
+		}
+		var result struct {
+			Text     string `json:"text"`
+			Segments []struct {
+				Start float64      `json:"start"`
+				End   float64      `json:"end"`
+				Text  string       `json:"text"`
+				Words []vault.Word `json:"words"`
+			} `json:"segments"`
+			Words []vault.Word `json:"words"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			logger.Warn("draft transcript not refined", "id", id, "model", model, "error", "backend returned invalid JSON: "+err.Error())
+			return
+		}
+		refined := strings.TrimSpace(result.Text)
+		if refined == "" {
+			// Silence to the large model: keep what the draft heard.
+			logger.Warn("draft transcript not refined", "id", id, "model", model, "error", "no speech detected")
+			return
+		}
+		segs := make([]store.Segment, len(result.Segments))
+		vsegs := make([]vault.Segment, len(result.Segments))
+		words := result.Words
+		for i, seg := range result.Segments {
+			segs[i] = store.Segment{Start: seg.Start, End: seg.End, Text: strings.TrimSpace(seg.Text)}
+			vsegs[i] = vault.Segment(segs[i])
+			if len(result.Words) == 0 {
+				words = append(words, seg.Words...)
+			}
+		}
+
+		if _, err := index.Get(id); err != nil {
+			// Undone or deleted while the large model was busy.
+			logger.Info("draft transcript gone before it was refined", "id", id)
+			return
+		}
+		before, _ := os.Stat(file)
+		applied, err := vault.Refine(file, draft, draftModel, added, vault.Transcription{
+			Text: refined, Language: language, Model: model, Segments: vsegs, Words: words,
+		})
+		if err != nil {
+			logger.Warn("refined transcript not written", "id", id, "file", file, "error", err)
+			return
+		}
+		if applied {
+			lastSave.Rewritten(file, before)
+			err := index.Update(id, func(e *store.Entry) {
+				e.Chars = len([]rune(refined))
+				e.Words = store.CountWords(refined)
+				e.Model = model
+			})
+			if err == nil && len(segs) > 0 {
+				err = index.SetSegments(id, segs)
+			}
+			if err != nil {
+				// Non-fatal: the note has the refined text; the index catches up on the next check.
+				logger.Warn("refined transcript not indexed", "id", id, "error", err)
+			}
+		}
+		changes := refine.Diff(draft, refined)
+		logger.Info("draft transcript refined", "id", id, "draft_model", draftModel, "model", model,
+			"changes", len(changes), "applied", applied)
+		refinedEvent := events.TranscriptRefined{TranscriptID: id, SavedTo: file, DraftModel: draftModel, Model: model,
+			Changes: len(changes), Diff: refine.Summary(changes, 0), Applied: applied}
+		if cfg.PrivacyMode {
+			refinedEvent.Diff = ""
+		}
+		eventBus.Publish(events.New(events.TypeTranscriptRefined, "vault", refinedEvent))
+		if len(changes) == 0 || !notifyOn(notifyRefined) {
+			return
+		}
+		msg := notify.Message{Title: "Transcript refined: " + filepath.Base(file), Tags: []string{"pencil2"}}
+		if cfg.PrivacyMode {
+			msg.Body = fmt.Sprintf("%s changed %d passages of the %s draft.", model, len(changes), draftModel)
+		} else {
+			msg.Body = refine.Summary(changes, 10)
+		}
+		if !applied {
+			msg.Body = "Not applied — the note was edited after it was saved.\n" + msg.Body
+		}
+		// No key: every refined transcript is worth its own message.
+		notifier.Alert("", msg)
+	}
+
+	// saveNote saves a transcript to the vault the one way every caller
+	// does: described by the LLM, translated in language-learning mode,
+	// its recording attached, indexed, announced, and refined in the
+	// background if it is a draft. errNoVault means no vault is set.
+	errNoVault := errors.New("vault directory not configured")
+	saveNote := func(ctx context.Context, n noteSave) (noteSaved, error) {
+		settings.mu.RLock()
+		dir := settings.VaultDir
+		dateFmt := settings.DateFormat
+		title := settings.FileTitle
+		tags := settings.DefaultTags
+		attach, attachDir := settings.AttachAudio, settings.AttachmentsDir
+		noteTemplate := settings.NoteTemplate
+		sidecar := settings.VaultSidecar
+		learning, layout := settings.LanguageLearning, settings.BilingualLayout
+		draftModel, refineModel := settings.DraftModel, settings.Model
+		var daily *vault.DailyNote
+		if settings.VaultMode == vault.ModeDaily {
+			daily = &vault.DailyNote{
+				Folder: settings.DailyNoteFolder,
+				Format: settings.DailyNoteFormat,
+				Entry:  settings.DailyNoteEntry,
+				Order:  settings.DailyNoteOrder,
+				TOC:    settings.DailyNoteTOC,
+			}
+		}
+		settings.mu.RUnlock()
+		if n.Attach != nil {
+			attach = *n.Attach
+		}
+		if n.Model == "" {
+			n.Model = refineModel
+		}
+		saver := vault.New(dir, dateFmt, title, logger).WithTags(tags).WithMonitor(&saveMonitor).WithDailyNote(daily).WithTemplate(noteTemplate).WithSidecar(sidecar)
+		if saver == nil {
+			return noteSaved{}, errNoVault
+		}
+		if d := noteDescriber(); d != nil {
+			saver.WithDescriber(d)
+		}
+		segments := make([]vault.Segment, len(n.Segments))
+		for i, seg := range n.Segments {
+			segments[i] = vault.Segment(seg)
+		}
+		hits := spotKeywords(n.Text, n.Segments)
+		noteTags := n.Tags
+		if daily == nil {
+			// A daily note's tags are the whole day's: keywords stay on
+			// the index entry.
+			noteTags = append(slices.Clip(noteTags), keywordNames(hits)...)
+		}
+		var pairs []bilingual.Pair
+		var translation, translatedTo string
+		if learning && strings.TrimSpace(n.Text) != "" {
+			var err error
+			pairs, translatedTo, err = translateSentences(ctx, n.Text, n.Language)
+			if err != nil {
+				// Non-fatal: the dictation matters more than its translation.
+				logger.Warn("note saved without its translation", "error", err)
+			}
+			translation = bilingual.Markdown(pairs, layout, n.Language, translatedTo)
+		}
+		file, added, err := saver.SaveTranscription(vault.Transcription{
+			Text:        n.Text,
+			Language:    n.Language,
+			Model:       n.Model,
+			Segments:    segments,
+			Words:       n.Words,
+			Tags:        noteTags,
+			Translation: translation,
+		})
+		if err != nil {
+			return noteSaved{}, err
+		}
+		saved := noteSaved{File: file}
+		recording := n.Recording
+		if file != "" && recording != "" && attach != vault.AttachOff {
+			// Non-fatal: the note is saved either way; without the attachment
+			// it just has no player.
+			path, err := saver.Attach(filepath.Join(recordingsDir, recording), attachDir, attach == vault.AttachMove)
+			if err != nil {
+				logger.Warn("recording not attached to vault note", "recording", recording, "error", err)
+			} else {
+				saved.Attachment = path
+				if attach == vault.AttachMove {
+					// It left the recordings folder; the index only tracks files there.
+					recording = ""
+				}
+				if err := vault.InsertEmbed(file, path, &added); err != nil {
+					logger.Warn("attachment not embedded in vault note", "file", file, "error", err)
+				}
+			}
+		}
+		if file != "" {
+			entry, err := index.Add(store.Entry{
+				VaultFile: vault.ExpandDir(file),
+				Recording: recording,
+				Language:  n.Language,
+				Chars:     len([]rune(n.Text)),
+				Words:     store.CountWords(n.Text),
+				Model:     n.Model,
+				Source:    n.Source,
+				DailyNote: daily != nil,
+				Keywords:  keywordNames(hits),
+			})
+			if err != nil {
+				// Non-fatal: the note is saved; the consistency check re-indexes it.
+				logger.Warn("transcript index update failed", "file", file, "error", err)
+			} else {
+				saved.ID = entry.ID
+				if len(n.Segments) > 0 {
+					if err := index.SetSegments(saved.ID, n.Segments); err != nil {
+						// Non-fatal: the text is in the vault; only paging by time is lost.
+						logger.Warn("transcript segments not stored", "id", saved.ID, "error", err)
+					}
+				}
+				if len(pairs) > 0 {
+					t := store.Translation{Language: translatedTo, Sentences: make([]store.SentencePair, len(pairs))}
+					for i, p := range pairs {
+						t.Sentences[i] = store.SentencePair(p)
+					}
+					if err := index.SetTranslation(saved.ID, t); err != nil {
+						// Non-fatal: the translation is in the note; only flashcards need it.
+						logger.Warn("transcript translation not stored", "id", saved.ID, "error", err)
+					}
+				}
+			}
+			lastSave.RecordEdit(file, saved.ID, added.Before)
+			eventBus.Publish(events.New(events.TypeVaultSaved, "vault", events.VaultSaved{
+				Path:     file,
+				Chars:    len([]rune(n.Text)),
+				Language: n.Language,
+			}))
+			announceKeywords(n.Source, saved.ID, file, recording, hits)
+		}
+		// A draft of two-pass dictation: transcribed again in the background.
+		audioPath := saved.Attachment
+		if recording != "" {
+			audioPath = filepath.Join(recordingsDir, recording)
+		}
+		if saved.ID != "" && draftModel != "" && n.Model == draftModel && refineModel != draftModel && audioPath != "" {
+			saved.Refining = refineModel
+			go refineTranscript(saved.ID, vault.ExpandDir(file), audioPath, n.Text, draftModel, n.Language, added)
+		}
+		return saved, nil
+	}
+
 	mux.HandleFunc("/api/vault/save", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			// WHY 405? Vault saves are write-only — POST with JSON body.
@@ -549,25 +2198,47 @@ func main() {
 				"WHY: /api/vault/save only accepts POST with JSON body")
 			return
 		}
-		r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB limit
+		// 4MB: the text is small, but an hour of segments is a few hundred KB.
+		r.Body = http.MaxBytesReader(w, r.Body, 4<<20)
 		var req struct {
-			Text     string `json:"text"`
-			Language string `json:"language"`
+			Text      string          `json:"text"`
+			Language  string          `json:"language"`
+			Recording string          `json:"recording,omitempty"` // filename from /api/recordings
+			Segments  []store.Segment `json:"segments,omitempty"`  // stored in the index, served by page
+			Words     []vault.Word    `json:"words,omitempty"`     // word timings, kept in the sidecar
+			Tags      []string        `json:"tags,omitempty"`      // added to default_tags for this note
+			Attach    *string         `json:"attach_audio"`        // overrides the attach_audio setting
+			Model     string          `json:"model,omitempty"`     // default: the model setting
+			Source    string          `json:"source,omitempty"`    // e.g. "microphone", "upload"; default "api"
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			// WHY 400? JSON decode failed — malformed JSON, wrong content-type,
-			// or body exceeds the 1MB MaxBytesReader limit.
+			// or body exceeds the 4MB MaxBytesReader limit.
 			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid request body",
-				"WHY: JSON decode failed — malformed body or exceeded 1MB limit")
+				"WHY: JSON decode failed — malformed body or exceeded 4MB limit")
 			return
 		}
-		settings.mu.RLock()
-		dir := settings.VaultDir
-		dateFmt := settings.DateFormat
-		title := settings.FileTitle
-		settings.mu.RUnlock()
-		saver := vault.New(dir, dateFmt, title, logger)
-		if saver == nil {
+		if req.Recording != "" && filepath.Base(req.Recording) != req.Recording {
+			// WHY 400? The index stores bare filenames inside recordingsDir;
+			// a path here could point the consistency fixer outside it.
+			httputil.Error(w, r, logger, http.StatusBadRequest, "recording must be a filename",
+				"WHY: 'recording' contained a path separator")
+			return
+		}
+		if req.Attach != nil && !vault.ValidAttachMode(*req.Attach) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, `attach_audio must be "", "copy" or "move"`, "")
+			return
+		}
+		if req.Source == "" {
+			req.Source = store.SourceAPI
+		}
+		if !validSource(req.Source) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "source must be lowercase letters, digits, - or _ (at most 32)",
+				"WHY: source is a filter value for /api/transcripts, not free text")
+			return
+		}
+		saved, err := saveNote(r.Context(), noteSave(req))
+		if errors.Is(err, errNoVault) {
 			// WHY 501? vault.New returns nil when VaultDir is empty.
 			// The user hasn't configured a vault directory yet.
 			httputil.Error(w, r, logger, http.StatusNotImplemented,
@@ -575,7 +2246,6 @@ func main() {
 				"WHY: settings.VaultDir is empty — user must set vault path in Preferences")
 			return
 		}
-		file, err := saver.Save(req.Text, req.Language)
 		if err != nil {
 			// WHY 500? vault.Save failed — directory doesn't exist, permissions
 			// denied, or disk full.
@@ -584,53 +2254,2779 @@ func main() {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"file": file, "status": "saved"})
+		resp := map[string]string{"file": saved.File, "id": saved.ID, "status": "saved"}
+		if saved.Attachment != "" {
+			resp["attachment"] = saved.Attachment
+		}
+		if saved.Refining != "" {
+			resp["refining"] = saved.Refining
+		}
+		json.NewEncoder(w).Encode(resp)
 	}))
 
-	// --- Vault history scan ---
-	mux.HandleFunc("/api/history", withAuth(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+	// GET /api/vault/last returns the save that can still be undone, with
+	// when the chance ends; DELETE undoes it — the note is deleted and its
+	// transcript index entry removed — within undo_window_seconds of the
+	// save. For accidental recordings that autosave has already written.
+	mux.HandleFunc("/api/vault/last", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodDelete {
 			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
-				"WHY: /api/history is GET only — reads vault directory")
+				"WHY: GET shows the last save, DELETE undoes it")
 			return
 		}
 		settings.mu.RLock()
-		dir := settings.VaultDir
+		window := time.Duration(settings.UndoWindowSeconds) * time.Second
 		settings.mu.RUnlock()
+		if window <= 0 {
+			httputil.Error(w, r, logger, http.StatusNotFound, "undo is disabled",
+				"WHY: undo_window_seconds (CAPTAINSLOG_UNDO_WINDOW) is 0")
+			return
+		}
 
-		if dir == "" {
-			// No vault configured — return empty array (not an error)
+		var saved vault.Saved
+		var err error
+		if r.Method == http.MethodGet {
+			saved, err = lastSave.Last(window)
+		} else {
+			saved, err = lastSave.Undo(window)
+		}
+		switch {
+		case errors.Is(err, vault.ErrNothingToUndo):
+			httputil.Error(w, r, logger, http.StatusNotFound, "nothing to undo",
+				"WHY: no note saved since the server started, or the last save was already undone")
+			return
+		case errors.Is(err, vault.ErrUndoExpired):
+			// WHY 410? The save existed and could have been undone; it can't now.
+			httputil.Error(w, r, logger, http.StatusGone, "undo window has passed",
+				fmt.Sprintf("WHY: saves can only be undone for %d seconds — delete the note by hand", int(window.Seconds())))
+			return
+		case errors.Is(err, vault.ErrNoteChanged):
+			httputil.Error(w, r, logger, http.StatusConflict, "note changed since it was saved",
+				"WHY: the note was edited after the save — not deleting someone's edits")
+			return
+		case err != nil:
+			httputil.ServerError(w, r, logger, "undo failed",
+				"WHY: the note could not be deleted — check vault permissions", err)
+			return
+		}
+
+		if r.Method == http.MethodGet {
 			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte("[]"))
+			json.NewEncoder(w).Encode(map[string]any{
+				"file": saved.File, "id": saved.ID, "saved_at": saved.At, "expires_at": saved.At.Add(window),
+			})
+			return
+		}
+		if saved.ID != "" {
+			if err := index.Remove(saved.ID); err != nil {
+				// Non-fatal: the consistency check drops entries whose note is gone.
+				logger.Warn("transcript index entry not removed after undo", "id", saved.ID, "error", err)
+			}
+		}
+		logger.Info("vault save undone", "file", saved.File, "id", saved.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"file": saved.File, "id": saved.ID, "status": "undone"})
+	}))
+
+	// --- Transcripts ---
+	// GET /api/transcripts/<id> returns metadata and text without segments;
+	// GET /api/transcripts/<id>/segments?from=&to=&offset=&limit= pages them,
+	// so an hour-long transcript never has to be shipped in one response.
+	// PATCH /api/transcripts/<id> {"pinned": true} pins or unpins the note.
+	// DELETE /api/transcripts/<id> deletes the note and its index entry;
+	// ?recording=true deletes the recording too.
+	// GET /api/transcripts/<id>/related?limit= suggests other notes in the
+	// vault on the same topic (shared tags and distinctive words).
+	// GET /api/transcripts?limit=&cursor=&from=&to=&language=&model=&source=
+	// pages through the index itself, newest first, without reading notes.
+	mux.HandleFunc("/api/transcripts", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: the transcript list is read-only — transcripts are created by /api/vault/save")
 			return
 		}
+		q := r.URL.Query()
+		limit := 0
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "limit must be a non-negative integer", "")
+				return
+			}
+			limit = n
+		}
+		from, err := parseDateParam(q.Get("from"))
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'from' — use YYYY-MM-DD or RFC 3339", err.Error())
+			return
+		}
+		to, err := parseDateParam(q.Get("to"))
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'to' — use YYYY-MM-DD or RFC 3339", err.Error())
+			return
+		}
+		filter := store.Filter{From: from, To: to, Language: q.Get("language"), Model: q.Get("model"), Source: q.Get("source")}
+		order, err := parseMetricParams(q, &filter)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		page, next, err := index.PageSorted(q.Get("cursor"), limit, order, filter.Match)
+		if errors.Is(err, store.ErrBadCursor) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid cursor",
+				"WHY: cursor must be a next_cursor value from an earlier /api/transcripts page")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Entries    []store.Entry `json:"entries"`
+			NextCursor string        `json:"next_cursor,omitempty"`
+		}{page, next})
+	}))
+
+	relatedFinder := related.NewFinder()
+	mux.HandleFunc("/api/transcripts/", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/transcripts/"), "/")
+		if r.Method != http.MethodGet && !((r.Method == http.MethodPatch || r.Method == http.MethodDelete) && sub == "") {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: transcripts are created by /api/vault/save — only pinning (PATCH) and deleting change one here")
+			return
+		}
+		entry, err := index.Get(id)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusNotFound, "transcript not found",
+				"WHY: no transcript index entry with this id")
+			return
+		}
+
+		if r.Method == http.MethodPatch {
+			var body struct {
+				Pinned *bool `json:"pinned"`
+			}
+			if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body); err != nil || body.Pinned == nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `body must be {"pinned": true|false}`,
+					"WHY: pinned is the only field of a transcript that can be changed")
+				return
+			}
+			if entry.VaultFile == "" {
+				// WHY 409? The pin is stored in the note's frontmatter, and
+				// this transcript's note is gone.
+				httputil.Error(w, r, logger, http.StatusConflict, "transcript has no note to pin",
+					"WHY: pins are saved in the vault note, and this entry has none")
+				return
+			}
+			if entry.DailyNote {
+				httputil.Error(w, r, logger, http.StatusConflict, "transcript is in a daily note",
+					"WHY: pins are saved in the note's frontmatter, which the daily note shares with the rest of the day")
+				return
+			}
+			if err := vault.SetPinned(entry.VaultFile, *body.Pinned); err != nil {
+				httputil.ServerError(w, r, logger, "pin failed",
+					"WHY: rewriting the note's frontmatter failed — check the vault file", err)
+				return
+			}
+			logger.Info("transcript pin changed", "id", id, "pinned", *body.Pinned)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"id": id, "pinned": *body.Pinned})
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			withRecording := r.URL.Query().Get("recording") == "true"
+			// A daily note holds the rest of the day too: only the index
+			// entry goes, the entry text stays for the user to remove.
+			if entry.VaultFile != "" && !entry.DailyNote {
+				if err := os.Remove(entry.VaultFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+					httputil.ServerError(w, r, logger, "transcript delete failed",
+						"WHY: the note could not be deleted — check vault permissions", err)
+					return
+				}
+				vault.RemoveSidecar(entry.VaultFile)
+			}
+			if withRecording && entry.Recording != "" {
+				if err := os.Remove(filepath.Join(recordingsDir, entry.Recording)); err != nil && !errors.Is(err, os.ErrNotExist) {
+					// Non-fatal: the note is gone; retention or the
+					// consistency fixer can remove the orphan later.
+					logger.Warn("recording not deleted with its transcript", "recording", entry.Recording, "error", err)
+				}
+			}
+			if err := index.Remove(id); err != nil {
+				httputil.ServerError(w, r, logger, "transcript delete failed",
+					"WHY: the note is deleted but the index could not be saved — the consistency check drops the entry", err)
+				return
+			}
+			logger.Info("transcript deleted", "id", id, "file", entry.VaultFile, "recording", withRecording)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"id": id, "deleted": true})
+			return
+		}
+
+		switch sub {
+		case "":
+			text := ""
+			pinned := false
+			if entry.VaultFile != "" {
+				if doc, err := vault.ReadDocument(entry.VaultFile); err == nil {
+					text = strings.TrimSpace(doc.Body)
+					pinned = doc.Pinned()
+				} else {
+					logger.Warn("transcript note unreadable", "id", id, "file", entry.VaultFile, "error", err)
+				}
+			}
+			segmentsURL := ""
+			var analytics *export.Analytics
+			if entry.Segments > 0 {
+				segmentsURL = "/api/transcripts/" + id + "/segments"
+				// Speaker statistics need every segment; a diarized
+				// meeting is the one transcript they are worth reading for.
+				if segs, err := index.AllSegments(id); err == nil {
+					exported := make([]export.Segment, len(segs))
+					for i, s := range segs {
+						exported[i] = export.Segment{Start: s.Start, End: s.End, Text: s.Text, Speaker: s.Speaker}
+					}
+					analytics = export.Analyze(exported)
+				} else {
+					logger.Warn("transcript segments unreadable", "id", id, "error", err)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				store.Entry
+				Text        string            `json:"text"`
+				Pinned      bool              `json:"pinned"`
+				SegmentsURL string            `json:"segments_url,omitempty"`
+				Analytics   *export.Analytics `json:"analytics,omitempty"`
+			}{entry, text, pinned, segmentsURL, analytics})
+		case "segments":
+			q := r.URL.Query()
+			var sq store.SegmentQuery
+			for _, p := range []struct {
+				name string
+				dst  *float64
+			}{{"from", &sq.From}, {"to", &sq.To}} {
+				if v := q.Get(p.name); v != "" {
+					f, err := strconv.ParseFloat(v, 64)
+					if err != nil || f < 0 {
+						httputil.Error(w, r, logger, http.StatusBadRequest, p.name+" must be seconds (a non-negative number)",
+							"WHY: from/to are offsets into the recording in seconds")
+						return
+					}
+					*p.dst = f
+				}
+			}
+			for _, p := range []struct {
+				name string
+				dst  *int
+			}{{"offset", &sq.Offset}, {"limit", &sq.Limit}} {
+				if v := q.Get(p.name); v != "" {
+					n, err := strconv.Atoi(v)
+					if err != nil || n < 0 {
+						httputil.Error(w, r, logger, http.StatusBadRequest, p.name+" must be a non-negative integer",
+							"WHY: offset/limit page through segments")
+						return
+					}
+					*p.dst = n
+				}
+			}
+			page, err := index.Segments(id, sq)
+			if err != nil {
+				httputil.ServerError(w, r, logger, "segments unavailable",
+					"WHY: the segment file for this transcript could not be read", err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(page)
+		case "related":
+			limit := 5
+			if v := r.URL.Query().Get("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "limit must be a positive integer",
+						"WHY: limit is the number of related notes to return")
+					return
+				}
+				limit = min(n, 20)
+			}
+			if entry.VaultFile == "" {
+				httputil.Error(w, r, logger, http.StatusConflict, "transcript has no note",
+					"WHY: related notes are found by comparing note text, and this entry has none")
+				return
+			}
+			// Candidates are the indexed notes in the same vault directory.
+			dir := filepath.Dir(entry.VaultFile)
+			all, err := index.List()
+			if err != nil {
+				httputil.ServerError(w, r, logger, "related notes failed",
+					"WHY: the transcript index could not be read for candidate notes", err)
+				return
+			}
+			var candidates []related.Candidate
+			for _, e := range all {
+				if e.VaultFile != "" && filepath.Dir(e.VaultFile) == dir {
+					candidates = append(candidates, related.Candidate{ID: e.ID, Path: e.VaultFile})
+				}
+			}
+			matches, err := relatedFinder.Find(related.Candidate{ID: id, Path: entry.VaultFile}, candidates, limit)
+			if err != nil {
+				httputil.ServerError(w, r, logger, "related notes unavailable",
+					"WHY: this transcript's note could not be read — check the vault file", err)
+				return
+			}
+			if matches == nil {
+				matches = []related.Match{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"id": id, "related": matches})
+		default:
+			httputil.Error(w, r, logger, http.StatusNotFound, "not found",
+				"WHY: only /api/transcripts/<id>, /api/transcripts/<id>/segments and /api/transcripts/<id>/related exist")
+		}
+	}))
+
+	// --- Export ---
+	// Renders a transcript as a file: GET /api/export?id=<transcript id>,
+	// or POST {"text": "...", "segments": [...]} for text that was never
+	// saved. ?format= (or "format") defaults to the default_export_format
+	// setting, ?timestamps= to the export mode (rich = timestamps).
+	// Flashcards use the translation saved in language-learning mode, or
+	// POSTed "cards"; without either the LLM translates the text now.
+	mux.HandleFunc("/api/export", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID         string            `json:"id"`
+			Format     string            `json:"format"`
+			Timestamps *bool             `json:"timestamps"`
+			Title      string            `json:"title"`
+			Language   string            `json:"language"`
+			Text       string            `json:"text"`
+			Segments   []export.Segment  `json:"segments"`
+			Subtitles  *export.Subtitles `json:"subtitles"` // nil = the subtitles setting
+			Cards      []export.Card     `json:"cards"`
+		}
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			req.ID, req.Format = q.Get("id"), q.Get("format")
+			if v := q.Get("timestamps"); v != "" {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "timestamps must be true or false", "")
+					return
+				}
+				req.Timestamps = &b
+			}
+			if req.ID == "" {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "id is required",
+					"WHY: GET exports a saved transcript — POST a body to export unsaved text")
+				return
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(io.LimitReader(r.Body, 8<<20)).Decode(&req); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", err.Error())
+				return
+			}
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/export is GET (saved transcript) or POST (text in the body)")
+			return
+		}
+
+		settings.mu.RLock()
+		format := settings.DefaultExportFormat
+		rich := settings.ExportMode != "pure"
+		fileTitle := settings.FileTitle
+		subtitles := settings.Subtitles
+		settings.mu.RUnlock()
+		if req.Subtitles != nil {
+			if err := req.Subtitles.Validate(); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			subtitles = *req.Subtitles
+		}
+		if req.Format != "" {
+			format = req.Format
+		}
+		if format == "" {
+			format = "txt"
+		}
+		format = strings.ToLower(strings.TrimPrefix(format, "."))
+		if req.Timestamps != nil {
+			rich = *req.Timestamps
+		}
+
+		doc := export.Doc{
+			Title:      req.Title,
+			Date:       time.Now(),
+			Language:   req.Language,
+			Text:       req.Text,
+			Segments:   req.Segments,
+			Timestamps: rich,
+			Subtitles:  subtitles,
+			Cards:      req.Cards,
+		}
+		if req.ID != "" {
+			entry, err := index.Get(req.ID)
+			if err != nil || entry.VaultFile == "" {
+				httputil.Error(w, r, logger, http.StatusNotFound, "transcript not found",
+					"WHY: no transcript index entry with a note for this id")
+				return
+			}
+			note, err := vault.ReadDocument(entry.VaultFile)
+			if err != nil {
+				httputil.ServerError(w, r, logger, "transcript note unreadable",
+					"WHY: the vault note for this transcript could not be read", err)
+				return
+			}
+			segs, err := index.AllSegments(req.ID)
+			if err != nil {
+				httputil.ServerError(w, r, logger, "segments unavailable",
+					"WHY: the segment file for this transcript could not be read", err)
+				return
+			}
+			doc.Title = strings.Trim(note.Get("title"), `"'`)
+			doc.Date = note.Time()
+			doc.Language = entry.Language
+			doc.Text = note.Body
+			doc.Segments = make([]export.Segment, len(segs))
+			for i, s := range segs {
+				doc.Segments[i] = export.Segment{Start: s.Start, End: s.End, Text: s.Text, Speaker: s.Speaker}
+			}
+			if format == "flashcards" {
+				t, err := index.Translation(req.ID)
+				if err != nil {
+					httputil.ServerError(w, r, logger, "translation unavailable",
+						"WHY: the translation file for this transcript could not be read", err)
+					return
+				}
+				for _, p := range t.Sentences {
+					doc.Cards = append(doc.Cards, export.Card{Front: p.Original, Back: p.Translation})
+				}
+			}
+		}
+		if strings.TrimSpace(doc.Text) == "" && len(doc.Segments) == 0 {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "nothing to export",
+				`WHY: pass "text" or "segments", or the id of a saved transcript`)
+			return
+		}
+		if doc.Title == "" {
+			doc.Title = fileTitle
+		}
+		if format == "flashcards" && len(doc.Cards) == 0 {
+			pairs, _, err := translateSentences(r.Context(), doc.Text, doc.Language)
+			if errors.Is(err, errNoLLM) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "LLM not enabled — enable in Settings → Connections",
+					"WHY: the transcript has no saved translation, and flashcards need one")
+				return
+			}
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadGateway, "translation failed",
+					"WHY: an LLM call failed — check the LLM server and model")
+				return
+			}
+			for _, p := range pairs {
+				doc.Cards = append(doc.Cards, export.Card{Front: p.Original, Back: p.Translation})
+			}
+		}
+
+		data, err := export.Render(format, doc)
+		if errors.Is(err, export.ErrUnknownFormat) || errors.Is(err, export.ErrNoCards) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		if err != nil {
+			httputil.ServerError(w, r, logger, "export failed", "WHY: rendering the "+format+" file failed", err)
+			return
+		}
+		name := doc.Title
+		if name == "" {
+			name = "Transcript"
+		}
+		name = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+				return '_'
+			}
+			return r
+		}, name) + "_" + doc.Date.Format("2006-01-02_15-04") + "." + export.Extension(format)
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		w.Write(data)
+	}))
+
+	// --- Caption previews ---
+	// A low-res render of a transcript's recording (or an uploaded video)
+	// with its subtitles burned in, to check the cue timing by watching it.
+	// Rendered in the background by ffmpeg; poll the job, then download.
+	previewDir := filepath.Join(stateDir, "previews")
+	previews := preview.NewManager(previewDir, logger)
+	type previewView struct {
+		preview.Job
+		StatusURL   string `json:"status_url"`
+		DownloadURL string `json:"download_url,omitempty"`
+	}
+	viewPreview := func(job preview.Job) previewView {
+		v := previewView{Job: job, StatusURL: "/api/previews/" + job.ID}
+		if job.Status == preview.StatusDone {
+			v.DownloadURL = "/api/previews/" + job.ID + "/file"
+		}
+		return v
+	}
+	mux.HandleFunc("/api/previews", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !previews.Available() {
+			httputil.Error(w, r, logger, http.StatusNotImplemented, "caption previews need ffmpeg",
+				"WHY: ffmpeg renders the preview and isn't installed")
+			return
+		}
+		if r.Method == http.MethodGet {
+			list := []previewView{}
+			for _, job := range previews.List() {
+				list = append(list, viewPreview(job))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"previews": list})
+			return
+		}
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/previews is GET (list) or POST (render one)")
+			return
+		}
+
+		// JSON {"id", "height", "subtitles"} previews the transcript's
+		// recording; multipart with the same fields and a "file" previews
+		// an uploaded video instead.
+		var req struct {
+			ID        string            `json:"id"`
+			Height    int               `json:"height"`
+			Subtitles *export.Subtitles `json:"subtitles"` // nil = the subtitles setting
+		}
+		var upload string
+		defer func() {
+			if upload != "" {
+				os.Remove(upload) // still here only if the job didn't start
+			}
+		}()
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			r.Body = http.MaxBytesReader(w, r.Body, 2<<30)
+			mr, err := r.MultipartReader()
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+				return
+			}
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+					return
+				}
+				if part.FormName() == "file" {
+					os.MkdirAll(previewDir, 0o700)
+					f, err := os.CreateTemp(previewDir, "upload-*"+filepath.Ext(part.FileName()))
+					if err != nil {
+						httputil.ServerError(w, r, logger, "failed to store upload", "WHY: the preview directory is not writable", err)
+						return
+					}
+					upload = f.Name()
+					_, err = io.Copy(f, part)
+					if cerr := f.Close(); err == nil {
+						err = cerr
+					}
+					if err != nil {
+						httputil.Error(w, r, logger, http.StatusBadRequest, "upload failed", err.Error())
+						return
+					}
+					continue
+				}
+				value, _ := io.ReadAll(io.LimitReader(part, 4<<10))
+				switch part.FormName() {
+				case "id":
+					req.ID = string(value)
+				case "height":
+					req.Height, _ = strconv.Atoi(string(value))
+				case "subtitles":
+					if err := json.Unmarshal(value, &req.Subtitles); err != nil {
+						httputil.Error(w, r, logger, http.StatusBadRequest, "subtitles must be a JSON object", err.Error())
+						return
+					}
+				}
+			}
+		} else if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", err.Error())
+			return
+		}
+
+		settings.mu.RLock()
+		subtitles := settings.Subtitles
+		settings.mu.RUnlock()
+		if req.Subtitles != nil {
+			if err := req.Subtitles.Validate(); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			subtitles = *req.Subtitles
+		}
+		entry, err := index.Get(req.ID)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusNotFound, "transcript not found",
+				"WHY: previews need the segment timings of a saved transcript")
+			return
+		}
+		segs, err := index.AllSegments(req.ID)
+		if err != nil {
+			httputil.ServerError(w, r, logger, "segments unavailable",
+				"WHY: the segment file for this transcript could not be read", err)
+			return
+		}
+		if len(segs) == 0 {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "transcript has no segments",
+				"WHY: without timings there are no captions to check")
+			return
+		}
+		media := upload
+		if media == "" {
+			if entry.Recording == "" {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "transcript has no recording — upload the video as \"file\"",
+					"WHY: there is nothing to burn the captions into")
+				return
+			}
+			media = filepath.Join(recordingsDir, entry.Recording)
+			if _, err := os.Stat(media); err != nil {
+				httputil.Error(w, r, logger, http.StatusNotFound, "recording not found",
+					"WHY: the recording linked to this transcript was deleted")
+				return
+			}
+		}
+
+		doc := export.Doc{Subtitles: subtitles, Segments: make([]export.Segment, len(segs))}
+		for i, s := range segs {
+			doc.Segments[i] = export.Segment{Start: s.Start, End: s.End, Text: s.Text, Speaker: s.Speaker}
+		}
+		captions, err := export.Render("srt", doc)
+		if err != nil {
+			httputil.ServerError(w, r, logger, "captions failed", "WHY: rendering the srt file failed", err)
+			return
+		}
+		title := strings.TrimSuffix(filepath.Base(entry.VaultFile), ".md")
+		job, err := previews.Start(preview.Request{
+			TranscriptID: req.ID,
+			Title:        title,
+			Media:        media,
+			MoveMedia:    upload != "",
+			Captions:     captions,
+			Height:       req.Height,
+			Duration:     segs[len(segs)-1].End,
+		})
+		if err != nil {
+			httputil.ServerError(w, r, logger, "failed to start preview", "WHY: the preview job could not be set up", err)
+			return
+		}
+		upload = "" // the job owns it now
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/previews/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(viewPreview(job))
+	}))
+	mux.HandleFunc("/api/previews/", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/previews/"), "/")
+		if !previews.Available() || !httputil.PreviewID.Valid(id) || (sub != "" && sub != "file") {
+			httputil.Error(w, r, logger, http.StatusNotFound, "preview not found", "")
+			return
+		}
+		switch {
+		case sub == "file" && r.Method == http.MethodGet:
+			path, job, err := previews.File(id)
+			switch {
+			case errors.Is(err, preview.ErrNotFound):
+				httputil.Error(w, r, logger, http.StatusNotFound, "preview not found",
+					"WHY: previews are kept for a day, in memory, and lost on restart")
+				return
+			case errors.Is(err, preview.ErrNotDone):
+				httputil.Error(w, r, logger, http.StatusConflict, "preview has not finished",
+					"WHY: poll GET /api/previews/<id> until status is done")
+				return
+			}
+			name := job.Title
+			if name == "" {
+				name = "Transcript"
+			}
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + " preview.mp4"}))
+			w.Header().Set("Content-Type", "video/mp4")
+			http.ServeFile(w, r, path)
+		case sub == "" && r.Method == http.MethodGet:
+			job, ok := previews.Get(id)
+			if !ok {
+				httputil.Error(w, r, logger, http.StatusNotFound, "preview not found",
+					"WHY: previews are kept for a day, in memory, and lost on restart")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(viewPreview(job))
+		case sub == "" && r.Method == http.MethodDelete:
+			if err := previews.Remove(id); errors.Is(err, preview.ErrNotFound) {
+				httputil.Error(w, r, logger, http.StatusNotFound, "preview not found", "")
+				return
+			} else if err != nil {
+				httputil.ServerError(w, r, logger, "failed to remove preview", "", err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/previews/<id> is GET (status) or DELETE (cancel/remove); /api/previews/<id>/file is GET")
+		}
+	}))
+
+	// --- Forced alignment ---
+	// POST /api/align times existing text against its audio, word by word:
+	// after heavy editing a transcript's Whisper timings no longer match
+	// its text, and subtitles need them to. JSON {"id", "text"} aligns
+	// the text with the transcript's recording; multipart with "text" and
+	// a "file" aligns an uploaded recording instead. ?format= (or a
+	// "format" field) json, srt or vtt.
+	aligner := align.New(cfg.AlignURL, backendTransport)
+	mux.HandleFunc("/api/align", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/align only accepts POST with the text and a transcript id or audio file")
+			return
+		}
+		if aligner.Backend() == "" {
+			httputil.Error(w, r, logger, http.StatusNotImplemented, "no forced-alignment backend",
+				"WHY: set CAPTAINSLOG_ALIGN_URL to a WhisperX-style service, or install aeneas (pip install aeneas)")
+			return
+		}
+
+		var req struct {
+			ID        string            `json:"id"`
+			Text      string            `json:"text"`
+			Language  string            `json:"language"`
+			Format    string            `json:"format"`
+			Subtitles *export.Subtitles `json:"subtitles"` // nil = the subtitles setting
+		}
+		var upload string
+		defer func() {
+			if upload != "" {
+				os.Remove(upload)
+			}
+		}()
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			r.Body = http.MaxBytesReader(w, r.Body, 2<<30)
+			mr, err := r.MultipartReader()
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+				return
+			}
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+					return
+				}
+				if part.FormName() == "file" {
+					f, err := os.CreateTemp("", "captainslog-align-*"+filepath.Ext(part.FileName()))
+					if err != nil {
+						httputil.ServerError(w, r, logger, "failed to store upload", "WHY: os.CreateTemp failed", err)
+						return
+					}
+					upload = f.Name()
+					_, err = io.Copy(f, part)
+					if cerr := f.Close(); err == nil {
+						err = cerr
+					}
+					if err != nil {
+						httputil.Error(w, r, logger, http.StatusBadRequest, "upload failed", err.Error())
+						return
+					}
+					continue
+				}
+				value, _ := io.ReadAll(io.LimitReader(part, 4<<20))
+				switch part.FormName() {
+				case "id":
+					req.ID = string(value)
+				case "text":
+					req.Text = string(value)
+				case "language":
+					req.Language = string(value)
+				case "format":
+					req.Format = string(value)
+				case "subtitles":
+					if err := json.Unmarshal(value, &req.Subtitles); err != nil {
+						httputil.Error(w, r, logger, http.StatusBadRequest, "subtitles must be a JSON object", err.Error())
+						return
+					}
+				}
+			}
+		} else if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&req); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", err.Error())
+			return
+		}
+		if v := r.URL.Query().Get("format"); v != "" {
+			req.Format = v
+		}
+		switch req.Format {
+		case "":
+			req.Format = "json"
+		case "json", "srt", "vtt":
+		default:
+			httputil.Error(w, r, logger, http.StatusBadRequest, "format must be json, srt or vtt", "")
+			return
+		}
+		if strings.TrimSpace(req.Text) == "" {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "missing text",
+				"WHY: alignment times the text you send against the audio")
+			return
+		}
+		settings.mu.RLock()
+		subtitles := settings.Subtitles
+		language := settings.Language
+		settings.mu.RUnlock()
+		if req.Subtitles != nil {
+			if err := req.Subtitles.Validate(); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			subtitles = *req.Subtitles
+		}
+
+		media := upload
+		if media == "" {
+			if req.ID == "" {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "missing audio — send a transcript \"id\" or upload a \"file\"",
+					"WHY: there is nothing to align the text with")
+				return
+			}
+			entry, err := index.Get(req.ID)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusNotFound, "transcript not found", "")
+				return
+			}
+			if entry.Recording == "" {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "transcript has no recording — upload the audio as \"file\"",
+					"WHY: there is nothing to align the text with")
+				return
+			}
+			media = filepath.Join(recordingsDir, entry.Recording)
+			if _, err := os.Stat(media); err != nil {
+				httputil.Error(w, r, logger, http.StatusNotFound, "recording not found",
+					"WHY: the recording linked to this transcript was deleted")
+				return
+			}
+			if req.Language == "" {
+				req.Language = entry.Language
+			}
+		}
+		if req.Language == "" && language != "und" {
+			req.Language = language
+		}
+
+		words, err := aligner.Align(r.Context(), media, req.Text, req.Language)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadGateway, "alignment failed: "+err.Error(),
+				"WHY: the "+aligner.Backend()+" backend could not align the text with the audio")
+			return
+		}
+		segs := align.Segments(words)
+		if req.Format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"backend": aligner.Backend(), "words": words, "segments": segs})
+			return
+		}
+		doc := export.Doc{Subtitles: subtitles, Segments: make([]export.Segment, len(segs))}
+		for i, s := range segs {
+			doc.Segments[i] = export.Segment{Start: s.Start, End: s.End, Text: s.Text}
+		}
+		out, err := export.Render(req.Format, doc)
+		if err != nil {
+			httputil.ServerError(w, r, logger, "subtitles failed", "WHY: rendering the aligned subtitles failed", err)
+			return
+		}
+		w.Header().Set("Content-Type", export.ContentType(req.Format))
+		w.Write(out)
+	}))
+
+	// --- Reading practice ---
+	// POST /api/reading scores a recorded reading of a text: multipart
+	// with the "text" read and the "file" recorded (and an optional
+	// "language"). The recording is transcribed with word timings and its
+	// words lined up with the text's, so the reply lists each word as
+	// correct, mismatched (read as another word), omitted or inserted.
+	mux.HandleFunc("/api/reading", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/reading only accepts POST with the text and the recorded reading")
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			httputil.Error(w, r, logger, http.StatusUnsupportedMediaType, "expected multipart/form-data",
+				"WHY: send the reference \"text\" and the recording as \"file\"")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+			return
+		}
+		var text, language, upload string
+		defer func() {
+			if upload != "" {
+				os.Remove(upload)
+			}
+		}()
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					httputil.Error(w, r, logger, http.StatusRequestEntityTooLarge,
+						fmt.Sprintf("upload exceeds %dMB", cfg.MaxUploadMB), "WHY: CAPTAINSLOG_MAX_UPLOAD caps uploads")
+					return
+				}
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+				return
+			}
+			switch part.FormName() {
+			case "file":
+				if upload != "" {
+					continue
+				}
+				f, err := os.CreateTemp("", "captainslog-reading-*"+filepath.Ext(part.FileName()))
+				if err != nil {
+					httputil.ServerError(w, r, logger, "failed to store upload", "WHY: os.CreateTemp failed", err)
+					return
+				}
+				upload = f.Name()
+				_, err = io.Copy(f, part)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "upload failed", err.Error())
+					return
+				}
+			case "text":
+				value, _ := io.ReadAll(io.LimitReader(part, 1<<20))
+				text = string(value)
+			case "language":
+				value, _ := io.ReadAll(io.LimitReader(part, 64))
+				language = strings.TrimSpace(string(value))
+			}
+		}
+		if strings.TrimSpace(text) == "" {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "missing text",
+				"WHY: the reading is scored against the \"text\" that was read")
+			return
+		}
+		if upload == "" {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "missing file",
+				"WHY: upload the recorded reading as \"file\"")
+			return
+		}
+
+		settings.mu.RLock()
+		model := settings.Model
+		if language == "" {
+			language = settings.Language
+		}
+		settings.mu.RUnlock()
+		body, err := transcribeFile(r.Context(), upload, model, language)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadGateway, "transcription failed: "+err.Error(),
+				"WHY: the Whisper backend could not transcribe the reading")
+			return
+		}
+		heard, err := reading.Transcript(body)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadGateway, "backend returned invalid JSON", err.Error())
+			return
+		}
+		var resp struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal(body, &resp)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			reading.Result
+			Heard string `json:"heard"`
+		}{reading.Score(text, heard), strings.TrimSpace(resp.Text)})
+	}))
+
+	// --- Model comparison ---
+	// POST /api/compare transcribes one recording with two models, or one
+	// model on two of the configured Whisper servers: multipart with the
+	// "file", "model_a" and "model_b" (default: the model setting),
+	// optional "backend_a" and "backend_b" (one of the Whisper URLs) and
+	// "language". The runs go one after the other, so they don't slow each
+	// other down on a shared GPU and their timings compare. The reply has
+	// both texts and timings and the words they disagree on (see
+	// internal/compare).
+	mux.HandleFunc("/api/compare", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/compare only accepts POST with the recording and the two models")
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			httputil.Error(w, r, logger, http.StatusUnsupportedMediaType, "expected multipart/form-data",
+				"WHY: send the recording as \"file\" and the models as \"model_a\" and \"model_b\"")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+			return
+		}
+		fields := map[string]string{}
+		var upload string
+		defer func() {
+			if upload != "" {
+				os.Remove(upload)
+			}
+		}()
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					httputil.Error(w, r, logger, http.StatusRequestEntityTooLarge,
+						fmt.Sprintf("upload exceeds %dMB", cfg.MaxUploadMB), "WHY: CAPTAINSLOG_MAX_UPLOAD caps uploads")
+					return
+				}
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid multipart body", err.Error())
+				return
+			}
+			switch name := part.FormName(); name {
+			case "file":
+				if upload != "" {
+					continue
+				}
+				f, err := os.CreateTemp("", "captainslog-compare-*"+filepath.Ext(part.FileName()))
+				if err != nil {
+					httputil.ServerError(w, r, logger, "failed to store upload", "WHY: os.CreateTemp failed", err)
+					return
+				}
+				upload = f.Name()
+				_, err = io.Copy(f, part)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "upload failed", err.Error())
+					return
+				}
+			case "model_a", "model_b", "backend_a", "backend_b", "language":
+				value, _ := io.ReadAll(io.LimitReader(part, 1024))
+				fields[name] = strings.TrimSpace(string(value))
+			}
+		}
+		if upload == "" {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "missing file",
+				"WHY: upload the recording to compare as \"file\"")
+			return
+		}
+
+		settings.mu.RLock()
+		model, language := settings.Model, fields["language"]
+		if language == "" {
+			language = settings.Language
+		}
+		whisperURLs, backendType := proxy.SplitURLs(settings.WhisperURL), settings.BackendType
+		settings.mu.RUnlock()
+		type side struct{ model, backend string }
+		sides := [2]side{{fields["model_a"], fields["backend_a"]}, {fields["model_b"], fields["backend_b"]}}
+		for i := range sides {
+			if sides[i].model == "" {
+				sides[i].model = model
+			}
+			if b := strings.TrimRight(sides[i].backend, "/"); b != "" {
+				// WHY only configured URLs: anything else would let a
+				// transcribe-scoped key point the server at any host.
+				if !slices.Contains(whisperURLs, b) {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "unknown backend: "+sides[i].backend,
+						"WHY: a backend must be one of the configured Whisper URLs")
+					return
+				}
+				sides[i].backend = b
+			}
+		}
+		if sides[0] == sides[1] {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "nothing to compare",
+				"WHY: model_a and model_b (or backend_a and backend_b) must differ")
+			return
+		}
+
+		var runs [2]compare.Run
+		for i, s := range sides {
+			wp := currentWhisperProxy()
+			if s.backend != "" {
+				wp = newWhisperProxy(s.backend, backendType)
+			}
+			start := time.Now()
+			body, err := transcribeFileWith(r.Context(), wp, upload, s.model, language)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadGateway, fmt.Sprintf("transcription with %s failed: %v", s.model, err),
+					"WHY: the Whisper backend could not transcribe the recording with this model")
+				return
+			}
+			if runs[i], err = compare.NewRun(s.model, s.backend, body, time.Since(start)); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadGateway, "backend returned invalid JSON", err.Error())
+				return
+			}
+		}
+		result := compare.Compare(runs[0], runs[1])
+		logger.Info("model comparison", "model_a", runs[0].Model, "model_b", runs[1].Model,
+			"elapsed_ms_a", runs[0].ElapsedMS, "elapsed_ms_b", runs[1].ElapsedMS, "agreement", result.Agreement)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}))
+
+	// --- Vault history scan ---
+	mux.HandleFunc("/api/history", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/history is GET only — reads vault directory")
+			return
+		}
+		// ?audience=shared|public returns only notes that audience may see
+		// (per their visibility: frontmatter). Default is the owner: everything.
+		audience := r.URL.Query().Get("audience")
+		if audience == "" {
+			audience = vault.AudienceOwner
+		}
+		if !vault.ValidAudience(audience) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "audience must be owner, shared or public",
+				"WHY: unknown audience — refusing rather than guessing who may see private notes")
+			return
+		}
+		// ?pinned=true returns only pinned notes, ?pinned=false only the rest.
+		var pinned *bool
+		if v := r.URL.Query().Get("pinned"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "pinned must be true or false", "")
+				return
+			}
+			pinned = &b
+		}
+		// ?tag=meeting returns only notes with that tag (any case).
+		tag := strings.TrimPrefix(r.URL.Query().Get("tag"), "#")
+		settings.mu.RLock()
+		dir := settings.VaultDir
+		settings.mu.RUnlock()
+
+		// ?limit= and/or ?cursor= page through the transcript index instead
+		// of reading the vault directory, reading only the notes on the page:
+		// {"entries": [...], "next_cursor": "..."}. Unindexed notes are left
+		// out; the consistency check indexes them. ?from= (inclusive) and
+		// ?to= (exclusive) narrow the pages to a date range, ?language=,
+		// ?model= and ?source= to the transcripts recorded with those, and
+		// ?min_words=, ?max_words=, ?min_wpm= and ?max_wpm= by length and
+		// speech rate. ?sort=words|reading_time|wpm (&order=asc) orders them.
+		q := r.URL.Query()
+		paged := q.Has("limit") || q.Has("cursor") || q.Has("from") || q.Has("to") ||
+			q.Has("language") || q.Has("model") || q.Has("source") ||
+			q.Has("min_words") || q.Has("max_words") || q.Has("min_wpm") || q.Has("max_wpm") ||
+			q.Has("sort") || q.Has("order")
+
+		if dir == "" {
+			// No vault configured — return empty array (not an error)
+			w.Header().Set("Content-Type", "application/json")
+			if paged {
+				w.Write([]byte(`{"entries":[]}`))
+			} else {
+				w.Write([]byte("[]"))
+			}
+			return
+		}
+
+		if paged {
+			limit := 0
+			if v := q.Get("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "limit must be a non-negative integer",
+						"WHY: limit is the page size for /api/history")
+					return
+				}
+				limit = n
+			}
+			from, err := parseDateParam(q.Get("from"))
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'from' — use YYYY-MM-DD or RFC 3339", err.Error())
+				return
+			}
+			to, err := parseDateParam(q.Get("to"))
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'to' — use YYYY-MM-DD or RFC 3339", err.Error())
+				return
+			}
+			dir = vault.ExpandDir(dir)
+			filter := store.Filter{From: from, To: to, Language: q.Get("language"), Model: q.Get("model"), Source: q.Get("source")}
+			order, err := parseMetricParams(q, &filter)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			notes := map[string]vault.Entry{}
+			page, next, err := index.PageSorted(q.Get("cursor"), limit, order, func(e store.Entry) bool {
+				// Only notes that pass the filter, still in the current
+				// vault, readable, and visible to the audience.
+				if !filter.Match(e) {
+					return false
+				}
+				if e.VaultFile == "" || filepath.Dir(e.VaultFile) != dir {
+					return false
+				}
+				note, err := vault.ReadEntry(e.VaultFile)
+				if err != nil || !vault.VisibleTo(note.Visibility, audience) {
+					return false
+				}
+				if pinned != nil && note.Pinned != *pinned {
+					return false
+				}
+				if tag != "" && !note.HasTag(tag) {
+					return false
+				}
+				setIndexFields(&note, e)
+				notes[e.ID] = note
+				return true
+			})
+			if errors.Is(err, store.ErrBadCursor) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid cursor",
+					"WHY: cursor must be a next_cursor value from an earlier /api/history page")
+				return
+			}
+			entries := make([]vault.Entry, 0, len(page))
+			for _, e := range page {
+				entries = append(entries, notes[e.ID])
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				Entries    []vault.Entry `json:"entries"`
+				NextCursor string        `json:"next_cursor,omitempty"`
+			}{entries, next})
+			return
+		}
+
+		entries, err := vault.Scan(dir, 200, logger)
+		if err != nil {
+			// Log with full context — never silent
+			logger.Warn("vault history scan failed", "dir", dir, "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+			return
+		}
+
+		entries = vault.FilterVisible(entries, audience)
+		if pinned != nil || tag != "" {
+			kept := entries[:0]
+			for _, e := range entries {
+				if (pinned == nil || e.Pinned == *pinned) && (tag == "" || e.HasTag(tag)) {
+					kept = append(kept, e)
+				}
+			}
+			entries = kept
+		}
+
+		// Link notes to their index entries so the UI can page segments.
+		indexed, err := index.List()
+		if err != nil {
+			// Non-fatal: the notes are listed, only without segment paging.
+			logger.Warn("history not linked to the transcript index", "error", err)
+		}
+		byFile := map[string]store.Entry{}
+		for _, e := range indexed {
+			byFile[e.VaultFile] = e
+		}
+		for i := range entries {
+			if e, ok := byFile[entries[i].File]; ok {
+				setIndexFields(&entries[i], e)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}))
+
+	// --- History calendar ---
+	// Per-day note counts and audio durations for one year, from the
+	// transcript index: the data behind the UI's activity heatmap.
+	// ?year= defaults to the current year; ?tz= (IANA name, e.g.
+	// Europe/Berlin) sets where days begin, defaulting to the server's zone.
+	mux.HandleFunc("/api/history/calendar", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/history/calendar is GET only — reads the transcript index")
+			return
+		}
+		q := r.URL.Query()
+		audience := q.Get("audience")
+		if audience == "" {
+			audience = vault.AudienceOwner
+		}
+		if !vault.ValidAudience(audience) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "audience must be owner, shared or public",
+				"WHY: unknown audience — refusing rather than guessing who may see private notes")
+			return
+		}
+		loc := time.Local
+		if tz := q.Get("tz"); tz != "" {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "unknown time zone",
+					"WHY: tz must be an IANA zone name such as Europe/Berlin")
+				return
+			}
+			loc = l
+		}
+		year := time.Now().In(loc).Year()
+		if v := q.Get("year"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 9999 {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "year must be a number like 2026", "")
+				return
+			}
+			year = n
+		}
+
+		settings.mu.RLock()
+		dir := settings.VaultDir
+		settings.mu.RUnlock()
+
+		resp := struct {
+			Year     int         `json:"year"`
+			TimeZone string      `json:"tz"`
+			Days     []store.Day `json:"days"`
+			Total    int         `json:"total"`
+			Duration float64     `json:"duration"`
+			Words    int         `json:"words"`
+		}{Year: year, TimeZone: loc.String(), Days: []store.Day{}}
+		if dir != "" {
+			dir = vault.ExpandDir(dir)
+			from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+			resp.Days = index.Calendar(from, from.AddDate(1, 0, 0), loc, func(e store.Entry) bool {
+				if e.VaultFile == "" || filepath.Dir(e.VaultFile) != dir {
+					return false
+				}
+				// The owner sees everything; other audiences need each
+				// note's visibility, so only they pay for reading notes.
+				if audience == vault.AudienceOwner {
+					return true
+				}
+				note, err := vault.ReadEntry(e.VaultFile)
+				return err == nil && vault.VisibleTo(note.Visibility, audience)
+			})
+		}
+		for _, d := range resp.Days {
+			resp.Total += d.Count
+			resp.Duration += d.Duration
+			resp.Words += d.Words
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	// --- History log ---
+	// Every transcription the server answered, newest first, saved to the
+	// vault or not (/api/history only sees the vault). GET ?limit=&offset=
+	// pages through it; DELETE clears it. /api/history/log/<id> is GET or
+	// DELETE for one entry.
+	historyLogOff := func(w http.ResponseWriter, r *http.Request) bool {
+		if historyLog != nil {
+			return false
+		}
+		httputil.Error(w, r, logger, http.StatusNotFound, "history log is off",
+			"WHY: CAPTAINSLOG_HISTORY_LOG_SIZE is 0")
+		return true
+	}
+	mux.HandleFunc("/api/history/log", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if historyLogOff(w, r) {
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			limit, offset := 50, 0
+			if v := q.Get("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "limit must be a positive integer",
+						"WHY: limit is the number of entries to return")
+					return
+				}
+				limit = min(n, 500)
+			}
+			if v := q.Get("offset"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					httputil.Error(w, r, logger, http.StatusBadRequest, "offset must be a non-negative integer",
+						"WHY: offset is the number of newer entries to skip")
+					return
+				}
+				offset = n
+			}
+			entries, total := historyLog.Page(offset, limit)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"entries": entries, "total": total, "limit": limit, "offset": offset})
+		case http.MethodDelete:
+			if err := historyLog.Clear(); err != nil {
+				httputil.ServerError(w, r, logger, "clear failed", "WHY: the history log file could not be rewritten", err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/history/log is GET (page) or DELETE (clear)")
+		}
+	}))
+	mux.HandleFunc("/api/history/log/", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if historyLogOff(w, r) {
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/history/log/")
+		if !httputil.HistoryID.Valid(id) {
+			httputil.Error(w, r, logger, http.StatusNotFound, "history entry not found", "")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			entry, err := historyLog.Get(id)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusNotFound, "history entry not found", "")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(entry)
+		case http.MethodDelete:
+			err := historyLog.Delete(id)
+			if errors.Is(err, history.ErrNotFound) {
+				httputil.Error(w, r, logger, http.StatusNotFound, "history entry not found", "")
+				return
+			}
+			if err != nil {
+				httputil.ServerError(w, r, logger, "delete failed", "WHY: the history log file could not be rewritten", err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/history/log/<id> is GET or DELETE")
+		}
+	}))
+
+	// --- Consistency check ---
+	// GET reports orphaned recordings, unindexed notes and dangling index
+	// entries; POST {"fix": [...]} repairs the chosen kinds and re-checks.
+	mux.HandleFunc("/api/admin/consistency", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/admin/consistency is GET (report) or POST (fix)")
+			return
+		}
+		settings.mu.RLock()
+		dir := settings.VaultDir
+		settings.mu.RUnlock()
+
+		// WHY a 10-minute grace? The UI uploads the recording before the vault
+		// save; a fresh recording without an entry is usually mid-save.
+		const grace = 10 * time.Minute
+		report, err := store.Check(index, dir, recordingsDir, grace)
+		if err != nil {
+			httputil.ServerError(w, r, logger, "consistency check failed",
+				"WHY: store.Check could not read the vault or recordings directory", err)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+
+		var body struct {
+			Fix []string `json:"fix"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&body); err != nil || len(body.Fix) == 0 {
+			httputil.Error(w, r, logger, http.StatusBadRequest,
+				"body must be {\"fix\": [...]} with one or more of: "+strings.Join(store.FixActions, ", "),
+				"WHY: fixes are destructive, so each kind must be named explicitly")
+			return
+		}
+		fixed, err := store.Fix(index, report, body.Fix)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		logger.Info("consistency fix applied", "actions", body.Fix, "fixed", fixed.Fixed, "errors", len(fixed.Errors))
+		after, err := store.Check(index, dir, recordingsDir, grace)
+		if err != nil {
+			httputil.ServerError(w, r, logger, "consistency re-check failed", "WHY: store.Check failed after fixing", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"fixed": fixed.Fixed, "errors": fixed.Errors, "report": after})
+	}))
+
+	// --- Post-processing pipeline ---
+	// GET /api/pipeline describes the configured steps and the step kinds;
+	// POST /api/pipeline/run {"text": "..."} (or {"id": "<transcript id>"})
+	// runs them in order through the configured LLM. "steps" in the body
+	// replaces the configured steps for that run.
+	mux.HandleFunc("/api/pipeline", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/pipeline is GET only — steps are changed through PUT /api/settings")
+			return
+		}
+		settings.mu.RLock()
+		steps := append([]pipeline.Step{}, settings.Pipeline...)
+		settings.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"steps":           steps,
+			"kinds":           pipeline.Kinds,
+			"default_prompts": pipeline.DefaultPrompts,
+		})
+	}))
+	mux.HandleFunc("/api/pipeline/run", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/pipeline/run is POST only")
+			return
+		}
+		var body struct {
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Steps []pipeline.Step `json:"steps"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", err.Error())
+			return
+		}
+
+		settings.mu.RLock()
+		enabled := settings.EnableLLM
+		llmURL := settings.LLMURL
+		llmModel := settings.LLMModel
+		steps := settings.Pipeline
+		settings.mu.RUnlock()
+		if body.Steps != nil {
+			steps = body.Steps
+		}
+
+		if !enabled || llmURL == "" {
+			httputil.Error(w, r, logger, http.StatusServiceUnavailable,
+				"LLM not enabled — enable in Settings → Connections",
+				"WHY: every pipeline step calls the LLM")
+			return
+		}
+		if err := pipeline.Validate(steps); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		if len(steps) == 0 {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "no pipeline steps",
+				`WHY: configure "pipeline" in settings or pass "steps"`)
+			return
+		}
+		text := body.Text
+		if body.ID != "" {
+			entry, err := index.Get(body.ID)
+			if err != nil || entry.VaultFile == "" {
+				httputil.Error(w, r, logger, http.StatusNotFound, "transcript not found",
+					"WHY: no transcript index entry with a note for this id")
+				return
+			}
+			doc, err := vault.ReadDocument(entry.VaultFile)
+			if err != nil {
+				httputil.ServerError(w, r, logger, "transcript note unreadable",
+					"WHY: the vault note for this transcript could not be read", err)
+				return
+			}
+			text = doc.Body
+		}
+		if strings.TrimSpace(text) == "" {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "text is empty",
+				`WHY: pass "text", or "id" of a saved transcript`)
+			return
+		}
+
+		res, err := pipeline.Run(r.Context(), llm.New(llmURL, llmModel, llm.WithTransport(llmTransport)), steps, text)
+		if err != nil {
+			// WHY 502? The steps were valid — the LLM failed.
+			httputil.Error(w, r, logger, http.StatusBadGateway, "pipeline failed: "+err.Error(),
+				"WHY: an LLM call failed — check the LLM server and model")
+			return
+		}
+		logger.Info("pipeline run", "steps", len(res.Steps), "chars", len([]rune(res.Text)))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+
+	// --- Bulk re-processing ---
+	// Re-runs LLM pipelines (summary, tags) over saved vault notes. Jobs run
+	// in the background one file at a time; poll GET /api/reprocess/<id>.
+	reprocessJobs := reprocess.NewManager(logger)
+	mux.HandleFunc("/api/reprocess", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"pipelines": reprocess.Names, "jobs": reprocessJobs.List()})
+			return
+		}
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/reprocess is GET (list jobs) or POST (start a job)")
+			return
+		}
+
+		var body struct {
+			Pipeline   string `json:"pipeline"`
+			From       string `json:"from"`
+			To         string `json:"to"`
+			Tag        string `json:"tag"`
+			ThrottleMS *int   `json:"throttle_ms"`
+			DryRun     bool   `json:"dry_run"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&body); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", err.Error())
+			return
+		}
+		filter := reprocess.Filter{Tag: body.Tag}
+		var err error
+		if filter.From, err = parseDateParam(body.From); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'from' — use YYYY-MM-DD or RFC 3339", err.Error())
+			return
+		}
+		if filter.To, err = parseDateParam(body.To); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'to' — use YYYY-MM-DD or RFC 3339", err.Error())
+			return
+		}
+		// WHY 500ms default? Enough gap for a live dictation's cleanup call to
+		// reach the LLM between re-processing requests.
+		throttle := 500 * time.Millisecond
+		if body.ThrottleMS != nil {
+			if *body.ThrottleMS < 0 {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "throttle_ms must be >= 0", "")
+				return
+			}
+			throttle = time.Duration(*body.ThrottleMS) * time.Millisecond
+		}
+
+		settings.mu.RLock()
+		dir := settings.VaultDir
+		enabled := settings.EnableLLM
+		llmURL := settings.LLMURL
+		llmModel := settings.LLMModel
+		settings.mu.RUnlock()
+
+		if dir == "" {
+			httputil.Error(w, r, logger, http.StatusNotImplemented, "vault not configured",
+				"WHY: settings.VaultDir is empty — nothing to re-process")
+			return
+		}
+		if !enabled || llmURL == "" {
+			httputil.Error(w, r, logger, http.StatusServiceUnavailable,
+				"LLM not enabled — enable in Settings → Connections",
+				"WHY: every re-processing pipeline calls the LLM")
+			return
+		}
+		pipeline, err := reprocess.Lookup(body.Pipeline, llm.New(llmURL, llmModel, llm.WithTransport(llmTransport)))
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+
+		job, err := reprocessJobs.Start(dir, reprocess.Request{
+			Pipeline: body.Pipeline,
+			Filter:   filter,
+			Throttle: throttle,
+			DryRun:   body.DryRun,
+		}, pipeline)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to start job", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	}))
+	// Digest: POST writes the summary note of a period now (a window
+	// ending now unless from/to are given); GET returns the last run.
+	mux.HandleFunc("/api/digest/run", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"last": digester.Last()})
+			return
+		}
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/digest/run is GET (last run) or POST (run now)")
+			return
+		}
+		var body struct {
+			Period string `json:"period"`
+			From   string `json:"from"`
+			To     string `json:"to"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&body); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", err.Error())
+				return
+			}
+		}
+		if body.Period == "" {
+			settings.mu.RLock()
+			body.Period = settings.DigestPeriod
+			settings.mu.RUnlock()
+		}
+		if !digest.ValidPeriod(body.Period) {
+			httputil.Error(w, r, logger, http.StatusBadRequest, `period must be "daily" or "weekly"`, "")
+			return
+		}
+		from, to := digest.Window(body.Period, time.Now())
+		if body.From != "" || body.To != "" {
+			var err error
+			if from, err = parseDateParam(body.From); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'from' — use YYYY-MM-DD or RFC 3339", err.Error())
+				return
+			}
+			if body.To == "" {
+				to = time.Now()
+			} else if to, err = parseDateParam(body.To); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid 'to' — use YYYY-MM-DD or RFC 3339", err.Error())
+				return
+			}
+			if !from.Before(to) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "'from' must be before 'to'", "")
+				return
+			}
+		}
+		res, err := digester.Run(r.Context(), body.Period, from, to)
+		switch {
+		case errors.Is(err, digest.ErrNoVault):
+			httputil.Error(w, r, logger, http.StatusNotImplemented, "vault not configured",
+				"WHY: settings.VaultDir is empty — nowhere to write the digest")
+			return
+		case errors.Is(err, digest.ErrNoLLM):
+			httputil.Error(w, r, logger, http.StatusServiceUnavailable,
+				"LLM not enabled — enable in Settings → Connections",
+				"WHY: the digest is a summary written by the LLM")
+			return
+		case err != nil:
+			httputil.Error(w, r, logger, http.StatusBadGateway, "digest failed", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+	mux.HandleFunc("/api/reprocess/", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/reprocess/")
+		switch r.Method {
+		case http.MethodGet:
+			job, ok := reprocessJobs.Get(id)
+			if !ok {
+				httputil.Error(w, r, logger, http.StatusNotFound, "job not found", "WHY: jobs are kept in memory and lost on restart")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(job)
+		case http.MethodDelete:
+			if !reprocessJobs.Cancel(id) {
+				httputil.Error(w, r, logger, http.StatusNotFound, "no running job with that id", "")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/reprocess/<id> is GET (progress) or DELETE (cancel)")
+		}
+	}))
+
+	// --- Server microphone (push-to-talk) ---
+	// For a headless box with a USB mic: POST /api/mic/start records from
+	// CAPTAINSLOG_MIC_DEVICE into the recordings folder, and POST
+	// /api/mic/stop ends it, transcribes it and answers with the text. The
+	// transcription goes where the watcher's do: the history log, the
+	// transcription.completed event and, with auto-save on, the vault.
+	micRecorder := mic.New(recordingsDir, cfg.MicDevice, cfg.MicMaxDuration, logger)
+	micUnavailable := func(w http.ResponseWriter, r *http.Request) bool {
+		if micRecorder.Available() {
+			return false
+		}
+		httputil.Error(w, r, logger, http.StatusNotImplemented, "no server microphone",
+			"WHY: set CAPTAINSLOG_MIC_DEVICE (e.g. default) and install arecord or ffmpeg")
+		return true
+	}
+	mux.HandleFunc("/api/mic", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/mic is GET (status); POST /api/mic/start and /api/mic/stop record")
+			return
+		}
+		if micUnavailable(w, r) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(micRecorder.Status())
+	}))
+	mux.HandleFunc("/api/mic/start", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/mic/start only accepts POST")
+			return
+		}
+		if micUnavailable(w, r) {
+			return
+		}
+		status, err := micRecorder.Start()
+		if errors.Is(err, mic.ErrBusy) {
+			httputil.Error(w, r, logger, http.StatusConflict, "already recording",
+				"WHY: the server has one microphone — POST /api/mic/stop first")
+			return
+		}
+		if err != nil {
+			httputil.ServerError(w, r, logger, "recording failed to start",
+				"WHY: check CAPTAINSLOG_MIC_DEVICE (arecord -l lists devices)", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}))
+	mux.HandleFunc("/api/mic/stop", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/mic/stop only accepts POST")
+			return
+		}
+		if micUnavailable(w, r) {
+			return
+		}
+		rec, err := micRecorder.Stop()
+		if errors.Is(err, mic.ErrNotRecording) {
+			httputil.Error(w, r, logger, http.StatusConflict, "not recording", "WHY: POST /api/mic/start first")
+			return
+		}
+		if err != nil {
+			httputil.ServerError(w, r, logger, "recording failed", "WHY: the recorder wrote no audio", err)
+			return
+		}
+
+		settings.mu.RLock()
+		model := settings.Model
+		lang := settings.Language
+		autoSave := settings.AutoSave
+		settings.mu.RUnlock()
+
+		f, err := os.Open(rec.Path)
+		if err != nil {
+			httputil.ServerError(w, r, logger, "recording unreadable", "WHY: os.Open on the new recording failed", err)
+			return
+		}
+		defer f.Close()
+		// Streamed, like /api/transcribe-url: a recording may run for minutes.
+		pr, pw := io.Pipe()
+		mpWriter := multipart.NewWriter(pw)
+		go func() {
+			part, err := mpWriter.CreateFormFile("file", rec.Filename)
+			if err == nil {
+				_, err = io.Copy(part, f)
+			}
+			if err == nil {
+				mpWriter.WriteField("response_format", "json")
+				mpWriter.WriteField("model", model)
+				if lang != "" && lang != "und" {
+					mpWriter.WriteField("language", lang)
+				}
+				err = mpWriter.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", pr).WithContext(r.Context())
+		req.Header.Set("Content-Type", mpWriter.FormDataContentType())
+		resp := httptest.NewRecorder()
+		currentWhisperProxy().Transcribe(resp, req)
+		pr.Close()
+		var result struct {
+			Text     string `json:"text"`
+			Language string `json:"language"`
+		}
+		if resp.Code != http.StatusOK || json.Unmarshal(resp.Body.Bytes(), &result) != nil {
+			eventBus.Publish(events.New(events.TypeTranscriptionFailed, "mic", events.TranscriptionFailed{
+				Filename: rec.Filename, Error: fmt.Sprintf("backend returned HTTP %d", resp.Code),
+			}))
+			httputil.Error(w, r, logger, http.StatusBadGateway,
+				fmt.Sprintf("transcription failed (HTTP %d); the recording is kept as %s", resp.Code, rec.Filename),
+				"WHY: the Whisper backend did not transcribe the recording — retry from /api/recordings")
+			return
+		}
+		text := strings.TrimSpace(result.Text)
+		if result.Language == "" && lang != "und" {
+			result.Language = lang
+		}
+
+		var file, id string
+		if autoSave && text != "" {
+			saved, err := saveNote(r.Context(), noteSave{Text: text, Language: result.Language, Model: model,
+				Recording: rec.Filename, Source: store.SourceMic})
+			if err != nil && !errors.Is(err, errNoVault) {
+				// Non-fatal: the text is in the response and the history log.
+				logger.Warn("mic transcription not saved to vault", "error", err)
+			}
+			file, id = saved.File, saved.ID
+		}
+		if file == "" {
+			// Saved notes announce their keywords; this one wasn't saved.
+			announceKeywords(store.SourceMic, "", "", rec.Filename, spotKeywords(text, nil))
+		}
+		if _, err := historyLog.Add(history.Entry{Text: text, Language: result.Language, Duration: rec.Seconds,
+			Source: history.SourceMic, Caller: auth.Caller(r.Context()), Filename: rec.Filename, VaultFile: file}); err != nil {
+			logger.Warn("transcription not logged", "error", err)
+		}
+		completed := events.TranscriptionCompleted{Filename: rec.Filename, Text: text, Language: result.Language, SavedTo: file}
+		if cfg.PrivacyMode {
+			completed.TextHash = redact.Hash(text)
+			completed.Text = redact.Truncate(text, 40)
+		}
+		eventBus.Publish(events.New(events.TypeTranscriptionCompleted, "mic", completed))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"text":      text,
+			"language":  result.Language,
+			"seconds":   rec.Seconds,
+			"recording": rec.Filename,
+			"file":      file,
+			"id":        id,
+		})
+	}))
+
+	// --- Background transcription jobs ---
+	// POST /api/jobs takes the same multipart upload as
+	// /v1/audio/transcriptions but answers 202 with a job ID at once; the
+	// upload is kept under configDir/jobs and transcribed by a worker, so an
+	// hour-long recording never has to hold a request open. Jobs survive a
+	// restart. With CAPTAINSLOG_SHARED_DIR any instance's worker may take it.
+	jobQueue, err := jobs.Open(filepath.Join(stateDir, "jobs"), func(ctx context.Context, body io.ReadSeeker, contentType string) ([]byte, string, error) {
+		settings.mu.RLock()
+		whisperURL := settings.WhisperURL
+		backendType := settings.BackendType
+		settings.mu.RUnlock()
+
+		// WHY through the proxy handler? Jobs get the same verbose_json
+		// upgrade and segment enrichment as a synchronous upload — only the
+		// backend timeout is longer.
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", body).WithContext(ctx)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		newWhisperProxy(whisperURL, backendType, proxy.WithTimeout(cfg.JobTimeout)).Transcribe(rec, req)
+		if rec.Code != http.StatusOK {
+			return nil, "", fmt.Errorf("backend returned HTTP %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+		entry := history.Parse(rec.Header().Get("Content-Type"), rec.Body.Bytes())
+		entry.Source = history.SourceJob
+		if _, err := historyLog.Add(entry); err != nil {
+			logger.Warn("transcription not logged", "error", err)
+		}
+		return rec.Body.Bytes(), rec.Header().Get("Content-Type"), nil
+	}, jobs.Options{
+		Workers:   cfg.JobWorkers,
+		MaxQueued: cfg.JobMaxQueued,
+		Retention: cfg.JobRetention,
+		Instance:  instanceID,
+		Shared:    cfg.SharedDir != "",
+	}, logger)
+	if err != nil {
+		logger.Error("failed to open job queue", "error", err)
+		os.Exit(1)
+	}
+	jobQueue.Run(bgCtx)
+
+	mux.HandleFunc("/api/jobs", withScope(auth.ScopeTranscribe, usageTracker.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"jobs": jobQueue.List()})
+			return
+		}
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/jobs is GET (list jobs) or POST (submit an upload)")
+			return
+		}
+		contentType := r.Header.Get("Content-Type")
+		if !strings.HasPrefix(contentType, "multipart/form-data") {
+			httputil.Error(w, r, logger, http.StatusUnsupportedMediaType, "expected multipart/form-data",
+				"WHY: jobs take the same form fields as /v1/audio/transcriptions")
+			return
+		}
+		// Same cap as the synchronous proxy, which replays the upload.
+		r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+		job, err := jobQueue.Submit(r.Body, contentType, r.URL.Query().Get("filename"))
+		if errors.Is(err, jobs.ErrQueueFull) {
+			w.Header().Set("Retry-After", "60")
+			httputil.Error(w, r, logger, http.StatusServiceUnavailable, "job queue is full — try again later",
+				"WHY: CAPTAINSLOG_JOB_MAX_QUEUED jobs are already waiting")
+			return
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				httputil.Error(w, r, logger, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("upload exceeds %dMB", cfg.MaxUploadMB), "WHY: CAPTAINSLOG_MAX_UPLOAD caps uploads")
+				return
+			}
+			httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to queue job", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"id": job.ID, "status": job.Status, "position": job.Position, "status_url": "/api/jobs/" + job.ID})
+	})))
+	mux.HandleFunc("/api/jobs/", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+		if !httputil.JobID.Valid(id) || (sub != "" && sub != "result") {
+			httputil.Error(w, r, logger, http.StatusNotFound, "job not found", "")
+			return
+		}
+		switch {
+		case sub == "result" && r.Method == http.MethodGet:
+			result, resultType, err := jobQueue.Result(id)
+			switch {
+			case errors.Is(err, jobs.ErrNotFound):
+				httputil.Error(w, r, logger, http.StatusNotFound, "job not found",
+					"WHY: finished jobs are deleted after CAPTAINSLOG_JOB_RETENTION")
+			case errors.Is(err, jobs.ErrNotDone):
+				httputil.Error(w, r, logger, http.StatusConflict, "job has not finished successfully",
+					"WHY: poll GET /api/jobs/<id> until status is done")
+			case err != nil:
+				httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to read result", err.Error())
+			default:
+				if resultType != "" {
+					w.Header().Set("Content-Type", resultType)
+				}
+				w.Write(result)
+			}
+		case sub == "" && r.Method == http.MethodGet:
+			job, ok := jobQueue.Get(id)
+			if !ok {
+				httputil.Error(w, r, logger, http.StatusNotFound, "job not found",
+					"WHY: finished jobs are deleted after CAPTAINSLOG_JOB_RETENTION")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(job)
+		case sub == "" && r.Method == http.MethodDelete:
+			if err := jobQueue.Remove(id); errors.Is(err, jobs.ErrNotFound) {
+				httputil.Error(w, r, logger, http.StatusNotFound, "job not found", "")
+				return
+			} else if err != nil {
+				httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to cancel job", err.Error())
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/jobs/<id> is GET (status) or DELETE (cancel/remove); /api/jobs/<id>/result is GET")
+		}
+	}))
+
+	// --- Stardate API ---
+	mux.HandleFunc("/api/stardate", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"stardate":  stardate.Now(),
+			"formatted": stardate.Format(now),
+			"earth":     now.Format(time.RFC3339),
+		})
+	})
+
+	// --- Event schema & webhook test ---
+	mux.HandleFunc("/api/events/schema", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events.Schema())
+	})
+	mux.HandleFunc("/api/events/test", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/events/test sends a delivery — POST only")
+			return
+		}
+		if webhook == nil {
+			httputil.Error(w, r, logger, http.StatusNotImplemented,
+				"no webhooks configured — set CAPTAINSLOG_WEBHOOK_URL",
+				"WHY: CAPTAINSLOG_WEBHOOK_URL is empty so there is nothing to test")
+			return
+		}
+		// Deliver synchronously so the caller sees each receiver's result.
+		env := events.New(events.TypeWebhookTest, "api", events.WebhookTest{Message: "Captain's Log webhook test"})
+		results := map[string]string{}
+		for _, u := range webhook.URLs() {
+			if err := webhook.Deliver(r.Context(), u, env); err != nil {
+				results[u] = err.Error()
+			} else {
+				results[u] = "ok"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"id": env.ID, "signed": cfg.WebhookSecret != "", "results": results})
+	}))
+
+	// --- Push notification test ---
+	mux.HandleFunc("/api/notify/test", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/notify/test sends a notification — POST only")
+			return
+		}
+		// Sent synchronously, past the cooldown, so the caller sees the result.
+		err := notifier.Send(r.Context(), notify.Message{
+			Title: "Captain's Log test",
+			Body:  "Notifications work. You'll hear about long transcriptions and failures here.",
+			Tags:  []string{"bell"},
+		})
+		switch {
+		case errors.Is(err, notify.ErrNotConfigured):
+			httputil.Error(w, r, logger, http.StatusNotImplemented,
+				"no notification provider configured — set notify_provider to ntfy or gotify",
+				"WHY: settings.NotifyProvider is empty so there is nothing to test")
+			return
+		case err != nil:
+			httputil.Error(w, r, logger, http.StatusBadGateway, "notification failed: "+err.Error(),
+				"WHY: the notification server refused the message or couldn't be reached")
+			return
+		}
+		settings.mu.RLock()
+		provider := settings.NotifyProvider
+		settings.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"provider": provider, "ok": true})
+	}))
+
+	// --- Stats & metrics ---
+	// /api/stats is the human-friendly JSON summary; /metrics is the same data
+	// in Prometheus text format for scraping.
+	mux.HandleFunc("/api/stats", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		entries, err := index.List()
+		if err != nil {
+			httputil.ServerError(w, r, logger, "stats failed",
+				"WHY: the transcript index could not be read", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"version":        version,
+			"started_at":     startedAt.UTC().Format(time.RFC3339),
+			"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+			"enrichment":     proxy.EnrichmentStats(metricsRegistry),
+			"transcripts":    transcriptStats(entries),
+		})
+	}))
+	// GET /api/stats/phrases is the speaking-habits report: the phrases and
+	// filler words that recur across the last ?limit= notes, counted per
+	// ?period= (week or month) so the latest can be compared with the one
+	// before. Notes are read from the vault on each call.
+	mux.HandleFunc("/api/stats/phrases", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/stats/phrases is a report — GET only")
+			return
+		}
+		q := r.URL.Query()
+		opts := phrases.Options{Period: phrases.PeriodMonth, Location: time.Local}
+		if v := q.Get("period"); v != "" {
+			if !phrases.ValidPeriod(v) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `period must be "week" or "month"`, "")
+				return
+			}
+			opts.Period = v
+		}
+		if tz := q.Get("tz"); tz != "" {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "unknown time zone",
+					"WHY: tz must be an IANA zone name such as Europe/Berlin")
+				return
+			}
+			opts.Location = l
+		}
+		limit := 100
+		for _, p := range []struct {
+			name string
+			dst  *int
+			max  int
+		}{{"limit", &limit, 1000}, {"top", &opts.Top, 100}} {
+			if v := q.Get(p.name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					httputil.Error(w, r, logger, http.StatusBadRequest, p.name+" must be a positive integer",
+						"WHY: limit is the number of recent notes read, top the number of phrases listed")
+					return
+				}
+				*p.dst = min(n, p.max)
+			}
+		}
+
+		entries, err := index.List()
+		if err != nil {
+			httputil.ServerError(w, r, logger, "phrases report failed",
+				"WHY: the transcript index could not be read for recent notes", err)
+			return
+		}
+		var notes []phrases.Note
+		seen := map[string]bool{} // a daily note holds many transcriptions
+		for _, e := range entries { // newest first
+			if len(notes) == limit {
+				break
+			}
+			if e.VaultFile == "" || seen[e.VaultFile] {
+				continue
+			}
+			seen[e.VaultFile] = true
+			doc, err := vault.ReadDocument(e.VaultFile)
+			if err != nil {
+				// Non-fatal: a note deleted or moved since is left out.
+				continue
+			}
+			notes = append(notes, phrases.Note{Time: e.CreatedAt, Text: doc.Body})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(phrases.Analyze(notes, opts))
+	}))
+	mux.Handle("/metrics", withAuth(metricsRegistry.Handler().ServeHTTP))
+
+	// --- Rate limiter state ---
+	// Who is being limited and how close each client is, so the limit can
+	// be tuned from what clients actually do. PUT changes the rate and
+	// window until restart; CAPTAINSLOG_RATE_LIMIT sets it for good.
+	mux.HandleFunc("/api/admin/ratelimit", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body struct {
+				Rate          *int     `json:"rate"`
+				WindowSeconds *float64 `json:"window_seconds"`
+			}
+			if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&body); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid JSON", "")
+				return
+			}
+			st := limiter.Stats()
+			rate, window := st.Rate, time.Duration(st.WindowSeconds*float64(time.Second))
+			if body.Rate != nil {
+				rate = *body.Rate
+			}
+			if body.WindowSeconds != nil {
+				window = time.Duration(*body.WindowSeconds * float64(time.Second))
+			}
+			if rate < 0 || window < time.Second {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "rate must be 0 or more and window_seconds at least 1",
+					"WHY: rate 0 turns limiting off; a window under a second would reset on nearly every request")
+				return
+			}
+			limiter.SetRate(rate, window)
+			logger.Info("rate limit changed", "rate", rate, "window", window)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/admin/ratelimit is GET (state) or PUT (change the rate)")
+			return
+		}
+		resp := map[string]any{"limiter": limiter.Stats()}
+		if uploadGuard.Enabled() {
+			resp["upload_guard"] = uploadGuard.Stats()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	// --- Runtime diagnostics ---
+	// pprof profiles, expvar counters and a goroutine dump, for memory
+	// growth or stuck requests in an instance that has run for weeks. Off
+	// unless CAPTAINSLOG_DEBUG_ENDPOINTS=true, and admin only: a heap
+	// profile or goroutine dump shows paths and what requests are doing.
+	if cfg.DebugEndpoints {
+		if cfg.AuthToken == "" {
+			logger.Warn("debug endpoints are on without CAPTAINSLOG_AUTH_TOKEN — anyone who can reach the server can profile it")
+		}
+		expvar.Publish("captainslog", expvar.Func(func() any {
+			return map[string]any{
+				"version":        version,
+				"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+				"goroutines":     runtime.NumGoroutine(),
+			}
+		}))
+		mux.HandleFunc("/debug/pprof/", withAuth(pprof.Index))
+		mux.HandleFunc("/debug/pprof/cmdline", withAuth(pprof.Cmdline))
+		mux.HandleFunc("/debug/pprof/profile", withAuth(pprof.Profile))
+		mux.HandleFunc("/debug/pprof/symbol", withAuth(pprof.Symbol))
+		mux.HandleFunc("/debug/pprof/trace", withAuth(pprof.Trace))
+		mux.HandleFunc("/debug/vars", withAuth(expvar.Handler().ServeHTTP))
+		// Every goroutine's stack as text, grouped by stack with ?debug=1.
+		goroutines := pprof.Handler("goroutine")
+		mux.HandleFunc("/api/admin/goroutines", withAuth(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("debug") == "" {
+				q.Set("debug", "2")
+				r.URL.RawQuery = q.Encode()
+			}
+			goroutines.ServeHTTP(w, r)
+		}))
+		logger.Info("debug endpoints enabled", "pprof", "/debug/pprof/", "expvar", "/debug/vars")
+	}
+
+	// persistSettings writes the settings to configFile. Callers run it in a
+	// goroutine after responding.
+	persistSettings := func() {
+		settings.mu.RLock()
+		data, err := json.MarshalIndent(settings, "", "  ")
+		settings.mu.RUnlock()
+		if err == nil {
+			if writeErr := os.WriteFile(configFile, data, 0600); writeErr != nil {
+				// WHY log only (no HTTP response)? This runs in a goroutine after
+				// the HTTP response has already been sent. Settings are applied in
+				// memory — persistence failure means they'll reset on restart.
+				logger.Error("failed to persist settings", "error", writeErr, "why", "os.WriteFile failed — settings applied in memory but won't survive restart")
+			} else {
+				logger.Info("settings persisted", "path", configFile)
+			}
+		}
+	}
+
+	// --- Folder watcher ---
+	// The watcher is started at the end of main() when watch_dir is set.
+	// /api/watcher/start and /stop control it at runtime, and changing
+	// watch_dir in settings restarts it on the new folder.
+	// The ledger of transcribed files is shared by every watcher, so a
+	// restart doesn't send a folder's old files to Whisper again. Losing it
+	// only costs repeat transcriptions, so a corrupt one is not fatal.
+	ledgerPath := filepath.Join(configDir, "watcher-ledger.json")
+	ledger, err := watcher.OpenLedger(ledgerPath)
+	if err != nil {
+		logger.Error("watcher ledger unreadable — moved aside, starting empty",
+			"error", err, "moved_to", ledgerPath+".corrupt")
+		os.Rename(ledgerPath, ledgerPath+".corrupt")
+		ledger, _ = watcher.OpenLedger(ledgerPath)
+	}
+	// indexWatcherNote adds a note the watcher saved to the transcript
+	// index, unless it is there already (the same file transcribed again
+	// overwrites its note).
+	indexWatcherNote := func(ev watcher.Event, lang string, hits []keywords.Hit) (id string) {
+		file := vault.ExpandDir(ev.SavedTo)
+		if len(hits) > 0 {
+			if err := vault.AddTags(file, keywordNames(hits)); err != nil {
+				logger.Warn("watch keywords not tagged in note", "file", file, "error", err)
+			}
+		}
+		entries, err := index.List()
+		if err != nil {
+			// Not added: it may be indexed already. The consistency check
+			// indexes it later if not.
+			logger.Warn("transcript index unreadable — watched note not indexed", "file", file, "error", err)
+			return ""
+		}
+		for _, e := range entries {
+			if e.VaultFile == file {
+				return e.ID
+			}
+		}
+		entry := store.Entry{VaultFile: file, Language: lang, Source: store.SourceWatcher, Keywords: keywordNames(hits)}
+		if ev.TextHash == "" {
+			// In privacy mode Text is only a preview.
+			entry.Chars = len([]rune(ev.Text))
+			entry.Words = store.CountWords(ev.Text)
+		}
+		entry, err = index.Add(entry)
+		if err != nil {
+			// Non-fatal: the consistency check indexes it later.
+			logger.Warn("transcript index update failed", "file", file, "error", err)
+		}
+		return entry.ID
+	}
+	// When each watched file started transcribing, to tell long
+	// transcriptions, which are worth a notification, from quick ones.
+	var watchStartedMu sync.Mutex
+	watchStarted := map[string]time.Time{}
+	notifyWatcher := func(ev watcher.Event) {
+		key := ev.Watcher + "\x00" + ev.Filename
+		watchStartedMu.Lock()
+		started, ok := watchStarted[key]
+		if ev.Type == "processing" {
+			watchStarted[key] = time.Now()
+		} else {
+			delete(watchStarted, key)
+		}
+		watchStartedMu.Unlock()
+
+		switch ev.Type {
+		case "transcription":
+			settings.mu.RLock()
+			minSeconds := settings.NotifyMinSeconds
+			settings.mu.RUnlock()
+			took := time.Since(started)
+			if !ok || took < time.Duration(minSeconds)*time.Second || !notifyOn(notifyTranscription) {
+				return
+			}
+			body := fmt.Sprintf("Transcribed in %s.", took.Round(time.Second))
+			if ev.SavedTo != "" {
+				body += " Saved to " + filepath.Base(ev.SavedTo) + "."
+			}
+			// No key: every transcription is worth its own message.
+			notifier.Alert("", notify.Message{
+				Title: "Transcribed " + ev.Filename,
+				Body:  body,
+				Tags:  []string{"white_check_mark"},
+			})
+		case "error":
+			if !notifyOn(notifyFailure) {
+				return
+			}
+			notifier.Alert("failure "+key, notify.Message{
+				Title:    "Could not transcribe " + ev.Filename,
+				Body:     ev.Error,
+				Priority: notify.PriorityHigh,
+				Tags:     []string{"x"},
+			})
+		}
+	}
+	watchOpts := []watcher.Option{
+		// Through the proxy, like jobs: the backend concurrency limit,
+		// retries, failover and the per-language models apply, and it
+		// preprocesses the audio.
+		watcher.WithTranscriber(func(w http.ResponseWriter, r *http.Request) {
+			currentWhisperProxy().Transcribe(w, r)
+		}),
+		watcher.WithDescriber(noteDescriber),
+		watcher.WithLedger(ledger),
+		watcher.WithSaveMonitor(&saveMonitor),
+		watcher.WithSpool(spoolMemory, cfg.SpoolDir),
+		watcher.WithNotify(func(ev watcher.Event) {
+			notifyWatcher(ev)
+			switch ev.Type {
+			case "transcription":
+				lang := ev.Language
+				if lang == "" {
+					settings.mu.RLock()
+					lang = settings.Language
+					settings.mu.RUnlock()
+				}
+				eventBus.Publish(events.New(events.TypeTranscriptionCompleted, "watcher", events.TranscriptionCompleted{
+					Filename: ev.Filename, Text: ev.Text, TextHash: ev.TextHash, Language: lang, SavedTo: ev.SavedTo,
+				}))
+				// In privacy mode Text is only a preview: too little to search.
+				var hits []keywords.Hit
+				if ev.TextHash == "" {
+					hits = spotKeywords(ev.Text, nil)
+				}
+				id := ""
+				if ev.SavedTo != "" {
+					id = indexWatcherNote(ev, lang, hits)
+				}
+				announceKeywords(store.SourceWatcher, id, ev.SavedTo, ev.Filename, hits)
+				// In privacy mode Text is only a preview: nothing to log.
+				if ev.TextHash == "" {
+					entry := history.Entry{Text: ev.Text, Language: lang, Source: history.SourceWatcher, Filename: ev.Filename}
+					if ev.SavedTo != "" {
+						entry.VaultFile = vault.ExpandDir(ev.SavedTo)
+					}
+					if _, err := historyLog.Add(entry); err != nil {
+						logger.Warn("transcription not logged", "error", err)
+					}
+				}
+			case "error":
+				eventBus.Publish(events.New(events.TypeTranscriptionFailed, "watcher", events.TranscriptionFailed{
+					Filename: ev.Filename, Error: ev.Error,
+				}))
+			}
+		}),
+	}
+	if cfg.PrivacyMode {
+		// WHY 40 runes? Enough for the UI toast to confirm which file
+		// finished, too little to carry the substance of a transcript.
+		watchOpts = append(watchOpts, watcher.WithPrivacy(40))
+	}
+	settings.mu.RLock()
+	fw := watcher.New(settings.WatchDir, primaryURL(cfg.WhisperURL), settings.VaultDir, settings.Language, logger, watchOpts...)
+	fw.SetRecursive(settings.WatchRecursive)
+	settings.mu.RUnlock()
+
+	// Watchers from the "watchers" setting, each on its own folder with its
+	// own language, vault subfolder and response format.
+	watcherManager := watcher.NewManager(primaryURL(cfg.WhisperURL), logger, watchOpts...)
+	applyWatchers := func() {
+		settings.mu.RLock()
+		cfgs, vaultDir, lang := settings.Watchers, settings.VaultDir, settings.Language
+		settings.mu.RUnlock()
+		if err := watcherManager.Apply(cfgs, vaultDir, lang); err != nil {
+			logger.Error("watchers not applied", "error", err)
+		}
+	}
+	// validateWatchers checks cfgs as a whole and against watch_dir.
+	validateWatchers := func(cfgs []watcher.Config, watchDir string, watchRecursive bool) error {
+		if err := watcher.Validate(cfgs); err != nil {
+			return err
+		}
+		for _, c := range cfgs {
+			if c.ID == "events" {
+				return fmt.Errorf("%w: id %q is reserved for /api/watchers/events", watcher.ErrInvalid, c.ID)
+			}
+			if watchDir != "" && filepath.Clean(c.Dir) == filepath.Clean(watchDir) {
+				return fmt.Errorf("%w: watcher %q watches watch_dir, which is already watched", watcher.ErrInvalid, c.ID)
+			}
+			if watchDir != "" && watchRecursive && watcher.Within(filepath.Clean(watchDir), filepath.Clean(c.Dir)) {
+				return fmt.Errorf("%w: watcher %q watches a subfolder of watch_dir, which is watched recursively", watcher.ErrInvalid, c.ID)
+			}
+			if watchDir != "" && c.Recursive && watcher.Within(filepath.Clean(c.Dir), filepath.Clean(watchDir)) {
+				return fmt.Errorf("%w: recursive watcher %q contains watch_dir, which is already watched", watcher.ErrInvalid, c.ID)
+			}
+		}
+		return nil
+	}
+
+	// restartWatcher stops the watcher and starts it again on dir with the
+	// current vault, language and watch_recursive. An empty dir leaves it
+	// stopped.
+	restartWatcher := func(dir string) error {
+		fw.Stop()
+		settings.mu.RLock()
+		vaultDir, lang, recursive := settings.VaultDir, settings.Language, settings.WatchRecursive
+		settings.mu.RUnlock()
+		if err := fw.Configure(dir, vaultDir, lang); err != nil {
+			return err
+		}
+		if err := fw.SetRecursive(recursive); err != nil {
+			return err
+		}
+		if dir == "" {
+			return nil
+		}
+		return fw.Start()
+	}
+
+	// SSE stream of watcher events. Mounted whether or not the watcher
+	// runs, so the UI sees it start and stop.
+	mux.HandleFunc("/api/watcher/events", withAuth(fw.SSEHandler()))
+
+	mux.HandleFunc("/api/watcher/status", withScope(auth.ScopeSettings, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed", "WHY: watcher status is read-only — use /api/watcher/start or /stop")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fw.Status())
+	}))
+
+	// POST /api/watcher/start {"dir": "...", "recursive": true} starts
+	// watching dir, or the watch_dir setting when dir is omitted; recursive
+	// defaults to watch_recursive. The settings are not changed.
+	mux.HandleFunc("/api/watcher/start", withScope(auth.ScopeSettings, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed", "WHY: starting the watcher changes state — use POST")
+			return
+		}
+		var body struct {
+			Dir       string `json:"dir"`
+			Recursive *bool  `json:"recursive"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body); err != nil && err != io.EOF {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `body must be {"dir": "...", "recursive": true} or empty`, err.Error())
+				return
+			}
+		}
+		dir := strings.TrimSpace(body.Dir)
+		if dir == "" {
+			settings.mu.RLock()
+			dir = settings.WatchDir
+			settings.mu.RUnlock()
+		}
+		if dir == "" {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "no folder to watch",
+				"WHY: pass {\"dir\": \"...\"} or set watch_dir (CAPTAINSLOG_WATCH_DIR)")
+			return
+		}
+		if fw.Running() {
+			httputil.Error(w, r, logger, http.StatusConflict, "folder watcher already running",
+				"WHY: stop it first with POST /api/watcher/stop")
+			return
+		}
+		settings.mu.RLock()
+		vaultDir, lang, recursive := settings.VaultDir, settings.Language, settings.WatchRecursive
+		settings.mu.RUnlock()
+		if body.Recursive != nil {
+			recursive = *body.Recursive
+		}
+		err := fw.Configure(dir, vaultDir, lang)
+		if err == nil {
+			err = fw.SetRecursive(recursive)
+		}
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusConflict, "folder watcher is busy",
+				"WHY: files from the last run are still being transcribed — try again when they finish")
+			return
+		}
+		if err := fw.Start(); err != nil {
+			if errors.Is(err, watcher.ErrBusy) {
+				httputil.Error(w, r, logger, http.StatusConflict, "folder watcher already running", "")
+				return
+			}
+			httputil.ServerError(w, r, logger, "folder watcher failed to start",
+				"WHY: the folder could not be created or watched — check the path and permissions", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fw.Status())
+	}))
+
+	mux.HandleFunc("/api/watcher/stop", withScope(auth.ScopeSettings, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed", "WHY: stopping the watcher changes state — use POST")
+			return
+		}
+		fw.Stop()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fw.Status())
+	}))
+
+	// --- Watchers (several folders, per-folder settings) ---
+	// GET /api/watchers lists them with their status; POST adds one.
+	// GET/PUT/DELETE /api/watchers/<id> reads, replaces or removes one.
+	// Changes are saved to the "watchers" setting and applied at once.
+	// /api/watchers/events streams all their events, tagged with "watcher".
+	mux.HandleFunc("/api/watchers/events", withAuth(watcherManager.SSEHandler()))
+
+	// updateWatchers applies fn to a copy of the watchers setting, then
+	// validates, saves and applies the result.
+	updateWatchers := func(fn func([]watcher.Config) ([]watcher.Config, error)) error {
+		settings.mu.Lock()
+		cfgs, err := fn(append([]watcher.Config(nil), settings.Watchers...))
+		if err == nil {
+			err = validateWatchers(cfgs, settings.WatchDir, settings.WatchRecursive)
+		}
+		if err != nil {
+			settings.mu.Unlock()
+			return err
+		}
+		settings.Watchers = cfgs
+		settings.mu.Unlock()
+		applyWatchers()
+		go persistSettings()
+		return nil
+	}
+	// watcherError maps an updateWatchers error to a response.
+	errWatcherNotFound := errors.New("no watcher with this id")
+	watcherError := func(w http.ResponseWriter, r *http.Request, err error) {
+		switch {
+		case errors.Is(err, errWatcherNotFound):
+			httputil.Error(w, r, logger, http.StatusNotFound, err.Error(), "WHY: GET /api/watchers lists the configured ids")
+		case errors.Is(err, watcher.ErrInvalid):
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "WHY: the watcher config failed validation")
+		default:
+			httputil.ServerError(w, r, logger, "watchers not updated", "WHY: unexpected error applying the watchers", err)
+		}
+	}
+	decodeWatcher := func(w http.ResponseWriter, r *http.Request) (watcher.Config, bool) {
+		var c watcher.Config
+		if err := json.NewDecoder(io.LimitReader(r.Body, 16<<10)).Decode(&c); err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, "invalid watcher JSON",
+				`WHY: body must be {"dir": "...", "language": "", "vault_subdir": "", "response_format": "", "paused": false, "recursive": false}`)
+			return c, false
+		}
+		return c, true
+	}
 
-		entries, err := vault.Scan(dir, 200, logger)
-		if err != nil {
-			// Log with full context — never silent
-			logger.Warn("vault history scan failed", "dir", dir, "error", err)
+	mux.HandleFunc("/api/watchers", withScope(auth.ScopeSettings, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
 			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte("[]"))
-			return
+			json.NewEncoder(w).Encode(map[string]any{"watchers": watcherManager.List(), "response_formats": watcher.ResponseFormats})
+		case http.MethodPost:
+			c, ok := decodeWatcher(w, r)
+			if !ok {
+				return
+			}
+			err := updateWatchers(func(cfgs []watcher.Config) ([]watcher.Config, error) {
+				if c.ID == "" {
+					c.ID = watcherID(c.Dir, cfgs)
+				}
+				return append(cfgs, c), nil
+			})
+			if err != nil {
+				watcherError(w, r, err)
+				return
+			}
+			info, _ := watcherManager.Get(c.ID)
+			logger.Info("watcher added", "watcher", c.ID, "dir", c.Dir)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(info)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: GET lists watchers, POST adds one — use /api/watchers/<id> for the rest")
 		}
+	}))
 
-		w.Header().Set("Content-Type", "application/json")
-		if entries == nil {
-			w.Write([]byte("[]"))
-			return
+	mux.HandleFunc("/api/watchers/", withScope(auth.ScopeSettings, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/watchers/")
+		switch r.Method {
+		case http.MethodGet:
+			info, ok := watcherManager.Get(id)
+			if !ok {
+				watcherError(w, r, errWatcherNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(info)
+		case http.MethodPut, http.MethodDelete:
+			var c watcher.Config
+			if r.Method == http.MethodPut {
+				var ok bool
+				if c, ok = decodeWatcher(w, r); !ok {
+					return
+				}
+				c.ID = id // the path names the watcher; an id in the body can't rename it
+			}
+			err := updateWatchers(func(cfgs []watcher.Config) ([]watcher.Config, error) {
+				for i := range cfgs {
+					if cfgs[i].ID != id {
+						continue
+					}
+					if r.Method == http.MethodDelete {
+						return append(cfgs[:i], cfgs[i+1:]...), nil
+					}
+					cfgs[i] = c
+					return cfgs, nil
+				}
+				return nil, errWatcherNotFound
+			})
+			if err != nil {
+				watcherError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == http.MethodDelete {
+				logger.Info("watcher removed", "watcher", id)
+				json.NewEncoder(w).Encode(map[string]string{"deleted": id})
+				return
+			}
+			info, _ := watcherManager.Get(id)
+			logger.Info("watcher updated", "watcher", id, "dir", c.Dir)
+			json.NewEncoder(w).Encode(info)
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/watchers/<id> accepts GET, PUT and DELETE")
 		}
-		json.NewEncoder(w).Encode(entries)
 	}))
-	// --- Stardate API ---
-	mux.HandleFunc("/api/stardate", func(w http.ResponseWriter, r *http.Request) {
-		now := time.Now()
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"stardate":  stardate.Now(),
-			"formatted": stardate.Format(now),
-			"earth":     now.Format(time.RFC3339),
-		})
-	})
 
 	// --- Settings API ---
 	mux.HandleFunc("/api/settings", func(w http.ResponseWriter, r *http.Request) {
@@ -641,17 +5037,10 @@ func main() {
 			json.NewEncoder(w).Encode(settings)
 			settings.mu.RUnlock()
 		case http.MethodPut:
-			// Auth required for writes when token is configured
-			if cfg.AuthToken != "" {
-				expected := []byte("Bearer " + cfg.AuthToken)
-				token := []byte(r.Header.Get("Authorization"))
-				if subtle.ConstantTimeCompare(token, expected) != 1 {
-					// WHY 401? Settings writes require auth when a token is configured.
-					// Prevents unauthorized settings changes over the network.
-					httputil.Error(w, r, logger, http.StatusUnauthorized, "unauthorized",
-						"WHY: settings PUT requires valid Bearer token when auth is configured")
-					return
-				}
+			// Auth required for writes when token is configured.
+			// Prevents unauthorized settings changes over the network.
+			if _, ok := authorize(w, r, auth.ScopeSettings); !ok {
+				return
 			}
 			r.Body = http.MaxBytesReader(w, r.Body, 64<<10) // 64KB limit
 			var update runtimeSettings
@@ -661,6 +5050,115 @@ func main() {
 					"WHY: settings JSON decode failed — malformed body or exceeded 64KB limit")
 				return
 			}
+			if !proxy.ValidBackendType(update.BackendType) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "backend_type must be auto, openai or whispercpp", "")
+				return
+			}
+			if err := pipeline.Validate(update.Pipeline); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+					"WHY: pipeline steps are checked before they are saved, not when they first run")
+				return
+			}
+			if !vault.ValidAttachMode(update.AttachAudio) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `attach_audio must be "", "copy" or "move"`, "")
+				return
+			}
+			if update.AttachmentsDir != "" && !filepath.IsLocal(update.AttachmentsDir) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "attachments_dir must be a relative path inside the vault",
+					"WHY: recordings are copied there — it must not point outside the vault")
+				return
+			}
+			if !vault.ValidMode(update.VaultMode) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `vault_mode must be "" or "daily"`, "")
+				return
+			}
+			if update.DailyNoteFolder != "" && !filepath.IsLocal(update.DailyNoteFolder) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "daily_note_folder must be a relative path inside the vault",
+					"WHY: saves are appended there — it must not point outside the vault")
+				return
+			}
+			if err := vault.ParseDailyEntry(update.DailyNoteEntry); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+					"WHY: the entry template is checked before it is saved, not on the next save")
+				return
+			}
+			if !vault.ValidDailyOrder(update.DailyNoteOrder) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `daily_note_order must be "bottom" or "top"`, "")
+				return
+			}
+			if update.DigestSchedule != "" {
+				if _, err := digest.ParseSchedule(update.DigestSchedule); err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+						"WHY: a schedule that doesn't parse would silently never run")
+					return
+				}
+			}
+			if err := update.Subtitles.Validate(); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			if update.DigestPeriod != "" && !digest.ValidPeriod(update.DigestPeriod) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `digest_period must be "daily" or "weekly"`, "")
+				return
+			}
+			if update.BilingualLayout != "" && !bilingual.ValidLayout(update.BilingualLayout) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, `bilingual_layout must be "columns" or "interleaved"`, "")
+				return
+			}
+			if update.DigestFolder != "" && !filepath.IsLocal(update.DigestFolder) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "digest_folder must be a relative path inside the vault",
+					"WHY: digest notes are written there — it must not point outside the vault")
+				return
+			}
+			if update.NotifyProvider != "" {
+				// The token is a secret and isn't sent here; it's checked by /api/notify/test.
+				nc := notify.Config{Provider: update.NotifyProvider, URL: update.NotifyURL, Topic: update.NotifyTopic, Token: "set"}
+				if err := nc.Validate(); err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+						"WHY: a notification setting that can't work would fail silently when it matters")
+					return
+				}
+			}
+			if !validNotifyOn(update.NotifyOn) {
+				httputil.Error(w, r, logger, http.StatusBadRequest,
+					"notify_on may only list "+strings.Join(notifyEvents, ", "), "")
+				return
+			}
+			settings.mu.RLock()
+			vaultDir, noteTemplate := settings.VaultDir, settings.NoteTemplate
+			settings.mu.RUnlock()
+			if update.VaultDir != "" {
+				vaultDir = update.VaultDir
+			}
+			// Checked only when it changes: the UI sends every setting, and a
+			// template deleted since must not block saving the others.
+			if update.NoteTemplate != noteTemplate {
+				if err := vault.CheckTemplate(vault.ExpandDir(vaultDir), update.NoteTemplate); err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+						"WHY: a note template that can't be read or parsed would fall back to the built-in format on every save")
+					return
+				}
+			}
+			if update.Watchers != nil {
+				if err := validateWatchers(update.Watchers, update.WatchDir, update.WatchRecursive); err != nil {
+					httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+						"WHY: watchers are checked before they are saved, not when they first start")
+					return
+				}
+			}
+			for _, u := range append(proxy.SplitURLs(update.WhisperURL), update.LLMURL) {
+				if u == "" {
+					continue
+				}
+				if err := urlPolicy.CheckURL(u); err != nil {
+					// WHY 400? The server fetches these URLs itself. Without the
+					// check, settings PUT turns the server into an open proxy
+					// into its own network (cloud metadata, admin panels).
+					httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+						"WHY: backend URL rejected by CAPTAINSLOG_URL_SCHEMES / CAPTAINSLOG_URL_ALLOW_HOSTS / CAPTAINSLOG_BLOCK_LINK_LOCAL")
+					return
+				}
+			}
 			settings.mu.Lock()
 			if update.VaultDir != "" {
 				settings.VaultDir = update.VaultDir
@@ -674,6 +5172,10 @@ func main() {
 			if update.Model != "" {
 				settings.Model = update.Model
 			}
+			settings.DraftModel = strings.TrimSpace(update.DraftModel)
+			if update.LanguageModels != nil {
+				settings.LanguageModels = proxy.CleanLanguageModels(update.LanguageModels)
+			}
 			settings.AutoSave = update.AutoSave
 			settings.AutoCopy = update.AutoCopy
 			settings.Prompt = update.Prompt
@@ -686,9 +5188,17 @@ func main() {
 			if update.FileTitle != "" {
 				settings.FileTitle = update.FileTitle
 			}
-			if update.WhisperURL != "" {
+			rebuild := false
+			if update.WhisperURL != "" && update.WhisperURL != settings.WhisperURL {
 				settings.WhisperURL = update.WhisperURL
-				whisperProxy = proxy.New(update.WhisperURL, logger)
+				rebuild = true
+			}
+			if update.BackendType != "" && update.BackendType != settings.BackendType {
+				settings.BackendType = update.BackendType
+				rebuild = true
+			}
+			if rebuild {
+				whisperProxy = newWhisperProxy(settings.WhisperURL, settings.BackendType)
 			}
 			if update.LLMURL != "" {
 				settings.LLMURL = update.LLMURL
@@ -710,6 +5220,7 @@ func main() {
 			}
 			// Advanced transcription parameters
 			settings.WordTimestamps = update.WordTimestamps
+			settings.DisableEnrichment = update.DisableEnrichment
 			if update.BeamSize > 0 {
 				settings.BeamSize = update.BeamSize
 			}
@@ -720,27 +5231,82 @@ func main() {
 			if update.ExportMode != "" {
 				settings.ExportMode = update.ExportMode
 			}
+			settings.Subtitles = update.Subtitles
 			settings.TranscriptDir = update.TranscriptDir
 			settings.TranslateDir = update.TranslateDir
+			watchChanged := settings.WatchDir != update.WatchDir || settings.WatchRecursive != update.WatchRecursive
 			settings.WatchDir = update.WatchDir
+			settings.WatchRecursive = update.WatchRecursive
+			settings.RecordingMaxAgeDays = max(update.RecordingMaxAgeDays, 0)
+			settings.RecordingMaxSizeMB = max(update.RecordingMaxSizeMB, 0)
+			settings.ArchiveAfterMonths = max(update.ArchiveAfterMonths, 0)
+			settings.UndoWindowSeconds = max(update.UndoWindowSeconds, 0)
+			if update.DefaultTags != nil {
+				settings.DefaultTags = vault.CleanTags(update.DefaultTags)
+			}
+			settings.AttachAudio = update.AttachAudio
+			settings.AttachmentsDir = update.AttachmentsDir
+			settings.VaultMode = update.VaultMode
+			settings.DailyNoteFolder = update.DailyNoteFolder
+			settings.DailyNoteFormat = update.DailyNoteFormat
+			settings.DailyNoteEntry = update.DailyNoteEntry
+			settings.DailyNoteOrder = update.DailyNoteOrder
+			settings.DailyNoteTOC = update.DailyNoteTOC
+			settings.NoteTemplate = update.NoteTemplate
+			settings.VaultSidecar = update.VaultSidecar
+			settings.LiveDictation = update.LiveDictation
+			settings.LLMDescribe = update.LLMDescribe
+			settings.LanguageLearning = update.LanguageLearning
+			if t := strings.TrimSpace(update.TranslateTo); t != "" {
+				settings.TranslateTo = t
+			}
+			if update.BilingualLayout != "" {
+				settings.BilingualLayout = update.BilingualLayout
+			}
+			settings.DigestSchedule = update.DigestSchedule
+			if update.DigestPeriod != "" {
+				settings.DigestPeriod = update.DigestPeriod
+			}
+			settings.DigestFolder = update.DigestFolder
+			settings.NotifyProvider = update.NotifyProvider
+			settings.NotifyURL = update.NotifyURL
+			settings.NotifyTopic = update.NotifyTopic
+			if update.NotifyOn != nil {
+				settings.NotifyOn = update.NotifyOn
+			}
+			settings.NotifyMinSeconds = max(update.NotifyMinSeconds, 0)
+			if update.WatchKeywords != nil {
+				settings.WatchKeywords = keywords.Clean(update.WatchKeywords)
+			}
+			settings.PreprocessAudio = update.PreprocessAudio
+			settings.PreprocessNormalize = update.PreprocessNormalize
+			settings.PreprocessTrimSilence = update.PreprocessTrimSilence
+			settings.ChunkAfterMinutes = max(update.ChunkAfterMinutes, 0)
+			if update.ChunkMinutes > 0 {
+				settings.ChunkMinutes = update.ChunkMinutes
+			}
+			if update.ChunkConcurrency > 0 {
+				settings.ChunkConcurrency = update.ChunkConcurrency
+			}
+			if update.Pipeline != nil {
+				settings.Pipeline = update.Pipeline
+			}
+			if update.Watchers != nil {
+				settings.Watchers = update.Watchers
+			}
 			settings.mu.Unlock()
+			// Watchers fall back to the vault and language, which may have changed.
+			applyWatchers()
 
-			// Persist to file
-			go func() {
-				settings.mu.RLock()
-				data, err := json.MarshalIndent(settings, "", "  ")
-				settings.mu.RUnlock()
-				if err == nil {
-					if writeErr := os.WriteFile(configFile, data, 0600); writeErr != nil {
-						// WHY log only (no HTTP response)? This runs in a goroutine after
-						// the HTTP response has already been sent. Settings are applied in
-						// memory — persistence failure means they'll reset on restart.
-						logger.Error("failed to persist settings", "error", writeErr, "why", "os.WriteFile failed — settings applied in memory but won't survive restart")
-					} else {
-						logger.Info("settings persisted", "path", configFile)
-					}
+			if watchChanged {
+				if err := restartWatcher(update.WatchDir); err != nil {
+					logger.Warn("folder watcher not restarted on the new folder", "dir", update.WatchDir, "error", err,
+						"why", "files from the last run may still be in flight — use POST /api/watcher/start once they finish")
 				}
-			}()
+			}
+
+			// Persist to file
+			go persistSettings()
 
 			logger.Info("settings updated", "vault_dir", settings.VaultDir, "language", settings.Language)
 			json.NewEncoder(w).Encode(map[string]string{"status": "saved"})
@@ -751,7 +5317,126 @@ func main() {
 		}
 	})
 
+	// --- API keys ---
+	// GET lists keys, POST {"name","scopes"} creates one and returns its
+	// secret, once; DELETE /api/keys/<id> revokes one.
+	mux.HandleFunc("/api/keys", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"keys": apiKeys.List(), "scopes": auth.Scopes})
+		case http.MethodPost:
+			if cfg.AuthToken == "" {
+				// WHY refuse? Without the token every endpoint is open; a key
+				// would restrict nothing, and its holder would think it did.
+				httputil.Error(w, r, logger, http.StatusConflict, "API keys need CAPTAINSLOG_AUTH_TOKEN",
+					"WHY: without an auth token the server is open and keys restrict nothing")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+			var req struct {
+				Name   string   `json:"name"`
+				Scopes []string `json:"scopes"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, "invalid request body",
+					`WHY: expected {"name": "...", "scopes": ["transcribe"]}`)
+				return
+			}
+			scopes, err := auth.ParseScopes(req.Scopes)
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			key, token, err := apiKeys.Create(req.Name, scopes)
+			if errors.Is(err, auth.ErrInvalid) {
+				httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(), "")
+				return
+			}
+			if err != nil {
+				httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to save API key", err.Error())
+				return
+			}
+			logger.Info("API key created", "id", key.ID, "name", key.Name, "scopes", key.Scopes)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"key": key, "token": token})
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/keys is GET (list) or POST (create); revoke with DELETE /api/keys/<id>")
+		}
+	}))
+	mux.HandleFunc("/api/keys/", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/keys/<id> only accepts DELETE")
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/keys/")
+		if err := apiKeys.Revoke(id); errors.Is(err, auth.ErrNotFound) {
+			httputil.Error(w, r, logger, http.StatusNotFound, "no such API key", "")
+			return
+		} else if err != nil {
+			httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to revoke API key", err.Error())
+			return
+		}
+		logger.Info("API key revoked", "id", id)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// --- Usage ---
+	// GET /api/usage?from=YYYY-MM-DD&to=YYYY-MM-DD&by=key|ip: daily
+	// transcription totals per caller (API key name, "token", "cert:<CN>"
+	// or "anonymous") or per client IP. Defaults to the last 30 days by key.
+	mux.HandleFunc("/api/usage", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/usage is read-only")
+			return
+		}
+		q := r.URL.Query()
+		now := time.Now()
+		from, to, by := q.Get("from"), q.Get("to"), q.Get("by")
+		if to == "" {
+			to = now.Format("2006-01-02")
+		}
+		if from == "" {
+			from = now.AddDate(0, 0, -29).Format("2006-01-02")
+		}
+		if by == "" {
+			by = usage.ByKey
+		}
+		days, totals, err := usageTracker.Query(from, to, by)
+		if err != nil {
+			httputil.Error(w, r, logger, http.StatusBadRequest, err.Error(),
+				"WHY: from and to are YYYY-MM-DD dates and by is key or ip")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"from": from, "to": to, "by": by, "days": days, "totals": totals})
+	}))
+
 	// --- Health ---
+	// /readyz is the probe for `captainslog healthcheck`, Docker and
+	// orchestrators: 200 while serving, 503 once shutdown has begun, so a
+	// load balancer stops sending work before connections drain. With
+	// ?whisper=1 it also needs a reachable Whisper backend.
+	var shuttingDown atomic.Bool
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		status, code := "ready", http.StatusOK
+		if shuttingDown.Load() {
+			status, code = "shutting down", http.StatusServiceUnavailable
+		} else if r.URL.Query().Get("whisper") == "1" {
+			if err := currentWhisperProxy().Health(); err != nil {
+				status, code = "whisper unreachable", http.StatusServiceUnavailable
+			}
+		}
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"status": status})
+	})
+
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		settings.mu.RLock()
 		vaultDir := settings.VaultDir
@@ -766,10 +5451,17 @@ func main() {
 			"timestamp": time.Now().UTC().Format(time.RFC3339),
 			"stardate":  stardate.Now(),
 			"version":   version,
+			"instance":  instanceID,
 			"whisper":   "unknown",
 			"llm":       "disabled",
 			"vault":     vaultDir != "",
 			"tls":       cfg.EnableTLS,
+			"ffmpeg":    audioConverter.Available(),
+		}
+		// Every note is read back after it is written; a failure here means
+		// notes may be missing or truncated.
+		if last, saves, failures, ok := saveMonitor.Last(); ok {
+			status["vault_save"] = map[string]any{"last": last, "saves": saves, "failures": failures}
 		}
 
 		// Diagnostics (for troubleshooting)
@@ -781,6 +5473,50 @@ func main() {
 			"rate_limit":   cfg.RateLimit,
 			"access_log":   accessLogOn,
 			"log_format":   logFormat,
+			"privacy_mode": cfg.PrivacyMode,
+			// Where each secret came from — never the value.
+			"secrets": map[string]string{
+				"auth_token":      authToken.Source(),
+				"webhook_secret":  webhookSecret.Source(),
+				"llm_api_key":     llmAPIKey.Source(),
+				"whisper_api_key": whisperAPIKey.Source(),
+				"notify_token":      notifyToken.Source(),
+				"tailscale_authkey": tailscaleAuthKey.Source(),
+				"rate_redis_url":    rateRedis.Source(),
+				"postgres_url":      postgresURL.Source(),
+			},
+		}
+		if uploadGuard.Enabled() {
+			diag["upload_guard"] = uploadGuard.Stats()
+		}
+		diag["backend_pool"] = backendPool.Stats()
+		diag["api_keys"] = apiKeys.Len()
+		if framePolicy.Enabled() {
+			diag["frame_ancestors"] = framePolicy.Ancestors()
+		}
+		diag["url_policy"] = map[string]any{
+			"schemes":          cfg.URLSchemes,
+			"allow_hosts":      cfg.URLAllowHosts,
+			"block_link_local": cfg.BlockLinkLocal,
+		}
+		if cfg.BackendCAFile != "" || cfg.BackendTLSInsecure {
+			diag["backend_tls"] = map[string]any{
+				"ca_file":  cfg.BackendCAFile,
+				"insecure": cfg.BackendTLSInsecure,
+			}
+		}
+		if req, err := http.NewRequest(http.MethodGet, primaryURL(whisperURL), nil); err == nil {
+			if u, err := http.ProxyFromEnvironment(req); err == nil && u != nil {
+				// Host only — proxy URLs often carry credentials.
+				diag["whisper_proxy"] = u.Host
+			}
+		}
+		if cfg.EnableTLS {
+			diag["tls_policy"] = tlsPolicy.Summary()
+			diag["tls_client_auth"] = cfg.TLSClientAuth
+		}
+		if chaosTransport != nil {
+			diag["chaos"] = map[string]any{"config": chaosCfg, "injected": chaosTransport.Stats()}
 		}
 		if vaultDir != "" {
 			if _, err := os.Stat(vaultDir); err != nil {
@@ -795,16 +5531,21 @@ func main() {
 			diag["settings_file_exists"] = true
 		}
 
-		if err := whisperProxy.Health(); err != nil {
+		wp := currentWhisperProxy()
+		if err := wp.Health(); err != nil {
 			status["whisper"] = "unreachable"
 			diag["whisper_error"] = err.Error()
 		} else {
 			status["whisper"] = "connected"
 		}
+		diag["whisper_backend"] = wp.BackendType()
+		// Per-backend health, for failover setups: which are up, which are
+		// being skipped and why.
+		status["whisper_backends"] = wp.Backends()
 		
 		// LLM health check (if enabled)
 		if enableLLM && llmURL != "" {
-			healthClient := &http.Client{Timeout: 5 * time.Second}
+			healthClient := &http.Client{Timeout: 5 * time.Second, Transport: llmTransport}
 			if resp, err := healthClient.Get(llmURL + "/v1/models"); err != nil {
 				status["llm"] = "unreachable"
 				diag["llm_error"] = err.Error()
@@ -824,74 +5565,94 @@ func main() {
 	})
 
 	// --- Version and update check ---
-	var (
-		cachedLatest    string
-		cachedReleaseAt time.Time
-	)
+	// The checker polls GitHub in the background and persists its result, so
+	// this handler never blocks on the network.
+	if !update.ValidChannel(cfg.UpdateChannel) {
+		logger.Warn("unknown update channel, using stable", "channel", cfg.UpdateChannel)
+	}
+	updates := update.New(update.Config{
+		Current:   version,
+		Channel:   cfg.UpdateChannel,
+		CachePath: filepath.Join(cacheDir, "update.json"),
+		Disabled:  !cfg.UpdateCheck,
+		Logger:    logger,
+	})
+	go updates.Start(bgCtx)
+
 	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		status := updates.Status()
+		build := readBuildInfo()
 		result := map[string]any{
-			"version": version,
+			"version":          version,
+			"build":            build,
+			"channel":          status.Channel,
+			"update_check":     !status.Disabled,
+			"update_available": status.UpdateAvailable,
 		}
-		// Check for updates via GitHub releases API (cached 1 hour)
-		if time.Since(cachedReleaseAt) > time.Hour || cachedLatest == "" {
-			client := &http.Client{Timeout: 5 * time.Second}
-			resp, err := client.Get("https://api.github.com/repos/ryan-winkler/captainslog-whisper/releases/latest")
-			if err == nil {
-				var release struct {
-					TagName string `json:"tag_name"`
-					HTMLURL string `json:"html_url"`
-				}
-				if json.NewDecoder(resp.Body).Decode(&release) == nil && release.TagName != "" {
-					cachedLatest = strings.TrimPrefix(release.TagName, "v")
-					cachedReleaseAt = time.Now()
-				}
-				resp.Body.Close()
-			}
+		if status.Latest != nil {
+			result["latest"] = status.Latest.Version
+			result["release_url"] = status.Latest.URL
+			result["release_notes"] = status.Latest.Notes
+			result["published_at"] = status.Latest.PublishedAt
+			result["prerelease"] = status.Latest.Prerelease
+		}
+		if len(status.Changelog) > 0 {
+			result["changelog"] = status.Changelog
+		}
+		if !status.CheckedAt.IsZero() {
+			result["checked_at"] = status.CheckedAt
 		}
-		if cachedLatest != "" {
-			result["latest"] = cachedLatest
-			result["update_available"] = cachedLatest != version
-			result["release_url"] = "https://github.com/ryan-winkler/captainslog-whisper/releases/latest"
+		if status.LastError != "" {
+			result["check_error"] = status.LastError
 		}
 		json.NewEncoder(w).Encode(result)
 	})
 
 	// --- Model discovery (dynamic from backends) ---
-	mux.HandleFunc("/api/models", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		result := map[string]any{
-			"whisper": []map[string]string{},
-		}
-
-		// Query whisper-fastapi for available models
+	// listModels asks the Whisper backend and, if enabled, the local LLM for
+	// their models. whisper falls back to the well-known sizes when the
+	// backend can't say; llm is nil when the LLM is off or unreachable.
+	listModels := func(ctx context.Context) (whisper, llm []map[string]string) {
 		settings.mu.RLock()
 		whisperURL := settings.WhisperURL
+		llmURL := settings.LLMURL
+		enableLLM := settings.EnableLLM
 		settings.mu.RUnlock()
 
-		client := &http.Client{Timeout: 3 * time.Second}
-
-		// whisper-fastapi exposes GET /v1/models (some versions)
-		if resp, err := client.Get(whisperURL + "/v1/models"); err == nil {
+		// Both shapes list models as {"data": [{"id": ...}]}.
+		fetch := func(client *http.Client, url string) []map[string]string {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil
+			}
+			defer resp.Body.Close()
 			var data struct {
 				Data []struct {
 					ID string `json:"id"`
 				} `json:"data"`
 			}
-			if json.NewDecoder(resp.Body).Decode(&data) == nil && len(data.Data) > 0 {
-				models := make([]map[string]string, len(data.Data))
-				for i, m := range data.Data {
-					models[i] = map[string]string{"id": m.ID, "name": m.ID}
-				}
-				result["whisper"] = models
+			if json.NewDecoder(resp.Body).Decode(&data) != nil || len(data.Data) == 0 {
+				return nil
 			}
-			resp.Body.Close()
+			models := make([]map[string]string, len(data.Data))
+			for i, m := range data.Data {
+				models[i] = map[string]string{"id": m.ID, "name": m.ID}
+			}
+			return models
 		}
 
+		// whisper-fastapi exposes GET /v1/models (some versions)
+		client := &http.Client{Timeout: 3 * time.Second, Transport: whisperTransport}
+		whisper = fetch(client, primaryURL(whisperURL)+"/v1/models")
+
 		// Fallback: provide known model list if backend doesn't support /v1/models
-		whisperModels, ok := result["whisper"].([]map[string]string)
-		if !ok || len(whisperModels) == 0 {
-			result["whisper"] = []map[string]string{
+		if len(whisper) == 0 {
+			whisper = []map[string]string{
 				{"id": "large-v3", "name": "large-v3 (best accuracy)"},
 				{"id": "large-v2", "name": "large-v2"},
 				{"id": "medium", "name": "medium (balanced)"},
@@ -902,55 +5663,109 @@ func main() {
 		}
 
 		// Query Local LLM for available models (Ollama or LM Studio)
-		if settings.EnableLLM {
+		if enableLLM {
+			// Separate client: only LLM requests may carry the LLM API key.
+			client := &http.Client{Timeout: 3 * time.Second, Transport: llmTransport}
 			// Try standard OpenAI /v1/models first (LM Studio, modern Ollama)
-			if resp, err := client.Get(settings.LLMURL + "/v1/models"); err == nil {
-				var data struct {
-					Data []struct {
-						ID string `json:"id"`
-					} `json:"data"`
-				}
-				if json.NewDecoder(resp.Body).Decode(&data) == nil && len(data.Data) > 0 {
-					models := make([]map[string]string, len(data.Data))
-					for i, m := range data.Data {
-						models[i] = map[string]string{"id": m.ID, "name": m.ID}
-					}
-					result["llm"] = models
-				}
-				resp.Body.Close()
-			}
-			
+			llm = fetch(client, llmURL+"/v1/models")
+
 			// Fallback: Try Ollama proprietary /api/tags if /v1/models fails or is empty
-			if _, ok := result["llm"]; !ok {
-				if resp, err := client.Get(settings.LLMURL + "/api/tags"); err == nil {
-					var data struct {
-						Models []struct {
-							Name string `json:"name"`
-						} `json:"models"`
-					}
-					if json.NewDecoder(resp.Body).Decode(&data) == nil {
-						models := make([]map[string]string, len(data.Models))
-						for i, m := range data.Models {
-							models[i] = map[string]string{"id": m.Name, "name": m.Name}
+			if llm == nil {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, llmURL+"/api/tags", nil)
+				if err == nil {
+					if resp, err := client.Do(req); err == nil {
+						var data struct {
+							Models []struct {
+								Name string `json:"name"`
+							} `json:"models"`
+						}
+						if json.NewDecoder(resp.Body).Decode(&data) == nil {
+							llm = make([]map[string]string, len(data.Models))
+							for i, m := range data.Models {
+								llm[i] = map[string]string{"id": m.Name, "name": m.Name}
+							}
 						}
-						result["llm"] = models
+						resp.Body.Close()
 					}
-					resp.Body.Close()
 				}
 			}
 		}
+		return whisper, llm
+	}
 
+	mux.HandleFunc("/api/models", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		whisper, llm := listModels(r.Context())
+		result := map[string]any{"whisper": whisper}
+		if llm != nil {
+			result["llm"] = llm
+		}
 		json.NewEncoder(w).Encode(result)
 	})
 
+	// /v1/models is the same list in OpenAI's shape, so OpenAI clients
+	// pointed at Captain's Log can enumerate models as well as post audio.
+	// owned_by says which backend serves a model. whisper-1, the name
+	// OpenAI clients send by default, is always listed: the proxy passes
+	// the model through and the backend decides.
+	openAIModels := func(ctx context.Context) []map[string]any {
+		whisper, llm := listModels(ctx)
+		var data []map[string]any
+		seen := map[string]bool{}
+		add := func(id, owner string) {
+			if id == "" || seen[id] {
+				return
+			}
+			seen[id] = true
+			data = append(data, map[string]any{"id": id, "object": "model", "created": 0, "owned_by": owner})
+		}
+		for _, m := range whisper {
+			add(m["id"], "whisper")
+		}
+		add("whisper-1", "whisper")
+		for _, m := range llm {
+			add(m["id"], "llm")
+		}
+		return data
+	}
+	mux.HandleFunc("/v1/models", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /v1/models only lists models")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": openAIModels(r.Context())})
+	}))
+	mux.HandleFunc("/v1/models/", withScope(auth.ScopeTranscribe, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/models/")
+		for _, m := range openAIModels(r.Context()) {
+			if m["id"] == id {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(m)
+				return
+			}
+		}
+		httputil.Error(w, r, logger, http.StatusNotFound, "model not found",
+			"WHY: neither the Whisper backend nor the LLM lists "+id)
+	}))
+
+	// Set once at startup, before the server listens (see --- Tailscale ---
+	// and --- Public tunnel ---).
+	var tailnetURL string
+	var tunnels *tunnel.Manager
+
 	// --- Config ---
 	mux.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]any{
+			"tailnet_url":   tailnetURL,
+			"tunnel_url":    tunnels.Status().URL,
 			"vault_enabled": settings.VaultDir != "",
 			"llm_enabled":   settings.EnableLLM,
 			"auth_required": cfg.AuthToken != "",
 			"tls_enabled":   cfg.EnableTLS,
+			"privacy_mode":  cfg.PrivacyMode,
 		})
 	})
 
@@ -976,11 +5791,7 @@ func main() {
 		}
 
 		// Build the target URL: prefer /v1/chat/completions
-		target := llmURL
-		if !strings.HasSuffix(target, "/v1") {
-			target += "/v1"
-		}
-		target += "/chat/completions"
+		target := llm.ChatURL(llmURL)
 
 		// Forward the request body to the LLM
 		proxyReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, target, r.Body)
@@ -990,19 +5801,121 @@ func main() {
 		}
 		proxyReq.Header.Set("Content-Type", "application/json")
 
-		client := &http.Client{Timeout: 120 * time.Second}
+		client := &http.Client{Timeout: 120 * time.Second, Transport: llmTransport}
 		resp, err := client.Do(proxyReq)
 		if err != nil {
 			httputil.Error(w, r, logger, http.StatusBadGateway,
 				"LLM unreachable — is Ollama/LM Studio running?", err.Error())
 			return
 		}
-		defer resp.Body.Close()
+		defer resp.Body.Close()
+
+		// Forward the response headers and body
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+
+	// --- End-to-end self-test ---
+	// Runs a synthesized clip through the real proxy handler, the LLM (if
+	// enabled), and the vault (if configured) so a config change can be
+	// verified with one click instead of a manual dictation.
+	mux.HandleFunc("/api/selftest", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/selftest only accepts POST — it writes to the vault and calls backends")
+			return
+		}
+		settings.mu.RLock()
+		lang := settings.Language
+		enableLLM := settings.EnableLLM
+		llmURL := settings.LLMURL
+		llmModel := settings.LLMModel
+		vaultDir := settings.VaultDir
+		dateFmt := settings.DateFormat
+		settings.mu.RUnlock()
 
-		// Forward the response headers and body
-		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
-		w.WriteHeader(resp.StatusCode)
-		io.Copy(w, resp.Body)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Minute)
+		defer cancel()
+
+		var transcript string
+		stages := []selftest.Stage{
+			{Name: "proxy", Run: func(ctx context.Context) (string, error) {
+				var buf bytes.Buffer
+				mpWriter := multipart.NewWriter(&buf)
+				part, _ := mpWriter.CreateFormFile("file", "selftest.wav")
+				part.Write(selftest.ToneWAV(2*time.Second, 440))
+				mpWriter.WriteField("response_format", "json")
+				if lang != "" && lang != "und" {
+					mpWriter.WriteField("language", lang)
+				}
+				mpWriter.Close()
+
+				// WHY an in-process request? Calling the proxy handler directly
+				// exercises the same verbose_json upgrade and SRT enrichment as
+				// a real upload, without depending on our own listen address,
+				// TLS, or auth token.
+				req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &buf).WithContext(ctx)
+				req.Header.Set("Content-Type", mpWriter.FormDataContentType())
+				rec := httptest.NewRecorder()
+				currentWhisperProxy().Transcribe(rec, req)
+				if rec.Code != http.StatusOK {
+					return "", fmt.Errorf("proxy returned HTTP %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+				}
+				var result struct {
+					Text     string `json:"text"`
+					Segments []any  `json:"segments"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+					return "", fmt.Errorf("proxy returned invalid JSON: %w", err)
+				}
+				transcript = strings.TrimSpace(result.Text)
+				return fmt.Sprintf("backend accepted synthetic clip (%d segments, %d chars — a pure tone has no speech)",
+					len(result.Segments), len(transcript)), nil
+			}},
+			{Name: "llm", Run: func(ctx context.Context) (string, error) {
+				if !enableLLM || llmURL == "" {
+					return "", selftest.Skip("LLM post-processing disabled")
+				}
+				reply, err := llm.New(llmURL, llmModel, llm.WithTransport(llmTransport)).Chat(ctx,
+					llm.Message{Role: "system", Content: "You are a connectivity probe. Reply with the single word OK."},
+					llm.Message{Role: "user", Content: "Self-test transcript: " + transcript},
+				)
+				if err != nil {
+					return "", err
+				}
+				if len(reply) > 80 {
+					reply = reply[:80] + "…"
+				}
+				return fmt.Sprintf("model %s replied %q", llmModel, reply), nil
+			}},
+			{Name: "vault", Run: func(ctx context.Context) (string, error) {
+				saver := vault.New(vaultDir, dateFmt, "Captain's Log Self-Test", logger)
+				if saver == nil {
+					return "", selftest.Skip("vault directory not configured")
+				}
+				marker := "Self-test entry written at stardate " + stardate.Now() + " — safe to delete."
+				file, err := saver.Save(marker, lang)
+				if err != nil {
+					return "", err
+				}
+				// Always clean up — the probe must never pollute the user's vault.
+				defer os.Remove(file)
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return "", fmt.Errorf("read back: %w", err)
+				}
+				if !strings.Contains(string(data), marker) {
+					return "", fmt.Errorf("read back: content mismatch in %s", filepath.Base(file))
+				}
+				return "wrote, verified, and removed " + filepath.Base(file), nil
+			}},
+		}
+
+		report := selftest.Run(ctx, stages)
+		logger.Info("self-test complete", "ok", report.OK, "duration_ms", report.DurationMS)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
 	}))
 
 	// --- Open file location (system folder) ---
@@ -1125,12 +6038,19 @@ func main() {
 		logger.Error("failed to load embedded web files", "error", err, "why", "binary may be corrupted — rebuild with go build")
 		os.Exit(1)
 	}
-	mux.Handle("/", http.FileServer(http.FS(webSub)))
+	// Hashed once at startup: versioned asset URLs are cached for a year,
+	// pages revalidate by ETag (see internal/assets).
+	webAssets, err := assets.New(webSub)
+	if err != nil {
+		logger.Error("failed to load embedded web files", "error", err, "why", "binary may be corrupted — rebuild with go build")
+		os.Exit(1)
+	}
+	mux.Handle("/", webAssets)
 
 	// --- Start ---
 	server := &http.Server{
 		Addr:         cfg.ListenAddr(),
-		Handler:      accessLog(limiter.Middleware(secure(mux))),
+		Handler:      accessLog(limiter.Middleware(uploadGuard.Middleware(secure(mux)))),
 		ReadTimeout:  120 * time.Second,
 		WriteTimeout: 120 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -1138,14 +6058,48 @@ func main() {
 
 	proto := "http"
 	if cfg.EnableTLS {
-		certDir := filepath.Join(os.Getenv("HOME"), ".config", "captainslog", "tls")
+		certDir := filepath.Join(configDir, "tls")
+		// Certificates used to stay in ~/.config/captainslog/tls even with
+		// CAPTAINSLOG_CONFIG_DIR set; keep using one browsers already trust.
+		if legacy := filepath.Join(paths.LegacyConfigDir(), "tls"); !portable && paths.LegacyConfigDir() != "" {
+			if _, err := os.Stat(certDir); os.IsNotExist(err) {
+				if _, err := os.Stat(legacy); err == nil {
+					certDir = legacy
+				}
+			}
+		}
 		hostnames := []string{"localhost", "captainslog.local"}
 		if extra := os.Getenv("CAPTAINSLOG_TLS_HOSTNAMES"); extra != "" {
 			for _, h := range strings.Split(extra, ",") {
 				hostnames = append(hostnames, strings.TrimSpace(h))
 			}
 		}
-		tlsConfig, err := localtls.GenerateOrLoad(certDir, hostnames, logger)
+		// A replaced cert is announced: every browser and client that
+		// trusted the old one has to be told to trust the new one.
+		tlsConfig, err := localtls.GenerateOrLoad(certDir, localtls.Options{
+			Hostnames:   hostnames,
+			RefreshSANs: cfg.TLSRefreshSANs,
+			LocalCA:     cfg.TLSLocalCA,
+			OnRotate: func(rot localtls.Rotation) {
+				eventBus.Publish(events.New(events.TypeCertificateRotated, "tls", events.CertificateRotated{
+					Reason:              rot.Reason,
+					Missing:             rot.Missing,
+					Fingerprint:         rot.Fingerprint,
+					PreviousFingerprint: rot.PreviousFingerprint,
+					Expires:             rot.Expires.UTC().Format(time.RFC3339),
+					Retrust:             rot.Retrust,
+				}))
+			},
+		}, tlsPolicy, logger)
+		if err == nil {
+			err = localtls.ConfigureClientAuth(tlsConfig, cfg.TLSClientCA, cfg.TLSClientAuth)
+		}
+		if err != nil && mtls {
+			// WHY not fall back to HTTP here? Client certs may be the only
+			// auth configured; plain HTTP would serve everything to anyone.
+			logger.Error("TLS setup failed and client certificate auth is configured — refusing to start without it", "error", err)
+			os.Exit(1)
+		}
 		if err != nil {
 			// WHY fallback to HTTP? TLS cert generation can fail (disk permissions,
 			// OpenSSL issues). Running without TLS is better than not starting at all —
@@ -1154,11 +6108,108 @@ func main() {
 		} else {
 			server.TLSConfig = tlsConfig
 			proto = "https"
+			// The local CA's certificate, for devices to install once. No
+			// auth: it is public, and a device needs it before it can
+			// reach the UI without a warning.
+			mux.HandleFunc("/api/tls/ca.crt", func(w http.ResponseWriter, r *http.Request) {
+				pem, err := localtls.CACert(certDir)
+				if errors.Is(err, os.ErrNotExist) {
+					httputil.Error(w, r, logger, http.StatusNotFound, "no local CA",
+						"WHY: CAPTAINSLOG_TLS_CA is off, or the existing self-signed certificate predates it (delete tls/captainslog.crt to switch)")
+					return
+				} else if err != nil {
+					httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to read the local CA", err.Error())
+					return
+				}
+				w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+				w.Header().Set("Content-Disposition", `attachment; filename="`+localtls.CAFile+`"`)
+				w.Write(pem)
+			})
+			logger.Info("TLS policy", "policy", tlsPolicy.Name, "min_version", tls.VersionName(tlsPolicy.MinVersion), "key_type", tlsPolicy.KeyType,
+				"client_auth", cfg.TLSClientAuth)
+		}
+	}
+
+	// --- Tailscale ---
+	// Join the tailnet as our own node (tsnet), so phones and laptops on the
+	// tailnet reach us over HTTPS at its MagicDNS name. WHY warn instead of
+	// exit? The server is still useful locally; the log says what to fix.
+	var tn *tailnet.Tailnet
+	if cfg.Tailscale {
+		tn = tailnet.New(tailnet.Options{
+			Server:   server,
+			AuthKey:  tailscaleAuthKey.Get(),
+			Hostname: cfg.TailscaleHostname,
+			Dir:      filepath.Join(configDir, "tailscale"),
+		}, logger)
+		tsCtx, tsCancel := context.WithTimeout(context.Background(), time.Minute)
+		if u, err := tn.Publish(tsCtx); err != nil {
+			logger.Warn("could not serve on tailnet", "error", err)
+		} else {
+			tailnetURL = u
+			if cfg.AuthToken == "" {
+				logger.Warn("every device on the tailnet can use this server without a token — set CAPTAINSLOG_AUTH_TOKEN to require one")
+			}
+		}
+		tsCancel()
+	}
+
+	localHost := cfg.Host
+	if localHost == "" || localHost == "0.0.0.0" || localHost == "::" {
+		localHost = "127.0.0.1"
+	}
+	localURL := fmt.Sprintf("%s://%s", proto, net.JoinHostPort(localHost, strconv.Itoa(cfg.Port)))
+	// --- Public tunnel ---
+	// CAPTAINSLOG_TUNNEL opens a temporary public URL through cloudflared or
+	// ngrok at startup; POST/DELETE /api/tunnel reopen or close it. WHY
+	// insist on the auth token? The URL is reachable from the whole
+	// internet, and tunnel traffic arrives from 127.0.0.1, which rate limits
+	// and upload guardrails exempt by default.
+	tunnels = tunnel.NewManager(tunnel.Options{Provider: cfg.Tunnel, Target: localURL, TTL: cfg.TunnelTTL}, logger)
+	startTunnel := func(ctx context.Context) (tunnel.Status, error) {
+		if authToken.Get() == "" {
+			return tunnel.Status{}, errors.New("a public tunnel needs CAPTAINSLOG_AUTH_TOKEN")
+		}
+		return tunnels.Start(ctx)
+	}
+	if cfg.Tunnel != "" {
+		if _, err := startTunnel(context.Background()); err != nil {
+			logger.Error("tunnel not opened", "provider", cfg.Tunnel, "error", err)
 		}
 	}
+	mux.HandleFunc("/api/tunnel", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.Tunnel == "" {
+			httputil.Error(w, r, logger, http.StatusNotImplemented, "no tunnel provider configured — set CAPTAINSLOG_TUNNEL",
+				"WHY: CAPTAINSLOG_TUNNEL is empty (cloudflared or ngrok)")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if _, err := startTunnel(r.Context()); err != nil {
+				httputil.Error(w, r, logger, http.StatusBadGateway, "failed to open tunnel", err.Error())
+				return
+			}
+		case http.MethodDelete:
+			if err := tunnels.Stop(); err != nil {
+				httputil.Error(w, r, logger, http.StatusInternalServerError, "failed to close tunnel", err.Error())
+				return
+			}
+		default:
+			httputil.Error(w, r, logger, http.StatusMethodNotAllowed, "method not allowed",
+				"WHY: /api/tunnel is GET (status), POST (open) or DELETE (close)")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tunnels.Status())
+	}))
 
 	sd := stardate.Now()
+	build := readBuildInfo()
 	logger.Info("Captain's Log starting",
+		"version", build.Version,
+		"commit", build.Commit,
+		"go", build.GoVersion,
 		"addr", cfg.ListenAddr(),
 		"proto", proto,
 		"stardate", sd,
@@ -1168,23 +6219,31 @@ func main() {
 
 	// WHY stdout (not stderr)? The startup banner is informational, not an error.
 	// journalctl and docker logs capture stdout by default.
-	fmt.Fprintf(os.Stdout, "\n  🖖 Captain's Log v%s\n  → Stardate %s\n  → %s://%s\n  → API: %s://%s/v1/audio/transcriptions\n\n", version, sd, proto, cfg.ListenAddr(), proto, cfg.ListenAddr())
+	fmt.Fprintf(os.Stdout, "\n  🖖 Captain's Log v%s\n  → Stardate %s\n  → %s://%s\n  → API: %s://%s/v1/audio/transcriptions\n", build, sd, proto, cfg.ListenAddr(), proto, cfg.ListenAddr())
+	if tailnetURL != "" {
+		fmt.Fprintf(os.Stdout, "  → Tailnet: %s\n", tailnetURL)
+	}
+	if st := tunnels.Status(); st.Active {
+		closes := "until shutdown"
+		if !st.ExpiresAt.IsZero() {
+			closes = "closes " + st.ExpiresAt.Format("15:04")
+		}
+		fmt.Fprintf(os.Stdout, "  → Public (%s, %s): %s\n", st.Provider, closes, st.URL)
+	}
+	fmt.Fprintln(os.Stdout)
 
 	// --- Folder watcher (auto-transcribe new audio files) ---
-	var fw *watcher.Watcher
 	settings.mu.RLock()
 	watchDir := settings.WatchDir
 	settings.mu.RUnlock()
 	if watchDir != "" {
-		fw = watcher.New(watchDir, cfg.WhisperURL, settings.VaultDir, settings.Language, logger)
 		if err := fw.Start(); err != nil {
 			logger.Error("folder watcher failed to start", "error", err, "dir", watchDir)
 		} else {
 			logger.Info("folder watcher active", "dir", watchDir)
-			// SSE endpoint for watcher events
-			mux.HandleFunc("/api/watcher/events", withAuth(fw.SSEHandler()))
 		}
 	}
+	applyWatchers()
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -1205,20 +6264,618 @@ func main() {
 		}
 	}()
 
-	<-stop
+	select {
+	case <-stop:
+	case <-serviceStop:
+	}
 	logger.Info("shutting down gracefully...")
+	shuttingDown.Store(true)
 	if fw != nil {
 		fw.Stop()
 	}
+	watcherManager.Stop()
+	bgCancel()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	tunnels.Stop()
 	if err := server.Shutdown(ctx); err != nil {
 		// WHY log but continue? Shutdown errors are non-fatal — the server is
 		// already stopping. This can happen if active connections don't drain
 		// within the 10-second timeout.
 		logger.Error("shutdown error", "error", err, "why", "graceful shutdown timed out — some connections may not have drained")
 	}
+	if tn != nil {
+		if err := tn.Close(); err != nil {
+			logger.Warn("could not leave the tailnet cleanly", "error", err)
+		}
+	}
+	// Again after the last requests drained; Run saved what came before.
+	if err := usageTracker.Flush(); err != nil {
+		logger.Warn("could not save usage", "error", err)
+	}
 	logger.Info("goodbye 🖖")
+	serviceDone()
+}
+
+// runLoadtest implements `captainslog loadtest`. It drives a running server
+// with concurrent transcriptions and prints throughput, latency percentiles,
+// and backend saturation. Returns the process exit code.
+func runLoadtest(args []string) int {
+	cfg := config.Load()
+	host := cfg.Host
+	if host == "0.0.0.0" || host == "" {
+		host = "127.0.0.1"
+	}
+
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	var (
+		url         = flags.String("url", fmt.Sprintf("http://%s:%d", host, cfg.Port), "Captain's Log server URL")
+		file        = flags.String("file", "", "Audio file to transcribe (required)")
+		concurrency = flags.Int("concurrency", 4, "Simultaneous clients")
+		requests    = flags.Int("requests", 0, "Total requests (default: 3 per client)")
+		duration    = flags.Duration("duration", 0, "Run for this long instead of a fixed request count (e.g. 2m)")
+		token       = flags.String("token", cfg.AuthToken, "Bearer token (default: $CAPTAINSLOG_AUTH_TOKEN)")
+		language    = flags.String("language", "", "Language hint (e.g. en)")
+		jsonOut     = flags.Bool("json", false, "Print the report as JSON")
+	)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: captainslog loadtest --file sample.wav [--concurrency N] [flags]\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *file == "" {
+		flags.Usage()
+		return 2
+	}
+	audio, err := os.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+		return 1
+	}
+	if *requests == 0 {
+		*requests = *concurrency * 3
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !*jsonOut {
+		mode := fmt.Sprintf("%d requests", *requests)
+		if *duration > 0 {
+			mode = duration.String()
+		}
+		fmt.Fprintf(os.Stderr, "  🖖 Load testing %s — %d clients, %s, %s\n", *url, *concurrency, mode, filepath.Base(*file))
+	}
+
+	report, err := loadtest.Run(ctx, loadtest.Config{
+		URL:         *url,
+		Token:       *token,
+		Audio:       audio,
+		Filename:    filepath.Base(*file),
+		Language:    *language,
+		Concurrency: *concurrency,
+		Requests:    *requests,
+		Duration:    *duration,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+		return 1
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runService implements `captainslog service install|uninstall|status`:
+// a systemd user unit on Linux, a launchd agent on macOS, a Windows
+// service (from an Administrator prompt) on Windows.
+func runService(args []string) int {
+	usage := func() int {
+		fmt.Fprintf(os.Stderr, "Usage: captainslog service install|uninstall|status\n\n")
+		fmt.Fprintf(os.Stderr, "install records this binary's path and the CAPTAINSLOG_* variables\n")
+		fmt.Fprintf(os.Stderr, "of this shell; run it again after changing either.\n")
+		return 2
+	}
+	if len(args) != 1 {
+		return usage()
+	}
+	switch args[0] {
+	case "install":
+		configDir, _, err := resolveDataDir(false, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "service install: %v\n", err)
+			return 1
+		}
+		spec, err := service.NewSpec(os.Environ(), configDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "service install: %v\n", err)
+			return 1
+		}
+		if err := service.Install(spec); err != nil {
+			fmt.Fprintf(os.Stderr, "service install: %v\n", err)
+			return 1
+		}
+		fmt.Printf("  ✅ Installed and started %s\n", spec.Exec)
+		fmt.Printf("     with %d CAPTAINSLOG_* variables\n", len(spec.Env))
+	case "uninstall":
+		if err := service.Uninstall(); err != nil {
+			fmt.Fprintf(os.Stderr, "service uninstall: %v\n", err)
+			return 1
+		}
+		fmt.Println("  ✅ Service stopped and removed")
+	case "status":
+		st, err := service.Query()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "service status: %v\n", err)
+			return 1
+		}
+		if !st.Installed {
+			fmt.Printf("  not installed (%s)\n", st.Path)
+			return 3
+		}
+		fmt.Printf("  installed: %s\n  state:     %s\n", st.Path, st.Detail)
+		if !st.Running {
+			return 3
+		}
+	default:
+		return usage()
+	}
+	return 0
+}
+
+// runHealthcheck implements `captainslog healthcheck`: it asks /readyz on
+// the local instance and exits 0 when ready, 1 when not. It exists so
+// Docker HEALTHCHECK and systemd ExecStartPost probes work in images
+// without curl or wget.
+func runHealthcheck(args []string) int {
+	cfg := config.Load()
+	host := cfg.Host
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	proto := "http"
+	if cfg.EnableTLS {
+		proto = "https"
+	}
+
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	var (
+		url     = flags.String("url", fmt.Sprintf("%s://%s", proto, net.JoinHostPort(host, strconv.Itoa(cfg.Port))), "Captain's Log server URL")
+		timeout = flags.Duration("timeout", 5*time.Second, "Give up on a single probe after this long")
+		wait    = flags.Duration("wait", 0, "Keep probing until ready or this long has passed (e.g. 30s for ExecStartPost)")
+		whisper = flags.Bool("whisper", false, "Also require a reachable Whisper backend")
+		quiet   = flags.Bool("quiet", false, "Print nothing; the exit code says it all")
+	)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: captainslog healthcheck [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "Exits 0 when the server answers /readyz with 200, 1 otherwise.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	probe := strings.TrimSuffix(*url, "/") + "/readyz"
+	if *whisper {
+		probe += "?whisper=1"
+	}
+	client := &http.Client{
+		Timeout: *timeout,
+		// WHY skip verification? The local instance serves a self-signed
+		// certificate; the probe asks whether it is up, not who it is.
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	deadline := time.Now().Add(*wait)
+	for {
+		err := checkReady(client, probe)
+		if err == nil {
+			if !*quiet {
+				fmt.Println("ready")
+			}
+			return 0
+		}
+		if time.Now().After(deadline) {
+			if !*quiet {
+				fmt.Fprintf(os.Stderr, "not ready: %v\n", err)
+			}
+			return 1
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// checkReady GETs the /readyz URL and returns nil on a 200.
+func checkReady(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var body struct {
+		Status string `json:"status"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 4<<10)).Decode(&body) == nil && body.Status != "" {
+		return fmt.Errorf("%s (HTTP %d)", body.Status, resp.StatusCode)
+	}
+	return fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// runVault implements `captainslog vault <command>`. Only "migrate" exists
+// today; it converts layouts, renames, moves and rewrites frontmatter, with
+// --dry-run to preview and --rollback to undo a previous run.
+func runVault(args []string) int {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprintf(os.Stderr, "Usage: captainslog vault migrate [flags]\n")
+		return 2
+	}
+	cfg := config.Load()
+
+	flags := flag.NewFlagSet("vault migrate", flag.ExitOnError)
+	var (
+		from       = flags.String("from", cfg.VaultDir, "Vault directory to migrate (default: $CAPTAINSLOG_VAULT_DIR)")
+		to         = flags.String("to", "", "Destination directory (default: same as --from)")
+		layout     = flags.String("layout", vault.LayoutEntry, "Target layout: entry (one file per dictation) or daily (one file per day)")
+		template   = flags.String("template", "", "File name template: {title} {date} {time} {lang} (default: per layout)")
+		dateFormat = flags.String("date-format", envOrDefault("CAPTAINSLOG_DATE_FORMAT", "2006-01-02"), "Go date layout for {date}")
+		title      = flags.String("title", envOrDefault("CAPTAINSLOG_FILE_TITLE", "Dictation"), "Title for notes that have none")
+		dailyOrder = flags.String("daily-order", vault.DailyOrderBottom, "Daily layout: bottom (oldest first, new entries appended) or top (newest first, under the heading)")
+		toc        = flags.Bool("toc", false, "Daily layout: list the day's entry times under the heading, linked to each section")
+		dryRun     = flags.Bool("dry-run", false, "Show what would change without touching any file")
+		rollback   = flags.String("rollback", "", "Undo a previous migration using its manifest.json")
+		set        = map[string]string{}
+		drop       []string
+	)
+	flags.Func("set", "Set a frontmatter key on every file, key=value (repeatable)", func(v string) error {
+		k, val, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("want key=value, got %q", v)
+		}
+		set[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		return nil
+	})
+	flags.Func("drop", "Remove a frontmatter key from every file (repeatable)", func(v string) error {
+		drop = append(drop, v)
+		return nil
+	})
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: captainslog vault migrate --layout entry|daily [flags]\n")
+		fmt.Fprintf(flags.Output(), "       captainslog vault migrate --rollback <vault>/.migrate-<time>/manifest.json\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args[1:])
+
+	if *rollback != "" {
+		if err := vault.Rollback(*rollback); err != nil {
+			fmt.Fprintf(os.Stderr, "vault migrate: rollback incomplete:\n%v\n", err)
+			return 1
+		}
+		fmt.Println("  ✅ Migration rolled back")
+		return 0
+	}
+	if *from == "" {
+		flags.Usage()
+		return 2
+	}
+
+	plan, err := vault.PlanMigration(vault.MigrateOptions{
+		SourceDir:  *from,
+		DestDir:    *to,
+		Layout:     *layout,
+		Template:   *template,
+		DateFormat: *dateFormat,
+		Title:      *title,
+		Set:        set,
+		Drop:       drop,
+		DailyOrder: *dailyOrder,
+		DailyTOC:   *toc,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vault migrate: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("  🖖 %d notes in %d files → %d %s files in %s\n",
+		plan.Notes, len(plan.Sources), len(plan.Outputs), *layout, plan.Options.DestDir)
+	for _, w := range plan.Warnings {
+		fmt.Printf("  ⚠️  %s\n", w)
+	}
+	if *dryRun {
+		for _, o := range plan.Outputs {
+			var names []string
+			for _, s := range o.Sources {
+				names = append(names, filepath.Base(s))
+			}
+			fmt.Printf("  %s  ←  %s\n", filepath.Base(o.Path), strings.Join(names, ", "))
+		}
+		fmt.Println("  (dry run — nothing changed)")
+		return 0
+	}
+
+	manifest, err := plan.Apply()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vault migrate: %v\n", err)
+		if manifest != "" {
+			fmt.Fprintf(os.Stderr, "  Finish undoing with: captainslog vault migrate --rollback %q\n", manifest)
+		}
+		return 1
+	}
+	fmt.Printf("  ✅ Migrated. Originals backed up in %s\n", filepath.Dir(manifest))
+	fmt.Printf("  Undo with: captainslog vault migrate --rollback %q\n", manifest)
+	return 0
+}
+
+// setIndexFields copies what the transcript index knows about a note onto
+// its history entry.
+func setIndexFields(note *vault.Entry, e store.Entry) {
+	note.ID, note.Segments = e.ID, e.Segments
+	note.Words, note.ReadingSeconds, note.WPM = e.Words, e.ReadingSeconds, e.WPM
+}
+
+// importIndex copies the entries of the JSON index at path into index
+// when index is empty: the first start on a database. The file is left in
+// place.
+func importIndex(index *store.Store, path string, logger *slog.Logger) {
+	if n, err := index.Len(); err != nil || n > 0 {
+		// An unreadable index is not an empty one: importing would add
+		// every entry again.
+		if err != nil {
+			logger.Error("transcript index not imported", "from", path, "error", err)
+		}
+		return
+	}
+	old, err := store.Open(path)
+	if err != nil {
+		return
+	}
+	entries, _ := old.List() // a JSON index reads from memory
+	if len(entries) == 0 {
+		return
+	}
+	imported := 0
+	for _, e := range entries {
+		if _, err := index.Add(e); err != nil {
+			logger.Error("failed to import transcript index entry", "id", e.ID, "error", err)
+			continue
+		}
+		imported++
+	}
+	logger.Info("imported transcript index", "from", path, "entries", imported)
+}
+
+// parseMetricParams reads the word count and speech rate filters
+// (?min_words=, ?max_words=, ?min_wpm=, ?max_wpm=) into f, and the order
+// (?sort=, ?order=asc|desc) of /api/transcripts and /api/history pages.
+func parseMetricParams(q url.Values, f *store.Filter) (store.Order, error) {
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"min_words", &f.MinWords}, {"max_words", &f.MaxWords}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return store.Order{}, fmt.Errorf("%s must be a non-negative integer", p.name)
+			}
+			*p.dst = n
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{{"min_wpm", &f.MinWPM}, {"max_wpm", &f.MaxWPM}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+				return store.Order{}, fmt.Errorf("%s must be a non-negative number", p.name)
+			}
+			*p.dst = n
+		}
+	}
+	order := store.Order{Key: q.Get("sort")}
+	if !store.ValidSortKey(order.Key) {
+		return store.Order{}, errors.New("sort must be created, words, reading_time or wpm")
+	}
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		order.Asc = true
+	default:
+		return store.Order{}, errors.New("order must be asc or desc")
+	}
+	return order, nil
+}
+
+// transcriptStats totals the transcript index for /api/stats. The average
+// speech rate is over the transcripts with a duration.
+func transcriptStats(entries []store.Entry) map[string]any {
+	var words, reading, timedWords int
+	var duration float64
+	for _, e := range entries {
+		words += e.Words
+		reading += e.ReadingSeconds
+		if e.Duration > 0 && e.Words > 0 {
+			timedWords += e.Words
+			duration += e.Duration
+		}
+	}
+	return map[string]any{
+		"count":           len(entries),
+		"words":           words,
+		"reading_seconds": reading,
+		"duration":        duration,
+		"avg_wpm":         store.SpeechRate(timedWords, duration),
+	}
+}
+
+// validDigestSchedule reports whether s is "" (no schedule) or a schedule
+// digest.ParseSchedule accepts.
+func validDigestSchedule(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := digest.ParseSchedule(s)
+	return err == nil
+}
+
+// What the notify_on setting can name.
+const (
+	notifyTranscription = "transcription" // a watcher transcription took NotifyMinSeconds or longer
+	notifyFailure       = "failure"       // a watcher transcription failed
+	notifyBackend       = "backend"       // a Whisper or LLM call failed (once per host per cooldown)
+	notifyKeyword       = "keyword"       // a new transcript mentions one of watch_keywords
+	notifyRefined       = "refined"       // two-pass dictation changed a draft transcript
+)
+
+var notifyEvents = []string{notifyTranscription, notifyFailure, notifyBackend, notifyKeyword, notifyRefined}
+
+// splitList splits a comma-separated setting, dropping blanks.
+func splitList(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// validNotifyOn reports whether every entry is one of notifyEvents.
+func validNotifyOn(on []string) bool {
+	for _, v := range on {
+		if !slices.Contains(notifyEvents, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDateParam accepts "" (zero time), YYYY-MM-DD or RFC 3339.
+// WHY UTC for bare dates? Vault frontmatter dates carry no zone and
+// vault.Document.Time reads them as UTC, so this compares wall-clock days.
+func parseDateParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// noteSave is a transcript for saveNote: the body of /api/vault/save, or
+// what another endpoint transcribed.
+type noteSave struct {
+	Text      string
+	Language  string
+	Recording string // filename in the recordings folder; "" = none
+	Segments  []store.Segment
+	Words     []vault.Word
+	Tags      []string // added to default_tags for this note
+	Attach    *string  // overrides the attach_audio setting
+	Model     string   // "" = the model setting
+	Source    string   // store.Source*
+}
+
+// noteSaved is where saveNote put a transcript.
+type noteSaved struct {
+	File       string // "" when the vault skipped it
+	ID         string // transcript index entry; "" if not indexed
+	Attachment string // the recording's copy in the vault
+	Refining   string // the model re-transcribing a draft, if any
+}
+
+// validSource reports whether s can name a transcript source: 1–32
+// lowercase letters, digits, - or _.
+func validSource(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// watcherID derives an ID for a new watcher from its folder name, unique
+// among cfgs: "~/Audio/Voice Memos" → "voice-memos", then "voice-memos-2".
+func watcherID(dir string, cfgs []watcher.Config) string {
+	base := strings.Trim(strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '-'
+	}, filepath.Base(filepath.Clean(dir))), "-_")
+	if base == "" || base == "events" {
+		base = "watcher"
+	}
+	if len(base) > 56 {
+		base = base[:56]
+	}
+	taken := map[string]bool{}
+	for _, c := range cfgs {
+		taken[c.ID] = true
+	}
+	id := base
+	for i := 2; taken[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	return id
+}
+
+// primaryURL returns the first of a comma-separated list of Whisper URLs,
+// for the few calls that go to one backend directly instead of through
+// the balancing proxy.
+func primaryURL(list string) string {
+	if urls := proxy.SplitURLs(list); len(urls) > 0 {
+		return urls[0]
+	}
+	return list
+}
+
+// portableDirName is the data folder beside the executable in portable mode.
+const portableDirName = "captainslog-data"
+
+// resolveDataDir returns the directory for settings, recordings, the index
+// and the rest, and whether that is portable mode: dir when given, the
+// portableDirName folder beside the executable with portable (or
+// CAPTAINSLOG_PORTABLE=true), else paths.ConfigDir.
+func resolveDataDir(portable bool, dir string) (string, bool, error) {
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", false, fmt.Errorf("data dir: %w", err)
+		}
+		return abs, true, nil
+	}
+	if portable || os.Getenv("CAPTAINSLOG_PORTABLE") == "true" {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", false, fmt.Errorf("portable mode: find executable: %w", err)
+		}
+		// The real file, not a symlink to it on $PATH.
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		return filepath.Join(filepath.Dir(exe), portableDirName), true, nil
+	}
+	dir, err := paths.ConfigDir()
+	return dir, false, err
 }
 
 func envOrDefault(key, fallback string) string {
@@ -1237,6 +6894,15 @@ func envOrIntDefault(key string, fallback int) int {
 	return fallback
 }
 
+func envOrFloatDefault(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code and bytes for access logging.
 type responseWriter struct {
 	http.ResponseWriter
@@ -1254,3 +6920,9 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	rw.bytes += n
 	return n, err
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer (the
+// streaming relay hijacks the connection for WebSocket).
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -87,9 +87,41 @@
         vault_dir: '',
         download_dir: '',
         watch_dir: '',
+        watch_recursive: false,
+        recording_max_age_days: 0,
+        recording_max_size_mb: 0,
+        archive_after_months: 0,
         language: 'en',
         model: 'large-v3',
+        draft_model: '',
+        language_models: {},
         auto_save: false,
+        undo_window_seconds: 30,
+        default_tags: [],
+        attach_audio: '',
+        attachments_dir: '',
+        vault_mode: '',
+        daily_note_folder: '',
+        daily_note_format: '',
+        daily_note_entry: '',
+        daily_note_order: '',
+        daily_note_toc: false,
+        note_template: '',
+        vault_sidecar: false,
+        live_dictation: false,
+        llm_describe: false,
+        language_learning: false,
+        translate_to: 'English',
+        bilingual_layout: 'columns',
+        digest_schedule: '',
+        digest_period: 'weekly',
+        digest_folder: '',
+        notify_provider: '',
+        notify_url: '',
+        notify_topic: '',
+        notify_on: ['transcription', 'failure', 'backend', 'keyword', 'refined'],
+        notify_min_seconds: 60,
+        watch_keywords: [],
         auto_copy: true,
         prompt: '',
         vad_filter: false,
@@ -97,6 +129,7 @@
         show_stardates: true,
         date_format: '2006-01-02',
         whisper_url: '',
+        backend_type: 'auto',
         llm_url: '',
         llm_model: '',
         enable_llm: false,
@@ -118,6 +151,7 @@
     let animationId = null;
     let currentTranscription = '';
     let currentSegments = [];
+    let currentWords = []; // top-level verbose_json words, for the vault sidecar
     let currentRecordingUrl = null;
 
     // Audio player for recording playback
@@ -158,48 +192,173 @@
     // Flush debounced history on page unload to prevent data loss
     window.addEventListener('beforeunload', () => persistHistoryNow());
 
-    function hydrateFromServer() {
-        fetch('/api/history').then(r => r.json()).then(serverEntries => {
-            if (!Array.isArray(serverEntries) || serverEntries.length === 0) return;
+    // Cursor for the next page of /api/history; null once the server has
+    // no more. Pages come from the transcript index, so "Load more" never
+    // re-reads the whole vault.
+    let historyCursor = null;
 
-            // Build lookup of existing entries by vault_file path
-            const existing = new Map();
-            logHistory.forEach((e, i) => {
-                if (e.vault_file) existing.set(e.vault_file, i);
-            });
+    function hydrateFromServer(cursor) {
+        const url = '/api/history?limit=50' + (cursor ? '&cursor=' + encodeURIComponent(cursor) : '');
+        fetch(url).then(r => r.json()).then(data => {
+            historyCursor = (data && data.next_cursor) || null;
+            mergeServerEntries(data && Array.isArray(data.entries) ? data.entries : []);
+        }).catch(() => { /* Graceful degradation — localStorage-only fallback */ });
+        if (!cursor) {
+            // Pinned notes however old, so a pin never depends on paging.
+            fetch('/api/history?pinned=true&limit=500').then(r => r.json()).then(data => {
+                mergeServerEntries(data && Array.isArray(data.entries) ? data.entries : []);
+            }).catch(() => {});
+        }
+    }
 
-            let added = 0;
-            serverEntries.forEach(se => {
-                if (!se.vault_file || !se.text) return;
+    // Pins are saved in the note's frontmatter (PATCH /api/transcripts/<id>).
+    // Entries that never reached the vault have no id and pin locally only.
+    function setPinned(entry, pinned) {
+        entry.pinned = pinned;
+        if (!entry.transcript_id) return;
+        fetch('/api/transcripts/' + encodeURIComponent(entry.transcript_id), {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ pinned })
+        }).then(r => {
+            if (!r.ok) throw new Error('HTTP ' + r.status);
+            entry.pin_synced = true;
+            persistHistory();
+        }).catch(e => console.warn('Pin not saved to the vault:', e));
+    }
 
-                if (existing.has(se.vault_file)) {
-                    // Entry exists in localStorage — preserve UI-only state,
-                    // but update text from filesystem (source of truth)
-                    const idx = existing.get(se.vault_file);
-                    logHistory[idx].text = se.text;
-                    if (se.language && !logHistory[idx].language) logHistory[idx].language = se.language;
-                } else {
-                    // New entry from filesystem — not in localStorage
-                    logHistory.push({
-                        text: se.text,
-                        language: se.language || '',
-                        timestamp: se.timestamp || new Date().toISOString(),
-                        vault_file: se.vault_file,
-                        title: se.title || '',
-                        recording: null,
-                        pinned: false
-                    });
-                    added++;
-                }
-            });
+    // Merges notes from /api/history into the local history and re-renders.
+    function mergeServerEntries(serverEntries) {
+        if (serverEntries.length === 0) {
+            renderHistory();
+            return;
+        }
 
-            if (added > 0) {
-                // Sort by timestamp (newest first) and persist
-                logHistory.sort((a, b) => (b.timestamp || '').localeCompare(a.timestamp || ''));
-                persistHistory();
-                renderHistory();
+        // Build lookup of existing entries by vault_file path
+        const existing = new Map();
+        logHistory.forEach((e, i) => {
+            if (e.vault_file) existing.set(e.vault_file, i);
+        });
+
+        let added = 0;
+        serverEntries.forEach(se => {
+            if (!se.vault_file || !se.text) return;
+
+            if (existing.has(se.vault_file)) {
+                // Entry exists in localStorage — preserve UI-only state,
+                // but update text from filesystem (source of truth)
+                const idx = existing.get(se.vault_file);
+                logHistory[idx].text = se.text;
+                if (se.language && !logHistory[idx].language) logHistory[idx].language = se.language;
+                if (se.id) {
+                    logHistory[idx].transcript_id = se.id;
+                    logHistory[idx].segment_count = se.segments || 0;
+                    logHistory[idx].words = se.words || 0;
+                    logHistory[idx].reading_seconds = se.reading_seconds || 0;
+                    logHistory[idx].wpm = se.wpm || 0;
+                    if (se.pinned) {
+                        logHistory[idx].pinned = true;
+                        logHistory[idx].pin_synced = true;
+                    } else if (logHistory[idx].pinned && !logHistory[idx].pin_synced) {
+                        // Pinned in this browser before pins were saved to
+                        // the vault: save it now, once.
+                        setPinned(logHistory[idx], true);
+                    } else {
+                        logHistory[idx].pinned = false;
+                    }
+                }
+            } else {
+                // New entry from filesystem — not in localStorage
+                logHistory.push({
+                    text: se.text,
+                    language: se.language || '',
+                    timestamp: se.timestamp || new Date().toISOString(),
+                    vault_file: se.vault_file,
+                    title: se.title || '',
+                    recording: null,
+                    pinned: !!se.pinned,
+                    pin_synced: !!se.id,
+                    transcript_id: se.id || null,
+                    segment_count: se.segments || 0,
+                    words: se.words || 0,
+                    reading_seconds: se.reading_seconds || 0,
+                    wpm: se.wpm || 0
+                });
+                added++;
             }
-        }).catch(() => { /* Graceful degradation — localStorage-only fallback */ });
+        });
+
+        if (added > 0) {
+            // Sort by timestamp (newest first) and persist
+            logHistory.sort((a, b) => (b.timestamp || '').localeCompare(a.timestamp || ''));
+            persistHistory();
+        }
+        renderHistory();
+    }
+
+    // --- Activity calendar ---
+    // A year of days as a heatmap (from /api/history/calendar); clicking a
+    // day loads that day's notes and narrows the list to them.
+    let calendarYear = new Date().getFullYear();
+    let historyDay = null; // 'YYYY-MM-DD' while the list shows one day
+
+    function localDate(d) {
+        return d.getFullYear() + '-' + String(d.getMonth() + 1).padStart(2, '0') + '-' + String(d.getDate()).padStart(2, '0');
+    }
+
+    function loadCalendar() {
+        const tz = Intl.DateTimeFormat().resolvedOptions().timeZone || '';
+        fetch('/api/history/calendar?year=' + calendarYear + (tz ? '&tz=' + encodeURIComponent(tz) : ''))
+            .then(r => r.ok ? r.json() : Promise.reject(r.status))
+            .then(renderCalendar)
+            .catch(() => { el('calendarSummary').textContent = 'Calendar unavailable'; });
+    }
+
+    function renderCalendar(data) {
+        const days = new Map((data.days || []).map(d => [d.date, d]));
+        const max = Math.max(1, ...(data.days || []).map(d => d.count));
+        el('calendarYear').textContent = calendarYear;
+        el('calendarNext').disabled = calendarYear >= new Date().getFullYear();
+        const minutes = Math.round((data.duration || 0) / 60);
+        el('calendarSummary').textContent = `${data.total || 0} notes · ${minutes} min · ${(data.words || 0).toLocaleString()} words`;
+
+        // Columns are weeks starting on Sunday; pad the first week.
+        const cells = [];
+        const jan1 = new Date(calendarYear, 0, 1);
+        for (let i = 0; i < jan1.getDay(); i++) cells.push('<span class="cal-day pad"></span>');
+        for (let d = new Date(jan1); d.getFullYear() === calendarYear; d.setDate(d.getDate() + 1)) {
+            const date = localDate(d);
+            const day = days.get(date);
+            const level = day ? Math.ceil(day.count / max * 4) : 0;
+            const title = day ? `${date}: ${day.count} note${day.count === 1 ? '' : 's'}, ${Math.round(day.duration / 60)} min` : date;
+            const selected = date === historyDay ? ' selected' : '';
+            cells.push(`<button class="cal-day l${level}${selected}" data-date="${date}" title="${title}"${day ? '' : ' disabled'}></button>`);
+        }
+        el('calendarGrid').innerHTML = cells.join('');
+    }
+
+    function showHistoryDay(date) {
+        historyDay = date;
+        const [y, m, d] = date.split('-').map(Number);
+        const from = new Date(y, m - 1, d);
+        const to = new Date(y, m - 1, d + 1);
+        const url = '/api/history?limit=500&from=' + encodeURIComponent(from.toISOString()) +
+            '&to=' + encodeURIComponent(to.toISOString());
+        fetch(url).then(r => r.json()).then(data => {
+            mergeServerEntries(data && Array.isArray(data.entries) ? data.entries : []);
+        }).catch(() => renderHistory());
+        el('historyDayLabel').textContent = '📅 ' + from.toLocaleDateString(undefined, { weekday: 'short', year: 'numeric', month: 'short', day: 'numeric' });
+        el('historyDayFilter').classList.remove('hidden');
+        el('calendarGrid').querySelectorAll('.cal-day.selected').forEach(c => c.classList.remove('selected'));
+        el('calendarGrid').querySelector(`[data-date="${date}"]`)?.classList.add('selected');
+        renderHistory();
+    }
+
+    function clearHistoryDay() {
+        historyDay = null;
+        el('historyDayFilter').classList.add('hidden');
+        el('calendarGrid').querySelectorAll('.cal-day.selected').forEach(c => c.classList.remove('selected'));
+        renderHistory();
     }
 
     // --- Header time (stardate or normal clock) ---
@@ -237,12 +396,29 @@
     }
 
     function applySettings() {
+        ensureLanguageOption(settings.language || 'en');
         el('settLanguage').value = settings.language || 'en';
         el('settVaultDir').value = settings.vault_dir || '';
         el('settDownloadDir').value = settings.download_dir || '';
         el('settModel').value = settings.model || 'large-v3';
+        el('settDraftModel').value = settings.draft_model || '';
+        el('settLanguageModels').value = Object.entries(settings.language_models || {})
+            .map(([lang, model]) => `${lang}=${model}`).join(', ');
         el('settAutoCopy').checked = settings.auto_copy !== false;
         el('settAutoSave').checked = !!settings.auto_save;
+        el('settUndoWindow').value = settings.undo_window_seconds ?? 30;
+        el('settDefaultTags').value = (settings.default_tags || []).join(', ');
+        el('settAttachAudio').value = settings.attach_audio || '';
+        el('settAttachmentsDir').value = settings.attachments_dir || '';
+        el('settVaultMode').value = settings.vault_mode || '';
+        el('settDailyNoteFolder').value = settings.daily_note_folder || '';
+        el('settDailyNoteFormat').value = settings.daily_note_format || '';
+        el('settDailyNoteEntry').value = settings.daily_note_entry || '';
+        el('settDailyNoteOrder').value = settings.daily_note_order || '';
+        el('settDailyNoteTOC').checked = !!settings.daily_note_toc;
+        el('settNoteTemplate').value = settings.note_template || '';
+        el('settVaultSidecar').checked = !!settings.vault_sidecar;
+        el('settLiveDictation').checked = !!settings.live_dictation;
         el('settPrompt').value = settings.prompt || '';
         el('settVAD').checked = !!settings.vad_filter;
         el('settDiarize').checked = !!settings.diarize;
@@ -250,13 +426,39 @@
         el('settDateFormat').value = settings.date_format || '2006-01-02';
         el('settFileTitle').value = settings.file_title || 'Dictation';
         el('settWhisperURL').value = settings.whisper_url || '';
+        el('settBackendType').value = settings.backend_type || 'auto';
         el('settLLMURL').value = settings.llm_url || '';
         el('settEnableLLM').checked = !!settings.enable_llm;
+        el('settLLMDescribe').checked = !!settings.llm_describe;
+        el('settLanguageLearning').checked = !!settings.language_learning;
+        el('settTranslateTo').value = settings.translate_to || 'English';
+        el('settBilingualLayout').value = settings.bilingual_layout || 'columns';
+        el('settDigestSchedule').value = settings.digest_schedule || '';
+        el('settDigestPeriod').value = settings.digest_period || 'weekly';
+        el('settDigestFolder').value = settings.digest_folder || '';
+        el('settNotifyProvider').value = settings.notify_provider || '';
+        el('settNotifyURL').value = settings.notify_url || '';
+        el('settNotifyTopic').value = settings.notify_topic || '';
+        const notifyOn = settings.notify_on || defaults.notify_on;
+        el('settNotifyTranscription').checked = notifyOn.includes('transcription');
+        el('settNotifyFailure').checked = notifyOn.includes('failure');
+        el('settNotifyBackend').checked = notifyOn.includes('backend');
+        el('settNotifyKeyword').checked = notifyOn.includes('keyword');
+        el('settNotifyRefined').checked = notifyOn.includes('refined');
+        el('settWatchKeywords').value = (settings.watch_keywords || []).join(', ');
+        el('settNotifyMinSeconds').value = settings.notify_min_seconds ?? 60;
         el('settAccessLog').checked = !!settings.access_log;
         el('settTimeFormat').value = settings.time_format || 'system';
 
         // Advanced transcription parameters
         el('settWordTimestamps').checked = !!settings.word_timestamps;
+        el('settDisableEnrichment').checked = !!settings.disable_enrichment;
+        el('settPreprocessAudio').checked = !!settings.preprocess_audio;
+        el('settPreprocessNormalize').checked = !!settings.preprocess_normalize;
+        el('settPreprocessTrim').checked = !!settings.preprocess_trim_silence;
+        el('settChunkAfter').value = settings.chunk_after_minutes ?? 20;
+        el('settChunkMinutes').value = settings.chunk_minutes || 10;
+        el('settChunkConcurrency').value = settings.chunk_concurrency || 2;
         el('settConditionPrev').checked = settings.condition_on_previous_text !== false;
         el('settBeamSize').value = settings.beam_size ?? 5;
         el('settTemperature').value = settings.temperature ?? 0;
@@ -273,9 +475,57 @@
         el('settEnableTLS').checked = settings.enable_tls || false;
         el('settExportFormat').value = settings.default_export_format || 'txt';
         el('settExportMode').value = settings.export_mode || 'rich';
+        const subtitles = settings.subtitles || {};
+        el('settSubMaxLineChars').value = subtitles.max_line_chars || '';
+        el('settSubMaxLines').value = subtitles.max_lines || '';
+        el('settSubMinDuration').value = subtitles.min_duration || '';
+        el('settSubMaxDuration').value = subtitles.max_duration || '';
         el('settTranscriptDir').value = settings.transcript_dir || '';
         el('settTranslateDir').value = settings.translate_dir || '';
         el('settWatchDir').value = settings.watch_dir || '';
+        el('settWatchRecursive').checked = !!settings.watch_recursive;
+        el('settRecordingMaxAge').value = settings.recording_max_age_days || 0;
+        el('settRecordingMaxSize').value = settings.recording_max_size_mb || 0;
+        el('settArchiveAfter').value = settings.archive_after_months || 0;
+    }
+
+    // The selector lists a few languages; a detected or API-set one that
+    // isn't among them is added so it shows instead of a blank.
+    function ensureLanguageOption(code, name) {
+        const select = el('settLanguage');
+        if ([...select.options].some(o => o.value === code)) return;
+        const label = name ? name.charAt(0).toUpperCase() + name.slice(1) : code;
+        select.appendChild(new Option(label, code));
+    }
+
+    // Once, on the first transcription: if the language was never chosen,
+    // ask /api/detect-language and make what it hears the default instead
+    // of assuming English.
+    async function prefillLanguage(audioBlob) {
+        if (localStorage.getItem('captainslog_language_chosen')) return;
+        localStorage.setItem('captainslog_language_chosen', '1');
+        if ((settings.language || 'en') !== 'en') return;
+        try {
+            const formData = new FormData();
+            formData.append('file', audioBlob, 'recording.webm');
+            const res = await fetch('/api/detect-language', { method: 'POST', body: formData });
+            if (!res.ok) return;
+            const d = await res.json();
+            if (!d.language || d.language === settings.language) return;
+            if (d.confidence != null && d.confidence < 0.5) return;
+            ensureLanguageOption(d.language, d.name);
+            settings.language = d.language;
+            el('settLanguage').value = d.language;
+            localStorage.setItem('captainslog_prefs', JSON.stringify(settings));
+            fetch('/api/settings', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(settings)
+            }).catch(e => console.warn('Could not save detected language:', e));
+            showToast(`Language set to ${el('settLanguage').selectedOptions[0].textContent} — change it in Settings`);
+        } catch (e) {
+            console.warn('Language detection failed:', e);
+        }
     }
 
     function saveSettingsToServer() {
@@ -283,8 +533,25 @@
         settings.download_dir = el('settDownloadDir').value.trim();
         settings.language = el('settLanguage').value;
         settings.model = el('settModel').value;
+        settings.draft_model = el('settDraftModel').value;
+        settings.language_models = Object.fromEntries(el('settLanguageModels').value.split(',')
+            .map(pair => pair.split('=').map(s => s.trim()))
+            .filter(([lang, model]) => lang && model));
         settings.auto_copy = el('settAutoCopy').checked;
         settings.auto_save = el('settAutoSave').checked;
+        settings.undo_window_seconds = Math.max(parseInt(el('settUndoWindow').value) || 0, 0);
+        settings.default_tags = el('settDefaultTags').value.split(',').map(t => t.trim()).filter(Boolean);
+        settings.attach_audio = el('settAttachAudio').value;
+        settings.attachments_dir = el('settAttachmentsDir').value.trim();
+        settings.vault_mode = el('settVaultMode').value;
+        settings.daily_note_folder = el('settDailyNoteFolder').value.trim();
+        settings.daily_note_format = el('settDailyNoteFormat').value.trim();
+        settings.daily_note_entry = el('settDailyNoteEntry').value;
+        settings.daily_note_order = el('settDailyNoteOrder').value;
+        settings.daily_note_toc = el('settDailyNoteTOC').checked;
+        settings.note_template = el('settNoteTemplate').value.trim();
+        settings.vault_sidecar = el('settVaultSidecar').checked;
+        settings.live_dictation = el('settLiveDictation').checked;
         settings.prompt = el('settPrompt').value.trim();
         settings.vad_filter = el('settVAD').checked;
         settings.diarize = el('settDiarize').checked;
@@ -292,9 +559,24 @@
         settings.date_format = el('settDateFormat').value;
         settings.file_title = el('settFileTitle').value.trim() || 'Dictation';
         settings.whisper_url = el('settWhisperURL').value.trim();
+        settings.backend_type = el('settBackendType').value;
         settings.llm_url = el('settLLMURL').value.trim();
         settings.llm_model = el('settLLMModel')?.value || '';
         settings.enable_llm = el('settEnableLLM').checked;
+        settings.llm_describe = el('settLLMDescribe').checked;
+        settings.language_learning = el('settLanguageLearning').checked;
+        settings.translate_to = el('settTranslateTo').value.trim() || 'English';
+        settings.bilingual_layout = el('settBilingualLayout').value;
+        settings.digest_schedule = el('settDigestSchedule').value.trim();
+        settings.digest_period = el('settDigestPeriod').value;
+        settings.digest_folder = el('settDigestFolder').value.trim();
+        settings.notify_provider = el('settNotifyProvider').value;
+        settings.notify_url = el('settNotifyURL').value.trim();
+        settings.notify_topic = el('settNotifyTopic').value.trim();
+        settings.notify_on = [['transcription', 'settNotifyTranscription'], ['failure', 'settNotifyFailure'], ['backend', 'settNotifyBackend'], ['keyword', 'settNotifyKeyword'], ['refined', 'settNotifyRefined']]
+            .filter(([, id]) => el(id).checked).map(([name]) => name);
+        settings.watch_keywords = el('settWatchKeywords').value.split(',').map(k => k.trim()).filter(Boolean);
+        settings.notify_min_seconds = Math.max(parseInt(el('settNotifyMinSeconds').value) || 0, 0);
         settings.access_log = el('settAccessLog').checked;
 
         // Show/hide LLM button
@@ -306,15 +588,32 @@
 
         // Advanced transcription parameters
         settings.word_timestamps = el('settWordTimestamps').checked;
+        settings.disable_enrichment = el('settDisableEnrichment').checked;
+        settings.preprocess_audio = el('settPreprocessAudio').checked;
+        settings.preprocess_normalize = el('settPreprocessNormalize').checked;
+        settings.preprocess_trim_silence = el('settPreprocessTrim').checked;
+        settings.chunk_after_minutes = Math.max(parseInt(el('settChunkAfter').value) || 0, 0);
+        settings.chunk_minutes = Math.max(parseInt(el('settChunkMinutes').value) || 10, 1);
+        settings.chunk_concurrency = Math.max(parseInt(el('settChunkConcurrency').value) || 2, 1);
         settings.condition_on_previous_text = el('settConditionPrev').checked;
         settings.beam_size = parseInt(el('settBeamSize').value) || 5;
         settings.temperature = parseFloat(el('settTemperature').value) || 0;
 
         // Export mode and auto-export directory
         settings.export_mode = el('settExportMode').value || 'rich';
+        settings.subtitles = {
+            max_line_chars: parseInt(el('settSubMaxLineChars').value, 10) || 0,
+            max_lines: parseInt(el('settSubMaxLines').value, 10) || 0,
+            min_duration: parseFloat(el('settSubMinDuration').value) || 0,
+            max_duration: parseFloat(el('settSubMaxDuration').value) || 0
+        };
         settings.transcript_dir = el('settTranscriptDir').value.trim();
         settings.translate_dir = el('settTranslateDir').value.trim();
         settings.watch_dir = el('settWatchDir').value.trim();
+        settings.watch_recursive = el('settWatchRecursive').checked;
+        settings.recording_max_age_days = Math.max(parseInt(el('settRecordingMaxAge').value) || 0, 0);
+        settings.recording_max_size_mb = Math.max(parseInt(el('settRecordingMaxSize').value) || 0, 0);
+        settings.archive_after_months = Math.max(parseInt(el('settArchiveAfter').value) || 0, 0);
 
         // Auto-switch default format if SRT/VTT selected but now in Pure mode
         if (settings.export_mode === 'pure' && (settings.default_export_format === 'srt' || settings.default_export_format === 'vtt')) {
@@ -368,6 +667,16 @@
     const refreshBtn = document.getElementById('refreshLLMModels');
     if (refreshBtn) refreshBtn.addEventListener('click', fetchLLMModels);
 
+    // --- Push notification test ---
+    el('testNotify').addEventListener('click', (e) => {
+        e.preventDefault();
+        fetch('/api/notify/test', { method: 'POST' }).then(async r => {
+            if (r.ok) { flashButton(el('testNotify'), 'Sent!', 'success'); return; }
+            const data = await r.json().catch(() => ({}));
+            showToast('Test notification failed: ' + (data.error || r.status));
+        }).catch(err => showToast('Test notification failed: ' + err.message));
+    });
+
     // --- Settings modal ---
     settingsBtn.addEventListener('click', () => { applySettings(); fetchModels(); fetchLLMModels(); settingsModal.classList.remove('hidden'); });
     closeSettings.addEventListener('click', () => settingsModal.classList.add('hidden'));
@@ -399,6 +708,9 @@
             const data = await res.json();
             backendStatus.className = 'status-dot ' + (data.whisper === 'connected' ? 'connected' : 'error');
             backendStatus.title = `Whisper: ${data.whisper} | Vault: ${data.vault ? 'on' : 'off'} | Stardate: ${data.stardate}`;
+            if (data.ffmpeg === false) {
+                el('preprocessHint').textContent = 'ffmpeg is not installed on the server — uploads are sent as they are.';
+            }
         } catch {
             backendStatus.className = 'status-dot error';
             backendStatus.title = 'Backend unreachable';
@@ -501,7 +813,7 @@
             document.getElementById('placeholder').style.display = 'none';
 
             // Start streaming if configured
-            if (settings.stream_url) startStreaming(stream);
+            if (settings.stream_url || settings.live_dictation) startStreaming(stream);
         } catch (err) {
             console.error('Microphone access denied:', err);
             const isHTTPIssue = location.protocol === 'http:' && location.hostname !== 'localhost' && location.hostname !== '127.0.0.1';
@@ -548,9 +860,16 @@
 
     // --- Live streaming transcription ---
     function startStreaming(mediaStream) {
-        if (!settings.stream_url) return;
+        if (!settings.stream_url && !settings.live_dictation) return;
         try {
-            streamingWs = new WebSocket(settings.stream_url);
+            // Connect to our own relay, not the backend: it applies auth and
+            // backend credentials, and the page's CSP only allows 'self'.
+            // Without a streaming backend the server cuts the audio at pauses
+            // and transcribes each utterance itself (live dictation); both
+            // answer with the running text in "text".
+            const proto = location.protocol === 'https:' ? 'wss:' : 'ws:';
+            const path = settings.stream_url ? 'stream' : 'live';
+            streamingWs = new WebSocket(`${proto}//${location.host}/v1/audio/transcriptions/${path}`);
             streamingWs.binaryType = 'arraybuffer';
 
             // Show LIVE badge
@@ -681,6 +1000,7 @@
 
     // --- File upload (batch queue) ---
     browseBtn.addEventListener('click', (e) => { e.preventDefault(); fileInput.click(); });
+    el('settLanguage').addEventListener('change', () => localStorage.setItem('captainslog_language_chosen', '1'));
     fileInput.addEventListener('change', (e) => { if (e.target.files.length) processFileQueue([...e.target.files]); });
     uploadZone.addEventListener('click', () => fileInput.click());
     uploadZone.addEventListener('dragover', (e) => { e.preventDefault(); uploadZone.classList.add('dragover'); });
@@ -712,9 +1032,15 @@
         const translateMode = el('translateMode')?.checked || false;
         const processingMsg = translateMode ? 'Translating → English…' : 'Transcribing…';
         showProcessing(true, processingMsg);
+        if (!translateMode) await prefillLanguage(audioBlob);
         const formData = new FormData();
         formData.append('file', audioBlob, 'recording.webm');
         formData.append('response_format', 'json');
+        // Two-pass dictation: the draft model answers now, the server
+        // refines the saved note with the main model afterwards.
+        const model = settings.draft_model && !translateMode && !(audioBlob instanceof File)
+            ? settings.draft_model : settings.model;
+        if (model !== settings.model) formData.append('model', model);
         const lang = settings.language || 'en';
         if (lang && lang !== 'und') formData.append('language', lang);
         if (settings.prompt) formData.append('prompt', settings.prompt);
@@ -758,6 +1084,8 @@
                 const hint = statusMessages[res.status] || `HTTP ${res.status}`;
                 throw new Error(`${hint}${detail ? '\n\nBackend: ' + detail : ''}`);
             }
+            const audioWarnings = audioWarningMessage(res.headers.get('X-Captainslog-Audio-Warnings'));
+            if (audioWarnings) showToast(audioWarnings);
             let data;
             try {
                 data = await res.json();
@@ -768,6 +1096,7 @@
                 throw new Error(`Backend returned invalid JSON. The Whisper server may not support this endpoint.\n\nRaw response: ${rawText.slice(0, 200)}`);
             }
             let text = data.text || '';
+            currentWords = Array.isArray(data.words) ? data.words : [];
 
             // Handle verbose_json with segments (word-level timestamps / speaker labels)
             if (data.segments && data.segments.length > 0) {
@@ -818,22 +1147,44 @@
 
                 // Auto-save to vault and capture file path
                 let vaultFile = null;
+                let transcriptId = null;
                 if (settings.auto_save && settings.vault_dir) {
                     try {
+                        // All segments go to the server; localStorage only keeps
+                        // the first 200 and pages the rest back in on demand.
+                        const segments = (currentSegments || []).map(s => ({
+                            start: s.start, end: s.end, text: (s.text || '').trim(),
+                            ...(s.speaker ? { speaker: s.speaker } : {})
+                        }));
+                        // Word timings for the sidecar: top-level (OpenAI) or
+                        // nested in each segment (faster-whisper).
+                        const words = settings.vault_sidecar
+                            ? (currentWords.length ? currentWords : (currentSegments || []).flatMap(s => s.words || []))
+                                .map(w => ({ word: w.word, start: w.start, end: w.end, ...(w.probability ? { probability: w.probability } : {}) }))
+                            : [];
                         const vaultRes = await fetch('/api/vault/save', {
                             method: 'POST',
                             headers: { 'Content-Type': 'application/json' },
-                            body: JSON.stringify({ text: text.trim(), language: lang })
+                            body: JSON.stringify({
+                                text: text.trim(), language: lang, recording: recordingFile, segments,
+                                ...(words.length ? { words } : {}),
+                                model, source: audioBlob instanceof File ? 'upload' : 'microphone'
+                            })
                         });
                         if (vaultRes.ok) {
                             const vaultData = await vaultRes.json();
                             vaultFile = vaultData.file;
+                            transcriptId = vaultData.id || null;
+                            // Moved into the vault: no longer served from /api/recordings.
+                            if (vaultData.attachment && settings.attach_audio === 'move') recordingFile = null;
+                            if (vaultData.refining) showToast(`Draft saved — refining with ${vaultData.refining} in the background`);
                         }
                     } catch (e) { console.warn('Vault auto-save failed:', e); }
                 }
 
                 // Save to history with recording + vault links
-                addToHistory(text.trim(), lang, recordingFile, vaultFile);
+                addToHistory(text.trim(), lang, recordingFile, vaultFile, transcriptId);
+                if (vaultFile) offerUndo(vaultFile);
                 // Auto-copy
                 if (settings.auto_copy) navigator.clipboard.writeText(text.trim()).catch(() => { });
             }
@@ -852,6 +1203,24 @@
         }
     }
 
+    // The server flags recordings Whisper is likely to mishear (see
+    // audio.Quality); quiet ones are helped by loudness normalization.
+    function audioWarningMessage(header) {
+        if (!header) return '';
+        const messages = {
+            low_sample_rate: 'recorded at a low sample rate (phone quality)',
+            clipping: 'clipped — lower the input gain',
+            quiet: 'very quiet',
+        };
+        const codes = header.split(',').map(c => c.trim()).filter(c => messages[c]);
+        if (!codes.length) return '';
+        let msg = '⚠️ Audio ' + codes.map(c => messages[c]).join('; ') + ' — accuracy may suffer.';
+        if (codes.includes('quiet') && !settings.preprocess_normalize) {
+            msg += ' Try Settings → Normalize loudness.';
+        }
+        return msg;
+    }
+
     function formatTimestamp(seconds) {
         if (!seconds && seconds !== 0) return '';
         const m = Math.floor(seconds / 60);
@@ -959,13 +1328,15 @@
     const bulkSelectAll = document.getElementById('bulkSelectAll');
     let selectMode = false;
 
-    function addToHistory(text, language, recordingFile, vaultFile) {
+    function addToHistory(text, language, recordingFile, vaultFile, transcriptId) {
         const entry = {
             text: text.substring(0, 500),
             language: language,
             timestamp: new Date().toISOString(),
             recording: recordingFile || null,
-            vault_file: vaultFile || null
+            vault_file: vaultFile || null,
+            transcript_id: transcriptId || null,
+            segment_count: (currentSegments || []).length
         };
         // Store segments for SRT/VTT export (compact: only start/end/text)
         if (currentSegments && currentSegments.length > 0) {
@@ -986,6 +1357,68 @@
         renderHistory();
     }
 
+    // Undo toast after an auto-save: for undo_window_seconds the note can be
+    // deleted again (DELETE /api/vault/last), for accidental recordings.
+    let undoTimer = null;
+    function offerUndo(vaultFile) {
+        const seconds = settings.undo_window_seconds ?? 30;
+        if (seconds <= 0) return;
+        let toast = document.getElementById('undoToast');
+        if (!toast) {
+            toast = document.createElement('div');
+            toast.id = 'undoToast';
+            toast.className = 'watcher-toast undo-toast';
+            document.body.appendChild(toast);
+        }
+        toast.innerHTML = '💾 Saved to vault <button class="btn-secondary" type="button">Undo</button>';
+        toast.classList.add('visible');
+        clearTimeout(undoTimer);
+        undoTimer = setTimeout(() => toast.classList.remove('visible'), seconds * 1000);
+        toast.querySelector('button').onclick = async () => {
+            clearTimeout(undoTimer);
+            try {
+                const res = await fetch('/api/vault/last', { method: 'DELETE' });
+                const data = await res.json().catch(() => ({}));
+                if (!res.ok) throw new Error(data.error || `HTTP ${res.status}`);
+                // The text stays in history; only the links to the note go.
+                for (const entry of logHistory) {
+                    if (entry.vault_file === vaultFile) {
+                        entry.vault_file = null;
+                        entry.transcript_id = null;
+                    }
+                }
+                persistHistory();
+                renderHistory();
+                toast.textContent = '↩️ Save undone';
+            } catch (e) {
+                toast.textContent = `❌ Undo failed: ${e.message}`;
+            }
+            undoTimer = setTimeout(() => toast.classList.remove('visible'), 3000);
+        };
+    }
+
+    // Full segment list for a history entry. localStorage holds at most 200;
+    // longer transcripts are paged in from /api/transcripts/{id}/segments.
+    async function loadSegments(entry) {
+        const local = entry.segments || [];
+        if (!entry.transcript_id || (entry.segment_count || 0) <= local.length) return local;
+        try {
+            const all = [];
+            let offset = 0;
+            while (offset !== null && offset !== undefined) {
+                const res = await fetch(`/api/transcripts/${encodeURIComponent(entry.transcript_id)}/segments?offset=${offset}&limit=1000`);
+                if (!res.ok) return local;
+                const page = await res.json();
+                all.push(...page.segments);
+                offset = page.next_offset;
+            }
+            return all;
+        } catch (e) {
+            console.warn('Segment fetch failed, using local copy:', e);
+            return local;
+        }
+    }
+
     function renderHistory() {
         const limit = settings.history_limit || 0;
         const total = logHistory.length;
@@ -1008,6 +1441,12 @@
             searchRecent = logHistory.map((e, i) => ({ ...e, _idx: i }))
                 .filter(e => !e.pinned && e.text.toLowerCase().includes(query));
         }
+        // A day picked in the calendar narrows both lists, ignoring the limit.
+        if (historyDay) {
+            const onDay = e => localDate(new Date(e.timestamp)) === historyDay;
+            searchPinned = searchPinned.filter(onDay);
+            searchRecent = (query ? searchRecent : unpinned).filter(onDay);
+        }
 
         // Update count
         const shownCount = searchPinned.length + searchRecent.length;
@@ -1027,16 +1466,35 @@
             }
         }
 
+        // "Load more" fetches older notes from the server. Hidden while the
+        // history limit or a search already hides entries we have.
+        const loadMoreBtn = document.getElementById('historyLoadMore');
+        if (loadMoreBtn) {
+            const truncated = limit > 0 && unpinned.length > limit;
+            loadMoreBtn.classList.toggle('hidden', !historyCursor || truncated || !!query || !!historyDay);
+        }
+
         // Recent (unpinned)
         if (searchRecent.length === 0) {
             historyList.innerHTML = query
                 ? '<div class="history-empty">No matches found.</div>'
-                : '<div class="history-empty">No logs yet. Record your first entry above.</div>';
+                : historyDay
+                    ? '<div class="history-empty">No notes on this day.</div>'
+                    : '<div class="history-empty">No logs yet. Record your first entry above.</div>';
             return;
         }
         historyList.innerHTML = searchRecent.map(entry => renderEntry(entry, entry._idx)).join('');
     }
 
+    // Reading time and speech rate from the transcript index, for the
+    // word count's tooltip.
+    function wordCountTitle(entry) {
+        const parts = [];
+        if (entry.reading_seconds) parts.push(`${Math.max(1, Math.round(entry.reading_seconds / 60))} min read`);
+        if (entry.wpm) parts.push(`${Math.round(entry.wpm)} words/min spoken`);
+        return parts.join(' · ');
+    }
+
     function renderEntry(entry, origIndex) {
         const time = new Date(entry.timestamp);
         const timeOpts = { hour: '2-digit', minute: '2-digit' };
@@ -1070,7 +1528,7 @@
         // Overflow menu (⋮)
         let menuItems = '';
         menuItems += `<button role="menuitem" class="overflow-item" data-export-as="${origIndex}">📥 Export As…</button>`;
-        if (entry.segments && entry.segments.length > 0) {
+        if ((entry.segments && entry.segments.length > 0) || (entry.transcript_id && entry.segment_count > 0)) {
             menuItems += `<button role="menuitem" class="overflow-item" data-edit-subs="${origIndex}">✍️ Edit Subtitles</button>`;
         }
         menuItems += `<button role="menuitem" class="overflow-item" data-toggle-notes="${origIndex}">📝 Notes</button>`;
@@ -1100,7 +1558,7 @@
                         <span class="log-entry-time">${timeStr}</span>
                         <span>${dateStr}</span>
                         ${stardate}
-                        <span class="word-count">${entry.text.split(/\s+/).filter(w => w).length}w</span>
+                        <span class="word-count" title="${wordCountTitle(entry)}">${entry.words || entry.text.split(/\s+/).filter(w => w).length}w</span>
                         ${isPinned ? '<span class="pin-badge">📌</span>' : ''}
                         ${entry.recording ? '<span>🎙️</span>' : ''}
                         ${entry.vault_file ? '<span>📁</span>' : ''}
@@ -1202,6 +1660,24 @@
             flashButton(bulkDeleteBtn, `Deleted ${indices.length}!`, 'success');
         });
     }
+    document.getElementById('historyLoadMore')?.addEventListener('click', () => {
+        if (historyCursor) hydrateFromServer(historyCursor);
+    });
+
+    el('historyCalendarBtn')?.addEventListener('click', () => {
+        const cal = el('historyCalendar');
+        const open = cal.classList.toggle('hidden') === false;
+        el('historyCalendarBtn').setAttribute('aria-expanded', String(open));
+        if (open) loadCalendar();
+    });
+    el('calendarPrev')?.addEventListener('click', () => { calendarYear--; loadCalendar(); });
+    el('calendarNext')?.addEventListener('click', () => { calendarYear++; loadCalendar(); });
+    el('calendarGrid')?.addEventListener('click', (e) => {
+        const cell = e.target.closest('.cal-day[data-date]');
+        if (cell && !cell.disabled) showHistoryDay(cell.dataset.date);
+    });
+    el('historyDayClear')?.addEventListener('click', clearHistoryDay);
+
     // Search history
     const historySearch = document.getElementById('historySearch');
     if (historySearch) {
@@ -1285,7 +1761,7 @@
         pinnedBulkUnpinBtn.addEventListener('click', () => {
             const indices = getPinnedSelectedIndices();
             if (!indices.length) return;
-            indices.forEach(idx => { if (logHistory[idx]) logHistory[idx].pinned = false; });
+            indices.forEach(idx => { if (logHistory[idx]) setPinned(logHistory[idx], false); });
             persistHistory();
             renderHistory();
             exitPinnedSelectMode();
@@ -1316,7 +1792,7 @@
             e.stopPropagation();
             const idx = parseInt(pinBtn.dataset.pin, 10);
             if (logHistory[idx]) {
-                logHistory[idx].pinned = !logHistory[idx].pinned;
+                setPinned(logHistory[idx], !logHistory[idx].pinned);
                 persistHistory();
                 renderHistory();
             }
@@ -1408,7 +1884,7 @@
             closeAllOverflows();
             const idx = parseInt(exportEntryBtn.dataset.export);
             if (logHistory[idx]) {
-                doExport(logHistory[idx].text, logHistory[idx].segments || [], getDefaultExportFormat());
+                loadSegments(logHistory[idx]).then(segs => doExport(logHistory[idx].text, segs, getDefaultExportFormat()));
             }
             return;
         }
@@ -1420,7 +1896,7 @@
             closeAllOverflows();
             const idx = parseInt(exportAsEntry.dataset.exportAs);
             if (logHistory[idx]) {
-                showExportAsDialog(logHistory[idx].text, logHistory[idx].segments || []);
+                loadSegments(logHistory[idx]).then(segs => showExportAsDialog(logHistory[idx].text, segs));
             }
             return;
         }
@@ -1432,8 +1908,8 @@
             closeAllOverflows();
             const idx = parseInt(editSubsBtn.dataset.editSubs);
             const entry = logHistory[idx];
-            if (entry && entry.segments) {
-                openEditor(entry.segments, entry.recording, idx);
+            if (entry) {
+                loadSegments(entry).then(segs => openEditor(segs, entry.recording, idx));
             }
             return;
         }
@@ -1620,6 +2096,44 @@
     }
 
     function doExport(text, segments, format, filenameBase) {
+        // Subtitles follow the cue rules, which only the server applies.
+        const subtitleRules = (format === 'srt' || format === 'vtt') &&
+            Object.values(settings.subtitles || {}).some(v => v > 0);
+        if (format === 'docx' || format === 'pdf' || format === 'minutes' || format === 'flashcards' || subtitleRules) {
+            // Binary formats, minutes and flashcards are rendered by the server (/api/export).
+            const pureText = (text || '').replace(/<[^>]*>/g, '').trim();
+            fetch('/api/export', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    format,
+                    title: filenameBase || settings.file_title || 'Dictation',
+                    language: settings.language || '',
+                    text: pureText,
+                    segments: (segments || []).map(s => ({
+                        start: s.start || 0,
+                        end: s.end || 0,
+                        text: (s.text || '').trim(),
+                        ...(s.speaker !== undefined && s.speaker !== null ? { speaker: `SPEAKER ${s.speaker + 1}` } : {})
+                    })),
+                    timestamps: (settings.export_mode || 'rich') === 'rich'
+                })
+            }).then(r => {
+                if (!r.ok) throw new Error('HTTP ' + r.status);
+                return r.blob();
+            }).then(blob => {
+                const url = URL.createObjectURL(blob);
+                const a = document.createElement('a');
+                a.href = url;
+                a.download = `${filenameBase || settings.file_title || 'Dictation'}_${Date.now()}.${{ minutes: 'md', flashcards: 'tsv' }[format] || format}`;
+                a.style.display = 'none';
+                document.body.appendChild(a);
+                a.click();
+                document.body.removeChild(a);
+                URL.revokeObjectURL(url);
+            }).catch(err => showToast('Export failed: ' + err.message));
+            return;
+        }
         const content = exportContent(text, segments, format);
         const ext = format;
         const mimeMap = {
@@ -1891,6 +2405,25 @@
                 return;
             }
             const savedTranscription = currentTranscription; // Preserve — AI response must not overwrite
+            if (Array.isArray(settings.pipeline) && settings.pipeline.length > 0) {
+                // Configured post-processing steps run server-side, in order.
+                try {
+                    flashButton(llmBtn, 'Running pipeline…', '');
+                    const res = await fetch('/api/pipeline/run', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ text: currentTranscription })
+                    });
+                    const d = await res.json().catch(() => ({}));
+                    if (!res.ok) throw new Error(d.error || `Pipeline returned ${res.status}`);
+                    (d.steps || []).forEach(s => appendTranscription(`\n\n🤖 ${s.name}:\n${s.output}`, false));
+                    currentTranscription = savedTranscription;
+                    flashButton(llmBtn, '✅ Done', 'success');
+                } catch (err) {
+                    flashButton(llmBtn, err.message || 'Pipeline failed', 'error');
+                }
+                return;
+            }
             const model = settings.llm_model || 'llama3.2';
             const aiPrompt = 'Please review, correct errors, improve formatting, and respond:\n\n' + currentTranscription;
 
@@ -2367,9 +2900,12 @@
     // ====================================================================
     // FOLDER WATCHER SSE (auto-transcription notifications)
     // ====================================================================
-    function connectWatcherSSE() {
-        if (!settings.watch_dir) return;
-        const evtSource = new EventSource('/api/watcher/events');
+    // Two streams: the watch_dir watcher, and the per-folder watchers from
+    // /api/watchers (their events carry the watcher id).
+    function connectWatcherSSE(url = '/api/watcher/events') {
+        // Always connected: the watcher can be started later from
+        // /api/watcher/start or by setting a watch directory.
+        const evtSource = new EventSource(url);
         evtSource.onmessage = (event) => {
             try {
                 const ev = JSON.parse(event.data);
@@ -2385,13 +2921,17 @@
                     showToast(`⏳ Transcribing ${ev.filename}…`);
                 } else if (ev.type === 'error') {
                     showToast(`❌ ${ev.filename}: ${ev.error}`);
+                } else if (ev.type === 'started') {
+                    showToast(`📁 Folder watcher ${ev.watcher ? ev.watcher + ' ' : ''}started`);
+                } else if (ev.type === 'stopped') {
+                    showToast(`📁 Folder watcher ${ev.watcher ? ev.watcher + ' ' : ''}stopped`);
                 }
             } catch (e) { /* ignore parse errors */ }
         };
         evtSource.onerror = () => {
             // Reconnect after 5s on error
             evtSource.close();
-            setTimeout(connectWatcherSSE, 5000);
+            setTimeout(() => connectWatcherSSE(url), 5000);
         };
     }
 
@@ -2409,7 +2949,10 @@
     }
 
     // Connect after settings are loaded
-    setTimeout(connectWatcherSSE, 2000);
+    setTimeout(() => {
+        connectWatcherSSE('/api/watcher/events');
+        connectWatcherSSE('/api/watchers/events');
+    }, 2000);
 
     // --- PWA install ---
     let deferredInstallPrompt = null;
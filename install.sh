@@ -5,7 +5,6 @@ set -euo pipefail
 
 REPO="https://github.com/ryan-winkler/captainslog-whisper.git"
 INSTALL_DIR="$HOME/.local/bin"
-CONFIG_DIR="$HOME/.config/captainslog"
 SERVICE_DIR="$HOME/.config/systemd/user"
 CLONE_DIR="$HOME/code/captainslog-whisper"
 
@@ -58,9 +57,8 @@ if [ -f captainslog-cli ]; then
     chmod +x "$INSTALL_DIR/captainslog-cli"
 fi
 
-# --- Create config directory ---
-mkdir -p "$CONFIG_DIR"
-mkdir -p "$CONFIG_DIR/recordings"
+# The config directory (~/.config/captainslog on Linux, ~/Library/Application
+# Support/captainslog on macOS) is created by captainslog on first start.
 
 # --- Check PATH ---
 if [[ ":$PATH:" != *":$INSTALL_DIR:"* ]]; then
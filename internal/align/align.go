@@ -0,0 +1,338 @@
+// Package align times existing text against its audio (forced alignment):
+// each word of a transcript you have edited gets back a start and end, so
+// subtitles built from the edited text stay in sync with the speech.
+//
+// Whisper only times the words it heard itself; once a transcript has
+// been corrected, merged or rewritten, its segments no longer match the
+// text. Two backends are supported: a WhisperX-style HTTP service (POST
+// /align with the audio and text, answering WhisperX's own JSON), or
+// aeneas run through python3 when it is installed.
+package align
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backends.
+const (
+	BackendWhisperX = "whisperx"
+	BackendAeneas   = "aeneas"
+)
+
+// ErrUnavailable is returned by Align when there is no backend: no URL
+// is set and aeneas isn't installed.
+var ErrUnavailable = errors.New("no forced-alignment backend")
+
+// Word is one word of the text with its timing in seconds.
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Score float64 `json:"score,omitempty"` // confidence, when the backend gives one
+}
+
+// Aligner aligns text with audio through the configured backend.
+type Aligner struct {
+	url    string
+	client *http.Client
+	python string
+
+	once   sync.Once
+	aeneas bool
+}
+
+// New returns an Aligner that uses the WhisperX-style service at url, or
+// aeneas when url is empty.
+func New(url string, transport http.RoundTripper) *Aligner {
+	return &Aligner{
+		url:    strings.TrimRight(url, "/"),
+		client: &http.Client{Transport: transport, Timeout: 30 * time.Minute},
+		python: "python3",
+	}
+}
+
+// Backend returns the backend in use, or "" when there is none. The first
+// call without a URL checks whether aeneas can be imported.
+func (a *Aligner) Backend() string {
+	if a.url != "" {
+		return BackendWhisperX
+	}
+	a.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		a.aeneas = exec.CommandContext(ctx, a.python, "-c", "import aeneas.tools.execute_task").Run() == nil
+	})
+	if a.aeneas {
+		return BackendAeneas
+	}
+	return ""
+}
+
+// Align returns the words of text timed against the audio file. language
+// is an ISO 639-1 code ("en"), or empty for English with aeneas and
+// detection with WhisperX. Words the backend could not time take the
+// gap between their neighbours.
+func (a *Aligner) Align(ctx context.Context, audioPath, text, language string) ([]Word, error) {
+	if len(strings.Fields(text)) == 0 {
+		return nil, errors.New("no text to align")
+	}
+	var words []Word
+	var err error
+	switch a.Backend() {
+	case BackendWhisperX:
+		words, err = a.whisperX(ctx, audioPath, text, language)
+	case BackendAeneas:
+		words, err = a.runAeneas(ctx, audioPath, text, language)
+	default:
+		return nil, ErrUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, errors.New("alignment returned no words")
+	}
+	fillGaps(words)
+	return words, nil
+}
+
+func (a *Aligner) whisperX(ctx context.Context, audioPath, text, language string) ([]Word, error) {
+	f, err := os.Open(audioPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Streamed, like the proxy's uploads: a recording may be an hour long.
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			part, err := mw.CreateFormFile("file", filepath.Base(audioPath))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, f); err != nil {
+				return err
+			}
+			mw.WriteField("text", text)
+			if language != "" {
+				mw.WriteField("language", language)
+			}
+			return mw.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url+"/align", pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("alignment service: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("alignment service: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alignment service returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 500)])))
+	}
+	return parseWhisperX(body)
+}
+
+// whisperXWord is a word as WhisperX writes it: start and end are missing
+// for words it could not align (often numerals).
+type whisperXWord struct {
+	Word  string   `json:"word"`
+	Start *float64 `json:"start"`
+	End   *float64 `json:"end"`
+	Score float64  `json:"score"`
+}
+
+// parseWhisperX reads the output of whisperx.align: the flat word_segments
+// list, or else the words of each segment.
+func parseWhisperX(body []byte) ([]Word, error) {
+	var resp struct {
+		WordSegments []whisperXWord `json:"word_segments"`
+		Segments     []struct {
+			Words []whisperXWord `json:"words"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("alignment service: invalid JSON: %w", err)
+	}
+	list := resp.WordSegments
+	if len(list) == 0 {
+		for _, s := range resp.Segments {
+			list = append(list, s.Words...)
+		}
+	}
+	words := make([]Word, 0, len(list))
+	for _, w := range list {
+		word := Word{Word: strings.TrimSpace(w.Word), Start: -1, End: -1, Score: w.Score}
+		if word.Word == "" {
+			continue
+		}
+		if w.Start != nil && w.End != nil {
+			word.Start, word.End = *w.Start, *w.End
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+func (a *Aligner) runAeneas(ctx context.Context, audioPath, text, language string) ([]Word, error) {
+	dir, err := os.MkdirTemp("", "captainslog-align-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// One word per line: aeneas times lines, so this times words.
+	textPath := filepath.Join(dir, "text.txt")
+	if err := os.WriteFile(textPath, []byte(strings.Join(strings.Fields(text), "\n")+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	outPath := filepath.Join(dir, "map.json")
+	task := "task_language=" + aeneasLanguage(language) + "|is_text_type=plain|os_task_file_format=json"
+	cmd := exec.CommandContext(ctx, a.python, "-m", "aeneas.tools.execute_task", audioPath, textPath, task, outPath)
+	var stderr bytes.Buffer
+	cmd.Stdout = &stderr // aeneas reports errors on stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return nil, fmt.Errorf("aeneas: %w: %s", err, msg)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("aeneas: %w", err)
+	}
+	return parseAeneas(data)
+}
+
+// parseAeneas reads an aeneas JSON sync map, whose times are strings.
+func parseAeneas(data []byte) ([]Word, error) {
+	var m struct {
+		Fragments []struct {
+			Begin string   `json:"begin"`
+			End   string   `json:"end"`
+			Lines []string `json:"lines"`
+		} `json:"fragments"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("aeneas: invalid sync map: %w", err)
+	}
+	words := make([]Word, 0, len(m.Fragments))
+	for _, f := range m.Fragments {
+		text := strings.TrimSpace(strings.Join(f.Lines, " "))
+		if text == "" {
+			continue
+		}
+		start, err1 := strconv.ParseFloat(f.Begin, 64)
+		end, err2 := strconv.ParseFloat(f.End, 64)
+		if err1 != nil || err2 != nil {
+			start, end = -1, -1
+		}
+		words = append(words, Word{Word: text, Start: start, End: end})
+	}
+	return words, nil
+}
+
+// aeneasLanguages maps ISO 639-1 codes to the ISO 639-3 codes aeneas uses.
+var aeneasLanguages = map[string]string{
+	"cs": "ces", "da": "dan", "de": "deu", "el": "ell", "en": "eng", "es": "spa",
+	"fi": "fin", "fr": "fra", "hu": "hun", "it": "ita", "ja": "jpn", "ko": "kor",
+	"nb": "nor", "nl": "nld", "no": "nor", "pl": "pol", "pt": "por", "ro": "ron",
+	"ru": "rus", "sv": "swe", "tr": "tur", "uk": "ukr", "zh": "cmn",
+}
+
+func aeneasLanguage(language string) string {
+	language = strings.ToLower(language)
+	if code, ok := aeneasLanguages[language]; ok {
+		return code
+	}
+	if len(language) == 3 {
+		return language // already ISO 639-3
+	}
+	return "eng"
+}
+
+// fillGaps times the words the backend could not (Start < 0): each spans
+// the gap from the previous timed word's end to the next one's start,
+// shared evenly between untimed neighbours.
+func fillGaps(words []Word) {
+	for i := 0; i < len(words); i++ {
+		if words[i].Start >= 0 {
+			continue
+		}
+		j := i
+		for j < len(words) && words[j].Start < 0 {
+			j++
+		}
+		from := 0.0
+		if i > 0 {
+			from = words[i-1].End
+		}
+		to := from
+		if j < len(words) {
+			to = max(words[j].Start, from)
+		}
+		step := (to - from) / float64(j-i)
+		for k := i; k < j; k++ {
+			words[k].Start = from + step*float64(k-i)
+			words[k].End = from + step*float64(k-i+1)
+		}
+		i = j
+	}
+}
+
+// Segment is a run of aligned words.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Segments groups words into sentences, for subtitles: a segment ends
+// after a word ending a sentence, or before a pause of more than a
+// second.
+func Segments(words []Word) []Segment {
+	var segs []Segment
+	var cur []string
+	var start float64
+	for i, w := range words {
+		if len(cur) == 0 {
+			start = w.Start
+		}
+		cur = append(cur, w.Word)
+		end := strings.TrimRight(w.Word, `"')”’»`)
+		if i == len(words)-1 || strings.HasSuffix(end, ".") || strings.HasSuffix(end, "?") ||
+			strings.HasSuffix(end, "!") || words[i+1].Start-w.End > 1 {
+			segs = append(segs, Segment{Start: start, End: w.End, Text: strings.Join(cur, " ")})
+			cur = nil
+		}
+	}
+	return segs
+}
@@ -0,0 +1,105 @@
+package align
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseWhisperX(t *testing.T) {
+	words, err := parseWhisperX([]byte(`{"segments":[{"start":0.1,"end":2,"text":"Hello 42 world.","words":[
+		{"word":"Hello","start":0.1,"end":0.5,"score":0.9},{"word":"42"},{"word":"world.","start":1.2,"end":2.0,"score":0.8}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillGaps(words)
+	want := []Word{{"Hello", 0.1, 0.5, 0.9}, {"42", 0.5, 1.2, 0}, {"world.", 1.2, 2, 0.8}}
+	if !reflect.DeepEqual(words, want) {
+		t.Errorf("words = %v, want %v", words, want)
+	}
+	if _, err := parseWhisperX([]byte("<html>")); err == nil {
+		t.Error("HTML parsed")
+	}
+}
+
+func TestParseAeneas(t *testing.T) {
+	words, err := parseAeneas([]byte(`{"fragments":[
+		{"begin":"0.000","end":"0.640","id":"f000001","lines":["Captain's"]},
+		{"begin":"0.640","end":"1.000","id":"f000002","lines":["log."]},
+		{"begin":"1.000","end":"1.000","id":"f000003","lines":[]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Word{{Word: "Captain's", Start: 0, End: 0.64}, {Word: "log.", Start: 0.64, End: 1}}
+	if !reflect.DeepEqual(words, want) {
+		t.Errorf("words = %v, want %v", words, want)
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	words := []Word{{Word: "a", Start: -1, End: -1}, {Word: "b", Start: 1, End: 2},
+		{Word: "c", Start: -1, End: -1}, {Word: "d", Start: -1, End: -1}, {Word: "e", Start: 3, End: 4}, {Word: "f", Start: -1, End: -1}}
+	fillGaps(words)
+	var got [][2]float64
+	for _, w := range words {
+		got = append(got, [2]float64{w.Start, w.End})
+	}
+	want := [][2]float64{{0, 1}, {1, 2}, {2, 2.5}, {2.5, 3}, {3, 4}, {4, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("timings = %v, want %v", got, want)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	segs := Segments([]Word{{Word: "Hello", Start: 0, End: 0.4}, {Word: "there.", Start: 0.5, End: 1},
+		{Word: "Pause", Start: 1.1, End: 1.5}, {Word: "here", Start: 3, End: 3.5}, {Word: `"done!"`, Start: 3.6, End: 4}})
+	want := []Segment{{0, 1, "Hello there."}, {1.1, 1.5, "Pause"}, {3, 4, `here "done!"`}}
+	if !reflect.DeepEqual(segs, want) {
+		t.Errorf("segments = %v, want %v", segs, want)
+	}
+}
+
+func TestAlignWhisperX(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if r.URL.Path != "/align" || err != nil || r.FormValue("text") != "Hello world" || r.FormValue("language") != "en" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if audio, _ := io.ReadAll(f); string(audio) != "RIFF" {
+			http.Error(w, "bad audio", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"word_segments":[{"word":"Hello","start":0,"end":0.5},{"word":"world","start":0.6,"end":1}]}`)
+	}))
+	defer srv.Close()
+	audio := filepath.Join(t.TempDir(), "memo.wav")
+	os.WriteFile(audio, []byte("RIFF"), 0o600)
+
+	a := New(srv.URL+"/", nil)
+	if a.Backend() != BackendWhisperX {
+		t.Fatalf("backend = %q", a.Backend())
+	}
+	words, err := a.Align(context.Background(), audio, "Hello world", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 2 || words[1] != (Word{Word: "world", Start: 0.6, End: 1}) {
+		t.Errorf("words = %v", words)
+	}
+	if _, err := a.Align(context.Background(), audio, "Other text", "en"); err == nil {
+		t.Error("HTTP 400 not reported")
+	}
+
+	none := New("", nil)
+	none.python = filepath.Join(t.TempDir(), "no-python")
+	if _, err := none.Align(context.Background(), audio, "Hello", ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("without a backend = %v", err)
+	}
+}
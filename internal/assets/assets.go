@@ -0,0 +1,164 @@
+// Package assets serves the embedded web UI with cache headers.
+//
+// Every file is hashed once at startup. HTML pages are rewritten so their
+// references to other embedded files carry the hash ("app.js?v=3f2a…"), and
+// a request with the matching ?v= is cached for a year as immutable: a new
+// release changes the hash, and so the URL, so no browser ever runs stale
+// code. Everything else — the pages themselves, the service worker, the
+// manifest, unversioned URLs — is served no-cache with a content ETag, so a
+// repeat visit costs one 304 per file instead of a full download.
+//
+// Text files are also served compressed when the client accepts it: gzip,
+// made at startup, or brotli if the build left an app.js.br beside app.js
+// (`make compress`; the standard library has no brotli encoder), with the
+// hash of the app.js it was made from in app.js.br.sha256. A sidecar whose
+// hash doesn't match is stale and left out.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// immutable is the Cache-Control for a fingerprinted URL.
+const immutable = "public, max-age=31536000, immutable"
+
+// ref matches a src or href attribute pointing at a relative or root path.
+var ref = regexp.MustCompile(`\b(src|href)="(/?[A-Za-z0-9._-]+)"`)
+
+type file struct {
+	data []byte
+	hash string // first 16 hex digits of the SHA-256 of the served bytes
+	br   []byte // brotli variant, from a build-time sidecar (nil = none)
+	gz   []byte // gzip variant (nil = not worth it)
+}
+
+// Handler serves files from an embedded tree. It is immutable once built.
+type Handler struct {
+	files map[string]*file // by path without the leading slash
+}
+
+// New reads and hashes every file in fsys.
+func New(fsys fs.FS) (*Handler, error) {
+	h := &Handler{files: map[string]*file{}}
+	sidecars := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(name, suffixBrotli+suffixSource) {
+			sidecars[name] = data
+			return nil
+		}
+		if ext := path.Ext(name); (ext == suffixBrotli || ext == suffixGzip) && compressible[path.Ext(strings.TrimSuffix(name, ext))] {
+			sidecars[name] = data
+			return nil
+		}
+		h.files[name] = &file{data: data, hash: hashOf(data)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Pages are rewritten after every other file is hashed, and hashed
+	// again afterwards so their ETag covers the new references.
+	for name, f := range h.files {
+		if path.Ext(name) == ".html" {
+			f.data = h.fingerprint(f.data)
+			f.hash = hashOf(f.data)
+		}
+	}
+	for name, f := range h.files {
+		if !compressible[path.Ext(name)] {
+			continue
+		}
+		// A build-time sidecar of a page would have the references as
+		// written, not fingerprinted, so pages only get startup gzip.
+		if path.Ext(name) != ".html" {
+			f.br = freshBrotli(f.data, sidecars[name+suffixBrotli], sidecars[name+suffixBrotli+suffixSource])
+			f.gz = freshGzip(f.data, sidecars[name+suffixGzip])
+		}
+		if f.gz == nil {
+			if gz := gzipBytes(f.data); len(gz) < len(f.data) {
+				f.gz = gz
+			}
+		}
+	}
+	return h, nil
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// fingerprint appends ?v=<hash> to references to embedded files other than
+// pages.
+func (h *Handler) fingerprint(page []byte) []byte {
+	return ref.ReplaceAllFunc(page, func(m []byte) []byte {
+		sub := ref.FindSubmatch(m)
+		name := strings.TrimPrefix(string(sub[2]), "/")
+		f, ok := h.files[name]
+		if !ok || path.Ext(name) == ".html" {
+			return m
+		}
+		return []byte(string(sub[1]) + `="` + string(sub[2]) + "?v=" + f.hash + `"`)
+	})
+}
+
+// ServeHTTP serves GET and HEAD requests for embedded files; "/" is
+// index.html.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	f, ok := h.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	hdr := w.Header()
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		hdr.Set("Content-Type", ct)
+	}
+	encoding, data := f.variant(r.Header.Get("Accept-Encoding"))
+	if f.br != nil || f.gz != nil {
+		hdr.Add("Vary", "Accept-Encoding")
+	}
+	if encoding != "" {
+		hdr.Set("Content-Encoding", encoding)
+		// Each representation needs its own strong ETag.
+		hdr.Set("ETag", `"`+f.hash+"-"+encoding+`"`)
+	} else {
+		hdr.Set("ETag", `"`+f.hash+`"`)
+	}
+	if v := r.URL.Query().Get("v"); v != "" && v == f.hash {
+		hdr.Set("Cache-Control", immutable)
+	} else {
+		// WHY no-cache rather than a short max-age? It still lets the
+		// browser keep the file, but it asks first, so a new release is
+		// picked up on the next load — a 304 costs almost nothing on a LAN.
+		hdr.Set("Cache-Control", "no-cache")
+	}
+	// ServeContent handles If-None-Match, Range and HEAD.
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
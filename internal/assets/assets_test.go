@@ -0,0 +1,211 @@
+package assets
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":    {Data: []byte(`<link rel="stylesheet" href="style.css"><link rel="manifest" href="/manifest.json"><a href="/healthz">x</a><script src="app.js"></script>`)},
+		"app.js":        {Data: []byte(`console.log("v1")`)},
+		"style.css":     {Data: []byte(`body{}`)},
+		"manifest.json": {Data: []byte(`{}`)},
+	}
+}
+
+func get(t *testing.T, h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFingerprintedPage(t *testing.T) {
+	h, err := New(testFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := get(t, h, "/")
+	body := rec.Body.String()
+	js := h.files["app.js"].hash
+	for _, want := range []string{
+		`src="app.js?v=` + js + `"`,
+		`href="style.css?v=` + h.files["style.css"].hash + `"`,
+		`href="/manifest.json?v=`,
+		`href="/healthz"`, // not an embedded file
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %s:\n%s", want, body)
+		}
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("page Cache-Control = %q", cc)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("page Content-Type = %q", ct)
+	}
+
+	rec = get(t, h, "/app.js?v="+js)
+	if cc := rec.Header().Get("Cache-Control"); cc != immutable {
+		t.Errorf("versioned Cache-Control = %q", cc)
+	}
+	// A stale or missing version must not be cached for long.
+	for _, target := range []string{"/app.js", "/app.js?v=0000000000000000"} {
+		if cc := get(t, h, target).Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("%s Cache-Control = %q", target, cc)
+		}
+	}
+}
+
+func TestHashChangesWithContent(t *testing.T) {
+	fsys := testFS()
+	h1, _ := New(fsys)
+	fsys["app.js"] = &fstest.MapFile{Data: []byte(`console.log("v2")`)}
+	h2, _ := New(fsys)
+	if h1.files["app.js"].hash == h2.files["app.js"].hash {
+		t.Error("app.js hash unchanged")
+	}
+	if h1.files["index.html"].hash == h2.files["index.html"].hash {
+		t.Error("index.html ETag unchanged although its references changed")
+	}
+}
+
+func TestConditionalAndErrors(t *testing.T) {
+	h, _ := New(testFS())
+	etag := get(t, h, "/style.css").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if rec := get(t, h, "/style.css", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want 304", rec.Code)
+	}
+	if rec := get(t, h, "/missing.js"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing file status = %d", rec.Code)
+	}
+	if rec := get(t, h, "/../../etc/passwd"); rec.Code != http.StatusNotFound {
+		t.Errorf("traversal status = %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d", rec.Code)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	big := strings.Repeat("function f() { return 42; }\n", 200)
+	fsys := testFS()
+	fsys["app.js"] = &fstest.MapFile{Data: []byte(big)}
+	fsys["app.js.br"] = &fstest.MapFile{Data: []byte("brotli bytes")}
+	fsys["app.js.br.sha256"] = &fstest.MapFile{Data: []byte(sha256Hex(big) + "\n")}
+	fsys["index.html.br"] = &fstest.MapFile{Data: []byte("stale page")}
+	h, err := New(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := get(t, h, "/app.js", "Accept-Encoding", "gzip, deflate, br")
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli bytes" {
+		t.Errorf("br: encoding %q, body %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+	brTag := rec.Header().Get("ETag")
+
+	rec = get(t, h, "/app.js", "Accept-Encoding", "gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("gzip headers = %v", rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := io.ReadAll(zr); string(plain) != big {
+		t.Error("gzip body does not decompress to the file")
+	}
+	if gzTag := rec.Header().Get("ETag"); gzTag == brTag || gzTag == `"`+h.files["app.js"].hash+`"` {
+		t.Errorf("gzip ETag %s not distinct", gzTag)
+	}
+
+	rec = get(t, h, "/app.js")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != big {
+		t.Error("identity response was encoded")
+	}
+
+	// Sidecars aren't files of their own, and a page's is ignored because
+	// the page is rewritten at startup.
+	if rec := get(t, h, "/app.js.br"); rec.Code != http.StatusNotFound {
+		t.Errorf("sidecar served directly: %d", rec.Code)
+	}
+	if rec := get(t, h, "/", "Accept-Encoding", "br"); rec.Header().Get("Content-Encoding") == "br" {
+		t.Error("stale page sidecar served")
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// TestStaleSidecars verifies that sidecars not made from the embedded file
+// are left out: its new hash must never serve the old code.
+func TestStaleSidecars(t *testing.T) {
+	big := strings.Repeat("function f() { return 43; }\n", 200)
+	fsys := testFS()
+	fsys["app.js"] = &fstest.MapFile{Data: []byte(big)}
+	fsys["app.js.br"] = &fstest.MapFile{Data: []byte("brotli of the old app.js")}
+	fsys["app.js.br.sha256"] = &fstest.MapFile{Data: []byte(sha256Hex("old app.js"))}
+	fsys["style.css"] = &fstest.MapFile{Data: []byte(big)}
+	fsys["style.css.br"] = &fstest.MapFile{Data: []byte("brotli, no hash")}
+	fsys["style.css.gz"] = &fstest.MapFile{Data: gzipBytes([]byte("body{color:red}"))}
+	h, err := New(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/app.js", "/style.css"} {
+		rec := get(t, h, name, "Accept-Encoding", "gzip, br")
+		if rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("%s: encoding %q, want the startup gzip", name, rec.Header().Get("Content-Encoding"))
+			continue
+		}
+		zr, _ := gzip.NewReader(rec.Body)
+		if plain, _ := io.ReadAll(zr); string(plain) != big {
+			t.Errorf("%s: gzip body is not the embedded file", name)
+		}
+	}
+	if rec := get(t, h, "/app.js.br.sha256"); rec.Code != http.StatusNotFound {
+		t.Errorf("source hash served directly: %d", rec.Code)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		header, coding string
+		want           bool
+	}{
+		{"gzip, br", "br", true},
+		{"gzip;q=0.5", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"GZIP", "gzip", true},
+		{"*", "br", true},
+		{"*, br;q=0", "br", false},
+		{"identity", "gzip", false},
+		{"", "gzip", false},
+	}
+	for _, tt := range tests {
+		if got := accepts(tt.header, tt.coding); got != tt.want {
+			t.Errorf("accepts(%q, %q) = %v", tt.header, tt.coding, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// compressible lists the extensions worth compressing. Images and fonts
+// are compressed already.
+var compressible = map[string]bool{
+	".html": true, ".js": true, ".css": true, ".json": true,
+	".svg": true, ".txt": true, ".webmanifest": true,
+}
+
+// Sidecar suffixes for files compressed at build time, e.g. app.js.br from
+// `make compress`. They're served in place of the original, never on their
+// own.
+const (
+	suffixBrotli = ".br"
+	suffixGzip   = ".gz"
+	// suffixSource follows a brotli sidecar's name for the file holding
+	// the hex SHA-256 of the source it was made from: app.js.br.sha256.
+	suffixSource = ".sha256"
+)
+
+// freshBrotli returns the brotli sidecar br if sum, its recorded source
+// hash, is that of data, else nil. The standard library can't decode
+// brotli to compare, and a sidecar left over from an older `make compress`
+// would otherwise be served — under the new ?v= hash, cached for a year —
+// in place of the new file.
+func freshBrotli(data, br, sum []byte) []byte {
+	if br == nil {
+		return nil
+	}
+	want := sha256.Sum256(data)
+	if strings.TrimSpace(string(sum)) != hex.EncodeToString(want[:]) {
+		return nil
+	}
+	return br
+}
+
+// freshGzip returns the gzip sidecar gz if it decompresses to data, else
+// nil.
+func freshGzip(data, gz []byte) []byte {
+	if gz == nil {
+		return nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil || !bytes.Equal(plain, data) {
+		return nil
+	}
+	return gz
+}
+
+// gzipBytes compresses data at the best level — it is done once, at
+// startup.
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// variant returns the representation of f to send for an Accept-Encoding
+// header: the content coding ("" for identity) and the bytes. Brotli wins
+// over gzip when the client takes both.
+func (f *file) variant(acceptEncoding string) (string, []byte) {
+	if f.br != nil && accepts(acceptEncoding, "br") {
+		return "br", f.br
+	}
+	if f.gz != nil && accepts(acceptEncoding, "gzip") {
+		return "gzip", f.gz
+	}
+	return "", f.data
+}
+
+// accepts reports whether an Accept-Encoding header allows coding, by name
+// or through "*", with a non-zero q-value. An explicit entry overrides "*".
+func accepts(header, coding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		ok := qValue(params) > 0
+		if strings.EqualFold(name, coding) {
+			return ok
+		}
+		if name == "*" {
+			wildcard = ok
+		}
+	}
+	return wildcard
+}
+
+// qValue parses the q parameter of an Accept-Encoding entry (default 1).
+func qValue(params string) float64 {
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(k, "q") {
+			q, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0
+			}
+			return q
+		}
+	}
+	return 1
+}
@@ -0,0 +1,143 @@
+// Package audio prepares uploads for Whisper with ffmpeg: downmixed to
+// mono 16 kHz WAV, optionally loudness-normalized and with leading and
+// trailing silence cut. It also measures recordings for what is likely to
+// hurt accuracy (see Quality).
+//
+// Whisper resamples everything to 16 kHz mono before it starts, so a 48 kHz
+// stereo recording sends six times the samples over the wire for nothing,
+// and a compressed WebM costs the backend a decode on a busy GPU box that
+// the machine running Captain's Log could have done instead. Without
+// ffmpeg on $PATH there is no Converter and uploads go out as they came.
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// SampleRate is the rate Whisper models are trained on.
+const SampleRate = 16000
+
+// DefaultTimeout bounds one ffmpeg run. Decoding is far faster than real
+// time; an hour of audio takes seconds.
+const DefaultTimeout = 2 * time.Minute
+
+// Options says what to do to an upload. Any option on converts it to mono
+// 16 kHz 16-bit WAV; Normalize and TrimSilence add filters on top.
+type Options struct {
+	Downmix     bool `json:"downmix"`      // mono 16 kHz WAV
+	Normalize   bool `json:"normalize"`    // EBU R128 loudness (ffmpeg loudnorm), for quiet or uneven recordings
+	TrimSilence bool `json:"trim_silence"` // cut silence at the start and end; timestamps then start at the first sound
+}
+
+// Enabled reports whether any option is on.
+func (o Options) Enabled() bool { return o.Downmix || o.Normalize || o.TrimSilence }
+
+// Converter runs ffmpeg. A nil Converter converts nothing.
+type Converter struct {
+	bin     string
+	dir     string // temp files; "" = os.TempDir()
+	timeout time.Duration
+}
+
+// NewConverter returns a Converter using the ffmpeg on $PATH, keeping its
+// temp files in dir, or nil when ffmpeg isn't installed.
+func NewConverter(dir string) *Converter {
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil
+	}
+	return &Converter{bin: bin, dir: dir, timeout: DefaultTimeout}
+}
+
+// Available reports whether c can convert.
+func (c *Converter) Available() bool { return c != nil }
+
+// filters is the -af chain for o. Resampling comes first so the silence
+// filters, which buffer the reversed audio, hold 16 kHz mono rather than
+// the original; loudnorm comes last and resamples up internally, which the
+// output -ar undoes.
+func filters(o Options) string {
+	chain := []string{fmt.Sprintf("aresample=%d", SampleRate)}
+	if o.TrimSilence {
+		// silenceremove only trims the start; reversing twice trims the end.
+		const trim = "silenceremove=start_periods=1:start_threshold=-50dB:start_silence=0.2"
+		chain = append(chain, trim, "areverse", trim, "areverse")
+	}
+	if o.Normalize {
+		chain = append(chain, "loudnorm=I=-16:TP=-1.5:LRA=11")
+	}
+	return strings.Join(chain, ",")
+}
+
+// args is the ffmpeg command line converting in to out.
+func args(in, out string, o Options) []string {
+	return []string{"-nostdin", "-hide_banner", "-loglevel", "error", "-y",
+		"-i", in, "-vn", "-map_metadata", "-1",
+		"-af", filters(o), "-ac", "1", "-ar", fmt.Sprint(SampleRate), "-c:a", "pcm_s16le",
+		"-f", "wav", out}
+}
+
+// ConvertFile converts the file at in according to o and returns the path
+// of the WAV it wrote. The caller removes it.
+func (c *Converter) ConvertFile(ctx context.Context, in string, o Options) (string, error) {
+	out, err := os.CreateTemp(c.dir, "captainslog-audio-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("create temp wav: %w", err)
+	}
+	out.Close()
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.bin, args(in, out.Name(), o)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(out.Name())
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:] // the last line says what went wrong
+		}
+		return "", fmt.Errorf("ffmpeg: %w: %s", err, msg)
+	}
+	return out.Name(), nil
+}
+
+// Convert is ConvertFile for a stream: src goes to a temp file first, since
+// ffmpeg can't read MP4/M4A from a pipe (their index may be at the end).
+// The caller removes the returned WAV.
+func (c *Converter) Convert(ctx context.Context, src io.Reader, o Options) (string, error) {
+	in, err := os.CreateTemp(c.dir, "captainslog-upload-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp upload: %w", err)
+	}
+	defer os.Remove(in.Name())
+	if _, err := io.Copy(in, src); err != nil {
+		in.Close()
+		return "", fmt.Errorf("write temp upload: %w", err)
+	}
+	if err := in.Close(); err != nil {
+		return "", fmt.Errorf("write temp upload: %w", err)
+	}
+	return c.ConvertFile(ctx, in.Name(), o)
+}
+
+// IsSpeechWAV reports whether header (the first bytes of a file, 44 are
+// enough) is a PCM WAV that is already mono 16 kHz 16-bit, so downmixing
+// it alone would change nothing.
+func IsSpeechWAV(header []byte) bool {
+	if len(header) < 36 || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" || string(header[12:16]) != "fmt " {
+		return false
+	}
+	format := binary.LittleEndian.Uint16(header[20:22])
+	channels := binary.LittleEndian.Uint16(header[22:24])
+	rate := binary.LittleEndian.Uint32(header[24:28])
+	bits := binary.LittleEndian.Uint16(header[34:36])
+	return format == 1 && channels == 1 && rate == SampleRate && bits == 16
+}
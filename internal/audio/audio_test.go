@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFFmpeg puts an ffmpeg on $PATH that writes "converted:" and its
+// input to its output, and returns a Converter using it.
+func fakeFFmpeg(t *testing.T) *Converter {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"while [ $# -gt 1 ]; do [ \"$1\" = \"-i\" ] && in=\"$2\"; shift; done\n" +
+		"{ printf converted:; cat \"$in\"; } > \"$1\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	c := NewConverter(t.TempDir())
+	if c == nil {
+		t.Fatal("NewConverter = nil with ffmpeg on $PATH")
+	}
+	return c
+}
+
+func TestFilters(t *testing.T) {
+	if got := filters(Options{Downmix: true}); got != "aresample=16000" {
+		t.Errorf("downmix filters = %q", got)
+	}
+	got := filters(Options{Normalize: true, TrimSilence: true})
+	if !strings.HasPrefix(got, "aresample=16000,silenceremove=") || !strings.HasSuffix(got, ",areverse,loudnorm=I=-16:TP=-1.5:LRA=11") {
+		t.Errorf("filters = %q", got)
+	}
+	a := strings.Join(args("in.webm", "out.wav", Options{Downmix: true}), " ")
+	if !strings.Contains(a, "-i in.webm") || !strings.Contains(a, "-ac 1 -ar 16000 -c:a pcm_s16le -f wav out.wav") {
+		t.Errorf("args = %q", a)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c := fakeFFmpeg(t)
+	wav, err := c.Convert(context.Background(), strings.NewReader("webm-bytes"), Options{Downmix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(wav)
+	data, _ := os.ReadFile(wav)
+	if string(data) != "converted:webm-bytes" {
+		t.Errorf("output = %q", data)
+	}
+	// The temp upload is gone; only the WAV is left.
+	left, _ := os.ReadDir(c.dir)
+	if len(left) != 1 {
+		t.Errorf("temp dir holds %d files, want 1", len(left))
+	}
+}
+
+func TestConvertFailure(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte("#!/bin/sh\necho 'line one' >&2\necho 'Invalid data found' >&2\nexit 1\n"), 0755)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	c := NewConverter(t.TempDir())
+	_, err := c.ConvertFile(context.Background(), "missing.webm", Options{Downmix: true})
+	if err == nil || !strings.HasSuffix(err.Error(), ": Invalid data found") {
+		t.Errorf("err = %v, want the last stderr line", err)
+	}
+	if left, _ := os.ReadDir(c.dir); len(left) != 0 {
+		t.Errorf("failed run left %d files", len(left))
+	}
+}
+
+func TestNilConverter(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	c := NewConverter("")
+	if c != nil || c.Available() {
+		t.Error("no ffmpeg on $PATH, want a nil, unavailable Converter")
+	}
+}
+
+func TestIsSpeechWAV(t *testing.T) {
+	header := func(channels uint16, rate uint32) []byte {
+		h := make([]byte, 44)
+		copy(h, "RIFF")
+		copy(h[8:], "WAVEfmt ")
+		binary.LittleEndian.PutUint16(h[20:], 1)
+		binary.LittleEndian.PutUint16(h[22:], channels)
+		binary.LittleEndian.PutUint32(h[24:], rate)
+		binary.LittleEndian.PutUint16(h[34:], 16)
+		return h
+	}
+	if !IsSpeechWAV(header(1, 16000)) {
+		t.Error("mono 16 kHz not recognized")
+	}
+	if IsSpeechWAV(header(2, 48000)) || IsSpeechWAV(header(1, 44100)) {
+		t.Error("other formats taken for speech WAV")
+	}
+	if IsSpeechWAV([]byte("\x1aE\xdf\xa3 webm")) {
+		t.Error("webm taken for WAV")
+	}
+}
@@ -0,0 +1,210 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Warnings about a recording Whisper is likely to mishear.
+const (
+	// WarnLowSampleRate: recorded below 16 kHz, such as 8 kHz phone
+	// audio. The sounds that tell "s" from "f" are gone, and no
+	// resampling brings them back.
+	WarnLowSampleRate = "low_sample_rate"
+	// WarnClipping: the input gain was too high and the loudest parts
+	// are cut flat, which Whisper hears as noise.
+	WarnClipping = "clipping"
+	// WarnQuiet: even the loudest speech is faint. Normalizing the
+	// loudness (Options.Normalize) helps.
+	WarnQuiet = "quiet"
+)
+
+// Thresholds for the warnings.
+const (
+	clipLevel    = 32440 // |sample| at or above this counts as clipped: 99% of full scale
+	clipFraction = 0.001 // clipped samples above this share of all warn
+	quietLevel   = -35.0 // dBFS the loudest tenth of the recording stays under to warn
+	frameLength  = 0.05  // seconds of audio per level measurement
+)
+
+// analyzeSeconds bounds the audio decoded for Analyze: the first ten
+// minutes say as much about the microphone as the whole recording.
+const analyzeSeconds = 600
+
+// Quality is what Analyze measured in a recording.
+type Quality struct {
+	SampleRate int     `json:"sample_rate"` // of the recording as uploaded, in Hz; 0 if unknown
+	Peak       float64 `json:"peak_db"`     // loudest sample, dBFS
+	Level      float64 `json:"level_db"`    // RMS level the loudest tenth of 50 ms frames reach, dBFS
+	Clipped    float64 `json:"clipped"`     // share of samples at full scale, 0–1
+}
+
+// Warnings lists what in q is likely to hurt accuracy, or nil.
+func (q Quality) Warnings() []string {
+	var out []string
+	if q.SampleRate > 0 && q.SampleRate < SampleRate {
+		out = append(out, WarnLowSampleRate)
+	}
+	if q.Clipped > clipFraction {
+		out = append(out, WarnClipping)
+	}
+	if q.Level < quietLevel {
+		out = append(out, WarnQuiet)
+	}
+	return out
+}
+
+// Measure reads signed 16-bit little-endian mono samples at rate Hz from
+// r. The Quality it returns has SampleRate set to rate.
+func Measure(r io.Reader, rate int) (Quality, error) {
+	q := Quality{SampleRate: rate}
+	frame := max(1, int(float64(rate)*frameLength))
+	// hist counts frames by RMS level, in whole dB from -100 (or below)
+	// to 0, so the loudest tenth is found without keeping every frame.
+	var hist [101]int
+	var frames, n, clipped int64
+	var peak int
+	var sumSq float64
+	inFrame := 0
+	endFrame := func() {
+		db := dbfs(math.Sqrt(sumSq / float64(inFrame)))
+		hist[int(math.Round(max(db, -100)))+100]++
+		frames++
+		sumSq, inFrame = 0, 0
+	}
+
+	br := bufio.NewReader(r)
+	var buf [2]byte
+	for {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return q, err
+		}
+		s := int(int16(binary.LittleEndian.Uint16(buf[:])))
+		if s < 0 {
+			s = -s
+		}
+		n++
+		peak = max(peak, s)
+		if s >= clipLevel {
+			clipped++
+		}
+		sumSq += float64(s) * float64(s)
+		if inFrame++; inFrame == frame {
+			endFrame()
+		}
+	}
+	if inFrame > 0 {
+		endFrame()
+	}
+
+	q.Peak = dbfs(float64(peak))
+	q.Level = -100
+	if n > 0 {
+		q.Clipped = float64(clipped) / float64(n)
+	}
+	loudest := (frames + 9) / 10
+	for db := 100; db >= 0 && loudest > 0; db-- {
+		loudest -= int64(hist[db])
+		if loudest <= 0 {
+			q.Level = float64(db - 100)
+		}
+	}
+	return q, nil
+}
+
+// dbfs converts a 16-bit amplitude to dB below full scale, -100 for
+// silence.
+func dbfs(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return -100
+	}
+	return math.Round(20*math.Log10(amplitude/32768)*10) / 10
+}
+
+// Analyze decodes the file at path with ffmpeg and measures its quality.
+// Only the first ten minutes are looked at. Channels are mixed down, but
+// the audio isn't resampled: resampling would round off the flat tops
+// that give clipping away.
+func (c *Converter) Analyze(ctx context.Context, path string) (Quality, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.bin, "-nostdin", "-hide_banner", "-nostats",
+		"-i", path, "-t", strconv.Itoa(analyzeSeconds), "-vn", "-map_metadata", "-1",
+		"-ac", "1", "-c:a", "pcm_s16le", "-f", "s16le", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return Quality{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return Quality{}, fmt.Errorf("ffmpeg: %w", err)
+	}
+	// The input's rate is only read from stderr at the end, so frames
+	// are measured as if it were 16 kHz: at 8 kHz they are 100 ms long,
+	// which the level of the loudest tenth hardly notices.
+	q, measureErr := Measure(out, SampleRate)
+	io.Copy(io.Discard, out)
+	if err := cmd.Wait(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return Quality{}, fmt.Errorf("ffmpeg: %w: %s", err, msg)
+	}
+	if measureErr != nil {
+		return Quality{}, measureErr
+	}
+	q.SampleRate = inputRate(stderr.String())
+	return q, nil
+}
+
+// AnalyzeStream is Analyze for a stream, which goes to a temp file first
+// (see Convert).
+func (c *Converter) AnalyzeStream(ctx context.Context, src io.Reader) (Quality, error) {
+	in, err := os.CreateTemp(c.dir, "captainslog-upload-*")
+	if err != nil {
+		return Quality{}, fmt.Errorf("create temp upload: %w", err)
+	}
+	defer os.Remove(in.Name())
+	if _, err := io.Copy(in, src); err != nil {
+		in.Close()
+		return Quality{}, fmt.Errorf("write temp upload: %w", err)
+	}
+	if err := in.Close(); err != nil {
+		return Quality{}, fmt.Errorf("write temp upload: %w", err)
+	}
+	return c.Analyze(ctx, in.Name())
+}
+
+// streamRate matches the input audio stream in ffmpeg's description of
+// the file, such as "Stream #0:0: Audio: pcm_s16le ..., 8000 Hz, mono".
+var streamRate = regexp.MustCompile(`Stream #0:\d+.*?: Audio: .*?(\d+) Hz`)
+
+// inputRate is the sample rate of the first input audio stream ffmpeg
+// described in stderr, or 0.
+func inputRate(stderr string) int {
+	// The output section describes the output stream the same way.
+	if i := strings.Index(stderr, "\nOutput #0"); i >= 0 {
+		stderr = stderr[:i]
+	}
+	m := streamRate.FindStringSubmatch(stderr)
+	if m == nil {
+		return 0
+	}
+	rate, _ := strconv.Atoi(m[1])
+	return rate
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// samples encodes n samples alternating between +level and -level.
+func samples(n int, level int16) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		s := level
+		if i%2 == 1 {
+			s = -level
+		}
+		binary.Write(&b, binary.LittleEndian, s)
+	}
+	return b.Bytes()
+}
+
+func TestMeasure(t *testing.T) {
+	// A second of speech at -20 dBFS after nine of silence: the loudest
+	// tenth is the speech, however little of the recording it is.
+	data := append(samples(9*SampleRate, 0), samples(SampleRate, 3277)...)
+	q, err := Measure(bytes.NewReader(data), SampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.SampleRate != SampleRate || q.Level != -20 || q.Peak != -20 || q.Clipped != 0 {
+		t.Errorf("speech after silence = %+v", q)
+	}
+	if w := q.Warnings(); w != nil {
+		t.Errorf("warnings = %v", w)
+	}
+
+	// Clipped half the time.
+	data = append(samples(SampleRate, 32767), samples(SampleRate, 8000)...)
+	if q, _ = Measure(bytes.NewReader(data), SampleRate); q.Clipped != 0.5 || q.Peak != 0 {
+		t.Errorf("clipped = %+v", q)
+	}
+
+	// Nothing to measure is silence.
+	if q, _ = Measure(bytes.NewReader(nil), SampleRate); q.Level != -100 || q.Peak != -100 {
+		t.Errorf("empty = %+v", q)
+	}
+}
+
+func TestQualityWarnings(t *testing.T) {
+	for _, tt := range []struct {
+		q    Quality
+		want []string
+	}{
+		{Quality{SampleRate: 44100, Level: -18}, nil},
+		{Quality{SampleRate: 0, Level: -18}, nil}, // rate unknown
+		{Quality{SampleRate: 8000, Level: -18}, []string{WarnLowSampleRate}},
+		{Quality{SampleRate: 48000, Level: -3, Clipped: 0.01}, []string{WarnClipping}},
+		{Quality{SampleRate: 16000, Level: -48}, []string{WarnQuiet}},
+		{Quality{SampleRate: 8000, Level: -40, Clipped: 0.002}, []string{WarnLowSampleRate, WarnClipping, WarnQuiet}},
+	} {
+		if got := tt.q.Warnings(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: warnings = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestInputRate(t *testing.T) {
+	stderr := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'call.m4a':
+  Duration: 00:01:02.00, start: 0.000000, bitrate: 65 kb/s
+  Stream #0:0[0x1](und): Audio: aac (LC) (mp4a / 0x6134706D), 8000 Hz, mono, fltp, 64 kb/s (default)
+Stream mapping:
+  Stream #0:0 -> #0:0 (aac (native) -> pcm_s16le (native))
+Output #0, s16le, to 'pipe:1':
+  Stream #0:0: Audio: pcm_s16le, 8000 Hz, mono, s16, 128 kb/s
+`
+	if got := inputRate(stderr); got != 8000 {
+		t.Errorf("inputRate = %d, want 8000", got)
+	}
+	if got := inputRate("pipe:1: Invalid data found when processing input"); got != 0 {
+		t.Errorf("inputRate without a stream = %d", got)
+	}
+}
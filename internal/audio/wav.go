@@ -0,0 +1,179 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ToWAV saves src as a mono 16 kHz WAV and returns its path: as it is when
+// it already is one and o asks for no filters, through ffmpeg otherwise.
+// The caller removes it.
+func (c *Converter) ToWAV(ctx context.Context, src io.Reader, o Options) (string, error) {
+	head := bufio.NewReaderSize(src, 64)
+	if header, _ := head.Peek(44); o.Normalize || o.TrimSilence || !IsSpeechWAV(header) {
+		o.Downmix = true
+		return c.Convert(ctx, head, o)
+	}
+	f, err := os.CreateTemp(c.dir, "captainslog-audio-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("create temp wav: %w", err)
+	}
+	if _, err := io.Copy(f, head); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write temp wav: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write temp wav: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// WAV is an open mono 16 kHz 16-bit PCM WAV file, as ToWAV writes, that
+// can be read in time ranges without decoding.
+type WAV struct {
+	f          *os.File
+	dataOffset int64 // where the samples start
+	dataSize   int64 // bytes of samples
+}
+
+const bytesPerSecond = SampleRate * 2 // mono, 16-bit
+
+// OpenWAV opens the WAV at path. Anything but mono 16 kHz 16-bit PCM is
+// an error.
+func OpenWAV(path string) (*WAV, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := readWAV(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+func readWAV(f *os.File) (*WAV, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// Walk the chunks: ffmpeg may put a LIST chunk before the samples.
+	offset := int64(12)
+	sawFormat := false
+	for {
+		ch := make([]byte, 8)
+		if _, err := f.ReadAt(ch, offset); err != nil {
+			return nil, errors.New("no data chunk")
+		}
+		size := int64(binary.LittleEndian.Uint32(ch[4:8]))
+		body := offset + 8
+		switch string(ch[0:4]) {
+		case "fmt ":
+			fmtChunk := make([]byte, 16)
+			if _, err := f.ReadAt(fmtChunk, body); err != nil {
+				return nil, errors.New("short fmt chunk")
+			}
+			// IsSpeechWAV reads a canonical header; give it one.
+			canonical := append([]byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00"), fmtChunk...)
+			if !IsSpeechWAV(canonical) {
+				return nil, errors.New("not mono 16 kHz 16-bit PCM")
+			}
+			sawFormat = true
+		case "data":
+			if !sawFormat {
+				return nil, errors.New("data before fmt chunk")
+			}
+			// A size past the end (or 0xFFFFFFFF from a streamed write)
+			// means "to the end of the file".
+			if size == 0 || body+size > info.Size() {
+				size = info.Size() - body
+			}
+			return &WAV{f: f, dataOffset: body, dataSize: size &^ 1}, nil
+		}
+		offset = body + size + size&1 // chunks are word-aligned
+	}
+}
+
+// Duration is the length of the recording in seconds.
+func (w *WAV) Duration() float64 { return float64(w.dataSize) / bytesPerSecond }
+
+// Close closes the file.
+func (w *WAV) Close() error { return w.f.Close() }
+
+// Chunk is a time range of a recording, in seconds.
+type Chunk struct {
+	Start  float64
+	Length float64
+}
+
+// End is where the chunk ends.
+func (c Chunk) End() float64 { return c.Start + c.Length }
+
+// Plan splits duration seconds into chunks of length seconds, each
+// starting overlap seconds before the previous one ends, so no word is
+// lost at a cut. The last chunk runs to the end and may be shorter.
+func Plan(duration, length, overlap float64) []Chunk {
+	if length <= overlap || duration <= length {
+		return []Chunk{{Start: 0, Length: duration}}
+	}
+	var chunks []Chunk
+	for start := 0.0; ; start += length - overlap {
+		end := min(start+length, duration)
+		chunks = append(chunks, Chunk{Start: start, Length: end - start})
+		if end >= duration {
+			return chunks
+		}
+	}
+}
+
+// Reader returns c as a WAV file of its own.
+func (w *WAV) Reader(c Chunk) io.Reader {
+	from := min(int64(c.Start*bytesPerSecond)&^1, w.dataSize)
+	n := min(int64(c.Length*bytesPerSecond)&^1, w.dataSize-from)
+	return io.MultiReader(bytes.NewReader(wavHeader(n)), io.NewSectionReader(w.f, w.dataOffset+from, n))
+}
+
+// EncodeWAV returns samples (16 kHz mono, -1 to 1, as the browser's Web
+// Audio API gives them) as a 16-bit PCM WAV file. Samples out of range are
+// clipped.
+func EncodeWAV(samples []float32) []byte {
+	buf := make([]byte, 44, 44+2*len(samples))
+	copy(buf, wavHeader(int64(2*len(samples))))
+	for _, s := range samples {
+		v := int16(max(-1, min(1, s)) * 32767)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(v))
+	}
+	return buf
+}
+
+// wavHeader is the 44-byte header of a mono 16 kHz 16-bit PCM WAV holding
+// n bytes of samples.
+func wavHeader(n int64) []byte {
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(36+n))
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], 1) // mono
+	binary.LittleEndian.PutUint32(h[24:], SampleRate)
+	binary.LittleEndian.PutUint32(h[28:], bytesPerSecond)
+	binary.LittleEndian.PutUint16(h[32:], 2) // block align
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], uint32(n))
+	return h
+}
@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// speechWAV returns a mono 16 kHz WAV of seconds seconds in which every
+// sample holds the number of the second it is in.
+func speechWAV(seconds int) []byte {
+	var samples bytes.Buffer
+	for s := 0; s < seconds; s++ {
+		for i := 0; i < SampleRate; i++ {
+			binary.Write(&samples, binary.LittleEndian, int16(s))
+		}
+	}
+	return append(wavHeader(int64(samples.Len())), samples.Bytes()...)
+}
+
+func TestPlan(t *testing.T) {
+	got := Plan(25, 10, 2)
+	want := []Chunk{{0, 10}, {8, 10}, {16, 9}}
+	if len(got) != len(want) {
+		t.Fatalf("Plan = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chunk %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if got := Plan(5, 10, 2); len(got) != 1 || got[0].Length != 5 {
+		t.Errorf("short recording: Plan = %v, want one chunk", got)
+	}
+}
+
+func TestWAV(t *testing.T) {
+	data := speechWAV(5)
+	// ffmpeg may write a LIST chunk between fmt and data.
+	list := append([]byte("LIST\x05\x00\x00\x00INFO!\x00"), data[36:]...)
+	withList := append(append([]byte{}, data[:36]...), list...)
+
+	for name, file := range map[string][]byte{"plain": data, "list": withList} {
+		path := filepath.Join(t.TempDir(), "a.wav")
+		os.WriteFile(path, file, 0644)
+		w, err := OpenWAV(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if d := w.Duration(); d != 5 {
+			t.Errorf("%s: Duration = %v, want 5", name, d)
+		}
+		part, _ := io.ReadAll(w.Reader(Chunk{Start: 2, Length: 1.5}))
+		if !IsSpeechWAV(part) || len(part) != 44+SampleRate*3 {
+			t.Fatalf("%s: chunk is %d bytes, want a %d-byte WAV", name, len(part), 44+SampleRate*3)
+		}
+		if first, last := binary.LittleEndian.Uint16(part[44:]), binary.LittleEndian.Uint16(part[len(part)-2:]); first != 2 || last != 3 {
+			t.Errorf("%s: chunk holds seconds %d..%d, want 2..3", name, first, last)
+		}
+		w.Close()
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.wav")
+	os.WriteFile(bad, []byte("not audio at all, not at all"), 0644)
+	if _, err := OpenWAV(bad); err == nil {
+		t.Error("OpenWAV accepted a non-WAV file")
+	}
+}
+
+func TestToWAV(t *testing.T) {
+	c := fakeFFmpeg(t)
+	// Already speech WAV: saved as it is, no ffmpeg.
+	data := speechWAV(1)
+	path, err := c.ToWAV(context.Background(), bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	os.Remove(path)
+	if !bytes.Equal(got, data) {
+		t.Error("speech WAV was changed")
+	}
+	// Anything else goes through ffmpeg.
+	path, err = c.ToWAV(context.Background(), bytes.NewReader([]byte("webm")), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = os.ReadFile(path)
+	os.Remove(path)
+	if string(got) != "converted:webm" {
+		t.Errorf("webm: got %q", got)
+	}
+}
+
+func TestEncodeWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "live.wav")
+	if err := os.WriteFile(path, EncodeWAV(make([]float32, SampleRate/2)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := OpenWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Duration() != 0.5 {
+		t.Errorf("duration = %v, want 0.5", w.Duration())
+	}
+	data := EncodeWAV([]float32{1, -1, 2, 0.5})[44:]
+	want := []int16{32767, -32767, 32767, 16383}
+	for i, v := range want {
+		if got := int16(binary.LittleEndian.Uint16(data[2*i:])); got != v {
+			t.Errorf("sample %d = %d, want %d", i, got, v)
+		}
+	}
+}
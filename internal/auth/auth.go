@@ -0,0 +1,291 @@
+// Package auth keeps the named API keys that clients use in place of the
+// single CAPTAINSLOG_AUTH_TOKEN, each limited to the scopes it was
+// created with.
+//
+// Only a SHA-256 hash of each key is stored; the key itself is shown once,
+// when it is created. Keys are random 256-bit strings, so a fast hash is
+// enough — there is nothing to brute-force that a slow one would protect.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope is what a key may do.
+type Scope string
+
+const (
+	// ScopeTranscribe allows transcription and translation, jobs, URL
+	// transcription, language detection and the model list.
+	ScopeTranscribe Scope = "transcribe"
+	// ScopeSettings allows changing settings and folder watchers.
+	ScopeSettings Scope = "settings"
+	// ScopeAdmin allows everything, including managing keys.
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes are the valid scopes, in order of power.
+var Scopes = []Scope{ScopeTranscribe, ScopeSettings, ScopeAdmin}
+
+// TokenPrefix starts every key, so a leaked one is recognisable in logs
+// and by secret scanners.
+const TokenPrefix = "clk_"
+
+// lastUsedEvery is how stale the saved last-use time may get: recording
+// every request would write the file on every transcription.
+const lastUsedEvery = time.Hour
+
+// Errors returned by the Store.
+var (
+	ErrNotFound = errors.New("no such API key")
+	ErrInvalid  = errors.New("invalid API key request")
+)
+
+// Key is an API key as listed: everything but the secret.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // the first characters of the key, to tell keys apart
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // to the hour
+}
+
+// Allows reports whether k may act in scope s. Admin keys may do anything.
+func (k Key) Allows(s Scope) bool {
+	for _, have := range k.Scopes {
+		if have == s || have == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// storedKey is a Key as saved, with the hash of its secret.
+type storedKey struct {
+	Key
+	Hash string `json:"sha256"`
+}
+
+// Store holds the API keys, saved as JSON at its path. Safe for
+// concurrent use.
+type Store struct {
+	path string
+
+	mu   sync.Mutex
+	keys []storedKey
+}
+
+// Open loads the keys at path. A missing file is a store without keys.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read API keys: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return nil, fmt.Errorf("parse API keys %s: %w", filepath.Base(path), err)
+	}
+	return s, nil
+}
+
+// ParseScopes validates scope names. At least one is required.
+func ParseScopes(names []string) ([]Scope, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required (%s)", ErrInvalid, scopeList())
+	}
+	var scopes []Scope
+	seen := map[Scope]bool{}
+	for _, n := range names {
+		s := Scope(strings.ToLower(strings.TrimSpace(n)))
+		valid := false
+		for _, v := range Scopes {
+			valid = valid || s == v
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: unknown scope %q (%s)", ErrInvalid, n, scopeList())
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+func scopeList() string {
+	names := make([]string, len(Scopes))
+	for i, s := range Scopes {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Create makes a key called name with scopes and returns it with its
+// secret, which is not kept and can't be shown again.
+func (s *Store) Create(name string, scopes []Scope) (Key, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return Key{}, "", fmt.Errorf("%w: a name of 1 to 100 characters is required", ErrInvalid)
+	}
+	if len(scopes) == 0 {
+		return Key{}, "", fmt.Errorf("%w: at least one scope is required (%s)", ErrInvalid, scopeList())
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, "", err
+	}
+	token := TokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, "", err
+	}
+	k := storedKey{
+		Key: Key{
+			ID:        hex.EncodeToString(id),
+			Name:      name,
+			Prefix:    token[:len(TokenPrefix)+6],
+			Scopes:    scopes,
+			CreatedAt: time.Now().UTC().Truncate(time.Second),
+		},
+		Hash: hash(token),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, k)
+	if err := s.saveLocked(); err != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		return Key{}, "", err
+	}
+	return k.Key, token, nil
+}
+
+// Revoke deletes the key with id. Requests with it fail from then on.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.keys {
+		if k.ID == id {
+			old := s.keys
+			s.keys = append(append([]storedKey(nil), s.keys[:i]...), s.keys[i+1:]...)
+			if err := s.saveLocked(); err != nil {
+				s.keys = old
+				return err
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// List returns the keys, oldest first.
+func (s *Store) List() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]Key, len(s.keys))
+	for i, k := range s.keys {
+		keys[i] = k.Key
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// Len is the number of keys.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+// Authenticate returns the key whose secret is token. Every stored hash is
+// compared, in constant time, so timing says nothing about which one
+// matched.
+func (s *Store) Authenticate(token string) (Key, bool) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return Key{}, false
+	}
+	h := []byte(hash(token))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := -1
+	for i, k := range s.keys {
+		if subtle.ConstantTimeCompare(h, []byte(k.Hash)) == 1 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return Key{}, false
+	}
+	k := &s.keys[found]
+	now := time.Now().UTC()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= lastUsedEvery {
+		t := now.Truncate(time.Hour)
+		k.LastUsedAt = &t
+		// Non-fatal: the key works whether or not its last use is saved.
+		s.saveLocked()
+	}
+	return k.Key, true
+}
+
+type callerKey struct{}
+
+// WithCaller returns ctx carrying the name of who made the request: an API
+// key's name, "token" for the auth token, or "cert:" and the common name of
+// a client certificate.
+func WithCaller(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, callerKey{}, name)
+}
+
+// Caller returns the name set by WithCaller, or "" (auth is off).
+func Caller(ctx context.Context) string {
+	name, _ := ctx.Value(callerKey{}).(string)
+	return name
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// saveLocked writes the keys via temp file + rename, readable only by the
+// owner. Caller holds s.mu.
+func (s *Store) saveLocked() error {
+	keys := s.keys
+	if keys == nil {
+		keys = []storedKey{}
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("create API key dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write API keys: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace API keys: %w", err)
+	}
+	return nil
+}
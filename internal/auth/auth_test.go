@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateAuthenticateRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, token, err := s.Create("backup script", []Scope{ScopeTranscribe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, TokenPrefix) || !strings.HasPrefix(token, k.Prefix) {
+		t.Errorf("token %q, prefix %q", token, k.Prefix)
+	}
+
+	got, ok := s.Authenticate(token)
+	if !ok || got.ID != k.ID {
+		t.Fatalf("Authenticate = %+v, %v", got, ok)
+	}
+	if got.LastUsedAt == nil {
+		t.Error("last use not recorded")
+	}
+	if _, ok := s.Authenticate(token + "x"); ok {
+		t.Error("a wrong token authenticated")
+	}
+	if _, ok := s.Authenticate(""); ok {
+		t.Error("an empty token authenticated")
+	}
+
+	// The secret is never saved, only its hash.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Error("the key file holds the secret")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// Keys survive a restart.
+	s2, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s2.Authenticate(token); !ok {
+		t.Fatal("key lost on reopen")
+	}
+	if err := s2.Revoke(k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s2.Authenticate(token); ok {
+		t.Error("revoked key still works")
+	}
+	if err := s2.Revoke(k.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Revoke = %v, want ErrNotFound", err)
+	}
+	s3, _ := Open(path)
+	if s3.Len() != 0 {
+		t.Errorf("revocation not saved: %d keys", s3.Len())
+	}
+}
+
+func TestAllows(t *testing.T) {
+	transcribe := Key{Scopes: []Scope{ScopeTranscribe}}
+	if !transcribe.Allows(ScopeTranscribe) || transcribe.Allows(ScopeSettings) || transcribe.Allows(ScopeAdmin) {
+		t.Error("a transcribe key may only transcribe")
+	}
+	admin := Key{Scopes: []Scope{ScopeAdmin}}
+	for _, s := range Scopes {
+		if !admin.Allows(s) {
+			t.Errorf("admin key may not %s", s)
+		}
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	got, err := ParseScopes([]string{"Transcribe", "settings", "transcribe"})
+	if err != nil || len(got) != 2 || got[0] != ScopeTranscribe || got[1] != ScopeSettings {
+		t.Errorf("ParseScopes = %v, %v", got, err)
+	}
+	for _, bad := range [][]string{nil, {"root"}} {
+		if _, err := ParseScopes(bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ParseScopes(%v) = %v, want ErrInvalid", bad, err)
+		}
+	}
+}
+
+func TestCreate_Invalid(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "api-keys.json"))
+	if _, _, err := s.Create(" ", []Scope{ScopeAdmin}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("empty name: %v", err)
+	}
+	if _, _, err := s.Create("x", nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("no scopes: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Package backendauth attaches credentials to outbound calls to the Whisper
+// and LLM backends.
+//
+// A backend behind a reverse proxy with forward auth (Authelia, Authentik,
+// oauth2-proxy) or a hosted OpenAI-compatible API rejects anonymous
+// requests. Each backend gets one credential and, optionally, the header it
+// goes in:
+//
+//	CAPTAINSLOG_WHISPER_API_KEY=sk-...              → Authorization: Bearer sk-...
+//	CAPTAINSLOG_WHISPER_API_KEY="Basic dXNlcjpwdw=="  → Authorization: Basic dXNlcjpwdw==
+//	CAPTAINSLOG_WHISPER_AUTH_HEADER=X-API-Key       → X-API-Key: sk-...
+package backendauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultHeader is used when no header name is configured.
+const DefaultHeader = "Authorization"
+
+// Value returns what goes in the header for credential. For Authorization
+// a bare key becomes "Bearer <key>"; a credential that already names its
+// scheme ("Basic …", "Bearer …") is sent as-is. Other headers get the
+// credential unchanged.
+func Value(header, credential string) string {
+	if !strings.EqualFold(header, DefaultHeader) || strings.ContainsRune(credential, ' ') {
+		return credential
+	}
+	return "Bearer " + credential
+}
+
+// Transport adds the credential to every request that doesn't already carry
+// the header. credential is called per request so a rotated key takes
+// effect immediately; an empty credential sends nothing, which is what
+// local servers (Ollama, llama.cpp, a bare Speaches) expect.
+func Transport(base http.RoundTripper, header string, credential func() string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if header == "" {
+		header = DefaultHeader
+	}
+	return transport{base: base, header: http.CanonicalHeaderKey(header), credential: credential}
+}
+
+type transport struct {
+	base       http.RoundTripper
+	header     string
+	credential func() string
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.credential()
+	if c == "" || req.Header.Get(t.header) != "" {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the caller's request.
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, Value(t.header, c))
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,66 @@
+package backendauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	tests := []struct{ header, credential, want string }{
+		{"Authorization", "sk-1", "Bearer sk-1"},
+		{"authorization", "sk-1", "Bearer sk-1"},
+		{"Authorization", "Basic dXNlcjpwdw==", "Basic dXNlcjpwdw=="},
+		{"Authorization", "Bearer sk-1", "Bearer sk-1"},
+		{"X-API-Key", "sk-1", "sk-1"},
+	}
+	for _, tt := range tests {
+		if got := Value(tt.header, tt.credential); got != tt.want {
+			t.Errorf("Value(%q, %q) = %q, want %q", tt.header, tt.credential, got, tt.want)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer backend.Close()
+
+	key := "k1"
+	client := &http.Client{Transport: Transport(nil, "", func() string { return key })}
+	client.Get(backend.URL)
+	key = "k2"
+	client.Get(backend.URL)
+	key = ""
+	client.Get(backend.URL)
+	req, _ := http.NewRequest("GET", backend.URL, nil)
+	req.Header.Set("Authorization", "Bearer caller")
+	key = "k3"
+	client.Do(req)
+
+	want := []string{"Bearer k1", "Bearer k2", "", "Bearer caller"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d Authorization = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTransportCustomHeader(t *testing.T) {
+	var auth, apiKey string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, apiKey = r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+	}))
+	defer backend.Close()
+
+	client := &http.Client{Transport: Transport(nil, "x-api-key", func() string { return "sk-1" })}
+	client.Get(backend.URL)
+	if auth != "" || apiKey != "sk-1" {
+		t.Errorf("Authorization = %q, X-Api-Key = %q", auth, apiKey)
+	}
+}
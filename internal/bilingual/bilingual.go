@@ -0,0 +1,194 @@
+// Package bilingual keeps a transcript and its translation side by side,
+// sentence by sentence, for practising a spoken language: the learner
+// dictates in the language they are learning, reads what they said next
+// to what it means, and drills the sentences as flashcards.
+//
+// The translation is the LLM's. Sentences are sent in numbered batches so
+// that each translation lines up with the sentence it came from, which a
+// translation of the whole text would not.
+package bilingual
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+)
+
+// Layouts of the side-by-side markdown.
+const (
+	LayoutColumns     = "columns"     // a two-column table
+	LayoutInterleaved = "interleaved" // each sentence, then its translation
+)
+
+// ValidLayout reports whether l names a layout.
+func ValidLayout(l string) bool { return l == LayoutColumns || l == LayoutInterleaved }
+
+// batchSize is the sentences translated per LLM call: enough context for
+// a good translation, few enough that a small local model keeps count.
+const batchSize = 20
+
+// Pair is one sentence and its translation.
+type Pair struct {
+	Original    string `json:"original"`
+	Translation string `json:"translation"`
+}
+
+const translatePrompt = `Translate each numbered sentence from %s into %s.
+Reply with the translations only, one per line, numbered as in the input ("1. ...").
+Translate every sentence on its own: do not merge, split, skip or explain any.`
+
+// Translate translates text into target (a language name or code, as the
+// LLM will understand it) a sentence at a time. from is the language of
+// text, "" or "und" when unknown.
+func Translate(ctx context.Context, client *llm.Client, text, from, target string) ([]Pair, error) {
+	if from == "" || from == "und" {
+		from = "the language they are in"
+	}
+	sentences := Sentences(text)
+	pairs := make([]Pair, 0, len(sentences))
+	for start := 0; start < len(sentences); start += batchSize {
+		batch := sentences[start:min(start+batchSize, len(sentences))]
+		translated, err := translateBatch(ctx, client, batch, from, target)
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range batch {
+			pairs = append(pairs, Pair{Original: s, Translation: translated[i]})
+		}
+	}
+	return pairs, nil
+}
+
+// translateBatch translates sentences in one call. Sentences the reply
+// leaves out are asked for again one by one.
+func translateBatch(ctx context.Context, client *llm.Client, sentences []string, from, target string) ([]string, error) {
+	var b strings.Builder
+	for i, s := range sentences {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, s)
+	}
+	reply, err := client.Chat(ctx,
+		llm.Message{Role: "system", Content: fmt.Sprintf(translatePrompt, from, target)},
+		llm.Message{Role: "user", Content: b.String()})
+	if err != nil {
+		return nil, err
+	}
+	numbered := parseNumbered(reply)
+	out := make([]string, len(sentences))
+	for i, s := range sentences {
+		if t := numbered[i+1]; t != "" {
+			out[i] = t
+			continue
+		}
+		if len(sentences) == 1 {
+			// The model answered without a number.
+			if t := strings.TrimSpace(reply); t != "" {
+				out[i] = strings.Join(strings.Fields(t), " ")
+				continue
+			}
+			return nil, fmt.Errorf("llm reply had no translation of %q", s)
+		}
+		single, err := translateBatch(ctx, client, []string{s}, from, target)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = single[0]
+	}
+	return out, nil
+}
+
+// parseNumbered reads the "1. text" lines of a reply ("1)" and "1:" too),
+// by number.
+func parseNumbered(reply string) map[int]string {
+	out := map[int]string{}
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "-*• ")
+		digits := strings.IndexFunc(line, func(r rune) bool { return !unicode.IsDigit(r) })
+		if digits <= 0 || !strings.ContainsRune(".):", rune(line[digits])) {
+			continue
+		}
+		n, err := strconv.Atoi(line[:digits])
+		if err != nil {
+			continue
+		}
+		if text := strings.TrimSpace(line[digits+1:]); text != "" && out[n] == "" {
+			out[n] = text
+		}
+	}
+	return out
+}
+
+// Sentences splits text into sentences: at a full stop, question or
+// exclamation mark (and their CJK forms) followed by white space, and at
+// line breaks. White space inside a sentence is collapsed.
+func Sentences(text string) []string {
+	var out []string
+	runes := []rune(text)
+	start := 0
+	flush := func(end int) {
+		if s := strings.Join(strings.Fields(string(runes[start:end])), " "); s != "" {
+			out = append(out, s)
+		}
+		start = end
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' {
+			flush(i)
+			continue
+		}
+		if strings.ContainsRune("。！？", r) {
+			flush(i + 1)
+			continue
+		}
+		if !strings.ContainsRune(".!?…", r) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && strings.ContainsRune(".!?…\"')]”’»", runes[end]) {
+			end++
+		}
+		if end == len(runes) || unicode.IsSpace(runes[end]) {
+			flush(end)
+			i = end - 1
+		}
+	}
+	flush(len(runes))
+	return out
+}
+
+// Markdown renders pairs in layout under a "Translation" heading, for the
+// note below the transcript. from and target label the columns; from may
+// be "" or "und".
+func Markdown(pairs []Pair, layout, from, target string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	if from == "" || from == "und" {
+		from = "Original"
+	}
+	var b strings.Builder
+	b.WriteString("## Translation (" + target + ")\n\n")
+	if layout == LayoutInterleaved {
+		for i, p := range pairs {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(p.Original + "\n> " + p.Translation + "\n")
+		}
+		return b.String()
+	}
+	b.WriteString("| " + cell(from) + " | " + cell(target) + " |\n|---|---|\n")
+	for _, p := range pairs {
+		b.WriteString("| " + cell(p.Original) + " | " + cell(p.Translation) + " |\n")
+	}
+	return b.String()
+}
+
+// cell escapes s for a markdown table cell.
+func cell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
@@ -0,0 +1,72 @@
+package bilingual
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+)
+
+func TestSentences(t *testing.T) {
+	got := Sentences("Hola, ¿qué tal?  Cuesta 3.50 euros. «Vale.»\nBueno\n\n今日は。元気？ Sí…")
+	want := []string{"Hola, ¿qué tal?", "Cuesta 3.50 euros.", "«Vale.»", "Bueno", "今日は。", "元気？", "Sí…"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sentences = %q, want %q", got, want)
+	}
+}
+
+// fakeLLM answers each call with the next reply and records the prompts.
+func fakeLLM(t *testing.T, prompts *[]string, replies ...string) *llm.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []llm.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		*prompts = append(*prompts, req.Messages[len(req.Messages)-1].Content)
+		reply := replies[0]
+		replies = replies[1:]
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return llm.New(srv.URL, "test")
+}
+
+func TestTranslate(t *testing.T) {
+	var prompts []string
+	// The model skips the second sentence; it is asked again on its own.
+	client := fakeLLM(t, &prompts, "1. Good morning.\n3) See you tomorrow.", "How are you?")
+	pairs, err := Translate(context.Background(), client, "Buenos días. ¿Cómo estás? Hasta mañana.", "es", "English")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Pair{{"Buenos días.", "Good morning."}, {"¿Cómo estás?", "How are you?"}, {"Hasta mañana.", "See you tomorrow."}}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("pairs = %+v", pairs)
+	}
+	if len(prompts) != 2 || prompts[0] != "1. Buenos días.\n2. ¿Cómo estás?\n3. Hasta mañana.\n" || prompts[1] != "1. ¿Cómo estás?\n" {
+		t.Errorf("prompts = %q", prompts)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	pairs := []Pair{{"Hola.", "Hello."}, {"¿A | B?", "A or B?"}}
+	columns := Markdown(pairs, LayoutColumns, "es", "English")
+	if !strings.Contains(columns, "| es | English |\n|---|---|\n| Hola. | Hello. |\n| ¿A \\| B? | A or B? |\n") {
+		t.Errorf("columns:\n%s", columns)
+	}
+	interleaved := Markdown(pairs, LayoutInterleaved, "und", "English")
+	if !strings.HasPrefix(interleaved, "## Translation (English)\n\nHola.\n> Hello.\n\n¿A | B?\n> A or B?\n") {
+		t.Errorf("interleaved:\n%s", interleaved)
+	}
+	if Markdown(nil, LayoutColumns, "es", "English") != "" {
+		t.Error("no pairs should render nothing")
+	}
+}
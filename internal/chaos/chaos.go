@@ -0,0 +1,163 @@
+// Package chaos injects faults into outbound backend calls for resilience testing.
+//
+// It wraps an http.RoundTripper and, according to the configured rates, adds
+// latency, answers with synthetic 5xx responses, or fails the call as if the
+// connection had been dropped. This lets developers verify that retries,
+// circuit breakers, and the UI's error handling behave as intended without
+// actually breaking a GPU box.
+//
+// Developer mode only — every injected fault is logged at Warn level so it
+// can never be mistaken for a real backend problem.
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDropped is returned for calls failed by the drop injector.
+var ErrDropped = errors.New("chaos: connection dropped")
+
+// errorStatuses are the status codes used for synthetic failures —
+// the ones real Whisper servers return when overloaded or restarting.
+var errorStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Config controls which faults are injected. The zero value injects nothing.
+type Config struct {
+	Latency   time.Duration // fixed delay added to every call
+	Jitter    time.Duration // random extra delay in [0, Jitter)
+	ErrorRate float64       // fraction of calls answered with a synthetic 5xx
+	DropRate  float64       // fraction of calls failed with ErrDropped
+}
+
+// Enabled reports whether any fault is configured.
+func (c Config) Enabled() bool {
+	return c.Latency > 0 || c.Jitter > 0 || c.ErrorRate > 0 || c.DropRate > 0
+}
+
+// Stats counts injected faults since startup.
+type Stats struct {
+	Calls   int64 `json:"calls"`
+	Delayed int64 `json:"delayed"`
+	Errors  int64 `json:"errors"`
+	Dropped int64 `json:"dropped"`
+}
+
+// Transport is a fault-injecting http.RoundTripper.
+type Transport struct {
+	next   http.RoundTripper
+	cfg    Config
+	logger *slog.Logger
+
+	mu  sync.Mutex // guards rnd — math/rand.Rand is not goroutine-safe
+	rnd *rand.Rand
+
+	calls, delayed, errors, dropped atomic.Int64
+}
+
+// Wrap returns a Transport injecting faults in front of next.
+// If next is nil, http.DefaultTransport is used.
+func Wrap(next http.RoundTripper, cfg Config, logger *slog.Logger) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		next:   next,
+		cfg:    cfg,
+		logger: logger,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Stats returns a snapshot of injected fault counts.
+func (t *Transport) Stats() Stats {
+	return Stats{
+		Calls:   t.calls.Load(),
+		Delayed: t.delayed.Load(),
+		Errors:  t.errors.Load(),
+		Dropped: t.dropped.Load(),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+
+	t.mu.Lock()
+	delay := t.cfg.Latency
+	if t.cfg.Jitter > 0 {
+		delay += time.Duration(t.rnd.Int63n(int64(t.cfg.Jitter)))
+	}
+	roll := t.rnd.Float64()
+	status := errorStatuses[t.rnd.Intn(len(errorStatuses))]
+	t.mu.Unlock()
+
+	if delay > 0 {
+		t.delayed.Add(1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	// A single roll decides the outcome so the configured rates are
+	// independent fractions of all calls: [0, drop) drops, [drop, drop+error) errors.
+	switch {
+	case roll < t.cfg.DropRate:
+		t.dropped.Add(1)
+		t.logger.Warn("chaos: dropping backend connection", "url", req.URL.String())
+		closeBody(req)
+		return nil, ErrDropped
+	case roll < t.cfg.DropRate+t.cfg.ErrorRate:
+		t.errors.Add(1)
+		t.logger.Warn("chaos: injecting backend error", "url", req.URL.String(), "status", status)
+		closeBody(req)
+		return syntheticResponse(req, status), nil
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// closeBody honours the RoundTripper contract: the request body must be
+// closed even when the request is never sent.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func syntheticResponse(req *http.Request, status int) *http.Response {
+	body, _ := json.Marshal(map[string]any{
+		"error":  fmt.Sprintf("chaos: injected %d %s", status, http.StatusText(status)),
+		"status": status,
+	})
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}, "X-Chaos-Injected": []string{"true"}},
+		Body:          io.NopCloser(strings.NewReader(string(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
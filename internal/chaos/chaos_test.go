@@ -0,0 +1,96 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEnabled(t *testing.T) {
+	if (Config{}).Enabled() {
+		t.Error("zero Config should be disabled")
+	}
+	if !(Config{ErrorRate: 0.1}).Enabled() {
+		t.Error("Config with an error rate should be enabled")
+	}
+}
+
+func TestErrorRateOne(t *testing.T) {
+	backend := newBackend(t)
+	client := &http.Client{Transport: Wrap(nil, Config{ErrorRate: 1}, testLogger())}
+
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 500 {
+		t.Errorf("status = %d, want synthetic 5xx", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Chaos-Injected") != "true" {
+		t.Error("synthetic response should be marked with X-Chaos-Injected")
+	}
+}
+
+func TestDropRateOne(t *testing.T) {
+	backend := newBackend(t)
+	tr := Wrap(nil, Config{DropRate: 1}, testLogger())
+	client := &http.Client{Transport: tr}
+
+	_, err := client.Get(backend.URL)
+	if !errors.Is(err, ErrDropped) {
+		t.Errorf("err = %v, want ErrDropped", err)
+	}
+	if s := tr.Stats(); s.Dropped != 1 || s.Calls != 1 {
+		t.Errorf("stats = %+v, want 1 call, 1 drop", s)
+	}
+}
+
+func TestLatencyPassesThrough(t *testing.T) {
+	backend := newBackend(t)
+	client := &http.Client{Transport: Wrap(nil, Config{Latency: 30 * time.Millisecond}, testLogger())}
+
+	start := time.Now()
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want real backend response", body)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("elapsed = %v, want >= 30ms injected latency", elapsed)
+	}
+}
+
+func TestLatencyRespectsContext(t *testing.T) {
+	backend := newBackend(t)
+	client := &http.Client{Transport: Wrap(nil, Config{Latency: time.Minute}, testLogger())}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Error("request should fail when the context expires during injected latency")
+	}
+}
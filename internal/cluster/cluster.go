@@ -0,0 +1,153 @@
+// Package cluster lets several Captain's Log instances share one data
+// directory — the job queue and the transcript index — so a busy office
+// can run more than one proxy in front of the same Whisper GPUs.
+//
+// WHY files, not a database? Everything else here is JSON on disk, and a
+// shared filesystem (NFS, SMB, CephFS) is something most offices already
+// have. The only coordination needed is mutual exclusion, and creating a
+// file with O_EXCL is atomic on all of them. A lock whose holder died is
+// recognised by its age and taken over by renaming it, which is atomic
+// too.
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// ErrLockTimeout is returned by Lock when the lock stayed held for the
+// whole wait.
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// InstanceID names this instance in job records and the index: id if set,
+// else the host name. Replicas must have distinct IDs; in containers the
+// host name is the container's, which is.
+func InstanceID(id string) string {
+	if id = strings.TrimSpace(id); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "captainslog"
+}
+
+// Lock takes the lock file at path, waiting up to wait for another holder
+// to release it. A lock file older than stale is assumed left behind by an
+// instance that died mid-write and is taken over. Call unlock when done.
+func Lock(path string, wait, stale time.Duration) (unlock func(), err error) {
+	deadline := time.Now().Add(wait)
+	for {
+		release, err := TryClaim(path, "", stale)
+		if err != nil {
+			return nil, err
+		}
+		if release != nil {
+			return release, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: %w", path, ErrLockTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TryClaim creates the claim file at path holding owner, without waiting.
+// It returns nil if someone else holds it, else the func that releases
+// it. A claim not touched (see Touch) for longer than stale is taken over.
+//
+// The file also holds a random token, so release removes the claim only
+// while it is still this one: a holder that ran past stale must not
+// delete the claim of whoever took over.
+func TryClaim(path, owner string, stale time.Duration) (release func(), err error) {
+	token := httputil.NewID("", 8)
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.WriteString(token + "\n" + owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("claim %s: %w", path, err)
+			}
+			return func() { releaseClaim(path, token) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("claim %s: %w", path, err)
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // released in between; try again
+		}
+		if err != nil || time.Since(info.ModTime()) < stale || !takeOver(path, stale) {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// takeOver moves the stale claim at path out of the way, reporting
+// whether it did.
+//
+// WHY rename rather than remove? Two instances taking over the same stale
+// claim must not both succeed. Of their renames only one finds the file;
+// a remove would also delete the claim the faster one has created since.
+// The file is checked again once moved: if it turns out fresh, it was
+// that new claim, and it goes back.
+func takeOver(path string, stale time.Duration) bool {
+	aside := path + ".stale-" + httputil.NewID("", 8)
+	if err := os.Rename(path, aside); err != nil {
+		return false
+	}
+	if info, err := os.Stat(aside); err == nil && time.Since(info.ModTime()) < stale {
+		// A link, unlike a rename, never replaces a claim made meanwhile.
+		if err := os.Link(aside, path); err != nil && !errors.Is(err, os.ErrExist) {
+			os.Rename(aside, path)
+		}
+		os.Remove(aside)
+		return false
+	}
+	os.Remove(aside)
+	return true
+}
+
+// releaseClaim removes the claim at path if it still holds token.
+func releaseClaim(path, token string) {
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), token+"\n") {
+		return
+	}
+	os.Remove(path)
+}
+
+// Touch marks the claim at path as alive.
+func Touch(path string) error {
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// Owner returns who holds the claim at path, or "" if nobody does or it
+// is older than stale.
+func Owner(path string, stale time.Duration) string {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) >= stale {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	// The token comes first; a claim from before tokens holds the owner alone.
+	_, owner, found := strings.Cut(string(data), "\n")
+	if !found {
+		return string(data)
+	}
+	return owner
+}
@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTryClaim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.claim")
+	releaseA, err := TryClaim(path, "a", time.Minute)
+	if releaseA == nil || err != nil {
+		t.Fatalf("first claim = %v", err)
+	}
+	if release, _ := TryClaim(path, "b", time.Minute); release != nil {
+		t.Error("second instance claimed a held claim")
+	}
+	if got := Owner(path, time.Minute); got != "a" {
+		t.Errorf("Owner = %q, want a", got)
+	}
+
+	// Not touched for longer than stale: taken over.
+	old := time.Now().Add(-2 * time.Minute)
+	os.Chtimes(path, old, old)
+	if got := Owner(path, time.Minute); got != "" {
+		t.Errorf("Owner of a stale claim = %q", got)
+	}
+	if release, _ := TryClaim(path, "b", time.Minute); release == nil {
+		t.Error("stale claim not taken over")
+	}
+	if got := Owner(path, time.Minute); got != "b" {
+		t.Errorf("Owner = %q, want b", got)
+	}
+
+	// a, back from its stall, releases what it thinks is its claim.
+	releaseA()
+	if got := Owner(path, time.Minute); got != "b" {
+		t.Errorf("after the old holder's release, Owner = %q, want b", got)
+	}
+}
+
+// Instances taking over one stale claim at the same moment: only one may
+// get it.
+func TestTryClaimTakeOverRace(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Minute)
+	for round := 0; round < 50; round++ {
+		path := filepath.Join(dir, fmt.Sprintf("job%d.claim", round))
+		os.WriteFile(path, []byte("token\ndead"), 0600)
+		os.Chtimes(path, old, old)
+
+		var mu sync.Mutex
+		var winners []string
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for _, owner := range []string{"a", "b", "c"} {
+			wg.Add(1)
+			go func(owner string) {
+				defer wg.Done()
+				<-start
+				if release, err := TryClaim(path, owner, time.Minute); release != nil {
+					mu.Lock()
+					winners = append(winners, owner)
+					mu.Unlock()
+				} else if err != nil {
+					t.Error(err)
+				}
+			}(owner)
+		}
+		close(start)
+		wg.Wait()
+		if len(winners) != 1 {
+			t.Fatalf("round %d: %v took over the claim, want exactly one", round, winners)
+		}
+		if got := Owner(path, time.Minute); got != winners[0] {
+			t.Fatalf("round %d: Owner = %q, want %q", round, got, winners[0])
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.lock")
+	var mu sync.Mutex
+	inside, overlap := 0, false
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := Lock(path, 5*time.Second, time.Minute)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			inside++
+			overlap = overlap || inside > 1
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			inside--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if overlap {
+		t.Error("two holders inside the lock at once")
+	}
+
+	unlock, _ := Lock(path, time.Second, time.Minute)
+	defer unlock()
+	if _, err := Lock(path, 30*time.Millisecond, time.Minute); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("held lock = %v, want ErrLockTimeout", err)
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	if got := InstanceID(" web-2 "); got != "web-2" {
+		t.Errorf("InstanceID = %q", got)
+	}
+	if got := InstanceID(""); got == "" {
+		t.Error("no default instance ID")
+	}
+}
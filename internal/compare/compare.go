@@ -0,0 +1,91 @@
+// Package compare puts two transcriptions of the same recording side by
+// side, for choosing between models: what each heard, how long each took
+// and which words they disagree on. A bigger model is only worth its time
+// if it hears the speaker's words better.
+package compare
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/refine"
+)
+
+// Run is one transcription of the recording.
+type Run struct {
+	Model     string `json:"model"`
+	Backend   string `json:"backend,omitempty"` // "" = the configured backends
+	Text      string `json:"text"`
+	Words     int    `json:"words"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+	// Speed is seconds of audio transcribed per second taken; 0 when the
+	// backend did not report the audio's duration.
+	Speed float64 `json:"speed,omitempty"`
+}
+
+// NewRun reads a Whisper JSON response that took elapsed to come back.
+func NewRun(model, backend string, body []byte, elapsed time.Duration) (Run, error) {
+	var resp struct {
+		Text     string  `json:"text"`
+		Duration float64 `json:"duration"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Run{}, err
+	}
+	run := Run{
+		Model:     model,
+		Backend:   backend,
+		Text:      strings.TrimSpace(resp.Text),
+		ElapsedMS: elapsed.Milliseconds(),
+	}
+	run.Words = len(strings.Fields(run.Text))
+	if resp.Duration > 0 && elapsed > 0 {
+		run.Speed = round(resp.Duration / elapsed.Seconds())
+	}
+	return run, nil
+}
+
+// Result is the comparison of two runs.
+type Result struct {
+	A Run `json:"a"`
+	B Run `json:"b"`
+	// Diff is the words B heard differently from A, in text order: Draft
+	// is A's words, Refined B's.
+	Diff []refine.Change `json:"diff"`
+	// ChangedWords counts the words of either text inside a change.
+	ChangedWords int `json:"changed_words"`
+	// Agreement is the percentage of words the two texts share, of the
+	// longer one's.
+	Agreement float64 `json:"agreement"`
+	// Faster names the quicker run, "a" or "b"; "" when they took as long.
+	Faster string `json:"faster,omitempty"`
+}
+
+// Compare diffs b against a, ignoring case and punctuation.
+func Compare(a, b Run) Result {
+	res := Result{A: a, B: b, Diff: refine.Diff(a.Text, b.Text)}
+	if res.Diff == nil {
+		res.Diff = []refine.Change{}
+	}
+	dropped := 0
+	for _, c := range res.Diff {
+		d, r := len(strings.Fields(c.Draft)), len(strings.Fields(c.Refined))
+		dropped += d
+		res.ChangedWords += d + r
+	}
+	res.Agreement = 100
+	if longer := max(a.Words, b.Words); longer > 0 {
+		res.Agreement = round(float64(a.Words-dropped) * 100 / float64(longer))
+	}
+	switch {
+	case a.ElapsedMS < b.ElapsedMS:
+		res.Faster = "a"
+	case b.ElapsedMS < a.ElapsedMS:
+		res.Faster = "b"
+	}
+	return res
+}
+
+func round(f float64) float64 { return math.Round(f*100) / 100 }
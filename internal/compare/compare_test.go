@@ -0,0 +1,39 @@
+package compare
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRun(t *testing.T) {
+	run, err := NewRun("large-v3", "", []byte(`{"text":" Send the report. ","duration":10}`), 4*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Text != "Send the report." || run.Words != 3 || run.ElapsedMS != 4000 || run.Speed != 2.5 {
+		t.Errorf("run = %+v", run)
+	}
+	if _, err := NewRun("tiny", "", []byte("not json"), time.Second); err == nil {
+		t.Error("invalid JSON accepted")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := Run{Model: "tiny", Text: "Send teh report to Anna", Words: 5, ElapsedMS: 300}
+	b := Run{Model: "large-v3", Text: "Send the report to Anna, please.", Words: 6, ElapsedMS: 2100}
+	res := Compare(a, b)
+	if len(res.Diff) != 2 || res.Diff[0].Draft != "teh" || res.Diff[1].Refined != "please." {
+		t.Errorf("diff = %+v", res.Diff)
+	}
+	if res.ChangedWords != 3 || res.Agreement != 66.67 || res.Faster != "a" {
+		t.Errorf("result = %+v", res)
+	}
+
+	same := Compare(a, a)
+	if len(same.Diff) != 0 || same.Agreement != 100 || same.Faster != "" {
+		t.Errorf("identical runs = %+v", same)
+	}
+	if empty := Compare(Run{}, Run{}); empty.Agreement != 100 || empty.Diff == nil {
+		t.Errorf("empty runs = %+v", empty)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config holds the application configuration.
@@ -15,9 +16,21 @@ type Config struct {
 	Host    string // CAPTAINSLOG_HOST (default: 0.0.0.0)
 
 	// Backend
-	WhisperURL string // CAPTAINSLOG_WHISPER_URL (default: http://127.0.0.1:5000)
+	WhisperURL string // CAPTAINSLOG_WHISPER_URL (default: http://127.0.0.1:5000 — comma-separate several to balance and fail over)
+	WhisperStrategy string // CAPTAINSLOG_WHISPER_STRATEGY (default: round-robin — or failover, for list order)
 	LLMURL     string // CAPTAINSLOG_LLM_URL (default: http://127.0.0.1:11434)
 	StreamURL  string // CAPTAINSLOG_STREAM_URL (optional — WebSocket URL for live streaming)
+	AlignURL   string // CAPTAINSLOG_ALIGN_URL (optional — WhisperX-style forced-alignment service for /api/align; without it aeneas is used if installed)
+	WhisperAuthHeader string // CAPTAINSLOG_WHISPER_AUTH_HEADER (default: Authorization — header for CAPTAINSLOG_WHISPER_API_KEY)
+	LLMAuthHeader     string // CAPTAINSLOG_LLM_AUTH_HEADER (default: Authorization — header for CAPTAINSLOG_LLM_API_KEY)
+	ProxyForwardHeaders string // CAPTAINSLOG_PROXY_FORWARD_HEADERS (optional — comma-separated inbound headers sent on to Whisper, "X-Model-*" for a prefix)
+	ProxyExposeHeaders  string // CAPTAINSLOG_PROXY_EXPOSE_HEADERS (default: Content-Type,Content-Language,Content-Disposition,Retry-After)
+	RetryAttempts   int           // CAPTAINSLOG_RETRY_ATTEMPTS (default: 2 — times a request every Whisper backend failed is tried again; 0 disables)
+	RetryBackoff    time.Duration // CAPTAINSLOG_RETRY_BACKOFF (default: 500ms — wait before the first retry, doubled for each next one)
+	RetryMaxBackoff time.Duration // CAPTAINSLOG_RETRY_MAX_BACKOFF (default: 10s — longest wait, also for a backend's Retry-After)
+	RetryStatuses   string        // CAPTAINSLOG_RETRY_STATUSES (default: 502,503,504 — backend statuses retried, besides timeouts and refused connections)
+	BreakerFailures int           // CAPTAINSLOG_BREAKER_FAILURES (default: 5 — consecutive failures after which a Whisper backend fails fast with 503; 0 disables)
+	BreakerOpenFor  time.Duration // CAPTAINSLOG_BREAKER_OPEN (default: 30s — how long it fails fast before one request may try again)
 
 	// Security
 	AuthToken string // CAPTAINSLOG_AUTH_TOKEN (optional — if set, requires Bearer token)
@@ -28,14 +41,108 @@ type Config struct {
 	// Features
 	EnableLLM bool // CAPTAINSLOG_ENABLE_LLM (default: false — works with Ollama, LM Studio, etc.)
 	EnableTLS bool // CAPTAINSLOG_ENABLE_TLS (default: false — auto-generates self-signed cert)
+	UpdateCheck bool // CAPTAINSLOG_UPDATE_CHECK (default: true — set false for air-gapped installs)
+	UpdateChannel string // CAPTAINSLOG_UPDATE_CHANNEL (default: stable — set beta to be offered pre-releases)
+
+	// TLS / crypto policy (only used with EnableTLS)
+	CryptoPolicy    string // CAPTAINSLOG_CRYPTO_POLICY (default: default — set fips to allow only FIPS 140 approved ciphers and curves)
+	TLSMinVersion   string // CAPTAINSLOG_TLS_MIN_VERSION (default: 1.2 — or 1.3)
+	TLSCipherSuites string // CAPTAINSLOG_TLS_CIPHERS (optional — comma-separated Go cipher suite names, TLS 1.2 only)
+	TLSCurves       string // CAPTAINSLOG_TLS_CURVES (optional — comma-separated: X25519, P256, P384, P521)
+	TLSKeyType      string // CAPTAINSLOG_TLS_KEY_TYPE (default: ecdsa — or rsa for the self-signed cert)
+	TLSClientCA     string // CAPTAINSLOG_TLS_CLIENT_CA (optional — PEM file of CAs that may issue client certificates)
+	TLSClientAuth   string // CAPTAINSLOG_TLS_CLIENT_AUTH (default: off — optional accepts a client cert instead of the bearer token, require rejects clients without one)
+	TLSLocalCA      bool   // CAPTAINSLOG_TLS_CA (default: true — issue the cert from a local CA devices install once, instead of self-signing it)
+	TLSRefreshSANs  bool   // CAPTAINSLOG_TLS_REFRESH_SANS (default: true — regenerate the self-signed cert at startup when a LAN IP or hostname it should cover is missing)
+
+	// Tailscale (auth key: CAPTAINSLOG_TAILSCALE_AUTHKEY, loaded as a secret)
+	Tailscale         bool   // CAPTAINSLOG_TAILSCALE (default: false — serve on the tailnet at https://<hostname>.<tailnet>.ts.net as its own tsnet node)
+	TailscaleHostname string // CAPTAINSLOG_TAILSCALE_HOSTNAME (optional — the node's machine name on the tailnet; default: the binary name)
+
+	// Public tunnel (needs CAPTAINSLOG_AUTH_TOKEN)
+	Tunnel    string        // CAPTAINSLOG_TUNNEL (optional — cloudflared or ngrok; opens a temporary public URL at startup)
+	TunnelTTL time.Duration // CAPTAINSLOG_TUNNEL_TTL (default: 1h — the tunnel closes after this; 0 keeps it open until shutdown)
 
 	// Observability
 	AccessLog bool   // CAPTAINSLOG_ACCESS_LOG (default: false — set true for per-request JSON logs)
 	LogDir    string // CAPTAINSLOG_LOG_DIR (optional — directory for log files, empty = stdout only)
+	PrivacyMode bool // CAPTAINSLOG_PRIVACY_MODE (default: false — redact transcript text from logs and SSE, scrub logged query strings)
+
+	// Outbound integrations
+	WebhookURL    string // CAPTAINSLOG_WEBHOOK_URL (optional — comma-separated URLs that receive event POSTs)
+	WebhookSecret string // CAPTAINSLOG_WEBHOOK_SECRET (optional — HMAC-SHA256 key for the X-Captainslog-Signature header)
+
+	// Upload buffering
+	SpoolMemoryMB int    // CAPTAINSLOG_SPOOL_MEMORY_MB (default: 8 — upload MB kept in RAM; the rest spills to a temp file)
+	SpoolDir      string // CAPTAINSLOG_SPOOL_DIR (optional — directory for spilled uploads, default: system temp dir)
+	MaxUploadMB   int    // CAPTAINSLOG_MAX_UPLOAD (default: 100 — largest upload in MB the transcription endpoints accept)
+
+	// Audio quality warnings (needs ffmpeg; see audio.Quality)
+	AudioWarnings bool // CAPTAINSLOG_AUDIO_WARNINGS (default: true — analyze uploads and flag phone-quality, clipped or quiet audio in X-Captainslog-Audio-Warnings)
+
+	// Background transcription jobs (POST /api/jobs)
+	JobWorkers   int           // CAPTAINSLOG_JOB_WORKERS (default: 1 — jobs transcribed at the same time)
+	JobTimeout   time.Duration // CAPTAINSLOG_JOB_TIMEOUT (default: 30m — longest a single job may take at the backend)
+	JobRetention time.Duration // CAPTAINSLOG_JOB_RETENTION (default: 24h — finished jobs and their results are deleted after this)
+	JobMaxQueued int           // CAPTAINSLOG_JOB_MAX_QUEUED (default: 100 — waiting jobs before new submissions get 503)
+
+	// Live dictation with server-side voice activity detection
+	// (/v1/audio/transcriptions/live; see stream.Segmenter)
+	LiveSilence   time.Duration // CAPTAINSLOG_LIVE_SILENCE (default: 700ms — pause that ends an utterance)
+	LiveMaxLength time.Duration // CAPTAINSLOG_LIVE_MAX_UTTERANCE (default: 20s — speech without a pause is cut into pieces this long)
+	LiveThreshold float64       // CAPTAINSLOG_LIVE_THRESHOLD (default: 0.01 — lowest RMS level, 0–1, counted as speech; raised to 3× the room's noise)
+
+	// A microphone attached to the server, recorded with arecord or ffmpeg
+	// (POST /api/mic/start and /api/mic/stop; see internal/mic)
+	MicDevice      string        // CAPTAINSLOG_MIC_DEVICE (optional — ALSA device such as "default" or "plughw:1,0"; empty disables)
+	MicMaxDuration time.Duration // CAPTAINSLOG_MIC_MAX_DURATION (default: 10m — a recording nobody stops ends here)
+
+	// Server-side transcription log (GET /api/history/log)
+	HistoryLogSize int // CAPTAINSLOG_HISTORY_LOG_SIZE (default: 500 — latest transcriptions kept, saved to the vault or not; 0 disables)
+
+	// Horizontal scaling: instances sharing one job queue and transcript index
+	SharedDir  string // CAPTAINSLOG_SHARED_DIR (optional — directory on a shared filesystem for jobs, index and recordings)
+	InstanceID string // CAPTAINSLOG_INSTANCE_ID (default: host name — recorded on jobs; must differ between instances)
+
+	// Backend URL policy (SSRF guard for URLs changed via settings)
+	URLSchemes     string // CAPTAINSLOG_URL_SCHEMES (optional — comma-separated, e.g. "https"; empty allows http and https)
+	URLAllowHosts  string // CAPTAINSLOG_URL_ALLOW_HOSTS (optional — comma-separated hostnames, *.domain, IPs or CIDRs; empty allows any host)
+	BlockLinkLocal bool   // CAPTAINSLOG_BLOCK_LINK_LOCAL (default: false — refuse to dial link-local and cloud metadata IPs such as 169.254.169.254)
+
+	// Embedding the UI in iframes (Obsidian, Home Assistant dashboards)
+	FrameAncestors string // CAPTAINSLOG_FRAME_ANCESTORS (optional — comma-separated origins allowed to frame the UI, e.g. "app://obsidian.md"; empty denies framing)
+	FramePaths     string // CAPTAINSLOG_FRAME_PATHS (default: /,/index.html — routes those origins may frame; "/dir/*" for a prefix)
+
+	// Outbound TLS to backends (HTTPS_PROXY / NO_PROXY are honoured as usual)
+	BackendCAFile      string // CAPTAINSLOG_BACKEND_CA_FILE (optional — PEM bundle of extra CAs trusted for Whisper/LLM HTTPS)
+	BackendTLSInsecure bool   // CAPTAINSLOG_BACKEND_TLS_INSECURE (default: false — skip backend certificate verification; lab use only)
+
+	// Backend connection pool (shared by proxy, watcher, LLM and health checks)
+	BackendMaxIdleConns   int           // CAPTAINSLOG_BACKEND_MAX_IDLE_CONNS (default: 100 — idle connections kept across all backends)
+	BackendMaxIdlePerHost int           // CAPTAINSLOG_BACKEND_MAX_IDLE_PER_HOST (default: 16 — idle connections kept per backend)
+	BackendIdleTimeout    time.Duration // CAPTAINSLOG_BACKEND_IDLE_TIMEOUT (default: 90s — how long an idle connection is kept)
+	BackendKeepAlive      time.Duration // CAPTAINSLOG_BACKEND_KEEPALIVE (default: 30s — TCP keep-alive probe interval)
+	BackendDNSRefresh     time.Duration // CAPTAINSLOG_BACKEND_DNS_REFRESH (default: 30s — re-resolve backend hostnames and drop connections to old IPs; 0 disables)
+	BackendFailureFlush   int           // CAPTAINSLOG_BACKEND_FAILURE_FLUSH (default: 3 — consecutive failed requests before pooled connections are dropped; 0 disables)
+	BackendConcurrency    int           // CAPTAINSLOG_BACKEND_CONCURRENCY (default: 2 — requests in flight per Whisper backend, the rest queue; 0 = unlimited)
 
 	// Rate limiting
 	RateLimit int    // CAPTAINSLOG_RATE_LIMIT (default: 0 — disabled, set >0 to enable for LAN/public)
 	RateAllow string // CAPTAINSLOG_RATE_ALLOW (default: "127.0.0.1,::1" — comma-separated IPs/CIDRs)
+
+	// Upload guardrails (per client IP; RateAllow IPs are exempt)
+	MaxUploadsPerIP    int // CAPTAINSLOG_MAX_UPLOADS_PER_IP (default: 0 — unlimited concurrent uploads per client)
+	MaxInflightMBPerIP int // CAPTAINSLOG_MAX_INFLIGHT_MB_PER_IP (default: 0 — unlimited upload MB in flight per client)
+	MaxInflightMB      int // CAPTAINSLOG_MAX_INFLIGHT_MB (default: 0 — unlimited upload MB in flight across all clients)
+
+	// Chaos testing (developer mode — injects faults into backend calls, never enable in production)
+	ChaosLatency   time.Duration // CAPTAINSLOG_CHAOS_LATENCY (default: 0 — fixed delay added to every backend call, e.g. "2s")
+	ChaosJitter    time.Duration // CAPTAINSLOG_CHAOS_JITTER (default: 0 — random extra delay up to this value)
+	ChaosErrorRate float64       // CAPTAINSLOG_CHAOS_ERROR_RATE (default: 0 — fraction of calls answered with a synthetic 5xx)
+	ChaosDropRate  float64       // CAPTAINSLOG_CHAOS_DROP_RATE (default: 0 — fraction of calls failed as dropped connections)
+
+	// Runtime diagnostics
+	DebugEndpoints bool // CAPTAINSLOG_DEBUG_ENDPOINTS (default: false — serve /debug/pprof/, /debug/vars and /api/admin/goroutines, admin only)
 }
 
 // Load reads configuration from environment variables with sensible defaults.
@@ -44,16 +151,86 @@ func Load() *Config {
 		Port:         envInt("CAPTAINSLOG_PORT", 8090),
 		Host:         envStr("CAPTAINSLOG_HOST", "0.0.0.0"),
 		WhisperURL:   envStr("CAPTAINSLOG_WHISPER_URL", "http://127.0.0.1:5000"),
+		WhisperStrategy: envStr("CAPTAINSLOG_WHISPER_STRATEGY", "round-robin"),
 		LLMURL:       envStr("CAPTAINSLOG_LLM_URL", envStr("CAPTAINSLOG_OLLAMA_URL", "http://127.0.0.1:11434")),
 		StreamURL:    envStr("CAPTAINSLOG_STREAM_URL", ""),
+		AlignURL:     envStr("CAPTAINSLOG_ALIGN_URL", ""),
+		WhisperAuthHeader: envStr("CAPTAINSLOG_WHISPER_AUTH_HEADER", "Authorization"),
+		LLMAuthHeader:     envStr("CAPTAINSLOG_LLM_AUTH_HEADER", "Authorization"),
+		ProxyForwardHeaders: envStr("CAPTAINSLOG_PROXY_FORWARD_HEADERS", ""),
+		ProxyExposeHeaders:  envStr("CAPTAINSLOG_PROXY_EXPOSE_HEADERS", ""),
+		RetryAttempts:   envInt("CAPTAINSLOG_RETRY_ATTEMPTS", 2),
+		RetryBackoff:    envDuration("CAPTAINSLOG_RETRY_BACKOFF", 500*time.Millisecond),
+		RetryMaxBackoff: envDuration("CAPTAINSLOG_RETRY_MAX_BACKOFF", 10*time.Second),
+		RetryStatuses:   envStr("CAPTAINSLOG_RETRY_STATUSES", ""),
+		BreakerFailures: envInt("CAPTAINSLOG_BREAKER_FAILURES", 5),
+		BreakerOpenFor:  envDuration("CAPTAINSLOG_BREAKER_OPEN", 30*time.Second),
 		AuthToken:    envStr("CAPTAINSLOG_AUTH_TOKEN", ""),
 		VaultDir:     envStr("CAPTAINSLOG_VAULT_DIR", ""),
 		EnableLLM:    envBool("CAPTAINSLOG_ENABLE_LLM", envBool("CAPTAINSLOG_ENABLE_OLLAMA", false)),
 		EnableTLS:    envBool("CAPTAINSLOG_ENABLE_TLS", false),
+		UpdateCheck:  envBool("CAPTAINSLOG_UPDATE_CHECK", true),
+		UpdateChannel: envStr("CAPTAINSLOG_UPDATE_CHANNEL", "stable"),
+		CryptoPolicy:    envStr("CAPTAINSLOG_CRYPTO_POLICY", "default"),
+		TLSMinVersion:   envStr("CAPTAINSLOG_TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites: envStr("CAPTAINSLOG_TLS_CIPHERS", ""),
+		TLSCurves:       envStr("CAPTAINSLOG_TLS_CURVES", ""),
+		TLSKeyType:      envStr("CAPTAINSLOG_TLS_KEY_TYPE", "ecdsa"),
+		TLSClientCA:     envStr("CAPTAINSLOG_TLS_CLIENT_CA", ""),
+		TLSClientAuth:   envStr("CAPTAINSLOG_TLS_CLIENT_AUTH", "off"),
+		TLSLocalCA:      envBool("CAPTAINSLOG_TLS_CA", true),
+		TLSRefreshSANs:  envBool("CAPTAINSLOG_TLS_REFRESH_SANS", true),
+		Tailscale:         envBool("CAPTAINSLOG_TAILSCALE", false),
+		TailscaleHostname: envStr("CAPTAINSLOG_TAILSCALE_HOSTNAME", ""),
+		Tunnel:    envStr("CAPTAINSLOG_TUNNEL", ""),
+		TunnelTTL: envDuration("CAPTAINSLOG_TUNNEL_TTL", time.Hour),
 		AccessLog:    envBool("CAPTAINSLOG_ACCESS_LOG", false),
 		LogDir:       envStr("CAPTAINSLOG_LOG_DIR", ""),
+		PrivacyMode:  envBool("CAPTAINSLOG_PRIVACY_MODE", false),
+		WebhookURL:    envStr("CAPTAINSLOG_WEBHOOK_URL", ""),
+		WebhookSecret: envStr("CAPTAINSLOG_WEBHOOK_SECRET", ""),
+		SpoolMemoryMB:  envInt("CAPTAINSLOG_SPOOL_MEMORY_MB", 8),
+		SpoolDir:       envStr("CAPTAINSLOG_SPOOL_DIR", ""),
+		MaxUploadMB:    envInt("CAPTAINSLOG_MAX_UPLOAD", 100),
+		AudioWarnings: envBool("CAPTAINSLOG_AUDIO_WARNINGS", true),
+		JobWorkers:   envInt("CAPTAINSLOG_JOB_WORKERS", 1),
+		JobTimeout:   envDuration("CAPTAINSLOG_JOB_TIMEOUT", 30*time.Minute),
+		JobRetention: envDuration("CAPTAINSLOG_JOB_RETENTION", 24*time.Hour),
+		JobMaxQueued: envInt("CAPTAINSLOG_JOB_MAX_QUEUED", 100),
+		LiveSilence:   envDuration("CAPTAINSLOG_LIVE_SILENCE", 700*time.Millisecond),
+		LiveMaxLength: envDuration("CAPTAINSLOG_LIVE_MAX_UTTERANCE", 20*time.Second),
+		LiveThreshold: envFloat("CAPTAINSLOG_LIVE_THRESHOLD", 0.01),
+		MicDevice:      envStr("CAPTAINSLOG_MIC_DEVICE", ""),
+		MicMaxDuration: envDuration("CAPTAINSLOG_MIC_MAX_DURATION", 10*time.Minute),
+		HistoryLogSize: envInt("CAPTAINSLOG_HISTORY_LOG_SIZE", 500),
+		SharedDir:    envStr("CAPTAINSLOG_SHARED_DIR", ""),
+		InstanceID:   envStr("CAPTAINSLOG_INSTANCE_ID", ""),
+		URLSchemes:     envStr("CAPTAINSLOG_URL_SCHEMES", ""),
+		URLAllowHosts:  envStr("CAPTAINSLOG_URL_ALLOW_HOSTS", ""),
+		BlockLinkLocal: envBool("CAPTAINSLOG_BLOCK_LINK_LOCAL", false),
+		FrameAncestors: envStr("CAPTAINSLOG_FRAME_ANCESTORS", ""),
+		FramePaths:     envStr("CAPTAINSLOG_FRAME_PATHS", "/,/index.html"),
+		BackendCAFile:      envStr("CAPTAINSLOG_BACKEND_CA_FILE", ""),
+		BackendTLSInsecure: envBool("CAPTAINSLOG_BACKEND_TLS_INSECURE", false),
+		BackendMaxIdleConns:   envInt("CAPTAINSLOG_BACKEND_MAX_IDLE_CONNS", 100),
+		BackendMaxIdlePerHost: envInt("CAPTAINSLOG_BACKEND_MAX_IDLE_PER_HOST", 16),
+		BackendIdleTimeout:    envDuration("CAPTAINSLOG_BACKEND_IDLE_TIMEOUT", 90*time.Second),
+		BackendKeepAlive:      envDuration("CAPTAINSLOG_BACKEND_KEEPALIVE", 30*time.Second),
+		BackendDNSRefresh:     envDuration("CAPTAINSLOG_BACKEND_DNS_REFRESH", 30*time.Second),
+		BackendFailureFlush:   envInt("CAPTAINSLOG_BACKEND_FAILURE_FLUSH", 3),
+		BackendConcurrency:    envInt("CAPTAINSLOG_BACKEND_CONCURRENCY", 2),
 		RateLimit:    envInt("CAPTAINSLOG_RATE_LIMIT", 0),
 		RateAllow:    envStr("CAPTAINSLOG_RATE_ALLOW", "127.0.0.1,::1"),
+		MaxUploadsPerIP:    envInt("CAPTAINSLOG_MAX_UPLOADS_PER_IP", 0),
+		MaxInflightMBPerIP: envInt("CAPTAINSLOG_MAX_INFLIGHT_MB_PER_IP", 0),
+		MaxInflightMB:      envInt("CAPTAINSLOG_MAX_INFLIGHT_MB", 0),
+
+		ChaosLatency:   envDuration("CAPTAINSLOG_CHAOS_LATENCY", 0),
+		ChaosJitter:    envDuration("CAPTAINSLOG_CHAOS_JITTER", 0),
+		ChaosErrorRate: envFloat("CAPTAINSLOG_CHAOS_ERROR_RATE", 0),
+		ChaosDropRate:  envFloat("CAPTAINSLOG_CHAOS_DROP_RATE", 0),
+
+		DebugEndpoints: envBool("CAPTAINSLOG_DEBUG_ENDPOINTS", false),
 	}
 }
 
@@ -86,3 +263,21 @@ func envBool(key string, fallback bool) bool {
 	}
 	return fallback
 }
+
+func envFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadDefaults(t *testing.T) {
@@ -11,7 +12,7 @@ func TestLoadDefaults(t *testing.T) {
 		"CAPTAINSLOG_PORT", "CAPTAINSLOG_HOST", "CAPTAINSLOG_WHISPER_URL",
 		"CAPTAINSLOG_LLM_URL", "CAPTAINSLOG_OLLAMA_URL", "CAPTAINSLOG_AUTH_TOKEN",
 		"CAPTAINSLOG_VAULT_DIR", "CAPTAINSLOG_ENABLE_LLM", "CAPTAINSLOG_ENABLE_OLLAMA",
-		"CAPTAINSLOG_ENABLE_TLS",
+		"CAPTAINSLOG_ENABLE_TLS", "CAPTAINSLOG_UPDATE_CHECK", "CAPTAINSLOG_UPDATE_CHANNEL",
 	} {
 		os.Unsetenv(key)
 	}
@@ -42,6 +43,12 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.EnableTLS {
 		t.Error("EnableTLS should be false by default")
 	}
+	if !cfg.UpdateCheck {
+		t.Error("UpdateCheck should be true by default")
+	}
+	if cfg.UpdateChannel != "stable" {
+		t.Errorf("UpdateChannel = %q, want stable", cfg.UpdateChannel)
+	}
 }
 
 func TestLoadFromEnv(t *testing.T) {
@@ -118,3 +125,127 @@ func TestEnvBoolInvalid(t *testing.T) {
 		t.Error("EnableLLM should fallback to false on invalid input")
 	}
 }
+
+func TestLoadChaos(t *testing.T) {
+	t.Setenv("CAPTAINSLOG_CHAOS_LATENCY", "1500ms")
+	t.Setenv("CAPTAINSLOG_CHAOS_ERROR_RATE", "0.25")
+	t.Setenv("CAPTAINSLOG_CHAOS_DROP_RATE", "not-a-float")
+
+	cfg := Load()
+
+	if cfg.ChaosLatency != 1500*time.Millisecond {
+		t.Errorf("ChaosLatency = %v, want 1.5s", cfg.ChaosLatency)
+	}
+	if cfg.ChaosErrorRate != 0.25 {
+		t.Errorf("ChaosErrorRate = %v, want 0.25", cfg.ChaosErrorRate)
+	}
+	if cfg.ChaosDropRate != 0 {
+		t.Errorf("ChaosDropRate = %v, want fallback 0 on invalid input", cfg.ChaosDropRate)
+	}
+}
+
+func TestLoadUpdateCheckOptOut(t *testing.T) {
+	t.Setenv("CAPTAINSLOG_UPDATE_CHECK", "false")
+
+	if Load().UpdateCheck {
+		t.Error("UpdateCheck should be false when CAPTAINSLOG_UPDATE_CHECK=false")
+	}
+}
+
+func TestLoadWebhook(t *testing.T) {
+	t.Setenv("CAPTAINSLOG_WEBHOOK_URL", "http://a/hook,http://b/hook")
+	t.Setenv("CAPTAINSLOG_WEBHOOK_SECRET", "s3cret")
+
+	cfg := Load()
+
+	if cfg.WebhookURL != "http://a/hook,http://b/hook" {
+		t.Errorf("WebhookURL = %q", cfg.WebhookURL)
+	}
+	if cfg.WebhookSecret != "s3cret" {
+		t.Errorf("WebhookSecret = %q", cfg.WebhookSecret)
+	}
+}
+
+func TestLoadPrivacyMode(t *testing.T) {
+	if Load().PrivacyMode {
+		t.Error("PrivacyMode should default to false")
+	}
+	t.Setenv("CAPTAINSLOG_PRIVACY_MODE", "true")
+	if !Load().PrivacyMode {
+		t.Error("PrivacyMode should be true when CAPTAINSLOG_PRIVACY_MODE=true")
+	}
+}
+
+func TestLoadTLSPolicy(t *testing.T) {
+	cfg := Load()
+	if cfg.CryptoPolicy != "default" || cfg.TLSMinVersion != "1.2" || cfg.TLSKeyType != "ecdsa" {
+		t.Errorf("defaults = %q %q %q", cfg.CryptoPolicy, cfg.TLSMinVersion, cfg.TLSKeyType)
+	}
+	t.Setenv("CAPTAINSLOG_CRYPTO_POLICY", "fips")
+	t.Setenv("CAPTAINSLOG_TLS_MIN_VERSION", "1.3")
+	t.Setenv("CAPTAINSLOG_TLS_CIPHERS", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	t.Setenv("CAPTAINSLOG_TLS_CURVES", "P384")
+	t.Setenv("CAPTAINSLOG_TLS_KEY_TYPE", "rsa")
+	cfg = Load()
+	if cfg.CryptoPolicy != "fips" || cfg.TLSMinVersion != "1.3" || cfg.TLSKeyType != "rsa" ||
+		cfg.TLSCipherSuites != "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" || cfg.TLSCurves != "P384" {
+		t.Errorf("from env = %+v", cfg)
+	}
+}
+
+func TestLoadTLSClientAuth(t *testing.T) {
+	if cfg := Load(); cfg.TLSClientAuth != "off" || cfg.TLSClientCA != "" {
+		t.Errorf("defaults = %q %q", cfg.TLSClientAuth, cfg.TLSClientCA)
+	}
+	t.Setenv("CAPTAINSLOG_TLS_CLIENT_AUTH", "require")
+	t.Setenv("CAPTAINSLOG_TLS_CLIENT_CA", "/etc/captainslog/clients.pem")
+	if cfg := Load(); cfg.TLSClientAuth != "require" || cfg.TLSClientCA != "/etc/captainslog/clients.pem" {
+		t.Errorf("from env = %q %q", cfg.TLSClientAuth, cfg.TLSClientCA)
+	}
+}
+
+func TestLoadURLPolicy(t *testing.T) {
+	if cfg := Load(); cfg.URLSchemes != "" || cfg.URLAllowHosts != "" || cfg.BlockLinkLocal {
+		t.Errorf("defaults = %q %q %v", cfg.URLSchemes, cfg.URLAllowHosts, cfg.BlockLinkLocal)
+	}
+	t.Setenv("CAPTAINSLOG_URL_SCHEMES", "https")
+	t.Setenv("CAPTAINSLOG_URL_ALLOW_HOSTS", "localhost,*.home.arpa")
+	t.Setenv("CAPTAINSLOG_BLOCK_LINK_LOCAL", "true")
+	if cfg := Load(); cfg.URLSchemes != "https" || cfg.URLAllowHosts != "localhost,*.home.arpa" || !cfg.BlockLinkLocal {
+		t.Errorf("from env = %q %q %v", cfg.URLSchemes, cfg.URLAllowHosts, cfg.BlockLinkLocal)
+	}
+}
+
+func TestLoadUploadGuard(t *testing.T) {
+	if cfg := Load(); cfg.MaxUploadsPerIP != 0 || cfg.MaxInflightMBPerIP != 0 || cfg.MaxInflightMB != 0 {
+		t.Errorf("defaults = %d %d %d", cfg.MaxUploadsPerIP, cfg.MaxInflightMBPerIP, cfg.MaxInflightMB)
+	}
+	t.Setenv("CAPTAINSLOG_MAX_UPLOADS_PER_IP", "2")
+	t.Setenv("CAPTAINSLOG_MAX_INFLIGHT_MB_PER_IP", "100")
+	t.Setenv("CAPTAINSLOG_MAX_INFLIGHT_MB", "300")
+	if cfg := Load(); cfg.MaxUploadsPerIP != 2 || cfg.MaxInflightMBPerIP != 100 || cfg.MaxInflightMB != 300 {
+		t.Errorf("from env = %d %d %d", cfg.MaxUploadsPerIP, cfg.MaxInflightMBPerIP, cfg.MaxInflightMB)
+	}
+}
+
+func TestLoadSpool(t *testing.T) {
+	if cfg := Load(); cfg.SpoolMemoryMB != 8 || cfg.SpoolDir != "" {
+		t.Errorf("defaults = %d %q", cfg.SpoolMemoryMB, cfg.SpoolDir)
+	}
+	t.Setenv("CAPTAINSLOG_SPOOL_MEMORY_MB", "2")
+	t.Setenv("CAPTAINSLOG_SPOOL_DIR", "/var/tmp/captainslog")
+	if cfg := Load(); cfg.SpoolMemoryMB != 2 || cfg.SpoolDir != "/var/tmp/captainslog" {
+		t.Errorf("from env = %d %q", cfg.SpoolMemoryMB, cfg.SpoolDir)
+	}
+}
+
+func TestLoadJobs(t *testing.T) {
+	if cfg := Load(); cfg.JobWorkers != 1 || cfg.JobTimeout != 30*time.Minute || cfg.JobRetention != 24*time.Hour || cfg.JobMaxQueued != 100 {
+		t.Errorf("defaults = %d %v %v %d", cfg.JobWorkers, cfg.JobTimeout, cfg.JobRetention, cfg.JobMaxQueued)
+	}
+	t.Setenv("CAPTAINSLOG_JOB_WORKERS", "2")
+	t.Setenv("CAPTAINSLOG_JOB_TIMEOUT", "2h")
+	if cfg := Load(); cfg.JobWorkers != 2 || cfg.JobTimeout != 2*time.Hour {
+		t.Errorf("from env = %d %v", cfg.JobWorkers, cfg.JobTimeout)
+	}
+}
@@ -0,0 +1,349 @@
+// Package connpool builds the one http.Transport shared by every backend
+// client (proxy, watcher, LLM, health checks) and counts what its
+// connection pool does.
+//
+// WHY not http.DefaultTransport? It keeps only two idle connections per
+// host. Under a burst of uploads every request past the second dials a new
+// connection to the same Whisper box and throws it away afterwards — TCP
+// and TLS handshakes per transcription, and TIME_WAIT sockets piling up.
+//
+// Pooling has a cost when backends are addressed by hostname (Docker
+// service names, Tailscale MagicDNS): after a container restart the name
+// points at a new IP while the pool still holds connections to the old one.
+// Watch re-resolves those names and drops idle connections once the
+// addresses move, and a run of failed requests to a host drops them too.
+package connpool
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/metrics"
+)
+
+// Metric names. All are labelled by backend host[:port].
+const (
+	metricOpen   = "captainslog_backend_connections_open"
+	metricDials  = "captainslog_backend_dials_total"
+	metricReused = "captainslog_backend_connections_reused_total"
+	metricFlush  = "captainslog_backend_pool_flushes_total"
+)
+
+// Why idle connections were dropped, as recorded in Stats and metrics.
+const (
+	flushDNS      = "dns_changed"
+	flushFailures = "failures"
+)
+
+// Config tunes the pool. Zero size and timeout fields take the values in
+// DefaultConfig; zero RefreshInterval and FailureThreshold turn those
+// checks off.
+type Config struct {
+	MaxIdleConns        int           // idle connections kept across all backends
+	MaxIdleConnsPerHost int           // idle connections kept per backend
+	IdleConnTimeout     time.Duration // how long an idle connection is kept
+	KeepAlive           time.Duration // TCP keep-alive probe interval
+	DialTimeout         time.Duration // TCP connect timeout
+	RefreshInterval     time.Duration // how often Watch re-resolves backend hostnames
+	FailureThreshold    int           // consecutive failed requests to a host before idle connections are dropped
+
+	TLS     *tls.Config                                            // outbound TLS settings (nil = Go defaults)
+	Control func(network, address string, c syscall.RawConn) error // dialer hook, e.g. ssrf.Policy.Control
+}
+
+// DefaultConfig matches http.DefaultTransport except for the per-host idle
+// limit, which is sized for a handful of concurrent transcriptions.
+func DefaultConfig() Config {
+	return Config{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		KeepAlive:           30 * time.Second,
+		DialTimeout:         30 * time.Second,
+		RefreshInterval:     30 * time.Second,
+		FailureThreshold:    3,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = d.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = d.MaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = d.IdleConnTimeout
+	}
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = d.KeepAlive
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = d.DialTimeout
+	}
+	return c
+}
+
+// Stats summarises pool activity since startup.
+type Stats struct {
+	Open                int64            `json:"open"`
+	Dials               int64            `json:"dials"`
+	Reused              int64            `json:"reused"`
+	Flushes             map[string]int64 `json:"flushes,omitempty"`
+	MaxIdleConnsPerHost int              `json:"max_idle_conns_per_host"`
+	IdleConnTimeout     string           `json:"idle_conn_timeout"`
+}
+
+// Pool is an http.RoundTripper over a single tuned *http.Transport.
+type Pool struct {
+	cfg       Config
+	transport *http.Transport
+
+	open, dials, reused atomic.Int64
+
+	// lookup resolves hostnames for Watch; a field so tests can fake DNS.
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	mu       sync.Mutex
+	peers    map[string]map[string]int // hostname → remote IP → open connections
+	failures map[string]int            // host → consecutive failed requests
+	flushes  map[string]int64          // reason → count
+
+	// nil unless WithMetrics is used
+	mOpen   *metrics.Gauge
+	mDials  *metrics.Counter
+	mReused *metrics.Counter
+	mFlush  *metrics.Counter
+}
+
+// New builds a Pool. HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honoured, as
+// with http.DefaultTransport.
+func New(cfg Config) *Pool {
+	cfg = cfg.withDefaults()
+	p := &Pool{
+		cfg:      cfg,
+		lookup:   net.DefaultResolver.LookupIPAddr,
+		peers:    map[string]map[string]int{},
+		failures: map[string]int{},
+		flushes:  map[string]int64{},
+	}
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.KeepAlive, Control: cfg.Control}
+	p.transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return p.track(conn, addr), nil
+		},
+		TLSClientConfig:       cfg.TLS,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return p
+}
+
+// WithMetrics records pool activity into reg and returns p. Call it before
+// the pool is first used.
+func (p *Pool) WithMetrics(reg *metrics.Registry) *Pool {
+	p.mOpen = reg.Gauge(metricOpen, "Open connections to backends.", "backend")
+	p.mDials = reg.Counter(metricDials, "New connections dialled to backends.", "backend")
+	p.mReused = reg.Counter(metricReused, "Backend requests served over a pooled connection.", "backend")
+	p.mFlush = reg.Counter(metricFlush, "Times idle backend connections were dropped, by reason (dns_changed, failures).", "reason")
+	return p
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				p.reused.Add(1)
+				if p.mReused != nil {
+					p.mReused.Inc(host)
+				}
+			}
+		},
+	}
+	ctx := httptrace.WithClientTrace(req.Context(), trace)
+	resp, err := p.transport.RoundTrip(req.WithContext(ctx))
+	p.observe(host, err)
+	return resp, err
+}
+
+// observe counts consecutive failed requests per host. A request the caller
+// cancelled says nothing about the backend and is ignored.
+func (p *Pool) observe(host string, err error) {
+	if p.cfg.FailureThreshold <= 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+	p.mu.Lock()
+	if err == nil {
+		delete(p.failures, host)
+		p.mu.Unlock()
+		return
+	}
+	p.failures[host]++
+	flush := p.failures[host] >= p.cfg.FailureThreshold
+	if flush {
+		delete(p.failures, host)
+	}
+	p.mu.Unlock()
+	if flush {
+		p.flush(flushFailures)
+	}
+}
+
+// Watch re-resolves the hostnames of open connections every
+// RefreshInterval until ctx is done, and drops idle connections when a
+// name no longer resolves to an address they point at. It is a no-op when
+// RefreshInterval is zero.
+func (p *Pool) Watch(ctx context.Context) {
+	if p.cfg.RefreshInterval <= 0 {
+		return
+	}
+	t := time.NewTicker(p.cfg.RefreshInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh re-resolves backend hostnames now. Returns true if idle
+// connections were dropped.
+func (p *Pool) Refresh(ctx context.Context) bool {
+	p.mu.Lock()
+	hosts := make(map[string][]string, len(p.peers))
+	for host, ips := range p.peers {
+		for ip := range ips {
+			hosts[host] = append(hosts[host], ip)
+		}
+	}
+	p.mu.Unlock()
+
+	for host, connected := range hosts {
+		addrs, err := p.lookup(ctx, host)
+		if err != nil || len(addrs) == 0 {
+			// A DNS hiccup is not evidence the backend moved.
+			continue
+		}
+		current := make(map[string]bool, len(addrs))
+		for _, a := range addrs {
+			current[a.IP.String()] = true
+		}
+		for _, ip := range connected {
+			if !current[ip] {
+				p.flush(flushDNS)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// flush drops idle connections. WHY all of them, not just the stale host's?
+// http.Transport offers no per-host close, and redialling the other
+// backends once is cheap next to a request failing on a dead connection.
+func (p *Pool) flush(reason string) {
+	p.transport.CloseIdleConnections()
+	p.mu.Lock()
+	p.flushes[reason]++
+	p.mu.Unlock()
+	if p.mFlush != nil {
+		p.mFlush.Inc(reason)
+	}
+}
+
+// CloseIdleConnections closes pooled connections that are not in use.
+func (p *Pool) CloseIdleConnections() { p.transport.CloseIdleConnections() }
+
+// Stats returns a snapshot of pool activity.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	var flushes map[string]int64
+	if len(p.flushes) > 0 {
+		flushes = make(map[string]int64, len(p.flushes))
+		for k, v := range p.flushes {
+			flushes[k] = v
+		}
+	}
+	p.mu.Unlock()
+	return Stats{
+		Open:                p.open.Load(),
+		Dials:               p.dials.Load(),
+		Reused:              p.reused.Load(),
+		Flushes:             flushes,
+		MaxIdleConnsPerHost: p.cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     p.cfg.IdleConnTimeout.String(),
+	}
+}
+
+// track counts a new connection until it is closed. addr is the dialled
+// address — the backend itself, or the HTTP proxy in front of it.
+func (p *Pool) track(conn net.Conn, addr string) net.Conn {
+	p.dials.Add(1)
+	p.open.Add(1)
+	if p.mDials != nil {
+		p.mDials.Inc(addr)
+		p.mOpen.Add(1, addr)
+	}
+	// Remember which IP a hostname was dialled at, for Refresh.
+	host, _, _ := net.SplitHostPort(addr)
+	var ip string
+	if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); ok && net.ParseIP(host) == nil {
+		ip = tcp.IP.String()
+		p.mu.Lock()
+		if p.peers[host] == nil {
+			p.peers[host] = map[string]int{}
+		}
+		p.peers[host][ip]++
+		p.mu.Unlock()
+	}
+	return &trackedConn{Conn: conn, onClose: func() {
+		p.open.Add(-1)
+		if p.mOpen != nil {
+			p.mOpen.Add(-1, addr)
+		}
+		if ip != "" {
+			p.mu.Lock()
+			if p.peers[host][ip]--; p.peers[host][ip] <= 0 {
+				delete(p.peers[host], ip)
+				if len(p.peers[host]) == 0 {
+					delete(p.peers, host)
+				}
+			}
+			p.mu.Unlock()
+		}
+	}}
+}
+
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
@@ -0,0 +1,198 @@
+package connpool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/metrics"
+)
+
+func TestPoolReusesConnections(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+
+	reg := metrics.NewRegistry()
+	p := New(Config{}).WithMetrics(reg)
+	client := &http.Client{Transport: p}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(backend.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	s := p.Stats()
+	if s.Dials != 1 || s.Reused != 4 || s.Open != 1 {
+		t.Errorf("stats = %+v, want 1 dial, 4 reused, 1 open", s)
+	}
+
+	var out strings.Builder
+	reg.WritePrometheus(&out)
+	for _, want := range []string{metricDials, metricReused, metricOpen} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics output lacks %s", want)
+		}
+	}
+
+	p.CloseIdleConnections()
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Open != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if open := p.Stats().Open; open != 0 {
+		t.Errorf("open = %d after CloseIdleConnections, want 0", open)
+	}
+}
+
+func TestPoolDefaults(t *testing.T) {
+	p := New(Config{MaxIdleConnsPerHost: 4})
+	if p.transport.MaxIdleConnsPerHost != 4 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 4", p.transport.MaxIdleConnsPerHost)
+	}
+	d := DefaultConfig()
+	if p.transport.MaxIdleConns != d.MaxIdleConns || p.transport.IdleConnTimeout != d.IdleConnTimeout {
+		t.Errorf("zero fields did not take defaults: %+v", p.cfg)
+	}
+	if p.transport.Proxy == nil {
+		t.Error("proxy environment variables are not honoured")
+	}
+}
+
+func TestPoolControlHook(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	called := false
+	p := New(Config{Control: func(network, address string, c syscall.RawConn) error {
+		called = true
+		return nil
+	}})
+	resp, err := (&http.Client{Transport: p}).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !called {
+		t.Error("dialer Control hook was not called")
+	}
+}
+
+// get fetches url through p and drains the body so the connection returns
+// to the pool.
+func get(t *testing.T, p *Pool, url string) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: p}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func waitOpen(p *Pool, want int64) int64 {
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Open != want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return p.Stats().Open
+}
+
+func TestRefreshDropsConnectionsWhenDNSMoves(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	url := "http://localhost:" + port
+
+	p := New(Config{})
+	resolved := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}}
+	p.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if host != "localhost" {
+			t.Errorf("looked up %q, want localhost", host)
+		}
+		return resolved, nil
+	}
+	get(t, p, url)
+
+	if p.Refresh(context.Background()) {
+		t.Error("Refresh dropped connections although DNS did not change")
+	}
+	if open := p.Stats().Open; open != 1 {
+		t.Fatalf("open = %d, want 1", open)
+	}
+
+	// The container restarted somewhere else.
+	resolved = []net.IPAddr{{IP: net.ParseIP("10.0.0.7")}}
+	if !p.Refresh(context.Background()) {
+		t.Error("Refresh kept connections to an address the name no longer has")
+	}
+	if open := waitOpen(p, 0); open != 0 {
+		t.Errorf("open = %d after refresh, want 0", open)
+	}
+	if n := p.Stats().Flushes[flushDNS]; n != 1 {
+		t.Errorf("dns flushes = %d, want 1", n)
+	}
+}
+
+func TestRefreshIgnoresLookupErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+
+	p := New(Config{})
+	p.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return nil, errors.New("no such host")
+	}
+	get(t, p, "http://localhost:"+port)
+	if p.Refresh(context.Background()) || p.Stats().Open != 1 {
+		t.Error("a failed lookup should keep existing connections")
+	}
+}
+
+func TestFailuresDropConnections(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	p := New(Config{FailureThreshold: 2})
+	get(t, p, backend.URL)
+
+	// A closed port fails fast; two failures in a row trip the threshold.
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	deadURL := dead.URL
+	dead.Close()
+	client := &http.Client{Transport: p}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get(deadURL); err == nil {
+			t.Fatal("expected an error from a closed backend")
+		}
+	}
+	if n := p.Stats().Flushes[flushFailures]; n != 1 {
+		t.Errorf("failure flushes = %d, want 1", n)
+	}
+	if open := waitOpen(p, 0); open != 0 {
+		t.Errorf("open = %d, want 0 after the pool was flushed", open)
+	}
+}
+
+func TestFailureCountResetsOnSuccess(t *testing.T) {
+	p := New(Config{FailureThreshold: 2})
+
+	p.observe("h:1", errors.New("boom"))
+	p.observe("h:1", nil)
+	p.observe("h:1", errors.New("boom"))
+	p.observe("h:1", context.Canceled)
+	if len(p.Stats().Flushes) != 0 {
+		t.Errorf("flushes = %v, want none", p.Stats().Flushes)
+	}
+}
@@ -0,0 +1,44 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	sunday := time.Date(2026, 3, 8, 21, 30, 0, 0, time.Local) // a Sunday
+	tests := []struct {
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"30 21 * * 0", sunday, true},
+		{"30 21 * * 7", sunday, true},
+		{"30 21 * * 1-5", sunday, false},
+		{"*/15 * * * *", sunday, true},
+		{"*/20 * * * *", sunday, false},
+		{"0,30 21 8 * *", sunday, true},
+		{"30 21 1 * 0", sunday, true},                   // either day field matches
+		{"30 21 1 * 1", sunday, false},                  // neither does
+		{"@daily", sunday.Add(150 * time.Minute), true}, // Monday 00:00
+		{"@weekly", sunday, false},
+		{"@hourly", sunday.Add(30 * time.Minute), true},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got := s.Match(tt.at); got != tt.want {
+			t.Errorf("%q at %v = %v, want %v", tt.expr, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@monthly"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package digest writes "Captain's Log — Weekly Summary" notes: the
+// transcripts of a day or week, summarized by the LLM into one vault note.
+//
+// A Digester runs on a cron schedule from settings, and on demand through
+// /api/digest/run. It reads the period's notes, has the LLM summarize
+// them (in parts, then the parts together, when they don't fit a small
+// model's context), and writes the digest note with links back to every
+// note it covers. Running the same period again replaces its digest.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+// Periods a digest covers.
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+// ValidPeriod reports whether p names a period.
+func ValidPeriod(p string) bool { return p == PeriodDaily || p == PeriodWeekly }
+
+// Window is the time a digest of period covers when run at now: the day
+// or week before it.
+func Window(period string, now time.Time) (from, to time.Time) {
+	if period == PeriodDaily {
+		return now.AddDate(0, 0, -1), now
+	}
+	return now.AddDate(0, 0, -7), now
+}
+
+// runTimeout bounds one digest: a week of notes can take several calls.
+const runTimeout = 10 * time.Minute
+
+const summaryPrompt = `The following are dictated notes from %s. Write a summary of them for the author's log: ` +
+	`a short overview paragraph, then the main themes, decisions and open action items as markdown bullet lists. ` +
+	`Leave out empty sections. Reply in markdown without a title.`
+
+const mergePrompt = `The following are summaries of parts of the dictated notes from %s. Merge them into one summary: ` +
+	`a short overview paragraph, then the main themes, decisions and open action items as markdown bullet lists. ` +
+	`Leave out empty sections. Reply in markdown without a title.`
+
+// Note is one transcript a digest covers.
+type Note struct {
+	File  string // absolute path in the vault
+	Time  time.Time
+	Title string
+	Text  string
+}
+
+// Settings are read before each run, so changes apply without a restart.
+type Settings struct {
+	VaultDir string
+	Folder   string      // inside the vault; "" = the vault itself
+	Client   *llm.Client // nil while the LLM is disabled
+	Schedule string      // cron expression (see ParseSchedule); "" = manual runs only
+	Period   string      // of scheduled runs
+}
+
+// Result describes one run.
+type Result struct {
+	Period string    `json:"period"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Notes  int       `json:"notes"`
+	File   string    `json:"file,omitempty"` // "" when there were no notes to digest
+	At     time.Time `json:"at"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// ErrNoLLM is returned by Run while the LLM is disabled.
+var ErrNoLLM = errors.New("LLM not enabled")
+
+// ErrNoVault is returned by Run without a vault to write to.
+var ErrNoVault = errors.New("vault directory not configured")
+
+// Digester writes digests.
+type Digester struct {
+	Settings func() Settings
+	// Notes returns the transcripts saved in [from, to), oldest first.
+	Notes  func(from, to time.Time) ([]Note, error)
+	Logger *slog.Logger
+
+	run  sync.Mutex // one digest at a time
+	mu   sync.Mutex
+	last *Result
+}
+
+// Last returns the latest run's result, nil before the first.
+func (d *Digester) Last() *Result {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last == nil {
+		return nil
+	}
+	r := *d.last
+	return &r
+}
+
+// Run writes the digest of the notes saved in [from, to).
+func (d *Digester) Run(ctx context.Context, period string, from, to time.Time) (Result, error) {
+	d.run.Lock()
+	defer d.run.Unlock()
+	res := Result{Period: period, From: from, To: to, At: time.Now()}
+	err := d.write(ctx, &res)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	d.mu.Lock()
+	d.last = &res
+	d.mu.Unlock()
+	return res, err
+}
+
+func (d *Digester) write(ctx context.Context, res *Result) error {
+	s := d.Settings()
+	if s.VaultDir == "" {
+		return ErrNoVault
+	}
+	if s.Client == nil {
+		return ErrNoLLM
+	}
+	notes, err := d.Notes(res.From, res.To)
+	if err != nil {
+		return fmt.Errorf("read notes: %w", err)
+	}
+	res.Notes = len(notes)
+	if len(notes) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+	span := describeSpan(res.From, res.To)
+	summary, err := summarize(ctx, s.Client, span, notes)
+	if err != nil {
+		return fmt.Errorf("summarize: %w", err)
+	}
+
+	dir := filepath.Join(s.VaultDir, filepath.FromSlash(s.Folder))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create digest folder: %w", err)
+	}
+	title := Title(res.Period)
+	last := res.To.Add(-time.Nanosecond) // the last day covered
+	path := filepath.Join(dir, fmt.Sprintf("%s %s.md", title, last.Format("2006-01-02")))
+	if _, err := vault.WriteVerified(path, render(title, res, span, summary, s.VaultDir, notes)); err != nil {
+		return fmt.Errorf("write digest: %w", err)
+	}
+	res.File = path
+	d.Logger.Info("digest written", "file", path, "period", res.Period, "notes", len(notes))
+	return nil
+}
+
+// Title is a digest note's title, and the start of its file name.
+func Title(period string) string {
+	if period == PeriodDaily {
+		return "Captain's Log — Daily Summary"
+	}
+	return "Captain's Log — Weekly Summary"
+}
+
+// describeSpan names the period for the prompt: "Monday 2026-03-02" or
+// "2026-03-02 to 2026-03-08".
+func describeSpan(from, to time.Time) string {
+	last := to.Add(-time.Nanosecond)
+	if from.Format("2006-01-02") == last.Format("2006-01-02") || to.Sub(from) <= 24*time.Hour {
+		return last.Format("Monday 2006-01-02")
+	}
+	return from.Format("2006-01-02") + " to " + last.Format("2006-01-02")
+}
+
+// summarize has the LLM summarize the notes: in one call if they fit,
+// else part by part and then the parts together.
+func summarize(ctx context.Context, client *llm.Client, span string, notes []Note) (string, error) {
+	var parts []string
+	var b strings.Builder
+	for _, n := range notes {
+		text := fmt.Sprintf("## %s — %s\n\n%s\n\n", n.Time.Format("Mon 2006-01-02 15:04"), n.Title, strings.TrimSpace(n.Text))
+		if runes := []rune(text); len(runes) > llm.MaxInputRunes {
+			text = string(runes[:llm.MaxInputRunes])
+		}
+		if b.Len() > 0 && len([]rune(b.String()))+len([]rune(text)) > llm.MaxInputRunes {
+			parts = append(parts, b.String())
+			b.Reset()
+		}
+		b.WriteString(text)
+	}
+	parts = append(parts, b.String())
+
+	summaries := make([]string, len(parts))
+	for i, part := range parts {
+		reply, err := client.Chat(ctx,
+			llm.Message{Role: "system", Content: fmt.Sprintf(summaryPrompt, span)},
+			llm.Message{Role: "user", Content: part})
+		if err != nil {
+			return "", err
+		}
+		summaries[i] = strings.TrimSpace(reply)
+	}
+	if len(summaries) == 1 {
+		if summaries[0] == "" {
+			return "", errors.New("llm returned an empty summary")
+		}
+		return summaries[0], nil
+	}
+	merged := strings.Join(summaries, "\n\n---\n\n")
+	if runes := []rune(merged); len(runes) > llm.MaxInputRunes {
+		merged = string(runes[:llm.MaxInputRunes])
+	}
+	reply, err := client.Chat(ctx,
+		llm.Message{Role: "system", Content: fmt.Sprintf(mergePrompt, span)},
+		llm.Message{Role: "user", Content: merged})
+	if err != nil {
+		return "", err
+	}
+	if reply = strings.TrimSpace(reply); reply == "" {
+		return "", errors.New("llm returned an empty summary")
+	}
+	return reply, nil
+}
+
+// render is the digest note: frontmatter, the summary, and a wikilink to
+// each note it covers.
+func render(title string, res *Result, span, summary, vaultDir string, notes []Note) []byte {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("title: " + vault.Quote(title+" · "+span) + "\n")
+	b.WriteString("date: " + res.At.Format("2006-01-02T15:04:05") + "\n")
+	b.WriteString("period: " + res.Period + "\n")
+	b.WriteString("from: " + res.From.Format("2006-01-02T15:04:05") + "\n")
+	b.WriteString("to: " + res.To.Format("2006-01-02T15:04:05") + "\n")
+	b.WriteString(fmt.Sprintf("notes: %d\n", len(notes)))
+	b.WriteString("tags: " + vault.FormatTags([]string{"digest", res.Period}) + "\n")
+	b.WriteString("---\n\n")
+	b.WriteString("# " + title + "\n\n")
+	b.WriteString("*" + span + "*\n\n")
+	b.WriteString(summary + "\n\n")
+	b.WriteString("## Notes\n\n")
+	for _, n := range notes {
+		link := n.File
+		if rel, err := filepath.Rel(vaultDir, n.File); err == nil && filepath.IsLocal(rel) {
+			link = filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+		}
+		b.WriteString(fmt.Sprintf("- %s [[%s]]\n", n.Time.Format("Mon 15:04"), link))
+	}
+	return []byte(b.String())
+}
+
+// RunScheduled runs the scheduled digest whenever the schedule matches,
+// checking every minute until ctx is canceled.
+func (d *Digester) RunScheduled(ctx context.Context) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	var lastMinute time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			minute := now.Truncate(time.Minute)
+			if minute.Equal(lastMinute) {
+				continue
+			}
+			lastMinute = minute
+			s := d.Settings()
+			if s.Schedule == "" {
+				continue
+			}
+			sched, err := ParseSchedule(s.Schedule)
+			if err != nil || !sched.Match(now) {
+				continue
+			}
+			from, to := Window(s.Period, minute)
+			if _, err := d.Run(ctx, s.Period, from, to); err != nil {
+				d.Logger.Warn("scheduled digest failed", "period", s.Period, "error", err)
+			}
+		}
+	}
+}
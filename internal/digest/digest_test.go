@@ -0,0 +1,118 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+// fakeLLM answers every chat request with reply, counting the calls.
+func fakeLLM(t *testing.T, reply string, calls *atomic.Int32) *llm.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return llm.New(srv.URL, "test")
+}
+
+func newDigester(dir string, client *llm.Client, notes []Note) *Digester {
+	return &Digester{
+		Settings: func() Settings {
+			return Settings{VaultDir: dir, Folder: "Digests", Client: client, Period: PeriodWeekly}
+		},
+		Notes:  func(from, to time.Time) ([]Note, error) { return notes, nil },
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+	client := fakeLLM(t, "A week of release planning.\n\n- Ship Friday", &calls)
+	to := time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local)
+	from := to.AddDate(0, 0, -7)
+	notes := []Note{
+		{File: filepath.Join(dir, "Captain's Log 2026-03-03.md"), Time: from.Add(30 * time.Hour), Title: "Planning", Text: "We ship Friday."},
+		{File: filepath.Join(dir, "2026", "Standup.md"), Time: from.Add(80 * time.Hour), Title: "Standup", Text: "QA signed off."},
+	}
+	d := newDigester(dir, client, notes)
+
+	res, err := d.Run(context.Background(), PeriodWeekly, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 || res.Notes != 2 {
+		t.Errorf("calls = %d, notes = %d", calls.Load(), res.Notes)
+	}
+	want := filepath.Join(dir, "Digests", "Captain's Log — Weekly Summary 2026-03-08.md")
+	if res.File != want {
+		t.Fatalf("file = %q, want %q", res.File, want)
+	}
+	doc, err := vault.ReadDocument(res.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Get("period") != "weekly" || doc.Get("notes") != "2" || !doc.HasTag("digest") {
+		t.Errorf("frontmatter: period %q, notes %q, tags %v", doc.Get("period"), doc.Get("notes"), doc.Tags())
+	}
+	for _, s := range []string{"# Captain's Log — Weekly Summary", "*2026-03-02 to 2026-03-08*", "- Ship Friday", "[[Captain's Log 2026-03-03]]", "[[2026/Standup]]"} {
+		if !strings.Contains(doc.Body, s) {
+			t.Errorf("body lacks %q:\n%s", s, doc.Body)
+		}
+	}
+	if last := d.Last(); last == nil || last.File != want {
+		t.Errorf("last = %+v", last)
+	}
+}
+
+func TestRunLongNotesMerges(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+	long := strings.Repeat("word ", llm.MaxInputRunes/5)
+	notes := []Note{{Time: time.Now(), Text: long}, {Time: time.Now(), Text: long}, {Time: time.Now(), Text: long}}
+	d := newDigester(dir, fakeLLM(t, "Summary.", &calls), notes)
+	if _, err := d.Run(context.Background(), PeriodDaily, time.Now().AddDate(0, 0, -1), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 4 { // one per note, then the merge
+		t.Errorf("calls = %d, want 4", calls.Load())
+	}
+}
+
+func TestRunNothingToDo(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+	d := newDigester(dir, fakeLLM(t, "Summary.", &calls), nil)
+	res, err := d.Run(context.Background(), PeriodWeekly, time.Now().AddDate(0, 0, -7), time.Now())
+	if err != nil || res.File != "" || calls.Load() != 0 {
+		t.Errorf("res = %+v, err = %v, calls = %d", res, err, calls.Load())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Digests")); !os.IsNotExist(err) {
+		t.Errorf("digest folder created without notes: %v", err)
+	}
+
+	d = newDigester(dir, nil, []Note{{Text: "x"}})
+	if _, err := d.Run(context.Background(), PeriodWeekly, time.Now(), time.Now()); !errors.Is(err, ErrNoLLM) {
+		t.Errorf("err = %v, want ErrNoLLM", err)
+	}
+	if last := d.Last(); last == nil || last.Error == "" {
+		t.Errorf("last = %+v", last)
+	}
+}
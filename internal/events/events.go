@@ -0,0 +1,266 @@
+// Package events defines the versioned payload schema shared by every
+// outbound integration (webhooks, SSE) and a small in-process bus to fan
+// events out to them.
+//
+// Compatibility guarantee for SchemaVersion "MAJOR.MINOR":
+//   - MINOR bumps only ADD: new event types or new optional data fields.
+//     Existing fields keep their name, type, and meaning.
+//   - MAJOR bumps may rename, remove, or retype fields. They are announced in
+//     the release notes and the schema endpoint reports the new major.
+//
+// Consumers should ignore unknown fields and unknown event types, and check
+// that the major part of schema_version is one they understand.
+package events
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// SchemaVersion is the version of the envelope and all event payloads.
+const SchemaVersion = "1.4"
+
+// Event types. Names are "<noun>.<past-tense verb>" and never change within
+// a major schema version.
+const (
+	TypeTranscriptionCompleted = "transcription.completed"
+	TypeTranscriptionFailed    = "transcription.failed"
+	TypeVaultSaved             = "vault.saved"
+	TypeWebhookTest            = "webhook.test"
+	TypeCertificateRotated     = "certificate.rotated"
+	TypeKeywordMatched         = "keyword.matched"
+	TypeTranscriptRefined      = "transcript.refined"
+)
+
+// Envelope wraps every event payload.
+type Envelope struct {
+	SchemaVersion string `json:"schema_version" desc:"Payload schema version (MAJOR.MINOR)"`
+	ID            string `json:"id" desc:"Unique event ID — use it to de-duplicate retried deliveries"`
+	Type          string `json:"type" desc:"Event type, e.g. transcription.completed"`
+	Source        string `json:"source" desc:"Component that emitted the event (watcher, vault, api)"`
+	Time          string `json:"time" desc:"When the event happened (RFC 3339, UTC)"`
+	Data          any    `json:"data" desc:"Type-specific payload, see types"`
+}
+
+// TranscriptionCompleted is the data of a transcription.completed event.
+type TranscriptionCompleted struct {
+	Filename string `json:"filename" desc:"Name of the transcribed audio file"`
+	Text     string `json:"text" desc:"Transcribed text (truncated when the server runs in privacy mode)"`
+	TextHash string `json:"text_sha256,omitempty" desc:"Short SHA-256 fingerprint of the full text; set in privacy mode"`
+	Language string `json:"language,omitempty" desc:"Language code used for transcription"`
+	SavedTo  string `json:"saved_to,omitempty" desc:"Vault file the transcript was written to, if any"`
+}
+
+// TranscriptionFailed is the data of a transcription.failed event.
+type TranscriptionFailed struct {
+	Filename string `json:"filename" desc:"Name of the audio file"`
+	Error    string `json:"error" desc:"Human-readable failure reason"`
+}
+
+// VaultSaved is the data of a vault.saved event.
+type VaultSaved struct {
+	Path     string `json:"path" desc:"Absolute path of the saved markdown file"`
+	Chars    int    `json:"chars" desc:"Length of the saved text in characters"`
+	Language string `json:"language,omitempty" desc:"Language code of the text"`
+}
+
+// WebhookTest is the data of a webhook.test event.
+type WebhookTest struct {
+	Message string `json:"message" desc:"Fixed greeting — confirms delivery and signature checking work"`
+}
+
+// CertificateRotated is the data of a certificate.rotated event.
+type CertificateRotated struct {
+	Reason              string   `json:"reason" desc:"Why it was replaced: expiring, invalid, key type or missing SANs"`
+	Missing             []string `json:"missing,omitempty" desc:"Hostnames and IPs the old certificate didn't cover"`
+	Fingerprint         string   `json:"fingerprint_sha256" desc:"SHA-256 fingerprint of the new certificate, as browsers show it"`
+	PreviousFingerprint string   `json:"previous_fingerprint_sha256,omitempty" desc:"SHA-256 fingerprint of the certificate it replaced"`
+	Expires             string   `json:"expires" desc:"When the new certificate expires (RFC 3339, UTC)"`
+	Retrust             bool     `json:"retrust" desc:"Devices must trust the new certificate; false when the local CA they already trust issued it"`
+}
+
+// KeywordMatched is the data of a keyword.matched event.
+type KeywordMatched struct {
+	Keyword      string   `json:"keyword" desc:"The watch keyword, as configured"`
+	Count        int      `json:"count" desc:"How often the transcript mentions it"`
+	Snippet      string   `json:"snippet,omitempty" desc:"The first mention with the text around it; left out in privacy mode"`
+	Start        *float64 `json:"start,omitempty" desc:"When the first mention was said, in seconds into the recording, when the transcript has timings"`
+	TranscriptID string   `json:"transcript_id,omitempty" desc:"Transcript index ID of the note"`
+	SavedTo      string   `json:"saved_to,omitempty" desc:"Vault file the transcript was written to"`
+	Filename     string   `json:"filename,omitempty" desc:"Name of the transcribed audio file, when known"`
+}
+
+// TranscriptRefined is the data of a transcript.refined event.
+type TranscriptRefined struct {
+	TranscriptID string `json:"transcript_id" desc:"Transcript index ID of the note"`
+	SavedTo      string `json:"saved_to" desc:"Vault file the transcript was written to"`
+	DraftModel   string `json:"draft_model" desc:"Model of the draft that was saved first"`
+	Model        string `json:"model" desc:"Model of the refined transcription"`
+	Changes      int    `json:"changes" desc:"Runs of words the refined text changed; 0 when both models heard the same"`
+	Diff         string `json:"diff,omitempty" desc:"The changes, one per line (\"draft\" → \"refined\", + added, − dropped); left out in privacy mode"`
+	Applied      bool   `json:"applied" desc:"Whether the note now holds the refined text; false when it was edited after the draft was saved"`
+}
+
+// registry maps each event type to its description and payload struct.
+var registry = map[string]struct {
+	desc string
+	data any
+}{
+	TypeTranscriptionCompleted: {"An audio file finished transcribing", TranscriptionCompleted{}},
+	TypeTranscriptionFailed:    {"An audio file could not be transcribed", TranscriptionFailed{}},
+	TypeVaultSaved:             {"A transcript was saved to the vault", VaultSaved{}},
+	TypeWebhookTest:            {"Sent on demand to test webhook configuration", WebhookTest{}},
+	TypeCertificateRotated:     {"The self-signed TLS certificate was regenerated", CertificateRotated{}},
+	TypeKeywordMatched:         {"A new transcript mentions a watch keyword", KeywordMatched{}},
+	TypeTranscriptRefined:      {"A draft transcript was transcribed again with the large model", TranscriptRefined{}},
+}
+
+// New builds an envelope for the given type and payload.
+func New(typ, source string, data any) Envelope {
+	return Envelope{
+		SchemaVersion: SchemaVersion,
+		ID:            httputil.EventID.New(),
+		Type:          typ,
+		Source:        source,
+		Time:          time.Now().UTC().Format(time.RFC3339),
+		Data:          data,
+	}
+}
+
+// Bus fans events out to subscribers. Subscribers run synchronously in
+// Publish, so they must not block — hand slow work (HTTP) to a goroutine.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []func(Envelope)
+}
+
+// Subscribe registers fn to receive every published event.
+func (b *Bus) Subscribe(fn func(Envelope)) {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+}
+
+// Publish delivers env to all subscribers. A nil Bus drops the event.
+func (b *Bus) Publish(env Envelope) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(env)
+	}
+}
+
+// Field describes one JSON field in the schema document.
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+// TypeSchema describes one event type.
+type TypeSchema struct {
+	Type        string  `json:"type"`
+	Description string  `json:"description"`
+	Data        []Field `json:"data"`
+}
+
+// Document is the machine-readable schema served at /api/events/schema.
+type Document struct {
+	SchemaVersion string       `json:"schema_version"`
+	Compatibility []string     `json:"compatibility"`
+	Envelope      []Field      `json:"envelope"`
+	Types         []TypeSchema `json:"types"`
+	Signature     SignatureDoc `json:"signature"`
+}
+
+// SignatureDoc describes how webhook deliveries are signed.
+type SignatureDoc struct {
+	Header    string   `json:"header"`
+	Format    string   `json:"format"`
+	Algorithm string   `json:"algorithm"`
+	Signed    string   `json:"signed_payload"`
+	Headers   []string `json:"other_headers"`
+}
+
+// Schema returns the schema document. It is generated from the payload
+// structs so it can't drift from what is actually sent.
+func Schema() Document {
+	doc := Document{
+		SchemaVersion: SchemaVersion,
+		Compatibility: []string{
+			"Minor versions only add event types or optional fields; existing fields never change.",
+			"Major versions may change or remove fields and are announced in the release notes.",
+			"Consumers should ignore unknown fields and unknown event types.",
+			"Deliveries may be retried; de-duplicate on the envelope id.",
+		},
+		Envelope: fields(reflect.TypeOf(Envelope{})),
+		Signature: SignatureDoc{
+			Header:    SignatureHeader,
+			Format:    "t=<unix seconds>,v1=<hex signature>",
+			Algorithm: "HMAC-SHA256 with CAPTAINSLOG_WEBHOOK_SECRET",
+			Signed:    "<t> + \".\" + <raw request body>",
+			Headers:   []string{EventHeader, SchemaHeader, DeliveryHeader},
+		},
+	}
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := registry[name]
+		doc.Types = append(doc.Types, TypeSchema{
+			Type:        name,
+			Description: r.desc,
+			Data:        fields(reflect.TypeOf(r.data)),
+		})
+	}
+	return doc
+}
+
+func fields(t reflect.Type) []Field {
+	var out []Field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		out = append(out, Field{
+			Name:        name,
+			Type:        jsonType(f.Type),
+			Required:    !strings.Contains(opts, "omitempty"),
+			Description: f.Tag.Get("desc"),
+		})
+	}
+	return out
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "object"
+}
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewEnvelope(t *testing.T) {
+	env := New(TypeVaultSaved, "vault", VaultSaved{Path: "/v/a.md", Chars: 3})
+	if env.SchemaVersion != SchemaVersion || env.Type != TypeVaultSaved || env.Source != "vault" {
+		t.Errorf("envelope = %+v", env)
+	}
+	if !strings.HasPrefix(env.ID, "evt_") || env.ID == New(TypeVaultSaved, "vault", nil).ID {
+		t.Errorf("ID = %q, want unique evt_ prefix", env.ID)
+	}
+	if _, err := time.Parse(time.RFC3339, env.Time); err != nil {
+		t.Errorf("Time = %q: %v", env.Time, err)
+	}
+}
+
+func TestSchemaCoversAllTypes(t *testing.T) {
+	doc := Schema()
+	if doc.SchemaVersion != SchemaVersion {
+		t.Errorf("schema_version = %q", doc.SchemaVersion)
+	}
+	if len(doc.Types) != len(registry) {
+		t.Fatalf("types = %d, want %d", len(doc.Types), len(registry))
+	}
+	for _, ts := range doc.Types {
+		if len(ts.Data) == 0 {
+			t.Errorf("type %s has no data fields", ts.Type)
+		}
+		for _, f := range ts.Data {
+			if f.Description == "" {
+				t.Errorf("%s.%s has no description", ts.Type, f.Name)
+			}
+		}
+	}
+}
+
+func TestSchemaFieldRequired(t *testing.T) {
+	var completed TypeSchema
+	for _, ts := range Schema().Types {
+		if ts.Type == TypeTranscriptionCompleted {
+			completed = ts
+		}
+	}
+	req := map[string]bool{}
+	for _, f := range completed.Data {
+		req[f.Name] = f.Required
+	}
+	if !req["text"] || req["saved_to"] {
+		t.Errorf("required flags = %v; text required, saved_to optional", req)
+	}
+}
+
+func TestBus(t *testing.T) {
+	var b Bus
+	var got []string
+	b.Subscribe(func(e Envelope) { got = append(got, "a:"+e.Type) })
+	b.Subscribe(func(e Envelope) { got = append(got, "b:"+e.Type) })
+	b.Publish(New("x.done", "test", nil))
+	if strings.Join(got, ",") != "a:x.done,b:x.done" {
+		t.Errorf("got %v", got)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(New("x.done", "test", nil)) // must not panic
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"webhook.test"}`)
+	sig := Sign("s3cret", time.Now(), body)
+
+	if err := Verify("s3cret", sig, body, 5*time.Minute); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := Verify("wrong", sig, body, 5*time.Minute); err == nil {
+		t.Error("Verify should fail with the wrong secret")
+	}
+	if err := Verify("s3cret", sig, []byte(`{"type":"tampered"}`), 5*time.Minute); err == nil {
+		t.Error("Verify should fail for a modified body")
+	}
+	old := Sign("s3cret", time.Now().Add(-time.Hour), body)
+	if err := Verify("s3cret", old, body, 5*time.Minute); err == nil {
+		t.Error("Verify should reject a stale timestamp")
+	}
+	if err := Verify("s3cret", "garbage", body, 0); err == nil {
+		t.Error("Verify should reject a malformed header")
+	}
+}
+
+func testWebhook(url string) *Webhook {
+	wh := NewWebhook([]string{url}, "s3cret", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	wh.backoff = time.Millisecond
+	return wh
+}
+
+func TestWebhookDeliverSigned(t *testing.T) {
+	var gotEvent, gotSchema string
+	var verifyErr error
+	var env Envelope
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotEvent = r.Header.Get(EventHeader)
+		gotSchema = r.Header.Get(SchemaHeader)
+		verifyErr = Verify("s3cret", r.Header.Get(SignatureHeader), body, time.Minute)
+		json.Unmarshal(body, &env)
+	}))
+	defer srv.Close()
+
+	sent := New(TypeWebhookTest, "api", WebhookTest{Message: "hi"})
+	if err := testWebhook(srv.URL).Deliver(context.Background(), srv.URL, sent); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if verifyErr != nil {
+		t.Errorf("receiver signature check: %v", verifyErr)
+	}
+	if gotEvent != TypeWebhookTest || gotSchema != SchemaVersion {
+		t.Errorf("headers: event = %q, schema = %q", gotEvent, gotSchema)
+	}
+	if env.ID != sent.ID || env.SchemaVersion != SchemaVersion {
+		t.Errorf("received envelope = %+v", env)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	if err := testWebhook(srv.URL).Deliver(context.Background(), srv.URL, New(TypeWebhookTest, "api", nil)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if err := testWebhook(srv.URL).Deliver(context.Background(), srv.URL, New(TypeWebhookTest, "api", nil)); err == nil {
+		t.Fatal("Deliver should fail on 401")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1 (no retry on 4xx)", calls.Load())
+	}
+}
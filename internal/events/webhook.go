@@ -0,0 +1,195 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook delivery headers.
+const (
+	SignatureHeader = "X-Captainslog-Signature"
+	EventHeader     = "X-Captainslog-Event"
+	SchemaHeader    = "X-Captainslog-Schema-Version"
+	DeliveryHeader  = "X-Captainslog-Delivery"
+)
+
+// Sign returns the signature header value for body at time ts.
+// The signed payload is "<unix ts>.<body>" so a captured request can't be
+// replayed later with a fresh timestamp.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + mac(secret, t, body)
+}
+
+// Verify checks a signature header produced by Sign. Signatures older (or
+// newer) than tolerance are rejected; tolerance <= 0 disables the check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig = v
+		}
+	}
+	if t == "" || sig == "" {
+		return errors.New("malformed signature header")
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if tolerance > 0 {
+		age := time.Since(time.Unix(unix, 0))
+		if age > tolerance || age < -tolerance {
+			return errors.New("signature timestamp outside tolerance")
+		}
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, t, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func mac(secret, t string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Webhook POSTs events to one or more URLs, signing each delivery when a
+// secret is configured.
+type Webhook struct {
+	urls     []string
+	client   *http.Client
+	logger   *slog.Logger
+	attempts int
+	backoff  time.Duration
+
+	mu     sync.RWMutex
+	secret string
+}
+
+// NewWebhook creates a Webhook for the given URLs. Returns nil if urls is empty.
+func NewWebhook(urls []string, secret string, logger *slog.Logger) *Webhook {
+	if len(urls) == 0 {
+		return nil
+	}
+	return &Webhook{
+		urls:     urls,
+		secret:   secret,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		attempts: 3,
+		backoff:  time.Second,
+	}
+}
+
+// SetSecret replaces the signing secret, e.g. after a secret file rotates.
+func (wh *Webhook) SetSecret(secret string) {
+	wh.mu.Lock()
+	wh.secret = secret
+	wh.mu.Unlock()
+}
+
+// Secret returns the current signing secret.
+func (wh *Webhook) Secret() string {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	return wh.secret
+}
+
+// URLs returns the configured webhook URLs.
+func (wh *Webhook) URLs() []string { return wh.urls }
+
+// Handle delivers env to every URL in the background. Its signature matches
+// Bus.Subscribe.
+func (wh *Webhook) Handle(env Envelope) {
+	for _, u := range wh.urls {
+		go func(url string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			if err := wh.Deliver(ctx, url, env); err != nil {
+				wh.logger.Warn("webhook delivery failed", "url", url, "event", env.Type, "id", env.ID, "error", err)
+			}
+		}(u)
+	}
+}
+
+// Deliver POSTs env to url, retrying 5xx responses and network errors with
+// exponential backoff. 4xx responses are not retried — the receiver has
+// rejected the payload and sending it again won't help.
+func (wh *Webhook) Deliver(ctx context.Context, url string, env Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	delay := wh.backoff
+	for attempt := 1; ; attempt++ {
+		err = wh.post(ctx, url, env, body)
+		var perm permanentError
+		if err == nil || errors.As(err, &perm) || attempt >= wh.attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// permanentError marks a delivery failure that retrying won't fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+func (wh *Webhook) post(ctx context.Context, url string, env Envelope, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "captainslog-webhook/"+SchemaVersion)
+	req.Header.Set(EventHeader, env.Type)
+	req.Header.Set(SchemaHeader, env.SchemaVersion)
+	req.Header.Set(DeliveryHeader, env.ID)
+	if secret := wh.Secret(); secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return permanentError{fmt.Errorf("receiver returned %d", resp.StatusCode)}
+	}
+	return fmt.Errorf("receiver returned %d", resp.StatusCode)
+}
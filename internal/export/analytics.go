@@ -0,0 +1,153 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// interruptGap is the longest pause after an unfinished sentence that
+// still counts as the next speaker cutting in.
+const interruptGap = 0.5
+
+// Analytics describes how a diarized conversation went: who spoke how
+// much, and how much of it was nobody speaking. Times are seconds.
+type Analytics struct {
+	Duration      float64        `json:"duration"` // to the end of the last segment
+	Speech        float64        `json:"speech"`   // someone speaking, overlaps counted once
+	Silence       float64        `json:"silence"`
+	SilenceRatio  float64        `json:"silence_ratio"` // Silence / Duration, 0 to 1
+	Turns         int            `json:"turns"`
+	Interruptions int            `json:"interruptions"`
+	Speakers      []SpeakerStats `json:"speakers"` // in the order they first spoke
+}
+
+// SpeakerStats is one speaker's share of the conversation.
+type SpeakerStats struct {
+	Speaker  string  `json:"speaker"`   // as the minutes name them: "Speaker 1", or the name given
+	TalkTime float64 `json:"talk_time"` // the length of their segments
+	Share    float64 `json:"share"`     // of everyone's talk time, 0 to 1
+	Turns    int     `json:"turns"`
+	Words    int     `json:"words"`
+	// LongestMonologue is their longest turn: from its first segment's
+	// start to its last one's end.
+	LongestMonologue float64 `json:"longest_monologue"`
+	// Interruptions counts the turns they began by cutting someone off:
+	// before that speaker's segment ended, or within half a second of
+	// a sentence that ended without punctuation. Interrupted counts the
+	// times it happened to them.
+	Interruptions int `json:"interruptions"`
+	Interrupted   int `json:"interrupted"`
+}
+
+// Analyze returns the speaker statistics of segments, or nil when none of
+// them has a speaker label (the transcript was not diarized). Segments
+// without text are left out, as in the minutes.
+func Analyze(segments []Segment) *Analytics {
+	d := Doc{Segments: segments}
+	turns := d.turns()
+	a := &Analytics{Turns: len(turns)}
+	index := map[string]int{}
+	speaker := func(name string) *SpeakerStats {
+		i, ok := index[name]
+		if !ok {
+			i = len(a.Speakers)
+			index[name] = i
+			a.Speakers = append(a.Speakers, SpeakerStats{Speaker: name})
+		}
+		return &a.Speakers[i]
+	}
+
+	type span struct{ start, end float64 }
+	var spans []span
+	var talk float64
+	for _, s := range segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		length := max(s.End-s.Start, 0)
+		spans = append(spans, span{s.Start, s.Start + length})
+		a.Duration = max(a.Duration, s.End)
+		name := speakerName(s.Speaker)
+		if name == "" {
+			continue
+		}
+		st := speaker(name)
+		st.TalkTime += length
+		st.Words += len(strings.Fields(text))
+		talk += length
+	}
+	if len(a.Speakers) == 0 {
+		return nil
+	}
+
+	for i, t := range turns {
+		if t.speaker == "" {
+			continue
+		}
+		st := speaker(t.speaker)
+		st.Turns++
+		st.LongestMonologue = max(st.LongestMonologue, t.end-t.start)
+		if i == 0 {
+			continue
+		}
+		prev := turns[i-1]
+		if prev.speaker == "" {
+			continue
+		}
+		if t.start < prev.end || (t.start-prev.end <= interruptGap && !endsSentence(prev.text)) {
+			st.Interruptions++
+			speaker(prev.speaker).Interrupted++
+			a.Interruptions++
+		}
+	}
+	for i := range a.Speakers {
+		if talk > 0 {
+			a.Speakers[i].Share = a.Speakers[i].TalkTime / talk
+		}
+	}
+
+	// Speech is the union of the segments: two people talking at once
+	// is not twice the speech.
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var cur span
+	for i, s := range spans {
+		if i > 0 && s.start <= cur.end {
+			cur.end = max(cur.end, s.end)
+			continue
+		}
+		a.Speech += cur.end - cur.start
+		cur = s
+	}
+	a.Speech += cur.end - cur.start
+	a.Silence = max(a.Duration-a.Speech, 0)
+	if a.Duration > 0 {
+		a.SilenceRatio = a.Silence / a.Duration
+	}
+	return a
+}
+
+// endsSentence reports whether text ends with a full stop, question or
+// exclamation mark.
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), `"')”’»`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") ||
+		strings.HasSuffix(text, "!") || strings.HasSuffix(text, "…")
+}
+
+// speakingTime writes the minutes' table of who spoke how much.
+func speakingTime(b *strings.Builder, a *Analytics) {
+	b.WriteString("## Speaking time\n\n")
+	b.WriteString("| Speaker | Talk time | Share | Turns | Longest monologue | Interruptions |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, s := range a.Speakers {
+		fmt.Fprintf(b, "| %s | %s | %.0f%% | %d | %s | %d |\n",
+			s.Speaker, clock(s.TalkTime), 100*s.Share, s.Turns, clock(s.LongestMonologue), s.Interruptions)
+	}
+	interruptions := "interruptions"
+	if a.Interruptions == 1 {
+		interruptions = "interruption"
+	}
+	fmt.Fprintf(b, "\nSilence: %.0f%% · %d %s in %d turns\n\n", 100*a.SilenceRatio, a.Interruptions, interruptions, a.Turns)
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// A DOCX file is a zip of XML parts. These are the parts Word, LibreOffice
+// and Google Docs need to open one: the content types, the package
+// relationships, the document, and the core properties (title, date).
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+		`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+		`</Types>`
+
+	docxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+		`</Relationships>`
+)
+
+// run styles for docx paragraphs.
+const (
+	runTitle = `<w:rPr><w:b/><w:sz w:val="32"/></w:rPr>`
+	runMeta  = `<w:rPr><w:color w:val="808080"/><w:sz w:val="20"/></w:rPr>`
+	runTime  = `<w:rPr><w:color w:val="808080"/></w:rPr>`
+)
+
+func docx(d Doc) ([]byte, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	para(&body, run(runTitle, d.heading()))
+	if meta := d.meta(); meta != "" {
+		para(&body, run(runMeta, meta))
+	}
+	for _, line := range d.lines() {
+		// The timestamp prefix from lines() is set in grey.
+		if ts, rest, ok := strings.Cut(line, "] "); ok && d.Timestamps && strings.HasPrefix(ts, "[") {
+			para(&body, run(runTime, ts+"] ")+run("", rest))
+			continue
+		}
+		para(&body, run("", line))
+	}
+	body.WriteString(`<w:sectPr/></w:body></w:document>`)
+
+	created := d.Date
+	if created.IsZero() {
+		created = time.Now()
+	}
+	core := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<dc:title>` + escape(d.heading()) + `</dc:title>` +
+		`<dc:language>` + escape(d.Language) + `</dc:language>` +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + created.UTC().Format(time.RFC3339) + `</dcterms:created>` +
+		`</cp:coreProperties>`
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, data string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRels},
+		{"word/document.xml", body.String()},
+		{"docProps/core.xml", core},
+	} {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.data)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func para(b *strings.Builder, runs string) {
+	b.WriteString("<w:p>" + runs + "</w:p>")
+}
+
+func run(props, text string) string {
+	return `<w:r>` + props + `<w:t xml:space="preserve">` + escape(text) + `</w:t></w:r>`
+}
+
+// escape makes text safe for XML character data. Characters XML can't
+// hold (control codes) become U+FFFD.
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,243 @@
+// Package export renders a transcript as a downloadable file: plain text,
+// markdown, JSON, subtitles (SRT, WebVTT, LRC), Word (DOCX), PDF, meeting
+// minutes or flashcards.
+//
+// The browser has always exported the text formats itself; this package
+// lets the server do it too, for clients without that code (the CLI,
+// scripts, other apps) and for the formats a browser can't easily build.
+// DOCX and PDF are written directly — a zip of four XML parts, and a
+// one-font PDF — rather than through a library, which keeps the binary's
+// dependency list as short as it is.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formats lists the supported formats, by file extension except for
+// "minutes" and "flashcards" (see Extension).
+var Formats = []string{"txt", "md", "json", "srt", "vtt", "lrc", "docx", "pdf", "minutes", "flashcards"}
+
+// ErrUnknownFormat is returned by Render for a format not in Formats.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+var contentTypes = map[string]string{
+	"txt":  "text/plain; charset=utf-8",
+	"md":   "text/markdown; charset=utf-8",
+	"json": "application/json",
+	"srt":  "application/x-subrip",
+	"vtt":  "text/vtt; charset=utf-8",
+	"lrc":  "application/x-lrc",
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"pdf":  "application/pdf",
+	// Minutes are markdown.
+	"minutes": "text/markdown; charset=utf-8",
+	// Flashcards are tab-separated, for Anki.
+	"flashcards": "text/tab-separated-values; charset=utf-8",
+}
+
+// ContentType returns the MIME type for format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Extension returns the file extension for format, without the dot.
+func Extension(format string) string {
+	switch format {
+	case "minutes":
+		return "md"
+	case "flashcards":
+		return "tsv"
+	}
+	return format
+}
+
+// Segment is one timed span of the transcript.
+type Segment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+	Speaker string  `json:"speaker,omitempty"`
+}
+
+// Doc is a transcript to export.
+type Doc struct {
+	Title    string
+	Date     time.Time
+	Language string
+	Text     string
+	Segments []Segment
+	// Timestamps writes the document formats (txt, md, docx, pdf) as one
+	// "[mm:ss] text" line per segment instead of the plain text. Ignored
+	// without segments.
+	Timestamps bool
+	// Subtitles are the cue rules of srt and vtt.
+	Subtitles Subtitles
+	// Cards are the sentences and their translations, for flashcards.
+	Cards []Card
+}
+
+// Render returns d in format.
+func Render(format string, d Doc) ([]byte, error) {
+	switch format {
+	case "txt":
+		return []byte(strings.Join(d.lines(), "\n") + "\n"), nil
+	case "md":
+		return markdown(d), nil
+	case "json":
+		return jsonDoc(d)
+	case "srt":
+		return subtitles(d, ","), nil
+	case "vtt":
+		return append([]byte("WEBVTT\n\n"), subtitles(d, ".")...), nil
+	case "lrc":
+		return lrc(d), nil
+	case "docx":
+		return docx(d)
+	case "pdf":
+		return pdf(d), nil
+	case "minutes":
+		return minutes(d), nil
+	case "flashcards":
+		return flashcards(d)
+	}
+	return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownFormat, format, strings.Join(Formats, ", "))
+}
+
+// lines returns the body as paragraphs: one per segment with its start
+// time when Timestamps is set, otherwise the text's own lines.
+func (d Doc) lines() []string {
+	if d.Timestamps && len(d.Segments) > 0 {
+		out := make([]string, 0, len(d.Segments))
+		for _, s := range d.Segments {
+			line := "[" + clock(s.Start) + "] "
+			if s.Speaker != "" {
+				line += s.Speaker + ": "
+			}
+			out = append(out, line+strings.TrimSpace(s.Text))
+		}
+		return out
+	}
+	return strings.Split(strings.TrimSpace(d.Text), "\n")
+}
+
+// heading is the title line of the document formats.
+func (d Doc) heading() string {
+	if d.Title != "" {
+		return d.Title
+	}
+	return "Transcript"
+}
+
+// meta is the line under the heading: date and language.
+func (d Doc) meta() string {
+	var parts []string
+	if !d.Date.IsZero() {
+		parts = append(parts, d.Date.Format("2006-01-02 15:04"))
+	}
+	if d.Language != "" {
+		parts = append(parts, d.Language)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// clock formats seconds as m:ss, or h:mm:ss from an hour on.
+func clock(sec float64) string {
+	t := int(max(sec, 0))
+	if t >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", t/3600, t/60%60, t%60)
+	}
+	return fmt.Sprintf("%02d:%02d", t/60, t%60)
+}
+
+func markdown(d Doc) []byte {
+	var b strings.Builder
+	b.WriteString("---\ntitle: " + d.heading() + "\n")
+	if !d.Date.IsZero() {
+		b.WriteString("date: " + d.Date.Format("2006-01-02T15:04:05") + "\n")
+	}
+	if d.Language != "" {
+		b.WriteString("language: " + d.Language + "\n")
+	}
+	b.WriteString("tags: [dictation]\n---\n\n")
+	b.WriteString(strings.Join(d.lines(), "\n") + "\n")
+	return []byte(b.String())
+}
+
+func jsonDoc(d Doc) ([]byte, error) {
+	out := struct {
+		Title     string     `json:"title,omitempty"`
+		Date      string     `json:"timestamp,omitempty"`
+		Language  string     `json:"language,omitempty"`
+		Text      string     `json:"text"`
+		Segments  []Segment  `json:"segments,omitempty"`
+		Analytics *Analytics `json:"analytics,omitempty"` // diarized transcripts only
+	}{d.Title, "", d.Language, strings.TrimSpace(d.Text), d.Segments, Analyze(d.Segments)}
+	if !d.Date.IsZero() {
+		out.Date = d.Date.Format(time.RFC3339)
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// subtitles renders SRT cues (sep ",") or WebVTT cues (sep "."). Without
+// segments the whole text is one ten-second cue, as in the browser export.
+func subtitles(d Doc, sep string) []byte {
+	segs := d.cues()
+	width := d.Subtitles.lineChars()
+	cues := make([]string, len(segs))
+	for i, s := range segs {
+		text := s.Text
+		if text == "" {
+			text = "..."
+		}
+		cues[i] = fmt.Sprintf("%d\n%s --> %s\n%s", i+1, cueTime(s.Start, sep), cueTime(s.End, sep), wrap(text, width))
+	}
+	return []byte(strings.Join(cues, "\n\n") + "\n")
+}
+
+func cueTime(sec float64, sep string) string {
+	ms := int64(max(sec, 0)*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// wrap breaks text into lines of at most width characters at spaces, the
+// usual limit for subtitles. Words longer than width stay whole.
+func wrap(text string, width int) string {
+	return strings.Join(wrapLines(text, width), "\n")
+}
+
+func wrapLines(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, w := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(w)) > width {
+			lines = append(lines, line)
+			line = w
+			continue
+		}
+		if line != "" {
+			line += " "
+		}
+		line += w
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func lrc(d Doc) []byte {
+	if len(d.Segments) == 0 {
+		return []byte("[00:00.00] " + strings.TrimSpace(d.Text) + "\n")
+	}
+	var b strings.Builder
+	for _, s := range d.Segments {
+		cs := int64(max(s.Start, 0)*100 + 0.5)
+		fmt.Fprintf(&b, "[%02d:%02d.%02d] %s\n", cs/6000, cs/100%60, cs%100, strings.TrimSpace(s.Text))
+	}
+	return []byte(b.String())
+}
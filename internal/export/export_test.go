@@ -0,0 +1,247 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sample = Doc{
+	Title:    "Standup",
+	Date:     time.Date(2026, 3, 5, 9, 30, 0, 0, time.UTC),
+	Language: "en",
+	Text:     "Ship the release. Then <lunch> & coffee.",
+	Segments: []Segment{
+		{Start: 0, End: 2.5, Text: " Ship the release."},
+		{Start: 3725.25, End: 3730, Text: "Then <lunch> & coffee.", Speaker: "Ana"},
+	},
+}
+
+func TestRenderText(t *testing.T) {
+	tests := []struct {
+		format     string
+		timestamps bool
+		want       string
+	}{
+		{"txt", false, "Ship the release. Then <lunch> & coffee.\n"},
+		{"txt", true, "[00:00] Ship the release.\n[1:02:05] Ana: Then <lunch> & coffee.\n"},
+		{"srt", false, "1\n00:00:00,000 --> 00:00:02,500\nShip the release.\n\n2\n01:02:05,250 --> 01:02:10,000\nThen <lunch> & coffee.\n"},
+		{"vtt", false, "WEBVTT\n\n1\n00:00:00.000 --> 00:00:02.500\nShip the release.\n\n2\n01:02:05.250 --> 01:02:10.000\nThen <lunch> & coffee.\n"},
+		{"lrc", false, "[00:00.00] Ship the release.\n[62:05.25] Then <lunch> & coffee.\n"},
+	}
+	for _, tt := range tests {
+		d := sample
+		d.Timestamps = tt.timestamps
+		got, err := Render(tt.format, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s (timestamps %v) =\n%q\nwant\n%q", tt.format, tt.timestamps, got, tt.want)
+		}
+	}
+
+	if _, err := Render("odt", sample); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("odt err = %v", err)
+	}
+}
+
+func TestSubtitlesWithoutSegments(t *testing.T) {
+	got, _ := Render("srt", Doc{Text: "a fairly long sentence that has to be wrapped onto two lines"})
+	want := "1\n00:00:00,000 --> 00:00:10,000\na fairly long sentence that has to be\nwrapped onto two lines\n"
+	if string(got) != want {
+		t.Errorf("srt = %q", got)
+	}
+}
+
+func TestDocx(t *testing.T) {
+	d := sample
+	d.Timestamps = true
+	data, err := Render("docx", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml", "docProps/core.xml"} {
+		if parts[name] == "" {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	doc := parts["word/document.xml"]
+	for _, want := range []string{">Standup<", ">[1:02:05] <", ">Ana: Then &lt;lunch&gt; &amp; coffee.<"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document.xml lacks %q", want)
+		}
+	}
+}
+
+func TestPDF(t *testing.T) {
+	d := Doc{Title: "Long (note)", Text: strings.Repeat("Grüße – “quoted” word ", 3000)}
+	data, err := Render("pdf", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF")
+	}
+	// Every xref offset points at its object.
+	xref := bytes.LastIndex(data, []byte("\nxref\n")) + 1
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, data[off:off+10])
+		}
+	}
+	if n := bytes.Count(data, []byte("/Type /Page ")); n < 2 {
+		t.Errorf("%d pages, want the long text to span several", n)
+	}
+	if !bytes.Contains(data, []byte("/Title (Long \\(note\\))")) {
+		t.Error("title not escaped")
+	}
+}
+
+func TestEncodeWinAnsi(t *testing.T) {
+	got := encodeWinAnsi("Grüße “hi” — 你")
+	want := []byte{'G', 'r', 0xfc, 0xdf, 'e', ' ', 0x93, 'h', 'i', 0x94, ' ', 0x97, ' ', '?'}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeWinAnsi = %v, want %v", got, want)
+	}
+}
+
+func TestMinutes(t *testing.T) {
+	d := Doc{
+		Title: "Planning",
+		Date:  time.Date(2026, 3, 5, 9, 30, 0, 0, time.UTC),
+		Segments: []Segment{
+			{Start: 0, End: 2, Text: " Morning all.", Speaker: "SPEAKER_01"},
+			{Start: 2, End: 5, Text: "Let's start with the release.", Speaker: "SPEAKER_01"},
+			{Start: 65, End: 70, Text: "QA signed off.", Speaker: "Ana"},
+			{Start: 68, End: 69, Text: "Good.", Speaker: "SPEAKER 3"},
+			{Start: 70, End: 72, Text: " ", Speaker: "SPEAKER_00"},
+			{Start: 72, End: 75, Text: "Then we ship Friday.", Speaker: "SPEAKER_01"},
+		},
+	}
+	got, err := Render("minutes", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\ntitle: Planning\ndate: 2026-03-05T09:30:00\nattendees: [Speaker 2, Ana, Speaker 3]\ntags: [minutes]\n---\n\n" +
+		"# Planning\n\n*2026-03-05 09:30 · 01:15*\n\n" +
+		"## Attendees\n\n- Speaker 2\n- Ana\n- Speaker 3\n\n" +
+		"## Speaking time\n\n" +
+		"| Speaker | Talk time | Share | Turns | Longest monologue | Interruptions |\n|---|---|---|---|---|---|\n" +
+		"| Speaker 2 | 00:08 | 57% | 2 | 00:05 | 0 |\n" +
+		"| Ana | 00:05 | 36% | 1 | 00:05 | 0 |\n" +
+		"| Speaker 3 | 00:01 | 7% | 1 | 00:01 | 1 |\n\n" +
+		"Silence: 83% · 1 interruption in 4 turns\n\n" +
+		"## Discussion\n\n" +
+		"**[00:00] Speaker 2:** Morning all. Let's start with the release.\n\n" +
+		"**[01:05] Ana:** QA signed off.\n\n" +
+		"**[01:08] Speaker 3:** Good.\n\n" +
+		"**[01:12] Speaker 2:** Then we ship Friday.\n"
+	if string(got) != want {
+		t.Errorf("minutes =\n%s\nwant\n%s", got, want)
+	}
+	if ContentType("minutes") != ContentType("md") || Extension("minutes") != "md" {
+		t.Errorf("minutes file: %s, .%s", ContentType("minutes"), Extension("minutes"))
+	}
+
+	got, _ = Render("minutes", Doc{Text: "Just dictation."})
+	if !strings.Contains(string(got), "Not identified") || !strings.HasSuffix(string(got), "## Discussion\n\nJust dictation.\n") {
+		t.Errorf("minutes without segments =\n%s", got)
+	}
+}
+
+func TestFlashcards(t *testing.T) {
+	d := Doc{Language: "es", Cards: []Card{{"Hola.", "Hello."}, {"Dijo \"sí\".", "She said\t\"yes\"."}}}
+	got, err := Render("flashcards", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#separator:tab\n#html:false\n#tags column:3\nHola.\tHello.\tes\n\"Dijo \"\"sí\"\".\"\t\"She said \"\"yes\"\".\"\tes\n"
+	if string(got) != want {
+		t.Errorf("flashcards:\n%s\nwant:\n%s", got, want)
+	}
+	if Extension("flashcards") != "tsv" {
+		t.Errorf("extension = %q", Extension("flashcards"))
+	}
+	if _, err := Render("flashcards", sample); !errors.Is(err, ErrNoCards) {
+		t.Errorf("without cards: %v", err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	a := Analyze([]Segment{
+		{Start: 0, End: 10, Text: "So the plan is", Speaker: "SPEAKER_00"},
+		{Start: 10.2, End: 14, Text: "Sorry, which plan?", Speaker: "SPEAKER_01"},
+		{Start: 13, End: 20, Text: "The launch plan.", Speaker: "SPEAKER_00"},
+		{Start: 20, End: 30, Text: "We move it to May.", Speaker: "SPEAKER_00"},
+		{Start: 40, End: 45, Text: "Fine by me.", Speaker: "SPEAKER_01"},
+	})
+	if a == nil {
+		t.Fatal("Analyze = nil for a diarized transcript")
+	}
+	near := func(got, want float64) bool { return got > want-1e-9 && got < want+1e-9 }
+	if !near(a.Duration, 45) || !near(a.Speech, 34.8) || !near(a.Silence, 10.2) || !near(a.SilenceRatio, 10.2/45) {
+		t.Errorf("duration %v, speech %v, silence %v (%v)", a.Duration, a.Speech, a.Silence, a.SilenceRatio)
+	}
+	if a.Turns != 4 || a.Interruptions != 2 || len(a.Speakers) != 2 {
+		t.Fatalf("analytics = %+v", a)
+	}
+	s1, s2 := a.Speakers[0], a.Speakers[1]
+	// Speaker 2 cut into an unfinished sentence; Speaker 1 talked over the question.
+	if s1.Speaker != "Speaker 1" || !near(s1.TalkTime, 27) || s1.Turns != 2 || !near(s1.LongestMonologue, 17) ||
+		s1.Words != 12 || s1.Interruptions != 1 || s1.Interrupted != 1 {
+		t.Errorf("speaker 1 = %+v", s1)
+	}
+	if s2.Speaker != "Speaker 2" || !near(s2.TalkTime, 8.8) || s2.Turns != 2 || s2.Interruptions != 1 || s2.Interrupted != 1 {
+		t.Errorf("speaker 2 = %+v", s2)
+	}
+	if !near(s1.Share+s2.Share, 1) {
+		t.Errorf("shares %v + %v != 1", s1.Share, s2.Share)
+	}
+
+	if Analyze([]Segment{{Start: 0, End: 3, Text: "Just dictation."}}) != nil {
+		t.Error("Analyze without speaker labels should be nil")
+	}
+}
+
+func TestSubtitleRules(t *testing.T) {
+	d := Doc{
+		Segments: []Segment{
+			{Start: 0, End: 0.3, Text: "So,"},
+			{Start: 0.3, End: 2, Text: "welcome everyone."},
+			{Start: 2, End: 14, Text: "Today we go through the release plan, the open bugs and who is on call over the long weekend."},
+			{Start: 14, End: 14.4, Text: "Right.", Speaker: "Ana"},
+		},
+		Subtitles: Subtitles{MaxLineChars: 32, MaxLines: 2, MinDuration: 1, MaxDuration: 6},
+	}
+	got, err := Render("srt", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:00,000 --> 00:00:02,000\nSo, welcome everyone.\n\n" +
+		"2\n00:00:02,000 --> 00:00:08,000\nToday we go through the release\nplan, the open\n\n" +
+		"3\n00:00:08,000 --> 00:00:14,000\nbugs and who is on call over the\nlong weekend.\n\n" +
+		"4\n00:00:14,000 --> 00:00:15,000\nRight.\n"
+	if string(got) != want {
+		t.Errorf("srt =\n%s\nwant\n%s", got, want)
+	}
+}
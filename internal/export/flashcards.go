@@ -0,0 +1,51 @@
+package export
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoCards is returned by Render for flashcards of a Doc without Cards.
+var ErrNoCards = errors.New("flashcards need a transcript saved with its translation (language-learning mode)")
+
+// Card is one flashcard: a sentence as spoken and its translation.
+type Card struct {
+	Front string `json:"front"`
+	Back  string `json:"back"`
+}
+
+// flashcards writes d's cards for Anki's text import: tab-separated, with
+// the header lines that tell Anki so. The language, if known, tags every
+// card.
+func flashcards(d Doc) ([]byte, error) {
+	if len(d.Cards) == 0 {
+		return nil, ErrNoCards
+	}
+	var b strings.Builder
+	b.WriteString("#separator:tab\n#html:false\n")
+	tag := strings.Join(strings.Fields(d.Language), "_")
+	if tag == "und" {
+		tag = ""
+	}
+	if tag != "" {
+		b.WriteString("#tags column:3\n")
+	}
+	for _, c := range d.Cards {
+		b.WriteString(tsvField(c.Front) + "\t" + tsvField(c.Back))
+		if tag != "" {
+			b.WriteString("\t" + tag)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// tsvField puts s on one line, quoted as Anki expects when it holds a
+// double quote.
+func tsvField(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if strings.Contains(s, `"`) {
+		s = `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
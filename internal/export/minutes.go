@@ -0,0 +1,127 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// turn is what one speaker said before the next one spoke: consecutive
+// segments of the same speaker, merged.
+type turn struct {
+	start, end float64
+	speaker    string
+	text       string
+}
+
+// turns merges the segments into speaker turns.
+func (d Doc) turns() []turn {
+	var out []turn
+	for _, s := range d.Segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		speaker := speakerName(s.Speaker)
+		if n := len(out); n > 0 && out[n-1].speaker == speaker {
+			out[n-1].text += " " + text
+			out[n-1].end = max(out[n-1].end, s.End)
+			continue
+		}
+		out = append(out, turn{start: s.Start, end: s.End, speaker: speaker, text: text})
+	}
+	return out
+}
+
+// speakerLabel matches the labels diarization gives speakers: SPEAKER_00,
+// SPEAKER_01, … counting from zero as the models write them, or SPEAKER 1,
+// SPEAKER 2, … counting from one as the browser shows them.
+var speakerLabel = regexp.MustCompile(`^SPEAKER([_ ])(\d+)$`)
+
+// speakerName turns a diarization label into "Speaker 1", "Speaker 2", …
+// Names a user gave the speakers are kept.
+func speakerName(label string) string {
+	label = strings.TrimSpace(label)
+	if m := speakerLabel.FindStringSubmatch(label); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		if m[1] == "_" {
+			n++
+		}
+		return fmt.Sprintf("Speaker %d", n)
+	}
+	return label
+}
+
+// minutes renders meeting minutes as markdown: the attendees (the
+// speakers, in the order they first spoke), how long each spoke (see
+// Analyze), then each speaker turn with its start time. A raw segment list splits every sentence of a speaker
+// into its own line; minutes read like the meeting did.
+func minutes(d Doc) []byte {
+	turns := d.turns()
+	var attendees []string
+	seen := map[string]bool{}
+	for _, t := range turns {
+		if t.speaker != "" && !seen[t.speaker] {
+			seen[t.speaker] = true
+			attendees = append(attendees, t.speaker)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("---\ntitle: " + d.heading() + "\n")
+	if !d.Date.IsZero() {
+		b.WriteString("date: " + d.Date.Format("2006-01-02T15:04:05") + "\n")
+	}
+	if d.Language != "" {
+		b.WriteString("language: " + d.Language + "\n")
+	}
+	if len(attendees) > 0 {
+		b.WriteString("attendees: [" + strings.Join(attendees, ", ") + "]\n")
+	}
+	b.WriteString("tags: [minutes]\n---\n\n")
+	b.WriteString("# " + d.heading() + "\n\n")
+	meta := d.meta()
+	if n := len(d.Segments); n > 0 {
+		if length := d.Segments[n-1].End; length > 0 {
+			if meta != "" {
+				meta += " · "
+			}
+			meta += clock(length)
+		}
+	}
+	if meta != "" {
+		b.WriteString("*" + meta + "*\n\n")
+	}
+
+	b.WriteString("## Attendees\n\n")
+	if len(attendees) == 0 {
+		b.WriteString("Not identified — the transcript has no speaker labels.\n\n")
+	}
+	for _, a := range attendees {
+		b.WriteString("- " + a + "\n")
+	}
+	if len(attendees) > 0 {
+		b.WriteString("\n")
+	}
+	if a := Analyze(d.Segments); a != nil {
+		speakingTime(&b, a)
+	}
+
+	b.WriteString("## Discussion\n\n")
+	if len(turns) == 0 {
+		b.WriteString(strings.TrimSpace(d.Text) + "\n")
+		return []byte(b.String())
+	}
+	for i, t := range turns {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("**[" + clock(t.start) + "]")
+		if t.speaker != "" {
+			b.WriteString(" " + t.speaker + ":")
+		}
+		b.WriteString("** " + t.text + "\n")
+	}
+	return []byte(b.String())
+}
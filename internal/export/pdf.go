@@ -0,0 +1,184 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Page geometry in points: A4 with 2cm margins.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 56.0
+)
+
+// Text styles: font resource, size, line height.
+type pdfStyle struct {
+	font    string // /F1 regular, /F2 bold
+	size    float64
+	leading float64
+	grey    bool
+}
+
+var (
+	styleTitle = pdfStyle{"/F2", 16, 24, false}
+	styleMeta  = pdfStyle{"/F1", 9, 18, true}
+	styleBody  = pdfStyle{"/F1", 11, 15, false}
+)
+
+// helveticaWidths are the widths of ASCII 32–126 in Helvetica, in 1/1000
+// em, from the standard font metrics. Other characters use avgWidth.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space – /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0–9
+	278, 278, 584, 584, 584, 556, 1015, // : – @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A–M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N–Z
+	278, 278, 278, 469, 556, 333, // [ – `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a–m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n–z
+	334, 260, 334, 584, // { – ~
+}
+
+const avgWidth = 556
+
+// winAnsi maps the non-Latin-1 characters of WinAnsiEncoding that
+// dictation produces (smart quotes, dashes, ellipsis, euro) to their codes.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encodeWinAnsi converts s to WinAnsiEncoding, the encoding of the PDF
+// standard fonts. Characters it can't represent become '?' — a transcript
+// in a non-Latin script needs an embedded font, which this writer doesn't
+// do; DOCX has no such limit.
+func encodeWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		case winAnsi[r] != 0:
+			out = append(out, winAnsi[r])
+		case r == '\t':
+			out = append(out, ' ')
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// textWidth is the width of encoded text in points at size.
+func textWidth(b []byte, size float64) float64 {
+	units := 0
+	for _, c := range b {
+		if c >= 32 && c <= 126 {
+			units += helveticaWidths[c-32]
+		} else {
+			units += avgWidth
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// wrapPDF breaks encoded text into lines that fit width at size.
+func wrapPDF(b []byte, size, width float64) [][]byte {
+	var lines [][]byte
+	var line []byte
+	for _, word := range bytes.Fields(b) {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+		if len(line) > 0 && textWidth(candidate, size) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = candidate
+	}
+	return append(lines, line) // an empty paragraph stays one blank line
+}
+
+// pdfString escapes b as a PDF literal string.
+func pdfString(b []byte) string {
+	var s strings.Builder
+	s.WriteByte('(')
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			s.WriteByte('\\')
+		}
+		s.WriteByte(c)
+	}
+	s.WriteByte(')')
+	return s.String()
+}
+
+func pdf(d Doc) []byte {
+	// Lay out every line, starting a new page when one is full.
+	var pages []*bytes.Buffer
+	var page *bytes.Buffer
+	y := 0.0
+	add := func(st pdfStyle, text string) {
+		for _, line := range wrapPDF(encodeWinAnsi(text), st.size, pageWidth-2*margin) {
+			if page == nil || y-st.leading < margin {
+				page = &bytes.Buffer{}
+				pages = append(pages, page)
+				y = pageHeight - margin
+			}
+			y -= st.leading
+			grey := "0 g"
+			if st.grey {
+				grey = "0.5 g"
+			}
+			fmt.Fprintf(page, "BT %s %s %g Tf %.2f %.2f Td %s Tj ET\n", grey, st.font, st.size, margin, y, pdfString(line))
+		}
+	}
+	add(styleTitle, d.heading())
+	if meta := d.meta(); meta != "" {
+		add(styleMeta, meta)
+	}
+	for _, line := range d.lines() {
+		add(styleBody, line)
+	}
+
+	// Objects: 1 catalog, 2 page tree, 3–4 fonts, 5 info, then a page and
+	// its content stream for each page.
+	var objs []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	objs = append(objs,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title %s /Producer (Captain's Log) >>", pdfString(encodeWinAnsi(d.heading()))),
+	)
+	for i, p := range pages {
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, 7+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return out.Bytes()
+}
@@ -0,0 +1,168 @@
+package export
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// DefaultLineChars is the subtitle line length when Subtitles doesn't set
+// one: the common broadcast limit.
+const DefaultLineChars = 42
+
+// Subtitles are the cue rules of srt and vtt exports. Whisper cuts
+// segments where the model paused, not where a viewer can read them: a
+// segment may run for half a minute, or flash a single word for 200ms.
+// Zero fields leave the segments as they are.
+type Subtitles struct {
+	MaxLineChars int     `json:"max_line_chars,omitempty"` // characters per line; 0 = DefaultLineChars
+	MaxLines     int     `json:"max_lines,omitempty"`      // lines per cue; longer cues are split
+	MinDuration  float64 `json:"min_duration,omitempty"`   // seconds; shorter cues are merged into a neighbour, or held longer
+	MaxDuration  float64 `json:"max_duration,omitempty"`   // seconds; longer cues are split
+}
+
+// Validate checks the rules for values no subtitle could meet.
+func (o Subtitles) Validate() error {
+	switch {
+	case o.MaxLineChars < 0 || o.MaxLines < 0 || o.MinDuration < 0 || o.MaxDuration < 0:
+		return errors.New("subtitle limits must not be negative")
+	case o.MaxLineChars > 0 && o.MaxLineChars < 10:
+		return errors.New("subtitle lines must allow at least 10 characters")
+	case o.MaxDuration > 0 && o.MinDuration > o.MaxDuration:
+		return errors.New("subtitle min_duration must not exceed max_duration")
+	}
+	return nil
+}
+
+func (o Subtitles) lineChars() int {
+	if o.MaxLineChars > 0 {
+		return o.MaxLineChars
+	}
+	return DefaultLineChars
+}
+
+// cues returns the subtitle cues: the segments with tiny ones merged, long
+// ones split, and short ones held on screen for MinDuration.
+func (d Doc) cues() []Segment {
+	o := d.Subtitles
+	segs := d.Segments
+	if len(segs) == 0 {
+		segs = []Segment{{Start: 0, End: 10, Text: d.Text}}
+	}
+	var cues []Segment
+	for _, s := range segs {
+		s.Text = strings.TrimSpace(s.Text)
+		if n := len(cues); n > 0 && o.MinDuration > 0 && o.mergeable(cues[n-1], s) {
+			cues[n-1].Text = strings.TrimSpace(cues[n-1].Text + " " + s.Text)
+			cues[n-1].End = max(cues[n-1].End, s.End)
+			continue
+		}
+		cues = append(cues, s)
+	}
+
+	var out []Segment
+	for _, c := range cues {
+		out = append(out, o.split(c)...)
+	}
+
+	if o.MinDuration > 0 {
+		for i := range out {
+			if out[i].End-out[i].Start >= o.MinDuration {
+				continue
+			}
+			end := out[i].Start + o.MinDuration
+			if i+1 < len(out) {
+				end = min(end, max(out[i].End, out[i+1].Start)) // never over the next cue
+			}
+			out[i].End = end
+		}
+	}
+	return out
+}
+
+// mergeable reports whether cue b can join cue a: one of them is shorter
+// than MinDuration, they have the same speaker, and together they still
+// fit the line and duration limits.
+func (o Subtitles) mergeable(a, b Segment) bool {
+	if a.End-a.Start >= o.MinDuration && b.End-b.Start >= o.MinDuration {
+		return false
+	}
+	if a.Speaker != b.Speaker {
+		return false
+	}
+	if o.MaxDuration > 0 && max(a.End, b.End)-a.Start > o.MaxDuration {
+		return false
+	}
+	if o.MaxLines > 0 && len(wrapLines(a.Text+" "+b.Text, o.lineChars())) > o.MaxLines {
+		return false
+	}
+	return true
+}
+
+// split cuts a cue that has more than MaxLines lines or lasts longer than
+// MaxDuration into the fewest pieces of about equal length that fit, at
+// word boundaries, each timed by its share of the text.
+func (o Subtitles) split(c Segment) []Segment {
+	words := strings.Fields(c.Text)
+	if o.fits(c) || len(words) < 2 {
+		return []Segment{c}
+	}
+	n := 2
+	if o.MaxLines > 0 {
+		n = max(n, (len(wrapLines(c.Text, o.lineChars()))+o.MaxLines-1)/o.MaxLines)
+	}
+	if o.MaxDuration > 0 {
+		n = max(n, int(math.Ceil((c.End-c.Start)/o.MaxDuration)))
+	}
+	for ; n < len(words); n++ {
+		pieces := cut(c, words, n)
+		ok := true
+		for _, p := range pieces {
+			ok = ok && o.fits(p)
+		}
+		if ok {
+			return pieces
+		}
+	}
+	return cut(c, words, len(words))
+}
+
+// fits reports whether a cue keeps to MaxLines and MaxDuration.
+func (o Subtitles) fits(c Segment) bool {
+	if o.MaxLines > 0 && len(wrapLines(c.Text, o.lineChars())) > o.MaxLines {
+		return false
+	}
+	// The tolerance absorbs float error in pieces timed by their share.
+	return o.MaxDuration <= 0 || c.End-c.Start <= o.MaxDuration+1e-9
+}
+
+// cut splits a cue's words into n pieces of about equal length in
+// characters, timed by their share of the text.
+func cut(c Segment, words []string, n int) []Segment {
+	total := len([]rune(strings.Join(words, " "))) + 1 // each word counts its space
+	dur := c.End - c.Start
+	out := make([]Segment, 0, n)
+	first, done := 0, 0 // first word of the piece; runes before it
+	for k := 1; k <= n; k++ {
+		last, size := first, done
+		for last < len(words) {
+			size += len([]rune(words[last])) + 1
+			last++
+			// Stop at this piece's share, leaving a word for each piece after it.
+			if size >= total*k/n || len(words)-last == n-k {
+				break
+			}
+		}
+		if k == n {
+			last, size = len(words), total
+		}
+		out = append(out, Segment{
+			Start:   c.Start + dur*float64(done)/float64(total),
+			End:     c.Start + dur*float64(size)/float64(total),
+			Text:    strings.Join(words[first:last], " "),
+			Speaker: c.Speaker,
+		})
+		first, done = last, size
+	}
+	return out
+}
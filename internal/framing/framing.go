@@ -0,0 +1,118 @@
+// Package framing decides who may embed the UI in an iframe.
+//
+// By default nothing may: every response carries X-Frame-Options: DENY and
+// frame-ancestors 'none', so a hostile page can't overlay the recorder and
+// trick a click on the record button. Obsidian's webview and Home Assistant
+// dashboards embed pages in iframes, though, so a Policy lists the origins
+// allowed to frame the app and the routes they may frame. Everything else
+// keeps the deny-all headers.
+//
+// X-Frame-Options has no allowlist form (ALLOW-FROM is ignored by current
+// browsers), so on an embeddable route it is dropped and CSP frame-ancestors
+// alone carries the allowlist.
+package framing
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultPaths are the routes that serve the UI page itself. Scripts and
+// styles don't need to be listed: frame-ancestors only applies to the
+// framed document.
+const DefaultPaths = "/,/index.html"
+
+// origin matches a CSP host-source with a scheme: scheme://host[:port],
+// where host may start with "*." and port may be "*". Paths are not
+// allowed; frame-ancestors matches origins.
+var origin = regexp.MustCompile(`^[a-z][a-z0-9+.-]*://(\*\.)?[a-z0-9-]+(\.[a-z0-9-]+)*(:(\d{1,5}|\*))?$`)
+
+// Policy is a framing allowlist. The zero value allows no framing.
+type Policy struct {
+	ancestors []string
+	exact     map[string]bool
+	prefixes  []string // from "/dir/*" entries, stored as "/dir/"
+}
+
+// New builds a Policy from comma-separated lists. ancestors are origins
+// such as "app://obsidian.md" or "https://*.example.com", or "'self'";
+// paths are exact routes or prefixes ending in "/*". Empty paths means
+// DefaultPaths.
+func New(ancestors, paths string) (*Policy, error) {
+	p := &Policy{exact: map[string]bool{}}
+	for _, a := range splitList(ancestors) {
+		a = strings.TrimRight(strings.ToLower(a), "/")
+		if a == "'self'" {
+			continue // always allowed on embeddable routes
+		}
+		if !origin.MatchString(a) {
+			return nil, fmt.Errorf("invalid frame ancestor %q (want scheme://host[:port] or 'self')", a)
+		}
+		p.ancestors = append(p.ancestors, a)
+	}
+	if strings.TrimSpace(paths) == "" {
+		paths = DefaultPaths
+	}
+	for _, path := range splitList(paths) {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid frame path %q (want an absolute path)", path)
+		}
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			p.prefixes = append(p.prefixes, prefix)
+		} else {
+			p.exact[path] = true
+		}
+	}
+	return p, nil
+}
+
+// Enabled reports whether any other origin may frame the app.
+func (p *Policy) Enabled() bool { return p != nil && len(p.ancestors) > 0 }
+
+// Ancestors returns the allowed origins.
+func (p *Policy) Ancestors() []string {
+	if p == nil {
+		return nil
+	}
+	return p.ancestors
+}
+
+// Allows reports whether path may be framed by the allowed origins.
+func (p *Policy) Allows(path string) bool {
+	if !p.Enabled() {
+		return false
+	}
+	if p.exact[path] {
+		return true
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the framing headers for a response to path, appending the
+// frame-ancestors directive to csp (the rest of the page's policy).
+func (p *Policy) Apply(h http.Header, path, csp string) {
+	if !p.Allows(path) {
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Content-Security-Policy", csp+"; frame-ancestors 'none'")
+		return
+	}
+	h.Del("X-Frame-Options")
+	h.Set("Content-Security-Policy", csp+"; frame-ancestors 'self' "+strings.Join(p.ancestors, " "))
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package framing
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const csp = "default-src 'self'"
+
+func TestZeroPolicyDenies(t *testing.T) {
+	var p *Policy
+	h := http.Header{}
+	p.Apply(h, "/", csp)
+	if h.Get("X-Frame-Options") != "DENY" || h.Get("Content-Security-Policy") != csp+"; frame-ancestors 'none'" {
+		t.Errorf("headers = %v", h)
+	}
+
+	empty, err := New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Enabled() || empty.Allows("/") {
+		t.Error("policy without ancestors allows framing")
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	p, err := New("app://obsidian.md, http://homeassistant.local:8123/, https://*.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header{"X-Frame-Options": {"DENY"}}
+	p.Apply(h, "/", csp)
+	want := csp + "; frame-ancestors 'self' app://obsidian.md http://homeassistant.local:8123 https://*.example.com"
+	if got := h.Get("Content-Security-Policy"); got != want {
+		t.Errorf("CSP = %q, want %q", got, want)
+	}
+	if h.Get("X-Frame-Options") != "" {
+		t.Error("X-Frame-Options kept on an embeddable route")
+	}
+
+	// API routes stay unframeable.
+	h = http.Header{}
+	p.Apply(h, "/api/settings", csp)
+	if h.Get("X-Frame-Options") != "DENY" || !strings.HasSuffix(h.Get("Content-Security-Policy"), "frame-ancestors 'none'") {
+		t.Errorf("API headers = %v", h)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	p, err := New("app://obsidian.md", "/, /embed/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		"/":             true,
+		"/embed/mini":   true,
+		"/index.html":   false,
+		"/api/settings": false,
+		"/embedded":     false,
+	} {
+		if got := p.Allows(path); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, a := range []string{
+		"obsidian.md",                       // no scheme
+		"https://example.com/path",          // path
+		"https://example.com; script-src *", // directive injection
+		"*",
+	} {
+		if _, err := New(a, ""); err == nil {
+			t.Errorf("New(%q) accepted", a)
+		}
+	}
+	if _, err := New("app://obsidian.md", "index.html"); err == nil {
+		t.Error("relative path accepted")
+	}
+	if p, err := New("'self'", ""); err != nil || p.Enabled() {
+		t.Errorf("'self' only: err = %v, enabled = %v", err, p.Enabled())
+	}
+}
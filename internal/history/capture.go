@@ -0,0 +1,120 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/auth"
+)
+
+// maxCapture caps the response kept to log; a transcription too long for
+// it is passed on in full but not logged.
+const maxCapture = 8 << 20
+
+// Wrap logs the transcriptions next answers with: each 2xx response to a
+// POST, whatever format the client asked for. The response goes out
+// unchanged. A nil Log returns next.
+func (l *Log) Wrap(source string, logger *slog.Logger, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+		if cw.status < 200 || cw.status >= 300 || cw.overflow {
+			return
+		}
+		e := Parse(w.Header().Get("Content-Type"), cw.body.Bytes())
+		e.Source = source
+		e.Caller = auth.Caller(r.Context())
+		if _, err := l.Add(e); err != nil {
+			logger.Warn("transcription not logged", "error", err)
+		}
+	}
+}
+
+// Parse reads a transcription response: OpenAI JSON (json, verbose_json)
+// for the text, language and duration, or the text of a text, srt or vtt
+// response.
+func Parse(contentType string, body []byte) Entry {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmed := bytes.TrimSpace(body)
+	if mediaType == "application/json" || (mediaType == "" && bytes.HasPrefix(trimmed, []byte("{"))) {
+		var resp struct {
+			Text     string  `json:"text"`
+			Language string  `json:"language"`
+			Duration float64 `json:"duration"`
+		}
+		if json.Unmarshal(trimmed, &resp) != nil {
+			return Entry{}
+		}
+		return Entry{Text: resp.Text, Language: resp.Language, Duration: resp.Duration}
+	}
+	if mediaType == "text/event-stream" {
+		return Entry{} // streamed partials, not one transcription
+	}
+	return Entry{Text: cueText(string(trimmed))}
+}
+
+// cueText returns the text of SRT or WebVTT cues joined by spaces: cue
+// numbers, timing lines and the WEBVTT header are dropped. Plain text
+// comes back with its lines joined.
+func cueText(s string) string {
+	var words []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", line == "WEBVTT", strings.Contains(line, "-->"), isDigits(line):
+			continue
+		}
+		words = append(words, line)
+	}
+	return strings.Join(words, " ")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// captureWriter keeps a copy of the response body.
+type captureWriter struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(p) > maxCapture {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,190 @@
+// Package history keeps a server-side log of recent transcriptions: every
+// one the server answers, saved to the vault or not.
+//
+// The browser keeps its own history in localStorage, which is per device
+// and lost when site data is cleared; the vault keeps only what was saved.
+// The log fills the gap: the last Max transcriptions with their text, in
+// one JSON file rewritten on each change, oldest dropped first.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// DefaultMax is how many transcriptions Open keeps when max is 0.
+const DefaultMax = 500
+
+// ErrNotFound is returned for an unknown entry ID.
+var ErrNotFound = errors.New("history entry not found")
+
+// Sources of entries.
+const (
+	SourceAPI         = "api"         // /v1/audio/transcriptions: the UI's recordings and uploads, and API clients
+	SourceTranslation = "translation" // /v1/audio/translations
+	SourceURL         = "url"         // /api/transcribe-url
+	SourceJob         = "job"         // a background job
+	SourceWatcher     = "watcher"     // the folder watcher
+	SourceMic         = "mic"         // the server's own microphone
+)
+
+// Entry is one logged transcription.
+type Entry struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Text      string    `json:"text"`
+	Language  string    `json:"language,omitempty"`
+	Duration  float64   `json:"duration,omitempty"` // seconds of audio, when the backend says
+	Source    string    `json:"source,omitempty"`
+	Caller    string    `json:"caller,omitempty"`   // API key name, when auth is on
+	Filename  string    `json:"filename,omitempty"` // the uploaded or watched file
+	VaultFile string    `json:"vault_file,omitempty"`
+}
+
+// Log is the transcription log. Safe for concurrent use.
+type Log struct {
+	path string
+	max  int
+
+	mu      sync.Mutex
+	entries []Entry // oldest first
+}
+
+// Open loads the log at path, keeping at most max entries (0 = DefaultMax).
+// A missing file is an empty log.
+func Open(path string, max int) (*Log, error) {
+	if max <= 0 {
+		max = DefaultMax
+	}
+	l := &Log{path: path, max: max}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history log: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.entries); err != nil {
+		return nil, fmt.Errorf("parse history log %s: %w", filepath.Base(path), err)
+	}
+	if n := len(l.entries); n > max {
+		l.entries = l.entries[n-max:]
+	}
+	return l, nil
+}
+
+// Add logs e, setting its ID and, if zero, CreatedAt, and drops the oldest
+// entries beyond the maximum. Entries without text are not logged, nor
+// anything by a nil Log (the log is off).
+func (l *Log) Add(e Entry) (Entry, error) {
+	e.Text = strings.TrimSpace(e.Text)
+	if l == nil || e.Text == "" {
+		return Entry{}, nil
+	}
+	e.ID = httputil.HistoryID.New()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := l.entries
+	l.entries = append(l.entries, e)
+	if n := len(l.entries); n > l.max {
+		l.entries = append([]Entry(nil), l.entries[n-l.max:]...)
+	}
+	if err := l.saveLocked(); err != nil {
+		l.entries = prev
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// Page returns up to limit entries, newest first, after skipping offset,
+// and the total number of entries. limit <= 0 returns all.
+func (l *Log) Page(offset, limit int) ([]Entry, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	total := len(l.entries)
+	out := []Entry{}
+	for i := total - 1 - max(offset, 0); i >= 0; i-- {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, l.entries[i])
+	}
+	return out, total
+}
+
+// Get returns the entry with the given ID.
+func (l *Log) Get(id string) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// Delete removes the entry with the given ID.
+func (l *Log) Delete(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, e := range l.entries {
+		if e.ID != id {
+			continue
+		}
+		prev := l.entries
+		l.entries = append(append([]Entry(nil), l.entries[:i]...), l.entries[i+1:]...)
+		if err := l.saveLocked(); err != nil {
+			l.entries = prev
+			return err
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// Clear removes every entry.
+func (l *Log) Clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := l.entries
+	l.entries = nil
+	if err := l.saveLocked(); err != nil {
+		l.entries = prev
+		return err
+	}
+	return nil
+}
+
+// saveLocked rewrites the file via a temp file and rename, so a crash
+// leaves the old log or the new one.
+func (l *Log) saveLocked() error {
+	entries := l.entries
+	if entries == nil {
+		entries = []Entry{}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write history log: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace history log: %w", err)
+	}
+	return nil
+}
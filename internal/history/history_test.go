@@ -0,0 +1,119 @@
+package history
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	l, err := Open(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, text := range []string{"one", "two", "  ", "three", "four"} {
+		e, err := l.Add(Entry{Text: text, Source: SourceAPI})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.ID != "" {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) != 4 {
+		t.Fatalf("logged %d entries, want 4 (blank text is skipped)", len(ids))
+	}
+
+	page, total := l.Page(0, 2)
+	if total != 3 || len(page) != 2 || page[0].Text != "four" || page[1].Text != "three" {
+		t.Fatalf("Page(0, 2) = %v, %d", page, total)
+	}
+	if page, _ := l.Page(2, 2); len(page) != 1 || page[0].Text != "two" {
+		t.Errorf("Page(2, 2) = %v", page)
+	}
+	if page, _ := l.Page(5, 0); len(page) != 0 {
+		t.Errorf("Page past the end = %v", page)
+	}
+	if _, err := l.Get(ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("oldest entry not dropped: %v", err)
+	}
+
+	if err := l.Delete(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ids[2]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete = %v", err)
+	}
+
+	// Reopened, with a smaller maximum.
+	l, err = Open(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page, total := l.Page(0, 0); total != 1 || page[0].Text != "four" || !httputil.HistoryID.Valid(page[0].ID) {
+		t.Errorf("reopened = %v, %d", page, total)
+	}
+	if err := l.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	l, _ = Open(path, 0)
+	if _, total := l.Page(0, 0); total != 0 {
+		t.Errorf("after Clear total = %d", total)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		want              Entry
+	}{
+		{"application/json", `{"text":" Hello there. "}`, Entry{Text: " Hello there. "}},
+		{"application/json; charset=utf-8", `{"text":"Hallo","language":"german","duration":3.5,"segments":[]}`,
+			Entry{Text: "Hallo", Language: "german", Duration: 3.5}},
+		{"text/plain; charset=utf-8", "Just text.\n", Entry{Text: "Just text."}},
+		{"application/x-subrip", "1\n00:00:00,000 --> 00:00:02,000\nHello\n\n2\n00:00:02,000 --> 00:00:04,000\nworld\n",
+			Entry{Text: "Hello world"}},
+		{"text/vtt", "WEBVTT\n\n00:00.000 --> 00:02.000\nHello\n", Entry{Text: "Hello"}},
+		{"application/json", `{broken`, Entry{}},
+		{"text/event-stream", "data: {\"text\":\"partial\"}\n\n", Entry{}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("Parse(%q, %q) = %+v, want %+v", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	l, _ := Open(filepath.Join(t.TempDir(), "history.json"), 0)
+	status := http.StatusOK
+	h := l.Wrap(SourceAPI, slog.Default(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, `{"text":"Captain's log"}`)
+	})
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", strings.NewReader("audio")))
+	if rec.Body.String() != `{"text":"Captain's log"}` {
+		t.Errorf("response changed: %q", rec.Body.String())
+	}
+	status = http.StatusBadGateway
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", nil))
+	status = http.StatusOK
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/audio/transcriptions", nil))
+
+	page, total := l.Page(0, 0)
+	if total != 1 || page[0].Text != "Captain's log" || page[0].Source != SourceAPI {
+		t.Errorf("logged %v", page)
+	}
+}
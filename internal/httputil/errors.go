@@ -10,6 +10,9 @@
 //
 //	httputil.Error(w, r, logger, http.StatusBadRequest, "no file in multipart form",
 //	    "WHY: recording upload requires a 'file' field in the multipart body")
+//
+// It also holds the small helpers several packages' handlers share:
+// NewID for record IDs and ClientIP for the caller's address.
 package httputil
 
 import (
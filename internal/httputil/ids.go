@@ -0,0 +1,42 @@
+package httputil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// NewID returns prefix followed by size random bytes in hex: "h_" and 8
+// give "h_3f9a0c…", 18 characters. IDs that come back in request paths
+// use one of the IDFormats below, so their check lives next to this.
+func NewID(prefix string, size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// IDFormat is the shape of one kind of ID: a prefix and a number of random
+// bytes, written in lowercase hex.
+type IDFormat struct {
+	Prefix string
+	Size   int
+}
+
+// The IDs handed out to clients.
+var (
+	JobID       = IDFormat{"", 8}      // transcription jobs
+	HistoryID   = IDFormat{"h_", 8}    // history entries
+	PreviewID   = IDFormat{"pv_", 6}   // caption previews
+	ReprocessID = IDFormat{"rp_", 6}   // reprocess jobs
+	EventID     = IDFormat{"evt_", 12} // webhook events
+)
+
+// New returns a fresh ID in format f.
+func (f IDFormat) New() string { return NewID(f.Prefix, f.Size) }
+
+// Valid reports whether id could have come from f.New, so request paths
+// can be rejected before they reach a map or the filesystem.
+func (f IDFormat) Valid(id string) bool {
+	hexPart, ok := strings.CutPrefix(id, f.Prefix)
+	return ok && len(hexPart) == 2*f.Size && strings.Trim(hexPart, "0123456789abcdef") == ""
+}
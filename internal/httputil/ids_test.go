@@ -0,0 +1,34 @@
+package httputil
+
+import "testing"
+
+func TestIDFormatValid(t *testing.T) {
+	for _, f := range []IDFormat{JobID, HistoryID, PreviewID, ReprocessID, EventID} {
+		if id := f.New(); !f.Valid(id) {
+			t.Errorf("%+v: new ID %q is not valid", f, id)
+		}
+	}
+	tests := []struct {
+		f    IDFormat
+		id   string
+		want bool
+	}{
+		{JobID, "0123456789abcdef", true},
+		{JobID, "0123456789ABCDEF", false},
+		{JobID, "../../etc/passwd", false},
+		{JobID, "abc", false},
+		{HistoryID, "h_0123456789abcdef", true},
+		{HistoryID, "0123456789abcdef", false},
+		{PreviewID, "pv_0123456789ab", true},
+		{PreviewID, "", false},
+		{PreviewID, "pv_", false},
+		{PreviewID, "pv_../../etc", false},
+		{PreviewID, "rp_0123456789ab", false},
+		{PreviewID, "pv_0123456789az", false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Valid(tt.id); got != tt.want {
+			t.Errorf("%+v.Valid(%q) = %v, want %v", tt.f, tt.id, got, tt.want)
+		}
+	}
+}
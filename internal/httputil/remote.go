@@ -0,0 +1,13 @@
+package httputil
+
+import "net"
+
+// ClientIP strips the port from a RemoteAddr, leaving it as it is when it
+// has none.
+func ClientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
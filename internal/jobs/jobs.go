@@ -0,0 +1,577 @@
+// Package jobs runs long transcriptions in the background.
+//
+// A synchronous upload holds the HTTP request open for as long as Whisper
+// takes, and an hour of audio outlasts every timeout between the browser
+// and the backend. Submitting a job instead stores the upload on disk and
+// returns an ID at once; workers transcribe jobs in order and the client
+// polls for status and fetches the result when it is done.
+//
+// The queue is JSON on disk, one metadata file per job next to its upload
+// and result — no database, and a restart picks up where it left off: jobs
+// that were queued stay queued, and a job interrupted mid-transcription is
+// queued again.
+//
+// Several instances can share one queue directory (Options.Shared): each
+// picks up the others' jobs, a claim file (see internal/cluster) makes
+// sure only one of them transcribes a job, and a job whose instance died
+// mid-transcription is queued again once its claim goes stale.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/cluster"
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// Job statuses.
+const (
+	StatusQueued     = "queued"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+	StatusCanceled   = "canceled"
+)
+
+// Errors returned by Queue methods.
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrNotDone   = errors.New("job has no result yet")
+)
+
+// maxProgress caps the estimate while a job runs — only a finished job is
+// at 100%.
+const maxProgress = 0.95
+
+// Shared queue timings. A running job's claim is touched every
+// heartbeatInterval; one untouched for claimStale belongs to a dead
+// instance.
+const (
+	claimStale   = 2 * time.Minute
+	syncInterval = 2 * time.Second
+)
+
+// heartbeatInterval is a var so tests needn't wait half a minute.
+var heartbeatInterval = claimStale / 4
+
+// Job is a snapshot of one background transcription.
+type Job struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Filename   string    `json:"filename,omitempty"`
+	Size       int64     `json:"size"`               // upload bytes
+	Progress   float64   `json:"progress"`           // 0–1; estimated while processing
+	Position   int       `json:"position,omitempty"` // while queued, its place in line, 1 = next
+	Attempts   int       `json:"attempts,omitempty"` // >1 when a restart interrupted it
+	Instance   string    `json:"instance,omitempty"` // instance that accepted the upload
+	Worker     string    `json:"worker,omitempty"`   // instance that transcribed it (or is)
+	Error      string    `json:"error,omitempty"`
+	ResultType string    `json:"result_type,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has reached a final status.
+func (j Job) Finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// record is what is persisted: the Job plus what the worker needs to
+// replay the upload.
+type record struct {
+	Job
+	UploadType string `json:"upload_type"`
+}
+
+// Processor transcribes one upload. body is the request body as submitted
+// and contentType its Content-Type; the result is returned as-is to the
+// client later.
+type Processor func(ctx context.Context, body io.ReadSeeker, contentType string) (result []byte, resultType string, err error)
+
+// Options tunes a Queue. Zero values take the defaults noted.
+type Options struct {
+	Workers   int           // concurrent transcriptions (default 1)
+	MaxQueued int           // jobs waiting before Submit refuses (default 100)
+	Retention time.Duration // finished jobs are deleted after this long (default 24h)
+	Instance  string        // this instance's ID, recorded on the jobs it accepts and runs
+	Shared    bool          // other instances use the same dir
+}
+
+// Queue is a persistent background job queue. Safe for concurrent use.
+type Queue struct {
+	dir    string
+	proc   Processor
+	opts   Options
+	logger *slog.Logger
+
+	pending chan string
+
+	mu       sync.Mutex
+	jobs     map[string]*record
+	cancels  map[string]context.CancelFunc
+	enqueued map[string]bool      // in pending, not yet taken by a worker
+	seen     map[string]time.Time // job file mod times, to spot other instances' changes (shared only)
+	beat     time.Duration        // heartbeatInterval, read once
+	rate     float64              // bytes/second of recent jobs (EWMA), for progress estimates
+
+	// claimMu guards claims apart from mu: a claim is released with mu
+	// held or not.
+	claimMu sync.Mutex
+	claims  map[string]func() // releases this instance's claims (shared only)
+}
+
+// Open loads the queue in dir, creating it if needed. Jobs left processing
+// by a previous run are queued again. Call Run to start the workers.
+func Open(dir string, proc Processor, opts Options, logger *slog.Logger) (*Queue, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = 100
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 24 * time.Hour
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create jobs dir: %w", err)
+	}
+	q := &Queue{
+		dir:      dir,
+		proc:     proc,
+		opts:     opts,
+		logger:   logger,
+		jobs:     map[string]*record{},
+		cancels:  map[string]context.CancelFunc{},
+		enqueued: map[string]bool{},
+		seen:     map[string]time.Time{},
+		beat:     heartbeatInterval,
+		claims:   map[string]func(){},
+	}
+
+	metas, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var queued []*record
+	for _, path := range metas {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read job: %w", err)
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil || rec.ID == "" {
+			logger.Warn("skipping unreadable job file", "file", filepath.Base(path), "error", err)
+			continue
+		}
+		// In a shared queue another instance may be transcribing it right now.
+		if rec.Status == StatusProcessing && q.abandoned(rec.ID) {
+			rec.Status, rec.Progress, rec.StartedAt = StatusQueued, 0, time.Time{}
+			if err := q.save(&rec); err != nil {
+				return nil, err
+			}
+		}
+		q.jobs[rec.ID] = &rec
+		if rec.Status == StatusQueued {
+			queued = append(queued, &rec)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].CreatedAt.Before(queued[j].CreatedAt) })
+
+	// WHY size the channel by MaxQueued + what was already queued? Submit
+	// never blocks, and a restart never drops a job it had accepted.
+	q.pending = make(chan string, opts.MaxQueued+len(queued))
+	for _, rec := range queued {
+		q.pending <- rec.ID
+		q.enqueued[rec.ID] = true
+	}
+	if len(queued) > 0 {
+		logger.Info("resuming queued transcription jobs", "count", len(queued))
+	}
+	return q, nil
+}
+
+// Run starts the workers and the cleanup of old jobs. It returns at once;
+// everything stops when ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	for i := 0; i < q.opts.Workers; i++ {
+		go q.worker(ctx)
+	}
+	go func() {
+		t := time.NewTicker(10 * time.Minute)
+		defer t.Stop()
+		for {
+			q.Prune(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	if q.opts.Shared {
+		go func() {
+			t := time.NewTicker(syncInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					q.sync()
+				}
+			}
+		}()
+	}
+}
+
+// Submit stores body as a new job and queues it.
+func (q *Queue) Submit(body io.Reader, contentType, filename string) (Job, error) {
+	q.mu.Lock()
+	waiting := len(q.pending)
+	q.mu.Unlock()
+	if waiting >= q.opts.MaxQueued {
+		return Job{}, ErrQueueFull
+	}
+
+	rec := &record{
+		Job: Job{
+			ID:        httputil.JobID.New(),
+			Status:    StatusQueued,
+			Filename:  filepath.Base(filename),
+			Instance:  q.opts.Instance,
+			CreatedAt: time.Now().UTC(),
+		},
+		UploadType: contentType,
+	}
+	f, err := os.OpenFile(q.uploadPath(rec.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return Job{}, fmt.Errorf("store upload: %w", err)
+	}
+	n, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return Job{}, fmt.Errorf("store upload: %w", err)
+	}
+	rec.Size = n
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.save(rec); err != nil {
+		os.Remove(f.Name())
+		return Job{}, err
+	}
+	select {
+	case q.pending <- rec.ID:
+	default:
+		q.removeFiles(rec.ID)
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[rec.ID] = rec
+	q.enqueued[rec.ID] = true
+	q.logger.Info("transcription job queued", "id", rec.ID, "bytes", n)
+	job := rec.Job
+	job.Position = q.positionsLocked()[rec.ID]
+	return job, nil
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rec, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	job := rec.Job
+	job.Position = q.positionsLocked()[id]
+	return job, true
+}
+
+// List returns snapshots of all jobs, newest first.
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	positions := q.positionsLocked()
+	out := make([]Job, 0, len(q.jobs))
+	for _, rec := range q.jobs {
+		job := rec.Job
+		job.Position = positions[job.ID]
+		out = append(out, job)
+	}
+	q.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// positionsLocked numbers the queued jobs in the order workers take them,
+// oldest first. q.mu must be held.
+func (q *Queue) positionsLocked() map[string]int {
+	var queued []*record
+	for _, rec := range q.jobs {
+		if rec.Status == StatusQueued {
+			queued = append(queued, rec)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].CreatedAt.Before(queued[j].CreatedAt) })
+	positions := make(map[string]int, len(queued))
+	for i, rec := range queued {
+		positions[rec.ID] = i + 1
+	}
+	return positions
+}
+
+// Result returns the transcription of a finished job and its content type.
+func (q *Queue) Result(id string) ([]byte, string, error) {
+	job, ok := q.Get(id)
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	if job.Status != StatusDone {
+		return nil, "", ErrNotDone
+	}
+	data, err := os.ReadFile(q.resultPath(id))
+	if err != nil {
+		return nil, "", fmt.Errorf("read result: %w", err)
+	}
+	return data, job.ResultType, nil
+}
+
+// Remove cancels a queued or running job, or deletes a finished one with
+// its files.
+func (q *Queue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rec, ok := q.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	switch rec.Status {
+	case StatusQueued:
+		if q.opts.Shared {
+			// Claimed like a worker would, so no instance starts it meanwhile.
+			release, err := cluster.TryClaim(q.claimPath(id), q.opts.Instance, claimStale)
+			if err != nil {
+				return err
+			}
+			if release == nil {
+				// A worker got to it first.
+				return q.requestCancel(id)
+			}
+			defer release()
+		}
+		// The worker skips it when it comes up.
+		rec.Status, rec.FinishedAt = StatusCanceled, time.Now().UTC()
+		os.Remove(q.uploadPath(id))
+		return q.save(rec)
+	case StatusProcessing:
+		// The worker records the cancellation when the processor returns.
+		if cancel, ok := q.cancels[id]; ok {
+			cancel()
+			return nil
+		}
+		return q.requestCancel(id)
+	}
+	delete(q.jobs, id)
+	q.removeFiles(id)
+	return nil
+}
+
+// Prune deletes jobs that finished more than Retention before now.
+func (q *Queue) Prune(now time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for id, rec := range q.jobs {
+		if rec.Finished() && now.Sub(rec.FinishedAt) > q.opts.Retention {
+			delete(q.jobs, id)
+			q.removeFiles(id)
+			n++
+		}
+	}
+	return n
+}
+
+func (q *Queue) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.pending:
+			if ctx.Err() != nil {
+				// Shutting down; the job stays queued on disk.
+				return
+			}
+			q.process(ctx, id)
+		}
+	}
+}
+
+func (q *Queue) process(ctx context.Context, id string) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.mu.Lock()
+	delete(q.enqueued, id)
+	q.mu.Unlock()
+	if q.opts.Shared && !q.claim(id) {
+		return
+	}
+
+	q.mu.Lock()
+	rec, ok := q.jobs[id]
+	if !ok || rec.Status != StatusQueued {
+		q.mu.Unlock()
+		q.release(id)
+		return
+	}
+	rec.Status, rec.StartedAt, rec.Progress = StatusProcessing, time.Now().UTC(), 0
+	rec.Worker = q.opts.Instance
+	rec.Attempts++
+	q.cancels[id] = cancel
+	q.saveOrLog(rec)
+	size, uploadType, rate := rec.Size, rec.UploadType, q.rate
+	q.mu.Unlock()
+
+	stopProgress := q.trackProgress(rec, size, rate)
+	stopHeartbeat := q.heartbeat(rec, cancel)
+	result, resultType, err := q.run(jobCtx, id, uploadType)
+	stopHeartbeat()
+	stopProgress()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.release(id)
+	delete(q.cancels, id)
+	if ctx.Err() != nil {
+		// Shutting down: leave it processing so Open queues it again — or,
+		// in a shared queue, another instance once the claim is gone.
+		return
+	}
+	rec.FinishedAt = time.Now().UTC()
+	switch {
+	case jobCtx.Err() != nil:
+		rec.Status = StatusCanceled
+	case err != nil:
+		rec.Status, rec.Error = StatusFailed, err.Error()
+	default:
+		if werr := writeFile(q.resultPath(id), result); werr != nil {
+			rec.Status, rec.Error = StatusFailed, werr.Error()
+			break
+		}
+		rec.Status, rec.Progress, rec.ResultType = StatusDone, 1, resultType
+		if took := rec.FinishedAt.Sub(rec.StartedAt).Seconds(); took > 0 && size > 0 {
+			q.observeRate(float64(size) / took)
+		}
+	}
+	// The upload has served its purpose once the job is over.
+	os.Remove(q.uploadPath(id))
+	q.saveOrLog(rec)
+	q.logger.Info("transcription job finished", "id", id, "status", rec.Status,
+		"duration", rec.FinishedAt.Sub(rec.StartedAt).Round(time.Millisecond), "error", rec.Error)
+}
+
+func (q *Queue) run(ctx context.Context, id, uploadType string) ([]byte, string, error) {
+	f, err := os.Open(q.uploadPath(id))
+	if err != nil {
+		return nil, "", fmt.Errorf("open upload: %w", err)
+	}
+	defer f.Close()
+	return q.proc(ctx, f, uploadType)
+}
+
+// trackProgress estimates progress from the throughput of earlier jobs
+// until the returned stop func is called. WHY estimate? Whisper backends
+// report nothing until the transcription is complete.
+func (q *Queue) trackProgress(rec *record, size int64, rate float64) (stop func()) {
+	if rate <= 0 || size <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				p := min(maxProgress, time.Since(start).Seconds()*rate/float64(size))
+				q.mu.Lock()
+				if rec.Status == StatusProcessing {
+					rec.Progress = p
+				}
+				q.mu.Unlock()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// observeRate folds a finished job's throughput into the estimate.
+// Caller holds q.mu.
+func (q *Queue) observeRate(bytesPerSecond float64) {
+	if q.rate == 0 {
+		q.rate = bytesPerSecond
+		return
+	}
+	q.rate = 0.7*q.rate + 0.3*bytesPerSecond
+}
+
+func (q *Queue) metaPath(id string) string   { return filepath.Join(q.dir, id+".json") }
+func (q *Queue) uploadPath(id string) string { return filepath.Join(q.dir, id+".upload") }
+func (q *Queue) resultPath(id string) string { return filepath.Join(q.dir, id+".result") }
+func (q *Queue) claimPath(id string) string  { return filepath.Join(q.dir, id+".claim") }
+func (q *Queue) cancelPath(id string) string { return filepath.Join(q.dir, id+".cancel") }
+
+// save persists rec. Caller holds q.mu (or owns rec exclusively).
+func (q *Queue) save(rec *record) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(q.metaPath(rec.ID), data); err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) saveOrLog(rec *record) {
+	if err := q.save(rec); err != nil {
+		q.logger.Error("failed to persist job state", "id", rec.ID, "error", err)
+	}
+}
+
+func (q *Queue) removeFiles(id string) {
+	for _, p := range []string{q.metaPath(id), q.uploadPath(id), q.resultPath(id), q.claimPath(id), q.cancelPath(id)} {
+		os.Remove(p)
+	}
+}
+
+// writeFile writes via temp file + rename so a crash never leaves a
+// half-written job behind.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// echoProcessor returns the upload upper-cased.
+func echoProcessor(ctx context.Context, body io.ReadSeeker, contentType string) ([]byte, string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(strings.ToUpper(string(data))), contentType, nil
+}
+
+// waitFinished polls until the job reaches a final status.
+func waitFinished(t *testing.T, q *Queue, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := q.Get(id); job.Finished() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+	return Job{}
+}
+
+func open(t *testing.T, dir string, proc Processor, opts Options) *Queue {
+	t.Helper()
+	q, err := Open(dir, proc, opts, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestSubmitAndResult(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir, echoProcessor, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Run(ctx)
+
+	job, err := q.Submit(strings.NewReader("hello"), "text/plain", "../../memo.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusQueued || job.Size != 5 || job.Filename != "memo.wav" || !httputil.JobID.Valid(job.ID) {
+		t.Errorf("submitted job = %+v", job)
+	}
+
+	done := waitFinished(t, q, job.ID)
+	if done.Status != StatusDone || done.Progress != 1 || done.Attempts != 1 {
+		t.Fatalf("finished job = %+v", done)
+	}
+	result, typ, err := q.Result(job.ID)
+	if err != nil || string(result) != "HELLO" || typ != "text/plain" {
+		t.Errorf("Result = %q %q %v", result, typ, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, job.ID+".upload")); !os.IsNotExist(err) {
+		t.Errorf("upload kept after the job finished: %v", err)
+	}
+
+	if err := q.Remove(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.Get(job.ID); ok {
+		t.Error("job still listed after Remove")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("files left after Remove: %d", len(files))
+	}
+}
+
+func TestFailedJob(t *testing.T) {
+	q := open(t, t.TempDir(), func(context.Context, io.ReadSeeker, string) ([]byte, string, error) {
+		return nil, "", errors.New("backend returned HTTP 500")
+	}, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Run(ctx)
+
+	job, _ := q.Submit(strings.NewReader("x"), "", "")
+	done := waitFinished(t, q, job.ID)
+	if done.Status != StatusFailed || done.Error != "backend returned HTTP 500" {
+		t.Errorf("job = %+v", done)
+	}
+	if _, _, err := q.Result(job.ID); !errors.Is(err, ErrNotDone) {
+		t.Errorf("Result err = %v, want ErrNotDone", err)
+	}
+}
+
+func TestCancelRunning(t *testing.T) {
+	started := make(chan struct{})
+	q := open(t, t.TempDir(), func(ctx context.Context, _ io.ReadSeeker, _ string) ([]byte, string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, "", ctx.Err()
+	}, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Run(ctx)
+
+	job, _ := q.Submit(strings.NewReader("x"), "", "")
+	<-started
+	if err := q.Remove(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if done := waitFinished(t, q, job.ID); done.Status != StatusCanceled {
+		t.Errorf("status = %q, want canceled", done.Status)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	// No workers running, so nothing leaves the queue.
+	q := open(t, t.TempDir(), echoProcessor, Options{MaxQueued: 1})
+	if _, err := q.Submit(strings.NewReader("a"), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Submit(strings.NewReader("b"), "", ""); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("second Submit err = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	// No workers running, so the jobs stay queued in submission order.
+	q := open(t, t.TempDir(), echoProcessor, Options{MaxQueued: 3})
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		job, err := q.Submit(strings.NewReader("a"), "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Position != i {
+			t.Errorf("job %d submitted at position %d", i, job.Position)
+		}
+		ids = append(ids, job.ID)
+		time.Sleep(time.Millisecond) // distinct CreatedAt
+	}
+	if err := q.Remove(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := q.Get(ids[2]); job.Position != 2 {
+		t.Errorf("after a cancel, last job at position %d, want 2", job.Position)
+	}
+	for _, job := range q.List() {
+		if job.ID == ids[0] && job.Position != 0 {
+			t.Errorf("canceled job at position %d", job.Position)
+		}
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// First run: one job interrupted mid-transcription, one never started.
+	started := make(chan struct{})
+	ctx1, stop := context.WithCancel(context.Background())
+	q1 := open(t, dir, func(ctx context.Context, _ io.ReadSeeker, _ string) ([]byte, string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, "", ctx.Err()
+	}, Options{})
+	q1.Run(ctx1)
+	interrupted, _ := q1.Submit(strings.NewReader("first"), "text/plain", "")
+	<-started
+	waiting, _ := q1.Submit(strings.NewReader("second"), "text/plain", "")
+	stop()
+	time.Sleep(20 * time.Millisecond)
+
+	q2 := open(t, dir, echoProcessor, Options{})
+	if job, _ := q2.Get(interrupted.ID); job.Status != StatusQueued {
+		t.Fatalf("interrupted job reloaded as %q, want queued", job.Status)
+	}
+	ctx2, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q2.Run(ctx2)
+
+	for id, want := range map[string]string{interrupted.ID: "FIRST", waiting.ID: "SECOND"} {
+		job := waitFinished(t, q2, id)
+		result, _, _ := q2.Result(id)
+		if job.Status != StatusDone || string(result) != want {
+			t.Errorf("job %s = %q %q", id, job.Status, result)
+		}
+	}
+	if job, _ := q2.Get(interrupted.ID); job.Attempts != 2 {
+		t.Errorf("interrupted job attempts = %d, want 2", job.Attempts)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	q := open(t, t.TempDir(), echoProcessor, Options{Retention: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Run(ctx)
+
+	job, _ := q.Submit(strings.NewReader("x"), "", "")
+	waitFinished(t, q, job.ID)
+	if n := q.Prune(time.Now()); n != 0 {
+		t.Errorf("pruned %d fresh jobs", n)
+	}
+	if n := q.Prune(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Errorf("pruned %d, want 1", n)
+	}
+	if len(q.List()) != 0 {
+		t.Error("pruned job still listed")
+	}
+}
@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/cluster"
+)
+
+// claim takes the job for this instance. The job is then reread from
+// disk: another instance may have canceled it since the last sync.
+func (q *Queue) claim(id string) bool {
+	release, err := cluster.TryClaim(q.claimPath(id), q.opts.Instance, claimStale)
+	if err != nil {
+		q.logger.Error("failed to claim job", "id", id, "error", err)
+	}
+	if release == nil {
+		return false
+	}
+	q.claimMu.Lock()
+	q.claims[id] = release
+	q.claimMu.Unlock()
+	disk, err := readRecord(q.metaPath(id))
+	if err != nil {
+		// Deleted by another instance (or unreadable): nothing to run.
+		q.release(id)
+		return false
+	}
+	q.mu.Lock()
+	if rec, ok := q.jobs[id]; ok {
+		*rec = *disk
+	} else {
+		q.jobs[id] = disk
+	}
+	q.mu.Unlock()
+	return true
+}
+
+// release gives up this instance's claim on a job. A claim another
+// instance has taken over since is left alone.
+func (q *Queue) release(id string) {
+	q.claimMu.Lock()
+	release, ok := q.claims[id]
+	delete(q.claims, id)
+	q.claimMu.Unlock()
+	if ok {
+		release()
+		os.Remove(q.cancelPath(id))
+	}
+}
+
+// abandoned reports whether a job found processing when the queue opens
+// was left so by a run that ended: always in a queue of its own, and in a
+// shared one when its claim is this instance's or stale. Its claim is
+// cleared.
+func (q *Queue) abandoned(id string) bool {
+	switch owner := q.claimOwner(id); {
+	case !q.opts.Shared || owner == q.opts.Instance:
+		os.Remove(q.claimPath(id))
+		return true
+	case owner != "":
+		return false
+	}
+	// Stale: taken over, so of two instances starting at once only one
+	// queues it again.
+	release, _ := cluster.TryClaim(q.claimPath(id), q.opts.Instance, claimStale)
+	if release == nil {
+		return false
+	}
+	release()
+	return true
+}
+
+// claimOwner is the instance transcribing a job, "" if none is alive.
+func (q *Queue) claimOwner(id string) string {
+	return cluster.Owner(q.claimPath(id), claimStale)
+}
+
+// requestCancel asks the instance transcribing a job to cancel it. It
+// notices within a heartbeat.
+func (q *Queue) requestCancel(id string) error {
+	if err := writeFile(q.cancelPath(id), []byte(q.opts.Instance)); err != nil {
+		return fmt.Errorf("cancel job: %w", err)
+	}
+	return nil
+}
+
+// heartbeat keeps a running job's claim fresh, saves its progress for the
+// other instances to show, and cancels it if one of them asked to, until
+// the returned stop func is called.
+func (q *Queue) heartbeat(rec *record, cancel context.CancelFunc) (stop func()) {
+	if !q.opts.Shared {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(q.beat)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := cluster.Touch(q.claimPath(rec.ID)); err != nil {
+					q.logger.Warn("failed to renew job claim", "id", rec.ID, "error", err)
+				}
+				if _, err := os.Stat(q.cancelPath(rec.ID)); err == nil {
+					cancel()
+				}
+				q.mu.Lock()
+				q.saveOrLog(rec)
+				q.mu.Unlock()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// sync picks up what other instances changed in a shared queue: new jobs
+// are queued here too, finished and deleted ones are updated, and jobs
+// left processing by an instance that died are queued again.
+func (q *Queue) sync() {
+	metas, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return
+	}
+	present := map[string]bool{}
+	for _, path := range metas {
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		present[id] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		q.mu.Lock()
+		_, running := q.cancels[id]
+		unchanged := q.seen[id].Equal(info.ModTime())
+		q.mu.Unlock()
+		if running || unchanged {
+			// This instance's copy of a job it runs is ahead of the file.
+			continue
+		}
+		rec, err := readRecord(path)
+		if err != nil {
+			continue
+		}
+		q.mu.Lock()
+		q.seen[id] = info.ModTime()
+		if cur, ok := q.jobs[id]; ok {
+			*cur = *rec
+		} else {
+			q.jobs[id] = rec
+		}
+		q.mu.Unlock()
+	}
+
+	var orphaned []string
+	q.mu.Lock()
+	for id, rec := range q.jobs {
+		_, running := q.cancels[id]
+		switch {
+		case running:
+		case !present[id]:
+			delete(q.jobs, id)
+			delete(q.seen, id)
+		case rec.Status == StatusQueued && !q.enqueued[id]:
+			select {
+			case q.pending <- id:
+				q.enqueued[id] = true
+			default:
+				// Full; the next sync tries again.
+			}
+		case rec.Status == StatusProcessing && q.claimOwner(id) == "":
+			orphaned = append(orphaned, id)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, id := range orphaned {
+		q.requeue(id)
+	}
+}
+
+// requeue queues again a job whose instance died while transcribing it.
+func (q *Queue) requeue(id string) {
+	if !q.claim(id) {
+		return
+	}
+	defer q.release(id)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rec, ok := q.jobs[id]
+	if !ok || rec.Status != StatusProcessing {
+		return
+	}
+	q.logger.Warn("requeueing job abandoned by its instance", "id", id, "worker", rec.Worker)
+	rec.Status, rec.Progress, rec.StartedAt = StatusQueued, 0, time.Time{}
+	q.saveOrLog(rec)
+	select {
+	case q.pending <- id:
+		q.enqueued[id] = true
+	default:
+	}
+}
+
+func readRecord(path string) (*record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil || rec.ID == "" {
+		return nil, fmt.Errorf("unreadable job file %s: %v", filepath.Base(path), err)
+	}
+	return &rec, nil
+}
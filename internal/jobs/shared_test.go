@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharedQueue(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// a only takes uploads; b transcribes them.
+	a := open(t, dir, echoProcessor, Options{Instance: "a", Shared: true})
+	b := open(t, dir, echoProcessor, Options{Instance: "b", Shared: true})
+	b.Run(ctx)
+
+	job, err := a.Submit(strings.NewReader("hello"), "text/plain", "memo.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.sync()
+	done := waitFinished(t, b, job.ID)
+	if done.Status != StatusDone || done.Instance != "a" || done.Worker != "b" {
+		t.Fatalf("job on b = %+v", done)
+	}
+
+	a.sync()
+	if got, _ := a.Get(job.ID); got.Status != StatusDone || got.Worker != "b" {
+		t.Errorf("job on a = %+v", got)
+	}
+	if result, _, err := a.Result(job.ID); err != nil || string(result) != "HELLO" {
+		t.Errorf("Result on a = %q, %v", result, err)
+	}
+	if _, err := os.Stat(a.claimPath(job.ID)); !os.IsNotExist(err) {
+		t.Error("claim left behind")
+	}
+
+	// Deleted on one instance, gone from the other after a sync.
+	if err := b.Remove(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	a.sync()
+	if _, ok := a.Get(job.ID); ok {
+		t.Error("deleted job still listed on a")
+	}
+}
+
+func TestSharedClaimedOnce(t *testing.T) {
+	dir := t.TempDir()
+	a := open(t, dir, echoProcessor, Options{Instance: "a", Shared: true})
+	b := open(t, dir, echoProcessor, Options{Instance: "b", Shared: true})
+	job, _ := a.Submit(strings.NewReader("x"), "text/plain", "")
+	b.sync()
+
+	if !a.claim(job.ID) {
+		t.Fatal("a could not claim its job")
+	}
+	if b.claim(job.ID) {
+		t.Error("b claimed a job a holds")
+	}
+	a.release(job.ID)
+	if !b.claim(job.ID) {
+		t.Error("b could not claim a released job")
+	}
+}
+
+func TestSharedRequeueAbandoned(t *testing.T) {
+	dir := t.TempDir()
+	a := open(t, dir, echoProcessor, Options{Instance: "a", Shared: true})
+	job, _ := a.Submit(strings.NewReader("x"), "text/plain", "")
+
+	// a dies mid-transcription: the job is left processing under its claim.
+	a.claim(job.ID)
+	a.mu.Lock()
+	rec := a.jobs[job.ID]
+	rec.Status, rec.Worker = StatusProcessing, "a"
+	a.saveOrLog(rec)
+	a.mu.Unlock()
+
+	b := open(t, dir, echoProcessor, Options{Instance: "b", Shared: true})
+	if got, _ := b.Get(job.ID); got.Status != StatusProcessing {
+		t.Fatalf("b requeued a job with a live claim: %+v", got)
+	}
+
+	old := time.Now().Add(-2 * claimStale)
+	os.Chtimes(a.claimPath(job.ID), old, old)
+	b.sync()
+	if got, _ := b.Get(job.ID); got.Status != StatusQueued {
+		t.Fatalf("abandoned job = %+v, want queued", got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Run(ctx)
+	if done := waitFinished(t, b, job.ID); done.Status != StatusDone || done.Worker != "b" {
+		t.Errorf("requeued job = %+v", done)
+	}
+}
+
+func TestSharedCancelRemote(t *testing.T) {
+	defer func(d time.Duration) { heartbeatInterval = d }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	started := make(chan struct{})
+	blocking := func(ctx context.Context, body io.ReadSeeker, contentType string) ([]byte, string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, "", ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := open(t, dir, blocking, Options{Instance: "a", Shared: true})
+	a.Run(ctx)
+	b := open(t, dir, echoProcessor, Options{Instance: "b", Shared: true})
+
+	job, _ := a.Submit(strings.NewReader("x"), "text/plain", "")
+	<-started
+	b.sync()
+	if got, _ := b.Get(job.ID); got.Status != StatusProcessing || got.Worker != "a" {
+		t.Fatalf("job on b = %+v", got)
+	}
+	if err := b.Remove(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if done := waitFinished(t, a, job.ID); done.Status != StatusCanceled {
+		t.Errorf("job = %+v, want canceled", done)
+	}
+	data, _ := os.ReadFile(a.metaPath(job.ID))
+	var rec record
+	json.Unmarshal(data, &rec)
+	if rec.Status != StatusCanceled {
+		t.Errorf("saved status = %q", rec.Status)
+	}
+}
@@ -0,0 +1,187 @@
+// Package keywords spots watch keywords in new transcripts: words or
+// phrases a user wants to hear about ("invoice", "urgent", a client's
+// name). The server tags the note with each keyword found and announces
+// it with the sentence it was said in.
+package keywords
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/store"
+)
+
+// snippetContext is how many characters of context a snippet keeps on
+// each side of the keyword, before rounding to whole words.
+const snippetContext = 60
+
+// Hit is a keyword found in a transcript.
+type Hit struct {
+	Keyword string `json:"keyword"` // as the user wrote it
+	Count   int    `json:"count"`   // occurrences in the whole transcript
+	// Snippet is the first occurrence with some text around it.
+	Snippet string `json:"snippet"`
+	// Start is when the first occurrence was said, in seconds: the start
+	// of its segment. Nil without segment timings.
+	Start *float64 `json:"start,omitempty"`
+}
+
+// Clock is Start as m:ss (h:mm:ss from an hour on), or "" without it.
+func (h Hit) Clock() string {
+	if h.Start == nil {
+		return ""
+	}
+	t := int(max(*h.Start, 0))
+	if t >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", t/3600, t/60%60, t%60)
+	}
+	return fmt.Sprintf("%02d:%02d", t/60, t%60)
+}
+
+// Clean trims keywords, collapses the spaces inside phrases and drops
+// empty ones and duplicates (ignoring case). Order is kept.
+func Clean(keywords []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, k := range keywords {
+		k = strings.Join(strings.Fields(k), " ")
+		if k == "" || seen[strings.ToLower(k)] {
+			continue
+		}
+		seen[strings.ToLower(k)] = true
+		out = append(out, k)
+	}
+	return out
+}
+
+// Spot returns the keywords text mentions, in the order of keywords.
+// Matching ignores case and only takes whole words: "invoice" is found in
+// "Invoice 42" and "the invoice." but not in "invoiced". With segments the
+// hits are timed and snippets come from the segment; text is then only
+// used when no segment has text.
+func Spot(keywords []string, text string, segments []store.Segment) []Hit {
+	var spans []store.Segment
+	for _, s := range segments {
+		if strings.TrimSpace(s.Text) != "" {
+			spans = append(spans, s)
+		}
+	}
+	timed := len(spans) > 0
+	if !timed {
+		spans = []store.Segment{{Text: text}}
+	}
+
+	var hits []Hit
+	for _, k := range Clean(keywords) {
+		needle := fold([]rune(k))
+		var hit *Hit
+		for _, s := range spans {
+			runes := []rune(s.Text)
+			at := find(fold(runes), needle)
+			if len(at) == 0 {
+				continue
+			}
+			if hit == nil {
+				hit = &Hit{Keyword: k, Snippet: snippet(runes, at[0][0], at[0][1])}
+				if timed {
+					start := s.Start
+					hit.Start = &start
+				}
+			}
+			hit.Count += len(at)
+		}
+		if hit != nil {
+			hits = append(hits, *hit)
+		}
+	}
+	return hits
+}
+
+// fold lower-cases runes one for one, so offsets stay valid in the
+// original.
+func fold(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+// find returns the start and end offsets of needle in haystack where it
+// stands as whole words: not preceded or followed by a letter or digit. A
+// space in needle matches any run of white space.
+func find(haystack, needle []rune) [][2]int {
+	var at [][2]int
+	if len(needle) == 0 {
+		return nil
+	}
+	for i := 0; i < len(haystack); i++ {
+		end, ok := matchAt(haystack, i, needle)
+		if !ok {
+			continue
+		}
+		if i > 0 && isWord(haystack[i-1]) && isWord(needle[0]) {
+			continue
+		}
+		if end < len(haystack) && isWord(haystack[end]) && isWord(needle[len(needle)-1]) {
+			continue
+		}
+		at = append(at, [2]int{i, end})
+		i = end - 1
+	}
+	return at
+}
+
+// matchAt reports whether needle matches haystack at i, and where the
+// match ends.
+func matchAt(haystack []rune, i int, needle []rune) (int, bool) {
+	for _, r := range needle {
+		if i >= len(haystack) {
+			return 0, false
+		}
+		if r == ' ' {
+			if !unicode.IsSpace(haystack[i]) {
+				return 0, false
+			}
+			for i < len(haystack) && unicode.IsSpace(haystack[i]) {
+				i++
+			}
+			continue
+		}
+		if haystack[i] != r {
+			return 0, false
+		}
+		i++
+	}
+	return i, true
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// snippet returns runes[from:to] with up to snippetContext characters on
+// either side, cut at spaces, marking cuts with "…".
+func snippet(runes []rune, from, to int) string {
+	start := max(from-snippetContext, 0)
+	if start > 0 {
+		for start < from && !unicode.IsSpace(runes[start-1]) {
+			start++
+		}
+	}
+	end := min(to+snippetContext, len(runes))
+	if end < len(runes) {
+		for end > to && !unicode.IsSpace(runes[end]) {
+			end--
+		}
+	}
+	s := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return s
+}
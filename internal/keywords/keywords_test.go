@@ -0,0 +1,62 @@
+package keywords
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/store"
+)
+
+func TestSpotText(t *testing.T) {
+	text := "Please send the Invoice today. It's urgent! The invoiced amount was wrong, so the invoice is late."
+	hits := Spot([]string{"urgent", " invoice", "INVOICE", "Acme Corp", ""}, text, nil)
+	if len(hits) != 2 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if hits[0].Keyword != "urgent" || hits[0].Count != 1 || hits[0].Start != nil {
+		t.Errorf("urgent = %+v", hits[0])
+	}
+	// "invoiced" is another word.
+	if hits[1].Keyword != "invoice" || hits[1].Count != 2 {
+		t.Errorf("invoice = %+v", hits[1])
+	}
+	if !strings.Contains(hits[1].Snippet, "send the Invoice today") || hits[1].Clock() != "" {
+		t.Errorf("snippet = %q", hits[1].Snippet)
+	}
+}
+
+func TestSpotSegments(t *testing.T) {
+	segs := []store.Segment{
+		{Start: 1, End: 4, Text: "Good morning."},
+		{Start: 75, End: 80, Text: "About the Acme  Corp contract,"},
+		{Start: 3700, End: 3705, Text: "acme corp again."},
+	}
+	hits := Spot([]string{"acme corp"}, "ignored when segments have text", segs)
+	if len(hits) != 1 || hits[0].Count != 2 || hits[0].Start == nil || *hits[0].Start != 75 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if hits[0].Clock() != "01:15" || hits[0].Snippet != "About the Acme Corp contract," {
+		t.Errorf("clock %q, snippet %q", hits[0].Clock(), hits[0].Snippet)
+	}
+	// A phrase split by two spaces in the text is still the phrase.
+	if hits := Spot([]string{"acme   corp"}, "", segs); len(hits) != 1 {
+		t.Errorf("spaced keyword: %+v", hits)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 40) + "needle " + strings.Repeat("more ", 40)
+	hits := Spot([]string{"needle"}, long, nil)
+	s := hits[0].Snippet
+	if !strings.HasPrefix(s, "…word") || !strings.HasSuffix(s, "more…") || !strings.Contains(s, " needle ") || len([]rune(s)) > 2*snippetContext+10 {
+		t.Errorf("snippet = %q", s)
+	}
+}
+
+func TestClean(t *testing.T) {
+	got := Clean([]string{" urgent ", "Urgent", "", "Acme \t Corp"})
+	if want := []string{"urgent", "Acme Corp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Clean = %q, want %q", got, want)
+	}
+}
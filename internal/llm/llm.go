@@ -0,0 +1,110 @@
+// Package llm provides a minimal client for OpenAI-compatible chat completion
+// servers (Ollama, LM Studio, llama.cpp server, vLLM).
+//
+// The browser talks to the LLM through the raw /api/llm/chat passthrough;
+// this client is for server-side features that need a completion without a
+// browser in the loop (self-test, post-processing, digests).
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxInputRunes caps the text a server-side feature sends in one call.
+// Small local models have 4–8k token contexts; ~12k characters leaves room
+// for the prompt.
+const MaxInputRunes = 12000
+
+// Message is a single chat message in OpenAI format.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Client sends chat completion requests to an OpenAI-compatible server.
+type Client struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// Option configures optional Client behaviour.
+type Option func(*Client)
+
+// WithTransport sets the RoundTripper used for LLM calls.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) { c.client.Transport = rt }
+}
+
+// New creates a Client for the given base URL (e.g. http://127.0.0.1:11434)
+// and model name.
+func New(baseURL, model string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		client:  &http.Client{Timeout: 120 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ChatURL returns the chat/completions endpoint for an LLM base URL.
+// Both "http://host:11434" and "http://host:11434/v1" are accepted.
+func ChatURL(baseURL string) string {
+	target := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(target, "/v1") {
+		target += "/v1"
+	}
+	return target + "/chat/completions"
+}
+
+// Chat sends the messages and returns the content of the first choice.
+func (c *Client) Chat(ctx context.Context, messages ...Message) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":    c.model,
+		"messages": messages,
+		"stream":   false,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ChatURL(c.baseURL), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("llm request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("llm returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("llm returned no choices")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
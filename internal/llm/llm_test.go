@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"http://127.0.0.1:11434", "http://127.0.0.1:11434/v1/chat/completions"},
+		{"http://127.0.0.1:11434/", "http://127.0.0.1:11434/v1/chat/completions"},
+		{"http://127.0.0.1:1234/v1", "http://127.0.0.1:1234/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		if got := ChatURL(tt.base); got != tt.want {
+			t.Errorf("ChatURL(%q) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestChat(t *testing.T) {
+	var gotModel string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q, want /v1/chat/completions", r.URL.Path)
+		}
+		var req struct {
+			Model    string    `json:"model"`
+			Messages []Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotModel = req.Model
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"role": "assistant", "content": "  OK \n"}},
+			},
+		})
+	}))
+	defer backend.Close()
+
+	c := New(backend.URL, "llama3.2")
+	got, err := c.Chat(context.Background(), Message{Role: "user", Content: "ping"})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if got != "OK" {
+		t.Errorf("Chat = %q, want %q", got, "OK")
+	}
+	if gotModel != "llama3.2" {
+		t.Errorf("model = %q, want llama3.2", gotModel)
+	}
+}
+
+func TestChatBackendError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	if _, err := New(backend.URL, "missing").Chat(context.Background()); err == nil {
+		t.Error("Chat should fail on non-200 response")
+	}
+}
+
+func TestChatNoChoices(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": []}`))
+	}))
+	defer backend.Close()
+
+	if _, err := New(backend.URL, "m").Chat(context.Background()); err == nil {
+		t.Error("Chat should fail when no choices are returned")
+	}
+}
@@ -0,0 +1,336 @@
+// Package loadtest drives concurrent transcription requests against a running
+// Captain's Log server to size a deployment before real users arrive.
+//
+// It measures what a room full of people dictating at once would feel:
+// throughput, latency percentiles, error rates, and how far the Whisper
+// backend is from scaling linearly with concurrency (saturation).
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes a load test run.
+type Config struct {
+	URL         string        // server base URL, e.g. http://127.0.0.1:8090
+	Token       string        // optional Bearer token (CAPTAINSLOG_AUTH_TOKEN)
+	Audio       []byte        // audio file sent with every request
+	Filename    string        // filename reported in the multipart upload
+	Language    string        // optional language hint
+	Concurrency int           // number of simultaneous clients
+	Requests    int           // total requests to send (ignored when Duration > 0)
+	Duration    time.Duration // run for this long instead of a fixed request count
+	Timeout     time.Duration // per-request timeout (default: 10m)
+	Client      *http.Client  // optional; defaults to a client with Timeout
+}
+
+// Latency summarises request latencies.
+type Latency struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// Saturation compares the concurrent run against a single-request baseline.
+type Saturation struct {
+	// Baseline is the latency of one request with no other load.
+	Baseline time.Duration `json:"baseline"`
+	// Slowdown is P50 under load divided by Baseline. ~1 means requests
+	// don't wait on each other; ~Concurrency means the backend is serial.
+	Slowdown float64 `json:"slowdown"`
+	// Efficiency is achieved throughput as a fraction of perfect linear
+	// scaling (Concurrency / Baseline). Below ~0.5 the backend is saturated.
+	Efficiency float64 `json:"efficiency"`
+	// Rejected counts 429/502/503/504 responses — the server or backend
+	// shedding load rather than queueing it.
+	Rejected int `json:"rejected"`
+}
+
+// Report is the outcome of a load test run.
+type Report struct {
+	Concurrency int            `json:"concurrency"`
+	Requests    int            `json:"requests"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	StatusCodes map[int]int    `json:"status_codes"`
+	Errors      map[string]int `json:"errors,omitempty"`
+	Elapsed     time.Duration  `json:"elapsed"`
+	Throughput  float64        `json:"throughput_rps"`
+	Latency     Latency        `json:"latency"`
+	Saturation  Saturation     `json:"saturation"`
+}
+
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// Run executes the load test. A single baseline request is sent first to
+// verify the server works and to measure unloaded latency; if it fails, the
+// run is aborted instead of hammering a broken server.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Requests < 1 && cfg.Duration <= 0 {
+		cfg.Requests = cfg.Concurrency
+	}
+	if cfg.Filename == "" {
+		cfg.Filename = "sample.wav"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Minute
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	if len(cfg.Audio) == 0 {
+		return Report{}, errors.New("no audio to send")
+	}
+
+	base := send(ctx, cfg)
+	if base.err != nil {
+		return Report{}, fmt.Errorf("baseline request: %w", base.err)
+	}
+	if base.status != http.StatusOK {
+		return Report{}, fmt.Errorf("baseline request returned %d", base.status)
+	}
+
+	runCtx := ctx
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	// WHY a channel of tickets? Workers pull one ticket per request, so
+	// fixed-count mode sends exactly cfg.Requests and duration mode stops
+	// handing out tickets the moment runCtx expires.
+	jobs := make(chan struct{}, cfg.Concurrency)
+	go func() {
+		defer close(jobs)
+		for i := 0; cfg.Duration > 0 || i < cfg.Requests; i++ {
+			select {
+			case jobs <- struct{}{}:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				// In duration mode, requests in flight when time runs out
+				// are allowed to finish — they use ctx, not runCtx.
+				r := send(ctx, cfg)
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return summarise(results, time.Since(start), cfg.Concurrency, base.latency), nil
+}
+
+// send performs one transcription request and records its outcome.
+func send(ctx context.Context, cfg Config) result {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", cfg.Filename)
+	if err != nil {
+		return result{err: err}
+	}
+	fw.Write(cfg.Audio)
+	mw.WriteField("response_format", "json")
+	if cfg.Language != "" {
+		mw.WriteField("language", cfg.Language)
+	}
+	mw.Close()
+
+	target := strings.TrimRight(cfg.URL, "/") + "/v1/audio/transcriptions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, &buf)
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+
+	start := time.Now()
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		return result{latency: time.Since(start), err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return result{status: resp.StatusCode, latency: time.Since(start)}
+}
+
+func summarise(results []result, elapsed time.Duration, concurrency int, baseline time.Duration) Report {
+	rep := Report{
+		Concurrency: concurrency,
+		Requests:    len(results),
+		StatusCodes: map[int]int{},
+		Elapsed:     elapsed,
+	}
+	var ok []time.Duration
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			rep.Failed++
+			if rep.Errors == nil {
+				rep.Errors = map[string]int{}
+			}
+			rep.Errors[errorKind(r.err)]++
+		case r.status == http.StatusOK:
+			rep.Succeeded++
+			rep.StatusCodes[r.status]++
+			ok = append(ok, r.latency)
+		default:
+			rep.Failed++
+			rep.StatusCodes[r.status]++
+		}
+		switch r.status {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			rep.Saturation.Rejected++
+		}
+	}
+
+	if elapsed > 0 {
+		rep.Throughput = float64(rep.Succeeded) / elapsed.Seconds()
+	}
+	rep.Latency = summariseLatency(ok)
+
+	rep.Saturation.Baseline = baseline
+	if baseline > 0 {
+		rep.Saturation.Slowdown = float64(rep.Latency.P50) / float64(baseline)
+		ideal := float64(concurrency) / baseline.Seconds()
+		rep.Saturation.Efficiency = rep.Throughput / ideal
+	}
+	return rep
+}
+
+func summariseLatency(d []time.Duration) Latency {
+	if len(d) == 0 {
+		return Latency{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var total time.Duration
+	for _, v := range d {
+		total += v
+	}
+	return Latency{
+		Min:  d[0],
+		Mean: total / time.Duration(len(d)),
+		P50:  Percentile(d, 50),
+		P90:  Percentile(d, 90),
+		P95:  Percentile(d, 95),
+		P99:  Percentile(d, 99),
+		Max:  d[len(d)-1],
+	}
+}
+
+// Percentile returns the p-th percentile (nearest-rank) of sorted durations.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// errorKind collapses transport errors into short buckets for the report.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case strings.Contains(err.Error(), "connection refused"):
+		return "connection refused"
+	case strings.Contains(err.Error(), "connection reset"):
+		return "connection reset"
+	}
+	return "other"
+}
+
+// WriteText prints a human-readable report.
+func (r Report) WriteText(w io.Writer) {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+
+	fmt.Fprintf(w, "\n  Requests:     %d (%d ok, %d failed) over %s\n", r.Requests, r.Succeeded, r.Failed, round(r.Elapsed))
+	fmt.Fprintf(w, "  Concurrency:  %d\n", r.Concurrency)
+	fmt.Fprintf(w, "  Throughput:   %.2f req/s\n", r.Throughput)
+
+	fmt.Fprintf(w, "\n  Latency (successful requests)\n")
+	fmt.Fprintf(w, "    min %s  mean %s  max %s\n", round(r.Latency.Min), round(r.Latency.Mean), round(r.Latency.Max))
+	fmt.Fprintf(w, "    p50 %s  p90 %s  p95 %s  p99 %s\n",
+		round(r.Latency.P50), round(r.Latency.P90), round(r.Latency.P95), round(r.Latency.P99))
+
+	fmt.Fprintf(w, "\n  Backend saturation\n")
+	fmt.Fprintf(w, "    baseline (1 request)  %s\n", round(r.Saturation.Baseline))
+	fmt.Fprintf(w, "    slowdown under load   %.1fx\n", r.Saturation.Slowdown)
+	fmt.Fprintf(w, "    scaling efficiency    %.0f%%\n", r.Saturation.Efficiency*100)
+	fmt.Fprintf(w, "    rejected (429/5xx)    %d\n", r.Saturation.Rejected)
+
+	if len(r.StatusCodes) > 0 {
+		codes := make([]int, 0, len(r.StatusCodes))
+		for c := range r.StatusCodes {
+			codes = append(codes, c)
+		}
+		sort.Ints(codes)
+		fmt.Fprintf(w, "\n  Status codes\n")
+		for _, c := range codes {
+			fmt.Fprintf(w, "    %d  %d\n", c, r.StatusCodes[c])
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(w, "\n  Errors\n")
+		for kind, n := range r.Errors {
+			fmt.Fprintf(w, "    %-20s %d\n", kind, n)
+		}
+	}
+
+	switch {
+	case r.Failed > 0 && r.Saturation.Rejected > 0:
+		fmt.Fprintf(w, "\n  ⚠ Server or backend rejected requests — lower concurrency or add backend capacity.\n\n")
+	case r.Saturation.Efficiency > 0 && r.Saturation.Efficiency < 0.5:
+		fmt.Fprintf(w, "\n  ⚠ Backend is saturated — requests are queueing rather than running in parallel.\n\n")
+	default:
+		fmt.Fprintf(w, "\n  ✓ Backend kept up at this concurrency.\n\n")
+	}
+}
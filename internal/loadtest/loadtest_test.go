@@ -0,0 +1,126 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0, 1 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Percentile(d, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile(empty) = %v, want 0", got)
+	}
+}
+
+func TestRunCountsRequests(t *testing.T) {
+	var calls atomic.Int32
+	var gotAuth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		gotAuth.Store(r.Header.Get("Authorization"))
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Write([]byte(`{"text":"ok"}`))
+	}))
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{
+		URL:         srv.URL,
+		Token:       "secret",
+		Audio:       []byte("RIFF"),
+		Concurrency: 4,
+		Requests:    10,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Requests != 10 || rep.Succeeded != 10 {
+		t.Errorf("requests = %d, succeeded = %d, want 10/10", rep.Requests, rep.Succeeded)
+	}
+	// 10 measured requests + 1 baseline.
+	if calls.Load() != 11 {
+		t.Errorf("server saw %d calls, want 11", calls.Load())
+	}
+	if gotAuth.Load() != "Bearer secret" {
+		t.Errorf("Authorization = %q", gotAuth.Load())
+	}
+}
+
+func TestRunCountsRejections(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Baseline succeeds, everything after is shed.
+		if calls.Add(1) == 1 {
+			w.Write([]byte(`{"text":"ok"}`))
+			return
+		}
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{URL: srv.URL, Audio: []byte("x"), Concurrency: 2, Requests: 4})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Failed != 4 || rep.Saturation.Rejected != 4 {
+		t.Errorf("failed = %d, rejected = %d, want 4/4", rep.Failed, rep.Saturation.Rejected)
+	}
+	if rep.StatusCodes[http.StatusServiceUnavailable] != 4 {
+		t.Errorf("status codes = %v", rep.StatusCodes)
+	}
+}
+
+func TestRunAbortsOnBrokenBaseline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if _, err := Run(context.Background(), Config{URL: srv.URL, Audio: []byte("x"), Concurrency: 2}); err == nil {
+		t.Error("Run should fail when the baseline request fails")
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte(`{"text":"ok"}`))
+	}))
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{
+		URL: srv.URL, Audio: []byte("x"), Concurrency: 2, Duration: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Succeeded < 2 {
+		t.Errorf("succeeded = %d, want several requests in 100ms", rep.Succeeded)
+	}
+	if rep.Throughput <= 0 {
+		t.Errorf("throughput = %v, want > 0", rep.Throughput)
+	}
+}
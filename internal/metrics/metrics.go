@@ -0,0 +1,219 @@
+// Package metrics provides a tiny in-process metrics registry with
+// Prometheus text exposition.
+//
+// WHY not prometheus/client_golang? It pulls in a dozen transitive modules
+// for what is, here, a handful of counters. The text format is simple and
+// stable, and keeping it in-tree keeps the binary dependency-light.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric kinds, as written in the Prometheus "# TYPE" line.
+const (
+	KindCounter = "counter"
+	KindGauge   = "gauge"
+)
+
+// Sample is one labelled value of a metric.
+type Sample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*series // keyed by joined label values
+}
+
+type series struct {
+	values []string
+	value  float64
+}
+
+// Registry holds metric families. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Counter registers (or returns the existing) counter family with the given
+// label names. Registering the same name twice returns the same family, so
+// components that are rebuilt at runtime (e.g. the proxy after a settings
+// change) keep accumulating into the same series.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r: r, f: r.register(name, help, KindCounter, labels)}
+}
+
+// Gauge registers (or returns the existing) gauge family.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r: r, f: r.register(name, help, KindGauge, labels)}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		return f
+	}
+	f := &family{name: name, help: help, kind: kind, labels: labels, series: map[string]*series{}}
+	r.families[name] = f
+	return f
+}
+
+// update applies fn to the series identified by values, creating it if needed.
+func (r *Registry) update(f *family, values []string, fn func(*series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	r.mu.Lock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+	fn(s)
+	r.mu.Unlock()
+}
+
+// Counter is a monotonically increasing metric.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Inc adds 1 to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds v (which must be >= 0) to the series with the given label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.r.update(c.f, labelValues, func(s *series) { s.value += v })
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.r.update(g.f, labelValues, func(s *series) { s.value = v })
+}
+
+// Add adds v (possibly negative) to the series with the given label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.r.update(g.f, labelValues, func(s *series) { s.value += v })
+}
+
+// Samples returns a copy of every series of the named metric, sorted by
+// label values. Returns nil for unknown metrics.
+func (r *Registry) Samples(name string) []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		return nil
+	}
+	out := make([]Sample, 0, len(f.series))
+	for _, s := range sortedSeries(f) {
+		sample := Sample{Value: s.value}
+		if len(f.labels) > 0 {
+			sample.Labels = make(map[string]string, len(f.labels))
+			for i, l := range f.labels {
+				sample.Labels[l] = s.values[i]
+			}
+		}
+		out = append(out, sample)
+	}
+	return out
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range sortedSeries(f) {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(f.labels, s.values),
+				strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+}
+
+// Handler serves the registry in Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+func sortedSeries(f *family) []*series {
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = f.series[k]
+	}
+	return out
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
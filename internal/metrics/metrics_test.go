@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterAccumulates(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Requests.", "backend")
+	c.Inc("a")
+	c.Inc("a")
+	c.Add(3, "b")
+	c.Add(-1, "b") // counters never decrease
+
+	got := r.Samples("requests_total")
+	if len(got) != 2 {
+		t.Fatalf("got %d series, want 2", len(got))
+	}
+	if got[0].Labels["backend"] != "a" || got[0].Value != 2 {
+		t.Errorf("series a = %+v", got[0])
+	}
+	if got[1].Labels["backend"] != "b" || got[1].Value != 3 {
+		t.Errorf("series b = %+v", got[1])
+	}
+}
+
+func TestCounterReRegisterSharesSeries(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x_total", "X.").Inc()
+	r.Counter("x_total", "X.").Inc()
+	if got := r.Samples("x_total"); len(got) != 1 || got[0].Value != 2 {
+		t.Errorf("samples = %+v, want one series with value 2", got)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("inflight", "In flight.")
+	g.Add(2)
+	g.Add(-1)
+	if got := r.Samples("inflight"); got[0].Value != 1 {
+		t.Errorf("gauge = %v, want 1", got[0].Value)
+	}
+	g.Set(7)
+	if got := r.Samples("inflight"); got[0].Value != 7 {
+		t.Errorf("gauge = %v, want 7", got[0].Value)
+	}
+}
+
+func TestSamplesUnknown(t *testing.T) {
+	if got := NewRegistry().Samples("nope"); got != nil {
+		t.Errorf("Samples(unknown) = %v, want nil", got)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("b_total", "Second metric.", "backend", "result").Inc(`host:5000`, `o"k`)
+	r.Gauge("a_value", "First metric.").Set(1.5)
+
+	var buf bytes.Buffer
+	r.WritePrometheus(&buf)
+	want := `# HELP a_value First metric.
+# TYPE a_value gauge
+a_value 1.5
+# HELP b_total Second metric.
+# TYPE b_total counter
+b_total{backend="host:5000",result="o\"k"} 1
+`
+	if buf.String() != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "label values") {
+			t.Errorf("recover = %v, want label count panic", r)
+		}
+	}()
+	NewRegistry().Counter("x_total", "X.", "a").Inc()
+}
@@ -0,0 +1,250 @@
+// Package mic records from a microphone attached to the server, for boxes
+// without a browser: a kiosk or intercom with a USB mic starts and stops
+// a recording over HTTP (push-to-talk) and the server transcribes it.
+//
+// Recording is done by arecord (ALSA, on Linux) or ffmpeg, whichever is
+// installed, as a mono 16 kHz WAV — what Whisper wants, so nothing needs
+// converting afterwards.
+package mic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxDuration caps a recording nobody stopped.
+const DefaultMaxDuration = 10 * time.Minute
+
+var (
+	// ErrBusy is returned by Start while a recording runs.
+	ErrBusy = errors.New("already recording")
+	// ErrNotRecording is returned by Stop without a recording.
+	ErrNotRecording = errors.New("not recording")
+)
+
+// Status is a snapshot of the recorder.
+type Status struct {
+	Device    string     `json:"device"`
+	Tool      string     `json:"tool"` // "arecord" or "ffmpeg"
+	Recording bool       `json:"recording"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	Seconds   float64    `json:"seconds,omitempty"` // recorded so far
+	// Ended is set once the recorder stopped by itself: at the maximum
+	// duration, or because the device went away. Stop still collects it.
+	Ended bool `json:"ended,omitempty"`
+}
+
+// Recording is a finished recording.
+type Recording struct {
+	Path     string  `json:"-"`
+	Filename string  `json:"filename"` // in the recorder's directory
+	Seconds  float64 `json:"seconds"`
+}
+
+// Recorder records one recording at a time. Safe for concurrent use.
+type Recorder struct {
+	dir         string
+	device      string
+	tool        string // path of arecord or ffmpeg
+	maxDuration time.Duration
+	logger      *slog.Logger
+
+	mu  sync.Mutex
+	cur *session
+}
+
+type session struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	path    string
+	started time.Time
+	stderr  bytes.Buffer
+	done    chan struct{} // closed when the process exits
+	err     error         // its exit error, once done
+}
+
+// New returns a Recorder writing to dir from device ("default" for the
+// system's default input), or nil when device is empty or neither arecord
+// nor ffmpeg is installed. maxDuration <= 0 means DefaultMaxDuration.
+func New(dir, device string, maxDuration time.Duration, logger *slog.Logger) *Recorder {
+	if device == "" {
+		return nil
+	}
+	tool := ""
+	if runtime.GOOS == "linux" {
+		tool, _ = exec.LookPath("arecord")
+	}
+	if tool == "" {
+		tool, _ = exec.LookPath("ffmpeg")
+	}
+	if tool == "" {
+		logger.Warn("server microphone needs arecord or ffmpeg; neither is installed", "device", device)
+		return nil
+	}
+	return newRecorder(dir, device, tool, maxDuration, logger)
+}
+
+func newRecorder(dir, device, tool string, maxDuration time.Duration, logger *slog.Logger) *Recorder {
+	if maxDuration <= 0 {
+		maxDuration = DefaultMaxDuration
+	}
+	return &Recorder{dir: dir, device: device, tool: tool, maxDuration: maxDuration, logger: logger}
+}
+
+// Available reports whether r can record.
+func (r *Recorder) Available() bool { return r != nil }
+
+// toolName is "arecord" or "ffmpeg".
+func (r *Recorder) toolName() string {
+	return strings.TrimSuffix(filepath.Base(r.tool), ".exe")
+}
+
+// args returns the command line recording to out.
+func (r *Recorder) args(out string) []string {
+	seconds := strconv.Itoa(max(1, int(r.maxDuration.Seconds())))
+	if r.toolName() == "arecord" {
+		return []string{"-q", "-D", r.device, "-f", "S16_LE", "-r", "16000", "-c", "1", "-t", "wav", "-d", seconds, out}
+	}
+	var input []string
+	switch runtime.GOOS {
+	case "darwin":
+		device := r.device
+		if device == "default" {
+			device = "0"
+		}
+		input = []string{"-f", "avfoundation", "-i", ":" + device}
+	case "windows":
+		input = []string{"-f", "dshow", "-i", "audio=" + r.device}
+	default:
+		input = []string{"-f", "alsa", "-i", r.device}
+	}
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, input...)
+	return append(args, "-t", seconds, "-ac", "1", "-ar", "16000", "-c:a", "pcm_s16le", "-y", out)
+}
+
+// Start begins a recording. It waits a moment for the recorder to fail,
+// so a wrong device is reported here rather than at Stop.
+func (r *Recorder) Start() (Status, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cur != nil {
+		return r.statusLocked(), ErrBusy
+	}
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return Status{}, err
+	}
+	started := time.Now()
+	path := filepath.Join(r.dir, "mic-"+started.Format("20060102-150405")+".wav")
+	s := &session{path: path, started: started, done: make(chan struct{})}
+	s.cmd = exec.Command(r.tool, r.args(path)...)
+	s.cmd.Stderr = &s.stderr
+	stdin, err := s.cmd.StdinPipe()
+	if err != nil {
+		return Status{}, err
+	}
+	s.stdin = stdin
+	if err := s.cmd.Start(); err != nil {
+		return Status{}, fmt.Errorf("start %s: %w", r.toolName(), err)
+	}
+	go func() {
+		s.err = s.cmd.Wait()
+		close(s.done)
+	}()
+
+	select {
+	case <-s.done:
+		os.Remove(path)
+		return Status{}, fmt.Errorf("%s failed: %s", r.toolName(), lastLine(s.stderr.String(), s.err))
+	case <-time.After(500 * time.Millisecond):
+	}
+	r.cur = s
+	r.logger.Info("server microphone recording", "device", r.device, "file", filepath.Base(path))
+	return r.statusLocked(), nil
+}
+
+// Stop ends the recording and returns it. The file stays in the
+// recorder's directory; the caller decides what becomes of it.
+func (r *Recorder) Stop() (Recording, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.cur
+	if s == nil {
+		return Recording{}, ErrNotRecording
+	}
+	r.cur = nil
+
+	select {
+	case <-s.done: // stopped by itself
+	default:
+		// WHY not kill? Both tools write the WAV header's sizes on the way
+		// out; killed, the file claims to be empty.
+		if r.toolName() == "ffmpeg" {
+			io.WriteString(s.stdin, "q")
+		} else {
+			s.cmd.Process.Signal(os.Interrupt)
+		}
+		select {
+		case <-s.done:
+		case <-time.After(5 * time.Second):
+			s.cmd.Process.Kill()
+			<-s.done
+		}
+	}
+	s.stdin.Close()
+
+	info, err := os.Stat(s.path)
+	if err != nil || info.Size() <= 44 {
+		os.Remove(s.path)
+		return Recording{}, fmt.Errorf("%s recorded nothing: %s", r.toolName(), lastLine(s.stderr.String(), s.err))
+	}
+	rec := Recording{Path: s.path, Filename: filepath.Base(s.path), Seconds: float64(info.Size()-44) / 32000}
+	r.logger.Info("server microphone stopped", "file", rec.Filename, "seconds", rec.Seconds)
+	return rec, nil
+}
+
+// Status returns the recorder's state.
+func (r *Recorder) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.statusLocked()
+}
+
+func (r *Recorder) statusLocked() Status {
+	st := Status{Device: r.device, Tool: r.toolName()}
+	if s := r.cur; s != nil {
+		started := s.started
+		st.StartedAt = &started
+		select {
+		case <-s.done:
+			st.Ended = true
+		default:
+			st.Recording = true
+			st.Seconds = time.Since(started).Seconds()
+		}
+	}
+	return st
+}
+
+// lastLine returns the last line of a tool's stderr, or err without one.
+func lastLine(stderr string, err error) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return "no output"
+}
@@ -0,0 +1,84 @@
+package mic
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeArecord writes a script standing in for arecord: it writes a WAV
+// header and a second of silence to its last argument and waits for
+// SIGINT, or fails like a missing device when it is "hw:9".
+func fakeArecord(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	bin := filepath.Join(t.TempDir(), "arecord")
+	script := `#!/bin/sh
+for last; do :; done
+case "$*" in *hw:9*) echo "arecord: main:830: audio open error: No such file or directory" >&2; exit 1;; esac
+head -c 32044 /dev/zero > "$last"
+trap 'exit 0' INT
+while :; do sleep 0.05; done
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestArgs(t *testing.T) {
+	r := newRecorder("/rec", "plughw:1,0", "/usr/bin/arecord", 90*time.Second, slog.Default())
+	got := strings.Join(r.args("/rec/a.wav"), " ")
+	if got != "-q -D plughw:1,0 -f S16_LE -r 16000 -c 1 -t wav -d 90 /rec/a.wav" {
+		t.Errorf("arecord args = %s", got)
+	}
+	r = newRecorder("/rec", "default", "/usr/bin/ffmpeg", 0, slog.Default())
+	args := r.args("/rec/a.wav")
+	if !slices.Contains(args, "600") || args[len(args)-1] != "/rec/a.wav" || !strings.Contains(strings.Join(args, " "), "-ac 1 -ar 16000") {
+		t.Errorf("ffmpeg args = %v", args)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r := newRecorder(dir, "default", fakeArecord(t), time.Minute, slog.Default())
+	if _, err := r.Stop(); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("Stop before Start = %v", err)
+	}
+	st, err := r.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Recording || st.Tool != "arecord" || st.StartedAt == nil {
+		t.Errorf("status = %+v", st)
+	}
+	if _, err := r.Start(); !errors.Is(err, ErrBusy) {
+		t.Errorf("second Start = %v", err)
+	}
+	rec, err := r.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Seconds != 1 || !strings.HasPrefix(rec.Filename, "mic-") || filepath.Dir(rec.Path) != dir {
+		t.Errorf("recording = %+v", rec)
+	}
+	if r.Status().Recording {
+		t.Error("still recording after Stop")
+	}
+
+	bad := newRecorder(dir, "hw:9", r.tool, time.Minute, slog.Default())
+	if _, err := bad.Start(); err == nil || !strings.Contains(err.Error(), "audio open error") {
+		t.Errorf("Start with a missing device = %v", err)
+	}
+	if bad.Status().Recording {
+		t.Error("recording after a failed Start")
+	}
+}
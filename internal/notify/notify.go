@@ -0,0 +1,235 @@
+// Package notify pushes notifications to a phone through ntfy or Gotify.
+//
+// Both are self-hostable push services with a phone app: ntfy publishes to
+// a topic on a server (https://ntfy.sh or your own), Gotify to an
+// application on your server, identified by its token.
+//
+//	ntfy:   POST <url>/<topic>    Title, Priority, Tags headers; body = message
+//	gotify: POST <url>/message    X-Gotify-Key header; {"title", "message", "priority"}
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Providers.
+const (
+	ProviderNtfy   = "ntfy"
+	ProviderGotify = "gotify"
+)
+
+// DefaultNtfyURL is the ntfy server used when Config.URL is empty.
+const DefaultNtfyURL = "https://ntfy.sh"
+
+// Priorities, on ntfy's scale of 1 (min) to 5 (max). Gotify's 0–10 scale
+// gets them doubled.
+const (
+	PriorityLow     = 2
+	PriorityDefault = 3
+	PriorityHigh    = 4
+)
+
+// DefaultCooldown is how long Alert stays quiet about the same thing.
+const DefaultCooldown = 15 * time.Minute
+
+// ErrNotConfigured is returned by Send when no provider is set up.
+var ErrNotConfigured = errors.New("notifications are not configured")
+
+// ValidProvider reports whether p is "" (off) or a known provider.
+func ValidProvider(p string) bool {
+	return p == "" || p == ProviderNtfy || p == ProviderGotify
+}
+
+// Config says where notifications go.
+type Config struct {
+	Provider string // ProviderNtfy, ProviderGotify; "" = off
+	URL      string // server; "" = DefaultNtfyURL for ntfy, required for Gotify
+	Topic    string // ntfy only
+	Token    string // ntfy access token (optional) or Gotify application token
+}
+
+// Validate checks that c names a provider and has what it needs.
+func (c Config) Validate() error {
+	switch c.Provider {
+	case "":
+		return ErrNotConfigured
+	case ProviderNtfy:
+		if c.Topic == "" {
+			return errors.New("ntfy needs a topic")
+		}
+		if strings.ContainsAny(c.Topic, "/?#") {
+			return errors.New("ntfy topic must not contain /, ? or #")
+		}
+	case ProviderGotify:
+		if c.URL == "" {
+			return errors.New("gotify needs the server URL")
+		}
+		if c.Token == "" {
+			return errors.New("gotify needs an application token (CAPTAINSLOG_NOTIFY_TOKEN)")
+		}
+	default:
+		return fmt.Errorf("unknown notification provider %q", c.Provider)
+	}
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notification URL %q must be an http(s) URL", c.URL)
+		}
+	}
+	return nil
+}
+
+// Message is one notification.
+type Message struct {
+	Title    string
+	Body     string
+	Priority int      // PriorityLow … PriorityHigh; 0 = PriorityDefault
+	Tags     []string // ntfy tags, shown as emoji when they name one
+}
+
+// Notifier sends messages to the configured provider.
+type Notifier struct {
+	config func() Config // read per message, so settings changes apply at once
+	client *http.Client
+	logger *slog.Logger
+
+	// Cooldown is how long Alert drops messages with a key it just sent.
+	Cooldown time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time // by Alert key
+}
+
+// New creates a Notifier. config is called for every message.
+func New(config func() Config, logger *slog.Logger) *Notifier {
+	return &Notifier{
+		config:   config,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		Cooldown: DefaultCooldown,
+		last:     map[string]time.Time{},
+	}
+}
+
+// Enabled reports whether a provider is configured.
+func (n *Notifier) Enabled() bool {
+	return n.config().Provider != ""
+}
+
+// Send delivers msg now.
+func (n *Notifier) Send(ctx context.Context, msg Message) error {
+	c := n.config()
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if msg.Priority == 0 {
+		msg.Priority = PriorityDefault
+	}
+	var req *http.Request
+	var err error
+	switch c.Provider {
+	case ProviderNtfy:
+		req, err = ntfyRequest(ctx, c, msg)
+	case ProviderGotify:
+		req, err = gotifyRequest(ctx, c, msg)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "captainslog-notify")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %d: %s", c.Provider, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+	return nil
+}
+
+// Alert sends msg in the background, unless a message with the same key
+// went out within Cooldown: a backend that is down fails every call, and
+// one notification says so as well as fifty. An empty key is never held
+// back. Failures are logged.
+func (n *Notifier) Alert(key string, msg Message) {
+	if !n.Enabled() {
+		return
+	}
+	if key != "" {
+		n.mu.Lock()
+		if t, ok := n.last[key]; ok && time.Since(t) < n.Cooldown {
+			n.mu.Unlock()
+			return
+		}
+		n.last[key] = time.Now()
+		n.mu.Unlock()
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := n.Send(ctx, msg); err != nil {
+			n.logger.Warn("notification failed", "title", msg.Title, "error", err)
+		}
+	}()
+}
+
+func ntfyRequest(ctx context.Context, c Config, msg Message) (*http.Request, error) {
+	base := c.URL
+	if base == "" {
+		base = DefaultNtfyURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(base, "/")+"/"+url.PathEscape(c.Topic), strings.NewReader(msg.Body))
+	if err != nil {
+		return nil, err
+	}
+	// Header values must be ASCII for ntfy; RFC 2047 encoding covers the rest
+	// (titles are note names, and note names have accents).
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", msg.Title))
+	req.Header.Set("Priority", strconv.Itoa(msg.Priority))
+	if len(msg.Tags) > 0 {
+		req.Header.Set("Tags", strings.Join(msg.Tags, ","))
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+func gotifyRequest(ctx context.Context, c Config, msg Message) (*http.Request, error) {
+	body, err := json.Marshal(map[string]any{
+		"title":    msg.Title,
+		"message":  msg.Body,
+		"priority": msg.Priority * 2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(c.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The header, not ?token=, so the token stays out of proxy access logs.
+	req.Header.Set("X-Gotify-Key", c.Token)
+	return req, nil
+}
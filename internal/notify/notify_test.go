@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type received struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+func server(t *testing.T, status int) (*httptest.Server, chan received) {
+	t.Helper()
+	got := make(chan received, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got <- received{path: r.URL.Path, headers: r.Header, body: string(b)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestSendNtfy(t *testing.T) {
+	srv, got := server(t, http.StatusOK)
+	n := New(func() Config {
+		return Config{Provider: ProviderNtfy, URL: srv.URL + "/", Topic: "captains-log", Token: "tk"}
+	}, slog.Default())
+
+	err := n.Send(context.Background(), Message{Title: "Journal — café", Body: "done", Tags: []string{"white_check_mark"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-got
+	if r.path != "/captains-log" || r.body != "done" {
+		t.Errorf("got %s %q", r.path, r.body)
+	}
+	if h := r.headers.Get("Title"); h != "=?utf-8?q?Journal_=E2=80=94_caf=C3=A9?=" {
+		t.Errorf("Title = %q", h)
+	}
+	if r.headers.Get("Priority") != "3" || r.headers.Get("Tags") != "white_check_mark" || r.headers.Get("Authorization") != "Bearer tk" {
+		t.Errorf("headers = %v", r.headers)
+	}
+}
+
+func TestSendGotify(t *testing.T) {
+	srv, got := server(t, http.StatusOK)
+	n := New(func() Config {
+		return Config{Provider: ProviderGotify, URL: srv.URL, Token: "app"}
+	}, slog.Default())
+
+	if err := n.Send(context.Background(), Message{Title: "t", Body: "b", Priority: PriorityHigh}); err != nil {
+		t.Fatal(err)
+	}
+	r := <-got
+	if r.path != "/message" || r.headers.Get("X-Gotify-Key") != "app" {
+		t.Errorf("got %s key %q", r.path, r.headers.Get("X-Gotify-Key"))
+	}
+	var body struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+	if err := json.Unmarshal([]byte(r.body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Title != "t" || body.Message != "b" || body.Priority != 8 {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	srv, _ := server(t, http.StatusUnauthorized)
+	tests := []struct {
+		name string
+		c    Config
+		ok   func(error) bool
+	}{
+		{"off", Config{}, func(err error) bool { return errors.Is(err, ErrNotConfigured) }},
+		{"no topic", Config{Provider: ProviderNtfy}, func(err error) bool { return err != nil }},
+		{"gotify without token", Config{Provider: ProviderGotify, URL: srv.URL}, func(err error) bool { return err != nil }},
+		{"bad url", Config{Provider: ProviderNtfy, URL: "ftp://x", Topic: "a"}, func(err error) bool { return err != nil }},
+		{"rejected", Config{Provider: ProviderNtfy, URL: srv.URL, Topic: "a"}, func(err error) bool { return err != nil }},
+		{"unknown", Config{Provider: "pushover"}, func(err error) bool { return err != nil }},
+	}
+	for _, tt := range tests {
+		n := New(func() Config { return tt.c }, slog.Default())
+		if err := n.Send(context.Background(), Message{Title: "x"}); !tt.ok(err) {
+			t.Errorf("%s: Send = %v", tt.name, err)
+		}
+	}
+}
+
+func TestAlertCooldown(t *testing.T) {
+	srv, got := server(t, http.StatusOK)
+	n := New(func() Config {
+		return Config{Provider: ProviderNtfy, URL: srv.URL, Topic: "a"}
+	}, slog.Default())
+
+	n.Alert("whisper", Message{Title: "1"})
+	n.Alert("whisper", Message{Title: "2"}) // within the cooldown
+	n.Alert("llm", Message{Title: "3"})
+	n.Alert("", Message{Title: "4"})
+	n.Alert("", Message{Title: "4"})
+
+	titles := map[string]bool{}
+	titles4 := 0
+	for i := 0; i < 4; i++ {
+		select {
+		case r := <-got:
+			titles[r.headers.Get("Title")] = true
+			if r.headers.Get("Title") == "4" {
+				titles4++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for alerts")
+		}
+	}
+	if !titles["1"] || !titles["3"] || titles4 != 2 {
+		t.Errorf("alerts = %v (%d× 4), want 1, 3 and 4 twice", titles, titles4)
+	}
+	select {
+	case r := <-got:
+		t.Errorf("unexpected alert %q", r.headers.Get("Title"))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTransport(t *testing.T) {
+	status := http.StatusOK
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer backend.Close()
+
+	var mu sync.Mutex
+	var failures []string
+	client := &http.Client{Transport: Transport(nil, func(req *http.Request, err error) {
+		mu.Lock()
+		failures = append(failures, err.Error())
+		mu.Unlock()
+	})}
+
+	client.Get(backend.URL)
+	status = http.StatusNotFound
+	client.Get(backend.URL)
+	status = http.StatusBadGateway
+	client.Get(backend.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL, nil)
+	client.Do(req)
+	backend.Close()
+	client.Get(backend.URL)
+
+	if len(failures) != 2 || failures[0] != "HTTP 502" {
+		t.Errorf("failures = %q, want HTTP 502 and a connection error", failures)
+	}
+}
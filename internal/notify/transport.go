@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Transport reports failed backend calls to onFailure: network errors and
+// 5xx responses. A call the caller cancelled is not a failure of the
+// backend. The response is passed on unchanged.
+func Transport(base http.RoundTripper, onFailure func(req *http.Request, err error)) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return transport{base: base, onFailure: onFailure}
+}
+
+type transport struct {
+	base      http.RoundTripper
+	onFailure func(*http.Request, error)
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		if req.Context().Err() == nil && !errors.Is(err, context.Canceled) {
+			t.onFailure(req, err)
+		}
+	case resp.StatusCode >= 500:
+		t.onFailure(req, fmt.Errorf("HTTP %d", resp.StatusCode))
+	}
+	return resp, err
+}
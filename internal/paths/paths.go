@@ -0,0 +1,142 @@
+// Package paths decides where Captain's Log keeps its files: the
+// platform's own config and cache directories, unless overridden.
+//
+//	              config (settings, recordings, index, certs)  cache (update check)
+//	Linux, BSD    $XDG_CONFIG_HOME/captainslog, ~/.config/…    $XDG_CACHE_HOME/captainslog, ~/.cache/…
+//	macOS         ~/Library/Application Support/captainslog    ~/Library/Caches/captainslog
+//	Windows       %AppData%\captainslog                        %LocalAppData%\captainslog
+//
+// Versions before this package used $HOME/.config/captainslog everywhere;
+// Migrate moves that directory to the new place once.
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// App is the directory name under the platform directories.
+const App = "captainslog"
+
+// ConfigDir returns CAPTAINSLOG_CONFIG_DIR, or the platform config
+// directory for the app.
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("CAPTAINSLOG_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("find config dir (set CAPTAINSLOG_CONFIG_DIR): %w", err)
+	}
+	return filepath.Join(base, App), nil
+}
+
+// CacheDir returns CAPTAINSLOG_CACHE_DIR, or the platform cache directory
+// for the app. Anything in it can be deleted.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("CAPTAINSLOG_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("find cache dir (set CAPTAINSLOG_CACHE_DIR): %w", err)
+	}
+	return filepath.Join(base, App), nil
+}
+
+// LegacyConfigDir is where versions before this package kept everything,
+// or "" without $HOME.
+func LegacyConfigDir() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".config", App)
+}
+
+// Migrate moves the directory from to to, unless from doesn't exist or to
+// already has files, and reports whether it did. Where it can, it leaves a
+// symlink at from, so older versions and scripts still find the files.
+func Migrate(from, to string) (bool, error) {
+	if from == "" || filepath.Clean(from) == filepath.Clean(to) {
+		return false, nil
+	}
+	info, err := os.Stat(from)
+	if err != nil || !info.IsDir() {
+		return false, nil
+	}
+	if entries, err := os.ReadDir(to); err == nil && len(entries) > 0 {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return false, fmt.Errorf("create %s: %w", filepath.Dir(to), err)
+	}
+	// An empty to (say, made by an earlier start) is in the way of Rename.
+	os.Remove(to)
+	err = os.Rename(from, to)
+	if errors.Is(err, syscall.EXDEV) {
+		// Another filesystem: copy, then remove the original.
+		if err = copyTree(from, to); err == nil {
+			err = os.RemoveAll(from)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("move %s to %s: %w", from, to, err)
+	}
+	// Non-fatal: the data has moved; the link is a convenience.
+	os.Symlink(to, from)
+	return true, nil
+}
+
+// copyTree copies the directory from to to, keeping file modes.
+func copyTree(from, to string) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(to, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(dst, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dst)
+		case d.Type().IsRegular():
+			return copyFile(dst, path, info.Mode().Perm())
+		}
+		return nil // sockets, devices: nothing of ours
+	})
+}
+
+func copyFile(dst, src string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,88 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestConfigDir(t *testing.T) {
+	t.Setenv("CAPTAINSLOG_CONFIG_DIR", "/srv/captainslog")
+	if dir, _ := ConfigDir(); dir != "/srv/captainslog" {
+		t.Errorf("override: ConfigDir = %q", dir)
+	}
+	t.Setenv("CAPTAINSLOG_CONFIG_DIR", "")
+	if runtime.GOOS == "linux" {
+		t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+		if dir, _ := ConfigDir(); dir != "/xdg/config/captainslog" {
+			t.Errorf("XDG: ConfigDir = %q", dir)
+		}
+		t.Setenv("XDG_CACHE_HOME", "/xdg/cache")
+		if dir, _ := CacheDir(); dir != "/xdg/cache/captainslog" {
+			t.Errorf("XDG: CacheDir = %q", dir)
+		}
+	}
+	t.Setenv("HOME", "/home/a")
+	if dir := LegacyConfigDir(); dir != "/home/a/.config/captainslog" {
+		t.Errorf("LegacyConfigDir = %q", dir)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "old")
+	to := filepath.Join(root, "new", "captainslog")
+	os.MkdirAll(filepath.Join(from, "recordings"), 0755)
+	os.WriteFile(filepath.Join(from, "settings.json"), []byte(`{"language":"de"}`), 0600)
+	os.WriteFile(filepath.Join(from, "recordings", "a.webm"), []byte("audio"), 0644)
+	// An empty target, as an earlier start may have made, is no obstacle.
+	os.MkdirAll(to, 0755)
+
+	moved, err := Migrate(from, to)
+	if err != nil || !moved {
+		t.Fatalf("Migrate = %v, %v", moved, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(to, "settings.json")); string(data) != `{"language":"de"}` {
+		t.Errorf("settings.json = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(to, "recordings", "a.webm")); err != nil {
+		t.Error("recording not moved")
+	}
+	if runtime.GOOS != "windows" {
+		if data, _ := os.ReadFile(filepath.Join(from, "settings.json")); string(data) != `{"language":"de"}` {
+			t.Error("old path doesn't lead to the moved files")
+		}
+	}
+
+	// Once moved, nothing more happens; a populated target is never replaced.
+	if moved, err := Migrate(from, to); moved || err != nil {
+		t.Errorf("second Migrate = %v, %v", moved, err)
+	}
+	other := filepath.Join(root, "other")
+	os.MkdirAll(other, 0755)
+	os.WriteFile(filepath.Join(other, "x"), nil, 0644)
+	if moved, _ := Migrate(other, to); moved {
+		t.Error("Migrate replaced a directory with files in it")
+	}
+	if moved, err := Migrate(filepath.Join(root, "missing"), filepath.Join(root, "t2")); moved || err != nil {
+		t.Errorf("missing source: Migrate = %v, %v", moved, err)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	root := t.TempDir()
+	from, to := filepath.Join(root, "a"), filepath.Join(root, "b")
+	os.MkdirAll(filepath.Join(from, "tls"), 0700)
+	os.WriteFile(filepath.Join(from, "tls", "key.pem"), []byte("k"), 0600)
+	if err := copyTree(from, to); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(to, "tls", "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,399 @@
+// Package phrases reports the phrases a speaker leans on: the word
+// sequences that keep coming back across their notes ("at the end of the
+// day", "to be honest", "going forward") and filler words ("um", "you
+// know", "basically"), per week or month, so they can see whether a habit
+// is fading.
+//
+// Phrases are found without a list: every run of two to six words in a
+// sentence is counted, a level at a time (a six-word phrase can only be
+// frequent if both its five-word halves are), and a phrase that only ever
+// occurs inside a longer one is reported as the longer one.
+package phrases
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Periods the report compares.
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// ValidPeriod reports whether p names a period.
+func ValidPeriod(p string) bool { return p == PeriodWeek || p == PeriodMonth }
+
+// Limits of a phrase.
+const (
+	minWords = 2
+	maxWords = 6
+	// minCount and minNotes make a phrase a habit rather than a topic of
+	// one note.
+	minCount = 3
+	minNotes = 2
+)
+
+// DefaultTop is the number of phrases reported when Options.Top is 0.
+const DefaultTop = 20
+
+// Fillers are the filler words and phrases counted, lower-case.
+var Fillers = []string{
+	"um", "umm", "uh", "uhm", "er", "erm", "ah", "hmm",
+	"you know", "i mean", "kind of", "sort of", "basically", "actually",
+	"literally", "honestly", "i guess", "you see", "or something", "and stuff",
+}
+
+// stopwords are words too common for a phrase of nothing else ("of the",
+// "it was a") to say anything about how someone speaks.
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a an and are as at be but by do for from had has have he her his i if in
+		is it it's its me my no not of on or our she so that the their them then there they this to up was
+		we were what when which who will with you your`) {
+		stopwords[w] = true
+	}
+}
+
+// Note is one transcript in the report.
+type Note struct {
+	Time time.Time
+	Text string
+}
+
+// Options tune a report.
+type Options struct {
+	Period   string         // PeriodWeek or PeriodMonth; "" = PeriodMonth
+	Top      int            // phrases reported; 0 = DefaultTop
+	Location *time.Location // where periods start; nil = time.Local
+}
+
+// Report is the phrase report over a set of notes.
+type Report struct {
+	Notes   int       `json:"notes"`
+	Words   int       `json:"words"`
+	From    time.Time `json:"from"` // the oldest note
+	To      time.Time `json:"to"`   // the newest
+	Period  string    `json:"period"`
+	Periods []Period  `json:"periods"` // oldest first, empty ones included
+	// Phrases are the most used, most first; Fillers every filler used.
+	Phrases []Count `json:"phrases"`
+	Fillers []Count `json:"fillers"`
+	// FillersPerThousand is fillers per 1,000 words, over all notes.
+	FillersPerThousand float64 `json:"fillers_per_1000_words"`
+}
+
+// Period is one week or month of the report.
+type Period struct {
+	Start              time.Time `json:"start"`
+	Notes              int       `json:"notes"`
+	Words              int       `json:"words"`
+	Fillers            int       `json:"fillers"`
+	FillersPerThousand float64   `json:"fillers_per_1000_words"`
+}
+
+// Count is how often a phrase or filler was said.
+type Count struct {
+	Text        string  `json:"text"`
+	Count       int     `json:"count"`
+	Notes       int     `json:"notes"` // notes it occurs in
+	PerThousand float64 `json:"per_1000_words"`
+	// ByPeriod counts it per period, in the order of Report.Periods.
+	ByPeriod []int `json:"by_period"`
+	// Change compares the last period with the one before, in uses per
+	// 1,000 words: negative is a habit fading. Nil with one period, or
+	// when either has no words.
+	Change *float64 `json:"change,omitempty"`
+}
+
+// note is a Note split into sentences of lower-case words.
+type note struct {
+	period    int
+	sentences [][]string
+}
+
+// Analyze builds the report over notes, in any order.
+func Analyze(notes []Note, opts Options) Report {
+	if !ValidPeriod(opts.Period) {
+		opts.Period = PeriodMonth
+	}
+	if opts.Top <= 0 {
+		opts.Top = DefaultTop
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	rep := Report{Notes: len(notes), Period: opts.Period, Periods: []Period{}, Phrases: []Count{}, Fillers: []Count{}}
+	if len(notes) == 0 {
+		return rep
+	}
+
+	rep.From, rep.To = notes[0].Time, notes[0].Time
+	for _, n := range notes {
+		if n.Time.Before(rep.From) {
+			rep.From = n.Time
+		}
+		if n.Time.After(rep.To) {
+			rep.To = n.Time
+		}
+	}
+	first := periodStart(rep.From, opts.Period, opts.Location)
+	for t := first; !t.After(rep.To); t = nextPeriod(t, opts.Period) {
+		rep.Periods = append(rep.Periods, Period{Start: t})
+	}
+	parsed := make([]note, len(notes))
+	for i, n := range notes {
+		p := 0
+		start := periodStart(n.Time, opts.Period, opts.Location)
+		for p+1 < len(rep.Periods) && !rep.Periods[p+1].Start.After(start) {
+			p++
+		}
+		parsed[i] = note{period: p, sentences: sentences(n.Text)}
+		rep.Periods[p].Notes++
+		for _, s := range parsed[i].sentences {
+			rep.Periods[p].Words += len(s)
+			rep.Words += len(s)
+		}
+	}
+
+	phrases := frequent(parsed)
+	if len(phrases) > opts.Top {
+		phrases = phrases[:opts.Top]
+	}
+	rep.Phrases = tally(parsed, phrases, rep)
+
+	rep.Fillers = tally(parsed, Fillers, rep)
+	kept := rep.Fillers[:0]
+	total := 0
+	for _, f := range rep.Fillers {
+		if f.Count > 0 {
+			kept = append(kept, f)
+			total += f.Count
+			for p, c := range f.ByPeriod {
+				rep.Periods[p].Fillers += c
+			}
+		}
+	}
+	rep.Fillers = kept
+	sort.SliceStable(rep.Fillers, func(i, j int) bool { return rep.Fillers[i].Count > rep.Fillers[j].Count })
+	rep.FillersPerThousand = perThousand(total, rep.Words)
+	for i := range rep.Periods {
+		rep.Periods[i].FillersPerThousand = perThousand(rep.Periods[i].Fillers, rep.Periods[i].Words)
+	}
+	return rep
+}
+
+// gram is a candidate phrase's tally.
+type gram struct {
+	count, notes, last int // last: index+1 of the last note counted
+	subsumed           bool
+}
+
+// frequent returns the habitual phrases of notes, most used first.
+func frequent(notes []note) []string {
+	levels := make([]map[string]*gram, maxWords+1)
+	for n := minWords; n <= maxWords; n++ {
+		level := map[string]*gram{}
+		for i, nt := range notes {
+			for _, s := range nt.sentences {
+				for at := 0; at+n <= len(s); at++ {
+					if n > minWords && (!isFrequent(levels[n-1], s[at:at+n-1]) || !isFrequent(levels[n-1], s[at+1:at+n])) {
+						continue
+					}
+					key := strings.Join(s[at:at+n], " ")
+					g := level[key]
+					if g == nil {
+						g = &gram{}
+						level[key] = g
+					}
+					g.count++
+					if g.last != i+1 {
+						g.notes++
+						g.last = i + 1
+					}
+				}
+			}
+		}
+		for key, g := range level {
+			if g.count < minCount {
+				delete(level, key)
+			}
+		}
+		levels[n] = level
+		if len(level) == 0 {
+			break
+		}
+	}
+
+	// A phrase that never occurs outside a longer one is that phrase.
+	for n := maxWords; n > minWords; n-- {
+		for key, g := range levels[n] {
+			words := strings.Fields(key)
+			for _, part := range [][]string{words[:n-1], words[1:]} {
+				if sub := levels[n-1][strings.Join(part, " ")]; sub != nil && sub.count == g.count {
+					sub.subsumed = true
+				}
+			}
+		}
+	}
+
+	type candidate struct {
+		text  string
+		words int
+		g     *gram
+	}
+	var out []candidate
+	for n := minWords; n <= maxWords; n++ {
+		for key, g := range levels[n] {
+			if g.subsumed || g.notes < minNotes || isFiller(key) || (n <= 3 && allStopwords(key)) {
+				continue
+			}
+			out = append(out, candidate{key, n, g})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].g.count != out[j].g.count {
+			return out[i].g.count > out[j].g.count
+		}
+		if out[i].words != out[j].words {
+			return out[i].words > out[j].words
+		}
+		return out[i].text < out[j].text
+	})
+	texts := make([]string, len(out))
+	for i, c := range out {
+		texts[i] = c.text
+	}
+	return texts
+}
+
+func isFrequent(level map[string]*gram, words []string) bool {
+	return level[strings.Join(words, " ")] != nil
+}
+
+// tally counts each of texts (lower-case, space-separated words) in notes.
+func tally(notes []note, texts []string, rep Report) []Count {
+	counts := make([]Count, len(texts))
+	index := map[string]int{}
+	for i, t := range texts {
+		counts[i] = Count{Text: t, ByPeriod: make([]int, len(rep.Periods))}
+		index[t] = i
+	}
+	last := make([]int, len(texts))
+	for ni, nt := range notes {
+		for _, s := range nt.sentences {
+			for at := range s {
+				for n := 1; n <= maxWords && at+n <= len(s); n++ {
+					i, ok := index[strings.Join(s[at:at+n], " ")]
+					if !ok {
+						continue
+					}
+					counts[i].Count++
+					counts[i].ByPeriod[nt.period]++
+					if last[i] != ni+1 {
+						counts[i].Notes++
+						last[i] = ni + 1
+					}
+				}
+			}
+		}
+	}
+	for i := range counts {
+		c := &counts[i]
+		c.PerThousand = perThousand(c.Count, rep.Words)
+		if p := len(rep.Periods); p >= 2 && rep.Periods[p-1].Words > 0 && rep.Periods[p-2].Words > 0 {
+			change := perThousand(c.ByPeriod[p-1], rep.Periods[p-1].Words) - perThousand(c.ByPeriod[p-2], rep.Periods[p-2].Words)
+			change = math.Round(change*100) / 100
+			c.Change = &change
+		}
+	}
+	return counts
+}
+
+// perThousand is n per 1,000 of words, to two decimals.
+func perThousand(n, words int) float64 {
+	if words == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*1000/float64(words)*100) / 100
+}
+
+func isFiller(text string) bool {
+	for _, f := range Fillers {
+		if f == text {
+			return true
+		}
+	}
+	return false
+}
+
+func allStopwords(text string) bool {
+	for _, w := range strings.Fields(text) {
+		if !stopwords[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// sentences splits text into sentences of lower-case words. Headings,
+// embeds and code fences, which a note template adds, are left out;
+// phrases never run across a sentence end.
+func sentences(text string) [][]string {
+	var out [][]string
+	var cur []string
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, cur)
+			cur = nil
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "![[") || strings.HasPrefix(trimmed, "```") {
+			flush()
+			continue
+		}
+		word := []rune{}
+		for _, r := range trimmed + "\n" {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r) || (r == '\'' || r == '’') && len(word) > 0:
+				if r == '’' {
+					r = '\''
+				}
+				word = append(word, unicode.ToLower(r))
+				continue
+			}
+			if len(word) > 0 {
+				cur = append(cur, strings.TrimRight(string(word), "'"))
+				word = word[:0]
+			}
+			if strings.ContainsRune(".?!;:\n", r) {
+				flush()
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+// periodStart is the start of the week (Monday) or month holding t.
+func periodStart(t time.Time, period string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	if period == PeriodWeek {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+func nextPeriod(t time.Time, period string) time.Time {
+	if period == PeriodWeek {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 1, 0)
+}
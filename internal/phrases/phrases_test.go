@@ -0,0 +1,86 @@
+package phrases
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 10, 0, 0, 0, time.UTC) }
+	notes := []Note{
+		{Time: day(3, 2), Text: "# Standup\n\nAt the end of the day, um, we ship. To be honest the tests are slow."},
+		{Time: day(3, 20), Text: "At the end of the day it works. Um, you know, to be honest I like it."},
+		{Time: day(5, 4), Text: "At the end of the day we decide. To be honest, basically, it is fine."},
+		{Time: day(5, 6), Text: "The build is green. Of the two, the first. Of the two, neither. Of the two, both."},
+	}
+	rep := Analyze(notes, Options{Period: PeriodMonth, Location: time.UTC})
+	if rep.Notes != 4 || !rep.From.Equal(day(3, 2)) || !rep.To.Equal(day(5, 6)) {
+		t.Errorf("notes %d, from %v, to %v", rep.Notes, rep.From, rep.To)
+	}
+	// March, April (empty) and May.
+	if len(rep.Periods) != 3 || rep.Periods[1].Notes != 0 || rep.Periods[2].Notes != 2 ||
+		!rep.Periods[2].Start.Equal(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("periods = %+v", rep.Periods)
+	}
+
+	var got []string
+	for _, p := range rep.Phrases {
+		got = append(got, p.Text)
+	}
+	// "end of the" and "to be" only occur inside the longer phrases; "of
+	// the two" is one note's and "of the" all stopwords.
+	if want := []string{"at the end of the day", "to be honest"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("phrases = %q, want %q", got, want)
+	}
+	end := rep.Phrases[0]
+	if end.Count != 3 || end.Notes != 3 || !reflect.DeepEqual(end.ByPeriod, []int{2, 0, 1}) {
+		t.Errorf("phrase = %+v", end)
+	}
+	// April has no words: nothing to compare May with.
+	if end.Change != nil {
+		t.Errorf("change = %v, want none", *end.Change)
+	}
+
+	fillers := map[string]int{}
+	for _, f := range rep.Fillers {
+		fillers[f.Text] = f.Count
+	}
+	if want := map[string]int{"um": 2, "you know": 1, "basically": 1}; !reflect.DeepEqual(fillers, want) {
+		t.Errorf("fillers = %v, want %v", fillers, want)
+	}
+	if rep.Periods[0].Fillers != 3 || rep.FillersPerThousand <= 0 {
+		t.Errorf("filler rate: %+v, %v", rep.Periods[0], rep.FillersPerThousand)
+	}
+}
+
+func TestChange(t *testing.T) {
+	notes := []Note{
+		{Time: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), Text: "Um, so um, this is um it."},
+		{Time: time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC), Text: "This is better, um, and calmer now."},
+	}
+	rep := Analyze(notes, Options{Period: PeriodWeek, Location: time.UTC})
+	if len(rep.Periods) != 2 || rep.Periods[0].Start.Weekday() != time.Monday {
+		t.Fatalf("periods = %+v", rep.Periods)
+	}
+	um := rep.Fillers[0]
+	// 3 in 7 words, then 1 in 7: 428.57 to 142.86 per 1,000.
+	if um.Text != "um" || um.Change == nil || *um.Change != -285.71 {
+		t.Errorf("um = %+v", um)
+	}
+}
+
+func TestSentences(t *testing.T) {
+	got := sentences("# Title\n![[memo.m4a]]\nIt’s fine. Isn't it?\nYes")
+	want := [][]string{{"it's", "fine"}, {"isn't", "it"}, {"yes"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sentences = %q, want %q", got, want)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	rep := Analyze(nil, Options{})
+	if rep.Period != PeriodMonth || rep.Phrases == nil || rep.Fillers == nil || rep.Periods == nil {
+		t.Errorf("empty report = %+v", rep)
+	}
+}
@@ -0,0 +1,150 @@
+// Package pipeline runs a user-defined sequence of LLM steps over a
+// transcription: clean it up, summarize it, pull out action items,
+// translate it.
+//
+// Steps run in order. Cleanup and translate rewrite the text and hand the
+// result to the next step; summarize and action items only report, so a
+// summary is always of the cleaned-up text and never feeds a later step.
+// Each step has a built-in prompt that the user can replace in settings.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+)
+
+// Step kinds.
+const (
+	KindCleanup     = "cleanup"
+	KindSummarize   = "summarize"
+	KindActionItems = "action_items"
+	KindTranslate   = "translate"
+)
+
+// Kinds lists the step kinds in the order they are usually run.
+var Kinds = []string{KindCleanup, KindSummarize, KindActionItems, KindTranslate}
+
+// MaxSteps bounds a pipeline; every step is a full LLM round trip.
+const MaxSteps = 10
+
+// DefaultPrompts are the system prompts used when a step has none.
+// Translate's contains %s for the target language.
+var DefaultPrompts = map[string]string{
+	KindCleanup: `Clean up the following dictated text: fix punctuation, capitalisation and obvious ` +
+		`transcription errors, and remove filler words. Keep the wording and meaning. ` +
+		`Reply with the cleaned text only.`,
+	KindSummarize: `Summarize the following dictated note in one or two sentences. ` +
+		`Reply with the summary only — no preamble, no quotes, no markdown.`,
+	KindActionItems: `List the action items in the following dictated note as a markdown checklist ` +
+		`("- [ ] ..."), one per line. Reply with the list only, or "None" if there are none.`,
+	KindTranslate: `Translate the following text into %s. Reply with the translation only.`,
+}
+
+// Step is one stage of a pipeline, as stored in settings.json.
+type Step struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name,omitempty"`     // label in results (default: the kind)
+	Prompt   string `json:"prompt,omitempty"`   // replaces the built-in system prompt
+	Language string `json:"language,omitempty"` // translate: target language, e.g. "German"
+}
+
+// label returns the step's name for results and errors.
+func (s Step) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Kind
+}
+
+// transforms reports whether the step's output replaces the text.
+func (s Step) transforms() bool {
+	return s.Kind == KindCleanup || s.Kind == KindTranslate
+}
+
+func (s Step) systemPrompt() string {
+	if s.Prompt != "" {
+		return s.Prompt
+	}
+	if s.Kind == KindTranslate {
+		return fmt.Sprintf(DefaultPrompts[KindTranslate], s.Language)
+	}
+	return DefaultPrompts[s.Kind]
+}
+
+// ErrInvalid wraps every Validate error.
+var ErrInvalid = errors.New("invalid pipeline")
+
+// Validate checks steps before they are saved or run.
+func Validate(steps []Step) error {
+	if len(steps) > MaxSteps {
+		return fmt.Errorf("%w: %d steps, at most %d", ErrInvalid, len(steps), MaxSteps)
+	}
+	for i, s := range steps {
+		if _, ok := DefaultPrompts[s.Kind]; !ok {
+			return fmt.Errorf("%w: step %d: unknown kind %q (available: %s)", ErrInvalid, i+1, s.Kind, strings.Join(Kinds, ", "))
+		}
+		if s.Kind == KindTranslate && s.Prompt == "" && strings.TrimSpace(s.Language) == "" {
+			return fmt.Errorf("%w: step %d: translate needs a language", ErrInvalid, i+1)
+		}
+	}
+	return nil
+}
+
+// Chatter is the LLM a pipeline runs on; *llm.Client implements it.
+type Chatter interface {
+	Chat(ctx context.Context, messages ...llm.Message) (string, error)
+}
+
+// StepResult is the output of one step.
+type StepResult struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Output     string `json:"output"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Text  string       `json:"text"` // after the cleanup and translate steps
+	Steps []StepResult `json:"steps"`
+}
+
+// Run applies steps to text in order. On an error it returns the steps
+// completed so far along with it.
+func Run(ctx context.Context, c Chatter, steps []Step, text string) (Result, error) {
+	if err := Validate(steps); err != nil {
+		return Result{}, err
+	}
+	res := Result{Text: strings.TrimSpace(text), Steps: []StepResult{}}
+	for i, s := range steps {
+		input := res.Text
+		if runes := []rune(input); len(runes) > llm.MaxInputRunes {
+			input = string(runes[:llm.MaxInputRunes])
+		}
+		start := time.Now()
+		out, err := c.Chat(ctx,
+			llm.Message{Role: "system", Content: s.systemPrompt()},
+			llm.Message{Role: "user", Content: input})
+		if err == nil && out == "" {
+			err = errors.New("llm returned an empty reply")
+		}
+		if err != nil {
+			return res, fmt.Errorf("step %d (%s): %w", i+1, s.label(), err)
+		}
+		res.Steps = append(res.Steps, StepResult{
+			Name:       s.label(),
+			Kind:       s.Kind,
+			Output:     out,
+			DurationMS: time.Since(start).Milliseconds(),
+		})
+		if s.transforms() {
+			res.Text = out
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+)
+
+// fakeLLM replies with the system prompt's first word and the input, so
+// tests can see which prompt each step used and what text it received.
+type fakeLLM struct {
+	calls []string
+	fail  int // 1-based call to fail; 0 never
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, msgs ...llm.Message) (string, error) {
+	f.calls = append(f.calls, msgs[1].Content)
+	if len(f.calls) == f.fail {
+		return "", errors.New("llm returned 500")
+	}
+	return strings.Fields(msgs[0].Content)[0] + "(" + msgs[1].Content + ")", nil
+}
+
+func TestRunChainsTransformingSteps(t *testing.T) {
+	f := &fakeLLM{}
+	res, err := Run(context.Background(), f, []Step{
+		{Kind: KindCleanup},
+		{Kind: KindSummarize},
+		{Kind: KindTranslate, Language: "German", Name: "de"},
+		{Kind: KindActionItems, Prompt: "Todos:"},
+	}, "  um hello  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "Translate(Clean(um hello))" {
+		t.Errorf("text = %q", res.Text)
+	}
+	want := []string{"Clean(um hello)", "Summarize(Clean(um hello))", "Translate(Clean(um hello))", "Todos:(Translate(Clean(um hello)))"}
+	for i, s := range res.Steps {
+		if s.Output != want[i] {
+			t.Errorf("step %d output = %q, want %q", i, s.Output, want[i])
+		}
+	}
+	if res.Steps[2].Name != "de" || res.Steps[1].Name != KindSummarize {
+		t.Errorf("names = %q, %q", res.Steps[2].Name, res.Steps[1].Name)
+	}
+}
+
+func TestRunStopsAtFailedStep(t *testing.T) {
+	f := &fakeLLM{fail: 2}
+	res, err := Run(context.Background(), f, []Step{{Kind: KindCleanup}, {Kind: KindSummarize}, {Kind: KindActionItems}}, "x")
+	if err == nil || !strings.Contains(err.Error(), "step 2 (summarize)") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(res.Steps) != 1 || len(f.calls) != 2 {
+		t.Errorf("ran %d calls, kept %d steps", len(f.calls), len(res.Steps))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []Step
+		ok    bool
+	}{
+		{"empty", nil, true},
+		{"all kinds", []Step{{Kind: KindCleanup}, {Kind: KindSummarize}, {Kind: KindActionItems}, {Kind: KindTranslate, Language: "fr"}}, true},
+		{"unknown kind", []Step{{Kind: "poem"}}, false},
+		{"translate without language", []Step{{Kind: KindTranslate}}, false},
+		{"translate with own prompt", []Step{{Kind: KindTranslate, Prompt: "Into Klingon."}}, true},
+		{"too many", make([]Step, MaxSteps+1), false},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.steps)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err %v doesn't wrap ErrInvalid", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Package preview renders a low-resolution video of a recording with its
+// subtitles burned in, to check cue timing before publishing.
+//
+// A subtitle file looks right in a text editor and still flashes a cue a
+// second early; the only way to know is to watch it. Previews are rendered
+// by ffmpeg's subtitles filter (libass) in the background, one at a time,
+// and kept for a day. Audio-only recordings get a black picture to carry
+// the captions. Without ffmpeg on $PATH there is no Manager.
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// DefaultHeight is the preview's height in pixels: enough to read the
+// captions, small enough to render quickly and download on a phone.
+const DefaultHeight = 360
+
+// MaxHeight caps Request.Height; a preview is not the final render.
+const MaxHeight = 720
+
+// DefaultRetention is how long finished previews are kept.
+const DefaultRetention = 24 * time.Hour
+
+// Files in a job's directory. Fixed names keep paths out of the filter
+// graph, where ':' and ',' would need escaping.
+const (
+	captionsFile = "captions.srt"
+	outputFile   = "preview.mp4"
+)
+
+// Job statuses.
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// ErrNotFound is returned for an unknown job ID.
+var ErrNotFound = errors.New("preview not found")
+
+// ErrNotDone is returned by File for a job without a finished preview.
+var ErrNotDone = errors.New("preview has not finished")
+
+// Request describes a preview to render.
+type Request struct {
+	TranscriptID string
+	Title        string  // for the download's file name
+	Media        string  // the recording or video; read, never modified
+	MoveMedia    bool    // Media is a temp file the job takes over and removes
+	Captions     []byte  // SRT
+	Height       int     // pixels; 0 = DefaultHeight
+	Duration     float64 // seconds, for progress when ffprobe can't tell
+}
+
+// Job is a snapshot of a preview render.
+type Job struct {
+	ID           string    `json:"id"`
+	TranscriptID string    `json:"transcript_id,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Status       string    `json:"status"`
+	Progress     float64   `json:"progress"` // 0–1, from ffmpeg's position in the media
+	Height       int       `json:"height"`
+	Video        bool      `json:"video"` // the media has a picture; false = captions on black
+	Size         int64     `json:"size,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	FinishedAt   time.Time `json:"finished_at,omitempty"`
+}
+
+// Manager renders and tracks previews.
+type Manager struct {
+	dir       string
+	ffmpeg    string
+	ffprobe   string // "" = not installed; audio is assumed and progress uses Request.Duration
+	retention time.Duration
+	logger    *slog.Logger
+	slot      chan struct{} // one render at a time: ffmpeg uses every core
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+}
+
+// NewManager returns a Manager keeping previews under dir, or nil when
+// ffmpeg isn't installed. Previews left from an earlier run are removed:
+// jobs live in memory and nothing could find them.
+func NewManager(dir string, logger *slog.Logger) *Manager {
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil
+	}
+	probe, _ := exec.LookPath("ffprobe")
+	os.RemoveAll(dir)
+	return newManager(dir, bin, probe, logger)
+}
+
+func newManager(dir, ffmpeg, ffprobe string, logger *slog.Logger) *Manager {
+	return &Manager{
+		dir:       dir,
+		ffmpeg:    ffmpeg,
+		ffprobe:   ffprobe,
+		retention: DefaultRetention,
+		logger:    logger,
+		slot:      make(chan struct{}, 1),
+		jobs:      map[string]*Job{},
+		cancels:   map[string]context.CancelFunc{},
+	}
+}
+
+// Available reports whether m can render.
+func (m *Manager) Available() bool { return m != nil }
+
+// Start queues a render and returns at once. The returned Job is a
+// snapshot; poll Get for progress.
+func (m *Manager) Start(req Request) (Job, error) {
+	m.prune()
+	if len(bytes.TrimSpace(req.Captions)) == 0 {
+		return Job{}, errors.New("no captions to burn in")
+	}
+	if req.Height <= 0 {
+		req.Height = DefaultHeight
+	}
+	req.Height = min(req.Height, MaxHeight) &^ 1 // libx264 needs even sizes
+	// Absolute, since ffmpeg runs in the job's directory.
+	media, err := filepath.Abs(req.Media)
+	if err != nil {
+		return Job{}, err
+	}
+	req.Media = media
+
+	job := &Job{
+		ID:           httputil.PreviewID.New(),
+		TranscriptID: req.TranscriptID,
+		Title:        req.Title,
+		Status:       StatusQueued,
+		Height:       req.Height,
+		CreatedAt:    time.Now().UTC(),
+	}
+	dir := filepath.Join(m.dir, job.ID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Job{}, fmt.Errorf("create preview dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, captionsFile), req.Captions, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return Job{}, fmt.Errorf("write captions: %w", err)
+	}
+	if req.MoveMedia {
+		media := filepath.Join(dir, "media"+filepath.Ext(req.Media))
+		if err := os.Rename(req.Media, media); err != nil {
+			os.RemoveAll(dir)
+			return Job{}, fmt.Errorf("keep upload: %w", err)
+		}
+		req.Media = media
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.cancels[job.ID] = cancel
+	snapshot := *job
+	m.mu.Unlock()
+
+	go m.run(ctx, job, dir, req)
+	return snapshot, nil
+}
+
+func (m *Manager) run(ctx context.Context, job *Job, dir string, req Request) {
+	var err error
+	defer func() {
+		m.mu.Lock()
+		switch {
+		case ctx.Err() != nil:
+			job.Status = StatusCanceled
+		case err != nil:
+			job.Status = StatusFailed
+			job.Error = err.Error()
+		default:
+			job.Status = StatusDone
+			job.Progress = 1
+			if info, statErr := os.Stat(filepath.Join(dir, outputFile)); statErr == nil {
+				job.Size = info.Size()
+			}
+		}
+		job.FinishedAt = time.Now().UTC()
+		m.cancels[job.ID]()
+		delete(m.cancels, job.ID)
+		status := job.Status
+		m.mu.Unlock()
+		if status != StatusDone {
+			os.RemoveAll(dir) // keep nothing but finished previews
+		}
+		m.logger.Info("caption preview finished", "id", job.ID, "transcript", job.TranscriptID, "status", status, "error", err)
+	}()
+	if req.MoveMedia {
+		defer os.Remove(req.Media) // the preview is all that's kept
+	}
+
+	select {
+	case m.slot <- struct{}{}:
+		defer func() { <-m.slot }()
+	case <-ctx.Done():
+		return
+	}
+
+	video, duration := m.probe(ctx, req.Media)
+	if duration <= 0 {
+		duration = req.Duration
+	}
+	m.mu.Lock()
+	job.Status = StatusRunning
+	job.Video = video
+	m.mu.Unlock()
+
+	err = m.render(ctx, dir, args(req.Media, video, req.Height), func(seconds float64) {
+		if duration <= 0 {
+			return
+		}
+		m.mu.Lock()
+		job.Progress = min(seconds/duration, 0.99)
+		m.mu.Unlock()
+	})
+}
+
+// render runs ffmpeg in dir, reporting the position it has reached.
+func (m *Manager) render(ctx context.Context, dir string, argv []string, progress func(seconds float64)) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.ffmpeg, argv...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	readProgress(out, progress)
+	if err := cmd.Wait(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:] // the last line says what went wrong
+		}
+		return fmt.Errorf("ffmpeg: %w: %s", err, msg)
+	}
+	return nil
+}
+
+// args is the ffmpeg command line rendering media with the captions in
+// the working directory burned in. Audio gets a black 16:9 picture.
+func args(media string, video bool, height int) []string {
+	argv := []string{"-nostdin", "-hide_banner", "-loglevel", "error", "-y",
+		"-progress", "pipe:1", "-nostats"}
+	if video {
+		argv = append(argv, "-i", media,
+			"-map", "0:v:0", "-map", "0:a:0?",
+			"-vf", fmt.Sprintf("scale=-2:%d,subtitles=%s", height, captionsFile))
+	} else {
+		width := (height*16/9 + 1) &^ 1
+		argv = append(argv, "-f", "lavfi", "-i", fmt.Sprintf("color=c=black:s=%dx%d:r=10", width, height),
+			"-i", media,
+			"-map", "0:v", "-map", "1:a:0",
+			"-vf", "subtitles="+captionsFile, "-shortest")
+	}
+	return append(argv,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "30", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "64k", "-ac", "1",
+		"-movflags", "+faststart", outputFile)
+}
+
+// readProgress reads ffmpeg's -progress output (key=value lines) and
+// reports each out_time_us in seconds.
+func readProgress(r io.Reader, progress func(seconds float64)) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if !ok || k != "out_time_us" {
+			continue
+		}
+		if us, err := strconv.ParseInt(v, 10, 64); err == nil && us >= 0 {
+			progress(float64(us) / 1e6)
+		}
+	}
+	io.Copy(io.Discard, r)
+}
+
+// probe reports whether media has a picture, and its duration in seconds.
+// Without ffprobe, or when it fails, media is taken for audio of unknown
+// length: the black picture works for any input with sound.
+func (m *Manager) probe(ctx context.Context, media string) (video bool, duration float64) {
+	if m.ffprobe == "" {
+		return false, 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, m.ffprobe, "-v", "error",
+		"-show_entries", "format=duration:stream=codec_type:stream_disposition=attached_pic",
+		"-of", "json", media).Output()
+	if err != nil {
+		return false, 0
+	}
+	return parseProbe(out)
+}
+
+func parseProbe(out []byte) (video bool, duration float64) {
+	var p struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType   string `json:"codec_type"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+	}
+	if json.Unmarshal(out, &p) != nil {
+		return false, 0
+	}
+	for _, s := range p.Streams {
+		// Cover art in an MP3 or M4A is a video stream of one frame.
+		if s.CodecType == "video" && s.Disposition.AttachedPic == 0 {
+			video = true
+		}
+	}
+	duration, _ = strconv.ParseFloat(p.Format.Duration, 64)
+	return video, duration
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns snapshots of all jobs, newest first.
+func (m *Manager) List() []Job {
+	m.prune()
+	m.mu.Lock()
+	out := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		out = append(out, *job)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// File returns the path of a finished preview.
+func (m *Manager) File(id string) (string, Job, error) {
+	job, ok := m.Get(id)
+	switch {
+	case !ok:
+		return "", Job{}, ErrNotFound
+	case job.Status != StatusDone:
+		return "", job, ErrNotDone
+	}
+	return filepath.Join(m.dir, id, outputFile), job, nil
+}
+
+// Remove cancels a queued or running job, or deletes a finished one and
+// its preview.
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[id]; !ok {
+		return ErrNotFound
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel() // run removes the files
+		return nil
+	}
+	delete(m.jobs, id)
+	return os.RemoveAll(filepath.Join(m.dir, id))
+}
+
+// prune deletes jobs, and their previews, that finished longer than the
+// retention ago.
+func (m *Manager) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, job := range m.jobs {
+		if !job.FinishedAt.IsZero() && time.Since(job.FinishedAt) > m.retention {
+			delete(m.jobs, id)
+			os.RemoveAll(filepath.Join(m.dir, id))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package preview
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArgs(t *testing.T) {
+	video := strings.Join(args("/rec/talk.mp4", true, 360), " ")
+	if !strings.Contains(video, "-i /rec/talk.mp4 -map 0:v:0 -map 0:a:0? -vf scale=-2:360,subtitles=captions.srt") {
+		t.Errorf("video args = %s", video)
+	}
+	audio := args("/rec/memo.webm", false, 360)
+	joined := strings.Join(audio, " ")
+	if !strings.Contains(joined, "color=c=black:s=640x360:r=10 -i /rec/memo.webm -map 0:v -map 1:a:0 -vf subtitles=captions.srt -shortest") {
+		t.Errorf("audio args = %s", joined)
+	}
+	if audio[len(audio)-1] != outputFile || !slices.Contains(audio, "pipe:1") {
+		t.Errorf("audio args = %s", joined)
+	}
+}
+
+func TestReadProgress(t *testing.T) {
+	var got []float64
+	readProgress(strings.NewReader("frame=10\nout_time_us=1500000\nout_time_us=N/A\nprogress=continue\nout_time_us=3000000\nprogress=end\n"),
+		func(s float64) { got = append(got, s) })
+	if !slices.Equal(got, []float64{1.5, 3}) {
+		t.Errorf("progress = %v", got)
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		video    bool
+		duration float64
+	}{
+		{"video", `{"streams":[{"codec_type":"video"},{"codec_type":"audio"}],"format":{"duration":"12.5"}}`, true, 12.5},
+		{"audio", `{"streams":[{"codec_type":"audio"}],"format":{"duration":"60.000000"}}`, false, 60},
+		{"cover art", `{"streams":[{"codec_type":"audio"},{"codec_type":"video","disposition":{"attached_pic":1}}],"format":{}}`, false, 0},
+		{"garbage", `not json`, false, 0},
+	}
+	for _, tt := range tests {
+		video, duration := parseProbe([]byte(tt.json))
+		if video != tt.video || duration != tt.duration {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, video, duration, tt.video, tt.duration)
+		}
+	}
+}
+
+// fakeFFmpeg writes a script that reports progress and writes the output
+// file, or fails when the captions mention "fail".
+func fakeFFmpeg(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	bin := filepath.Join(t.TempDir(), "ffmpeg")
+	script := `#!/bin/sh
+if grep -q fail captions.srt; then echo "Unable to open captions.srt" >&2; exit 1; fi
+echo out_time_us=5000000
+echo progress=end
+printf 'mp4' > preview.mp4
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := m.Get(id); job.Status != StatusQueued && job.Status != StatusRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("preview did not finish")
+	return Job{}
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	m := newManager(filepath.Join(dir, "previews"), fakeFFmpeg(t), "", slog.Default())
+	media := filepath.Join(dir, "memo.webm")
+	os.WriteFile(media, []byte("audio"), 0o644)
+
+	job, err := m.Start(Request{TranscriptID: "t1", Media: media, Captions: []byte("1\n00:00:00,000 --> 00:00:02,000\nHello\n"), Height: 1081})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Height != MaxHeight {
+		t.Errorf("height = %d, want %d", job.Height, MaxHeight)
+	}
+	job = wait(t, m, job.ID)
+	if job.Status != StatusDone || job.Progress != 1 || job.Size != 3 || job.Video {
+		t.Fatalf("job = %+v", job)
+	}
+	path, _, err := m.File(job.ID)
+	if data, _ := os.ReadFile(path); err != nil || string(data) != "mp4" {
+		t.Fatalf("File = %q %v", data, err)
+	}
+	if _, err := os.Stat(media); err != nil {
+		t.Errorf("the recording was touched: %v", err)
+	}
+
+	// A failed render keeps the error and no files; a moved upload is removed.
+	upload := filepath.Join(dir, "upload.mp4")
+	os.WriteFile(upload, []byte("video"), 0o644)
+	failed, err := m.Start(Request{Media: upload, MoveMedia: true, Captions: []byte("fail")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed = wait(t, m, failed.ID)
+	if failed.Status != StatusFailed || !strings.Contains(failed.Error, "Unable to open captions.srt") {
+		t.Errorf("failed job = %+v", failed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "previews", failed.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("failed job left files: %v", err)
+	}
+	if _, err := os.Stat(upload); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("upload not taken over: %v", err)
+	}
+	if _, _, err := m.File(failed.ID); !errors.Is(err, ErrNotDone) {
+		t.Errorf("File of failed job = %v", err)
+	}
+
+	if _, err := m.Start(Request{Media: media, Captions: []byte("  \n")}); err == nil {
+		t.Error("Start without captions succeeded")
+	}
+	if got := m.List(); len(got) != 2 {
+		t.Errorf("List = %d jobs, want 2", len(got))
+	}
+
+	// Finished jobs past the retention are pruned with their files.
+	m.retention = 0
+	time.Sleep(time.Millisecond)
+	if got := m.List(); len(got) != 0 {
+		t.Errorf("List after retention = %d jobs", len(got))
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("pruned preview left on disk: %v", err)
+	}
+	if err := m.Remove(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Remove of pruned job = %v", err)
+	}
+}
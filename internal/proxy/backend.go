@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+)
+
+// Backend types, as chosen by the backend_type setting.
+const (
+	BackendAuto       = "auto"       // try the OpenAI API, fall back to whisper.cpp on 404
+	BackendOpenAI     = "openai"     // faster-whisper-server, whisper-fastapi, LocalAI, OpenAI itself
+	BackendWhisperCpp = "whispercpp" // whisper.cpp's examples/server
+)
+
+// ValidBackendType reports whether t is a known backend type. An empty
+// type means auto.
+func ValidBackendType(t string) bool {
+	switch t {
+	case "", BackendAuto, BackendOpenAI, BackendWhisperCpp:
+		return true
+	}
+	return false
+}
+
+// Operations a backend performs.
+const (
+	opTranscribe = "transcribe"
+	opTranslate  = "translate"
+	opDetect     = "detect" // a transcription asked only for its language
+)
+
+// adapter describes how one kind of backend spells the OpenAI audio API.
+//
+// whisper.cpp's server takes the same multipart fields as OpenAI (file,
+// language, prompt, temperature, response_format) but on one endpoint,
+// /inference, with translation as a translate=true field instead of a
+// separate route. It has no /v1/models, and some versions report errors as
+// {"error": ...} with status 200. It transcribes in the language it was
+// started with unless asked for language=auto.
+type adapter struct {
+	name           string
+	transcribePath string
+	translatePath  string
+	healthPath     string
+	translateField bool // translation is a transcription with translate=true
+	autoLanguage   bool // detecting the language needs language=auto
+	errorsAs200    bool // a 200 body of {"error": ...} is a failure
+}
+
+var (
+	openAIAdapter = adapter{
+		name:           BackendOpenAI,
+		transcribePath: "/v1/audio/transcriptions",
+		translatePath:  "/v1/audio/translations",
+		healthPath:     "/v1/models",
+	}
+	whisperCppAdapter = adapter{
+		name:           BackendWhisperCpp,
+		transcribePath: "/inference",
+		translatePath:  "/inference",
+		healthPath:     "/",
+		translateField: true,
+		autoLanguage:   true,
+		errorsAs200:    true,
+	}
+)
+
+func (a adapter) path(op string) string {
+	if op == opTranslate {
+		return a.translatePath
+	}
+	return a.transcribePath
+}
+
+// WithBackendType selects the backend API (BackendAuto, BackendOpenAI or
+// BackendWhisperCpp). The default is BackendAuto.
+func WithBackendType(t string) Option {
+	return func(p *Proxy) {
+		switch t {
+		case BackendOpenAI:
+			p.fixed = &openAIAdapter
+		case BackendWhisperCpp:
+			p.fixed = &whisperCppAdapter
+		default:
+			p.fixed = nil
+		}
+	}
+}
+
+// adapterFor returns the adapter to use for b and whether it is still a
+// guess that sendTo may revise.
+func (p *Proxy) adapterFor(b *backend) (adapter, bool) {
+	if p.fixed != nil {
+		return *p.fixed, false
+	}
+	if a := b.detected.Load(); a != nil {
+		return *a, false
+	}
+	return openAIAdapter, true
+}
+
+// BackendType reports the backend API in use: the configured type, or in
+// auto mode the detected one ("auto" until the first request settles it).
+// With several backends it describes the first; see Backends for the rest.
+func (p *Proxy) BackendType() string {
+	a, guessing := p.adapterFor(p.backends[0])
+	if guessing {
+		return BackendAuto
+	}
+	return a.name
+}
+
+// do makes one request for op to t's backend in its dialect, with fields
+// set in the multipart body.
+func (p *Proxy) do(r *http.Request, t target, op string, body *spool.Buffer, contentType string, fields map[string][]string) (*http.Response, error) {
+	a := t.a
+	set := make(map[string][]string, len(fields)+1)
+	for k, v := range fields {
+		set[k] = v
+	}
+	if op == opTranslate && a.translateField {
+		set["translate"] = []string{"true"}
+	}
+	if op == opDetect && a.autoLanguage {
+		set["language"] = []string{"auto"}
+	}
+	q := t.b.queue
+	if q != nil {
+		position, wait, err := q.acquire(r.Context())
+		noteQueue(r.Context(), position, wait)
+		if err != nil {
+			return nil, err
+		}
+		if position > 0 {
+			p.logger.Info("whisper request waited for a backend slot", "backend", t.b.label, "position", position,
+				"waited", wait.Round(time.Millisecond))
+		}
+	}
+	req, err := p.newBackendRequest(r, t.b.url+a.path(op), body, contentType, set)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = p.client.Do(req); err == nil {
+			if q != nil {
+				resp.Body = &releaseBody{ReadCloser: resp.Body, release: sync.OnceFunc(q.release)}
+			}
+			return resp, nil
+		}
+	} else {
+		err = fmt.Errorf("build %s request: %w", op, err)
+	}
+	if q != nil {
+		q.release()
+	}
+	return nil, err
+}
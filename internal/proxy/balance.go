@@ -0,0 +1,400 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+)
+
+// Strategies for spreading requests over several backends.
+const (
+	StrategyRoundRobin = "round-robin" // take turns; skip backends that are down
+	StrategyFailover   = "failover"    // always the first healthy backend in list order
+)
+
+// ValidStrategy reports whether s is a known strategy. Empty means
+// round-robin.
+func ValidStrategy(s string) bool {
+	return s == "" || s == StrategyRoundRobin || s == StrategyFailover
+}
+
+// DefaultCooldown is how long a backend that failed a request is passed
+// over before it is tried again.
+const DefaultCooldown = 30 * time.Second
+
+// SplitURLs parses a comma-separated backend URL list, trimming spaces
+// and trailing slashes and dropping empty entries.
+func SplitURLs(list string) []string {
+	var out []string
+	for _, u := range strings.Split(list, ",") {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// WithBalancing sets how requests are spread over several backends and how
+// long a failed backend is skipped. Zero cooldown means DefaultCooldown.
+func WithBalancing(strategy string, cooldown time.Duration) Option {
+	return func(p *Proxy) {
+		p.strategy = strategy
+		if cooldown > 0 {
+			p.cooldown = cooldown
+		}
+	}
+}
+
+// backend is one Whisper server behind the proxy, with its health as seen
+// by recent requests and probes.
+type backend struct {
+	url           string
+	label         string                  // host[:port], for logs and metrics
+	detected      atomic.Pointer[adapter] // auto-detected API (nil = not yet known)
+	noDetectRoute atomic.Bool             // answered 404 on /detect-language
+	queue         *queue                  // slots shared through a Limiter; nil = no limit
+
+	mu        sync.Mutex
+	failures  int       // consecutive failed requests or probes
+	downUntil time.Time // skipped until then, unless nothing else is up
+	openUntil time.Time // with the circuit open (see CircuitBreaker), skipped until then regardless
+	lastError string
+	requests  int64
+	errors    int64
+	checked   time.Time
+}
+
+func newBackend(url string) *backend {
+	return &backend{url: url, label: backendLabel(url)}
+}
+
+func (b *backend) up(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.downUntil)
+}
+
+func (b *backend) succeeded() {
+	b.mu.Lock()
+	b.requests++
+	b.failures = 0
+	b.downUntil = time.Time{}
+	b.mu.Unlock()
+}
+
+// failed records a failed request, and reports whether it opened b's
+// circuit.
+func (b *backend) failed(err string, cooldown time.Duration, cb CircuitBreaker) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.errors++
+	return b.failedLocked(err, time.Now(), cooldown, cb)
+}
+
+func (b *backend) failedLocked(err string, now time.Time, cooldown time.Duration, cb CircuitBreaker) bool {
+	b.failures++
+	b.lastError = err
+	b.downUntil = now.Add(cooldown)
+	if !cb.tripped(b.failures) {
+		return false
+	}
+	b.openUntil = now.Add(cb.OpenFor)
+	return b.failures == cb.Failures
+}
+
+// BackendStatus describes one backend for /healthz.
+type BackendStatus struct {
+	Backend   string     `json:"backend"` // host[:port]
+	Type      string     `json:"type"`    // auto until detected, openai, whispercpp
+	Healthy   bool       `json:"healthy"`
+	Failures  int        `json:"consecutive_failures"`
+	LastError string     `json:"last_error,omitempty"`
+	DownUntil *time.Time `json:"down_until,omitempty"`
+	Requests  int64      `json:"requests"`
+	Errors    int64      `json:"errors"`
+	Checked   *time.Time `json:"last_checked,omitempty"`
+
+	// CircuitOpen is set while requests to the backend fail fast (see
+	// CircuitBreaker).
+	CircuitOpen bool `json:"circuit_open,omitempty"`
+	// InFlight and Queued count the requests holding and waiting for a
+	// slot (see Limiter); both are absent without a limit.
+	InFlight *int `json:"in_flight,omitempty"`
+	Queued   *int `json:"queued,omitempty"`
+}
+
+// Backends reports the health of every backend, in configured order.
+func (p *Proxy) Backends() []BackendStatus {
+	now := time.Now()
+	out := make([]BackendStatus, 0, len(p.backends))
+	for _, b := range p.backends {
+		a, guessing := p.adapterFor(b)
+		typ := a.name
+		if guessing {
+			typ = BackendAuto
+		}
+		b.mu.Lock()
+		st := BackendStatus{
+			Backend:   b.label,
+			Type:      typ,
+			Healthy:   !now.Before(b.downUntil),
+			Failures:  b.failures,
+			LastError: b.lastError,
+			Requests:  b.requests,
+			Errors:    b.errors,
+		}
+		if !st.Healthy {
+			until := b.downUntil
+			st.DownUntil = &until
+		}
+		st.CircuitOpen = p.breaker.tripped(b.failures) && now.Before(b.openUntil)
+		if b.queue != nil {
+			inFlight, queued := len(b.queue.slots), int(b.queue.waiting.Load())
+			st.InFlight, st.Queued = &inFlight, &queued
+		}
+		if !b.checked.IsZero() {
+			checked := b.checked
+			st.Checked = &checked
+		}
+		b.mu.Unlock()
+		out = append(out, st)
+	}
+	return out
+}
+
+// order returns the backends in the order a request should try them:
+// healthy ones first — rotated for round-robin, in list order for
+// failover — then those cooling down, as a last resort.
+func (p *Proxy) order() []*backend {
+	n := len(p.backends)
+	start := 0
+	if p.strategy != StrategyFailover && n > 1 {
+		start = int(p.next.Add(1)-1) % n
+	}
+	now := time.Now()
+	up := make([]*backend, 0, n)
+	var down []*backend
+	for i := 0; i < n; i++ {
+		b := p.backends[(start+i)%n]
+		if b.up(now) {
+			up = append(up, b)
+		} else {
+			down = append(down, b)
+		}
+	}
+	return append(up, down...)
+}
+
+// target is a backend and the API used to talk to it.
+type target struct {
+	b *backend
+	a adapter
+}
+
+// send posts body, with fields set in it, to the endpoint for op, failing
+// over to the next backend when one is unreachable or overloaded, and
+// trying them all again as the retry policy allows. The body is spooled,
+// so it can be replayed. A request the client cancelled is neither retried
+// nor counted against the backend. A response that took retries says how
+// many in RetriesHeader.
+func (p *Proxy) send(r *http.Request, op string, body *spool.Buffer, contentType string, fields map[string][]string) (*http.Response, target, error) {
+	var (
+		resp    *http.Response
+		t       target
+		err     error
+		retries int
+	)
+	for {
+		resp, t, err = p.sendRound(r, op, body, contentType, fields)
+		var open *CircuitOpenError
+		if r.Context().Err() != nil || retries >= p.retry.Attempts || errors.As(err, &open) ||
+			(err == nil && !p.retryable(resp.StatusCode)) {
+			break
+		}
+		wait := p.retry.delay(retries, resp)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+			resp.Body.Close()
+		}
+		retries++
+		p.logger.Warn("whisper request failed, retrying", "op", op, "retry", retries, "of", p.retry.Attempts,
+			"wait", wait, "error", reason)
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, t, r.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if resp != nil {
+		resp.Header.Del(RetriesHeader)
+		if retries > 0 {
+			resp.Header.Set(RetriesHeader, strconv.Itoa(retries))
+			p.logger.Info("whisper request retried", "op", op, "retries", retries, "status", resp.StatusCode)
+		}
+	}
+	return resp, t, err
+}
+
+// sendRound tries each backend once, in p.order, until one answers with
+// something other than a retryable status. Backends whose circuit is open
+// are passed over; with all of them open it returns a CircuitOpenError.
+func (p *Proxy) sendRound(r *http.Request, op string, body *spool.Buffer, contentType string, fields map[string][]string) (*http.Response, target, error) {
+	var (
+		resp  *http.Response
+		t     target
+		err   error
+		tried bool
+	)
+	backends := p.order()
+	for i, b := range backends {
+		if !b.admit(time.Now(), p.breaker) {
+			continue
+		}
+		tried = true
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, t, err = p.sendTo(r, b, op, body, contentType, fields)
+		if r.Context().Err() != nil {
+			return resp, t, err
+		}
+		if err == nil && !p.retryable(resp.StatusCode) {
+			b.succeeded()
+			return resp, t, nil
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		}
+		if b.failed(reason, p.cooldown, p.breaker) {
+			p.logger.Warn("whisper backend circuit open, failing fast", "backend", b.label,
+				"failures", p.breaker.Failures, "for", p.breaker.OpenFor, "error", reason)
+		}
+		if i < len(backends)-1 {
+			p.logger.Warn("whisper backend failed, trying the next one", "backend", b.label, "error", reason)
+		}
+	}
+	if !tried {
+		a, _ := p.adapterFor(backends[0])
+		return nil, target{backends[0], a}, p.circuitOpen(time.Now())
+	}
+	return resp, t, err
+}
+
+// sendTo makes the request to one backend. In auto mode, a 404 or 405 from
+// the OpenAI path means the server doesn't speak that API, so the same
+// body is tried on whisper.cpp's /inference; whichever answers is
+// remembered for that backend.
+func (p *Proxy) sendTo(r *http.Request, b *backend, op string, body *spool.Buffer, contentType string, fields map[string][]string) (*http.Response, target, error) {
+	a, guessing := p.adapterFor(b)
+	t := target{b, a}
+	resp, err := p.do(r, t, op, body, contentType, fields)
+	if err != nil || !guessing {
+		return resp, t, err
+	}
+	if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusMethodNotAllowed {
+		if resp.StatusCode < 500 {
+			b.detected.Store(&openAIAdapter)
+		}
+		return resp, t, nil
+	}
+
+	// Read the first answer now, so its backend slot is free for the
+	// second request (see Limiter).
+	first, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(first))
+
+	alt := target{b, whisperCppAdapter}
+	altResp, err := p.do(r, alt, op, body, contentType, fields)
+	if err != nil || altResp.StatusCode == http.StatusNotFound || altResp.StatusCode == http.StatusMethodNotAllowed {
+		if altResp != nil {
+			altResp.Body.Close()
+		}
+		// Neither API is there; report the original answer.
+		return resp, t, nil
+	}
+	resp.Body.Close()
+	b.detected.Store(&whisperCppAdapter)
+	p.logger.Info("detected whisper.cpp backend", "backend", b.label)
+	return altResp, alt, nil
+}
+
+// Health probes every backend and records the result. It returns nil if at
+// least one is reachable.
+//
+// Uses a dedicated short-timeout client (5s) to avoid blocking on the
+// transcription client timeout during health probes.
+func (p *Proxy) Health() error {
+	errs := make([]error, len(p.backends))
+	var wg sync.WaitGroup
+	for i, b := range p.backends {
+		wg.Add(1)
+		go func(i int, b *backend) {
+			defer wg.Done()
+			errs[i] = p.probe(b)
+		}(i, b)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("all %d backends unreachable: %w", len(errs), errors.Join(errs...))
+}
+
+func (p *Proxy) probe(b *backend) error {
+	a, _ := p.adapterFor(b)
+	ctx, cancel := context.WithTimeout(context.Background(), p.healthClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+a.healthPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.healthClient.Do(req)
+	b.mu.Lock()
+	b.checked = time.Now()
+	if err != nil {
+		if b.failedLocked(err.Error(), b.checked, p.cooldown, p.breaker) {
+			p.logger.Warn("whisper backend circuit open, failing fast", "backend", b.label,
+				"failures", p.breaker.Failures, "for", p.breaker.OpenFor, "error", err)
+		}
+	} else {
+		// Any HTTP answer means the server is up; a request that failed
+		// earlier may get its turn back.
+		b.failures = 0
+		b.downUntil = time.Time{}
+	}
+	b.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("backend %s unreachable: %w", b.label, err)
+	}
+	// Drain and close the body to return the connection to the pool.
+	// Without draining, the TCP connection stays open until GC, exhausting
+	// the transport's connection limit under repeated health checks.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10)) // cap at 1KB
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CircuitBreaker stops sending requests to a backend that keeps failing.
+// A backend that is merely cooling down is still tried when nothing else
+// is up; one whose circuit is open is not, so while the GPU box reboots
+// requests fail at once instead of each waiting out the client timeout.
+type CircuitBreaker struct {
+	Failures int           // consecutive failed requests or probes that open the circuit; 0 = no breaker
+	OpenFor  time.Duration // how long an open circuit fails fast before one request may try again
+}
+
+// WithCircuitBreaker sets the circuit breaker. Without it a backend is
+// always tried when no other is up.
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(p *Proxy) {
+		if cb.OpenFor <= 0 {
+			cb.OpenFor = DefaultCooldown
+		}
+		p.breaker = cb
+	}
+}
+
+// CircuitOpenError is returned when every backend's circuit is open.
+type CircuitOpenError struct {
+	RetryAfter time.Duration // until the first circuit lets a request through
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("whisper backend circuit open, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// tripped reports whether failures open the circuit.
+func (cb CircuitBreaker) tripped(failures int) bool {
+	return cb.Failures > 0 && failures >= cb.Failures
+}
+
+// admit reports whether a request may go to b. While b's circuit is open
+// it may not; once OpenFor has passed one request may, and the circuit
+// stays open for the others until that one succeeds or another OpenFor
+// passes.
+func (b *backend) admit(now time.Time, cb CircuitBreaker) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !cb.tripped(b.failures) {
+		return true
+	}
+	if now.Before(b.openUntil) {
+		return false
+	}
+	b.openUntil = now.Add(cb.OpenFor)
+	return true
+}
+
+// circuitOpen builds the error for a request no backend admitted.
+func (p *Proxy) circuitOpen(now time.Time) error {
+	wait := p.breaker.OpenFor
+	for _, b := range p.backends {
+		b.mu.Lock()
+		wait = min(wait, b.openUntil.Sub(now))
+		b.mu.Unlock()
+	}
+	return &CircuitOpenError{RetryAfter: max(wait, time.Second)}
+}
+
+// unavailable answers 503 with a Retry-After if err is a CircuitOpenError,
+// and reports whether it did.
+func unavailable(w http.ResponseWriter, err error) bool {
+	var open *CircuitOpenError
+	if !errors.As(err, &open) {
+		return false
+	}
+	secs := int(math.Ceil(open.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, fmt.Sprintf(`{"error": "transcription backend is down, failing fast — retry in %ds"}`, secs), http.StatusServiceUnavailable)
+	return true
+}
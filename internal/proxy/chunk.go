@@ -0,0 +1,367 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/export"
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+	"github.com/ryan-winkler/captainslog-whisper/internal/usage"
+)
+
+// Chunking says when and how long recordings are split (see WithChunking).
+type Chunking struct {
+	After       time.Duration // split recordings longer than this; 0 = never
+	Length      time.Duration // chunk length
+	Overlap     time.Duration // audio shared by neighbouring chunks
+	Concurrency int           // chunks in flight at once
+}
+
+// DefaultChunkOverlap is long enough to hold a word or two cut at either
+// side of a chunk boundary.
+const DefaultChunkOverlap = 5 * time.Second
+
+// minBitrate is the lowest bitrate (bytes per second) an upload is assumed
+// to have: Opus voice at 16 kbit/s. Smaller uploads can't be longer than
+// Chunking.After, so they aren't decoded to find out.
+const minBitrate = 2000
+
+// WithChunking transcribes recordings longer than opts().After in
+// overlapping chunks, several at a time, and stitches the segments back
+// together with their times corrected. It needs the ffmpeg Converter of
+// WithPreprocess (even with preprocessing itself off) to measure and cut
+// the audio.
+//
+// WHY chunk? One request for a three-hour meeting runs past every timeout
+// between the browser and the backend, and keeps one backend busy while
+// the others idle.
+func WithChunking(opts func() Chunking) Option {
+	return func(p *Proxy) { p.chunking = opts }
+}
+
+// chunkFormats are the response formats a chunked transcription can give.
+var chunkFormats = map[string]bool{"json": true, "verbose_json": true, "text": true, "srt": true, "vtt": true}
+
+// transcribeChunked answers the request in chunks if its recording is
+// long enough, and reports whether it did. When it returns false nothing
+// has been written and the request goes the usual way.
+func (p *Proxy) transcribeChunked(w http.ResponseWriter, r *http.Request, body *spool.Buffer, contentType, format string, words bool) bool {
+	if p.chunking == nil || !p.converter.Available() || !chunkFormats[format] {
+		return false
+	}
+	c := p.chunking()
+	if c.After <= 0 || c.Length <= c.Overlap || body.Size() < int64(c.After.Seconds())*minBitrate {
+		return false
+	}
+	fields, wavPath, err := p.splitUpload(r.Context(), body, contentType)
+	if err != nil {
+		p.logger.Warn("could not measure the recording, sending it whole", "error", err)
+		return false
+	}
+	defer os.Remove(wavPath)
+	wav, err := audio.OpenWAV(wavPath)
+	if err != nil {
+		p.logger.Warn("could not measure the recording, sending it whole", "error", err)
+		return false
+	}
+	defer wav.Close()
+	duration := wav.Duration()
+	if duration <= c.After.Seconds() {
+		return false
+	}
+
+	// The server's write timeout is sized for one request, not for an
+	// hour of audio; each chunk has the client timeout instead.
+	// Non-fatal: a ResponseWriter without deadlines has none to lift.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	chunks := audio.Plan(duration, c.Length.Seconds(), c.Overlap.Seconds())
+	start := time.Now()
+	p.logger.Info("transcribing in chunks", "duration", time.Duration(duration*float64(time.Second)).Round(time.Second), "chunks", len(chunks))
+	results, err := p.transcribeChunks(r, wav, chunks, fields, max(c.Concurrency, 1))
+	if err != nil {
+		p.logger.Error("chunked transcription failed", "error", err)
+		if unavailable(w, err) {
+			return true
+		}
+		http.Error(w, `{"error": "transcription backend failed on part of the recording"}`, http.StatusBadGateway)
+		return true
+	}
+	v := stitch(results, c.Overlap.Seconds())
+	usage.AddAudio(r.Context(), duration)
+	p.logger.Info("transcription proxied", "status", http.StatusOK, "chunks", len(chunks), "segments", len(v.Segments), "took", time.Since(start).Round(time.Millisecond))
+	p.writeStitched(w, v, duration, format, words)
+	return true
+}
+
+// splitUpload returns the form fields of the multipart body and its audio
+// saved as a mono 16 kHz WAV. The caller removes the WAV.
+func (p *Proxy) splitUpload(ctx context.Context, body *spool.Buffer, contentType string) (map[string][]string, string, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+	rd, err := body.Reader()
+	if err != nil {
+		return nil, "", err
+	}
+	var o audio.Options
+	if p.audioOpts != nil {
+		o = p.audioOpts()
+	}
+	fields := map[string][]string{}
+	wavPath := ""
+	reader := multipart.NewReader(rd, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if wavPath != "" {
+				os.Remove(wavPath)
+			}
+			return nil, "", fmt.Errorf("read multipart: %w", err)
+		}
+		name := part.FormName()
+		switch {
+		case name == "file" && part.FileName() != "" && wavPath == "":
+			if wavPath, err = p.converter.ToWAV(ctx, part, o); err != nil {
+				return nil, "", err
+			}
+		case part.FileName() == "":
+			val, _ := io.ReadAll(io.LimitReader(part, 64<<10))
+			fields[name] = append(fields[name], string(val))
+		}
+		part.Close()
+	}
+	if wavPath == "" {
+		return nil, "", errors.New("no file in the upload")
+	}
+	return fields, wavPath, nil
+}
+
+// chunkResult is one chunk's verbose_json.
+type chunkResult struct {
+	chunk    audio.Chunk
+	text     string
+	language string
+	segments []map[string]any
+}
+
+// transcribeChunks sends every chunk, at most concurrency at a time, and
+// returns the results in order. The first failure cancels the rest.
+func (p *Proxy) transcribeChunks(r *http.Request, wav *audio.WAV, chunks []audio.Chunk, fields map[string][]string, concurrency int) ([]chunkResult, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	results := make([]chunkResult, len(chunks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i, ch := range chunks {
+		wg.Add(1)
+		go func(i int, ch audio.Chunk) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+			res, err := p.transcribeChunk(r, wav, ch, i, fields)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("chunk %d of %d (%.0fs): %w", i+1, len(chunks), ch.Start, err)
+					cancel()
+				})
+				return
+			}
+			results[i] = res
+		}(i, ch)
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return results, firstErr
+}
+
+// transcribeChunk sends chunk ch as a request of its own, with the
+// client's fields and response_format verbose_json.
+func (p *Proxy) transcribeChunk(r *http.Request, wav *audio.WAV, ch audio.Chunk, i int, fields map[string][]string) (chunkResult, error) {
+	buf := spool.New(p.spoolMemory, p.spoolDir)
+	defer buf.Close()
+	mw := multipart.NewWriter(buf)
+	for name, values := range fields {
+		if name == "response_format" {
+			continue
+		}
+		for _, v := range values {
+			mw.WriteField(name, v)
+		}
+	}
+	mw.WriteField("response_format", "verbose_json")
+	fw, err := mw.CreateFormFile("file", fmt.Sprintf("chunk-%03d.wav", i+1))
+	if err != nil {
+		return chunkResult{}, err
+	}
+	if _, err := io.Copy(fw, wav.Reader(ch)); err != nil {
+		return chunkResult{}, fmt.Errorf("read audio: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return chunkResult{}, err
+	}
+
+	resp, tgt, err := p.send(r, opTranscribe, buf, mw.FormDataContentType(), nil)
+	if err != nil {
+		return chunkResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return chunkResult{}, fmt.Errorf("backend %s returned %d: %s", tgt.b.label, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var v struct {
+		Text     string           `json:"text"`
+		Language string           `json:"language"`
+		Segments []map[string]any `json:"segments"`
+		Error    any              `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return chunkResult{}, fmt.Errorf("decode response: %w", err)
+	}
+	if v.Error != nil && v.Text == "" {
+		return chunkResult{}, fmt.Errorf("backend %s reported an error: %v", tgt.b.label, v.Error)
+	}
+	p.metrics.observe(tgt.b.label, sourceNative, len(v.Segments))
+	return chunkResult{chunk: ch, text: v.Text, language: v.Language, segments: v.Segments}, nil
+}
+
+// stitched is the reassembled transcript.
+type stitched struct {
+	Text     string
+	Language string
+	Segments []map[string]any
+}
+
+// stitch joins the chunk results into one transcript. Times are moved by
+// each chunk's start. Where two chunks overlap, the cut is at the middle
+// of the overlap: a segment belongs to the chunk in which it starts before
+// the cut. A chunk without segments contributes its text whole.
+func stitch(results []chunkResult, overlap float64) stitched {
+	var v stitched
+	var texts []string
+	for i, res := range results {
+		if v.Language == "" {
+			v.Language = res.language
+		}
+		from, to := -1.0, -1.0 // -1 = open
+		if i > 0 {
+			from = res.chunk.Start + overlap/2
+		}
+		if i < len(results)-1 {
+			to = results[i+1].chunk.Start + overlap/2
+		}
+		if len(res.segments) == 0 {
+			if t := strings.TrimSpace(res.text); t != "" {
+				texts = append(texts, t)
+			}
+			continue
+		}
+		for _, seg := range res.segments {
+			start := num(seg["start"]) + res.chunk.Start
+			if (from >= 0 && start < from) || (to >= 0 && start >= to) {
+				continue
+			}
+			shift(seg, res.chunk.Start)
+			seg["id"] = len(v.Segments)
+			v.Segments = append(v.Segments, seg)
+			if t, _ := seg["text"].(string); strings.TrimSpace(t) != "" {
+				texts = append(texts, strings.TrimSpace(t))
+			}
+		}
+	}
+	v.Text = strings.Join(texts, " ")
+	return v
+}
+
+// shift moves a segment and its words by offset seconds.
+func shift(seg map[string]any, offset float64) {
+	for _, k := range []string{"start", "end"} {
+		if _, ok := seg[k]; ok {
+			seg[k] = num(seg[k]) + offset
+		}
+	}
+	if words, ok := seg["words"].([]any); ok {
+		for _, w := range words {
+			if wm, ok := w.(map[string]any); ok {
+				shift(wm, offset)
+			}
+		}
+	}
+}
+
+func num(v any) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+// writeStitched writes v in the format the client asked for.
+func (p *Proxy) writeStitched(w http.ResponseWriter, v stitched, duration float64, format string, words bool) {
+	segs := make([]export.Segment, len(v.Segments))
+	for i, s := range v.Segments {
+		text, _ := s["text"].(string)
+		segs[i] = export.Segment{Start: num(s["start"]), End: num(s["end"]), Text: text}
+	}
+	var out []byte
+	var err error
+	switch format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		out = []byte(v.Text + "\n")
+	case "srt", "vtt":
+		w.Header().Set("Content-Type", export.ContentType(format))
+		if format == "vtt" && words {
+			out = []byte(wordVTT(toVerbose(v.Segments)))
+		} else {
+			out, err = export.Render(format, export.Doc{Text: v.Text, Segments: segs})
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		out, err = json.Marshal(map[string]any{
+			"text":     v.Text,
+			"language": v.Language,
+			"duration": duration,
+			"segments": v.Segments,
+		})
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to assemble transcript"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+// toVerbose converts stitched segments for wordVTT.
+func toVerbose(segments []map[string]any) verboseResponse {
+	var v verboseResponse
+	if data, err := json.Marshal(map[string]any{"segments": segments}); err == nil {
+		json.Unmarshal(data, &v)
+	}
+	return v
+}
@@ -0,0 +1,290 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+)
+
+// DetectSample is how much of a recording DetectLanguage sends when ffmpeg
+// can cut it: Whisper decides the language from its first 30-second
+// window anyway.
+const DetectSample = 30 * time.Second
+
+// Detection is the answer of DetectLanguage.
+type Detection struct {
+	Language   string   `json:"language"`             // ISO 639-1 code where there is one ("haw", "yue" otherwise)
+	Name       string   `json:"name,omitempty"`       // English name, as Whisper spells it
+	Confidence *float64 `json:"confidence,omitempty"` // 0–1; absent when the backend doesn't say
+	Source     string   `json:"source"`               // "backend" (its own detect-language route) or "transcription"
+}
+
+// DetectLanguage handles POST /api/detect-language: a multipart upload
+// with a file, answered with a Detection.
+//
+// The backend gets a short sample, not the whole recording. A backend
+// with a /detect-language route (whisper-asr-webservice) is asked there,
+// which returns a confidence; anything else transcribes the sample as
+// verbose_json without a language and reports the language it found.
+// A backend answering 404 on the route isn't asked there again.
+func (p *Proxy) DetectLanguage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error": "method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w, r = p.trackQueue(w, r)
+	body, _, ok := p.readUpload(w, r)
+	if !ok {
+		return
+	}
+	defer body.Close()
+	start := time.Now()
+	d, err := p.detect(r, body, r.Header.Get("Content-Type"))
+	var bad *badUploadError
+	if errors.As(err, &bad) {
+		p.logger.Warn("language detection: bad upload", "error", err)
+		http.Error(w, `{"error": "expected a multipart upload with a file"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		p.logger.Error("language detection failed", "error", err)
+		if unavailable(w, err) {
+			return
+		}
+		http.Error(w, `{"error": "transcription backend could not detect the language"}`, http.StatusBadGateway)
+		return
+	}
+	if d.Language == "" {
+		p.logger.Warn("backend reported no language")
+		http.Error(w, `{"error": "transcription backend reported no language"}`, http.StatusBadGateway)
+		return
+	}
+	d.Name = whisperLanguages[d.Language]
+	p.logger.Info("language detected", "language", d.Language, "source", d.Source, "took", time.Since(start).Round(time.Millisecond))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// badUploadError is a multipart body detect found no audio in.
+type badUploadError struct{ err error }
+
+func (e *badUploadError) Error() string { return e.err.Error() }
+func (e *badUploadError) Unwrap() error { return e.err }
+
+// detect finds the language of the file in the multipart body, from a
+// sample of it: see DetectLanguage. A body without a file is a
+// badUploadError.
+func (p *Proxy) detect(r *http.Request, body *spool.Buffer, contentType string) (Detection, error) {
+	sample, err := p.detectSample(r.Context(), body, contentType, p.converter.Available())
+	if err != nil && p.converter.Available() {
+		p.logger.Warn("could not cut a sample, sending the whole file", "error", err)
+		sample, err = p.detectSample(r.Context(), body, contentType, false)
+	}
+	if err != nil {
+		return Detection{}, &badUploadError{err}
+	}
+	defer sample.Close()
+	d, err := p.detectByRoute(r, sample)
+	if err == nil && d.Language == "" {
+		d, err = p.detectByTranscription(r, sample)
+	}
+	return d, err
+}
+
+// detectSampleData is the audio DetectLanguage sends, spooled, with the
+// file name and type it goes out under.
+type detectSampleData struct {
+	*spool.Buffer
+	filename    string
+	contentType string
+}
+
+// detectSample takes the file out of the multipart body: with cut, its
+// first DetectSample seconds as WAV (which needs ffmpeg); without, whole.
+func (p *Proxy) detectSample(ctx context.Context, body *spool.Buffer, contentType string, cut bool) (*detectSampleData, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := body.Reader()
+	if err != nil {
+		return nil, err
+	}
+	reader := multipart.NewReader(rd, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("no file in the upload")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read multipart: %w", err)
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		defer part.Close()
+		if cut {
+			return p.cutSample(ctx, part)
+		}
+		s := &detectSampleData{Buffer: spool.New(p.spoolMemory, p.spoolDir), filename: part.FileName(), contentType: part.Header.Get("Content-Type")}
+		if _, err := s.ReadFrom(part); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		return s, nil
+	}
+}
+
+// cutSample converts src to WAV and keeps its first DetectSample seconds.
+func (p *Proxy) cutSample(ctx context.Context, src io.Reader) (*detectSampleData, error) {
+	wavPath, err := p.converter.ToWAV(ctx, src, audio.Options{Downmix: true})
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(wavPath)
+	wav, err := audio.OpenWAV(wavPath)
+	if err != nil {
+		return nil, err
+	}
+	defer wav.Close()
+	s := &detectSampleData{Buffer: spool.New(p.spoolMemory, p.spoolDir), filename: "sample.wav", contentType: "audio/wav"}
+	if _, err := s.ReadFrom(wav.Reader(audio.Chunk{Length: DetectSample.Seconds()})); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("read audio: %w", err)
+	}
+	return s, nil
+}
+
+// form builds a multipart body holding the sample as field, plus fields.
+func (s *detectSampleData) form(p *Proxy, field string, fields map[string]string) (*spool.Buffer, string, error) {
+	rd, err := s.Reader()
+	if err != nil {
+		return nil, "", err
+	}
+	buf := spool.New(p.spoolMemory, p.spoolDir)
+	mw := multipart.NewWriter(buf)
+	for name, v := range fields {
+		mw.WriteField(name, v)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field, quoteEscaper.Replace(s.filename)))
+	if s.contentType != "" {
+		h.Set("Content-Type", s.contentType)
+	} else {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	pw, err := mw.CreatePart(h)
+	if err == nil {
+		_, err = io.Copy(pw, rd)
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	if err != nil {
+		buf.Close()
+		return nil, "", err
+	}
+	return buf, mw.FormDataContentType(), nil
+}
+
+// detectByRoute asks the first backend's /detect-language route. An empty
+// Detection with no error means the backend has no such route, or it
+// failed there: transcribing will tell.
+func (p *Proxy) detectByRoute(r *http.Request, s *detectSampleData) (Detection, error) {
+	b := p.order()[0]
+	if b.noDetectRoute.Load() {
+		return Detection{}, nil
+	}
+	if a, guessing := p.adapterFor(b); !guessing && a.autoLanguage {
+		return Detection{}, nil // whisper.cpp has none
+	}
+	form, contentType, err := s.form(p, "audio_file", nil)
+	if err != nil {
+		return Detection{}, err
+	}
+	defer form.Close()
+	req, err := p.newBackendRequest(r, b.url+"/detect-language", form, contentType, nil)
+	if err != nil {
+		return Detection{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.logger.Warn("detect-language route failed, transcribing instead", "backend", b.label, "error", err)
+		return Detection{}, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		b.noDetectRoute.Store(true)
+		p.logger.Info("backend has no detect-language route, transcribing instead", "backend", b.label)
+		return Detection{}, nil
+	}
+	var v struct {
+		Code       string   `json:"language_code"`
+		Detected   string   `json:"detected_language"`
+		Confidence *float64 `json:"confidence"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&v) != nil {
+		p.logger.Warn("detect-language route failed, transcribing instead", "backend", b.label, "status", resp.StatusCode)
+		return Detection{}, nil
+	}
+	lang := languageCode(v.Code)
+	if lang == "" {
+		lang = languageCode(v.Detected)
+	}
+	return Detection{Language: lang, Confidence: v.Confidence, Source: "backend"}, nil
+}
+
+// detectByTranscription transcribes the sample with no language set and
+// reads the language from the verbose_json answer. faster-whisper reports
+// language_probability and whisper.cpp detected_language_probability;
+// OpenAI reports neither.
+func (p *Proxy) detectByTranscription(r *http.Request, s *detectSampleData) (Detection, error) {
+	form, contentType, err := s.form(p, "file", map[string]string{"response_format": "verbose_json", "temperature": "0"})
+	if err != nil {
+		return Detection{}, err
+	}
+	defer form.Close()
+	resp, tgt, err := p.send(r, opDetect, form, contentType, nil)
+	if err != nil {
+		return Detection{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Detection{}, fmt.Errorf("backend %s returned %d: %s", tgt.b.label, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var v struct {
+		Language            string   `json:"language"`
+		Probability         *float64 `json:"language_probability"`
+		Detected            string   `json:"detected_language"`
+		DetectedProbability *float64 `json:"detected_language_probability"`
+		Error               any      `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return Detection{}, fmt.Errorf("decode response: %w", err)
+	}
+	if v.Error != nil {
+		return Detection{}, fmt.Errorf("backend %s reported an error: %v", tgt.b.label, v.Error)
+	}
+	d := Detection{Language: languageCode(v.Detected), Confidence: v.DetectedProbability, Source: "transcription"}
+	if d.Language == "" {
+		d.Language = languageCode(v.Language)
+	}
+	if d.Confidence == nil {
+		d.Confidence = v.Probability
+	}
+	return d, nil
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errNotObject means the backend body is not a single JSON object, so it is
+// forwarded untouched.
+var errNotObject = errors.New("response is not a JSON object")
+
+// jsonShape is what scanResponse learns about a backend JSON response
+// without decoding it into memory.
+type jsonShape struct {
+	hasSegments bool    // "segments" key present (even if null)
+	hasWords    bool    // "words" key present: top-level word timings
+	hasText     bool    // "text" key present
+	hasError    bool    // "error" key present
+	segments    int     // number of elements when segments is an array
+	duration    float64 // top-level "duration" in seconds; 0 when absent
+	keys        int     // number of top-level keys
+	closeAt     int64   // byte offset of the closing '}'
+}
+
+// scanResponse walks the top level of a JSON object token by token.
+//
+// WHY not json.Unmarshal into a map? verbose_json for an hour of audio is
+// several megabytes of segments (with per-word timings on some backends).
+// Decoding it into map[string]interface{} and marshalling it back costs
+// several times that in memory, and reorders the backend's keys
+// alphabetically. We only need to know whether "segments" is there; the
+// bytes themselves are forwarded as the backend sent them.
+func scanResponse(r io.Reader) (jsonShape, error) {
+	var shape jsonShape
+	dec := json.NewDecoder(r)
+	// Numbers other than the duration are skipped; UseNumber avoids
+	// parsing them.
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return shape, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return shape, errNotObject
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return shape, err
+		}
+		key, _ := tok.(string)
+		shape.keys++
+		switch key {
+		case "text":
+			shape.hasText = true
+		case "error":
+			shape.hasError = true
+		case "words":
+			shape.hasWords = true
+		}
+		if key == "duration" {
+			tok, err := dec.Token()
+			if err != nil {
+				return shape, err
+			}
+			if n, ok := tok.(json.Number); ok {
+				shape.duration, _ = n.Float64()
+			} else if d, ok := tok.(json.Delim); ok && (d == '{' || d == '[') {
+				if err := skipNested(dec); err != nil {
+					return shape, err
+				}
+			}
+			continue
+		}
+		if key == "segments" {
+			shape.hasSegments = true
+			n, err := countArray(dec)
+			if err != nil {
+				return shape, err
+			}
+			shape.segments = n
+			continue
+		}
+		if err := skipValue(dec); err != nil {
+			return shape, err
+		}
+	}
+	// InputOffset is just past the '}' once it has been read.
+	if _, err := dec.Token(); err != nil {
+		return shape, err
+	}
+	shape.closeAt = dec.InputOffset() - 1
+	// Trailing data after the object means it isn't one JSON document.
+	if _, err := dec.Token(); err != io.EOF {
+		return shape, errNotObject
+	}
+	return shape, nil
+}
+
+// countArray consumes the next value and, if it is an array, returns the
+// number of elements in it.
+func countArray(dec *json.Decoder) (int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return 0, nil
+	}
+	if d != '[' {
+		return 0, skipNested(dec)
+	}
+	n := 0
+	for dec.More() {
+		n++
+		if err := skipValue(dec); err != nil {
+			return 0, err
+		}
+	}
+	_, err = dec.Token() // ']'
+	return n, err
+}
+
+// skipValue consumes the next value, however deeply nested.
+func skipValue(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); ok && (d == '{' || d == '[') {
+		return skipNested(dec)
+	}
+	return nil
+}
+
+// skipNested consumes tokens until the object or array just opened closes.
+func skipNested(dec *json.Decoder) error {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			default:
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// appendSegments writes the object from r with a "segments" key added
+// before its closing brace. Everything the backend sent is copied byte for
+// byte; only the tail is new.
+func appendSegments(w io.Writer, r io.Reader, shape jsonShape, segments any) error {
+	extra, err := json.Marshal(segments)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, r, shape.closeAt); err != nil {
+		return err
+	}
+	tail := make([]byte, 0, len(extra)+16)
+	if shape.keys > 0 {
+		tail = append(tail, ',')
+	}
+	tail = append(tail, `"segments":`...)
+	tail = append(tail, extra...)
+	tail = append(tail, '}')
+	_, err = w.Write(tail)
+	return err
+}
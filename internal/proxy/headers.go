@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultExposeHeaders are the backend response headers passed on to
+// clients when nothing is configured. Everything else the backend sends
+// (Server, Set-Cookie, its own CORS or auth headers) stays behind the proxy.
+var DefaultExposeHeaders = []string{"Content-Type", "Content-Language", "Content-Disposition", "Retry-After"}
+
+// neverForward are inbound headers that must not reach the backend even if
+// configured: our own credentials, and headers the transport owns.
+var neverForward = headerSet("Authorization", "Proxy-Authorization", "Cookie",
+	"Host", "Content-Length", "Content-Type", "Connection", "Keep-Alive",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade")
+
+// neverExpose are backend response headers that must not reach the client
+// even if configured. Content-Length is wrong once a JSON body is enriched;
+// Set-Cookie would plant the backend's cookies on our origin.
+var neverExpose = headerSet("Set-Cookie", "Content-Length", "Connection",
+	"Keep-Alive", "Trailer", "Transfer-Encoding", "Upgrade")
+
+// headerRules is an allow-list of header names. A trailing "*" matches a
+// prefix, so "X-Model-*" covers X-Model-Name and X-Model-Revision.
+type headerRules struct {
+	exact    map[string]bool
+	prefixes []string
+}
+
+func newHeaderRules(names []string) headerRules {
+	rules := headerRules{exact: map[string]bool{}}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(n, "*"); ok {
+			rules.prefixes = append(rules.prefixes, http.CanonicalHeaderKey(prefix))
+			continue
+		}
+		rules.exact[http.CanonicalHeaderKey(n)] = true
+	}
+	return rules
+}
+
+func (h headerRules) allows(name string) bool {
+	if h.exact[name] {
+		return true
+	}
+	for _, p := range h.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyHeaders copies the headers in src allowed by rules and not in deny.
+func copyHeaders(dst, src http.Header, rules headerRules, deny map[string]bool) {
+	for k, v := range src {
+		if deny[k] || !rules.allows(k) {
+			continue
+		}
+		for _, val := range v {
+			dst.Add(k, val)
+		}
+	}
+}
+
+// forwardHeaders copies the configured inbound headers onto a backend request.
+func (p *Proxy) forwardHeaders(dst *http.Request, src *http.Request) {
+	copyHeaders(dst.Header, src.Header, p.forward, neverForward)
+}
+
+// exposeHeaders copies the configured backend response headers to the client.
+// Call it before setting headers of our own, which then take precedence.
+func (p *Proxy) exposeHeaders(w http.ResponseWriter, resp *http.Response) {
+	copyHeaders(w.Header(), resp.Header, p.expose, neverExpose)
+	if n := resp.Header.Get(RetriesHeader); n != "" {
+		w.Header().Set(RetriesHeader, n)
+	}
+}
+
+func headerSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[http.CanonicalHeaderKey(n)] = true
+	}
+	return set
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+)
+
+// WithLanguageModels picks the model by language: a transcription that
+// names no model (or OpenAI's placeholder "whisper-1") goes out with the
+// model models maps its language to, such as an English-only distilled
+// model for "en". A request without a language is first run through
+// language detection, on a sample, so it can be mapped too; the detected
+// language is then sent along. models is called per request; an empty
+// map turns this off.
+func WithLanguageModels(models func() map[string]string) Option {
+	return func(p *Proxy) { p.langModels = models }
+}
+
+// ParseLanguageModels reads a "en=distil-large-v3, ja=large-v3" list.
+func ParseLanguageModels(list string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		lang, model, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want language=model", strings.TrimSpace(pair))
+		}
+		out[lang] = model
+	}
+	return CleanLanguageModels(out), nil
+}
+
+// CleanLanguageModels trims and lower-cases the languages and trims the
+// models of m, dropping entries with either empty.
+func CleanLanguageModels(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for lang, model := range m {
+		lang, model = strings.ToLower(strings.TrimSpace(lang)), strings.TrimSpace(model)
+		if lang != "" && model != "" {
+			out[lang] = model
+		}
+	}
+	return out
+}
+
+// minDetectConfidence is the detection confidence under which a detected
+// language isn't trusted to pick the model.
+const minDetectConfidence = 0.5
+
+// languageModel returns the fields to set so the request form goes out
+// with the model for its language, or nil to leave it as it is.
+func (p *Proxy) languageModel(r *http.Request, body *spool.Buffer, contentType string, form map[string]string) map[string][]string {
+	if p.langModels == nil || form == nil {
+		return nil
+	}
+	if m := form["model"]; m != "" && m != "whisper-1" {
+		return nil
+	}
+	models := p.langModels()
+	if len(models) == 0 {
+		return nil
+	}
+	lang := strings.ToLower(form["language"])
+	detected := lang == "" || lang == "auto" || lang == "und"
+	if detected {
+		d, err := p.detect(r, body, contentType)
+		if err != nil || d.Language == "" || (d.Confidence != nil && *d.Confidence < minDetectConfidence) {
+			p.logger.Warn("language unknown, the model is left to the backend", "error", err)
+			return nil
+		}
+		lang = d.Language
+	}
+	model := models[lang]
+	if model == "" {
+		return nil
+	}
+	p.logger.Info("model chosen by language", "language", lang, "model", model, "detected", detected)
+	fields := map[string][]string{"model": {model}}
+	if detected {
+		fields["language"] = []string{lang}
+	}
+	return fields
+}
+
+// withFields returns a copy of the multipart body src with fields set, or
+// nil if it can't be rewritten.
+func (p *Proxy) withFields(src *spool.Buffer, contentType string, fields map[string][]string) *spool.Buffer {
+	rd, err := src.Reader()
+	if err != nil {
+		return nil
+	}
+	out := spool.New(p.spoolMemory, p.spoolDir)
+	if err := setMultipartFields(out, rd, contentType, fields); err != nil {
+		p.logger.Warn("could not set the model for the language", "error", err)
+		out.Close()
+		return nil
+	}
+	return out
+}
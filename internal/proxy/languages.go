@@ -0,0 +1,68 @@
+package proxy
+
+import "strings"
+
+// whisperLanguages are the languages Whisper knows, by the code it uses —
+// ISO 639-1 where there is one — with the English name OpenAI-style
+// backends report in verbose_json. From openai/whisper's tokenizer.
+var whisperLanguages = map[string]string{
+	"en": "english", "zh": "chinese", "de": "german", "es": "spanish",
+	"ru": "russian", "ko": "korean", "fr": "french", "ja": "japanese",
+	"pt": "portuguese", "tr": "turkish", "pl": "polish", "ca": "catalan",
+	"nl": "dutch", "ar": "arabic", "sv": "swedish", "it": "italian",
+	"id": "indonesian", "hi": "hindi", "fi": "finnish", "vi": "vietnamese",
+	"he": "hebrew", "uk": "ukrainian", "el": "greek", "ms": "malay",
+	"cs": "czech", "ro": "romanian", "da": "danish", "hu": "hungarian",
+	"ta": "tamil", "no": "norwegian", "th": "thai", "ur": "urdu",
+	"hr": "croatian", "bg": "bulgarian", "lt": "lithuanian", "la": "latin",
+	"mi": "maori", "ml": "malayalam", "cy": "welsh", "sk": "slovak",
+	"te": "telugu", "fa": "persian", "lv": "latvian", "bn": "bengali",
+	"sr": "serbian", "az": "azerbaijani", "sl": "slovenian", "kn": "kannada",
+	"et": "estonian", "mk": "macedonian", "br": "breton", "eu": "basque",
+	"is": "icelandic", "hy": "armenian", "ne": "nepali", "mn": "mongolian",
+	"bs": "bosnian", "kk": "kazakh", "sq": "albanian", "sw": "swahili",
+	"gl": "galician", "mr": "marathi", "pa": "punjabi", "si": "sinhala",
+	"km": "khmer", "sn": "shona", "yo": "yoruba", "so": "somali",
+	"af": "afrikaans", "oc": "occitan", "ka": "georgian", "be": "belarusian",
+	"tg": "tajik", "sd": "sindhi", "gu": "gujarati", "am": "amharic",
+	"yi": "yiddish", "lo": "lao", "uz": "uzbek", "fo": "faroese",
+	"ht": "haitian creole", "ps": "pashto", "tk": "turkmen", "nn": "nynorsk",
+	"mt": "maltese", "sa": "sanskrit", "lb": "luxembourgish", "my": "myanmar",
+	"bo": "tibetan", "tl": "tagalog", "mg": "malagasy", "as": "assamese",
+	"tt": "tatar", "haw": "hawaiian", "ln": "lingala", "ha": "hausa",
+	"ba": "bashkir", "jw": "javanese", "su": "sundanese", "yue": "cantonese",
+}
+
+// languageCodes maps names back to codes, with the aliases Whisper accepts.
+var languageCodes = func() map[string]string {
+	m := map[string]string{
+		"burmese": "my", "valencian": "ca", "flemish": "nl", "haitian": "ht",
+		"letzeburgesch": "lb", "pushto": "ps", "panjabi": "pa", "moldavian": "ro",
+		"moldovan": "ro", "sinhalese": "si", "castilian": "es", "mandarin": "zh",
+	}
+	for code, name := range whisperLanguages {
+		m[name] = code
+	}
+	return m
+}()
+
+// languageCode turns what a backend reports as the language — a code
+// ("de"), a name ("german", "German") or a locale ("de-DE") — into a
+// code, or "" if it is none of those. Codes Whisper doesn't know, as a
+// fine-tuned model may report, are kept.
+func languageCode(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if code, ok := languageCodes[s]; ok {
+		return code
+	}
+	s, _, _ = strings.Cut(strings.ReplaceAll(s, "_", "-"), "-")
+	if len(s) < 2 || len(s) > 3 {
+		return ""
+	}
+	for _, c := range s {
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+	}
+	return s
+}
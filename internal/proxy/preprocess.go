@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+)
+
+// WithPreprocess converts uploads with conv before they are sent, as opts
+// says at the time of each request (see internal/audio). A nil conv, as
+// NewConverter returns without ffmpeg, turns it off.
+func WithPreprocess(conv *audio.Converter, opts func() audio.Options) Option {
+	return func(p *Proxy) {
+		p.converter = conv
+		p.audioOpts = opts
+	}
+}
+
+// preprocess returns a copy of the multipart body src with its audio
+// converted, or nil to send src as it is: preprocessing off, nothing to
+// gain, or ffmpeg failed. A failure is logged, never returned — the
+// backend may well read what ffmpeg couldn't.
+func (p *Proxy) preprocess(ctx context.Context, src *spool.Buffer, contentType string) *spool.Buffer {
+	if !p.converter.Available() || p.audioOpts == nil {
+		return nil
+	}
+	o := p.audioOpts()
+	if !o.Enabled() {
+		return nil
+	}
+	start := time.Now()
+	out, err := p.convertUpload(ctx, src, contentType, o)
+	if err != nil {
+		p.logger.Warn("audio preprocessing failed, sending the upload as-is", "error", err)
+		return nil
+	}
+	if out != nil {
+		p.logger.Info("audio preprocessed", "bytes_in", src.Size(), "bytes_out", out.Size(), "took", time.Since(start).Round(time.Millisecond))
+	}
+	return out
+}
+
+// convertUpload copies the multipart body src with its file part replaced
+// by the converted WAV. It returns nil when the upload is already mono
+// 16 kHz WAV and only downmixing was asked for.
+func (p *Proxy) convertUpload(ctx context.Context, src *spool.Buffer, contentType string, o audio.Options) (*spool.Buffer, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	boundary, ok := params["boundary"]
+	if !ok {
+		return nil, fmt.Errorf("multipart content type has no boundary")
+	}
+	rd, err := src.Reader()
+	if err != nil {
+		return nil, err
+	}
+	out := spool.New(p.spoolMemory, p.spoolDir)
+	reader := multipart.NewReader(rd, boundary)
+	writer := multipart.NewWriter(out)
+	if err := writer.SetBoundary(boundary); err != nil {
+		out.Close()
+		return nil, err
+	}
+	converted := false
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return nil, fmt.Errorf("read multipart: %w", err)
+		}
+		if part.FormName() == "file" && part.FileName() != "" && !converted {
+			head := bufio.NewReaderSize(part, 64)
+			if header, _ := head.Peek(44); !o.Normalize && !o.TrimSilence && audio.IsSpeechWAV(header) {
+				out.Close()
+				return nil, nil
+			}
+			if err := p.writeConverted(ctx, writer, part.FileName(), head, o); err != nil {
+				out.Close()
+				return nil, err
+			}
+			converted = true
+			part.Close()
+			continue
+		}
+		pw, err := writer.CreatePart(part.Header)
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		if _, err := io.Copy(pw, part); err != nil {
+			out.Close()
+			return nil, fmt.Errorf("copy part %q: %w", part.FormName(), err)
+		}
+		part.Close()
+	}
+	if err := writer.Close(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if !converted {
+		out.Close()
+		return nil, nil
+	}
+	return out, nil
+}
+
+// writeConverted converts audio and writes it as the file part, renamed to
+// .wav: OpenAI-style backends pick the decoder by extension.
+func (p *Proxy) writeConverted(ctx context.Context, writer *multipart.Writer, filename string, audioData io.Reader, o audio.Options) error {
+	wav, err := p.converter.Convert(ctx, audioData, o)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(wav)
+	f, err := os.Open(wav)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	name := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".wav"
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(name)))
+	h.Set("Content-Type", "audio/wav")
+	pw, err := writer.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(pw, f)
+	return err
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
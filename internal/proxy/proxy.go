@@ -2,34 +2,145 @@
 package proxy
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+	"github.com/ryan-winkler/captainslog-whisper/internal/usage"
 )
 
 // Proxy forwards transcription requests to a Whisper-compatible backend.
 type Proxy struct {
-	backendURL   string
-	client       *http.Client // Long timeout for audio transcription (120s)
-	healthClient *http.Client // Short timeout for health checks (5s)
+	backends     []*backend // from the comma-separated URL list, in order
+	strategy     string     // StrategyRoundRobin or StrategyFailover
+	cooldown     time.Duration
+	next         atomic.Uint64 // round-robin position
+	client       *http.Client  // Long timeout for audio transcription (300s)
+	healthClient *http.Client  // Short timeout for health checks (5s)
 	logger       *slog.Logger
+	metrics      *enrichmentMetrics       // nil unless WithMetrics is used
+	spoolMemory  int64                    // upload bytes kept in RAM before spilling to disk
+	spoolDir     string                   // where spilled uploads go ("" = os.TempDir())
+	maxUpload    int64                    // largest upload accepted, in bytes
+	forward      headerRules              // inbound headers passed to the backend
+	expose       headerRules              // backend response headers passed to the client
+	fixed        *adapter                 // backend API set by WithBackendType; nil = auto-detect
+	words        func() bool              // ask for word timestamps on every transcription; nil = only when the client does
+	converter    *audio.Converter         // ffmpeg for WithPreprocess; nil = uploads go as they came
+	audioOpts    func() audio.Options     // preprocessing asked for, per request
+	chunking     func() Chunking          // when to split long recordings; nil = never
+	enrich       func() bool              // SRT fallback for JSON without segments; nil = always
+	noSegments   atomic.Bool              // the last JSON transcription came back without segments
+	retry        RetryPolicy              // another round over the backends after a transient failure
+	breaker      CircuitBreaker           // stop trying a backend that keeps failing
+	langModels   func() map[string]string // model per language; nil = the client's or the backend's
+	quality      *audio.Converter         // ffmpeg for WithAudioWarnings; nil = uploads aren't analyzed
+}
+
+// Option configures optional Proxy behaviour.
+type Option func(*Proxy)
+
+// WithTransport sets the RoundTripper used for all backend calls
+// (transcription, translation, and health checks).
+func WithTransport(rt http.RoundTripper) Option {
+	return func(p *Proxy) {
+		p.client.Transport = rt
+		p.healthClient.Transport = rt
+	}
+}
+
+// WithSpool sets how much of each upload is buffered in memory (the rest
+// spills to a temp file in dir) — see internal/spool.
+func WithSpool(memory int64, dir string) Option {
+	return func(p *Proxy) {
+		p.spoolMemory = memory
+		p.spoolDir = dir
+	}
+}
+
+// DefaultMaxUpload is the largest upload accepted without WithMaxUpload.
+const DefaultMaxUpload = 100 << 20
+
+// WithMaxUpload sets the largest upload, in bytes, that Transcribe,
+// Translate and DetectLanguage accept; larger ones are answered 413. n <= 0
+// keeps DefaultMaxUpload.
+func WithMaxUpload(n int64) Option {
+	return func(p *Proxy) {
+		if n > 0 {
+			p.maxUpload = n
+		}
+	}
+}
+
+// WithTimeout sets how long a transcription or translation may take
+// (default 300s). Health checks keep their short timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(p *Proxy) { p.client.Timeout = d }
+}
+
+// WithHeaders sets which inbound request headers are forwarded to the
+// backend and which backend response headers are passed back to clients.
+// Names are case-insensitive; a trailing "*" matches a prefix ("X-Model-*").
+// A nil expose keeps DefaultExposeHeaders. Credentials, cookies and
+// hop-by-hop headers are never passed in either direction.
+func WithHeaders(forward, expose []string) Option {
+	return func(p *Proxy) {
+		p.forward = newHeaderRules(forward)
+		if expose != nil {
+			p.expose = newHeaderRules(expose)
+		}
+	}
+}
+
+// WithWordTimestamps asks the backend for word-level timestamps on every
+// transcription while on returns true, not only when the client sends
+// word_timestamps=true. on is called per request, so a settings change
+// applies without rebuilding the proxy.
+func WithWordTimestamps(on func() bool) Option {
+	return func(p *Proxy) { p.words = on }
 }
 
-// New creates a new Proxy targeting the given backend URL.
-func New(backendURL string, logger *slog.Logger) *Proxy {
-	return &Proxy{
-		backendURL:   strings.TrimRight(backendURL, "/"),
+// WithEnrichment turns the SRT fallback on and off: while on returns
+// false, a JSON response without segments is forwarded as it is instead of
+// being enriched from a second, SRT request. on is called per request.
+func WithEnrichment(on func() bool) Option {
+	return func(p *Proxy) { p.enrich = on }
+}
+
+// New creates a new Proxy targeting the given backend URL, or a
+// comma-separated list of URLs to balance and fail over between.
+func New(backendURL string, logger *slog.Logger, opts ...Option) *Proxy {
+	p := &Proxy{
+		cooldown:     DefaultCooldown,
 		client:       &http.Client{Timeout: 300 * time.Second},
 		healthClient: &http.Client{Timeout: 5 * time.Second},
 		logger:       logger,
+		expose:       newHeaderRules(DefaultExposeHeaders),
+		maxUpload:    DefaultMaxUpload,
+	}
+	for _, u := range SplitURLs(backendURL) {
+		p.backends = append(p.backends, newBackend(u))
+	}
+	if len(p.backends) == 0 {
+		// Requests fail with a connection error, as before.
+		p.backends = []*backend{newBackend("")}
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
 // Transcribe handles POST /v1/audio/transcriptions
@@ -39,232 +150,472 @@ func New(backendURL string, logger *slog.Logger) *Proxy {
 //   - language: ISO language code (optional)
 //   - response_format: json, text, srt, vtt (default: json)
 //   - prompt: initial prompt (optional)
+//   - word_timestamps: "true" for per-word timings (optional)
 //
 // WHY verbose_json? When the client requests JSON format, we ask the backend
 // for verbose_json instead — this returns segments with timestamps natively,
 // eliminating the need for a second SRT request. If the backend doesn't
-// support verbose_json or doesn't return segments, we fall back to a parallel
-// SRT fetch. This optimization cuts transcription time nearly in half for
-// backends that support it (faster-whisper-server, whisper.cpp).
+// support verbose_json or doesn't return segments, we fall back to an SRT
+// fetch. This optimization cuts transcription time nearly in half for
+// backends that support it (faster-whisper-server, whisper.cpp). Once a
+// response has come back without segments, the SRT is asked for alongside
+// the next request rather than after it, so a backend without verbose_json
+// costs a second transcription but not twice the wait.
+//
+// With word timestamps on, the backend is asked for them in both spellings
+// (faster-whisper's word_timestamps and OpenAI's timestamp_granularities[]),
+// and a vtt request is answered from verbose_json with one cue per segment
+// and an inline <hh:mm:ss.mmm> tag before each word — see wordVTT.
 func (p *Proxy) Transcribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error": "method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
+	w, r = p.trackQueue(w, r)
 
-	// Limit upload size to 100MB
-	r.Body = http.MaxBytesReader(w, r.Body, 100<<20)
-
-	// Buffer the entire request body so we can replay it for fallback SRT
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		p.logger.Error("failed to read request body", "error", err)
-		http.Error(w, `{"error": "failed to read request body"}`, http.StatusBadRequest)
+	// Spool the request body so we can replay it for fallback SRT. Small
+	// uploads stay in memory; large ones spill to a temp file.
+	body, form, ok := p.readUpload(w, r)
+	if !ok {
 		return
 	}
+	defer body.Close()
 	contentType := r.Header.Get("Content-Type")
+	p.warnQuality(r.Context(), w, body, contentType)
+	if converted := p.preprocess(r.Context(), body, contentType); converted != nil {
+		defer converted.Close()
+		body = converted
+	}
+	if fields := p.languageModel(r, body, contentType, form); fields != nil {
+		if rewritten := p.withFields(body, contentType, fields); rewritten != nil {
+			defer rewritten.Close()
+			body = rewritten
+		}
+	}
 
-	backendURL := fmt.Sprintf("%s/v1/audio/transcriptions", p.backendURL)
-
-	// Determine the client's requested format by properly parsing the multipart
-	// form — NOT substring match on raw binary which can match audio data.
-	requestedFormat := extractMultipartField(bodyBytes, contentType, "response_format")
+	// The client's requested format comes from properly parsing the
+	// multipart form — NOT substring match on raw binary which can match
+	// audio data.
+	requestedFormat := form["response_format"]
 	if requestedFormat == "" {
 		requestedFormat = "json" // default
 	}
+	words := (p.words != nil && p.words()) || form["word_timestamps"] == "true"
+
+	if p.transcribeChunked(w, r, body, contentType, requestedFormat, words) {
+		return
+	}
 
 	// For json requests, upgrade to verbose_json to get segments natively.
 	// This eliminates the second HTTP call that previously doubled latency.
 	wantsJSON := requestedFormat == "json" || requestedFormat == "verbose_json"
-	var backendBody []byte
-	if requestedFormat == "json" {
-		// Try to rewrite existing response_format field: json → verbose_json
-		if extractMultipartField(bodyBytes, contentType, "response_format") != "" {
-			backendBody = replaceMIMEField(bodyBytes, contentType, "response_format", "verbose_json")
-		} else {
-			// No response_format field exists — add one
-			backendBody = addMIMEField(bodyBytes, contentType, "response_format", "verbose_json")
-		}
-		p.logger.Info("upgraded response_format to verbose_json for segment enrichment")
-	} else {
-		backendBody = bodyBytes
+	wantsVTT := words && requestedFormat == "vtt"
+	fields := map[string][]string{}
+	if requestedFormat == "json" || wantsVTT {
+		fields["response_format"] = []string{"verbose_json"}
+	}
+	if words {
+		fields["word_timestamps"] = []string{"true"}
+		fields["timestamp_granularities[]"] = []string{"segment", "word"}
+	}
+	if len(fields) > 0 && form == nil {
+		// Not fatal: the backend gets the original body and answers in
+		// plain json, which the SRT fallback below still enriches.
+		p.logger.Warn("could not parse the multipart form, sending body as-is")
+		fields, wantsVTT = nil, false
+	} else if len(fields) > 0 {
+		p.logger.Info("rewriting form fields for enrichment", "response_format", requestedFormat, "word_timestamps", words)
 	}
 
-	// Make the primary request
-	proxyReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, backendURL, bytes.NewReader(backendBody))
-	if err != nil {
-		p.logger.Error("failed to create proxy request", "error", err)
-		http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
-		return
+	enrich := wantsJSON && (p.enrich == nil || p.enrich())
+	var speculative chan srtResult
+	if enrich && p.noSegments.Load() {
+		ctx, cancel := context.WithCancel(r.Context())
+		var wg sync.WaitGroup
+		speculative = make(chan srtResult, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res srtResult
+			res.segments, res.result = p.srtSegments(r.WithContext(ctx), nil, body, contentType)
+			speculative <- res
+		}()
+		// Not needed if the response has segments after all; either way it
+		// must be done with body before body is closed.
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
 	}
-	proxyReq.Header.Set("Content-Type", contentType)
-	proxyReq.ContentLength = int64(len(backendBody))
 
-	resp, err := p.client.Do(proxyReq)
+	// Make the primary request
+	resp, tgt, err := p.send(r, opTranscribe, body, contentType, fields)
 	if err != nil {
-		p.logger.Error("backend request failed", "error", err, "url", backendURL)
+		p.logger.Error("backend request failed", "error", err, "backend", tgt.b.label)
+		if unavailable(w, err) {
+			return
+		}
 		http.Error(w, `{"error": "transcription backend unavailable"}`, http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
 
+	if wantsVTT && resp.StatusCode == http.StatusOK {
+		p.writeWordVTT(w, r, resp, tgt, body, contentType)
+		return
+	}
+
 	// If NOT a JSON request or the backend failed, just forward as-is
 	if !wantsJSON || resp.StatusCode != http.StatusOK {
-		for k, v := range resp.Header {
-			for _, val := range v {
-				w.Header().Add(k, val)
-			}
-		}
+		p.exposeHeaders(w, resp)
 		w.WriteHeader(resp.StatusCode)
 		io.Copy(w, resp.Body)
 		p.logger.Info("transcription proxied", "status", resp.StatusCode)
 		return
 	}
 
-	// JSON request — read and parse the response
-	jsonBody, err := io.ReadAll(resp.Body)
+	// JSON request — spool the response (verbose_json for long audio can be
+	// megabytes) and scan it for segments without decoding it.
+	respBody := spool.New(p.spoolMemory, p.spoolDir)
+	defer respBody.Close()
+	if _, err := respBody.ReadFrom(resp.Body); err != nil {
+		http.Error(w, `{"error": "failed to read backend response"}`, http.StatusInternalServerError)
+		return
+	}
+	rd, err := respBody.Reader()
 	if err != nil {
 		http.Error(w, `{"error": "failed to read backend response"}`, http.StatusInternalServerError)
 		return
 	}
-
-	var jsonResp map[string]interface{}
-	if err := json.Unmarshal(jsonBody, &jsonResp); err != nil {
+	shape, err := scanResponse(rd)
+	if err != nil {
 		// Not valid JSON — forward as-is
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(resp.StatusCode)
-		w.Write(jsonBody)
+		p.writeJSON(w, resp, respBody, resp.StatusCode)
 		return
 	}
+	if tgt.a.errorsAs200 && shape.hasError && !shape.hasText {
+		p.logger.Error("backend reported an error", "backend", tgt.b.label, "type", tgt.a.name)
+		p.writeJSON(w, resp, respBody, http.StatusBadGateway)
+		return
+	}
+	usage.AddAudio(r.Context(), shape.duration)
 
 	// Check if verbose_json gave us segments. If not, fall back to SRT.
 	// This handles backends that don't support verbose_json or return
-	// it without segment data.
-	if _, hasSegments := jsonResp["segments"]; !hasSegments {
-		p.logger.Info("verbose_json response lacks segments, falling back to parallel SRT fetch")
-		// Fall back: fetch SRT in parallel to enrich the response
-		srtBody := replaceMIMEField(bodyBytes, contentType, "response_format", "srt")
-		srtReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, backendURL, bytes.NewReader(srtBody))
-		if err == nil {
-			srtReq.Header.Set("Content-Type", contentType)
-			srtReq.ContentLength = int64(len(srtBody))
-			srtResp, srtErr := p.client.Do(srtReq)
-			if srtErr == nil && srtResp.StatusCode == http.StatusOK {
-				srtData, _ := io.ReadAll(srtResp.Body)
-				srtResp.Body.Close()
-				segments := parseSRT(string(srtData))
-				if len(segments) > 0 {
-					jsonResp["segments"] = segments
-					p.logger.Info("enriched JSON with SRT segments (fallback)", "count", len(segments))
-				}
-			} else if srtResp != nil {
-				srtResp.Body.Close()
+	// it without segment data. Word timings are timings enough.
+	timed := shape.hasSegments || shape.hasWords
+	p.noSegments.Store(!timed)
+	switch {
+	case timed:
+		p.logger.Info("verbose_json returned native segments")
+		p.metrics.observe(tgt.b.label, sourceNative, shape.segments)
+	case !enrich:
+		p.metrics.observe(tgt.b.label, sourceNone, 0)
+	default:
+		fallbackStart := time.Now()
+		var res srtResult
+		if speculative != nil {
+			p.logger.Info("verbose_json response lacks segments, waiting for the SRT fetched alongside")
+			res = <-speculative
+		} else {
+			p.logger.Info("verbose_json response lacks segments, falling back to SRT fetch")
+			res.segments, res.result = p.srtSegments(r, &tgt, body, contentType)
+		}
+		p.metrics.observeFallback(tgt.b.label, res.result, time.Since(fallbackStart))
+		if len(res.segments) == 0 {
+			p.metrics.observe(tgt.b.label, sourceNone, 0)
+			break
+		}
+		p.logger.Info("enriched JSON with SRT segments (fallback)", "count", len(res.segments))
+		p.metrics.observe(tgt.b.label, sourceSRTFallback, len(res.segments))
+		if rd, err := respBody.Reader(); err == nil {
+			p.exposeHeaders(w, resp)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			if err := appendSegments(w, rd, shape, res.segments); err != nil {
+				p.logger.Warn("failed to write enriched response", "error", err)
 			}
+			p.logger.Info("transcription proxied", "status", resp.StatusCode, "has_segments", true)
+			return
 		}
+	}
+
+	// Return the backend's JSON untouched
+	p.writeJSON(w, resp, respBody, http.StatusOK)
+	p.logger.Info("transcription proxied", "status", resp.StatusCode, "has_segments", shape.hasSegments)
+}
+
+// srtResult is the outcome of srtSegments.
+type srtResult struct {
+	segments []map[string]interface{}
+	result   string // fallbackOK, fallbackEmpty or fallbackError
+}
+
+// srtSegments asks for body as SRT and parses it into segments, to enrich
+// a JSON response without them. It asks tgt, or with tgt nil, whichever
+// backend send picks.
+func (p *Proxy) srtSegments(r *http.Request, tgt *target, body *spool.Buffer, contentType string) ([]map[string]interface{}, string) {
+	fields := map[string][]string{"response_format": {"srt"}}
+	var resp *http.Response
+	var err error
+	if tgt != nil {
+		resp, err = p.do(r, *tgt, opTranscribe, body, contentType, fields)
 	} else {
-		p.logger.Info("verbose_json returned native segments")
+		resp, _, err = p.send(r, opTranscribe, body, contentType, fields)
+	}
+	if err != nil {
+		return nil, fallbackError
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fallbackError
 	}
+	data, _ := io.ReadAll(resp.Body)
+	segments := parseSRT(string(data))
+	if len(segments) == 0 {
+		return nil, fallbackEmpty
+	}
+	return segments, fallbackOK
+}
 
-	// Return the (possibly enriched) JSON response
-	enriched, _ := json.Marshal(jsonResp)
+// writeJSON sends a spooled backend body to the client unchanged.
+func (p *Proxy) writeJSON(w http.ResponseWriter, resp *http.Response, buf *spool.Buffer, status int) {
+	rd, err := buf.Reader()
+	if err != nil {
+		http.Error(w, `{"error": "failed to read backend response"}`, http.StatusInternalServerError)
+		return
+	}
+	p.exposeHeaders(w, resp)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(enriched)
-	p.logger.Info("transcription proxied", "status", resp.StatusCode, "has_segments", jsonResp["segments"] != nil)
+	w.WriteHeader(status)
+	io.Copy(w, rd)
 }
 
-// extractMultipartField reads a single form-field value from a buffered
-// multipart body. It properly parses the multipart stream so it never matches
-// on binary audio data. Returns "" if the field is not found or parsing fails.
-func extractMultipartField(body []byte, contentType, fieldName string) string {
+// newBackendRequest builds a POST to url whose body is read from buf, with
+// fields set in it (see setMultipartFields) when there are any. The body
+// can be re-read (GetBody), so redirects that keep the body still work.
+//
+// WHY stream the rewrite instead of spooling a rewritten copy? The upload
+// may be a gigabyte on disk; a copy doubles the disk it takes and the
+// writing. Counting the rewritten length first costs one read of buf, and
+// keeps a Content-Length for backends that refuse chunked uploads.
+func (p *Proxy) newBackendRequest(r *http.Request, url string, buf *spool.Buffer, contentType string, fields map[string][]string) (*http.Request, error) {
+	open := func() (io.ReadCloser, error) {
+		rd, err := buf.Reader()
+		return io.NopCloser(rd), err
+	}
+	size := buf.Size()
+	if len(fields) > 0 {
+		rd, err := buf.Reader()
+		if err != nil {
+			return nil, err
+		}
+		var n countWriter
+		if err := setMultipartFields(&n, rd, contentType, fields); err != nil {
+			return nil, err
+		}
+		size = int64(n)
+		open = func() (io.ReadCloser, error) { return formReader(buf, contentType, fields) }
+	}
+	rd, err := open()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, rd)
+	if err != nil {
+		rd.Close()
+		return nil, err
+	}
+	p.forwardHeaders(req, r)
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = size
+	req.GetBody = open
+	return req, nil
+}
+
+// formReader streams buf with fields set. Closing it stops the copy.
+func formReader(buf *spool.Buffer, contentType string, fields map[string][]string) (io.ReadCloser, error) {
+	rd, err := buf.Reader()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(setMultipartFields(pw, rd, contentType, fields))
+	}()
+	return pr, nil
+}
+
+// countWriter counts the bytes written to it.
+type countWriter int64
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	*c += countWriter(len(b))
+	return len(b), nil
+}
+
+// maxFieldSize caps a form field's value read by formValues.
+const maxFieldSize = 1024
+
+// readUpload spools r's body, up to p.maxUpload bytes, and collects its
+// form fields on the same pass (see formValues), so they needn't be parsed
+// out of a spilled upload again. form is nil when the body is not a
+// well-formed multipart form. On failure it has answered the client; the
+// caller must otherwise Close body.
+func (p *Proxy) readUpload(w http.ResponseWriter, r *http.Request) (body *spool.Buffer, form map[string]string, ok bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, p.maxUpload)
+	body = spool.New(p.spoolMemory, p.spoolDir)
+
+	pr, pw := io.Pipe()
+	parsed := make(chan map[string]string, 1)
+	go func() {
+		form, err := formValues(pr, r.Header.Get("Content-Type"))
+		if err != nil {
+			form = nil
+		}
+		io.Copy(io.Discard, pr) // whatever follows the closing boundary
+		parsed <- form
+	}()
+	_, err := body.ReadFrom(io.TeeReader(r.Body, pw))
+	pw.CloseWithError(err)
+	form = <-parsed
+
+	if err != nil {
+		body.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			p.logger.Warn("upload too large", "limit", p.maxUpload)
+			http.Error(w, fmt.Sprintf(`{"error": "upload exceeds the %dMB limit"}`, p.maxUpload>>20), http.StatusRequestEntityTooLarge)
+			return nil, nil, false
+		}
+		p.logger.Error("failed to read request body", "error", err)
+		http.Error(w, `{"error": "failed to read request body"}`, http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return body, form, true
+}
+
+// formValues reads the form fields of a multipart body: the first value
+// of each, up to maxFieldSize bytes. File parts are skipped, never held in
+// memory. It properly parses the multipart stream so it never matches on
+// binary audio data.
+func formValues(body io.Reader, contentType string) (map[string]string, error) {
 	_, params, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return ""
+		return nil, err
 	}
 	boundary, ok := params["boundary"]
 	if !ok {
-		return ""
+		return nil, fmt.Errorf("multipart content type has no boundary")
 	}
-	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	form := map[string]string{}
+	reader := multipart.NewReader(body, boundary)
 	for {
 		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
 		if err != nil {
-			break
+			return form, err
 		}
 		name := part.FormName()
-		if name == "" || part.FileName() != "" {
-			// Skip file parts — don't read audio into memory
-			part.Close()
-			continue
-		}
-		if name == fieldName {
-			val, _ := io.ReadAll(io.LimitReader(part, 1024))
-			part.Close()
-			return strings.TrimSpace(string(val))
+		if _, seen := form[name]; name != "" && part.FileName() == "" && !seen {
+			val, _ := io.ReadAll(io.LimitReader(part, maxFieldSize))
+			form[name] = strings.TrimSpace(string(val))
 		}
 		part.Close()
 	}
-	return ""
 }
 
-// replaceMIMEField replaces a multipart form field value in a raw body.
-// This is a simple find-and-replace that works for typical multipart form data
-// where the field is formatted as: Content-Disposition: form-data; name="response_format"\r\n\r\njson
-func replaceMIMEField(body []byte, contentType, field, newValue string) []byte {
-	s := string(body)
-	// Find the field in the multipart body
-	fieldPattern := "name=\"" + field + "\""
-	idx := strings.Index(s, fieldPattern)
-	if idx < 0 {
-		return body // field not found, return as-is
-	}
-	// Find the value after \r\n\r\n
-	afterField := s[idx+len(fieldPattern):]
-	headerEnd := strings.Index(afterField, "\r\n\r\n")
-	if headerEnd < 0 {
-		return body
-	}
-	valueStart := idx + len(fieldPattern) + headerEnd + 4
-	// Find the boundary after the value
-	valueEnd := strings.Index(s[valueStart:], "\r\n")
-	if valueEnd < 0 {
-		return body
-	}
-	// Replace the old value with the new one
-	result := s[:valueStart] + newValue + s[valueStart+valueEnd:]
-	return []byte(result)
+// extractMultipartField reads a single form-field value from a multipart
+// body. Returns "" if the field is not found or parsing fails.
+func extractMultipartField(body io.Reader, contentType, fieldName string) string {
+	form, _ := formValues(body, contentType)
+	return form[fieldName]
 }
 
-// addMIMEField injects a new form field into a raw multipart body.
-// It inserts the field part before the final closing boundary marker.
-// WHY raw manipulation? We've already buffered the body bytes and need to
-// avoid re-parsing the multipart (which would require reading the full audio
-// file part into memory again).
-func addMIMEField(body []byte, contentType, field, value string) []byte {
+// setMultipartField copies the multipart body src to dst with field set
+// to value, replacing an existing field or appending one. The boundary is
+// kept, so the original Content-Type header stays valid.
+//
+// WHY re-encode instead of patching bytes? The body may be a 90MB file on
+// disk; streaming part by part never holds more than a copy buffer, and a
+// real multipart parser can't be fooled by audio bytes that happen to look
+// like a form header.
+func setMultipartField(dst io.Writer, src io.Reader, contentType, field, value string) error {
+	return setMultipartFields(dst, src, contentType, map[string][]string{field: {value}})
+}
+
+// setMultipartFields is setMultipartField for several fields. Each field
+// gets all of its values, in order, in place of its first occurrence;
+// repeats of it in src are dropped. Fields src lacks are appended in name
+// order.
+func setMultipartFields(dst io.Writer, src io.Reader, contentType string, fields map[string][]string) error {
 	_, params, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return body
+		return err
 	}
 	boundary, ok := params["boundary"]
 	if !ok {
-		return body
+		return fmt.Errorf("multipart content type has no boundary")
 	}
-
-	// Find the final boundary marker: --boundary--
-	closingBoundary := "--" + boundary + "--"
-	s := string(body)
-	idx := strings.LastIndex(s, closingBoundary)
-	if idx < 0 {
-		return body
+	reader := multipart.NewReader(src, boundary)
+	writer := multipart.NewWriter(dst)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return err
 	}
-
-	// Build the new field part
-	newPart := fmt.Sprintf("--%s\r\nContent-Disposition: form-data; name=%q\r\n\r\n%s\r\n",
-		boundary, field, value)
-
-	// Insert before the closing boundary
-	result := s[:idx] + newPart + s[idx:]
-	return []byte(result)
+	found := make(map[string]bool, len(fields))
+	writeField := func(name string) error {
+		for _, v := range fields[name] {
+			if err := writer.WriteField(name, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		// NextRawPart: copy parts verbatim, without undoing any
+		// Content-Transfer-Encoding the client applied.
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read multipart: %w", err)
+		}
+		if _, ok := fields[part.FormName()]; ok && part.FileName() == "" {
+			name := part.FormName()
+			part.Close()
+			if !found[name] {
+				if err := writeField(name); err != nil {
+					return err
+				}
+			}
+			found[name] = true
+			continue
+		}
+		pw, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(pw, part); err != nil {
+			return fmt.Errorf("copy part %q: %w", part.FormName(), err)
+		}
+		part.Close()
+	}
+	missing := make([]string, 0, len(fields))
+	for name := range fields {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		if err := writeField(name); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
 }
 
 // parseSRT parses an SRT subtitle string into segments with start/end times.
@@ -319,24 +670,28 @@ func (p *Proxy) Translate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
+	w, r = p.trackQueue(w, r)
 
-	r.Body = http.MaxBytesReader(w, r.Body, 100<<20)
-
-	backendURL := fmt.Sprintf("%s/v1/audio/translations", p.backendURL)
-
-	proxyReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, backendURL, r.Body)
-	if err != nil {
-		p.logger.Error("failed to create proxy request", "error", err)
-		http.Error(w, `{"error": "internal server error"}`, http.StatusInternalServerError)
+	// Spooled, because whisper.cpp needs a translate=true field added and
+	// auto-detection may send the body twice.
+	body, _, ok := p.readUpload(w, r)
+	if !ok {
 		return
 	}
+	defer body.Close()
+	p.warnQuality(r.Context(), w, body, r.Header.Get("Content-Type"))
+	if converted := p.preprocess(r.Context(), body, r.Header.Get("Content-Type")); converted != nil {
+		defer converted.Close()
+		body = converted
+	}
 
-	proxyReq.Header.Set("Content-Type", r.Header.Get("Content-Type"))
-	proxyReq.ContentLength = r.ContentLength
-
-	resp, err := p.client.Do(proxyReq)
+	resp, tgt, err := p.send(r, opTranslate, body, r.Header.Get("Content-Type"), nil)
+	backendURL := tgt.b.url + tgt.a.path(opTranslate)
 	if err != nil {
 		p.logger.Error("translation backend request failed", "error", err, "url", backendURL)
+		if unavailable(w, err) {
+			return
+		}
 		http.Error(w, `{"error": "translation backend unavailable — is the Whisper server running and does it support /v1/audio/translations?"}`, http.StatusBadGateway)
 		return
 	}
@@ -349,6 +704,7 @@ func (p *Proxy) Translate(w http.ResponseWriter, r *http.Request) {
 	if resp.StatusCode != http.StatusOK {
 		errBody, _ := io.ReadAll(resp.Body)
 		p.logger.Error("translation backend returned error", "status", resp.StatusCode, "body", string(errBody), "url", backendURL)
+		p.exposeHeaders(w, resp)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(resp.StatusCode)
 		// Forward the error body so the frontend can display it
@@ -360,27 +716,7 @@ func (p *Proxy) Translate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for k, v := range resp.Header {
-		for _, val := range v {
-			w.Header().Add(k, val)
-		}
-	}
+	p.exposeHeaders(w, resp)
 	w.WriteHeader(resp.StatusCode)
 	io.Copy(w, resp.Body)
 }
-
-// Health checks if the backend is reachable.
-// Uses a dedicated short-timeout client (5s) to avoid blocking on the
-// 120s transcription client timeout during health probes.
-func (p *Proxy) Health() error {
-	resp, err := p.healthClient.Get(fmt.Sprintf("%s/v1/models", p.backendURL))
-	if err != nil {
-		return fmt.Errorf("backend unreachable: %w", err)
-	}
-	// Drain and close the body to return the connection to the pool.
-	// Without draining, the TCP connection stays open until GC, exhausting
-	// the transport's connection limit under repeated health checks.
-	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10)) // cap at 1KB
-	resp.Body.Close()
-	return nil
-}
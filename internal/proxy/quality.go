@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+)
+
+// AudioWarningsHeader lists, comma-separated, what about an uploaded
+// recording is likely to hurt accuracy: audio.WarnLowSampleRate,
+// audio.WarnClipping or audio.WarnQuiet. It is absent when nothing is.
+const AudioWarningsHeader = "X-Captainslog-Audio-Warnings"
+
+// WithAudioWarnings measures each upload with conv before it is sent (see
+// audio.Converter.Analyze) and reports what it finds in
+// AudioWarningsHeader. A nil conv turns it off.
+func WithAudioWarnings(conv *audio.Converter) Option {
+	return func(p *Proxy) { p.quality = conv }
+}
+
+// warnQuality sets AudioWarningsHeader on w for the file in the multipart
+// body. It looks at the upload as it came, before preprocessing evens out
+// what it would warn about. A failure is logged and warns of nothing.
+func (p *Proxy) warnQuality(ctx context.Context, w http.ResponseWriter, body *spool.Buffer, contentType string) {
+	if !p.quality.Available() {
+		return
+	}
+	start := time.Now()
+	q, err := p.analyzeUpload(ctx, body, contentType)
+	if err != nil {
+		p.logger.Warn("could not analyze the upload's audio", "error", err)
+		return
+	}
+	warnings := q.Warnings()
+	p.logger.Info("audio analyzed", "sample_rate", q.SampleRate, "peak_db", q.Peak, "level_db", q.Level,
+		"clipped", q.Clipped, "warnings", warnings, "took", time.Since(start).Round(time.Millisecond))
+	if len(warnings) > 0 {
+		w.Header().Set(AudioWarningsHeader, strings.Join(warnings, ", "))
+	}
+}
+
+// analyzeUpload measures the file part of the multipart body.
+func (p *Proxy) analyzeUpload(ctx context.Context, body *spool.Buffer, contentType string) (audio.Quality, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return audio.Quality{}, err
+	}
+	rd, err := body.Reader()
+	if err != nil {
+		return audio.Quality{}, err
+	}
+	reader := multipart.NewReader(rd, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return audio.Quality{}, errors.New("no file in the upload")
+		}
+		if err != nil {
+			return audio.Quality{}, fmt.Errorf("read multipart: %w", err)
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			defer part.Close()
+			return p.quality.AnalyzeStream(ctx, part)
+		}
+		part.Close()
+	}
+}
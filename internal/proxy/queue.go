@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Headers on a response whose request waited for a backend slot (see
+// Limiter): its place in the queue on arrival, 1 being next, and how long
+// it waited, in milliseconds.
+const (
+	QueuePositionHeader = "X-Captainslog-Queue-Position"
+	QueueWaitHeader     = "X-Captainslog-Queue-Wait"
+)
+
+// Limiter caps the requests in flight to each backend; the rest wait
+// their turn, first come first served. A single-GPU faster-whisper
+// server transcribes one file at a time anyway, and ten at once only
+// fight over its memory. One Limiter may be shared by several Proxies,
+// so one rebuilt after a settings change, or made for a single request,
+// queues behind the same requests.
+type Limiter struct {
+	perBackend int
+	mu         sync.Mutex
+	queues     map[string]*queue // by backend URL
+}
+
+// NewLimiter returns a Limiter allowing perBackend requests in flight to
+// each backend, or nil, meaning no limit, for perBackend <= 0.
+func NewLimiter(perBackend int) *Limiter {
+	if perBackend <= 0 {
+		return nil
+	}
+	return &Limiter{perBackend: perBackend, queues: map[string]*queue{}}
+}
+
+func (l *Limiter) queue(url string) *queue {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	q := l.queues[url]
+	if q == nil {
+		q = &queue{slots: make(chan struct{}, l.perBackend)}
+		l.queues[url] = q
+	}
+	return q
+}
+
+// WithLimiter queues requests to each backend behind l's slots. A nil l
+// leaves them unlimited.
+func WithLimiter(l *Limiter) Option {
+	return func(p *Proxy) {
+		if l == nil {
+			return
+		}
+		for _, b := range p.backends {
+			b.queue = l.queue(b.url)
+		}
+	}
+}
+
+// queue is one backend's slots.
+type queue struct {
+	slots   chan struct{} // one token per request in flight
+	waiting atomic.Int32
+}
+
+// acquire waits for a slot. position is the place in the queue the
+// request took, 0 if a slot was free.
+func (q *queue) acquire(ctx context.Context) (position int, wait time.Duration, err error) {
+	select {
+	case q.slots <- struct{}{}:
+		return 0, 0, nil
+	default:
+	}
+	position = int(q.waiting.Add(1))
+	defer q.waiting.Add(-1)
+	start := time.Now()
+	select {
+	case q.slots <- struct{}{}:
+		return position, time.Since(start), nil
+	case <-ctx.Done():
+		return position, time.Since(start), ctx.Err()
+	}
+}
+
+func (q *queue) release() { <-q.slots }
+
+// releaseBody frees a slot once the response is read to the end or
+// closed, whichever comes first: the backend is done with the request
+// when it has sent the whole answer.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// queueStats is how long a client request waited for backend slots, over
+// all the backend requests made for it.
+type queueStats struct {
+	mu       sync.Mutex
+	position int // the furthest back it had to queue
+	wait     time.Duration
+}
+
+type queueStatsKey struct{}
+
+// noteQueue records a wait in the stats of ctx's request, if it has any.
+func noteQueue(ctx context.Context, position int, wait time.Duration) {
+	s, _ := ctx.Value(queueStatsKey{}).(*queueStats)
+	if s == nil || position == 0 {
+		return
+	}
+	s.mu.Lock()
+	s.position = max(s.position, position)
+	s.wait += wait
+	s.mu.Unlock()
+}
+
+// trackQueue sets up r to record its waits for backend slots, and w to
+// report them in QueuePositionHeader and QueueWaitHeader. Without a
+// Limiter both are returned as they are.
+func (p *Proxy) trackQueue(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	if p.backends[0].queue == nil {
+		return w, r
+	}
+	s := &queueStats{}
+	return &queueHeaderWriter{ResponseWriter: w, stats: s}, r.WithContext(context.WithValue(r.Context(), queueStatsKey{}, s))
+}
+
+// queueHeaderWriter adds the queue headers when the response starts.
+type queueHeaderWriter struct {
+	http.ResponseWriter
+	stats   *queueStats
+	started bool
+}
+
+func (w *queueHeaderWriter) WriteHeader(status int) {
+	if !w.started {
+		w.started = true
+		w.stats.mu.Lock()
+		if w.stats.position > 0 {
+			w.Header().Set(QueuePositionHeader, strconv.Itoa(w.stats.position))
+			w.Header().Set(QueueWaitHeader, strconv.FormatInt(w.stats.wait.Milliseconds(), 10))
+		}
+		w.stats.mu.Unlock()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *queueHeaderWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *queueHeaderWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetriesHeader is set on responses that needed retries, to how many.
+const RetriesHeader = "X-Captainslog-Retries"
+
+// DefaultRetryStatuses are the backend statuses retried when a policy names
+// none: the backend is down, overloaded or timed out, not the request bad.
+// A 500 is not retried: it is as likely to be the audio as the server.
+var DefaultRetryStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy is how a request that failed on every backend is tried
+// again. Failover to the next backend happens either way; a retry is
+// another round over all of them, after a wait.
+type RetryPolicy struct {
+	Attempts int           // retries after the first round; 0 = none
+	Backoff  time.Duration // wait before the first retry, doubled for each next one
+	// MaxBackoff caps a wait, including one a Retry-After header asks
+	// for; 0 = uncapped.
+	MaxBackoff time.Duration
+	// Statuses are the backend answers worth failing over and retrying,
+	// besides unreachable backends and timeouts; nil = DefaultRetryStatuses.
+	Statuses []int
+}
+
+// WithRetry sets the retry policy. Without it a request is not retried.
+func WithRetry(policy RetryPolicy) Option {
+	return func(p *Proxy) { p.retry = policy }
+}
+
+// retryable reports whether a backend answer is worth trying elsewhere or
+// again.
+func (p *Proxy) retryable(status int) bool {
+	if p.retry.Statuses == nil {
+		return slices.Contains(DefaultRetryStatuses, status)
+	}
+	return slices.Contains(p.retry.Statuses, status)
+}
+
+// delay is how long to wait before retry n (0-based): the backend's
+// Retry-After in seconds if resp has one, else the doubled backoff.
+func (rp RetryPolicy) delay(n int, resp *http.Response) time.Duration {
+	d := rp.Backoff
+	for i := 0; i < n && d < time.Hour; i++ {
+		d *= 2
+	}
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			d = time.Duration(secs) * time.Second
+		}
+	}
+	if rp.MaxBackoff > 0 {
+		d = min(d, rp.MaxBackoff)
+	}
+	return d
+}
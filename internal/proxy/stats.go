@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/metrics"
+)
+
+// Metric names recorded by the proxy. All are labelled by backend host.
+const (
+	metricJSONTranscriptions = "captainslog_proxy_json_transcriptions_total"
+	metricSegmentSource      = "captainslog_proxy_segment_source_total"
+	metricSegments           = "captainslog_proxy_segments_total"
+	metricSRTFallback        = "captainslog_proxy_srt_fallback_total"
+	metricSRTFallbackSeconds = "captainslog_proxy_srt_fallback_seconds_total"
+)
+
+// Where the segments in a JSON response came from.
+const (
+	sourceNative      = "native"       // verbose_json returned segments
+	sourceSRTFallback = "srt_fallback" // second SRT request supplied them
+	sourceNone        = "none"         // no segments at all
+)
+
+// Outcome of the SRT fallback request.
+const (
+	fallbackOK    = "ok"    // SRT parsed into segments
+	fallbackEmpty = "empty" // SRT returned but no segments parsed
+	fallbackError = "error" // SRT request failed or returned non-200
+)
+
+// enrichmentMetrics counts how JSON responses got their segments.
+// A nil *enrichmentMetrics is valid and records nothing.
+type enrichmentMetrics struct {
+	transcriptions  *metrics.Counter
+	segmentSource   *metrics.Counter
+	segments        *metrics.Counter
+	fallback        *metrics.Counter
+	fallbackSeconds *metrics.Counter
+}
+
+// WithMetrics records segment-enrichment metrics into reg.
+//
+// WHY? The SRT fallback doubles the backend work for every transcription.
+// These counters show, per backend, how often it fires — so after a backend
+// upgrade you can confirm verbose_json now returns segments natively.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(p *Proxy) {
+		p.metrics = &enrichmentMetrics{
+			transcriptions: reg.Counter(metricJSONTranscriptions,
+				"Successful JSON transcriptions eligible for segment enrichment.", "backend"),
+			segmentSource: reg.Counter(metricSegmentSource,
+				"JSON transcriptions by where their segments came from (native, srt_fallback, none).", "backend", "source"),
+			segments: reg.Counter(metricSegments,
+				"Segments returned to clients, by source.", "backend", "source"),
+			fallback: reg.Counter(metricSRTFallback,
+				"SRT fallback requests by outcome (ok, empty, error).", "backend", "result"),
+			fallbackSeconds: reg.Counter(metricSRTFallbackSeconds,
+				"Extra seconds spent waiting on SRT fallback requests.", "backend"),
+		}
+	}
+}
+
+func (m *enrichmentMetrics) observe(backend, source string, segments int) {
+	if m == nil {
+		return
+	}
+	m.transcriptions.Inc(backend)
+	m.segmentSource.Inc(backend, source)
+	m.segments.Add(float64(segments), backend, source)
+}
+
+func (m *enrichmentMetrics) observeFallback(backend, result string, took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.fallback.Inc(backend, result)
+	m.fallbackSeconds.Add(took.Seconds(), backend)
+}
+
+// backendLabel reduces a backend URL to host[:port] so credentials and paths
+// never end up in metric labels.
+func backendLabel(backendURL string) string {
+	u, err := url.Parse(backendURL)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
+
+// BackendStats summarises segment enrichment for one backend.
+type BackendStats struct {
+	Transcriptions  int     `json:"transcriptions"`
+	NativeSegments  int     `json:"native_segments"`
+	SRTFallbacks    int     `json:"srt_fallbacks"`
+	FallbackOK      int     `json:"fallback_ok"`
+	FallbackEmpty   int     `json:"fallback_empty"`
+	FallbackErrors  int     `json:"fallback_errors"`
+	NoSegments      int     `json:"no_segments"`
+	FallbackRate    float64 `json:"fallback_rate"`             // SRT fallbacks / transcriptions
+	FallbackSeconds float64 `json:"fallback_seconds"`          // total extra wait
+	AvgFallbackMS   float64 `json:"avg_fallback_ms,omitempty"` // mean cost of one fallback
+	AvgSegments     float64 `json:"avg_segments"`              // segments per transcription
+}
+
+// EnrichmentStats reads the proxy metrics from reg and summarises them per
+// backend host, for /api/stats.
+func EnrichmentStats(reg *metrics.Registry) map[string]BackendStats {
+	out := map[string]BackendStats{}
+	edit := func(backend string, fn func(*BackendStats)) {
+		s := out[backend]
+		fn(&s)
+		out[backend] = s
+	}
+
+	for _, s := range reg.Samples(metricJSONTranscriptions) {
+		edit(s.Labels["backend"], func(b *BackendStats) { b.Transcriptions = int(s.Value) })
+	}
+	for _, s := range reg.Samples(metricSegmentSource) {
+		edit(s.Labels["backend"], func(b *BackendStats) {
+			switch s.Labels["source"] {
+			case sourceNative:
+				b.NativeSegments = int(s.Value)
+			case sourceNone:
+				b.NoSegments = int(s.Value)
+			}
+		})
+	}
+	segments := map[string]float64{}
+	for _, s := range reg.Samples(metricSegments) {
+		segments[s.Labels["backend"]] += s.Value
+	}
+	for _, s := range reg.Samples(metricSRTFallback) {
+		edit(s.Labels["backend"], func(b *BackendStats) {
+			b.SRTFallbacks += int(s.Value)
+			switch s.Labels["result"] {
+			case fallbackOK:
+				b.FallbackOK = int(s.Value)
+			case fallbackEmpty:
+				b.FallbackEmpty = int(s.Value)
+			case fallbackError:
+				b.FallbackErrors = int(s.Value)
+			}
+		})
+	}
+	for _, s := range reg.Samples(metricSRTFallbackSeconds) {
+		edit(s.Labels["backend"], func(b *BackendStats) { b.FallbackSeconds = s.Value })
+	}
+
+	for backend, b := range out {
+		if b.Transcriptions > 0 {
+			b.FallbackRate = float64(b.SRTFallbacks) / float64(b.Transcriptions)
+			b.AvgSegments = segments[backend] / float64(b.Transcriptions)
+		}
+		if b.SRTFallbacks > 0 {
+			b.AvgFallbackMS = b.FallbackSeconds * 1000 / float64(b.SRTFallbacks)
+		}
+		out[backend] = b
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+	"github.com/ryan-winkler/captainslog-whisper/internal/usage"
+)
+
+// timedWord is one entry of a verbose_json "words" array.
+type timedWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// verboseSegment is the part of a verbose_json segment wordVTT needs.
+type verboseSegment struct {
+	Start float64     `json:"start"`
+	End   float64     `json:"end"`
+	Text  string      `json:"text"`
+	Words []timedWord `json:"words"`
+}
+
+// verboseResponse is the part of a verbose_json body wordVTT needs.
+// faster-whisper nests words in each segment; OpenAI lists them at the top
+// level when asked for timestamp_granularities[]=word.
+type verboseResponse struct {
+	Segments []verboseSegment `json:"segments"`
+	Words    []timedWord      `json:"words"`
+}
+
+// writeWordVTT answers a vtt request from the backend's verbose_json
+// response resp. A response without segments is not an error: the original
+// request (body) is sent again and the backend's own VTT forwarded.
+func (p *Proxy) writeWordVTT(w http.ResponseWriter, r *http.Request, resp *http.Response, tgt target, body *spool.Buffer, contentType string) {
+	var v verboseResponse
+	err := json.NewDecoder(resp.Body).Decode(&v)
+	if err == nil && len(v.Segments) > 0 {
+		p.exposeHeaders(w, resp)
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, wordVTT(v))
+		usage.AddAudio(r.Context(), v.Segments[len(v.Segments)-1].End)
+		p.metrics.observe(tgt.b.label, sourceNative, len(v.Segments))
+		p.logger.Info("transcription proxied", "status", resp.StatusCode, "format", "vtt", "word_timestamps", true)
+		return
+	}
+	p.logger.Info("verbose_json response lacks segments, fetching the backend's vtt", "error", err)
+	resp.Body.Close() // frees its backend slot for the next request (see Limiter)
+	vttResp, err := p.do(r, tgt, opTranscribe, body, contentType, nil)
+	if err != nil {
+		p.logger.Error("backend request failed", "error", err, "backend", tgt.b.label)
+		http.Error(w, `{"error": "transcription backend unavailable"}`, http.StatusBadGateway)
+		return
+	}
+	defer vttResp.Body.Close()
+	p.exposeHeaders(w, vttResp)
+	w.WriteHeader(vttResp.StatusCode)
+	io.Copy(w, vttResp.Body)
+	p.logger.Info("transcription proxied", "status", vttResp.StatusCode, "format", "vtt")
+}
+
+// wordVTT renders v as WebVTT: one cue per segment, with a timestamp tag
+// before every word after the first (the cue start times the first), so
+// players can highlight words as they are spoken. A segment without words
+// is a plain cue.
+func wordVTT(v verboseResponse) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	top := v.Words
+	for _, seg := range v.Segments {
+		words := seg.Words
+		if len(words) == 0 {
+			// Top-level words belong to the segment they start in.
+			i := 0
+			for i < len(top) && top[i].Start < seg.End {
+				i++
+			}
+			words, top = top[:i], top[i:]
+		}
+		fmt.Fprintf(&b, "\n%s --> %s\n", vttTime(seg.Start), vttTime(seg.End))
+		if len(words) == 0 {
+			b.WriteString(vttEscape(strings.TrimSpace(seg.Text)))
+			b.WriteByte('\n')
+			continue
+		}
+		for i, wd := range words {
+			if i > 0 {
+				fmt.Fprintf(&b, " <%s>", vttTime(max(wd.Start, seg.Start)))
+			}
+			b.WriteString(vttEscape(strings.TrimSpace(wd.Word)))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// vttTime formats seconds as a WebVTT timestamp, hh:mm:ss.mmm.
+func vttTime(sec float64) string {
+	ms := int64(sec*1000 + 0.5)
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// vttEscape escapes the characters WebVTT cue text reserves.
+var vttEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
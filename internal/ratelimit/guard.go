@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// ErrInFlightLimit is returned from a guarded request body's Read when the
+// client (or the server as a whole) has too many bytes in flight.
+var ErrInFlightLimit = errors.New("too many upload bytes in flight")
+
+// Guard caps what a single client can hold open at once: concurrent
+// uploads and bytes of upload body in flight, per IP and in total. The
+// Limiter counts requests per minute; Guard stops one client from filling
+// a small host's memory and bandwidth with a few huge, slow uploads.
+//
+// Only requests with a body (uploads) are counted. A zero limit disables
+// that check.
+type Guard struct {
+	maxUploads  int   // concurrent uploads per IP
+	maxIPBytes  int64 // in-flight body bytes per IP
+	maxAllBytes int64 // in-flight body bytes across all clients
+
+	allowList map[string]bool
+	allowNets []*net.IPNet
+
+	mu       sync.Mutex
+	clients  map[string]*usage
+	allBytes int64
+	rejected int64
+}
+
+type usage struct {
+	uploads int
+	bytes   int64
+}
+
+// GuardStats is a snapshot of in-flight usage.
+type GuardStats struct {
+	Clients  int   `json:"clients"`
+	Uploads  int   `json:"uploads"`
+	Bytes    int64 `json:"bytes"`
+	Rejected int64 `json:"rejected"`
+}
+
+// NewGuard creates a Guard. allowList IPs/CIDRs bypass it, like Limiter.
+func NewGuard(maxUploads int, maxIPBytes, maxAllBytes int64, allowList []string) *Guard {
+	allowed, nets := parseAllowList(allowList)
+	return &Guard{
+		maxUploads:  maxUploads,
+		maxIPBytes:  maxIPBytes,
+		maxAllBytes: maxAllBytes,
+		allowList:   allowed,
+		allowNets:   nets,
+		clients:     make(map[string]*usage),
+	}
+}
+
+// Enabled reports whether any limit is set.
+func (g *Guard) Enabled() bool {
+	return g.maxUploads > 0 || g.maxIPBytes > 0 || g.maxAllBytes > 0
+}
+
+// Stats returns current in-flight usage.
+func (g *Guard) Stats() GuardStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := GuardStats{Clients: len(g.clients), Bytes: g.allBytes, Rejected: g.rejected}
+	for _, u := range g.clients {
+		st.Uploads += u.uploads
+	}
+	return st
+}
+
+// start registers an upload from ip. It fails if the client already has
+// maxUploads running.
+func (g *Guard) start(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	u := g.clients[ip]
+	if u == nil {
+		u = &usage{}
+		g.clients[ip] = u
+	}
+	if g.maxUploads > 0 && u.uploads >= g.maxUploads {
+		return false
+	}
+	u.uploads++
+	return true
+}
+
+// reserve accounts n more in-flight bytes for ip.
+func (g *Guard) reserve(ip string, n int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	u := g.clients[ip]
+	if g.maxIPBytes > 0 && u.bytes+n > g.maxIPBytes {
+		return false
+	}
+	if g.maxAllBytes > 0 && g.allBytes+n > g.maxAllBytes {
+		return false
+	}
+	u.bytes += n
+	g.allBytes += n
+	return true
+}
+
+func (g *Guard) reject() {
+	g.mu.Lock()
+	g.rejected++
+	g.mu.Unlock()
+}
+
+// finish releases an upload and all bytes it reserved.
+func (g *Guard) finish(ip string, reserved int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	u := g.clients[ip]
+	u.uploads--
+	u.bytes -= reserved
+	g.allBytes -= reserved
+	if u.uploads == 0 {
+		delete(g.clients, ip)
+	}
+}
+
+// Middleware enforces the guard on requests that carry a body.
+//
+// A declared Content-Length is reserved up front, so an oversized upload is
+// rejected before a byte is read. Chunked bodies are counted as they are
+// read and fail with ErrInFlightLimit once over the limit.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	if !g.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := httputil.ClientIP(r.RemoteAddr)
+		if inAllowList(ip, g.allowList, g.allowNets) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if g.maxIPBytes > 0 && r.ContentLength > g.maxIPBytes {
+			g.reject()
+			// WHY 413, not 429? This upload can never fit, however long
+			// the client waits.
+			http.Error(w, fmt.Sprintf(`{"error": "upload larger than the per-client in-flight limit (%d bytes)"}`, g.maxIPBytes),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !g.start(ip) {
+			g.reject()
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, `{"error": "too many concurrent uploads from this client"}`, http.StatusTooManyRequests)
+			return
+		}
+		body := &guardedBody{ReadCloser: r.Body, g: g, ip: ip}
+		defer func() { g.finish(ip, body.reserved) }()
+		if r.ContentLength > 0 {
+			if !g.reserve(ip, r.ContentLength) {
+				g.reject()
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, `{"error": "too many upload bytes in flight — retry when earlier uploads finish"}`, http.StatusTooManyRequests)
+				return
+			}
+			body.reserved, body.prepaid = r.ContentLength, true
+		}
+		r.Body = body
+		next.ServeHTTP(w, r)
+	})
+}
+
+// guardedBody counts bytes of a body whose length wasn't declared.
+type guardedBody struct {
+	io.ReadCloser
+	g        *Guard
+	ip       string
+	reserved int64
+	prepaid  bool
+}
+
+func (b *guardedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.prepaid {
+		if !b.g.reserve(b.ip, int64(n)) {
+			b.g.reject()
+			return 0, ErrInFlightLimit
+		}
+		b.reserved += int64(n)
+	}
+	return n, err
+}
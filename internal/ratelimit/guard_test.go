@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func upload(h http.Handler, ip string, body io.Reader, length int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", body)
+	req.RemoteAddr = ip + ":40000"
+	req.ContentLength = length
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGuardConcurrentUploads(t *testing.T) {
+	g := NewGuard(1, 0, 0, nil)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan int)
+	go func() { done <- upload(h, "10.0.0.1", strings.NewReader("a"), 1).Code }()
+	<-started
+
+	if code := upload(h, "10.0.0.1", strings.NewReader("b"), 1).Code; code != http.StatusTooManyRequests {
+		t.Errorf("second concurrent upload = %d, want 429", code)
+	}
+	if st := g.Stats(); st.Uploads != 1 || st.Rejected != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first upload = %d", code)
+	}
+	if st := g.Stats(); st.Uploads != 0 || st.Clients != 0 || st.Bytes != 0 {
+		t.Errorf("usage not released: %+v", st)
+	}
+}
+
+func TestGuardOtherClientsUnaffected(t *testing.T) {
+	g := NewGuard(1, 0, 0, []string{"127.0.0.1"})
+	block, started := make(chan struct{}), make(chan struct{})
+	defer close(block)
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.RemoteAddr == "10.0.0.1:40000" {
+			close(started)
+			<-block
+		}
+	}))
+	go upload(h, "10.0.0.1", strings.NewReader("a"), 1)
+	<-started
+	if code := upload(h, "10.0.0.2", strings.NewReader("b"), 1).Code; code != http.StatusOK {
+		t.Errorf("other client = %d", code)
+	}
+	if code := upload(h, "127.0.0.1", strings.NewReader("c"), 1).Code; code != http.StatusOK {
+		t.Errorf("allow-listed client = %d", code)
+	}
+}
+
+func TestGuardDeclaredLength(t *testing.T) {
+	g := NewGuard(0, 100, 150, nil)
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if code := upload(h, "10.0.0.1", strings.NewReader(strings.Repeat("x", 101)), 101).Code; code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload = %d, want 413", code)
+	}
+	if code := upload(h, "10.0.0.1", strings.NewReader(strings.Repeat("x", 100)), 100).Code; code != http.StatusOK {
+		t.Errorf("upload at the limit = %d", code)
+	}
+
+	// Hold 100 bytes for one client; another 100 would exceed the total.
+	g.start("10.0.0.9")
+	g.reserve("10.0.0.9", 100)
+	if code := upload(h, "10.0.0.2", strings.NewReader(strings.Repeat("x", 60)), 60).Code; code != http.StatusTooManyRequests {
+		t.Errorf("upload over the total = %d, want 429", code)
+	}
+	g.finish("10.0.0.9", 100)
+	if code := upload(h, "10.0.0.2", strings.NewReader(strings.Repeat("x", 60)), 60).Code; code != http.StatusOK {
+		t.Errorf("upload after release = %d", code)
+	}
+}
+
+func TestGuardChunkedBody(t *testing.T) {
+	g := NewGuard(0, 10, 0, nil)
+	var readErr error
+	h := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+	upload(h, "10.0.0.1", strings.NewReader(strings.Repeat("x", 50)), -1)
+	if !errors.Is(readErr, ErrInFlightLimit) {
+		t.Errorf("reading an oversized chunked body = %v, want ErrInFlightLimit", readErr)
+	}
+	if st := g.Stats(); st.Bytes != 0 {
+		t.Errorf("bytes not released: %+v", st)
+	}
+}
+
+func TestGuardDisabled(t *testing.T) {
+	g := NewGuard(0, 0, 0, nil)
+	if g.Enabled() {
+		t.Error("zero limits should disable the guard")
+	}
+}
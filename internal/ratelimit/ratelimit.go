@@ -4,79 +4,177 @@ package ratelimit
 import (
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+	"github.com/ryan-winkler/captainslog-whisper/internal/metrics"
+)
+
+// Metric names.
+const (
+	metricTracked  = "captainslog_ratelimit_tracked_ips"
+	metricRejected = "captainslog_ratelimit_rejected_total"
+	metricRate     = "captainslog_ratelimit_rate"
 )
 
 // Limiter is a per-IP rate limiter with an allow list.
 type Limiter struct {
 	mu        sync.Mutex
 	visitors  map[string]*visitor
-	rate      int           // requests per window
+	rate      int           // requests per window; 0 = disabled
 	window    time.Duration // window duration
 	allowList map[string]bool
 	allowNets []*net.IPNet // pre-parsed CIDRs for O(1) per-request check
-	enabled   bool
+	rejected  int64
+	shared    Shared // nil = count in this process only
+	sharedErr int64  // Shared calls that failed and were counted locally
+	// sharedRetry is when to try Shared again after it failed
+	sharedRetry time.Time
+
+	// nil unless WithMetrics is used
+	mTracked  *metrics.Gauge
+	mRejected *metrics.Counter
+	mRate     *metrics.Gauge
 }
 
 type visitor struct {
 	tokens    int
 	lastReset time.Time
+	rejected  int64 // kept across windows, until Cleanup drops the visitor
+}
+
+// Stats is a snapshot of the limiter's state.
+type Stats struct {
+	Enabled       bool           `json:"enabled"`
+	Rate          int            `json:"rate"`
+	WindowSeconds float64        `json:"window_seconds"`
+	Rejected      int64          `json:"rejected"` // since startup
+	Shared        bool           `json:"shared"`   // counted across instances (see WithShared)
+	SharedErrors  int64          `json:"shared_errors,omitempty"`
+	Visitors      []VisitorStats `json:"visitors"` // busiest first
+}
+
+// VisitorStats is one tracked client IP.
+type VisitorStats struct {
+	IP        string    `json:"ip"`
+	Remaining int       `json:"remaining"` // requests left in the current window
+	ResetAt   time.Time `json:"reset_at"`
+	Rejected  int64     `json:"rejected"`
 }
 
 // New creates a rate limiter. rate is requests per window.
 // allowList is a list of IPs/CIDRs that bypass limiting.
 // Pass rate=0 to disable limiting entirely.
 func New(rate int, window time.Duration, allowList []string) *Limiter {
-	allowed := make(map[string]bool)
-	var nets []*net.IPNet
-	for _, entry := range allowList {
-		entry = strings.TrimSpace(entry)
-		if strings.Contains(entry, "/") {
-			// Pre-parse CIDR at init time — avoids re-parsing on every request
-			if _, network, err := net.ParseCIDR(entry); err == nil {
-				nets = append(nets, network)
-			}
-		} else {
-			allowed[entry] = true
-		}
-	}
+	allowed, nets := parseAllowList(allowList)
 	return &Limiter{
 		visitors:  make(map[string]*visitor),
 		rate:      rate,
 		window:    window,
 		allowList: allowed,
 		allowNets: nets,
-		enabled:   rate > 0,
 	}
 }
 
-// Allow checks if a request from the given IP is allowed.
-func (l *Limiter) Allow(ip string) bool {
-	if !l.enabled {
-		return true
-	}
+// WithMetrics records the limiter's state into reg and returns l. Per-IP
+// counts stay in Stats: an IP label would grow without bound.
+func (l *Limiter) WithMetrics(reg *metrics.Registry) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mTracked = reg.Gauge(metricTracked, "Client IPs the rate limiter is tracking.")
+	l.mRejected = reg.Counter(metricRejected, "Requests rejected by the rate limiter.")
+	l.mRate = reg.Gauge(metricRate, "Requests allowed per client IP per window; 0 = disabled.")
+	l.mRate.Set(float64(l.rate))
+	return l
+}
 
-	// Normalize IP (strip port)
-	host, _, err := net.SplitHostPort(ip)
-	if err != nil {
-		host = ip
+// SetRate changes the limit at runtime. Clients keep their current
+// window, with no more tokens left than the new rate allows. rate=0
+// disables limiting; window must be positive.
+func (l *Limiter) SetRate(rate int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.window = rate, window
+	for _, v := range l.visitors {
+		if v.tokens > rate-1 {
+			v.tokens = max(rate-1, 0)
+		}
 	}
+	if l.mRate != nil {
+		l.mRate.Set(float64(rate))
+	}
+}
 
-	// Check allow list (exact IP match or pre-parsed CIDR)
-	if l.isAllowed(host) {
-		return true
+// Enabled reports whether requests are limited.
+func (l *Limiter) Enabled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate > 0
+}
+
+// Stats returns the limiter's settings and every tracked client.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := Stats{
+		Enabled:       l.rate > 0,
+		Rate:          l.rate,
+		WindowSeconds: l.window.Seconds(),
+		Rejected:      l.rejected,
+		Shared:        l.shared != nil,
+		SharedErrors:  l.sharedErr,
+		Visitors:      make([]VisitorStats, 0, len(l.visitors)),
+	}
+	now := time.Now()
+	for ip, v := range l.visitors {
+		vs := VisitorStats{IP: ip, Remaining: v.tokens, ResetAt: v.lastReset.Add(l.window), Rejected: v.rejected}
+		if !vs.ResetAt.After(now) {
+			// The window is over; the next request starts a new one.
+			vs.Remaining = l.rate
+		}
+		st.Visitors = append(st.Visitors, vs)
 	}
+	sort.Slice(st.Visitors, func(i, j int) bool {
+		a, b := st.Visitors[i], st.Visitors[j]
+		if a.Remaining != b.Remaining {
+			return a.Remaining < b.Remaining
+		}
+		return a.IP < b.IP
+	})
+	return st
+}
+
+// Allow checks if a request from the given IP is allowed.
+func (l *Limiter) Allow(ip string) bool {
+	// Normalize IP (strip port)
+	host := httputil.ClientIP(ip)
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	v, exists := l.visitors[host]
+	// Check allow list (exact IP match or pre-parsed CIDR)
+	if l.rate <= 0 || l.isAllowed(host) {
+		return true
+	}
+
 	now := time.Now()
+	if l.shared != nil && !now.Before(l.sharedRetry) {
+		if allowed, ok := l.allowShared(host, now); ok {
+			return allowed
+		}
+	}
 
+	v, exists := l.visitors[host]
 	if !exists || now.Sub(v.lastReset) >= l.window {
-		l.visitors[host] = &visitor{tokens: l.rate - 1, lastReset: now}
+		nv := &visitor{tokens: l.rate - 1, lastReset: now}
+		if exists {
+			nv.rejected = v.rejected
+		}
+		l.visitors[host] = nv
+		l.trackedChanged()
 		return true
 	}
 
@@ -85,11 +183,82 @@ func (l *Limiter) Allow(ip string) bool {
 		return true
 	}
 
+	return l.rejectLocked(v)
+}
+
+// sharedBackoff is how long the limiter counts locally after the shared
+// counter fails, rather than waiting out its timeout on every request.
+const sharedBackoff = 5 * time.Second
+
+// allowShared counts the request in the shared counter. ok is false if
+// that failed and the request should be counted locally. Callers hold
+// l.mu; it is released during the call so a slow Redis doesn't queue
+// every other client behind it.
+func (l *Limiter) allowShared(host string, now time.Time) (allowed, ok bool) {
+	shared, rate, window := l.shared, l.rate, l.window
+	key, start := sharedKey(host, window, now)
+	l.mu.Unlock()
+	n, err := shared.Incr(key, window)
+	l.mu.Lock()
+	if err != nil {
+		l.sharedErr++
+		l.sharedRetry = time.Now().Add(sharedBackoff)
+		return false, false
+	}
+	// Mirror the shared count locally, for Stats.
+	v, exists := l.visitors[host]
+	if !exists {
+		v = &visitor{}
+		l.visitors[host] = v
+		l.trackedChanged()
+	}
+	v.tokens, v.lastReset = rate-int(min(n, int64(rate))), start
+	if n <= int64(rate) {
+		return true, true
+	}
+	return l.rejectLocked(v), true
+}
+
+// rejectLocked counts a rejected request from v. Callers hold l.mu.
+func (l *Limiter) rejectLocked(v *visitor) bool {
+	v.rejected++
+	l.rejected++
+	if l.mRejected != nil {
+		l.mRejected.Inc()
+	}
 	return false
 }
 
+// trackedChanged updates the tracked-IPs gauge. Callers hold l.mu.
+func (l *Limiter) trackedChanged() {
+	if l.mTracked != nil {
+		l.mTracked.Set(float64(len(l.visitors)))
+	}
+}
+
 func (l *Limiter) isAllowed(ip string) bool {
-	if l.allowList[ip] {
+	return inAllowList(ip, l.allowList, l.allowNets)
+}
+
+func parseAllowList(allowList []string) (map[string]bool, []*net.IPNet) {
+	allowed := make(map[string]bool)
+	var nets []*net.IPNet
+	for _, entry := range allowList {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			// Pre-parse CIDR at init time — avoids re-parsing on every request
+			if _, network, err := net.ParseCIDR(entry); err == nil {
+				nets = append(nets, network)
+			}
+		} else {
+			allowed[entry] = true
+		}
+	}
+	return allowed, nets
+}
+
+func inAllowList(ip string, allowed map[string]bool, nets []*net.IPNet) bool {
+	if allowed[ip] {
 		return true
 	}
 	// Check pre-parsed CIDR ranges
@@ -97,7 +266,7 @@ func (l *Limiter) isAllowed(ip string) bool {
 	if parsed == nil {
 		return false
 	}
-	for _, network := range l.allowNets {
+	for _, network := range nets {
 		if network.Contains(parsed) {
 			return true
 		}
@@ -105,11 +274,9 @@ func (l *Limiter) isAllowed(ip string) bool {
 	return false
 }
 
-// Middleware returns an HTTP middleware that enforces rate limits.
+// Middleware returns an HTTP middleware that enforces rate limits. It
+// wraps next even while limiting is off, so SetRate can turn it on.
 func (l *Limiter) Middleware(next http.Handler) http.Handler {
-	if !l.enabled {
-		return next
-	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !l.Allow(r.RemoteAddr) {
 			http.Error(w, `{"error": "rate limit exceeded"}`, http.StatusTooManyRequests)
@@ -129,4 +296,5 @@ func (l *Limiter) Cleanup() {
 			delete(l.visitors, ip)
 		}
 	}
+	l.trackedChanged()
 }
@@ -1,8 +1,12 @@
 package ratelimit
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/metrics"
 )
 
 func TestAllowUnderLimit(t *testing.T) {
@@ -80,3 +84,77 @@ func TestCleanup(t *testing.T) {
 		t.Errorf("expected 0 visitors after cleanup, got %d", count)
 	}
 }
+
+func TestStats(t *testing.T) {
+	l := New(2, time.Minute, nil)
+	l.Allow("1.1.1.1:1")
+	l.Allow("2.2.2.2:1")
+	l.Allow("2.2.2.2:1")
+	l.Allow("2.2.2.2:1") // rejected
+	st := l.Stats()
+	if !st.Enabled || st.Rate != 2 || st.WindowSeconds != 60 || st.Rejected != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+	if len(st.Visitors) != 2 {
+		t.Fatalf("visitors = %+v", st.Visitors)
+	}
+	// Busiest first.
+	if v := st.Visitors[0]; v.IP != "2.2.2.2" || v.Remaining != 0 || v.Rejected != 1 {
+		t.Errorf("first visitor = %+v", v)
+	}
+	if v := st.Visitors[1]; v.IP != "1.1.1.1" || v.Remaining != 1 || v.Rejected != 0 {
+		t.Errorf("second visitor = %+v", v)
+	}
+}
+
+func TestSetRate(t *testing.T) {
+	l := New(0, time.Minute, nil)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "1.2.3.4:1"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("disabled limiter = %d", code)
+	}
+
+	// Turned on after the middleware was built.
+	l.SetRate(1, time.Minute)
+	if get() != http.StatusOK || get() != http.StatusTooManyRequests {
+		t.Error("rate set at runtime not enforced")
+	}
+
+	// Lowering the rate takes away tokens already granted.
+	l.SetRate(10, time.Minute)
+	l.Allow("5.5.5.5:1")
+	l.SetRate(2, time.Minute)
+	l.Allow("5.5.5.5:1")
+	if l.Allow("5.5.5.5:1") {
+		t.Error("third request allowed after lowering the rate to 2")
+	}
+
+	l.SetRate(0, time.Minute)
+	if code := get(); code != http.StatusOK || l.Enabled() {
+		t.Errorf("limiter still on after SetRate(0): %d", code)
+	}
+}
+
+func TestLimiterMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	l := New(1, time.Minute, nil).WithMetrics(reg)
+	l.Allow("1.1.1.1:1")
+	l.Allow("1.1.1.1:1")
+	l.Allow("2.2.2.2:1")
+	for name, want := range map[string]float64{
+		"captainslog_ratelimit_tracked_ips":    2,
+		"captainslog_ratelimit_rejected_total": 1,
+		"captainslog_ratelimit_rate":           1,
+	} {
+		if s := reg.Samples(name); len(s) != 1 || s[0].Value != want {
+			t.Errorf("%s = %+v, want %v", name, s, want)
+		}
+	}
+}
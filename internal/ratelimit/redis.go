@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Shared counts requests across server instances, so a client behind a
+// load balancer gets one limit rather than one per replica.
+type Shared interface {
+	// Incr counts one request under key and returns the count so far. The
+	// count is forgotten ttl after the first request.
+	Incr(key string, ttl time.Duration) (int64, error)
+}
+
+// WithShared makes the limiter count requests in s. Each window is a fixed
+// slice of time (aligned to the Unix epoch, so every instance agrees on
+// it) rather than starting at a client's first request. If s fails, the
+// request is counted locally instead — one broken Redis must not take
+// every replica down with it. Returns l for chaining.
+func (l *Limiter) WithShared(s Shared) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.shared = s
+	return l
+}
+
+// sharedKey names a client's counter for the window holding now. The
+// window length is part of the key, so a SetRate to a new window starts
+// fresh counts.
+func sharedKey(ip string, window time.Duration, now time.Time) (string, time.Time) {
+	start := now.Truncate(window)
+	return fmt.Sprintf("captainslog:ratelimit:%s:%d:%d", ip, window.Milliseconds(), start.UnixMilli()), start
+}
+
+// incrScript increments a counter and sets its expiry on the first
+// increment, atomically: INCR then PEXPIRE from the client could leave a
+// counter that never expires if the connection dropped in between.
+const incrLua = `local n = redis.call('INCR', KEYS[1]) if n == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end return n`
+
+var incrScript = redis.NewScript(incrLua)
+
+// Redis is a Shared counter in a Redis (or Valkey, KeyDB…) server.
+type Redis struct {
+	c       *redis.Client
+	addr    string
+	timeout time.Duration
+}
+
+// NewRedis parses a redis:// or rediss:// (TLS) URL:
+// redis://[user:password@]host[:port][/db]. timeout bounds each call,
+// dial included; 0 means 250ms.
+func NewRedis(rawURL string, timeout time.Duration) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Not err itself: url.Error repeats the URL, password and all.
+		return nil, fmt.Errorf("redis URL: %w", errors.Unwrap(err))
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis URL: scheme must be redis or rediss, not %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("redis URL: no host")
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis URL: %w", err)
+	}
+	if opts.Password == "" {
+		// redis://secret@host: the one field is the password.
+		opts.Username, opts.Password = "", opts.Username
+	}
+	if timeout <= 0 {
+		timeout = 250 * time.Millisecond
+	}
+	opts.DialTimeout, opts.ReadTimeout, opts.WriteTimeout = timeout, timeout, timeout
+	// A slow Redis delays every request; the limiter counts locally rather
+	// than retrying (see WithShared).
+	opts.MaxRetries = -1
+	opts.PoolSize = 8
+	return &Redis{c: redis.NewClient(opts), addr: opts.Addr, timeout: timeout}, nil
+}
+
+// Addr is the server's host:port, for logs.
+func (r *Redis) Addr() string { return r.addr }
+
+// Incr implements Shared.
+func (r *Redis) Incr(key string, ttl time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	n, err := incrScript.Run(ctx, r.c, []string{key}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("redis: %w", err)
+	}
+	return n, nil
+}
+
+// Ping checks that the server is reachable and the credentials work.
+func (r *Redis) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if err := r.c.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers the commands Redis sends: AUTH, SELECT, PING and the
+// counter script. Like a Redis older than 6 it knows neither HELLO nor
+// CLIENT SETINFO, and it keeps no scripts, so EVALSHA is always answered
+// with NOSCRIPT.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu     sync.Mutex
+	counts map[string]int64
+	ttls   map[string]string
+	cmds   []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, password: password, counts: map[string]int64{}, ttls: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		args[0] = strings.ToUpper(args[0]) // commands are case-insensitive
+		f.cmds = append(f.cmds, args[0])
+		reply := "-ERR unknown command\r\n"
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed, reply = true, "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case args[0] == "HELLO" || args[0] == "CLIENT":
+			// Unknown commands are refused before authentication.
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "PING":
+			reply = "+PONG\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "EVALSHA":
+			reply = "-NOSCRIPT No matching script.\r\n"
+		case args[0] == "EVAL" && args[1] == incrLua:
+			f.counts[args[3]]++
+			if f.counts[args[3]] == 1 {
+				f.ttls[args[3]] = args[4]
+			}
+			reply = fmt.Sprintf(":%d\r\n", f.counts[args[3]])
+		}
+		f.mu.Unlock()
+		conn.Write([]byte(reply))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, errors.New("not an array")
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil { // $len
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisIncr(t *testing.T) {
+	f := newFakeRedis(t, "s3cret")
+	r, err := NewRedis("redis://:s3cret@"+f.ln.Addr().String()+"/2", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		n, err := r.Incr("k", 90*time.Second)
+		if err != nil || n != want {
+			t.Fatalf("Incr = %d, %v; want %d", n, err, want)
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ttls["k"] != "90000" {
+		t.Errorf("ttl = %q ms, want 90000", f.ttls["k"])
+	}
+	// One connection, authenticated and switched to db 2 once, reused. The
+	// server keeps no scripts, so each EVALSHA falls back to EVAL.
+	if got := strings.Join(f.cmds, " "); got != "HELLO AUTH SELECT CLIENT CLIENT PING EVALSHA EVAL EVALSHA EVAL EVALSHA EVAL" {
+		t.Errorf("commands = %s", got)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	f := newFakeRedis(t, "s3cret")
+	r, _ := NewRedis("redis://wrong@"+f.ln.Addr().String(), time.Second)
+	if _, err := r.Incr("k", time.Minute); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("wrong password: %v", err)
+	}
+
+	for _, u := range []string{"http://localhost", "redis://", "redis://host/db"} {
+		if _, err := NewRedis(u, 0); err == nil {
+			t.Errorf("NewRedis(%q) accepted", u)
+		}
+	}
+	r, err := NewRedis("rediss://cache.internal", 0)
+	if err != nil || r.Addr() != "cache.internal:6379" || r.c.Options().TLSConfig == nil {
+		t.Errorf("rediss URL: %+v, %v", r, err)
+	}
+}
+
+// sharedCounter is an in-memory Shared, standing in for several instances'
+// common Redis.
+type sharedCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	fail   bool
+}
+
+func (s *sharedCounter) Incr(key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return 0, errors.New("down")
+	}
+	s.counts[key]++
+	return s.counts[key], nil
+}
+
+func TestLimiterShared(t *testing.T) {
+	shared := &sharedCounter{counts: map[string]int64{}}
+	a := New(3, time.Hour, nil).WithShared(shared)
+	b := New(3, time.Hour, nil).WithShared(shared)
+
+	// Three requests across two instances use up the client's limit on both.
+	if !a.Allow("1.2.3.4:1") || !b.Allow("1.2.3.4:2") || !a.Allow("1.2.3.4:3") {
+		t.Fatal("requests under the shared limit rejected")
+	}
+	if b.Allow("1.2.3.4:4") {
+		t.Error("fourth request allowed by the other instance")
+	}
+	if st := b.Stats(); !st.Shared || st.Rejected != 1 || st.Visitors[0].Remaining != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	// With the shared counter down, each instance counts on its own.
+	shared.fail = true
+	c := New(1, time.Hour, nil).WithShared(shared)
+	if !c.Allow("5.6.7.8:1") || c.Allow("5.6.7.8:1") {
+		t.Error("local fallback not limiting")
+	}
+	if st := c.Stats(); st.SharedErrors != 1 {
+		t.Errorf("shared errors = %d, want 1 (then backing off)", st.SharedErrors)
+	}
+}
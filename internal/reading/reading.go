@@ -0,0 +1,227 @@
+// Package reading scores a recorded reading of a text: the reader records
+// themselves reading a reference text aloud, Whisper transcribes it, and
+// the words heard are lined up with the words written to show which were
+// read as written, read as something else, skipped or added.
+//
+// Whisper hears what it expects a word to be, so this is a reading check
+// more than a phonetic one: a badly mispronounced word usually comes back
+// as another word, a slightly off one often as itself with a low
+// probability, which is reported as unclear.
+package reading
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Statuses of a word.
+const (
+	StatusCorrect  = "correct"  // read as written
+	StatusMismatch = "mismatch" // read as another word
+	StatusOmitted  = "omitted"  // in the text, not heard
+	StatusInserted = "inserted" // heard, not in the text
+)
+
+// UnclearBelow is the word probability under which a correct word is
+// flagged as unclear.
+const UnclearBelow = 0.5
+
+// Heard is a word of the transcript of the reading. Start, End and
+// Probability are zero when the backend gives no word timings.
+type Heard struct {
+	Word        string  `json:"word"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// Word is one step of the alignment.
+type Word struct {
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"` // as written in the text
+	Heard    string `json:"heard,omitempty"`
+	// Start and End are when the word was heard, in seconds; nil for an
+	// omitted word or without word timings.
+	Start       *float64 `json:"start,omitempty"`
+	End         *float64 `json:"end,omitempty"`
+	Probability float64  `json:"probability,omitempty"`
+	Unclear     bool     `json:"unclear,omitempty"` // correct, but with a low probability
+}
+
+// Result is the score of a reading.
+type Result struct {
+	Words      []Word `json:"words"` // in reading order
+	Reference  int    `json:"reference_words"`
+	Correct    int    `json:"correct"`
+	Mismatched int    `json:"mismatched"`
+	Omitted    int    `json:"omitted"`
+	Inserted   int    `json:"inserted"`
+	Unclear    int    `json:"unclear"`
+	// Accuracy is the percentage of the text's words read as written.
+	Accuracy float64 `json:"accuracy"`
+	// WER is the word error rate of the reading against the text:
+	// (mismatched + omitted + inserted) / reference words.
+	WER float64 `json:"wer"`
+}
+
+// Score aligns heard with the words of reference. Words are compared
+// ignoring case and punctuation.
+func Score(reference string, heard []Heard) Result {
+	var want []string
+	for _, w := range strings.Fields(reference) {
+		if normalize(w) != "" {
+			want = append(want, w)
+		}
+	}
+	var got []Heard
+	for _, h := range heard {
+		h.Word = strings.TrimSpace(h.Word)
+		if normalize(h.Word) != "" {
+			got = append(got, h)
+		}
+	}
+
+	res := Result{Words: []Word{}, Reference: len(want)}
+	for _, step := range align(want, got) {
+		w := Word{Status: step.status}
+		if step.want >= 0 {
+			w.Expected = want[step.want]
+		}
+		if step.got >= 0 {
+			h := got[step.got]
+			w.Heard, w.Probability = h.Word, h.Probability
+			if h.End > 0 {
+				start, end := h.Start, h.End
+				w.Start, w.End = &start, &end
+			}
+		}
+		switch w.Status {
+		case StatusCorrect:
+			res.Correct++
+			if w.Probability > 0 && w.Probability < UnclearBelow {
+				w.Unclear = true
+				res.Unclear++
+			}
+		case StatusMismatch:
+			res.Mismatched++
+		case StatusOmitted:
+			res.Omitted++
+		case StatusInserted:
+			res.Inserted++
+		}
+		res.Words = append(res.Words, w)
+	}
+	if res.Reference > 0 {
+		res.Accuracy = round(float64(res.Correct) * 100 / float64(res.Reference))
+		res.WER = round(float64(res.Mismatched+res.Omitted+res.Inserted) / float64(res.Reference))
+	}
+	return res
+}
+
+// Transcript reads the words of a Whisper JSON response: its "words",
+// those of its segments, or, without word timings, its text split at
+// spaces.
+func Transcript(body []byte) ([]Heard, error) {
+	var resp struct {
+		Text     string  `json:"text"`
+		Words    []Heard `json:"words"`
+		Segments []struct {
+			Words []Heard `json:"words"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Words) > 0 {
+		return resp.Words, nil
+	}
+	var words []Heard
+	for _, s := range resp.Segments {
+		words = append(words, s.Words...)
+	}
+	if len(words) > 0 {
+		return words, nil
+	}
+	for _, w := range strings.Fields(resp.Text) {
+		words = append(words, Heard{Word: w})
+	}
+	return words, nil
+}
+
+// step pairs a reference word with a heard word; -1 when there is none.
+type step struct {
+	status    string
+	want, got int
+}
+
+// align is the word-level edit distance between want and got, traced
+// back into steps in reading order. On ties a mismatch is preferred over
+// an omission plus an insertion, so a misread word stays next to what was
+// heard instead.
+func align(want []string, got []Heard) []step {
+	n, m := len(want), len(got)
+	wn := make([]string, n)
+	for i, w := range want {
+		wn[i] = normalize(w)
+	}
+	gn := make([]string, m)
+	for j, h := range got {
+		gn[j] = normalize(h.Word)
+	}
+	cost := make([][]int, n+1)
+	for i := range cost {
+		cost[i] = make([]int, m+1)
+		cost[i][0] = i
+	}
+	for j := 0; j <= m; j++ {
+		cost[0][j] = j
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			sub := cost[i-1][j-1]
+			if wn[i-1] != gn[j-1] {
+				sub++
+			}
+			cost[i][j] = min(sub, cost[i-1][j]+1, cost[i][j-1]+1)
+		}
+	}
+
+	var steps []step
+	i, j := n, m
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && wn[i-1] == gn[j-1] && cost[i][j] == cost[i-1][j-1]:
+			steps = append(steps, step{StatusCorrect, i - 1, j - 1})
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1]+1:
+			steps = append(steps, step{StatusMismatch, i - 1, j - 1})
+			i, j = i-1, j-1
+		case i > 0 && cost[i][j] == cost[i-1][j]+1:
+			steps = append(steps, step{StatusOmitted, i - 1, -1})
+			i--
+		default:
+			steps = append(steps, step{StatusInserted, -1, j - 1})
+			j--
+		}
+	}
+	for a, b := 0, len(steps)-1; a < b; a, b = a+1, b-1 {
+		steps[a], steps[b] = steps[b], steps[a]
+	}
+	return steps
+}
+
+// normalize lower-cases w and keeps only its letters and digits, so that
+// "Hello," and " hello" are the same word.
+func normalize(w string) string {
+	var b strings.Builder
+	for _, r := range w {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func round(f float64) float64 { return math.Round(f*100) / 100 }
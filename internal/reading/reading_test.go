@@ -0,0 +1,62 @@
+package reading
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	heard := []Heard{
+		{Word: " the", Start: 0, End: 0.2, Probability: 0.9},
+		{Word: " quick", Start: 0.2, End: 0.5, Probability: 0.3},
+		{Word: " brow", Start: 0.5, End: 0.8, Probability: 0.6},
+		{Word: " um", Start: 0.8, End: 1.0, Probability: 0.7},
+		{Word: " jumps.", Start: 1.4, End: 1.8, Probability: 0.95},
+	}
+	res := Score("The quick brown fox jumps.", heard)
+	var got [][3]string
+	for _, w := range res.Words {
+		got = append(got, [3]string{w.Status, w.Expected, w.Heard})
+	}
+	want := [][3]string{
+		{StatusCorrect, "The", "the"},
+		{StatusCorrect, "quick", "quick"},
+		{StatusMismatch, "brown", "brow"},
+		{StatusMismatch, "fox", "um"},
+		{StatusCorrect, "jumps.", "jumps."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alignment = %q", got)
+	}
+	if !res.Words[1].Unclear || res.Unclear != 1 || res.Words[0].Start == nil || *res.Words[4].End != 1.8 {
+		t.Errorf("words = %+v", res.Words)
+	}
+	if res.Correct != 3 || res.Mismatched != 2 || res.Accuracy != 60 || res.WER != 0.4 {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestScoreOmittedAndInserted(t *testing.T) {
+	res := Score("one two three four", []Heard{{Word: "one"}, {Word: "three"}, {Word: "four"}, {Word: "five"}})
+	var statuses []string
+	for _, w := range res.Words {
+		statuses = append(statuses, w.Status)
+	}
+	if want := []string{StatusCorrect, StatusOmitted, StatusCorrect, StatusCorrect, StatusInserted}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %q", statuses)
+	}
+	if res.Omitted != 1 || res.Inserted != 1 || res.Accuracy != 75 || res.WER != 0.5 || res.Words[0].Start != nil {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	words, err := Transcript([]byte(`{"text":"hi there","segments":[{"words":[{"word":" hi","start":0,"end":0.3}]},{"words":[{"word":" there","start":0.3,"end":0.6}]}]}`))
+	if err != nil || len(words) != 2 || words[1].Word != " there" || words[1].End != 0.6 {
+		t.Errorf("segment words = %+v, %v", words, err)
+	}
+	words, _ = Transcript([]byte(`{"text":" hi  there "}`))
+	if len(words) != 2 || words[0].Word != "hi" || words[0].End != 0 {
+		t.Errorf("text words = %+v", words)
+	}
+}
@@ -0,0 +1,169 @@
+// Package recordings lists the saved audio recordings with their metadata,
+// for the UI's recordings browser.
+//
+// Durations come from ffprobe when it is installed. Probing costs a process
+// per file, so results are cached per file and only redone when the file's
+// size or modification time changes. Without ffprobe the duration is left
+// at zero and callers fall back to what the transcript index knows.
+package recordings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds one ffprobe run; a file it can't read in that time
+// is listed without a duration.
+const probeTimeout = 5 * time.Second
+
+// probeWorkers is how many ffprobe processes run at once on a first listing.
+const probeWorkers = 4
+
+// Recording describes one file in the recordings directory.
+type Recording struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Duration  float64   `json:"duration,omitempty"` // seconds; 0 when unknown
+	CreatedAt time.Time `json:"created_at"`         // modification time: recordings are written once
+}
+
+type cached struct {
+	size     int64
+	mod      time.Time
+	duration float64
+}
+
+// Lister lists a recordings directory. Safe for concurrent use.
+type Lister struct {
+	dir string
+
+	// probe returns the duration of the file at path in seconds; nil when
+	// ffprobe isn't installed. A field so tests can fake it.
+	probe func(ctx context.Context, path string) (float64, error)
+
+	mu        sync.Mutex
+	durations map[string]cached // by file name
+}
+
+// NewLister returns a Lister for dir, probing durations with ffprobe if it
+// is on $PATH.
+func NewLister(dir string) *Lister {
+	l := &Lister{dir: dir, durations: map[string]cached{}}
+	if bin, err := exec.LookPath("ffprobe"); err == nil {
+		l.probe = func(ctx context.Context, path string) (float64, error) {
+			return ffprobe(ctx, bin, path)
+		}
+	}
+	return l
+}
+
+// CanProbe reports whether durations are probed.
+func (l *Lister) CanProbe() bool { return l.probe != nil }
+
+// List returns the recordings, newest first. A missing directory is an
+// empty list.
+func (l *Lister) List(ctx context.Context) ([]Recording, error) {
+	entries, err := os.ReadDir(l.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Recording{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read recordings dir: %w", err)
+	}
+	recs := make([]Recording, 0, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		recs = append(recs, Recording{Name: e.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if !recs[i].CreatedAt.Equal(recs[j].CreatedAt) {
+			return recs[i].CreatedAt.After(recs[j].CreatedAt)
+		}
+		return recs[i].Name > recs[j].Name
+	})
+	if l.probe != nil {
+		l.fillDurations(ctx, recs)
+	}
+	return recs, nil
+}
+
+// fillDurations sets Duration from the cache, probing files that are new or
+// changed, and drops cache entries for files that are gone.
+func (l *Lister) fillDurations(ctx context.Context, recs []Recording) {
+	var todo []int
+	l.mu.Lock()
+	seen := make(map[string]bool, len(recs))
+	for i, r := range recs {
+		seen[r.Name] = true
+		if c, ok := l.durations[r.Name]; ok && c.size == r.Size && c.mod.Equal(r.CreatedAt) {
+			recs[i].Duration = c.duration
+			continue
+		}
+		todo = append(todo, i)
+	}
+	for name := range l.durations {
+		if !seen[name] {
+			delete(l.durations, name)
+		}
+	}
+	l.mu.Unlock()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(probeWorkers, len(todo)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pctx, cancel := context.WithTimeout(ctx, probeTimeout)
+				d, err := l.probe(pctx, filepath.Join(l.dir, recs[i].Name))
+				cancel()
+				if ctx.Err() != nil {
+					continue // client gone: don't cache a cut-short probe
+				}
+				if err != nil {
+					d = 0 // unreadable or no duration in the container: cached as unknown
+				}
+				recs[i].Duration = d
+				l.mu.Lock()
+				l.durations[recs[i].Name] = cached{recs[i].Size, recs[i].CreatedAt, d}
+				l.mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range todo {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// ffprobe reads the container duration of path. Browser recordings (WebM
+// from MediaRecorder) often carry none; that is an error here.
+func ffprobe(ctx context.Context, bin, path string) (float64, error) {
+	out, err := exec.CommandContext(ctx, bin, "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: no duration in %q", strings.TrimSpace(string(out)))
+	}
+	return d, nil
+}
@@ -0,0 +1,86 @@
+package recordings
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func write(t *testing.T, dir, name string, size int, age time.Duration) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Now().Add(-age)
+	os.Chtimes(path, mod, mod)
+}
+
+func TestListNewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "old.webm", 10, time.Hour)
+	write(t, dir, "new.webm", 20, time.Minute)
+	write(t, dir, ".partial", 5, 0)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	l := NewLister(dir)
+	l.probe = nil
+	recs, err := l.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Name != "new.webm" || recs[0].Size != 20 || recs[1].Name != "old.webm" {
+		t.Errorf("recs = %+v", recs)
+	}
+	if recs[0].Duration != 0 {
+		t.Errorf("duration without ffprobe = %v, want 0", recs[0].Duration)
+	}
+}
+
+func TestListCachesProbes(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "a.wav", 10, time.Hour)
+	write(t, dir, "bad.webm", 10, time.Hour)
+
+	var calls atomic.Int32
+	l := NewLister(dir)
+	l.probe = func(ctx context.Context, path string) (float64, error) {
+		calls.Add(1)
+		if filepath.Base(path) == "bad.webm" {
+			return 0, errors.New("no duration")
+		}
+		return 12.5, nil
+	}
+	for i := 0; i < 2; i++ {
+		recs, _ := l.List(context.Background())
+		if recs[0].Name != "bad.webm" && recs[1].Name != "bad.webm" {
+			t.Fatalf("recs = %+v", recs)
+		}
+		for _, r := range recs {
+			if want := map[string]float64{"a.wav": 12.5, "bad.webm": 0}[r.Name]; r.Duration != want {
+				t.Errorf("%s duration = %v, want %v", r.Name, r.Duration, want)
+			}
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("probed %d times, want 2 (once per file)", n)
+	}
+
+	// A changed file is probed again.
+	write(t, dir, "a.wav", 30, 0)
+	l.List(context.Background())
+	if n := calls.Load(); n != 3 {
+		t.Errorf("probed %d times after a change, want 3", n)
+	}
+}
+
+func TestListMissingDir(t *testing.T) {
+	recs, err := NewLister(filepath.Join(t.TempDir(), "nope")).List(context.Background())
+	if err != nil || len(recs) != 0 {
+		t.Errorf("recs = %v, err = %v", recs, err)
+	}
+}
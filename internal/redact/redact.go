@@ -0,0 +1,172 @@
+// Package redact implements privacy mode: transcript text never reaches
+// logs, and URLs lose identifying query values before they are logged.
+//
+// Enforcement lives in a slog.Handler wrapper rather than at each log call,
+// so a new log line added next year can't forget to redact. Redacted values
+// keep their length and a short hash — enough to correlate two log lines
+// about the same transcript without being able to read it.
+package redact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// SensitiveKeys are log attribute keys whose values may contain transcript
+// or prompt text. Matching is case-insensitive.
+var SensitiveKeys = []string{"text", "body", "transcript", "prompt", "reply", "content"}
+
+// URLKeys are log attribute keys holding URLs or query strings.
+var URLKeys = []string{"url", "query", "referer", "target"}
+
+// SafeParams are query parameters whose values identify nothing and are
+// kept as-is. Every other value becomes "REDACTED".
+var SafeParams = []string{"audience", "diag", "verbose", "language", "format", "response_format", "model", "limit"}
+
+// Hash returns a short, stable fingerprint of s (first 12 hex chars of
+// SHA-256). Not a secret — just enough to tell two transcripts apart.
+func Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Text replaces s with a stand-in that reveals only its length and hash.
+func Text(s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("[redacted %d chars sha256:%s]", len([]rune(s)), Hash(s))
+}
+
+// Truncate keeps the first n runes of s and marks the cut. n <= 0 keeps
+// nothing but the marker.
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n < 0 {
+		n = 0
+	}
+	return string(runes[:n]) + "…"
+}
+
+// Query scrubs a raw query string: keys are kept so the request shape is
+// still debuggable, values of non-safe params become REDACTED.
+func Query(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return "REDACTED"
+	}
+	for k, vs := range values {
+		if isSafeParam(k) {
+			continue
+		}
+		for i := range vs {
+			vs[i] = "REDACTED"
+		}
+	}
+	return values.Encode()
+}
+
+// URL scrubs credentials, fragment and query values from a URL. Strings
+// that don't parse are replaced entirely.
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "REDACTED"
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	u.Fragment = ""
+	u.RawQuery = Query(u.RawQuery)
+	return u.String()
+}
+
+func isSafeParam(k string) bool {
+	for _, p := range SafeParams {
+		if strings.EqualFold(k, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func keyIn(key string, keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attr returns a redacted copy of a. Groups are redacted recursively.
+func Attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch {
+	case v.Kind() == slog.KindGroup:
+		attrs := v.Group()
+		out := make([]any, len(attrs))
+		for i, ga := range attrs {
+			out[i] = Attr(ga)
+		}
+		return slog.Group(a.Key, out...)
+	case keyIn(a.Key, SensitiveKeys):
+		return slog.String(a.Key, Text(v.String()))
+	case keyIn(a.Key, URLKeys):
+		if a.Key == "query" {
+			return slog.String(a.Key, Query(v.String()))
+		}
+		return slog.String(a.Key, URL(v.String()))
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// Handler wraps another slog.Handler and redacts every record's attributes.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler returns a redacting wrapper around next.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	red := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		red[i] = Attr(a)
+	}
+	return &Handler{next: h.next.WithAttrs(red)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,70 @@
+package redact
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	got := Text("my secret diary")
+	if strings.Contains(got, "secret") {
+		t.Errorf("Text leaked content: %q", got)
+	}
+	if !strings.HasPrefix(got, "[redacted 15 chars sha256:") {
+		t.Errorf("Text = %q", got)
+	}
+	if Text("my secret diary") != got {
+		t.Error("Text should be stable for the same input")
+	}
+	if Text("") != "" {
+		t.Error("empty text should stay empty")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("héllo world", 5); got != "héllo…" {
+		t.Errorf("Truncate = %q", got)
+	}
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("Truncate = %q", got)
+	}
+	if got := Truncate("abc", 0); got != "…" {
+		t.Errorf("Truncate(0) = %q", got)
+	}
+}
+
+func TestQueryAndURL(t *testing.T) {
+	if got := Query("path=/home/me/diary.md&diag=1"); got != "diag=1&path=REDACTED" {
+		t.Errorf("Query = %q", got)
+	}
+	got := URL("https://user:pw@www.youtube.com/watch?v=abc123&language=en#t=10")
+	if got != "https://REDACTED@www.youtube.com/watch?language=en&v=REDACTED" {
+		t.Errorf("URL = %q", got)
+	}
+	if got := URL("http://[::1"); got != "REDACTED" {
+		t.Errorf("bad URL = %q", got)
+	}
+}
+
+func TestHandlerRedactsAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&buf, nil)))
+
+	logger.With("prompt", "tell me a secret").Info("llm call",
+		"text", "Meeting with Alice about layoffs",
+		"url", "https://example.com/x?email=a@b.c",
+		"status", 200,
+		slog.Group("req", "body", "also secret"),
+	)
+	out := buf.String()
+	for _, leak := range []string{"Alice", "layoffs", "a@b.c", "tell me a secret", "also secret"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("log leaked %q:\n%s", leak, out)
+		}
+	}
+	if !strings.Contains(out, "status=200") || !strings.Contains(out, "email=REDACTED") {
+		t.Errorf("non-sensitive attrs should survive:\n%s", out)
+	}
+}
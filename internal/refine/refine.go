@@ -0,0 +1,121 @@
+// Package refine compares a draft transcript with the one that replaces
+// it. In two-pass dictation a small, fast model answers at once, so the
+// text can be pasted while the speaker is still thinking, and the
+// recording is then transcribed again with the large model in the
+// background; the differences are what the user is told about.
+package refine
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxCells bounds the word-by-word comparison table. A dictation is a few
+// hundred words; past this the texts are reported as one change.
+const maxCells = 4_000_000
+
+// Change is one run of words the refined text changed: Draft replaced by
+// Refined. Draft is "" for words added, Refined "" for words dropped.
+type Change struct {
+	Draft   string `json:"draft"`
+	Refined string `json:"refined"`
+}
+
+// Diff returns the changes from draft to refined, in text order. Words
+// are compared ignoring case and punctuation, so a change is a word heard
+// differently rather than a comma moved.
+func Diff(draft, refined string) []Change {
+	a, b := strings.Fields(draft), strings.Fields(refined)
+	an, bn := normalizeAll(a), normalizeAll(b)
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		if strings.Join(an, " ") == strings.Join(bn, " ") {
+			return nil
+		}
+		return []Change{{Draft: strings.Join(a, " "), Refined: strings.Join(b, " ")}}
+	}
+
+	// lcs[i][j] is the longest common subsequence of an[i:] and bn[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if an[i] == bn[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []Change
+	var dropped, added []string
+	flush := func() {
+		if len(dropped) > 0 || len(added) > 0 {
+			changes = append(changes, Change{Draft: strings.Join(dropped, " "), Refined: strings.Join(added, " ")})
+			dropped, added = nil, nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && an[i] == bn[j]:
+			flush()
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			dropped = append(dropped, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush()
+	return changes
+}
+
+// Summary describes changes one per line, at most limit of them
+// (0 = all): `"teh" → "the"`, `+ "very"`, `− "um"`.
+func Summary(changes []Change, limit int) string {
+	var lines []string
+	for n, c := range changes {
+		if limit > 0 && n == limit {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(changes)-limit))
+			break
+		}
+		switch {
+		case c.Draft == "":
+			lines = append(lines, fmt.Sprintf("+ %q", c.Refined))
+		case c.Refined == "":
+			lines = append(lines, fmt.Sprintf("− %q", c.Draft))
+		default:
+			lines = append(lines, fmt.Sprintf("%q → %q", c.Draft, c.Refined))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func normalizeAll(words []string) []string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = normalize(w)
+	}
+	return out
+}
+
+// normalize lower-cases w and drops its punctuation. A word of
+// punctuation only is kept as it is, so a dash is still a word.
+func normalize(w string) string {
+	var b strings.Builder
+	for _, r := range w {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	if b.Len() == 0 {
+		return w
+	}
+	return b.String()
+}
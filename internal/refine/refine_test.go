@@ -0,0 +1,42 @@
+package refine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	draft := "Um, send teh report to Anna by Friday."
+	refined := "Send the full report to Anna by Friday!"
+	want := []Change{
+		{Draft: "Um,", Refined: ""},
+		{Draft: "teh", Refined: "the full"},
+	}
+	if got := Diff(draft, refined); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %+v, want %+v", got, want)
+	}
+	if got := Diff("Hello, world.", "hello world"); got != nil {
+		t.Errorf("case and punctuation only: %+v", got)
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	draft := strings.Repeat("word ", 2100)
+	if got := Diff(draft, draft+"more"); len(got) != 1 || !strings.HasSuffix(got[0].Refined, "word more") {
+		t.Errorf("large diff = %d changes", len(got))
+	}
+	if got := Diff(draft, draft); got != nil {
+		t.Errorf("large identical texts: %d changes", len(got))
+	}
+}
+
+func TestSummary(t *testing.T) {
+	changes := []Change{{"teh", "the"}, {"", "very"}, {"um", ""}}
+	if got := Summary(changes, 0); got != "\"teh\" → \"the\"\n+ \"very\"\n− \"um\"" {
+		t.Errorf("Summary = %q", got)
+	}
+	if got := Summary(changes, 2); !strings.HasSuffix(got, "\n… and 1 more") {
+		t.Errorf("limited Summary = %q", got)
+	}
+}
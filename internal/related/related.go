@@ -0,0 +1,301 @@
+// Package related finds earlier notes related to a transcript, so the
+// archive can be browsed by topic rather than only by date.
+//
+// Two notes are related when they share tags or distinctive words. Words
+// are weighted TF-IDF across the vault — a word every note uses says
+// nothing, a project name used in three notes says a lot — and compared by
+// cosine similarity. Capitalised words in mid-sentence (names, places,
+// products) count double, as a cheap stand-in for entity extraction.
+//
+// WHY not embeddings? They would need an embedding model beside the chat
+// model, and a vector store. Word overlap runs locally in milliseconds on a
+// few thousand notes and explains itself: each match lists what it shares.
+package related
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+// Score weights: word similarity dominates, shared tags add to it.
+const (
+	weightText = 0.7
+	weightTags = 0.3
+)
+
+// MinScore is the lowest score reported; below it matches are noise.
+const MinScore = 0.05
+
+// maxShared caps the shared terms listed per match.
+const maxShared = 5
+
+// genericTags are on every dictation and say nothing about the topic.
+var genericTags = map[string]bool{"dictation": true, "auto-generated": true}
+
+// stopwords are common English words left out of the vectors. IDF would
+// weigh them down anyway, but dropping them keeps the shared-terms list
+// readable on a small vault.
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`about after again also and any are back because been before being
+		but can could did does doing done don't down even every for from get going got had has have having
+		her here him his how i'm into it's its just know like make more most much need now off one only other
+		our out over really right said same see she should some still such than that the their them then
+		there these they thing things think this those through too under until very want was way well were
+		what when where which while who why will with would yeah yes you your`) {
+		stopwords[w] = true
+	}
+}
+
+// Candidate is a note that may be related.
+type Candidate struct {
+	ID   string // transcript index ID
+	Path string // vault file
+}
+
+// Match is a related note.
+type Match struct {
+	ID          string    `json:"id"`
+	File        string    `json:"vault_file"`
+	Title       string    `json:"title,omitempty"`
+	Date        time.Time `json:"date"`
+	Score       float64   `json:"score"` // 0–1
+	SharedTags  []string  `json:"shared_tags,omitempty"`
+	SharedTerms []string  `json:"shared_terms,omitempty"`
+}
+
+// note is what Finder keeps per vault file.
+type note struct {
+	mod   time.Time
+	size  int64
+	title string
+	date  time.Time
+	tags  map[string]bool
+	terms map[string]float64 // term frequency, entities doubled
+}
+
+// Finder compares notes, caching each note's terms until the file changes.
+// Safe for concurrent use.
+type Finder struct {
+	mu    sync.Mutex
+	notes map[string]*note // by path
+}
+
+// NewFinder returns an empty Finder.
+func NewFinder() *Finder {
+	return &Finder{notes: map[string]*note{}}
+}
+
+// Find returns up to limit of candidates most related to target, best
+// first. Candidates that can't be read are skipped; target itself is never
+// returned.
+func (f *Finder) Find(target Candidate, candidates []Candidate, limit int) ([]Match, error) {
+	t, err := f.load(target.Path)
+	if err != nil {
+		return nil, err
+	}
+	type loaded struct {
+		c Candidate
+		n *note
+	}
+	corpus := []loaded{}
+	seen := map[string]bool{target.Path: true}
+	for _, c := range candidates {
+		if seen[c.Path] {
+			continue
+		}
+		seen[c.Path] = true
+		if n, err := f.load(c.Path); err == nil {
+			corpus = append(corpus, loaded{c, n})
+		}
+	}
+	f.prune(seen)
+
+	// Document frequency over the target and the candidates.
+	df := map[string]int{}
+	for term := range t.terms {
+		df[term]++
+	}
+	for _, l := range corpus {
+		for term := range l.n.terms {
+			df[term]++
+		}
+	}
+	docs := float64(len(corpus) + 1)
+	idf := func(term string) float64 { return math.Log(1 + docs/float64(df[term])) }
+	vec := func(n *note) (map[string]float64, float64) {
+		v := make(map[string]float64, len(n.terms))
+		norm := 0.0
+		for term, tf := range n.terms {
+			w := (1 + math.Log(tf)) * idf(term)
+			v[term] = w
+			norm += w * w
+		}
+		return v, math.Sqrt(norm)
+	}
+	tv, tnorm := vec(t)
+
+	var matches []Match
+	for _, l := range corpus {
+		cv, cnorm := vec(l.n)
+		dot := 0.0
+		type shared struct {
+			term string
+			w    float64
+		}
+		var terms []shared
+		for term, w := range tv {
+			if cw, ok := cv[term]; ok {
+				dot += w * cw
+				terms = append(terms, shared{term, w * cw})
+			}
+		}
+		cos := 0.0
+		if tnorm > 0 && cnorm > 0 {
+			cos = dot / (tnorm * cnorm)
+		}
+		sharedTags, tagScore := overlap(t.tags, l.n.tags)
+		score := weightText*cos + weightTags*tagScore
+		if score < MinScore {
+			continue
+		}
+		sort.Slice(terms, func(i, j int) bool {
+			if terms[i].w != terms[j].w {
+				return terms[i].w > terms[j].w
+			}
+			return terms[i].term < terms[j].term
+		})
+		m := Match{
+			ID:         l.c.ID,
+			File:       l.c.Path,
+			Title:      l.n.title,
+			Date:       l.n.date,
+			Score:      math.Round(score*1000) / 1000,
+			SharedTags: sharedTags,
+		}
+		for i := 0; i < len(terms) && i < maxShared; i++ {
+			m.SharedTerms = append(m.SharedTerms, terms[i].term)
+		}
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Date.After(matches[j].Date)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// overlap returns the tags in both sets, sorted, and their Jaccard index.
+func overlap(a, b map[string]bool) ([]string, float64) {
+	var both []string
+	union := len(b)
+	for t := range a {
+		if b[t] {
+			both = append(both, t)
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return nil, 0
+	}
+	sort.Strings(both)
+	return both, float64(len(both)) / float64(union)
+}
+
+// load returns the note at path, reading it only if it changed since the
+// last call.
+func (f *Finder) load(path string) (*note, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	n, ok := f.notes[path]
+	f.mu.Unlock()
+	if ok && n.mod.Equal(info.ModTime()) && n.size == info.Size() {
+		return n, nil
+	}
+	doc, err := vault.ReadDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	n = &note{
+		mod:   info.ModTime(),
+		size:  info.Size(),
+		title: strings.Trim(doc.Get("title"), `"'`),
+		date:  doc.Time(),
+		tags:  map[string]bool{},
+		terms: Terms(doc.Body),
+	}
+	for _, t := range doc.Tags() {
+		if t = strings.ToLower(t); !genericTags[t] {
+			n.tags[t] = true
+		}
+	}
+	f.mu.Lock()
+	f.notes[path] = n
+	f.mu.Unlock()
+	return n, nil
+}
+
+// prune forgets notes no longer among the candidates (deleted or moved).
+func (f *Finder) prune(keep map[string]bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for path := range f.notes {
+		if !keep[path] {
+			delete(f.notes, path)
+		}
+	}
+}
+
+// Terms returns the term frequencies of text: lowercased words of three or
+// more letters, without stopwords. A word capitalised in mid-sentence
+// counts twice.
+func Terms(text string) map[string]float64 {
+	terms := map[string]float64{}
+	sentenceStart := true
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '-' && r != '.' && r != '!' && r != '?'
+	}) {
+		end := strings.ContainsAny(word[len(word)-1:], ".!?")
+		w := strings.Trim(word, "'-.!?")
+		if w == "" {
+			sentenceStart = sentenceStart || end
+			continue
+		}
+		lower := strings.ToLower(w)
+		if len([]rune(lower)) >= 3 && !stopwords[lower] && !isNumber(lower) {
+			weight := 1.0
+			if first := []rune(w)[0]; unicode.IsUpper(first) && !sentenceStart {
+				weight = 2
+			}
+			terms[lower] += weight
+		}
+		sentenceStart = end
+	}
+	return terms
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package related
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeNote(t *testing.T, dir, name, tags, body string) Candidate {
+	t.Helper()
+	path := filepath.Join(dir, name+".md")
+	content := "---\ntitle: " + name + "\ndate: 2026-03-05T09:30:00\ntags: [dictation" + tags + "]\n---\n\n" + body + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Candidate{ID: name, Path: path}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	target := writeNote(t, dir, "target", ", apollo", "Call with Priya about the Apollo launch budget and the launch checklist.")
+	budget := writeNote(t, dir, "budget", "", "Went over the Apollo budget again with Priya.")
+	tagged := writeNote(t, dir, "tagged", ", apollo", "Unrelated words entirely here.")
+	other := writeNote(t, dir, "other", "", "Groceries: milk, eggs, bread.")
+
+	f := NewFinder()
+	got, err := f.Find(target, []Candidate{target, budget, tagged, other}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d matches, want 2: %+v", len(got), got)
+	}
+	if got[0].ID != "budget" || got[1].ID != "tagged" {
+		t.Errorf("order = %s, %s; want budget, tagged", got[0].ID, got[1].ID)
+	}
+	if len(got[0].SharedTerms) == 0 || got[0].SharedTerms[0] != "apollo" && got[0].SharedTerms[0] != "priya" {
+		t.Errorf("budget shared terms = %v, want the names first", got[0].SharedTerms)
+	}
+	if len(got[1].SharedTags) != 1 || got[1].SharedTags[0] != "apollo" {
+		t.Errorf("tagged shared tags = %v, want [apollo]", got[1].SharedTags)
+	}
+	if got[0].Title != "budget" || got[0].Date.IsZero() {
+		t.Errorf("budget title/date = %q %v", got[0].Title, got[0].Date)
+	}
+
+	// A limit trims; a changed file is re-read.
+	if got, _ := f.Find(target, []Candidate{budget, tagged}, 1); len(got) != 1 {
+		t.Errorf("limit 1 gave %d", len(got))
+	}
+	os.WriteFile(other.Path, []byte("Apollo launch checklist with Priya, budget done.\n"), 0o644)
+	os.Chtimes(other.Path, time.Now(), time.Now().Add(time.Minute))
+	if got, _ := f.Find(target, []Candidate{other}, 10); len(got) != 1 {
+		t.Errorf("edited note not picked up: %+v", got)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms("Meeting with Priya. Priya said the 2026 plan is done! Next: Apollo's budget.")
+	want := map[string]float64{"meeting": 1, "priya": 3, "plan": 1, "next": 1, "apollo's": 2, "budget": 1}
+	if len(got) != len(want) {
+		t.Errorf("Terms = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Terms[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+// WHY three labelled lines rather than JSON? Small local models often wrap
+// JSON in prose or code fences, or get a quote wrong; "Title: …" lines
+// survive all of that, and a missing line costs only that field.
+const describePrompt = `Describe the following dictated note for a notes app. Reply with exactly three lines:
+Title: a short title of at most 8 words
+Tags: up to 5 lowercase topic tags, comma-separated
+Summary: the note in one sentence
+No other text.`
+
+// maxTitleRunes caps a title; a model that ignores "8 words" must not
+// fill the frontmatter.
+const maxTitleRunes = 100
+
+// Describe returns a vault.Describer that asks the LLM for a new
+// transcription's title, tags and summary in one call, for notes that are
+// organized from the moment they are saved. Summarize and Tag do the same
+// for notes already in the vault.
+func Describe(client *llm.Client) vault.Describer {
+	return func(ctx context.Context, text string) (vault.Description, error) {
+		text = strings.TrimSpace(text)
+		if runes := []rune(text); len(runes) > llm.MaxInputRunes {
+			text = string(runes[:llm.MaxInputRunes])
+		}
+		if text == "" {
+			return vault.Description{}, nil
+		}
+		reply, err := client.Chat(ctx,
+			llm.Message{Role: "system", Content: describePrompt},
+			llm.Message{Role: "user", Content: text})
+		if err != nil {
+			return vault.Description{}, err
+		}
+		d := parseDescription(reply)
+		if d.Title == "" && d.Summary == "" && len(d.Tags) == 0 {
+			return vault.Description{}, errors.New("llm reply had no title, tags or summary")
+		}
+		return d, nil
+	}
+}
+
+// parseDescription reads the Title/Tags/Summary lines of a reply, in any
+// case and order, with or without markdown emphasis around the labels.
+func parseDescription(reply string) vault.Description {
+	var d vault.Description
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "-*#• ")
+		label, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(strings.TrimLeft(value, "* "))
+		switch strings.ToLower(strings.Trim(label, "* ")) {
+		case "title":
+			value = strings.TrimRight(strings.Trim(value, `"'`), ".")
+			if runes := []rune(value); len(runes) > maxTitleRunes {
+				value = strings.TrimSpace(string(runes[:maxTitleRunes]))
+			}
+			d.Title = value
+		case "tags":
+			d.Tags = parseTags(value)
+		case "summary":
+			d.Summary = strings.Trim(value, `"`)
+		}
+	}
+	return d
+}
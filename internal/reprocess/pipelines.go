@@ -0,0 +1,130 @@
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+const summarizePrompt = `Summarize the following dictated note in one or two sentences. ` +
+	`Reply with the summary only — no preamble, no quotes, no markdown.`
+
+const tagPrompt = `Suggest up to 5 short topic tags for the following dictated note. ` +
+	`Reply with a comma-separated list of lowercase single words or hyphenated-phrases only.`
+
+// Names lists the pipelines accepted by Lookup.
+var Names = []string{"summarize", "tag"}
+
+// ErrUnknownPipeline is returned by Lookup for a name not in Names.
+var ErrUnknownPipeline = errors.New("unknown pipeline")
+
+// Lookup returns the named pipeline bound to client.
+func Lookup(name string, client *llm.Client) (Pipeline, error) {
+	switch name {
+	case "summarize":
+		return Summarize(client), nil
+	case "tag":
+		return Tag(client), nil
+	case "diarize":
+		// WHY not? Vault notes hold text only — the original audio is
+		// discarded after transcription, so there is nothing to re-diarize.
+		return nil, fmt.Errorf("%w %q: vault notes don't keep the source audio, so speakers can't be re-identified", ErrUnknownPipeline, name)
+	}
+	return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownPipeline, name, strings.Join(Names, ", "))
+}
+
+// Summarize returns a pipeline that writes an LLM summary to the "summary"
+// frontmatter key.
+func Summarize(client *llm.Client) Pipeline {
+	return func(ctx context.Context, doc *vault.Document) (bool, error) {
+		text := bodyText(doc)
+		if text == "" {
+			return false, nil
+		}
+		reply, err := client.Chat(ctx,
+			llm.Message{Role: "system", Content: summarizePrompt},
+			llm.Message{Role: "user", Content: text})
+		if err != nil {
+			return false, err
+		}
+		if reply == "" {
+			return false, errors.New("llm returned an empty summary")
+		}
+		summary := vault.Quote(reply)
+		if doc.Get("summary") == summary {
+			return false, nil
+		}
+		doc.Set("summary", summary)
+		return true, nil
+	}
+}
+
+// Tag returns a pipeline that merges LLM-suggested tags into the document's
+// existing tags. Existing tags are never removed.
+func Tag(client *llm.Client) Pipeline {
+	return func(ctx context.Context, doc *vault.Document) (bool, error) {
+		text := bodyText(doc)
+		if text == "" {
+			return false, nil
+		}
+		reply, err := client.Chat(ctx,
+			llm.Message{Role: "system", Content: tagPrompt},
+			llm.Message{Role: "user", Content: text})
+		if err != nil {
+			return false, err
+		}
+
+		tags := doc.Tags()
+		changed := false
+		for _, t := range parseTags(reply) {
+			if !doc.HasTag(t) {
+				tags = append(tags, t)
+				changed = true
+			}
+		}
+		if changed {
+			doc.SetTags(tags)
+		}
+		return changed, nil
+	}
+}
+
+// parseTags normalises an LLM tag reply: lowercase, hyphenated, no '#',
+// at most 5 tags.
+func parseTags(reply string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, raw := range strings.FieldsFunc(reply, func(r rune) bool { return r == ',' || r == '\n' }) {
+		t := strings.ToLower(strings.TrimSpace(raw))
+		t = strings.TrimLeft(t, "#-*• ")
+		t = strings.Join(strings.Fields(t), "-")
+		t = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+				return r
+			}
+			return -1
+		}, t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		tags = append(tags, t)
+		if len(tags) == 5 {
+			break
+		}
+	}
+	return tags
+}
+
+func bodyText(doc *vault.Document) string {
+	text := strings.TrimSpace(doc.Body)
+	if runes := []rune(text); len(runes) > llm.MaxInputRunes {
+		text = string(runes[:llm.MaxInputRunes])
+	}
+	return text
+}
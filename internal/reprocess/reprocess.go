@@ -0,0 +1,291 @@
+// Package reprocess re-runs post-processing pipelines (summaries, tags) over
+// transcripts already saved in the vault.
+//
+// As models improve, old notes can be brought up to date without
+// re-recording. Jobs run in the background one file at a time with a
+// configurable pause between files, so a re-run over years of history
+// doesn't monopolise the local LLM while someone is dictating.
+package reprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+// Pipeline rewrites a document in place. It returns changed=false when the
+// document needed no update (so the file isn't rewritten).
+type Pipeline func(ctx context.Context, doc *vault.Document) (changed bool, err error)
+
+// Filter selects which vault files a job processes. Zero values match all.
+type Filter struct {
+	From time.Time `json:"from,omitempty"` // inclusive
+	To   time.Time `json:"to,omitempty"`   // exclusive
+	Tag  string    `json:"tag,omitempty"`
+}
+
+// Match reports whether doc passes the filter.
+func (f Filter) Match(doc *vault.Document) bool {
+	if f.Tag != "" && !doc.HasTag(f.Tag) {
+		return false
+	}
+	if f.From.IsZero() && f.To.IsZero() {
+		return true
+	}
+	t := doc.Time()
+	if !f.From.IsZero() && t.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !t.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// Job statuses.
+const (
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusCanceled = "canceled"
+)
+
+// maxJobErrors caps per-job error messages kept for the status endpoint.
+const maxJobErrors = 20
+
+// Finished jobs are kept for the status endpoint until they are older than
+// jobRetention or more than maxFinishedJobs have piled up.
+const (
+	jobRetention    = 24 * time.Hour
+	maxFinishedJobs = 50
+)
+
+// Job is a snapshot of a re-processing run.
+type Job struct {
+	ID         string    `json:"id"`
+	Pipeline   string    `json:"pipeline"`
+	Filter     Filter    `json:"filter"`
+	DryRun     bool      `json:"dry_run"`
+	Status     string    `json:"status"`
+	Total      int       `json:"total"`
+	Processed  int       `json:"processed"`
+	Changed    int       `json:"changed"`
+	Failed     int       `json:"failed"`
+	Errors     []string  `json:"errors,omitempty"`
+	Throttle   string    `json:"throttle"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Request describes a job to start.
+type Request struct {
+	Pipeline string
+	Filter   Filter
+	Throttle time.Duration // pause between files
+	DryRun   bool          // run the pipeline but don't write files
+}
+
+// Manager runs and tracks jobs.
+type Manager struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+}
+
+// NewManager creates an empty Manager.
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{
+		logger:  logger,
+		jobs:    map[string]*Job{},
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// Start selects matching files in dir and runs pipeline over them in the
+// background. The returned Job is a snapshot; poll Get for progress.
+func (m *Manager) Start(dir string, req Request, pipeline Pipeline) (Job, error) {
+	files, err := Select(dir, req.Filter)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:        httputil.ReprocessID.New(),
+		Pipeline:  req.Pipeline,
+		Filter:    req.Filter,
+		DryRun:    req.DryRun,
+		Status:    StatusRunning,
+		Total:     len(files),
+		Throttle:  req.Throttle.String(),
+		StartedAt: time.Now().UTC(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	m.prune(time.Now())
+	m.jobs[job.ID] = job
+	m.cancels[job.ID] = cancel
+	snapshot := *job
+	m.mu.Unlock()
+
+	m.logger.Info("reprocess job started", "id", job.ID, "pipeline", req.Pipeline, "files", len(files), "dry_run", req.DryRun)
+	go m.run(ctx, job, files, pipeline, req)
+	return snapshot, nil
+}
+
+func (m *Manager) run(ctx context.Context, job *Job, files []string, pipeline Pipeline, req Request) {
+	defer func() {
+		m.mu.Lock()
+		if job.Status == StatusRunning {
+			job.Status = StatusDone
+		}
+		job.FinishedAt = time.Now().UTC()
+		m.cancels[job.ID]()
+		delete(m.cancels, job.ID)
+		m.mu.Unlock()
+		m.logger.Info("reprocess job finished", "id", job.ID, "status", job.Status,
+			"processed", job.Processed, "changed", job.Changed, "failed", job.Failed)
+	}()
+
+	for i, path := range files {
+		if i > 0 && req.Throttle > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(req.Throttle):
+			}
+		}
+		if ctx.Err() != nil {
+			m.mu.Lock()
+			job.Status = StatusCanceled
+			m.mu.Unlock()
+			return
+		}
+
+		changed, err := processFile(ctx, path, pipeline, req.DryRun)
+
+		m.mu.Lock()
+		job.Processed++
+		switch {
+		case err != nil && ctx.Err() != nil:
+			job.Processed-- // interrupted mid-file, not a failure
+		case err != nil:
+			job.Failed++
+			if len(job.Errors) < maxJobErrors {
+				job.Errors = append(job.Errors, filepath.Base(path)+": "+err.Error())
+			}
+		case changed:
+			job.Changed++
+		}
+		m.mu.Unlock()
+	}
+}
+
+func processFile(ctx context.Context, path string, pipeline Pipeline, dryRun bool) (bool, error) {
+	doc, err := vault.ReadDocument(path)
+	if err != nil {
+		return false, err
+	}
+	changed, err := pipeline(ctx, doc)
+	if err != nil || !changed || dryRun {
+		return changed, err
+	}
+	return true, doc.Save()
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	snapshot := *job
+	snapshot.Errors = append([]string(nil), job.Errors...)
+	return snapshot, true
+}
+
+// List returns snapshots of all jobs, newest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	out := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		s := *job
+		s.Errors = append([]string(nil), job.Errors...)
+		out = append(out, s)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out
+}
+
+// Cancel stops a running job. Returns false if the job isn't running.
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cancel, ok := m.cancels[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// prune drops finished jobs older than jobRetention, then the oldest
+// finished jobs beyond maxFinishedJobs. The caller holds m.mu.
+func (m *Manager) prune(now time.Time) {
+	var finished []*Job
+	for id, job := range m.jobs {
+		switch {
+		case job.FinishedAt.IsZero():
+		case now.Sub(job.FinishedAt) > jobRetention:
+			delete(m.jobs, id)
+		default:
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.After(finished[j].FinishedAt) })
+	for _, job := range finished[maxFinishedJobs:] {
+		delete(m.jobs, job.ID)
+	}
+}
+
+// Select returns the .md files in dir that match f, oldest first.
+func Select(dir string, f Filter) ([]string, error) {
+	if dir == "" {
+		return nil, errors.New("vault directory not configured")
+	}
+	matches, err := filepath.Glob(filepath.Join(vault.ExpandDir(dir), "*.md"))
+	if err != nil {
+		return nil, fmt.Errorf("glob vault dir: %w", err)
+	}
+	type dated struct {
+		path string
+		t    time.Time
+	}
+	var selected []dated
+	for _, path := range matches {
+		doc, err := vault.ReadDocument(path)
+		if err != nil || !f.Match(doc) {
+			continue
+		}
+		selected = append(selected, dated{path, doc.Time()})
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].t.Before(selected[j].t) })
+	out := make([]string, len(selected))
+	for i, s := range selected {
+		out[i] = s.path
+	}
+	return out, nil
+}
@@ -0,0 +1,269 @@
+package reprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/llm"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func writeNote(t *testing.T, dir, name, date, tags, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	content := "---\ntitle: Dictation\ndate: " + date + "\ntags: [" + tags + "]\n---\n\n" + body + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// waitDone polls until the job leaves the running state.
+func waitDone(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := m.Get(id); job.Status != StatusRunning {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+	return Job{}
+}
+
+func TestFilterMatch(t *testing.T) {
+	doc := vault.ParseDocument("x.md", []byte("---\ndate: 2026-03-10T09:00:00\ntags: [dictation, work]\n---\n\nhi\n"))
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		f    Filter
+		want bool
+	}{
+		{"empty", Filter{}, true},
+		{"tag match", Filter{Tag: "Work"}, true},
+		{"tag miss", Filter{Tag: "home"}, false},
+		{"in range", Filter{From: day(1), To: day(11)}, true},
+		{"before range", Filter{From: day(11)}, false},
+		{"to is exclusive", Filter{To: day(10)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Match(doc); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelectOrdersOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "b.md", "2026-03-02T00:00:00", "dictation", "two")
+	writeNote(t, dir, "a.md", "2026-03-01T00:00:00", "dictation", "one")
+	writeNote(t, dir, "c.md", "2026-03-03T00:00:00", "other", "three")
+
+	files, err := Select(dir, Filter{Tag: "dictation"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "a.md" || filepath.Base(files[1]) != "b.md" {
+		t.Errorf("files = %v", files)
+	}
+}
+
+func TestJobRunsPipeline(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "a.md", "2026-03-01T00:00:00", "dictation", "one")
+	writeNote(t, dir, "b.md", "2026-03-02T00:00:00", "dictation", "two")
+	writeNote(t, dir, "c.md", "2026-03-03T00:00:00", "dictation", "fail")
+
+	upper := func(ctx context.Context, doc *vault.Document) (bool, error) {
+		if strings.Contains(doc.Body, "fail") {
+			return false, errors.New("boom")
+		}
+		doc.Set("shout", strings.ToUpper(strings.TrimSpace(doc.Body)))
+		return true, nil
+	}
+	m := NewManager(testLogger())
+	started, err := m.Start(dir, Request{Pipeline: "upper"}, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.Total != 3 {
+		t.Errorf("total = %d, want 3", started.Total)
+	}
+
+	job := waitDone(t, m, started.ID)
+	if job.Status != StatusDone || job.Processed != 3 || job.Changed != 2 || job.Failed != 1 {
+		t.Errorf("job = %+v", job)
+	}
+	if len(job.Errors) != 1 || !strings.HasPrefix(job.Errors[0], "c.md: ") {
+		t.Errorf("errors = %v", job.Errors)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "a.md"))
+	if !strings.Contains(string(data), "shout: ONE") {
+		t.Errorf("a.md not rewritten:\n%s", data)
+	}
+}
+
+func TestJobDryRunDoesNotWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := writeNote(t, dir, "a.md", "2026-03-01T00:00:00", "dictation", "one")
+	before, _ := os.ReadFile(path)
+
+	set := func(ctx context.Context, doc *vault.Document) (bool, error) {
+		doc.Set("x", "y")
+		return true, nil
+	}
+	m := NewManager(testLogger())
+	started, _ := m.Start(dir, Request{Pipeline: "set", DryRun: true}, set)
+	job := waitDone(t, m, started.ID)
+
+	after, _ := os.ReadFile(path)
+	if string(before) != string(after) {
+		t.Error("dry run modified the file")
+	}
+	if job.Changed != 1 {
+		t.Errorf("changed = %d, want 1 (would change)", job.Changed)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{"a", "b", "c"} {
+		writeNote(t, dir, n+".md", "2026-03-01T00:00:00", "dictation", n)
+	}
+	noop := func(ctx context.Context, doc *vault.Document) (bool, error) { return false, nil }
+	m := NewManager(testLogger())
+
+	started, _ := m.Start(dir, Request{Pipeline: "noop", Throttle: time.Hour}, noop)
+	if !m.Cancel(started.ID) {
+		t.Fatal("Cancel should find the running job")
+	}
+	job := waitDone(t, m, started.ID)
+	if job.Status != StatusCanceled || job.Processed >= 3 {
+		t.Errorf("job = %+v, want canceled before finishing", job)
+	}
+	if m.Cancel(started.ID) {
+		t.Error("Cancel should report false for a finished job")
+	}
+}
+
+func TestPruneFinishedJobs(t *testing.T) {
+	m := NewManager(testLogger())
+	now := time.Now()
+	m.jobs["rp_running"] = &Job{ID: "rp_running", Status: StatusRunning}
+	m.jobs["rp_old"] = &Job{ID: "rp_old", Status: StatusDone, FinishedAt: now.Add(-jobRetention - time.Minute)}
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		id := fmt.Sprintf("rp_%d", i)
+		m.jobs[id] = &Job{ID: id, Status: StatusDone, FinishedAt: now.Add(-time.Duration(i) * time.Minute)}
+	}
+
+	m.prune(now)
+	if _, ok := m.jobs["rp_running"]; !ok {
+		t.Error("running job was pruned")
+	}
+	if _, ok := m.jobs["rp_old"]; ok {
+		t.Error("job past retention was kept")
+	}
+	if _, ok := m.jobs["rp_0"]; !ok {
+		t.Error("newest finished job was pruned")
+	}
+	if _, ok := m.jobs[fmt.Sprintf("rp_%d", maxFinishedJobs)]; ok {
+		t.Error("finished job beyond the cap was kept")
+	}
+	if len(m.jobs) != maxFinishedJobs+1 {
+		t.Errorf("%d jobs kept, want %d", len(m.jobs), maxFinishedJobs+1)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	client := llm.New("http://127.0.0.1:1", "test")
+	for _, name := range Names {
+		if p, err := Lookup(name, client); err != nil || p == nil {
+			t.Errorf("Lookup(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"diarize", "nope"} {
+		if _, err := Lookup(name, client); !errors.Is(err, ErrUnknownPipeline) {
+			t.Errorf("Lookup(%q) err = %v, want ErrUnknownPipeline", name, err)
+		}
+	}
+}
+
+// fakeLLM answers every chat request with reply.
+func fakeLLM(t *testing.T, reply string) *llm.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return llm.New(srv.URL, "test")
+}
+
+func TestSummarizePipeline(t *testing.T) {
+	doc := vault.ParseDocument("x.md", []byte("---\ntitle: Dictation\n---\n\nWe agreed to ship on Friday.\n"))
+	changed, err := Summarize(fakeLLM(t, "Team agreed to ship Friday."))(context.Background(), doc)
+	if err != nil || !changed {
+		t.Fatalf("changed = %v, err = %v", changed, err)
+	}
+	if doc.Get("summary") != `"Team agreed to ship Friday."` {
+		t.Errorf("summary = %q", doc.Get("summary"))
+	}
+}
+
+func TestTagPipelineMerges(t *testing.T) {
+	doc := vault.ParseDocument("x.md", []byte("---\ntags: [dictation, work]\n---\n\nShip it.\n"))
+	changed, err := Tag(fakeLLM(t, "#Work, Release Planning, shipping"))(context.Background(), doc)
+	if err != nil || !changed {
+		t.Fatalf("changed = %v, err = %v", changed, err)
+	}
+	if got := doc.Get("tags"); got != "[dictation, work, release-planning, shipping]" {
+		t.Errorf("tags = %q", got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	reply := "Sure! Here you go:\n**Title:** \"Friday release: go/no-go.\"\nTags: #Release, planning\n**Summary:** We ship on Friday if QA signs off."
+	d, err := Describe(fakeLLM(t, reply))(context.Background(), "We ship on Friday if QA signs off.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Friday release: go/no-go" || strings.Join(d.Tags, ",") != "release,planning" || d.Summary != "We ship on Friday if QA signs off." {
+		t.Errorf("description = %+v", d)
+	}
+
+	if _, err := Describe(fakeLLM(t, "I can't help with that."))(context.Background(), "text"); err == nil {
+		t.Error("expected an error for a reply without the fields")
+	}
+}
+
+func TestDescribedSave(t *testing.T) {
+	dir := t.TempDir()
+	v := vault.New(dir, "", "", testLogger()).WithDescriber(Describe(fakeLLM(t, "Title: Warp core: status\nTags: engineering\nSummary: All systems nominal.")))
+	file, err := v.Save("Warp core is stable.", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := vault.ReadDocument(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Get("title") != `"Warp core: status"` || doc.Get("summary") != `"All systems nominal."` || !doc.HasTag("engineering") || !doc.HasTag("dictation") {
+		t.Errorf("frontmatter = title %s, summary %s, tags %v", doc.Get("title"), doc.Get("summary"), doc.Tags())
+	}
+}
@@ -0,0 +1,141 @@
+// Package retention keeps the recordings directory within an age and size
+// budget.
+//
+// Every saved recording stays on disk until something removes it, and at a
+// few megabytes per dictation that adds up. A Sweeper periodically deletes
+// recordings older than MaxAge, then the oldest ones until the directory
+// fits in MaxBytes. The policy is read on each sweep, so a settings change
+// applies on the next pass without a restart.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Grace protects recordings younger than this from the size limit: the UI
+// uploads the recording a moment before it links it to the saved note, and
+// the newest recording should never be the one to go.
+const Grace = 10 * time.Minute
+
+// Policy limits the recordings kept. Zero values mean no limit.
+type Policy struct {
+	MaxAge   time.Duration
+	MaxBytes int64
+}
+
+// Enabled reports whether p limits anything.
+func (p Policy) Enabled() bool { return p.MaxAge > 0 || p.MaxBytes > 0 }
+
+// Result describes one sweep.
+type Result struct {
+	Removed []string `json:"removed"` // file names
+	Freed   int64    `json:"freed_bytes"`
+	Kept    int      `json:"kept"`
+	Bytes   int64    `json:"bytes"` // total size of the kept files
+}
+
+type recording struct {
+	name string
+	size int64
+	mod  time.Time
+}
+
+// Sweep applies p to the regular files in dir as of now.
+func Sweep(dir string, p Policy, now time.Time) (Result, error) {
+	var res Result
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("read recordings dir: %w", err)
+	}
+	var recs []recording
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		recs = append(recs, recording{e.Name(), info.Size(), info.ModTime()})
+	}
+	// Oldest first, so the size limit removes the oldest.
+	sort.Slice(recs, func(i, j int) bool { return recs[i].mod.Before(recs[j].mod) })
+
+	var total int64
+	for _, r := range recs {
+		total += r.size
+	}
+	var errs []error
+	for _, r := range recs {
+		expired := p.MaxAge > 0 && now.Sub(r.mod) > p.MaxAge
+		over := p.MaxBytes > 0 && total > p.MaxBytes && now.Sub(r.mod) > Grace
+		if !expired && !over {
+			res.Kept++
+			res.Bytes += r.size
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, r.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			res.Kept++
+			res.Bytes += r.size
+			continue
+		}
+		total -= r.size
+		res.Removed = append(res.Removed, r.name)
+		res.Freed += r.size
+	}
+	return res, errors.Join(errs...)
+}
+
+// Sweeper runs Sweep periodically.
+type Sweeper struct {
+	Dir      string
+	Policy   func() Policy        // read before each sweep
+	OnRemove func(names []string) // called after a sweep removed files (optional)
+	Logger   *slog.Logger
+}
+
+// Run sweeps now and then every interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.Sweep()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Sweep applies the current policy once. A policy without limits is a
+// no-op.
+func (s *Sweeper) Sweep() Result {
+	p := s.Policy()
+	if !p.Enabled() {
+		return Result{}
+	}
+	res, err := Sweep(s.Dir, p, time.Now())
+	if err != nil {
+		s.Logger.Warn("recording retention sweep incomplete", "error", err)
+	}
+	if len(res.Removed) > 0 {
+		s.Logger.Info("old recordings removed", "count", len(res.Removed), "freed_bytes", res.Freed,
+			"kept", res.Kept, "kept_bytes", res.Bytes)
+		if s.OnRemove != nil {
+			s.OnRemove(res.Removed)
+		}
+	}
+	return res
+}
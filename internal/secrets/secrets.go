@@ -0,0 +1,201 @@
+// Package secrets loads credentials from somewhere other than plain
+// environment variables.
+//
+// For a setting CAPTAINSLOG_X, exactly one of these may be set:
+//
+//	CAPTAINSLOG_X          the value itself (simplest; visible in `ps e`, docker inspect)
+//	CAPTAINSLOG_X_FILE     path to a file holding the value (Docker/Kubernetes secrets)
+//	CAPTAINSLOG_X_COMMAND  shell command that prints the value (e.g. `pass show captainslog/token`)
+//
+// File secrets are re-read when the file changes, so a rotated Docker or
+// Kubernetes secret takes effect without a restart. Commands run once at
+// startup — a password manager prompting every few seconds would be worse
+// than useless.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources, as reported by Secret.Source.
+const (
+	SourceNone    = ""
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceCommand = "command"
+)
+
+// commandTimeout bounds KEY_COMMAND. A hung password manager must not hang
+// startup forever.
+const commandTimeout = 15 * time.Second
+
+// Secret is a credential that may change at runtime. The zero value is an
+// empty secret; Get on a nil *Secret returns "".
+type Secret struct {
+	name   string
+	source string
+	path   string
+	logger *slog.Logger
+
+	mu       sync.RWMutex
+	value    string
+	sum      [32]byte
+	onChange []func(string)
+}
+
+// Load resolves the secret for key from the environment.
+func Load(key string, logger *slog.Logger) (*Secret, error) {
+	plain, file, command := os.Getenv(key), os.Getenv(key+"_FILE"), os.Getenv(key+"_COMMAND")
+	set := 0
+	for _, v := range []string{plain, file, command} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("%s: set only one of %s, %s_FILE, %s_COMMAND", key, key, key, key)
+	}
+
+	s := &Secret{name: key, logger: logger}
+	switch {
+	case file != "":
+		s.source, s.path = SourceFile, file
+		v, err := readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		s.set(v)
+	case command != "":
+		s.source = SourceCommand
+		v, err := runCommand(command)
+		if err != nil {
+			return nil, fmt.Errorf("%s_COMMAND: %w", key, err)
+		}
+		s.set(v)
+	case plain != "":
+		s.source = SourceEnv
+		s.set(plain)
+	}
+	return s, nil
+}
+
+// Get returns the current value.
+func (s *Secret) Get() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+// Source reports where the value came from (SourceEnv, SourceFile, …).
+func (s *Secret) Source() string {
+	if s == nil {
+		return SourceNone
+	}
+	return s.source
+}
+
+// OnChange registers fn to run with the new value after each reload.
+func (s *Secret) OnChange(fn func(string)) {
+	s.mu.Lock()
+	s.onChange = append(s.onChange, fn)
+	s.mu.Unlock()
+}
+
+func (s *Secret) set(v string) bool {
+	sum := sha256.Sum256([]byte(v))
+	s.mu.Lock()
+	changed := sum != s.sum
+	s.value, s.sum = v, sum
+	hooks := s.onChange
+	s.mu.Unlock()
+	if changed {
+		for _, fn := range hooks {
+			fn(v)
+		}
+	}
+	return changed
+}
+
+// Watch re-reads a file secret every interval until ctx is done. It is a
+// no-op for other sources. An unreadable or empty file keeps the previous
+// value — failing open to "no token" would silently disable auth.
+func (s *Secret) Watch(ctx context.Context, interval time.Duration) {
+	if s == nil || s.source != SourceFile {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Reload()
+		}
+	}
+}
+
+// Reload re-reads a file secret now. Returns true if the value changed.
+func (s *Secret) Reload() bool {
+	if s == nil || s.source != SourceFile {
+		return false
+	}
+	v, err := readFile(s.path)
+	if err != nil {
+		s.logger.Warn("secret reload failed — keeping previous value", "secret", s.name, "file", s.path, "error", err)
+		return false
+	}
+	if s.set(v) {
+		// Never log the value — only that it changed.
+		s.logger.Info("secret reloaded", "secret", s.name, "file", s.path)
+		return true
+	}
+	return false
+}
+
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return v, nil
+}
+
+func runCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return "", fmt.Errorf("%w: %s", err, msg)
+	}
+	// WHY first line only? `pass show` prints the password on line one and
+	// optional metadata after it.
+	v, _, _ := strings.Cut(stdout.String(), "\n")
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", fmt.Errorf("command printed nothing")
+	}
+	return v, nil
+}
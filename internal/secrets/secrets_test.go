@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func TestLoadPlainEnv(t *testing.T) {
+	t.Setenv("CL_TEST_TOKEN", "plain")
+	s, err := Load("CL_TEST_TOKEN", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Get() != "plain" || s.Source() != SourceEnv {
+		t.Errorf("got %q from %q", s.Get(), s.Source())
+	}
+}
+
+func TestLoadUnset(t *testing.T) {
+	s, err := Load("CL_TEST_UNSET", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Get() != "" || s.Source() != SourceNone {
+		t.Errorf("got %q from %q", s.Get(), s.Source())
+	}
+}
+
+func TestLoadFileTrimsNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("from-file\n"), 0600)
+	t.Setenv("CL_TEST_TOKEN_FILE", path)
+
+	s, err := Load("CL_TEST_TOKEN", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Get() != "from-file" || s.Source() != SourceFile {
+		t.Errorf("got %q from %q", s.Get(), s.Source())
+	}
+}
+
+func TestLoadCommandFirstLine(t *testing.T) {
+	t.Setenv("CL_TEST_TOKEN_COMMAND", `printf 'hunter2\nurl: example.com\n'`)
+	s, err := Load("CL_TEST_TOKEN", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Get() != "hunter2" || s.Source() != SourceCommand {
+		t.Errorf("got %q from %q", s.Get(), s.Source())
+	}
+}
+
+func TestLoadCommandFailure(t *testing.T) {
+	t.Setenv("CL_TEST_TOKEN_COMMAND", "echo nope >&2; exit 3")
+	if _, err := Load("CL_TEST_TOKEN", testLogger()); err == nil {
+		t.Error("expected an error from a failing command")
+	}
+}
+
+func TestLoadRejectsAmbiguousSources(t *testing.T) {
+	t.Setenv("CL_TEST_TOKEN", "a")
+	t.Setenv("CL_TEST_TOKEN_FILE", "/nonexistent")
+	if _, err := Load("CL_TEST_TOKEN", testLogger()); err == nil {
+		t.Error("expected an error when two sources are set")
+	}
+}
+
+func TestReloadAndOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("v1"), 0600)
+	t.Setenv("CL_TEST_TOKEN_FILE", path)
+	s, _ := Load("CL_TEST_TOKEN", testLogger())
+
+	var got []string
+	s.OnChange(func(v string) { got = append(got, v) })
+
+	if s.Reload() {
+		t.Error("Reload should report no change for identical content")
+	}
+	os.WriteFile(path, []byte("v2\n"), 0600)
+	if !s.Reload() || s.Get() != "v2" {
+		t.Errorf("after rotation Get = %q", s.Get())
+	}
+	os.WriteFile(path, []byte(""), 0600)
+	if s.Reload() || s.Get() != "v2" {
+		t.Errorf("empty file should keep the previous value, got %q", s.Get())
+	}
+	if len(got) != 1 || got[0] != "v2" {
+		t.Errorf("OnChange calls = %v", got)
+	}
+}
+
+func TestWatchPicksUpChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("v1"), 0600)
+	t.Setenv("CL_TEST_TOKEN_FILE", path)
+	s, _ := Load("CL_TEST_TOKEN", testLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx, 10*time.Millisecond)
+
+	os.WriteFile(path, []byte("v2"), 0600)
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Get() != "v2" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.Get() != "v2" {
+		t.Errorf("Watch did not reload, Get = %q", s.Get())
+	}
+}
+
+func TestNilSecret(t *testing.T) {
+	var s *Secret
+	if s.Get() != "" || s.Source() != SourceNone {
+		t.Error("nil Secret should be empty")
+	}
+}
@@ -0,0 +1,66 @@
+package selftest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestToneWAVHeader(t *testing.T) {
+	wav := ToneWAV(time.Second, 440)
+
+	if string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		t.Fatalf("missing RIFF/WAVE header: %q", wav[:12])
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:28]); rate != SampleRate {
+		t.Errorf("sample rate = %d, want %d", rate, SampleRate)
+	}
+	if channels := binary.LittleEndian.Uint16(wav[22:24]); channels != 1 {
+		t.Errorf("channels = %d, want 1", channels)
+	}
+	dataLen := binary.LittleEndian.Uint32(wav[40:44])
+	if dataLen != SampleRate*2 {
+		t.Errorf("data length = %d, want %d (1s of 16-bit mono)", dataLen, SampleRate*2)
+	}
+	if len(wav) != 44+int(dataLen) {
+		t.Errorf("file length = %d, want %d", len(wav), 44+dataLen)
+	}
+}
+
+func TestRunAllOK(t *testing.T) {
+	report := Run(context.Background(), []Stage{
+		{Name: "a", Run: func(context.Context) (string, error) { return "fine", nil }},
+		{Name: "b", Run: func(context.Context) (string, error) { return "", Skip("disabled") }},
+	})
+	if !report.OK {
+		t.Fatal("report should be OK when no stage fails")
+	}
+	if report.Stages[0].Status != StatusOK || report.Stages[0].Detail != "fine" {
+		t.Errorf("stage a = %+v", report.Stages[0])
+	}
+	if report.Stages[1].Status != StatusSkipped || report.Stages[1].Detail != "disabled" {
+		t.Errorf("stage b = %+v, want skipped with reason", report.Stages[1])
+	}
+}
+
+func TestRunStopsAfterFailure(t *testing.T) {
+	ran := false
+	report := Run(context.Background(), []Stage{
+		{Name: "proxy", Run: func(context.Context) (string, error) { return "", errors.New("backend down") }},
+		{Name: "vault", Run: func(context.Context) (string, error) { ran = true; return "", nil }},
+	})
+	if report.OK {
+		t.Error("report should not be OK after a failed stage")
+	}
+	if report.Stages[0].Status != StatusFailed || report.Stages[0].Error != "backend down" {
+		t.Errorf("stage proxy = %+v", report.Stages[0])
+	}
+	if ran {
+		t.Error("stages after a failure should not run")
+	}
+	if report.Stages[1].Status != StatusSkipped {
+		t.Errorf("stage vault status = %q, want skipped", report.Stages[1].Status)
+	}
+}
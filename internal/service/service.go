@@ -0,0 +1,99 @@
+// Package service registers Captain's Log to start with the machine: a
+// systemd user unit on Linux, a launchd agent on macOS and a Windows
+// service, so desktop users don't have to keep a terminal open.
+//
+// The unit runs the binary that installed it, by absolute path, with the
+// CAPTAINSLOG_* environment of the install, so the service sees the same
+// vault, backends and settings as the shell the user tested in.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Name is the systemd unit and Windows service name.
+const Name = "captainslog"
+
+// Label is the launchd job label.
+const Label = "io.github.ryan-winkler.captainslog"
+
+// ErrUnsupported means this OS has no service manager we know.
+var ErrUnsupported = errors.New("service install is not supported on this OS")
+
+// Spec is what the service runs.
+type Spec struct {
+	Exec string            // absolute path to the binary
+	Env  map[string]string // environment, set in the unit
+}
+
+// Status is what the service manager reports about the service.
+type Status struct {
+	Installed bool
+	Running   bool
+	Path      string // unit file, plist or registry key
+	Detail    string // the manager's own word for the state
+}
+
+// NewSpec describes the running binary with the CAPTAINSLOG_* variables
+// from environ (as from os.Environ). configDir is pinned as
+// CAPTAINSLOG_CONFIG_DIR: the service may run with another home directory
+// (LocalSystem on Windows) and would otherwise not find settings.json.
+func NewSpec(environ []string, configDir string) (Spec, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Spec{}, fmt.Errorf("find executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	env := Capture(environ)
+	if configDir != "" {
+		if abs, err := filepath.Abs(configDir); err == nil {
+			env["CAPTAINSLOG_CONFIG_DIR"] = abs
+		}
+	}
+	return Spec{Exec: exe, Env: env}, nil
+}
+
+// Capture returns the CAPTAINSLOG_* variables of environ.
+func Capture(environ []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(k, "CAPTAINSLOG_") {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// sortedKeys returns the keys of env in order, so units are stable.
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Install writes the unit for spec and starts it. Installing again
+// replaces the unit, which is how a changed environment is picked up.
+func Install(spec Spec) error { return install(spec) }
+
+// Uninstall stops the service and removes its unit.
+func Uninstall() error { return uninstall() }
+
+// Query reports the service's state.
+func Query() (Status, error) { return query() }
+
+// Start hands the process to the service manager when it runs as a
+// Windows service. stop is closed when the manager asks it to stop; the
+// caller shuts down and then calls done. Elsewhere stop never closes and
+// done does nothing.
+func Start() (stop <-chan struct{}, done func()) { return start() }
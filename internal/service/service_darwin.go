@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// plistPath is the per-user launch agent, started at login.
+func plistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", Label+".plist"), nil
+}
+
+// domain is the launchd domain of the logged-in user's GUI session.
+func domain() string { return fmt.Sprintf("gui/%d", os.Getuid()) }
+
+func launchctl(args ...string) (string, error) {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+func install(spec Spec) error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	home, _ := os.UserHomeDir()
+	logFile := filepath.Join(home, "Library", "Logs", Name+".log")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create LaunchAgents dir: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return fmt.Errorf("create log dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(launchdPlist(spec, logFile)), 0644); err != nil {
+		return fmt.Errorf("write plist: %w", err)
+	}
+	// Non-fatal: bootout fails when the agent isn't loaded yet. A loaded
+	// one must go first, or bootstrap keeps the old plist.
+	launchctl("bootout", domain()+"/"+Label)
+	_, err = launchctl("bootstrap", domain(), path)
+	return err
+}
+
+func uninstall() error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is not installed", path)
+	}
+	// Non-fatal: an agent that isn't loaded has nothing to stop.
+	launchctl("bootout", domain()+"/"+Label)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove plist: %w", err)
+	}
+	return nil
+}
+
+func query() (Status, error) {
+	path, err := plistPath()
+	if err != nil {
+		return Status{}, err
+	}
+	st := Status{Path: path}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	st.Installed = true
+	out, err := launchctl("print", domain()+"/"+Label)
+	if err != nil {
+		st.Detail = "not loaded"
+		return st, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), " = "); ok && k == "state" {
+			st.Detail = v
+			break
+		}
+	}
+	st.Running = st.Detail == "running"
+	return st, nil
+}
+
+func start() (<-chan struct{}, func()) { return nil, func() {} }
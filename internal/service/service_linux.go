@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// unitPath is the systemd user unit. A user unit, not a system one: the
+// server reads the user's vault and needs no root.
+func unitPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", Name+".service"), nil
+}
+
+func systemctl(args ...string) (string, error) {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("systemctl --user %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+func install(spec Spec) error {
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create unit dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(systemdUnit(spec)), 0644); err != nil {
+		return fmt.Errorf("write unit: %w", err)
+	}
+	if _, err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	// restart, not start: a reinstall must pick up the new unit.
+	if _, err := systemctl("enable", Name); err != nil {
+		return err
+	}
+	_, err = systemctl("restart", Name)
+	return err
+}
+
+func uninstall() error {
+	path, err := unitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is not installed", path)
+	}
+	// Non-fatal: a unit that never started can't be stopped; remove it anyway.
+	systemctl("disable", "--now", Name)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove unit: %w", err)
+	}
+	_, err = systemctl("daemon-reload")
+	return err
+}
+
+func query() (Status, error) {
+	path, err := unitPath()
+	if err != nil {
+		return Status{}, err
+	}
+	st := Status{Path: path}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	st.Installed = true
+	// is-active exits non-zero for anything but active; its output is
+	// still the state.
+	out, _ := systemctl("is-active", Name)
+	st.Detail = strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	st.Running = st.Detail == "active"
+	return st, nil
+}
+
+func start() (<-chan struct{}, func()) { return nil, func() {} }
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+func install(Spec) error { return ErrUnsupported }
+
+func uninstall() error { return ErrUnsupported }
+
+func query() (Status, error) { return Status{}, ErrUnsupported }
+
+func start() (<-chan struct{}, func()) { return nil, func() {} }
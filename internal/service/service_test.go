@@ -0,0 +1,46 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	env := Capture([]string{"HOME=/home/a", "CAPTAINSLOG_PORT=8090", "CAPTAINSLOG_VAULT_DIR=/v=x", "PATH=/bin"})
+	if len(env) != 2 || env["CAPTAINSLOG_PORT"] != "8090" || env["CAPTAINSLOG_VAULT_DIR"] != "/v=x" {
+		t.Errorf("Capture = %v", env)
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	unit := systemdUnit(Spec{
+		Exec: "/opt/my apps/captainslog",
+		Env:  map[string]string{"CAPTAINSLOG_VAULT_DIR": `/home/a/100% "notes"`, "CAPTAINSLOG_PORT": "8090"},
+	})
+	for _, want := range []string{
+		"ExecStart=\"/opt/my apps/captainslog\"\n",
+		"Environment=\"CAPTAINSLOG_PORT=8090\"\nEnvironment=\"CAPTAINSLOG_VAULT_DIR=/home/a/100%% \\\"notes\\\"\"\n",
+		"WantedBy=default.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := launchdPlist(Spec{
+		Exec: "/Applications/Captain's Log/captainslog",
+		Env:  map[string]string{"CAPTAINSLOG_LLM_URL": "http://x/?a=1&b=2"},
+	}, "/Users/a/Library/Logs/captainslog.log")
+	for _, want := range []string{
+		"<key>Label</key>\n\t<string>" + Label + "</string>",
+		"<string>/Applications/Captain's Log/captainslog</string>",
+		"<key>CAPTAINSLOG_LLM_URL</key>\n\t\t<string>http://x/?a=1&amp;b=2</string>",
+		"<key>StandardErrorPath</key>\n\t<string>/Users/a/Library/Logs/captainslog.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist lacks %q:\n%s", want, plist)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// keyPath is the service's registry key; its Environment value is the
+// environment the service manager starts it with.
+const keyPath = `SYSTEM\CurrentControlSet\Services\` + Name
+
+func install(spec Spec) error {
+	// A service has no console: without a log dir its output is lost.
+	if spec.Env["CAPTAINSLOG_LOG_DIR"] == "" && spec.Env["CAPTAINSLOG_CONFIG_DIR"] != "" {
+		spec.Env["CAPTAINSLOG_LOG_DIR"] = filepath.Join(spec.Env["CAPTAINSLOG_CONFIG_DIR"], "logs")
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+	cfg := mgr.Config{
+		DisplayName: "Captain's Log",
+		Description: "Local speech-to-text",
+		StartType:   mgr.StartAutomatic,
+	}
+	s, err := m.OpenService(Name)
+	if err == nil {
+		// Reinstall: stop the old one, update it in place.
+		stopAndWait(s)
+		cur, err := s.Config()
+		if err == nil {
+			cur.BinaryPathName = fmt.Sprintf("%q", spec.Exec)
+			cur.DisplayName, cur.Description, cur.StartType = cfg.DisplayName, cfg.Description, cfg.StartType
+			err = s.UpdateConfig(cur)
+		}
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("update service: %w", err)
+		}
+	} else {
+		s, err = m.CreateService(Name, spec.Exec, cfg)
+		if err != nil {
+			return fmt.Errorf("create service: %w", err)
+		}
+	}
+	defer s.Close()
+	if err := setEnvironment(spec.Env); err != nil {
+		return err
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("start service: %w", err)
+	}
+	return nil
+}
+
+func setEnvironment(env map[string]string) error {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, keyPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("open service key: %w", err)
+	}
+	defer k.Close()
+	vars := make([]string, 0, len(env))
+	for _, name := range sortedKeys(env) {
+		vars = append(vars, name+"="+env[name])
+	}
+	if err := k.SetStringsValue("Environment", vars); err != nil {
+		return fmt.Errorf("set service environment: %w", err)
+	}
+	return nil
+}
+
+// stopAndWait stops s and waits up to 20s for it to exit.
+func stopAndWait(s *mgr.Service) {
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return
+	}
+	for deadline := time.Now().Add(20 * time.Second); st.State != svc.Stopped && time.Now().Before(deadline); {
+		time.Sleep(300 * time.Millisecond)
+		if st, err = s.Query(); err != nil {
+			return
+		}
+	}
+}
+
+func uninstall() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", Name)
+	}
+	defer s.Close()
+	stopAndWait(s)
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("delete service: %w", err)
+	}
+	return nil
+}
+
+var stateNames = map[svc.State]string{
+	svc.Stopped:         "stopped",
+	svc.StartPending:    "starting",
+	svc.StopPending:     "stopping",
+	svc.Running:         "running",
+	svc.ContinuePending: "resuming",
+	svc.PausePending:    "pausing",
+	svc.Paused:          "paused",
+}
+
+func query() (Status, error) {
+	st := Status{Path: `HKLM\` + keyPath}
+	m, err := mgr.Connect()
+	if err != nil {
+		return st, fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(Name)
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("open service: %w", err)
+	}
+	defer s.Close()
+	st.Installed = true
+	q, err := s.Query()
+	if err != nil {
+		return st, fmt.Errorf("query service: %w", err)
+	}
+	st.Detail = stateNames[q.State]
+	st.Running = q.State == svc.Running
+	return st, nil
+}
+
+// handler answers the service manager for the running server.
+type handler struct {
+	stop chan struct{} // closed on a stop request
+	done chan struct{} // closed by the caller once shut down
+}
+
+func (h *handler) Execute(_ []string, req <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for c := range req {
+		switch c.Cmd {
+		case svc.Interrogate:
+			status <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending, WaitHint: 15000}
+			close(h.stop)
+			<-h.done
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+func start() (<-chan struct{}, func()) {
+	if ok, err := svc.IsWindowsService(); err != nil || !ok {
+		return nil, func() {}
+	}
+	h := &handler{stop: make(chan struct{}), done: make(chan struct{})}
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		// Non-fatal: Run only fails when not started by the manager,
+		// which IsWindowsService has ruled out.
+		svc.Run(Name, h)
+	}()
+	return h.stop, func() {
+		close(h.done)
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package spool buffers request bodies in memory up to a threshold and
+// spills the rest to a temp file.
+//
+// The proxy has to hold a whole upload so it can replay it for the SRT
+// fallback, and the watcher has to build a multipart body around a
+// recording. Held in RAM, a 90MB recording on a Raspberry Pi is an OOM
+// kill; a spool keeps small uploads fast and large ones on disk.
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultMemory is the in-memory threshold used when a caller passes 0.
+const DefaultMemory = 8 << 20
+
+// ErrClosed is returned by Write and Reader after Close.
+var ErrClosed = errors.New("spool: buffer closed")
+
+// Buffer is an append-only byte buffer that moves to a temp file once it
+// outgrows its memory threshold. Write is not safe for concurrent use;
+// readers from Reader may run concurrently once writing is done.
+//
+// Always Close a Buffer — it is what removes the temp file.
+type Buffer struct {
+	memory int64
+	dir    string
+
+	mu     sync.Mutex
+	mem    bytes.Buffer
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// New returns a Buffer that keeps up to memory bytes in RAM (DefaultMemory
+// if memory <= 0) and spills to a temp file in dir ("" = os.TempDir()).
+func New(memory int64, dir string) *Buffer {
+	if memory <= 0 {
+		memory = DefaultMemory
+	}
+	return &Buffer{memory: memory, dir: dir}
+}
+
+// Write appends p, spilling to disk when the threshold is crossed.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, ErrClosed
+	}
+	if b.file == nil && b.size+int64(len(p)) > b.memory {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *Buffer) spill() error {
+	f, err := os.CreateTemp(b.dir, "captainslog-spool-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.mem.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	b.file = f
+	b.mem = bytes.Buffer{}
+	return nil
+}
+
+// ReadFrom copies r into the buffer until EOF.
+func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	// WHY not io.Copy? It would call this ReadFrom again.
+	buf := make([]byte, 32<<10)
+	var total int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			w, werr := b.Write(buf[:n])
+			total += int64(w)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Size is the number of bytes written.
+func (b *Buffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Spilled reports whether the contents live in a temp file.
+func (b *Buffer) Spilled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file != nil
+}
+
+// Reader returns a new reader over everything written so far. Each call
+// starts at the beginning, so the same body can be sent more than once.
+func (b *Buffer) Reader() (io.ReadSeeker, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size), nil
+	}
+	return bytes.NewReader(b.mem.Bytes()), nil
+}
+
+// Close releases the memory and deletes the temp file, if any. It is safe
+// to call more than once.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.mem = bytes.Buffer{}
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	b.file.Close()
+	b.file = nil
+	return os.Remove(name)
+}
@@ -0,0 +1,67 @@
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaysInMemoryUnderThreshold(t *testing.T) {
+	b := New(16, t.TempDir())
+	defer b.Close()
+	b.Write([]byte("hello"))
+	if b.Spilled() || b.Size() != 5 {
+		t.Errorf("spilled=%v size=%d", b.Spilled(), b.Size())
+	}
+	r, _ := b.Reader()
+	if got, _ := io.ReadAll(r); string(got) != "hello" {
+		t.Errorf("read %q", got)
+	}
+}
+
+func TestSpillsAndCleansUp(t *testing.T) {
+	dir := t.TempDir()
+	b := New(8, dir)
+	want := strings.Repeat("0123456789", 100)
+	if _, err := b.ReadFrom(strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Spilled() {
+		t.Fatal("expected a spill past the threshold")
+	}
+	// Two independent readers see the whole body.
+	for i := 0; i < 2; i++ {
+		r, _ := b.Reader()
+		got, _ := io.ReadAll(r)
+		if string(got) != want {
+			t.Fatalf("reader %d got %d bytes", i, len(got))
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "captainslog-spool-*"))
+	if len(files) != 1 {
+		t.Fatalf("temp files = %v", files)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Error("Close should delete the temp file")
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+	if _, err := b.Write([]byte("x")); err != ErrClosed {
+		t.Errorf("Write after Close = %v", err)
+	}
+}
+
+func TestSpillDirMissing(t *testing.T) {
+	b := New(4, filepath.Join(t.TempDir(), "nope"))
+	defer b.Close()
+	if _, err := b.Write(bytes.Repeat([]byte("x"), 10)); err == nil {
+		t.Error("expected an error when the spill dir doesn't exist")
+	}
+}
@@ -0,0 +1,220 @@
+// Package ssrf guards the backend URLs users can change at runtime.
+//
+// Settings PUT accepts any Whisper or LLM URL and the server then fetches
+// it, so an exposed instance is a proxy into whatever network it sits on.
+// A Policy limits schemes and hosts when the URL is set, and again on every
+// request and redirect; with BlockLinkLocal it also refuses to dial
+// link-local and cloud metadata addresses, checked on the resolved IP so DNS
+// tricks can't route around it.
+//
+// Loopback and private ranges stay reachable on purpose: the whole point of
+// the app is talking to a Whisper server on localhost or the LAN.
+package ssrf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlocked is wrapped by every policy rejection.
+var ErrBlocked = errors.New("blocked by URL policy")
+
+// metadataPrefixes are refused when BlockLinkLocal is set.
+var metadataPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("169.254.0.0/16"),     // IPv4 link-local, incl. AWS/GCP/Azure metadata at .169.254
+	netip.MustParsePrefix("fe80::/10"),          // IPv6 link-local
+	netip.MustParsePrefix("fd00:ec2::254/128"),  // AWS IMDS over IPv6
+	netip.MustParsePrefix("100.100.100.200/32"), // Alibaba Cloud metadata
+}
+
+// Policy describes which backend URLs may be fetched. The zero value allows
+// http and https to any host.
+type Policy struct {
+	Schemes        []string
+	BlockLinkLocal bool
+
+	hosts    []string // exact hostnames, lower-case
+	suffixes []string // from "*.example.com" entries, stored as ".example.com"
+	prefixes []netip.Prefix
+}
+
+// New builds a Policy. schemes and hosts are comma-separated; empty hosts
+// means any host. Host entries are hostnames, "*.domain" wildcards, IPs or
+// CIDRs. Names match the URL's host as written — a CIDR entry doesn't admit
+// a hostname that happens to resolve into it.
+func New(schemes, hosts string, blockLinkLocal bool) (*Policy, error) {
+	p := &Policy{BlockLinkLocal: blockLinkLocal}
+	for _, s := range splitList(schemes) {
+		s = strings.ToLower(s)
+		if s != "http" && s != "https" {
+			return nil, fmt.Errorf("unsupported URL scheme %q (want http or https)", s)
+		}
+		p.Schemes = append(p.Schemes, s)
+	}
+	for _, h := range splitList(hosts) {
+		h = strings.ToLower(h)
+		switch {
+		case strings.HasPrefix(h, "*."):
+			p.suffixes = append(p.suffixes, h[1:])
+		case strings.Contains(h, "/"):
+			pfx, err := netip.ParsePrefix(h)
+			if err != nil {
+				return nil, fmt.Errorf("bad CIDR in host allowlist: %w", err)
+			}
+			p.prefixes = append(p.prefixes, pfx.Masked())
+		default:
+			if addr, err := netip.ParseAddr(strings.Trim(h, "[]")); err == nil {
+				p.prefixes = append(p.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			} else {
+				p.hosts = append(p.hosts, h)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Restricted reports whether the policy limits anything beyond the
+// defaults.
+func (p *Policy) Restricted() bool {
+	return len(p.Schemes) > 0 || len(p.hosts)+len(p.suffixes)+len(p.prefixes) > 0 || p.BlockLinkLocal
+}
+
+// CheckURL validates raw against the scheme and host rules, and rejects
+// blocked IP literals up front so the caller gets the error at settings
+// time rather than on the first transcription.
+func (p *Policy) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	return p.check(u)
+}
+
+func (p *Policy) check(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("%w: scheme %q (only http and https)", ErrBlocked, u.Scheme)
+	}
+	if len(p.Schemes) > 0 && !contains(p.Schemes, scheme) {
+		return fmt.Errorf("%w: scheme %q not in %v", ErrBlocked, scheme, p.Schemes)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: URL has no host", ErrBlocked)
+	}
+	if !p.hostAllowed(host) {
+		return fmt.Errorf("%w: host %q not in the allowlist", ErrBlocked, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.CheckAddr(addr)
+	}
+	return nil
+}
+
+func (p *Policy) hostAllowed(host string) bool {
+	if len(p.hosts)+len(p.suffixes)+len(p.prefixes) == 0 {
+		return true
+	}
+	if contains(p.hosts, host) {
+		return true
+	}
+	for _, s := range p.suffixes {
+		if strings.HasSuffix(host, s) {
+			return true
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		for _, pfx := range p.prefixes {
+			if pfx.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckAddr rejects link-local and metadata addresses when BlockLinkLocal
+// is set.
+func (p *Policy) CheckAddr(addr netip.Addr) error {
+	if !p.BlockLinkLocal {
+		return nil
+	}
+	addr = addr.Unmap()
+	for _, pfx := range metadataPrefixes {
+		if pfx.Contains(addr) {
+			return fmt.Errorf("%w: %s is a link-local or cloud metadata address", ErrBlocked, addr)
+		}
+	}
+	return nil
+}
+
+// Control is a net.Dialer.Control hook that applies CheckAddr to the
+// address actually being dialled.
+func (p *Policy) Control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: cannot parse dial address %q", ErrBlocked, address)
+	}
+	return p.CheckAddr(addr)
+}
+
+// Transport returns an *http.Transport like http.DefaultTransport whose
+// dialer enforces CheckAddr. base is cloned, not modified.
+func (p *Policy) Transport(base *http.Transport) *http.Transport {
+	t := base.Clone()
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: p.Control}
+	t.DialContext = d.DialContext
+	return t
+}
+
+// Wrap checks every outgoing request — including each redirect hop —
+// against the scheme and host rules before handing it to next.
+func (p *Policy) Wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripper{p: p, next: next}
+}
+
+type roundTripper struct {
+	p    *Policy
+	next http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.p.check(req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return rt.next.RoundTrip(req)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package ssrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestZeroPolicyAllowsHTTP(t *testing.T) {
+	p, _ := New("", "", false)
+	for _, u := range []string{"http://127.0.0.1:5000", "https://whisper.lan", "http://169.254.169.254/latest"} {
+		if err := p.CheckURL(u); err != nil {
+			t.Errorf("CheckURL(%q) = %v", u, err)
+		}
+	}
+	for _, u := range []string{"file:///etc/passwd", "gopher://x", "http://"} {
+		if err := p.CheckURL(u); !errors.Is(err, ErrBlocked) {
+			t.Errorf("CheckURL(%q) = %v, want ErrBlocked", u, err)
+		}
+	}
+	if p.Restricted() {
+		t.Error("empty policy should not be Restricted")
+	}
+}
+
+func TestSchemes(t *testing.T) {
+	p, err := New("https", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CheckURL("http://whisper.lan") == nil {
+		t.Error("http should be rejected when only https is allowed")
+	}
+	if err := p.CheckURL("https://whisper.lan"); err != nil {
+		t.Error(err)
+	}
+	if _, err := New("http,ftp", "", false); err == nil {
+		t.Error("ftp should not be accepted as a scheme")
+	}
+}
+
+func TestHostAllowlist(t *testing.T) {
+	p, err := New("", "localhost, *.home.arpa, 192.168.1.0/24, ::1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := []string{
+		"http://localhost:5000", "http://WHISPER.home.arpa", "http://192.168.1.20:8000",
+		"http://[::1]:5000", "http://[::ffff:192.168.1.9]/",
+	}
+	for _, u := range allowed {
+		if err := p.CheckURL(u); err != nil {
+			t.Errorf("CheckURL(%q) = %v", u, err)
+		}
+	}
+	for _, u := range []string{"http://home.arpa", "http://192.168.2.1", "http://evil.com", "http://10.0.0.1"} {
+		if p.CheckURL(u) == nil {
+			t.Errorf("CheckURL(%q) should be blocked", u)
+		}
+	}
+	if _, err := New("", "10.0.0.0/33", false); err == nil {
+		t.Error("bad CIDR should fail")
+	}
+}
+
+func TestBlockLinkLocal(t *testing.T) {
+	p, _ := New("", "", true)
+	for _, u := range []string{"http://169.254.169.254/latest/meta-data", "http://[fe80::1]/", "http://[::ffff:169.254.169.254]/", "http://100.100.100.200/"} {
+		if p.CheckURL(u) == nil {
+			t.Errorf("CheckURL(%q) should be blocked", u)
+		}
+	}
+	if err := p.CheckURL("http://127.0.0.1:5000"); err != nil {
+		t.Errorf("loopback must stay reachable: %v", err)
+	}
+	if p.Control("tcp4", "169.254.169.254:80", nil) == nil {
+		t.Error("Control should refuse the metadata IP")
+	}
+	if err := p.Control("tcp4", "10.1.2.3:80", nil); err != nil {
+		t.Errorf("Control(private) = %v", err)
+	}
+}
+
+func TestWrapChecksRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://localhost:1/elsewhere", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	p, _ := New("", "127.0.0.1", false)
+	client := &http.Client{Transport: p.Wrap(http.DefaultTransport)}
+	resp, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatalf("allowed host: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := client.Get(redirector.URL); !errors.Is(err, ErrBlocked) {
+		t.Errorf("redirect to a non-allowed host = %v, want ErrBlocked", err)
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WHY archive? Segments are most of what the index keeps on disk, and a
+// year of long meetings fills a small disk. Old transcripts are rarely
+// opened, and their JSON compresses about tenfold. Archive gzips their
+// segment files in place. The entries stay in the index, so history,
+// filters and search still find them, and reading a segment page
+// decompresses on the fly. gzip rather than zstd: it is in the standard
+// library, and most of the gain is the same.
+
+// archiveExt is appended to the segment file of an archived entry.
+const archiveExt = ".gz"
+
+// ArchiveResult describes one Archive run.
+type ArchiveResult struct {
+	Archived int   `json:"archived"`    // entries whose segments were compressed
+	Saved    int64 `json:"saved_bytes"` // disk space freed
+}
+
+// Archive compresses the segment files of entries created before cutoff.
+// Already archived entries are skipped, so it can run repeatedly. An entry
+// that fails is left uncompressed and reported in the error; the rest are
+// archived anyway.
+func (s *Store) Archive(cutoff time.Time) (ArchiveResult, error) {
+	var res ArchiveResult
+	entries, err := s.List()
+	if err != nil {
+		return res, fmt.Errorf("read index: %w", err)
+	}
+	var errs []error
+	for _, e := range entries {
+		if !e.CreatedAt.Before(cutoff) {
+			continue
+		}
+		saved, err := archiveFile(s.segmentsPath(e.ID))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("archive %s: %w", e.ID, err))
+			continue
+		}
+		if saved != 0 {
+			res.Archived++
+			res.Saved += saved
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// archiveFile replaces path with a gzipped copy, returning the bytes
+// saved; 0 if there was nothing to compress. The copy is complete before
+// the original goes, so a crash leaves one or both, never neither.
+func archiveFile(path string) (int64, error) {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// A temp file of its own: instances sharing the directory may archive
+	// the same entry at once.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	zw, _ := gzip.NewWriterLevel(tmp, gzip.BestCompression)
+	if _, err := io.Copy(zw, in); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	packed, _ := tmp.Seek(0, io.SeekCurrent)
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path+archiveExt); err != nil {
+		return 0, err
+	}
+	in.Close()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return info.Size() - packed, nil
+}
+
+// readSegmentFile reads an entry's segment file, from the archive if it
+// was compressed. A file that is in neither place reads as
+// os.ErrNotExist.
+func readSegmentFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return data, err
+	}
+	f, err := os.Open(path + archiveExt)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("archived segments: %w", err)
+	}
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("archived segments: %w", err)
+	}
+	return data, nil
+}
+
+// removeSegments deletes an entry's segment file, archived or not, and
+// its translation.
+func (s *Store) removeSegments(id string) {
+	path := s.segmentsPath(id)
+	os.Remove(path)
+	os.Remove(path + archiveExt)
+	os.Remove(s.translationPath(id))
+}
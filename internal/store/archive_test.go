@@ -0,0 +1,61 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	now := time.Now()
+	old, _ := s.Add(Entry{VaultFile: "/v/old.md", CreatedAt: now.AddDate(0, -7, 0)})
+	recent, _ := s.Add(Entry{VaultFile: "/v/new.md", CreatedAt: now})
+	segs := []Segment{{Start: 0, End: 2, Text: strings.Repeat("engage ", 200)}, {Start: 2, End: 4, Text: "make it so"}}
+	s.SetSegments(old.ID, segs)
+	s.SetSegments(recent.ID, segs)
+
+	res, err := s.Archive(now.AddDate(0, -6, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Archived != 1 || res.Saved <= 0 {
+		t.Errorf("result = %+v, want 1 entry archived with space saved", res)
+	}
+	if _, err := os.Stat(s.segmentsPath(old.ID)); !os.IsNotExist(err) {
+		t.Error("uncompressed segments of the old entry still there")
+	}
+	if _, err := os.Stat(s.segmentsPath(recent.ID) + archiveExt); !os.IsNotExist(err) {
+		t.Error("recent entry archived")
+	}
+
+	// Reads decompress transparently.
+	page, err := s.Segments(old.ID, SegmentQuery{From: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Segments[1].Text != "make it so" {
+		t.Errorf("archived page = %+v", page)
+	}
+
+	// Archiving again finds nothing to do.
+	if res, err := s.Archive(now.AddDate(0, -6, 0)); err != nil || res.Archived != 0 {
+		t.Errorf("second run = %+v, %v", res, err)
+	}
+
+	// New segments replace the archive; removal deletes either form.
+	s.SetSegments(old.ID, segs[1:])
+	if all, _ := s.AllSegments(old.ID); len(all) != 1 {
+		t.Errorf("after SetSegments = %+v", all)
+	}
+	if _, err := os.Stat(s.segmentsPath(old.ID) + archiveExt); !os.IsNotExist(err) {
+		t.Error("stale archive left after SetSegments")
+	}
+	s.Archive(now.AddDate(0, -6, 0))
+	s.Remove(old.ID)
+	if _, err := os.Stat(s.segmentsPath(old.ID) + archiveExt); !os.IsNotExist(err) {
+		t.Error("archive left after Remove")
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// DateLayout is the day format of Calendar and of date ranges.
+const DateLayout = "2006-01-02"
+
+// Day totals the entries created on one calendar day.
+type Day struct {
+	Date     string  `json:"date"` // DateLayout
+	Count    int     `json:"count"`
+	Duration float64 `json:"duration"` // seconds of audio, from the segments
+	Words    int     `json:"words"`
+}
+
+// Calendar returns per-day totals for the entries created in [from, to),
+// with days taken in loc, oldest first. Days without entries are left out.
+// keep works as for Page. A database that can't be read has no days.
+func (s *Store) Calendar(from, to time.Time, loc *time.Location, keep func(Entry) bool) []Day {
+	var all []Entry
+	if s.db != nil {
+		all, _ = s.db.list(from, to)
+	} else {
+		s.lockRead()
+		all = append([]Entry(nil), s.entries...)
+		s.mu.Unlock()
+	}
+
+	byDate := map[string]*Day{}
+	for _, e := range all {
+		if e.CreatedAt.Before(from) || !e.CreatedAt.Before(to) {
+			continue
+		}
+		if keep != nil && !keep(e) {
+			continue
+		}
+		date := e.CreatedAt.In(loc).Format(DateLayout)
+		d := byDate[date]
+		if d == nil {
+			d = &Day{Date: date}
+			byDate[date] = d
+		}
+		d.Count++
+		d.Duration += e.Duration
+		d.Words += e.Words
+	}
+	days := make([]Day, 0, len(byDate))
+	for _, d := range byDate {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
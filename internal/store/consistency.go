@@ -0,0 +1,242 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+// Issue kinds reported by Check. Each is also the name of its fix action.
+const (
+	// OrphanRecordings are audio files no index entry points at. Fix: delete.
+	OrphanRecordings = "orphan_recordings"
+	// UnindexedNotes are Captain's Log vault notes missing from the index.
+	// Fix: add them.
+	UnindexedNotes = "unindexed_notes"
+	// MissingNotes are index entries whose vault file is gone. Fix: remove
+	// the entry (or just unlink the note if it still has a recording).
+	MissingNotes = "missing_notes"
+	// MissingRecordings are index entries whose recording is gone. Fix:
+	// unlink the recording from the entry.
+	MissingRecordings = "missing_recordings"
+)
+
+// FixActions lists every valid fix action.
+var FixActions = []string{OrphanRecordings, UnindexedNotes, MissingNotes, MissingRecordings}
+
+// Issue is one inconsistency.
+type Issue struct {
+	Path    string     `json:"path"`
+	EntryID string     `json:"entry_id,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+	ModTime *time.Time `json:"mod_time,omitempty"` // nil for missing files
+}
+
+// Report is the result of Check.
+type Report struct {
+	CheckedAt  time.Time          `json:"checked_at"`
+	Entries    int                `json:"entries"`
+	Notes      int                `json:"notes"`
+	Recordings int                `json:"recordings"`
+	Issues     map[string][]Issue `json:"issues"`
+	Clean      bool               `json:"clean"`
+}
+
+// Check compares the index against the vault and recordings directories.
+//
+// Only notes Captain's Log wrote (tagged "dictation") count as unindexed —
+// a vault is usually a whole Obsidian/Logseq notebook and the rest of it is
+// none of our business. Recordings younger than grace are skipped so an
+// upload whose vault save is still in flight isn't reported as orphaned.
+func Check(s *Store, vaultDir, recordingsDir string, grace time.Duration) (*Report, error) {
+	r := &Report{CheckedAt: time.Now().UTC(), Issues: map[string][]Issue{}}
+	for _, k := range FixActions {
+		r.Issues[k] = []Issue{}
+	}
+	// WHY fail rather than report? An unreadable index would list every
+	// recording as orphaned, and a fix in the same request deletes them.
+	entries, err := s.List()
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	r.Entries = len(entries)
+
+	indexedNotes := map[string]bool{}
+	indexedRecordings := map[string]bool{}
+	for _, e := range entries {
+		if e.VaultFile != "" {
+			indexedNotes[vault.ExpandDir(e.VaultFile)] = true
+			if _, err := os.Stat(e.VaultFile); errors.Is(err, os.ErrNotExist) {
+				r.Issues[MissingNotes] = append(r.Issues[MissingNotes], Issue{Path: e.VaultFile, EntryID: e.ID})
+			}
+		}
+		if e.Recording != "" {
+			indexedRecordings[e.Recording] = true
+			if recordingsDir != "" {
+				path := filepath.Join(recordingsDir, e.Recording)
+				if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+					r.Issues[MissingRecordings] = append(r.Issues[MissingRecordings], Issue{Path: path, EntryID: e.ID})
+				}
+			}
+		}
+	}
+
+	if vaultDir != "" {
+		notes, err := filepath.Glob(filepath.Join(vault.ExpandDir(vaultDir), "*.md"))
+		if err != nil {
+			return nil, fmt.Errorf("glob vault dir: %w", err)
+		}
+		for _, path := range notes {
+			doc, err := vault.ReadDocument(path)
+			if err != nil || !doc.HasTag("dictation") {
+				continue
+			}
+			r.Notes++
+			if !indexedNotes[path] {
+				r.Issues[UnindexedNotes] = append(r.Issues[UnindexedNotes], fileIssue(path))
+			}
+		}
+	}
+
+	if recordingsDir != "" {
+		dirEntries, err := os.ReadDir(recordingsDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read recordings dir: %w", err)
+		}
+		for _, d := range dirEntries {
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+				continue
+			}
+			r.Recordings++
+			if indexedRecordings[d.Name()] {
+				continue
+			}
+			issue := fileIssue(filepath.Join(recordingsDir, d.Name()))
+			if issue.ModTime != nil && time.Since(*issue.ModTime) < grace {
+				continue
+			}
+			r.Issues[OrphanRecordings] = append(r.Issues[OrphanRecordings], issue)
+		}
+	}
+
+	r.Clean = true
+	for _, issues := range r.Issues {
+		if len(issues) > 0 {
+			r.Clean = false
+		}
+	}
+	return r, nil
+}
+
+func fileIssue(path string) Issue {
+	issue := Issue{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		mod := info.ModTime().UTC()
+		issue.Size = info.Size()
+		issue.ModTime = &mod
+	}
+	return issue
+}
+
+// FixResult counts what Fix changed per action.
+type FixResult struct {
+	Fixed  map[string]int `json:"fixed"`
+	Errors []string       `json:"errors,omitempty"`
+}
+
+// Fix resolves the issues in r for the given actions (see FixActions).
+// Unknown actions are an error; nothing is changed in that case.
+func Fix(s *Store, r *Report, actions []string) (*FixResult, error) {
+	for _, a := range actions {
+		if _, ok := r.Issues[a]; !ok {
+			return nil, fmt.Errorf("unknown fix action %q (valid: %s)", a, strings.Join(FixActions, ", "))
+		}
+	}
+	res := &FixResult{Fixed: map[string]int{}}
+	fail := func(err error) { res.Errors = append(res.Errors, err.Error()) }
+
+	for _, a := range actions {
+		issues := r.Issues[a]
+		switch a {
+		case OrphanRecordings:
+			for _, is := range issues {
+				if err := os.Remove(is.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					fail(err)
+					continue
+				}
+				res.Fixed[a]++
+			}
+
+		case UnindexedNotes:
+			for _, is := range issues {
+				doc, err := vault.ReadDocument(is.Path)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				_, err = s.Add(Entry{
+					CreatedAt: doc.Time().UTC(),
+					VaultFile: is.Path,
+					Language:  doc.Get("language"),
+					Chars:     len([]rune(strings.TrimSpace(doc.Body))),
+					Words:     CountWords(doc.Body),
+				})
+				if err != nil {
+					fail(err)
+					continue
+				}
+				res.Fixed[a]++
+			}
+
+		case MissingNotes:
+			var remove []string
+			for _, is := range issues {
+				var empty bool
+				err := s.Update(is.EntryID, func(e *Entry) {
+					e.VaultFile = ""
+					empty = e.Recording == ""
+				})
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					fail(err)
+					continue
+				}
+				if empty {
+					remove = append(remove, is.EntryID)
+				}
+				res.Fixed[a]++
+			}
+			if err := s.Remove(remove...); err != nil {
+				fail(err)
+			}
+
+		case MissingRecordings:
+			var remove []string
+			for _, is := range issues {
+				var empty bool
+				err := s.Update(is.EntryID, func(e *Entry) {
+					e.Recording = ""
+					empty = e.VaultFile == ""
+				})
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					fail(err)
+					continue
+				}
+				if empty {
+					remove = append(remove, is.EntryID)
+				}
+				res.Fixed[a]++
+			}
+			if err := s.Remove(remove...); err != nil {
+				fail(err)
+			}
+		}
+	}
+	sort.Strings(res.Errors)
+	return res, nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const dictationNote = "---\ntitle: Dictation\ndate: 2026-02-20T12:00:00\nlanguage: en\ntags: [dictation, auto-generated]\n---\n\nHello.\n"
+
+// fixture builds a vault and recordings dir with one of each problem:
+//   - rec-orphan.webm: no entry
+//   - unindexed.md: dictation note with no entry
+//   - personal.md: user's own note (not ours — ignored)
+//   - entry "gone": vault file deleted, no recording
+//   - entry "norec": vault file present, recording deleted
+//   - entry "ok": both present
+func fixture(t *testing.T) (*Store, string, string) {
+	t.Helper()
+	root := t.TempDir()
+	vaultDir, recDir := filepath.Join(root, "vault"), filepath.Join(root, "recordings")
+	os.MkdirAll(vaultDir, 0755)
+	os.MkdirAll(recDir, 0755)
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(vaultDir, "ok.md"), dictationNote)
+	write(filepath.Join(vaultDir, "norec.md"), dictationNote)
+	write(filepath.Join(vaultDir, "unindexed.md"), dictationNote)
+	write(filepath.Join(vaultDir, "personal.md"), "# Shopping list\n")
+	write(filepath.Join(recDir, "ok.webm"), "audio")
+	write(filepath.Join(recDir, "rec-orphan.webm"), "audio")
+
+	s, _ := Open(filepath.Join(root, "index.json"))
+	s.Add(Entry{ID: "ok", VaultFile: filepath.Join(vaultDir, "ok.md"), Recording: "ok.webm"})
+	s.Add(Entry{ID: "gone", VaultFile: filepath.Join(vaultDir, "gone.md")})
+	s.Add(Entry{ID: "norec", VaultFile: filepath.Join(vaultDir, "norec.md"), Recording: "norec.webm"})
+	return s, vaultDir, recDir
+}
+
+// An index that can't be read must fail the check, not report every
+// recording as orphaned for a fix to delete.
+func TestCheckUnreadableIndex(t *testing.T) {
+	root := t.TempDir()
+	recDir := filepath.Join(root, "recordings")
+	os.MkdirAll(recDir, 0755)
+	rec := filepath.Join(recDir, "ok.webm")
+	os.WriteFile(rec, []byte("audio"), 0644)
+	s, err := OpenSQLite(filepath.Join(root, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(Entry{VaultFile: filepath.Join(root, "ok.md"), Recording: "ok.webm"})
+	s.db.db.Close()
+
+	r, err := Check(s, "", recDir, 0)
+	if err == nil {
+		t.Errorf("check of an unreadable index = %+v, want an error", r.Issues)
+		Fix(s, r, []string{OrphanRecordings})
+	}
+	if _, err := os.Stat(rec); err != nil {
+		t.Errorf("indexed recording removed: %v", err)
+	}
+}
+
+func TestCheckFindsEachKind(t *testing.T) {
+	s, vaultDir, recDir := fixture(t)
+	r, err := Check(s, vaultDir, recDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Clean {
+		t.Error("report should not be clean")
+	}
+	want := map[string]string{
+		OrphanRecordings:  "rec-orphan.webm",
+		UnindexedNotes:    "unindexed.md",
+		MissingNotes:      "gone.md",
+		MissingRecordings: "norec.webm",
+	}
+	for kind, name := range want {
+		issues := r.Issues[kind]
+		if len(issues) != 1 || filepath.Base(issues[0].Path) != name {
+			t.Errorf("%s = %+v, want just %s", kind, issues, name)
+		}
+	}
+	if r.Notes != 3 || r.Recordings != 2 || r.Entries != 3 {
+		t.Errorf("counts: notes=%d recordings=%d entries=%d", r.Notes, r.Recordings, r.Entries)
+	}
+}
+
+func TestCheckGraceSkipsFreshRecordings(t *testing.T) {
+	s, vaultDir, recDir := fixture(t)
+	r, _ := Check(s, vaultDir, recDir, time.Hour)
+	if n := len(r.Issues[OrphanRecordings]); n != 0 {
+		t.Errorf("fresh recording reported as orphan (%d)", n)
+	}
+}
+
+func TestFixResolvesEverything(t *testing.T) {
+	s, vaultDir, recDir := fixture(t)
+	r, _ := Check(s, vaultDir, recDir, 0)
+
+	res, err := Fix(s, r, FixActions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Errors) != 0 {
+		t.Errorf("fix errors: %v", res.Errors)
+	}
+	for _, a := range FixActions {
+		if res.Fixed[a] != 1 {
+			t.Errorf("fixed[%s] = %d, want 1", a, res.Fixed[a])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(recDir, "rec-orphan.webm")); !os.IsNotExist(err) {
+		t.Error("orphan recording should be deleted")
+	}
+
+	again, _ := Check(s, vaultDir, recDir, 0)
+	if !again.Clean {
+		t.Errorf("still inconsistent after fix: %+v", again.Issues)
+	}
+	if n, _ := s.Len(); n != 3 { // ok, norec (unlinked), unindexed (added); gone removed
+		t.Errorf("entries = %d, want 3", n)
+	}
+}
+
+func TestFixUnknownAction(t *testing.T) {
+	s, vaultDir, recDir := fixture(t)
+	r, _ := Check(s, vaultDir, recDir, 0)
+	if _, err := Fix(s, r, []string{"everything"}); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+	if _, err := os.Stat(filepath.Join(recDir, "rec-orphan.webm")); err != nil {
+		t.Error("nothing should change when an action is invalid")
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"math"
+	"strings"
+)
+
+// ReadingWPM is the reading speed behind Entry.ReadingSeconds: a common
+// estimate for adults reading silently.
+const ReadingWPM = 200
+
+// CountWords counts the words of a transcript: runs of non-space.
+func CountWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+// ReadingSeconds estimates how long words take to read at ReadingWPM,
+// rounded up to a whole second.
+func ReadingSeconds(words int) int {
+	return int(math.Ceil(float64(words) * 60 / ReadingWPM))
+}
+
+// SpeechRate is words per minute of speech, to one decimal; 0 without a
+// duration.
+func SpeechRate(words int, duration float64) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return math.Round(float64(words)/(duration/60)*10) / 10
+}
+
+// fillMetrics derives the reading time and speech rate from the word count
+// and duration. Add and Update call it, so they follow both.
+func (e *Entry) fillMetrics() {
+	e.ReadingSeconds = ReadingSeconds(e.Words)
+	e.WPM = SpeechRate(e.Words, e.Duration)
+}
+
+// SetWords records the word counts of many entries in one write, for
+// counting the words of entries indexed before words were counted. Unknown
+// IDs are ignored.
+func (s *Store) SetWords(words map[string]int) error {
+	if s.db != nil {
+		return s.db.setWords(words)
+	}
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prev := append([]Entry(nil), s.entries...)
+	changed := false
+	for i := range s.entries {
+		if n, ok := words[s.entries[i].ID]; ok && n != s.entries[i].Words {
+			s.entries[i].Words = n
+			s.entries[i].fillMetrics()
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := s.saveLocked(); err != nil {
+		s.entries = prev
+		return err
+	}
+	return nil
+}
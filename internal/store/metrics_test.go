@@ -0,0 +1,69 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	if n := CountWords("  We ship\non   Friday.\t"); n != 4 {
+		t.Errorf("CountWords = %d, want 4", n)
+	}
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{VaultFile: "/v/a.md", Words: 450})
+	if e.ReadingSeconds != 135 || e.WPM != 0 {
+		t.Errorf("added: reading %d s, %v wpm; want 135 s, no rate", e.ReadingSeconds, e.WPM)
+	}
+	if err := s.SetSegments(e.ID, []Segment{{Start: 0, End: 180, Text: "…"}}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ = s.Get(e.ID); e.WPM != 150 {
+		t.Errorf("with segments: %v wpm, want 150", e.WPM)
+	}
+	if err := s.SetWords(map[string]int{e.ID: 90, "unknown": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ = s.Get(e.ID); e.Words != 90 || e.ReadingSeconds != 27 || e.WPM != 30 {
+		t.Errorf("after SetWords: %+v", e)
+	}
+}
+
+func TestPageSorted(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, words := range []int{30, 10, 50, 10, 40} {
+		s.Add(Entry{VaultFile: filepath.Join("/v", string(rune('a'+i))+".md"), CreatedAt: base.Add(time.Duration(i) * time.Minute), Words: words})
+	}
+	pageAll := func(order Order) string {
+		var files []string
+		cur := ""
+		for {
+			page, next, err := s.PageSorted(cur, 2, order, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range page {
+				files = append(files, strings.TrimSuffix(filepath.Base(e.VaultFile), ".md"))
+			}
+			if next == "" {
+				return strings.Join(files, ",")
+			}
+			cur = next
+		}
+	}
+	// Equal counts (b and d) go newest first either way.
+	if got := pageAll(Order{Key: SortWords}); got != "c,e,a,d,b" {
+		t.Errorf("most words first = %s", got)
+	}
+	if got := pageAll(Order{Key: SortWords, Asc: true}); got != "d,b,a,e,c" {
+		t.Errorf("fewest words first = %s", got)
+	}
+	if got := pageAll(Order{Asc: true}); got != "a,b,c,d,e" {
+		t.Errorf("oldest first = %s", got)
+	}
+	if ValidSortKey("chars") {
+		t.Error("chars accepted as a sort key")
+	}
+}
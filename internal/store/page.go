@@ -0,0 +1,214 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes for Page.
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// ErrBadCursor is returned for a cursor Page did not issue.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// WHY a cursor instead of an offset? New notes are added at the top of the
+// list while the UI pages down it; an offset would then repeat entries. A
+// cursor names the last entry seen (its time and ID), so the next page
+// starts right after it however the index has changed since.
+// Sorted by something other than time, it also holds the entry's value of
+// the sort key.
+type cursor struct {
+	created time.Time
+	id      string
+	key     float64
+	keyed   bool
+}
+
+func (c cursor) String() string {
+	raw := strconv.FormatInt(c.created.UnixNano(), 10) + "." + c.id
+	if c.keyed {
+		raw = strconv.FormatFloat(c.key, 'g', -1, 64) + ":" + raw
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(s string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrBadCursor
+	}
+	var c cursor
+	rest := string(raw)
+	if k, r, ok := strings.Cut(rest, ":"); ok {
+		if c.key, err = strconv.ParseFloat(k, 64); err != nil {
+			return cursor{}, ErrBadCursor
+		}
+		c.keyed, rest = true, r
+	}
+	nanos, id, ok := strings.Cut(rest, ".")
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil || id == "" {
+		return cursor{}, ErrBadCursor
+	}
+	c.created, c.id = time.Unix(0, n).UTC(), id
+	return c, nil
+}
+
+// newer reports whether a sorts before b in page order: newest first, ties
+// broken by ID so the order is total.
+func newer(a, b cursor) bool {
+	if !a.created.Equal(b.created) {
+		return a.created.After(b.created)
+	}
+	return a.id > b.id
+}
+
+// Sort keys of an Order.
+const (
+	SortCreated     = "created"
+	SortWords       = "words"
+	SortReadingTime = "reading_time"
+	SortWPM         = "wpm"
+)
+
+// ValidSortKey reports whether key is "" or a sort key.
+func ValidSortKey(key string) bool {
+	switch key {
+	case "", SortCreated, SortWords, SortReadingTime, SortWPM:
+		return true
+	}
+	return false
+}
+
+// Order is the order of PageSorted: by Key, highest (or newest) first
+// unless Asc. Entries with equal keys go newest first. The zero Order is
+// Page's: newest first.
+type Order struct {
+	Key string // a sort key; "" = SortCreated
+	Asc bool
+}
+
+func (o Order) keyed() bool { return o.Key != "" && o.Key != SortCreated }
+
+func (o Order) value(e Entry) float64 {
+	switch o.Key {
+	case SortWords:
+		return float64(e.Words)
+	case SortReadingTime:
+		return float64(e.ReadingSeconds)
+	case SortWPM:
+		return e.WPM
+	}
+	return 0
+}
+
+func (o Order) cursorOf(e Entry) cursor {
+	return cursor{created: e.CreatedAt, id: e.ID, key: o.value(e), keyed: o.keyed()}
+}
+
+// before reports whether a sorts before b in page order.
+func (o Order) before(a, b cursor) bool {
+	if o.keyed() {
+		if a.key != b.key {
+			return (a.key < b.key) == o.Asc
+		}
+		return newer(a, b)
+	}
+	if o.Asc {
+		return newer(b, a)
+	}
+	return newer(a, b)
+}
+
+// Filter selects entries by their metadata. Zero fields match anything.
+type Filter struct {
+	From, To           time.Time // CreatedAt in [From, To)
+	Language           string
+	Model              string
+	Source             string
+	MinWords, MaxWords int     // Words in [MinWords, MaxWords]
+	MinWPM, MaxWPM     float64 // WPM in [MinWPM, MaxWPM]; entries without a speech rate fail either bound
+}
+
+// Match reports whether e passes the filter. Strings compare without case.
+func (f Filter) Match(e Entry) bool {
+	if (!f.From.IsZero() && e.CreatedAt.Before(f.From)) || (!f.To.IsZero() && !e.CreatedAt.Before(f.To)) {
+		return false
+	}
+	if (f.MinWords > 0 && e.Words < f.MinWords) || (f.MaxWords > 0 && e.Words > f.MaxWords) {
+		return false
+	}
+	if (f.MinWPM > 0 || f.MaxWPM > 0) && (e.WPM == 0 || e.WPM < f.MinWPM || (f.MaxWPM > 0 && e.WPM > f.MaxWPM)) {
+		return false
+	}
+	return matchField(f.Language, e.Language) && matchField(f.Model, e.Model) && matchField(f.Source, e.Source)
+}
+
+func matchField(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// Page returns up to limit entries, newest first, starting after the
+// entry named by cursor ("" for the first page), and the cursor for the
+// next page ("" after the last). Entries keep rejects are skipped; keep may
+// be nil, and is called without the store locked, so it may read files.
+func (s *Store) Page(cur string, limit int, keep func(Entry) bool) ([]Entry, string, error) {
+	return s.PageSorted(cur, limit, Order{}, keep)
+}
+
+// PageSorted is Page in the given order. A cursor belongs to the order of
+// the page that issued it.
+func (s *Store) PageSorted(cur string, limit int, order Order, keep func(Entry) bool) ([]Entry, string, error) {
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	limit = min(limit, MaxPageLimit)
+	if s.db != nil {
+		var after *cursor
+		if cur != "" {
+			c, err := parseCursor(cur)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: %q", ErrBadCursor, cur)
+			}
+			after = &c
+		}
+		return s.db.page(after, limit, order, keep)
+	}
+
+	s.lockRead()
+	all := append([]Entry(nil), s.entries...)
+	s.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return order.before(order.cursorOf(all[i]), order.cursorOf(all[j])) })
+
+	start := 0
+	if cur != "" {
+		after, err := parseCursor(cur)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q", ErrBadCursor, cur)
+		}
+		start = sort.Search(len(all), func(i int) bool { return order.before(after, order.cursorOf(all[i])) })
+	}
+
+	page := []Entry{}
+	for i := start; i < len(all); i++ {
+		if keep != nil && !keep(all[i]) {
+			continue
+		}
+		page = append(page, all[i])
+		if len(page) == limit {
+			if i+1 < len(all) {
+				return page, order.cursorOf(all[i]).String(), nil
+			}
+			break
+		}
+	}
+	return page, "", nil
+}
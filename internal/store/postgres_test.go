@@ -0,0 +1,108 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPostgresStore runs against the database named by
+// CAPTAINSLOG_TEST_POSTGRES_URL, which it empties; without one it is
+// skipped. The queries are the SQLite store's (see TestSQLitePagesLikeJSON).
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("CAPTAINSLOG_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("CAPTAINSLOG_TEST_POSTGRES_URL not set")
+	}
+	dir := t.TempDir()
+	a, err := OpenPostgres(url, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Remove(idsOf(all)...)
+	b, err := OpenPostgres(url, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := a.Add(Entry{VaultFile: "/v/a.md", Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Add(Entry{VaultFile: "/v/b.md", CreatedAt: e.CreatedAt.Add(time.Minute)})
+	if n, err := b.Len(); n != 2 {
+		t.Fatalf("other instance sees %d entries (%v), want 2", n, err)
+	}
+	if err := b.Update(e.ID, func(e *Entry) { e.Language = "de" }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := a.Get(e.ID); got.Language != "de" || !got.CreatedAt.Equal(e.CreatedAt) {
+		t.Errorf("after update = %+v", got)
+	}
+	page, next, err := b.Page("", 1, nil)
+	if err != nil || len(page) != 1 || page[0].VaultFile != "/v/b.md" || next == "" {
+		t.Fatalf("first page = %+v, next %q, err %v", page, next, err)
+	}
+	if page, _, _ = b.Page(next, 1, nil); len(page) != 1 || page[0].ID != e.ID {
+		t.Errorf("second page = %+v", page)
+	}
+	if err := a.Remove(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get(e.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("entry removed by the other instance still there")
+	}
+}
+
+func idsOf(entries []Entry) []string {
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestParsePostgresURL(t *testing.T) {
+	// Without an sslmode, the server's certificate is checked against its
+	// name, and there is no falling back to plain text.
+	cfg, err := parsePostgresURL("postgres://captain:pw@db.example:6543/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "db.example" || cfg.Port != 6543 || cfg.User != "captain" || cfg.Password != "pw" || cfg.Database != "log" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if cfg.TLSConfig == nil || cfg.TLSConfig.InsecureSkipVerify || cfg.TLSConfig.ServerName != "db.example" || len(cfg.Fallbacks) != 0 {
+		t.Errorf("default TLS = %+v, fallbacks %d", cfg.TLSConfig, len(cfg.Fallbacks))
+	}
+
+	cfg, err = parsePostgresURL("postgresql://captain@/log?host=/run/postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "/run/postgresql" || cfg.TLSConfig != nil {
+		t.Errorf("socket cfg = %+v", cfg)
+	}
+
+	for _, bad := range []string{
+		"mysql://u@h/db",
+		"postgres://h/db",
+		"postgres://u@h/db?sslmode=prefer",
+		"postgres://u@h/db?sslmode=require",
+		"postgres://u@h/db?sslmode=allow",
+	} {
+		if _, err := parsePostgresURL(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+	// The error of a malformed URL doesn't repeat the password.
+	if _, err := parsePostgresURL("postgres://u:s3cret@h:port/db"); err == nil || strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("malformed URL err = %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Segment is one timed span of a transcript, as returned by Whisper's
+// verbose_json.
+type Segment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+	Speaker string  `json:"speaker,omitempty"`
+}
+
+// Page sizes for Segments.
+const (
+	DefaultSegmentLimit = 100
+	MaxSegmentLimit     = 1000
+)
+
+// SegmentQuery selects a slice of a transcript's segments. From and To are
+// seconds; a segment matches if it overlaps [From, To). To <= 0 means "to
+// the end". Offset and Limit page through the matches.
+type SegmentQuery struct {
+	From, To      float64
+	Offset, Limit int
+}
+
+// SegmentPage is one page of segments.
+type SegmentPage struct {
+	ID         string    `json:"id"`
+	Total      int       `json:"total"` // segments matching From/To, across all pages
+	Offset     int       `json:"offset"`
+	Segments   []Segment `json:"segments"`
+	NextOffset *int      `json:"next_offset,omitempty"` // nil on the last page
+}
+
+// WHY a file per transcript? Segments are most of a long transcript's
+// size. Keeping them out of index.json keeps List (and the history view
+// built on it) fast, and a page request reads one small file.
+func (s *Store) segmentsPath(id string) string {
+	return filepath.Join(s.dir, "segments", id+".json")
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id string) (Entry, error) {
+	if s.db != nil {
+		return s.db.get(id)
+	}
+	s.lockRead()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// SetSegments stores the segments of an entry, replacing any previous
+// ones, and records their count and the transcript duration on the entry.
+func (s *Store) SetSegments(id string, segs []Segment) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	segs = append([]Segment(nil), segs...)
+	sort.SliceStable(segs, func(i, j int) bool { return segs[i].Start < segs[j].Start })
+	var duration float64
+	for _, seg := range segs {
+		duration = max(duration, seg.End)
+	}
+
+	data, err := json.Marshal(segs)
+	if err != nil {
+		return err
+	}
+	path := s.segmentsPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create segments dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write segments: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace segments: %w", err)
+	}
+	// The entry may have been archived; its old segments are stale now.
+	os.Remove(path + archiveExt)
+	return s.Update(id, func(e *Entry) {
+		e.Segments = len(segs)
+		e.Duration = duration
+	})
+}
+
+// Segments returns one page of an entry's segments. An entry without
+// stored segments yields an empty page.
+func (s *Store) Segments(id string, q SegmentQuery) (SegmentPage, error) {
+	if _, err := s.Get(id); err != nil {
+		return SegmentPage{}, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSegmentLimit
+	}
+	q.Limit = min(q.Limit, MaxSegmentLimit)
+	q.Offset = max(q.Offset, 0)
+
+	page := SegmentPage{ID: id, Offset: q.Offset, Segments: []Segment{}}
+	all, err := s.readSegments(id)
+	if err != nil {
+		return SegmentPage{}, err
+	}
+
+	var matched []Segment
+	for _, seg := range all {
+		if seg.End > q.From && (q.To <= 0 || seg.Start < q.To) {
+			matched = append(matched, seg)
+		}
+	}
+	page.Total = len(matched)
+	if q.Offset < len(matched) {
+		end := min(q.Offset+q.Limit, len(matched))
+		page.Segments = matched[q.Offset:end]
+		if end < len(matched) {
+			page.NextOffset = &end
+		}
+	}
+	return page, nil
+}
+
+// AllSegments returns every segment of an entry in start order, for
+// consumers that need the whole transcript at once (exports). An entry
+// without stored segments yields none.
+func (s *Store) AllSegments(id string) ([]Segment, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return s.readSegments(id)
+}
+
+func (s *Store) readSegments(id string) ([]Segment, error) {
+	data, err := readSegmentFile(s.segmentsPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read segments: %w", err)
+	}
+	var all []Segment
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("parse segments: %w", err)
+	}
+	return all, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hourLong(n int) []Segment {
+	segs := make([]Segment, n)
+	for i := range segs {
+		// Deliberately out of order: SetSegments sorts by start.
+		j := n - 1 - i
+		segs[i] = Segment{Start: float64(j * 10), End: float64(j*10 + 10), Text: fmt.Sprintf("seg %d", j)}
+	}
+	return segs
+}
+
+func TestSetSegmentsUpdatesEntry(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{VaultFile: "/v/a.md"})
+	if err := s.SetSegments(e.ID, hourLong(360)); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.Get(e.ID)
+	if got.Segments != 360 || got.Duration != 3600 {
+		t.Errorf("entry = %+v", got)
+	}
+	if err := s.SetSegments("nope", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown id = %v", err)
+	}
+}
+
+func TestSegmentsPaging(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{})
+	s.SetSegments(e.ID, hourLong(250))
+
+	page, err := s.Segments(e.ID, SegmentQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 250 || len(page.Segments) != DefaultSegmentLimit || page.NextOffset == nil || *page.NextOffset != 100 {
+		t.Fatalf("first page: total=%d len=%d next=%v", page.Total, len(page.Segments), page.NextOffset)
+	}
+	if page.Segments[0].Text != "seg 0" {
+		t.Errorf("segments not sorted: first = %q", page.Segments[0].Text)
+	}
+
+	last, _ := s.Segments(e.ID, SegmentQuery{Offset: 200, Limit: 100})
+	if len(last.Segments) != 50 || last.NextOffset != nil {
+		t.Errorf("last page: len=%d next=%v", len(last.Segments), last.NextOffset)
+	}
+	past, _ := s.Segments(e.ID, SegmentQuery{Offset: 999})
+	if len(past.Segments) != 0 || past.Segments == nil {
+		t.Errorf("past the end should be an empty, non-nil page: %+v", past)
+	}
+}
+
+func TestSegmentsTimeRange(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{})
+	s.SetSegments(e.ID, hourLong(360))
+
+	// 95s..125s overlaps segments starting at 90, 100, 110, 120.
+	page, _ := s.Segments(e.ID, SegmentQuery{From: 95, To: 125})
+	if page.Total != 4 || page.Segments[0].Start != 90 || page.Segments[3].Start != 120 {
+		t.Errorf("range page = %+v", page)
+	}
+	tail, _ := s.Segments(e.ID, SegmentQuery{From: 3590})
+	if tail.Total != 1 {
+		t.Errorf("open-ended range total = %d", tail.Total)
+	}
+}
+
+func TestSegmentsWithoutData(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{})
+	page, err := s.Segments(e.ID, SegmentQuery{})
+	if err != nil || page.Total != 0 || page.Segments == nil {
+		t.Errorf("page = %+v, err = %v", page, err)
+	}
+	if _, err := s.Segments("nope", SegmentQuery{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown id = %v", err)
+	}
+}
+
+func TestRemoveDeletesSegments(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{})
+	s.SetSegments(e.ID, hourLong(3))
+	s.Remove(e.ID)
+	if _, err := os.Stat(s.segmentsPath(e.ID)); !os.IsNotExist(err) {
+		t.Error("segment file should be removed with its entry")
+	}
+}
+
+func TestTranslation(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{VaultFile: "/v/a.md"})
+	if got, err := s.Translation(e.ID); err != nil || len(got.Sentences) != 0 {
+		t.Fatalf("before = %+v, %v", got, err)
+	}
+	want := Translation{Language: "English", Sentences: []SentencePair{{"Hola.", "Hello."}}}
+	if err := s.SetTranslation(e.ID, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Translation(e.ID); got.Language != "English" || len(got.Sentences) != 1 || got.Sentences[0] != want.Sentences[0] {
+		t.Errorf("after = %+v", got)
+	}
+	s.Remove(e.ID)
+	if _, err := os.Stat(s.translationPath(e.ID)); !os.IsNotExist(err) {
+		t.Error("translation should be removed with its entry")
+	}
+}
@@ -0,0 +1,338 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// sqlTimeout bounds each call to the database, a wait for another
+// writer's lock included.
+const sqlTimeout = 10 * time.Second
+
+// sqlDialect is what differs between the databases an sqlIndex runs on.
+type sqlDialect struct {
+	// schema creates the entries table and its indexes if missing.
+	schema string
+	// forUpdate is appended to a SELECT to lock the rows it reads until
+	// the transaction ends; "" where a write transaction locks anyway.
+	forUpdate string
+	// time converts a time to the created_at column's type.
+	time func(time.Time) any
+}
+
+// sqlIndex keeps the entries in a table of an SQL database, one row per
+// entry: the entry as JSON beside the columns it is looked up and sorted
+// by. Unlike the JSON file, nothing is held in memory: every call queries
+// the table, and pages are read a page at a time.
+type sqlIndex struct {
+	db *sql.DB
+	d  sqlDialect
+}
+
+// openSQL creates the table if needed and returns a Store keeping its
+// entries in db. Segment files still go in dir.
+func openSQL(db *sql.DB, d sqlDialect, dir string) (*Store, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, d.schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create index tables: %w", err)
+	}
+	return &Store{dir: dir, db: &sqlIndex{db: db, d: d}}, nil
+}
+
+// sortColumns are the columns behind the sort keys of an Order.
+var sortColumns = map[string]string{
+	SortWords:       "words",
+	SortReadingTime: "reading_seconds",
+	SortWPM:         "wpm",
+}
+
+// querier is a *sql.DB or a *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// query returns the entries of the rows selected by query, whose only
+// column is entry. They are all read before returning, so the caller may
+// query again, even with the pool down to one connection.
+func (x *sqlIndex) query(ctx context.Context, q querier, query string, args ...any) ([]Entry, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("read index: %w", err)
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("parse index entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	return entries, nil
+}
+
+// put inserts e, or replaces the entry with its ID.
+func (x *sqlIndex) put(ctx context.Context, q querier, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `INSERT INTO captainslog_entries (id, created_at, recording, words, reading_seconds, wpm, entry)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET created_at = excluded.created_at, recording = excluded.recording,
+			words = excluded.words, reading_seconds = excluded.reading_seconds, wpm = excluded.wpm, entry = excluded.entry`,
+		e.ID, x.d.time(e.CreatedAt), e.Recording, e.Words, e.ReadingSeconds, e.WPM, string(data))
+	if err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	return nil
+}
+
+// tx runs fn in a transaction, committed if fn returns nil.
+func (x *sqlIndex) tx(fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	if err := fn(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	return nil
+}
+
+// modify applies fn to the entries selected by query, each of which fn
+// keeps (and has saved) or drops (and has deleted), in one transaction.
+// It returns the IDs of those dropped.
+func (x *sqlIndex) modify(query string, args []any, fn func(*Entry) (keep bool)) ([]string, error) {
+	var dropped []string
+	err := x.tx(func(ctx context.Context, tx *sql.Tx) error {
+		dropped = nil
+		entries, err := x.query(ctx, tx, query+x.d.forUpdate, args...)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			id := e.ID
+			if !fn(&e) {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM captainslog_entries WHERE id = $1`, id); err != nil {
+					return fmt.Errorf("write index: %w", err)
+				}
+				dropped = append(dropped, id)
+				continue
+			}
+			e.ID = id
+			e.fillMetrics()
+			if err := x.put(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+func (x *sqlIndex) add(e Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	return x.put(ctx, x.db, e)
+}
+
+func (x *sqlIndex) update(id string, fn func(*Entry)) error {
+	found := false
+	_, err := x.modify(`SELECT entry FROM captainslog_entries WHERE id = $1`, []any{id}, func(e *Entry) bool {
+		found = true
+		fn(e)
+		return true
+	})
+	if err == nil && !found {
+		err = ErrNotFound
+	}
+	return err
+}
+
+func (x *sqlIndex) remove(ids []string) error {
+	return x.tx(func(ctx context.Context, tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM captainslog_entries WHERE id = $1`, id); err != nil {
+				return fmt.Errorf("write index: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// unlinkRecordings is Store.UnlinkRecordings, returning the IDs of the
+// entries removed.
+func (x *sqlIndex) unlinkRecordings(names []string) ([]string, error) {
+	var dropped []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		ids, err := x.modify(`SELECT entry FROM captainslog_entries WHERE recording = $1`, []any{name}, func(e *Entry) bool {
+			e.Recording = ""
+			return e.VaultFile != ""
+		})
+		dropped = append(dropped, ids...)
+		if err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+func (x *sqlIndex) setWords(words map[string]int) error {
+	return x.tx(func(ctx context.Context, tx *sql.Tx) error {
+		for id, n := range words {
+			entries, err := x.query(ctx, tx, `SELECT entry FROM captainslog_entries WHERE id = $1`+x.d.forUpdate, id)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 || entries[0].Words == n {
+				continue
+			}
+			e := entries[0]
+			e.Words = n
+			e.fillMetrics()
+			if err := x.put(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (x *sqlIndex) get(id string) (Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	entries, err := x.query(ctx, x.db, `SELECT entry FROM captainslog_entries WHERE id = $1`, id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entries) == 0 {
+		return Entry{}, ErrNotFound
+	}
+	return entries[0], nil
+}
+
+func (x *sqlIndex) count() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	var n int
+	if err := x.db.QueryRowContext(ctx, `SELECT count(*) FROM captainslog_entries`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("read index: %w", err)
+	}
+	return n, nil
+}
+
+// list returns the entries created in [from, to), newest first; zero
+// times leave that end open.
+func (x *sqlIndex) list(from, to time.Time) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	query := `SELECT entry FROM captainslog_entries`
+	var args []any
+	if !from.IsZero() || !to.IsZero() {
+		if from.IsZero() {
+			from = time.Unix(0, 0)
+		}
+		if to.IsZero() {
+			to = time.Now().AddDate(100, 0, 0)
+		}
+		query += ` WHERE created_at >= $1 AND created_at < $2`
+		args = []any{x.d.time(from), x.d.time(to)}
+	}
+	return x.query(ctx, x.db, query+` ORDER BY created_at DESC, id DESC`, args...)
+}
+
+// page is Store.PageSorted. Rows are read limit+1 at a time, the extra one
+// telling whether another page follows, and again while keep rejects some
+// of them.
+func (x *sqlIndex) page(after *cursor, limit int, order Order, keep func(Entry) bool) ([]Entry, string, error) {
+	page := []Entry{}
+	for {
+		rows, err := x.pageAfter(after, limit+1, order)
+		if err != nil {
+			return nil, "", err
+		}
+		for i, e := range rows {
+			c := order.cursorOf(e)
+			after = &c
+			if keep != nil && !keep(e) {
+				continue
+			}
+			page = append(page, e)
+			if len(page) < limit {
+				continue
+			}
+			more := i+1 < len(rows)
+			if !more && len(rows) > limit {
+				next, err := x.pageAfter(after, 1, order)
+				if err != nil {
+					return nil, "", err
+				}
+				more = len(next) > 0
+			}
+			if more {
+				return page, c.String(), nil
+			}
+			return page, "", nil
+		}
+		if len(rows) <= limit {
+			return page, "", nil
+		}
+	}
+}
+
+// pageAfter returns up to n entries in page order from just after the
+// entry named by after (nil for the start).
+func (x *sqlIndex) pageAfter(after *cursor, n int, order Order) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	query := `SELECT entry FROM captainslog_entries`
+	var args []any
+	if order.keyed() {
+		// Highest (or lowest) key first, ties newest first.
+		col, dir, past := sortColumns[order.Key], "DESC", "<"
+		if order.Asc {
+			dir, past = "ASC", ">"
+		}
+		if after != nil {
+			query += fmt.Sprintf(` WHERE %[1]s %[2]s $1 OR (%[1]s = $1 AND (created_at < $2 OR (created_at = $2 AND id < $3)))`, col, past)
+			args = []any{after.key, x.d.time(after.created), after.id}
+		}
+		query += fmt.Sprintf(` ORDER BY %s %s, created_at DESC, id DESC`, col, dir)
+	} else {
+		dir, past := "DESC", "<"
+		if order.Asc {
+			dir, past = "ASC", ">"
+		}
+		if after != nil {
+			query += fmt.Sprintf(` WHERE created_at %[1]s $1 OR (created_at = $1 AND id %[1]s $2)`, past)
+			args = []any{x.d.time(after.created), after.id}
+		}
+		query += fmt.Sprintf(` ORDER BY created_at %[1]s, id %[1]s`, dir)
+	}
+	args = append(args, n)
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	return x.query(ctx, x.db, query, args...)
+}
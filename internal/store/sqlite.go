@@ -0,0 +1,56 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// sqliteSchema creates the index table. created_at is in Unix nanoseconds,
+// which sort the way the times do.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS captainslog_entries (
+	id              TEXT PRIMARY KEY,
+	created_at      INTEGER NOT NULL,
+	recording       TEXT NOT NULL DEFAULT '',
+	words           INTEGER NOT NULL DEFAULT 0,
+	reading_seconds INTEGER NOT NULL DEFAULT 0,
+	wpm             REAL NOT NULL DEFAULT 0,
+	entry           TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS captainslog_entries_created ON captainslog_entries (created_at, id);
+CREATE INDEX IF NOT EXISTS captainslog_entries_recording ON captainslog_entries (recording);`
+
+var sqliteDialect = sqlDialect{
+	schema: sqliteSchema,
+	time:   func(t time.Time) any { return t.UnixNano() },
+}
+
+// OpenSQLite opens the index in the SQLite database at path, creating it
+// on first use. Segment files go in its directory.
+//
+// The database is for this instance alone: SQLite's locking is not to be
+// trusted on a network filesystem, so a shared index stays a JSON file
+// (see OpenShared).
+func OpenSQLite(path string) (*Store, error) {
+	// WAL lets reads go on during a write; the busy timeout waits out a
+	// write of another process (a backup, say) rather than failing.
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open index: %w", err)
+	}
+	// One connection: SQLite takes one writer at a time anyway, and a
+	// second connection would fail with SQLITE_BUSY instead of waiting.
+	db.SetMaxOpenConns(1)
+	s, err := openSQL(db, sqliteDialect, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return s, nil
+}
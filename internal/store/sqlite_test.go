@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.Add(Entry{VaultFile: "/v/a.md", Recording: "a.webm", Language: "en", Words: 450})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := s.Add(Entry{Recording: "b.webm", CreatedAt: a.CreatedAt.Add(time.Minute)})
+
+	reopened, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := reopened.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != b.ID || !list[1].CreatedAt.Equal(a.CreatedAt) || list[1].ReadingSeconds != 135 {
+		t.Errorf("list = %+v, want newest first", list)
+	}
+
+	if err := s.Update(a.ID, func(e *Entry) { e.Language = "de"; e.ID = "hijack" }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(a.ID); got.Language != "de" || got.Recording != "a.webm" {
+		t.Errorf("after update = %+v", got)
+	}
+	if err := s.Update("nope", func(*Entry) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	if err := s.SetWords(map[string]int{a.ID: 90, "unknown": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(a.ID); got.Words != 90 || got.ReadingSeconds != 27 {
+		t.Errorf("after SetWords = %+v", got)
+	}
+
+	// The recording-only entry goes with its recording; the other keeps
+	// its note.
+	if err := s.UnlinkRecordings("a.webm", "b.webm"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(a.ID); got.Recording != "" || got.VaultFile != "/v/a.md" {
+		t.Errorf("entry with note = %+v", got)
+	}
+	if _, err := s.Get(b.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("entry left with nothing was kept")
+	}
+	if err := s.Remove(a.ID, "unknown"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Len(); n != 0 {
+		t.Errorf("len = %d after remove", n)
+	}
+}
+
+// The database pages, sorts and filters as the in-memory index does.
+func TestSQLitePagesLikeJSON(t *testing.T) {
+	dir := t.TempDir()
+	mem, _ := Open(filepath.Join(dir, "index.json"))
+	db, err := OpenSQLite(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 23; i++ {
+		// Repeated times and word counts exercise the tie-breaks.
+		e := Entry{
+			ID:        fmt.Sprintf("e%02d", i),
+			VaultFile: fmt.Sprintf("/v/%d.md", i),
+			CreatedAt: base.Add(time.Duration(i/2) * time.Minute),
+			Words:     i * 7 % 5 * 10,
+			Duration:  float64(i%3) * 20,
+		}
+		mem.Add(e)
+		db.Add(e)
+	}
+	pageAll := func(s *Store, order Order, keep func(Entry) bool) []string {
+		var ids []string
+		cur := ""
+		for {
+			page, next, err := s.PageSorted(cur, 3, order, keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range page {
+				ids = append(ids, e.ID)
+			}
+			if next == "" {
+				return ids
+			}
+			cur = next
+		}
+	}
+	odd := func(e Entry) bool { return e.ID[2]%2 == 1 }
+	for _, order := range []Order{{}, {Asc: true}, {Key: SortWords}, {Key: SortWords, Asc: true}, {Key: SortWPM}, {Key: SortReadingTime, Asc: true}} {
+		for _, keep := range []func(Entry) bool{nil, odd} {
+			want, got := pageAll(mem, order, keep), pageAll(db, order, keep)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%+v (filtered %v):\n got %v\nwant %v", order, keep != nil, got, want)
+			}
+		}
+	}
+
+	from := base.Add(2 * time.Minute)
+	want := mem.Calendar(from, from.Add(5*time.Minute), time.UTC, odd)
+	if got := db.Calendar(from, from.Add(5*time.Minute), time.UTC, odd); !reflect.DeepEqual(got, want) {
+		t.Errorf("calendar = %+v, want %+v", got, want)
+	}
+}
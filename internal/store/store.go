@@ -0,0 +1,391 @@
+// Package store keeps the transcript index: one record per saved
+// transcription, linking the vault note to the audio recording it came from.
+//
+// The vault stays the source of truth for text — the index only holds
+// pointers and metadata, so it can always be rebuilt from the vault (see
+// Check/Fix). It lives in an SQLite database (see OpenSQLite), so history
+// pages are queries rather than a read of the whole index. Instances
+// sharing a filesystem share a JSON file instead, rewritten atomically on
+// each change (see OpenShared), and those who run PostgreSQL anyway, for
+// its backups and replication, can keep it in a table there (see
+// OpenPostgres).
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/cluster"
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// Entry is one indexed transcription.
+type Entry struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	VaultFile string    `json:"vault_file,omitempty"` // absolute path
+	Recording string    `json:"recording,omitempty"`  // file name in the recordings dir
+	Language  string    `json:"language,omitempty"`
+	Chars     int       `json:"chars,omitempty"`
+	Words     int       `json:"words,omitempty"`
+	Segments  int       `json:"segments,omitempty"`   // count; the segments themselves are fetched by page
+	Duration  float64   `json:"duration,omitempty"`   // seconds, end of the last segment
+	Model     string    `json:"model,omitempty"`      // Whisper model that transcribed it
+	Source    string    `json:"source,omitempty"`     // where it came from: SourceAPI, SourceWatcher, or a client's own name
+	DailyNote bool      `json:"daily_note,omitempty"` // VaultFile is a daily note the transcription was appended to
+	Keywords  []string  `json:"keywords,omitempty"`   // watch keywords the text mentions (see internal/keywords)
+	// Derived from Words and Duration whenever either changes
+	ReadingSeconds int     `json:"reading_seconds,omitempty"` // reading time at ReadingWPM
+	WPM            float64 `json:"wpm,omitempty"`             // speech rate: Words per minute of Duration
+}
+
+// Sources the server sets itself; clients of /api/vault/save may name
+// their own ("microphone", "upload").
+const (
+	SourceAPI     = "api"     // /api/vault/save without a source
+	SourceWatcher = "watcher" // the folder watcher
+	SourceMic     = "mic"     // the server's own microphone (/api/mic)
+)
+
+// Store is the transcript index. Safe for concurrent use.
+type Store struct {
+	dir    string       // for segment files
+	b      *fileBackend // where the entries are kept, mirrored in entries
+	db     *sqlIndex    // or, if set, the database they are queried from instead
+	shared bool         // other instances write the same index (see OpenShared)
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// fileBackend is the index as one JSON file, rewritten atomically on each
+// change. The Store holds s.mu around every call.
+type fileBackend struct {
+	path string
+	// The file as last read or written, so a shared index notices another
+	// instance's writes.
+	modTime time.Time
+	size    int64
+}
+
+// Lock file timings for a shared index. A write takes milliseconds; a
+// lock older than lockStale was left by an instance that died holding it.
+const (
+	lockWait  = 5 * time.Second
+	lockStale = 30 * time.Second
+)
+
+// ErrNotFound is returned for an unknown entry ID.
+var ErrNotFound = errors.New("entry not found")
+
+// Open loads the index at path, starting empty if the file doesn't exist.
+func Open(path string) (*Store, error) {
+	return open(filepath.Dir(path), &fileBackend{path: path}, false)
+}
+
+// OpenShared is Open for an index that other instances write too, on a
+// shared filesystem (see internal/cluster). Writes hold a lock file next
+// to the index, and every read or write first picks up what the others
+// changed.
+func OpenShared(path string) (*Store, error) {
+	return open(filepath.Dir(path), &fileBackend{path: path}, true)
+}
+
+func open(dir string, b *fileBackend, shared bool) (*Store, error) {
+	entries, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, b: b, shared: shared, entries: entries}, nil
+}
+
+// lockRead takes s.mu for reading entries. A shared index is reloaded
+// first if another instance changed it; if that fails, the last good
+// copy is read.
+func (s *Store) lockRead() {
+	s.mu.Lock()
+	if s.shared {
+		s.reloadLocked()
+	}
+}
+
+// lockWrite takes s.mu and, for a shared index, its lock file, then
+// reloads the index so the change applies to the latest entries. unlock
+// releases both.
+func (s *Store) lockWrite() (unlock func(), err error) {
+	s.mu.Lock()
+	if !s.shared {
+		return s.mu.Unlock, nil
+	}
+	release, err := s.b.lock()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("lock index: %w", err)
+	}
+	if err := s.reloadLocked(); err != nil {
+		release()
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		release()
+		s.mu.Unlock()
+	}, nil
+}
+
+// reloadLocked rereads the index if another instance changed it. Caller
+// holds s.mu.
+func (s *Store) reloadLocked() error {
+	changed, err := s.b.changed()
+	if err != nil || !changed {
+		return err
+	}
+	entries, err := s.b.load()
+	if err != nil {
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+// Add records a new entry, filling in ID and CreatedAt when unset.
+func (s *Store) Add(e Entry) (Entry, error) {
+	if e.ID == "" {
+		e.ID = httputil.NewID("", 8)
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	e.fillMetrics()
+	if s.db != nil {
+		if err := s.db.add(e); err != nil {
+			return Entry{}, err
+		}
+		return e, nil
+	}
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+	s.entries = append(s.entries, e)
+	if err := s.saveLocked(); err != nil {
+		s.entries = s.entries[:len(s.entries)-1]
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// Update applies fn to the entry with the given ID and persists the result.
+func (s *Store) Update(id string, fn func(*Entry)) error {
+	if s.db != nil {
+		return s.db.update(id, fn)
+	}
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for i := range s.entries {
+		if s.entries[i].ID == id {
+			prev := s.entries[i]
+			fn(&s.entries[i])
+			s.entries[i].ID = id
+			s.entries[i].fillMetrics()
+			if err := s.saveLocked(); err != nil {
+				s.entries[i] = prev
+				return err
+			}
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Remove deletes entries by ID. Unknown IDs are ignored.
+func (s *Store) Remove(ids ...string) error {
+	drop := map[string]bool{}
+	for _, id := range ids {
+		drop[id] = true
+	}
+	if s.db != nil {
+		if err := s.db.remove(ids); err != nil {
+			return err
+		}
+		for id := range drop {
+			s.removeSegments(id)
+		}
+		return nil
+	}
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prev := s.entries
+	kept := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if !drop[e.ID] {
+			kept = append(kept, e)
+		}
+	}
+	s.entries = kept
+	if err := s.saveLocked(); err != nil {
+		s.entries = prev
+		return err
+	}
+	for id := range drop {
+		s.removeSegments(id)
+	}
+	return nil
+}
+
+// UnlinkRecordings clears the recording of every entry that points at one
+// of names, after the files were deleted. Entries left with neither a note
+// nor a recording are removed, as Fix does for missing recordings.
+func (s *Store) UnlinkRecordings(names ...string) error {
+	if s.db != nil {
+		dropped, err := s.db.unlinkRecordings(names)
+		for _, id := range dropped {
+			s.removeSegments(id)
+		}
+		return err
+	}
+	gone := map[string]bool{}
+	for _, n := range names {
+		gone[n] = true
+	}
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	prev := s.entries
+	kept := make([]Entry, 0, len(s.entries))
+	var dropped []string
+	changed := false
+	for _, e := range s.entries {
+		if e.Recording != "" && gone[e.Recording] {
+			changed = true
+			e.Recording = ""
+			if e.VaultFile == "" {
+				dropped = append(dropped, e.ID)
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	if !changed {
+		unlock()
+		return nil
+	}
+	s.entries = kept
+	err = s.saveLocked()
+	if err != nil {
+		s.entries = prev
+	}
+	unlock()
+	if err != nil {
+		return err
+	}
+	for _, id := range dropped {
+		s.removeSegments(id)
+	}
+	return nil
+}
+
+// List returns a copy of all entries, newest first. Only a database index
+// can fail to read; callers must not take an error for an empty index.
+func (s *Store) List() ([]Entry, error) {
+	if s.db != nil {
+		return s.db.list(time.Time{}, time.Time{})
+	}
+	s.lockRead()
+	out := append([]Entry(nil), s.entries...)
+	s.mu.Unlock()
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Len returns the number of entries.
+func (s *Store) Len() (int, error) {
+	if s.db != nil {
+		return s.db.count()
+	}
+	s.lockRead()
+	defer s.mu.Unlock()
+	return len(s.entries), nil
+}
+
+// saveLocked persists the index. Caller holds s.mu.
+func (s *Store) saveLocked() error {
+	return s.b.save(s.entries)
+}
+
+// load reads every entry.
+func (b *fileBackend) load() ([]Entry, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse index %s: %w", filepath.Base(b.path), err)
+	}
+	b.stamp()
+	return entries, nil
+}
+
+// changed reports whether another instance wrote the file since the last
+// load or save.
+func (b *fileBackend) changed() (bool, error) {
+	info, err := os.Stat(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read index: %w", err)
+	}
+	return !info.ModTime().Equal(b.modTime) || info.Size() != b.size, nil
+}
+
+// lock keeps other instances from writing until unlock is called.
+func (b *fileBackend) lock() (unlock func(), err error) {
+	return cluster.Lock(b.path+".lock", lockWait, lockStale)
+}
+
+// save writes the whole index via temp file + rename.
+func (b *fileBackend) save(all []Entry) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("create index dir: %w", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace index: %w", err)
+	}
+	b.stamp()
+	return nil
+}
+
+// stamp remembers the file's state after reading or writing it.
+func (b *fileBackend) stamp() {
+	if info, err := os.Stat(b.path); err == nil {
+		b.modTime, b.size = info.ModTime(), info.Size()
+	}
+}
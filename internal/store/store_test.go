@@ -0,0 +1,234 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.Add(Entry{VaultFile: "/v/a.md", Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == "" || a.CreatedAt.IsZero() {
+		t.Errorf("Add should fill ID and CreatedAt: %+v", a)
+	}
+	s.Add(Entry{VaultFile: "/v/b.md", CreatedAt: a.CreatedAt.Add(time.Minute)})
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := reopened.List()
+	if len(list) != 2 || list[0].VaultFile != "/v/b.md" {
+		t.Errorf("list = %+v, want newest first", list)
+	}
+}
+
+func TestStoreUpdateAndRemove(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	e, _ := s.Add(Entry{VaultFile: "/v/a.md"})
+
+	if err := s.Update(e.ID, func(e *Entry) { e.Recording = "a.webm"; e.ID = "hijack" }); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := s.List()
+	if got := list[0]; got.Recording != "a.webm" || got.ID != e.ID {
+		t.Errorf("after update = %+v", got)
+	}
+	if err := s.Update("nope", func(*Entry) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	if err := s.Remove(e.ID, "unknown"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Len(); n != 0 {
+		t.Errorf("len = %d after remove", n)
+	}
+}
+
+func TestOpenCorruptIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	os.WriteFile(path, []byte("{not json"), 0600)
+	if _, err := Open(path); err == nil {
+		t.Error("expected an error for a corrupt index")
+	}
+}
+
+func TestPage(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.Add(Entry{VaultFile: filepath.Join("/v", string(rune('a'+i))+".md"), CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	var files []string
+	cur := ""
+	for pages := 0; ; pages++ {
+		page, next, err := s.Page(cur, 2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page {
+			files = append(files, filepath.Base(e.VaultFile))
+		}
+		if next == "" {
+			if pages != 2 {
+				t.Errorf("got %d pages, want 3", pages+1)
+			}
+			break
+		}
+		cur = next
+		// A note saved while paging doesn't shift later pages.
+		if pages == 0 {
+			s.Add(Entry{VaultFile: "/v/new.md", CreatedAt: base.Add(time.Hour)})
+		}
+	}
+	if got := strings.Join(files, ","); got != "e.md,d.md,c.md,b.md,a.md" {
+		t.Errorf("paged = %s", got)
+	}
+
+	// keep filters without ending the page early.
+	page, next, _ := s.Page("", 2, func(e Entry) bool { return e.VaultFile != "/v/d.md" && e.VaultFile != "/v/new.md" })
+	if len(page) != 2 || page[1].VaultFile != "/v/c.md" || next == "" {
+		t.Errorf("filtered page = %+v, next %q", page, next)
+	}
+
+	if _, _, err := s.Page("not-a-cursor", 2, nil); !errors.Is(err, ErrBadCursor) {
+		t.Errorf("bad cursor err = %v", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	e := Entry{CreatedAt: day, Language: "de", Model: "large-v3", Source: SourceWatcher, Words: 300, WPM: 150}
+	for name, tc := range map[string]struct {
+		f    Filter
+		want bool
+	}{
+		"empty":          {Filter{}, true},
+		"all fields":     {Filter{Language: "DE", Model: "large-v3", Source: "watcher"}, true},
+		"other language": {Filter{Language: "en"}, false},
+		"other source":   {Filter{Source: SourceAPI}, false},
+		"in range":       {Filter{From: day, To: day.Add(time.Hour)}, true},
+		"to exclusive":   {Filter{To: day}, false},
+		"before from":    {Filter{From: day.Add(time.Second)}, false},
+		"words in range": {Filter{MinWords: 300, MaxWords: 300}, true},
+		"too few words":  {Filter{MinWords: 301}, false},
+		"too many words": {Filter{MaxWords: 299}, false},
+		"wpm in range":   {Filter{MinWPM: 120, MaxWPM: 160}, true},
+		"too fast":       {Filter{MaxWPM: 149.9}, false},
+	} {
+		if got := tc.f.Match(e); got != tc.want {
+			t.Errorf("%s: Match = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestPageExactFit(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	s.Add(Entry{VaultFile: "/v/a.md"})
+	s.Add(Entry{VaultFile: "/v/b.md"})
+	page, next, _ := s.Page("", 2, nil)
+	if len(page) != 2 || next != "" {
+		t.Errorf("page = %d entries, next %q; want 2 and no next page", len(page), next)
+	}
+	if page, _, _ := s.Page("", 0, nil); len(page) != 2 {
+		t.Errorf("default limit page = %d entries", len(page))
+	}
+}
+
+func TestUnlinkRecordings(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	withNote, _ := s.Add(Entry{VaultFile: "/v/a.md", Recording: "a.webm"})
+	audioOnly, _ := s.Add(Entry{Recording: "b.webm"})
+	other, _ := s.Add(Entry{Recording: "c.webm"})
+
+	if err := s.UnlinkRecordings("a.webm", "b.webm", "zzz.webm"); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := s.Get(withNote.ID); e.Recording != "" || e.VaultFile != "/v/a.md" {
+		t.Errorf("entry with note = %+v", e)
+	}
+	if _, err := s.Get(audioOnly.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("entry left with nothing was kept")
+	}
+	if e, _ := s.Get(other.ID); e.Recording != "c.webm" {
+		t.Errorf("unrelated entry = %+v", e)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	loc := time.FixedZone("UTC+2", 2*3600)
+	add := func(at time.Time, file string, dur float64, words int) {
+		s.Add(Entry{VaultFile: file, CreatedAt: at, Duration: dur, Words: words})
+	}
+	add(time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC), "/v/a.md", 30, 70) // Jan 2 in loc
+	add(time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC), "/v/b.md", 15, 40)
+	add(time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC), "/v/c.md", 0, 5)
+	add(time.Date(2026, 3, 5, 13, 0, 0, 0, time.UTC), "/other/d.md", 60, 100)
+	add(time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC), "/v/old.md", 10, 20)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, loc)
+	days := s.Calendar(from, from.AddDate(1, 0, 0), loc, func(e Entry) bool {
+		return filepath.Dir(e.VaultFile) == "/v"
+	})
+	want := []Day{{"2026-01-02", 2, 45, 110}, {"2026-03-05", 1, 0, 5}}
+	if len(days) != len(want) {
+		t.Fatalf("days = %+v, want %+v", days, want)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], want[i])
+		}
+	}
+}
+
+func TestStoreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	a, _ := OpenShared(path)
+	b, _ := OpenShared(path)
+
+	// Writes from both instances land in the one index, none lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); a.Add(Entry{VaultFile: "/v/a.md"}) }()
+		go func() { defer wg.Done(); b.Add(Entry{VaultFile: "/v/b.md"}) }()
+	}
+	wg.Wait()
+	na, _ := a.Len()
+	nb, _ := b.Len()
+	if na != 20 || nb != 20 {
+		t.Fatalf("Len = %d, %d; want 20 on both", na, nb)
+	}
+
+	list, _ := b.List()
+	e := list[0]
+	if err := a.Update(e.ID, func(e *Entry) { e.Language = "de" }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Get(e.ID); got.Language != "de" {
+		t.Errorf("other instance sees language %q", got.Language)
+	}
+	if err := b.Remove(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get(e.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("entry removed by the other instance still there")
+	}
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("lock file left behind")
+	}
+}
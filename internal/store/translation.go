@@ -0,0 +1,73 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Translation is a transcript translated sentence by sentence, saved by
+// the language-learning mode (see internal/bilingual).
+type Translation struct {
+	Language  string         `json:"language"` // what it was translated into
+	Sentences []SentencePair `json:"sentences"`
+}
+
+// SentencePair is one sentence of a transcript and its translation.
+type SentencePair struct {
+	Original    string `json:"original"`
+	Translation string `json:"translation"`
+}
+
+// Kept beside the segments, and for the same reason: a translation is as
+// long as the transcript.
+func (s *Store) translationPath(id string) string {
+	return filepath.Join(s.dir, "translations", id+".json")
+}
+
+// SetTranslation stores the translation of an entry, replacing any
+// previous one.
+func (s *Store) SetTranslation(id string, t Translation) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	path := s.translationPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create translations dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write translation: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace translation: %w", err)
+	}
+	return nil
+}
+
+// Translation returns the translation of an entry. An entry without one
+// yields a Translation with no sentences.
+func (s *Store) Translation(id string) (Translation, error) {
+	if _, err := s.Get(id); err != nil {
+		return Translation{}, err
+	}
+	data, err := os.ReadFile(s.translationPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Translation{}, nil
+	}
+	if err != nil {
+		return Translation{}, fmt.Errorf("read translation: %w", err)
+	}
+	var t Translation
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Translation{}, fmt.Errorf("parse translation: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,185 @@
+package stream
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Dictation is an http.Handler for live dictation without a streaming
+// backend. The browser sends the same audio as to the Relay (binary
+// messages of 16 kHz mono float32 PCM); the server finds the pauses
+// between utterances itself (see Segmenter) and transcribes each one with
+// the ordinary Whisper backend as soon as it ends.
+//
+// Each transcribed utterance comes back as a text message:
+//
+//	{"type":"utterance","index":0,"start":1.2,"end":3.9,"utterance":"Hello.","text":"Hello."}
+//
+// where text is everything so far, so a client that only reads "text"
+// (as it would from a streaming backend) shows the running transcript.
+// {"type":"speech"} marks the start of each utterance and
+// {"type":"error","error":"..."} a failed one; the session carries on. The
+// text message {"type":"end"} asks for the rest: the last utterance is
+// transcribed, {"type":"done","text":"..."} sent, and the socket closed.
+type Dictation struct {
+	// Transcribe returns the text of one utterance, a 16 kHz mono WAV.
+	// previous is the text of the utterance before, for Whisper's prompt:
+	// it keeps spelling and punctuation consistent across the cuts.
+	Transcribe func(ctx context.Context, wav []byte, previous string) (string, error)
+	// Encode turns samples into the WAV Transcribe takes.
+	Encode func(samples []float32) []byte
+
+	VAD         VAD
+	IdleTimeout time.Duration // zero means DefaultIdleTimeout
+	MaxMessage  int64         // zero means DefaultMaxMessage
+	Logger      *slog.Logger
+}
+
+// dictationMessage is a message to the browser.
+type dictationMessage struct {
+	Type      string  `json:"type"`
+	Index     int     `json:"index"` // of the utterance; the total on "done"
+	Start     float64 `json:"start,omitempty"`
+	End       float64 `json:"end,omitempty"`
+	Utterance string  `json:"utterance,omitempty"`
+	Text      string  `json:"text,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ServeHTTP upgrades the request and transcribes until the browser ends
+// the session or goes away.
+func (d *Dictation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsHandshake(r) {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, `{"error": "websocket upgrade required"}`, http.StatusUpgradeRequired)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, `{"error": "cross-origin websocket refused"}`, http.StatusForbidden)
+		return
+	}
+	c, err := Upgrade(w, r)
+	if err != nil {
+		d.Logger.Warn("websocket upgrade failed", "error", err)
+		if errors.Is(err, ErrBadHandshake) {
+			http.Error(w, `{"error": "bad websocket handshake"}`, http.StatusBadRequest)
+		}
+		return
+	}
+	maxMessage := d.MaxMessage
+	if maxMessage <= 0 {
+		maxMessage = DefaultMaxMessage
+	}
+	c.SetMaxMessage(maxMessage)
+	idle := d.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+
+	// WHY not r.Context()? After the upgrade the request is over as far
+	// as net/http is concerned; the session ends when the socket does.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Utterances are transcribed one at a time, in order, while the reader
+	// keeps taking audio.
+	queue := make(chan Utterance, 16)
+	finished := make(chan string)
+	go func() {
+		var texts []string
+		index := 0
+		for u := range queue {
+			previous := ""
+			if len(texts) > 0 {
+				previous = texts[len(texts)-1]
+			}
+			text, err := d.Transcribe(ctx, d.Encode(u.Samples), previous)
+			text = strings.TrimSpace(text)
+			switch {
+			case err != nil:
+				if ctx.Err() == nil {
+					d.Logger.Warn("live utterance not transcribed", "error", err)
+					d.send(c, dictationMessage{Type: "error", Index: index, Start: u.Start, End: u.End(), Error: err.Error()})
+				}
+			case text != "":
+				texts = append(texts, text)
+				d.send(c, dictationMessage{Type: "utterance", Index: index, Start: u.Start, End: u.End(),
+					Utterance: text, Text: strings.Join(texts, " ")})
+			}
+			index++
+		}
+		finished <- strings.Join(texts, " ")
+	}()
+
+	seg := NewSegmenter(d.VAD)
+	start := time.Now()
+	ended := false
+	cut := 0
+	for {
+		c.SetReadDeadline(time.Now().Add(idle))
+		op, data, err := c.ReadMessage()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				c.Close(CloseGoingAway, "idle")
+			}
+			// Gone without asking for the rest: nobody is left to read it.
+			cancel()
+			break
+		}
+		if op == OpText {
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal(data, &msg) == nil && msg.Type == "end" {
+				ended = true
+				break
+			}
+			continue
+		}
+		was := seg.InSpeech()
+		for _, u := range seg.Write(float32s(data)) {
+			queue <- u
+			cut++
+		}
+		if !was && seg.InSpeech() {
+			d.send(c, dictationMessage{Type: "speech", Index: cut})
+		}
+	}
+	if ended {
+		if u, ok := seg.Flush(); ok {
+			queue <- u
+			cut++
+		}
+	}
+	close(queue)
+	text := <-finished
+	if ended {
+		d.send(c, dictationMessage{Type: "done", Index: cut, Text: text})
+		c.Close(CloseNormal, "")
+	}
+	d.Logger.Info("live dictation ended", "duration", time.Since(start).Round(time.Millisecond), "chars", len(text))
+}
+
+func (d *Dictation) send(c *Conn, m dictationMessage) {
+	data, _ := json.Marshal(m)
+	c.WriteMessage(OpText, data)
+}
+
+// float32s decodes little-endian float32 samples; a trailing partial
+// sample is dropped.
+func float32s(data []byte) []float32 {
+	out := make([]float32, len(data)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return out
+}
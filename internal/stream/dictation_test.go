@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func pcm(samples []float32) []byte {
+	out := make([]byte, 0, 4*len(samples))
+	for _, s := range samples {
+		out = binary.LittleEndian.AppendUint32(out, math.Float32bits(s))
+	}
+	return out
+}
+
+func TestDictation(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	var previous []string
+	srv := httptest.NewServer(&Dictation{
+		Transcribe: func(ctx context.Context, wav []byte, prev string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			previous = append(previous, prev)
+			return fmt.Sprintf(" Utterance %d of %d samples. ", calls, len(wav)/2), nil
+		},
+		Encode: func(samples []float32) []byte { return make([]byte, 2*len(samples)) },
+		Logger: testLogger(),
+	})
+	defer srv.Close()
+
+	c := dial(t, wsURL(srv.URL))
+	for _, part := range [][]float32{signal(0.5, 0), signal(1, 0.3), signal(1, 0), signal(0.6, 0.3)} {
+		if err := c.WriteMessage(OpBinary, pcm(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.WriteMessage(OpText, []byte(`{"type":"end"}`))
+
+	var msgs []dictationMessage
+	for {
+		op, data, err := c.ReadMessage()
+		if err != nil {
+			break
+		}
+		var m dictationMessage
+		if op != OpText || json.Unmarshal(data, &m) != nil {
+			t.Fatalf("unexpected message %q", data)
+		}
+		msgs = append(msgs, m)
+	}
+	// Speech marks come from the reader and may overtake utterances.
+	var utterances []dictationMessage
+	speech := 0
+	for _, m := range msgs[:len(msgs)-1] {
+		switch m.Type {
+		case "speech":
+			speech++
+		case "utterance":
+			utterances = append(utterances, m)
+		default:
+			t.Errorf("unexpected %+v", m)
+		}
+	}
+	if speech != 2 || len(utterances) != 2 {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	first, second := utterances[0], utterances[1]
+	if first.Index != 0 || second.Index != 1 || second.Start < 2 || first.Utterance != first.Text {
+		t.Errorf("utterances = %+v, %+v", first, second)
+	}
+	last := msgs[len(msgs)-1]
+	if last.Type != "done" || last.Text != second.Text || last.Text != first.Utterance+" "+second.Utterance || last.Index != 2 {
+		t.Errorf("done = %+v", last)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(previous) != 2 || previous[0] != "" || previous[1] != first.Utterance {
+		t.Errorf("prompts = %q", previous)
+	}
+}
@@ -0,0 +1,217 @@
+// Package stream relays live audio from the browser to a streaming Whisper
+// backend over WebSocket.
+//
+// The browser opens /v1/audio/transcriptions/stream on this server and
+// sends audio chunks (binary messages — 16 kHz mono float32 PCM from the
+// web UI). The relay opens its own WebSocket to the configured streaming
+// backend (whisper-streaming, WhisperLive, …) and passes messages through
+// unchanged in both directions, so partial hypotheses reach the browser as
+// soon as the backend emits them.
+//
+// WHY relay instead of letting the browser connect directly? The backend
+// then needs no public exposure, CORS or TLS of its own; it gets the same
+// auth token, URL policy and backend credentials as every other call; and
+// the page's Content-Security-Policy can stay at connect-src 'self'.
+//
+// Without a streaming backend, Dictation takes the same audio and does the
+// streaming itself: it cuts the stream at pauses (vad.go) and has the
+// ordinary Whisper backend transcribe each utterance.
+//
+// The WebSocket protocol (RFC 6455) is implemented in websocket.go — the
+// subset a relay needs, without an external dependency.
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIdleTimeout closes a session when neither side has sent anything
+// for this long — a closed laptop lid shouldn't hold a backend slot forever.
+const DefaultIdleTimeout = 60 * time.Second
+
+// Relay is an http.Handler bridging browser WebSockets to a backend.
+type Relay struct {
+	// Backend returns the current backend URL (ws:// or wss://). An empty
+	// URL means streaming is not configured.
+	Backend func() string
+	// Check, if set, vets the backend URL before each dial. It receives the
+	// URL with ws/wss mapped to http/https, so an HTTP URL policy applies.
+	Check func(httpURL string) error
+	// Header, if set, returns extra handshake headers for the backend
+	// (credentials).
+	Header func() http.Header
+	// Dial options for the backend connection (TLS, dialer hooks).
+	Dial DialOptions
+
+	IdleTimeout time.Duration // zero means DefaultIdleTimeout
+	MaxMessage  int64         // zero means DefaultMaxMessage
+	Logger      *slog.Logger
+}
+
+// ServeHTTP upgrades the request and relays until either side closes.
+func (rl *Relay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsHandshake(r) {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, `{"error": "websocket upgrade required"}`, http.StatusUpgradeRequired)
+		return
+	}
+	// WHY check Origin? Browsers send cookies and client certificates with
+	// cross-site WebSocket handshakes and CORS does not apply, so any page
+	// could otherwise stream through this server.
+	if !sameOrigin(r) {
+		http.Error(w, `{"error": "cross-origin websocket refused"}`, http.StatusForbidden)
+		return
+	}
+	backendURL := ""
+	if rl.Backend != nil {
+		backendURL = rl.Backend()
+	}
+	if backendURL == "" {
+		http.Error(w, `{"error": "streaming backend not configured (CAPTAINSLOG_STREAM_URL)"}`, http.StatusServiceUnavailable)
+		return
+	}
+	if rl.Check != nil {
+		if err := rl.Check(httpEquivalent(backendURL)); err != nil {
+			rl.Logger.Warn("streaming backend URL rejected by URL policy", "error", err)
+			http.Error(w, `{"error": "streaming backend URL not allowed"}`, http.StatusBadGateway)
+			return
+		}
+	}
+
+	// Dial the backend before upgrading, so a dead backend is an ordinary
+	// 502 the browser can report instead of a socket that opens and dies.
+	opts := rl.Dial
+	if rl.Header != nil {
+		opts.Header = rl.Header()
+	}
+	dialCtx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	backend, err := Dial(dialCtx, backendURL, opts)
+	cancel()
+	if err != nil {
+		rl.Logger.Error("streaming backend unavailable", "error", err)
+		http.Error(w, `{"error": "streaming backend unavailable"}`, http.StatusBadGateway)
+		return
+	}
+
+	client, err := Upgrade(w, r)
+	if err != nil {
+		backend.Close(CloseGoingAway, "")
+		rl.Logger.Warn("websocket upgrade failed", "error", err)
+		if errors.Is(err, ErrBadHandshake) {
+			http.Error(w, `{"error": "bad websocket handshake"}`, http.StatusBadRequest)
+		}
+		return
+	}
+
+	maxMessage := rl.MaxMessage
+	if maxMessage <= 0 {
+		maxMessage = DefaultMaxMessage
+	}
+	client.SetMaxMessage(maxMessage)
+	backend.SetMaxMessage(maxMessage)
+
+	start := time.Now()
+	up, down := rl.relay(client, backend)
+	rl.Logger.Info("stream session ended", "duration", time.Since(start).Round(time.Millisecond),
+		"chunks_up", up, "messages_down", down)
+}
+
+// relay pipes messages both ways until one side stops, then closes both.
+// It returns how many messages went up to the backend and down to the
+// browser.
+func (rl *Relay) relay(client, backend *Conn) (up, down int) {
+	idle := rl.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	var once sync.Once
+	done := make(chan struct{})
+	stop := func(code int) {
+		once.Do(func() {
+			client.Close(code, "")
+			backend.Close(code, "")
+			close(done)
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		up = pipe(client, backend, idle, stop)
+	}()
+	go func() {
+		defer wg.Done()
+		down = pipe(backend, client, idle, stop)
+	}()
+	<-done
+	wg.Wait()
+	return up, down
+}
+
+// pipe copies messages from src to dst until either fails, then calls stop
+// with the close code to send to both ends.
+func pipe(src, dst *Conn, idle time.Duration, stop func(code int)) int {
+	n := 0
+	for {
+		src.SetReadDeadline(time.Now().Add(idle))
+		op, data, err := src.ReadMessage()
+		if err != nil {
+			code := CloseNormal
+			var ce *CloseError
+			var ne net.Error
+			switch {
+			case errors.As(err, &ce) && ce.Code == CloseTooLarge:
+				code = CloseTooLarge
+			case errors.As(err, &ne) && ne.Timeout():
+				code = CloseGoingAway
+			}
+			stop(code)
+			return n
+		}
+		if err := dst.WriteMessage(op, data); err != nil {
+			stop(CloseInternalError)
+			return n
+		}
+		n++
+	}
+}
+
+// httpEquivalent maps ws:// and wss:// to http:// and https:// for checks
+// written against HTTP URLs.
+func httpEquivalent(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// sameOrigin reports whether a browser handshake came from a page on this
+// host. Non-browser clients send no Origin and are let through — they are
+// subject to the auth token like any other API call.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
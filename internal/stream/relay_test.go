@@ -0,0 +1,207 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// newBackend starts a fake streaming backend. It answers every audio chunk
+// with a text hypothesis and closes after "bye".
+func newBackend(t *testing.T, gotHeader *http.Header) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gotHeader != nil {
+			*gotHeader = r.Header.Clone()
+		}
+		c, err := Upgrade(w, r)
+		if err != nil {
+			t.Errorf("backend upgrade: %v", err)
+			return
+		}
+		for {
+			op, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if op == OpText && string(data) == "bye" {
+				c.Close(CloseNormal, "done")
+				return
+			}
+			c.WriteMessage(OpText, []byte(`{"partial":"heard `+string(rune('0'+len(data)))+` bytes"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newRelay(t *testing.T, rl *Relay) *httptest.Server {
+	t.Helper()
+	if rl.Logger == nil {
+		rl.Logger = testLogger()
+	}
+	srv := httptest.NewServer(rl)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wsURL(httpURL string) string { return "ws" + strings.TrimPrefix(httpURL, "http") }
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, url, DialOptions{})
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { c.Close(CloseNormal, "") })
+	return c
+}
+
+func TestRelayRoundTrip(t *testing.T) {
+	var hdr http.Header
+	backend := newBackend(t, &hdr)
+	relay := newRelay(t, &Relay{
+		Backend: func() string { return wsURL(backend.URL) },
+		Header:  func() http.Header { return http.Header{"Authorization": {"Bearer backend-key"}} },
+	})
+
+	c := dial(t, wsURL(relay.URL))
+	if err := c.WriteMessage(OpBinary, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	op, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != OpText || string(data) != `{"partial":"heard 3 bytes"}` {
+		t.Errorf("got op %d %q", op, data)
+	}
+	if hdr.Get("Authorization") != "Bearer backend-key" {
+		t.Errorf("backend Authorization = %q", hdr.Get("Authorization"))
+	}
+
+	// The backend closing ends the browser's session too.
+	c.WriteMessage(OpText, []byte("bye"))
+	_, _, err = c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		t.Errorf("after backend close, err = %v, want a CloseError", err)
+	}
+}
+
+func TestRelayLargeMessage(t *testing.T) {
+	backend := newBackend(t, nil)
+	relay := newRelay(t, &Relay{Backend: func() string { return wsURL(backend.URL) }, MaxMessage: 1024})
+
+	c := dial(t, wsURL(relay.URL))
+	c.WriteMessage(OpBinary, make([]byte, 2048))
+	_, _, err := c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != CloseTooLarge {
+		t.Errorf("err = %v, want close %d", err, CloseTooLarge)
+	}
+}
+
+func TestRelayIdleTimeout(t *testing.T) {
+	backend := newBackend(t, nil)
+	relay := newRelay(t, &Relay{Backend: func() string { return wsURL(backend.URL) }, IdleTimeout: 50 * time.Millisecond})
+
+	c := dial(t, wsURL(relay.URL))
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != CloseGoingAway {
+		t.Errorf("err = %v, want close %d", err, CloseGoingAway)
+	}
+}
+
+func TestRelayHTTPErrors(t *testing.T) {
+	backend := newBackend(t, nil)
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := wsURL(dead.URL)
+	dead.Close()
+
+	tests := []struct {
+		name    string
+		relay   *Relay
+		headers map[string]string
+		want    int
+	}{
+		{"not an upgrade", &Relay{Backend: func() string { return wsURL(backend.URL) }}, nil, http.StatusUpgradeRequired},
+		{"not configured", &Relay{Backend: func() string { return "" }}, nil, http.StatusServiceUnavailable},
+		{"cross origin", &Relay{Backend: func() string { return wsURL(backend.URL) }}, map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"policy", &Relay{
+			Backend: func() string { return wsURL(backend.URL) },
+			Check: func(u string) error {
+				if !strings.HasPrefix(u, "http://") {
+					t.Errorf("Check got %q, want an http:// URL", u)
+				}
+				return errors.New("blocked")
+			},
+		}, nil, http.StatusBadGateway},
+		{"backend down", &Relay{Backend: func() string { return deadURL }}, nil, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRelay(t, tt.relay)
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if tt.want != http.StatusUpgradeRequired {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Sec-WebSocket-Version", "13")
+				req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The worked example from RFC 6455 §1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %q", got)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin, host string
+		want         bool
+	}{
+		{"", "captainslog.lan:8090", true},
+		{"http://captainslog.lan:8090", "captainslog.lan:8090", true},
+		{"https://CaptainsLog.lan:8090", "captainslog.lan:8090", true},
+		{"https://evil.example", "captainslog.lan:8090", false},
+		{"http://captainslog.lan:9999", "captainslog.lan:8090", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := sameOrigin(r); got != tt.want {
+			t.Errorf("sameOrigin(%q on %q) = %v, want %v", tt.origin, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"math"
+	"time"
+)
+
+// vadFrame is the analysis window of the voice activity detector: 30 ms
+// of 16 kHz audio.
+const vadFrame = 480
+
+// VAD tunes the voice activity detector. Zero fields take the defaults.
+type VAD struct {
+	// Threshold is the lowest RMS level (0 to 1) counted as speech. The
+	// detector raises it to three times the background noise it measures.
+	Threshold float64
+	// Silence ends an utterance: a pause this long between words.
+	Silence time.Duration
+	// MaxUtterance cuts a long monologue without a pause into pieces, so
+	// text keeps arriving.
+	MaxUtterance time.Duration
+	// MinSpeech drops utterances with less speech than this: coughs, clicks
+	// and keyboard noise.
+	MinSpeech time.Duration
+}
+
+// Defaults for VAD.
+const (
+	DefaultVADThreshold    = 0.01
+	DefaultVADSilence      = 700 * time.Millisecond
+	DefaultVADMaxUtterance = 20 * time.Second
+	DefaultVADMinSpeech    = 250 * time.Millisecond
+)
+
+// Utterance is a stretch of speech cut from the stream.
+type Utterance struct {
+	Start   float64   // seconds since the stream began
+	Samples []float32 // 16 kHz mono
+}
+
+// End is where the utterance ends, in seconds since the stream began.
+func (u Utterance) End() float64 { return u.Start + float64(len(u.Samples))/sampleRate }
+
+const sampleRate = 16000
+
+// preRoll frames are kept ahead of detected speech, so the soft start of
+// the first word isn't cut off.
+const preRoll = 10
+
+// Segmenter cuts a stream of 16 kHz mono samples into utterances by
+// energy: speech is louder than the room. Not safe for concurrent use.
+type Segmenter struct {
+	threshold                           float64
+	silenceFrames, maxFrames, minVoiced int
+
+	pending []float32 // samples not yet a whole frame
+	frames  int       // frames analysed so far
+	noise   float64   // running estimate of the background level
+
+	speech []float32   // the utterance so far, pre-roll included
+	start  int         // frame the utterance starts at
+	voiced int         // frames above the threshold in it
+	quiet  int         // frames below the threshold since the last voiced one
+	onset  int         // consecutive voiced frames before speech starts
+	recent [][]float32 // the last frames before speech, for the pre-roll
+}
+
+// NewSegmenter returns a Segmenter tuned by v.
+func NewSegmenter(v VAD) *Segmenter {
+	frames := func(d, def time.Duration) int {
+		if d <= 0 {
+			d = def
+		}
+		return max(1, int(d/(vadFrame*time.Second/sampleRate)))
+	}
+	threshold := v.Threshold
+	if threshold <= 0 {
+		threshold = DefaultVADThreshold
+	}
+	return &Segmenter{
+		threshold:     threshold,
+		silenceFrames: frames(v.Silence, DefaultVADSilence),
+		maxFrames:     frames(v.MaxUtterance, DefaultVADMaxUtterance),
+		minVoiced:     frames(v.MinSpeech, DefaultVADMinSpeech),
+		start:         -1,
+	}
+}
+
+// Write analyses samples and returns the utterances they complete.
+func (s *Segmenter) Write(samples []float32) []Utterance {
+	var out []Utterance
+	s.pending = append(s.pending, samples...)
+	for len(s.pending) >= vadFrame {
+		frame := append([]float32(nil), s.pending[:vadFrame]...)
+		s.pending = s.pending[vadFrame:]
+		if u, ok := s.frame(frame); ok {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// Flush ends the stream: speech still in progress is returned as the last
+// utterance.
+func (s *Segmenter) Flush() (Utterance, bool) {
+	if s.start >= 0 && len(s.pending) > 0 {
+		s.speech = append(s.speech, s.pending...)
+	}
+	s.pending = nil
+	return s.cut()
+}
+
+// InSpeech reports whether an utterance is in progress.
+func (s *Segmenter) InSpeech() bool { return s.start >= 0 }
+
+func (s *Segmenter) frame(frame []float32) (Utterance, bool) {
+	defer func() { s.frames++ }()
+	level := rms(frame)
+	voiced := level > max(s.threshold, 3*s.noise)
+
+	if s.start < 0 {
+		if !voiced {
+			// The room's level, learned while nobody speaks.
+			s.noise = 0.95*s.noise + 0.05*level
+			s.onset = 0
+		} else {
+			s.onset++
+		}
+		s.recent = append(s.recent, frame)
+		if len(s.recent) > preRoll {
+			s.recent = s.recent[1:]
+		}
+		// Three loud frames in a row, not one click, start speech.
+		if s.onset < 3 {
+			return Utterance{}, false
+		}
+		s.start = s.frames - len(s.recent) + 1
+		for _, f := range s.recent {
+			s.speech = append(s.speech, f...)
+		}
+		s.recent = nil
+		s.voiced, s.quiet, s.onset = 3, 0, 0
+		return Utterance{}, false
+	}
+
+	s.speech = append(s.speech, frame...)
+	if voiced {
+		s.voiced++
+		s.quiet = 0
+	} else {
+		s.quiet++
+	}
+	if s.quiet >= s.silenceFrames {
+		return s.cut()
+	}
+	if len(s.speech)/vadFrame >= s.maxFrames {
+		u, ok := s.cut()
+		// Still talking: the next piece starts with the next frame.
+		s.start = s.frames + 1
+		return u, ok
+	}
+	return Utterance{}, false
+}
+
+// cut ends the current utterance, dropping it if it held too little speech.
+func (s *Segmenter) cut() (Utterance, bool) {
+	if s.start < 0 {
+		return Utterance{}, false
+	}
+	// Keep a little of the trailing pause, not all of it.
+	if trim := (s.quiet - preRoll/2) * vadFrame; trim > 0 && trim < len(s.speech) {
+		s.speech = s.speech[:len(s.speech)-trim]
+	}
+	u := Utterance{Start: float64(s.start*vadFrame) / sampleRate, Samples: s.speech}
+	enough := s.voiced >= s.minVoiced
+	s.speech, s.start, s.voiced, s.quiet = nil, -1, 0, 0
+	return u, enough
+}
+
+func rms(frame []float32) float64 {
+	var sum float64
+	for _, v := range frame {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(frame)))
+}
@@ -0,0 +1,66 @@
+package stream
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// signal returns seconds of a 220 Hz tone at amplitude level, over a
+// faint hiss.
+func signal(seconds, level float64) []float32 {
+	out := make([]float32, int(seconds*sampleRate))
+	for i := range out {
+		hiss := 0.001 * float64(i%7-3) / 3
+		out[i] = float32(level*math.Sin(2*math.Pi*220*float64(i)/sampleRate) + hiss)
+	}
+	return out
+}
+
+func TestSegmenter(t *testing.T) {
+	s := NewSegmenter(VAD{})
+	var got []Utterance
+	for _, part := range [][]float32{signal(1, 0), signal(1, 0.3), signal(1, 0), signal(0.05, 0.3), signal(1, 0), signal(0.8, 0.3)} {
+		// Browser-sized messages, not frame-aligned.
+		for len(part) > 0 {
+			n := min(len(part), 4096/3)
+			got = append(got, s.Write(part[:n])...)
+			part = part[n:]
+		}
+	}
+	if !s.InSpeech() {
+		t.Error("not in speech at the end of the last tone")
+	}
+	if u, ok := s.Flush(); ok {
+		got = append(got, u)
+	}
+	// The 50 ms click is dropped.
+	if len(got) != 2 {
+		t.Fatalf("got %d utterances, want 2", len(got))
+	}
+	if got[0].Start < 0.6 || got[0].Start > 1 || got[0].End() < 2 || got[0].End() > 2.5 {
+		t.Errorf("first utterance %.2f–%.2f, want about 0.7–2.2", got[0].Start, got[0].End())
+	}
+	if got[1].Start < 3.7 || got[1].Start > 4.05 || got[1].End() < 4.8 {
+		t.Errorf("second utterance %.2f–%.2f, want about 3.8–4.85", got[1].Start, got[1].End())
+	}
+}
+
+func TestSegmenterMaxUtterance(t *testing.T) {
+	s := NewSegmenter(VAD{MaxUtterance: time.Second})
+	got := s.Write(signal(3.5, 0.3))
+	if len(got) != 3 {
+		t.Fatalf("got %d utterances from 3.5s of speech, want 3 cut at 1s", len(got))
+	}
+	for i, u := range got {
+		if d := u.End() - u.Start; d < 0.9 || d > 1.05 {
+			t.Errorf("utterance of %.2fs", d)
+		}
+		if i > 0 && u.Start != got[i-1].End() {
+			t.Errorf("gap between pieces: %.3f to %.3f", got[i-1].End(), u.Start)
+		}
+	}
+	if !s.InSpeech() {
+		t.Error("the rest of the speech was dropped")
+	}
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+)
+
+// pipePair returns a connected client and server Conn over net.Pipe.
+func pipePair() (client, server *Conn, clientRaw net.Conn) {
+	a, b := net.Pipe()
+	client = &Conn{conn: a, br: bufio.NewReader(a), client: true, maxMessage: DefaultMaxMessage}
+	server = &Conn{conn: b, br: bufio.NewReader(b), maxMessage: DefaultMaxMessage}
+	return client, server, a
+}
+
+// maskedFrame builds a client frame with an all-zero mask, so the payload
+// goes over the wire as-is.
+func maskedFrame(fin bool, op int, payload string) []byte {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	f := []byte{b0, 0x80 | byte(len(payload)), 0, 0, 0, 0}
+	return append(f, payload...)
+}
+
+func TestReadMessageReassemblesFragmentsAndAnswersPings(t *testing.T) {
+	client, server, raw := pipePair()
+	defer client.conn.Close()
+	defer server.conn.Close()
+
+	go func() {
+		raw.Write(maskedFrame(false, OpText, "hel"))
+		raw.Write(maskedFrame(true, OpPing, "p"))
+		raw.Write(maskedFrame(true, OpContinuation, "lo"))
+	}()
+	pong := make(chan []byte, 1)
+	go func() {
+		_, op, payload, _ := client.readFrame()
+		if op == OpPong {
+			pong <- payload
+		}
+	}()
+
+	op, data, err := server.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != OpText || string(data) != "hello" {
+		t.Errorf("got op %d %q, want text \"hello\"", op, data)
+	}
+	if p := <-pong; string(p) != "p" {
+		t.Errorf("pong payload = %q", p)
+	}
+}
+
+func TestReadMessageRejectsUnmaskedClientFrames(t *testing.T) {
+	client, server, raw := pipePair()
+	defer client.conn.Close()
+
+	go func() {
+		raw.Write([]byte{0x82, 0x01, 0xFF})
+		client.readFrame() // drain the close frame
+	}()
+	_, _, err := server.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != CloseProtocolError {
+		t.Errorf("err = %v, want close %d", err, CloseProtocolError)
+	}
+}
+
+func TestWriteMessageRoundTrip(t *testing.T) {
+	client, server, _ := pipePair()
+	defer client.conn.Close()
+	defer server.conn.Close()
+
+	big := make([]byte, 70000) // 64-bit length form
+	for i := range big {
+		big[i] = byte(i)
+	}
+	go client.WriteMessage(OpBinary, big)
+	op, data, err := server.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != OpBinary || len(data) != len(big) || data[69999] != big[69999] {
+		t.Errorf("got op %d, %d bytes", op, len(data))
+	}
+}
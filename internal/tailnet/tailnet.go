@@ -0,0 +1,132 @@
+// Package tailnet serves Captain's Log directly on the user's Tailscale
+// network.
+//
+// With it enabled, the server joins the tailnet as its own node through
+// tsnet and is reachable at https://<hostname>.<tailnet>.ts.net from every
+// device on it — a phone can dictate remotely with no port forwarding, and
+// the MagicDNS name carries a real Let's Encrypt certificate, so the browser
+// grants microphone access without the self-signed-certificate warning.
+//
+// WHY tsnet? It needs no tailscaled or tailscale CLI on the machine: the
+// node runs inside the process, logs in with an auth key from the
+// environment the first time, and keeps its identity in a state directory
+// so the hostname stays the same across restarts.
+package tailnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tsnet"
+)
+
+// httpsAddr is the tailnet address the server listens on.
+const httpsAddr = ":443"
+
+// Options configures the tailnet node.
+type Options struct {
+	// Server serves tailnet connections alongside its local listener, so
+	// both share handlers, timeouts and Shutdown.
+	Server *http.Server
+	// AuthKey logs the node in the first time it starts. Once Dir holds
+	// the node's state it is no longer needed.
+	AuthKey string
+	// Hostname is the node's machine name (default: the binary name).
+	Hostname string
+	// Dir keeps the node's state: its keys and identity on the tailnet.
+	Dir string
+}
+
+// node is the part of *tsnet.Server used here; a seam so tests can fake
+// the tailnet.
+type node interface {
+	Up(ctx context.Context) (*ipnstate.Status, error)
+	CertDomains() []string
+	ListenTLS(network, addr string) (net.Listener, error)
+	Close() error
+}
+
+// Tailnet serves a local http.Server on the tailnet.
+type Tailnet struct {
+	opts    Options
+	logger  *slog.Logger
+	node    node
+	started bool
+
+	url string
+}
+
+// New returns a Tailnet for opts. Nothing runs until Publish.
+func New(opts Options, logger *slog.Logger) *Tailnet {
+	return &Tailnet{
+		opts:   opts,
+		logger: logger,
+		node: &tsnet.Server{
+			Dir:      opts.Dir,
+			Hostname: opts.Hostname,
+			AuthKey:  opts.AuthKey,
+			// Login URLs and state changes; the backend's own logs are
+			// debugging noise.
+			UserLogf: func(format string, args ...any) {
+				logger.Info("tailnet: " + strings.TrimSpace(fmt.Sprintf(format, args...)))
+			},
+			Logf: func(format string, args ...any) {
+				logger.Debug("tsnet: " + strings.TrimSpace(fmt.Sprintf(format, args...)))
+			},
+		},
+	}
+}
+
+// Publish joins the tailnet, waiting for login, and starts serving over
+// HTTPS. It returns the public tailnet URL.
+func (t *Tailnet) Publish(ctx context.Context) (string, error) {
+	t.started = true
+	st, err := t.node.Up(ctx)
+	if err != nil {
+		if ctx.Err() != nil && t.opts.AuthKey == "" {
+			return "", fmt.Errorf("not logged in to the tailnet — open the login URL above, or set CAPTAINSLOG_TAILSCALE_AUTHKEY: %w", err)
+		}
+		return "", fmt.Errorf("join tailnet: %w", err)
+	}
+	if st.Self == nil || st.Self.DNSName == "" {
+		return "", errors.New("tailnet has no MagicDNS name for this node — enable MagicDNS in the Tailscale admin console")
+	}
+	domains := t.node.CertDomains()
+	if len(domains) == 0 {
+		return "", errors.New("tailnet cannot issue certificates — enable HTTPS in the Tailscale admin console (DNS → HTTPS Certificates)")
+	}
+
+	ln, err := t.node.ListenTLS("tcp", httpsAddr)
+	if err != nil {
+		return "", fmt.Errorf("listen on tailnet: %w", err)
+	}
+	go func() {
+		if err := t.opts.Server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.logger.Warn("tailnet listener stopped", "error", err)
+		}
+	}()
+	t.url = "https://" + domains[0]
+	t.logger.Info("serving on tailnet", "url", t.url)
+	return t.url, nil
+}
+
+// URL returns the tailnet URL, or "" if not published.
+func (t *Tailnet) URL() string { return t.url }
+
+// Close takes the node off the tailnet. Call it after Server.Shutdown, so
+// requests from the tailnet drain first. The node stays logged in for the
+// next start.
+func (t *Tailnet) Close() error {
+	if !t.started {
+		return nil
+	}
+	t.started = false
+	t.url = ""
+	return t.node.Close()
+}
@@ -0,0 +1,127 @@
+package tailnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// fake is a tailnet node whose "TLS" listener is plain TCP on loopback.
+type fake struct {
+	upErr   error
+	dnsName string
+	domains []string
+
+	ln     net.Listener
+	closed bool
+}
+
+func (f *fake) Up(ctx context.Context) (*ipnstate.Status, error) {
+	if f.upErr != nil {
+		return nil, f.upErr
+	}
+	return &ipnstate.Status{Self: &ipnstate.PeerStatus{DNSName: f.dnsName}}, nil
+}
+
+func (f *fake) CertDomains() []string { return f.domains }
+
+func (f *fake) ListenTLS(network, addr string) (net.Listener, error) {
+	if addr != httpsAddr {
+		return nil, errors.New("unexpected address " + addr)
+	}
+	var err error
+	f.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	return f.ln, err
+}
+
+func (f *fake) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newFake(opts Options, f *fake) *Tailnet {
+	t := New(opts, testLogger())
+	t.node = f
+	return t
+}
+
+func running() *fake {
+	return &fake{dnsName: "captainslog.tail1234.ts.net.", domains: []string{"captainslog.tail1234.ts.net"}}
+}
+
+func TestPublishServes(t *testing.T) {
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	})}
+	f := running()
+	tn := newFake(Options{Server: srv}, f)
+
+	url, err := tn.Publish(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://captainslog.tail1234.ts.net" || tn.URL() != url {
+		t.Errorf("url = %q", url)
+	}
+
+	resp, err := http.Get("http://" + f.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("body = %q", body)
+	}
+
+	srv.Shutdown(context.Background())
+	if err := tn.Close(); err != nil || !f.closed || tn.URL() != "" {
+		t.Errorf("Close: err = %v, closed = %v, url = %q", err, f.closed, tn.URL())
+	}
+}
+
+func TestCloseBeforePublish(t *testing.T) {
+	f := running()
+	tn := newFake(Options{Server: &http.Server{}}, f)
+	if err := tn.Close(); err != nil || f.closed {
+		t.Errorf("Close before Publish: err = %v, node closed = %v", err, f.closed)
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		node *fake
+		want string
+	}{
+		{"login timed out", canceled, &fake{upErr: context.Canceled}, "CAPTAINSLOG_TAILSCALE_AUTHKEY"},
+		{"backend error", context.Background(), &fake{upErr: errors.New("tsnet.Up: backend: invalid key")}, "invalid key"},
+		{"no MagicDNS", context.Background(), &fake{domains: []string{"x"}}, "MagicDNS"},
+		{"no HTTPS certificates", context.Background(), &fake{dnsName: "captainslog.tail1234.ts.net."}, "HTTPS"},
+	}
+	for _, tt := range tests {
+		tn := newFake(Options{Server: &http.Server{}}, tt.node)
+		_, err := tn.Publish(tt.ctx)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want mention of %q", tt.name, err, tt.want)
+		}
+		if tt.node.ln != nil {
+			t.Errorf("%s: listened despite the error", tt.name)
+		}
+		if tn.Close(); !tt.node.closed {
+			t.Errorf("%s: node not closed", tt.name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// BackendConfig returns the TLS settings for outbound connections to the
+// Whisper and LLM backends, or nil when the defaults (system roots, full
+// verification) apply.
+//
+// caFile is a PEM bundle added to the system roots, for backends with a
+// certificate from an internal CA. insecure turns verification off
+// entirely; it exists for lab setups with self-signed backends and must be
+// opted into explicitly.
+func BackendConfig(caFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && !insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read backend CA: %w", err)
+	}
+	// WHY start from the system pool? A custom CA for the Whisper box must
+	// not break a hosted LLM endpoint with a public certificate.
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("backend CA %s contains no PEM certificates", caFile)
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
+}
@@ -0,0 +1,58 @@
+package tls
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackendConfigDefaults(t *testing.T) {
+	cfg, err := BackendConfig("", false)
+	if err != nil || cfg != nil {
+		t.Errorf("BackendConfig() = %v, %v; want nil, nil", cfg, err)
+	}
+}
+
+func TestBackendConfigCustomCA(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	// Without the CA the backend's certificate is rejected.
+	if _, err := http.Get(backend.URL); err == nil {
+		t.Fatal("expected a verification error without the CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw}), 0600)
+	cfg, err := BackendConfig(caFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("request with the CA failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestBackendConfigInsecure(t *testing.T) {
+	cfg, err := BackendConfig("", true)
+	if err != nil || cfg == nil || !cfg.InsecureSkipVerify {
+		t.Errorf("BackendConfig(insecure) = %+v, %v", cfg, err)
+	}
+}
+
+func TestBackendConfigBadCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, []byte("not a certificate"), 0600)
+	if _, err := BackendConfig(caFile, false); err == nil {
+		t.Error("expected an error for a CA file without certificates")
+	}
+	if _, err := BackendConfig(filepath.Join(t.TempDir(), "missing.pem"), false); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+}
@@ -0,0 +1,116 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CA file names in the cert dir. The CA key never leaves this machine;
+// the CA cert is what devices install, once.
+const (
+	CAFile    = "captainslog-ca.crt"
+	caKeyFile = "captainslog-ca.key"
+)
+
+// caValidity is how long the local CA is valid. Devices trust it once, so
+// it should outlive many host certificates.
+const caValidity = 10 * 365 * 24 * time.Hour
+
+// CACert returns the PEM of the local CA in certDir, for devices to
+// install. os.ErrNotExist means there is none yet.
+func CACert(certDir string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(certDir, CAFile))
+}
+
+// loadCA reads the local CA in certDir.
+func loadCA(certDir string) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(certDir, CAFile), filepath.Join(certDir, caKeyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || !ca.IsCA {
+		return nil, nil, fmt.Errorf("%s is not a CA", CAFile)
+	}
+	return ca, key, nil
+}
+
+// loadOrCreateCA returns the local CA in certDir, creating it the first
+// time, and whether it did. An existing CA keeps its key type whatever
+// policy says: replacing it would mean installing it on every device again.
+func loadOrCreateCA(certDir string, policy Policy, logger *slog.Logger) (*x509.Certificate, crypto.Signer, bool, error) {
+	ca, key, err := loadCA(certDir)
+	if err == nil && time.Now().Before(ca.NotAfter.Add(-30*24*time.Hour)) {
+		return ca, key, false, nil
+	}
+	if err == nil {
+		logger.Warn("local CA is about to expire, creating a new one — devices must install it again", "expires", ca.NotAfter)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("local CA unreadable, creating a new one — devices must install it again", "error", err)
+	}
+
+	key, _, err = newKey(policy)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	serialNumber, err := newSerial()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	host, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Captain's Log local CA"},
+			// The host name tells CAs of several machines apart in a
+			// device's trust store.
+			CommonName: fmt.Sprintf("Captain's Log CA (%s)", host),
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true, // it issues host certs, never other CAs
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("create CA: %w", err)
+	}
+	if err := writePair(filepath.Join(certDir, CAFile), filepath.Join(certDir, caKeyFile), der, key); err != nil {
+		return nil, nil, false, err
+	}
+	ca, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	logger.Info("created local CA — install it on your devices once to trust every certificate it issues",
+		"cert", filepath.Join(certDir, CAFile), "fingerprint", Fingerprint(der), "expires", ca.NotAfter)
+	return ca, key, true, nil
+}
+
+// issuedByOtherCA reports whether leaf was issued by a CA (it isn't
+// self-signed) that isn't the local CA in certDir — the CA was deleted or
+// replaced, so devices trusting the current one won't trust leaf.
+func issuedByOtherCA(leaf *x509.Certificate, certDir string) bool {
+	// Self-signed: its own key made its signature. (CheckSignatureFrom
+	// would refuse leaf as a parent, not being a CA.)
+	if leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil {
+		return false
+	}
+	ca, _, err := loadCA(certDir)
+	return err != nil || leaf.CheckSignatureFrom(ca) != nil
+}
@@ -0,0 +1,96 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateOrLoadLocalCA(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy, _ := ParsePolicy("", "", "", "", "ecdsa")
+	var rotations []Rotation
+	opts := Options{
+		Hostnames:   []string{"captainslog.home.arpa"},
+		RefreshSANs: true,
+		LocalCA:     true,
+		OnRotate:    func(r Rotation) { rotations = append(rotations, r) },
+	}
+	cfg, err := GenerateOrLoad(dir, opts, policy, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM, err := CACert(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		t.Fatal("CA cert is not PEM")
+	}
+	verify := func(cfg *tls.Config) {
+		t.Helper()
+		leaf, err := parseLeaf(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "captainslog.home.arpa"}); err != nil {
+			t.Errorf("leaf doesn't chain to the local CA: %v", err)
+		}
+	}
+	verify(cfg)
+	if info, err := os.Stat(filepath.Join(dir, caKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("CA key: %v, %v", info, err)
+	}
+
+	// A regenerated cert comes from the same CA: nothing to re-trust.
+	opts.Hostnames = append(opts.Hostnames, "mic.home.arpa")
+	cfg, err = GenerateOrLoad(dir, opts, policy, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(cfg)
+	if len(rotations) != 1 || rotations[0].Retrust {
+		t.Errorf("rotations = %+v, want one without retrust", rotations)
+	}
+	again, err := CACert(dir)
+	if err != nil || string(again) != string(caPEM) {
+		t.Error("the CA changed on renewal")
+	}
+
+	// Without the CA that issued it, the cert is reissued from a new one.
+	os.Remove(filepath.Join(dir, CAFile))
+	os.Remove(filepath.Join(dir, caKeyFile))
+	if _, err := GenerateOrLoad(dir, opts, policy, logger); err != nil {
+		t.Fatal(err)
+	}
+	if len(rotations) != 2 || rotations[1].Reason != "CA changed" || !rotations[1].Retrust {
+		t.Errorf("rotations = %+v, want a CA change needing retrust", rotations)
+	}
+}
+
+func TestGenerateOrLoadKeepsSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy, _ := ParsePolicy("", "", "", "", "ecdsa")
+	first, err := GenerateOrLoad(dir, Options{}, policy, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Turning the CA on doesn't replace a cert devices already trust.
+	second, err := GenerateOrLoad(dir, Options{LocalCA: true}, policy, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Fingerprint(first.Certificates[0].Certificate[0]) != Fingerprint(second.Certificates[0].Certificate[0]) {
+		t.Error("a valid self-signed cert was replaced")
+	}
+	if _, err := CACert(dir); !os.IsNotExist(err) {
+		t.Errorf("CACert = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,65 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Client certificate modes for ConfigureClientAuth.
+const (
+	ClientAuthOff      = "off"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// ConfigureClientAuth enables mTLS on cfg. caFile is a PEM bundle of the
+// CAs allowed to issue client certificates.
+//
+// In "optional" mode a client may present a certificate; if it verifies
+// against the CA it counts as authenticated (see Verified) and browsers
+// without one fall back to the bearer token. In "require" mode the
+// handshake fails without a valid certificate, so nothing else on the
+// listener is reachable.
+func ConfigureClientAuth(cfg *tls.Config, caFile, mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", ClientAuthOff:
+		if caFile != "" {
+			return fmt.Errorf("client CA %s is set but client auth is off — set the mode to %s or %s", caFile, ClientAuthOptional, ClientAuthRequire)
+		}
+		return nil
+	case ClientAuthOptional:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("unknown client auth mode %q (want %s, %s or %s)", mode, ClientAuthOff, ClientAuthOptional, ClientAuthRequire)
+	}
+	if caFile == "" {
+		return fmt.Errorf("client auth %q needs a client CA file", mode)
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("client CA %s contains no PEM certificates", caFile)
+	}
+	cfg.ClientCAs = pool
+	return nil
+}
+
+// Verified returns the verified client certificate of r, or nil if the
+// request didn't come over TLS with a certificate that chained to the
+// client CA.
+func Verified(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
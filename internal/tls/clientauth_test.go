@@ -0,0 +1,110 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCA returns a CA certificate, its key and a client certificate it issued.
+func newCA(t *testing.T) (*x509.Certificate, tls.Certificate) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ingest-bot"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+}
+
+func TestConfigureClientAuthErrors(t *testing.T) {
+	if err := ConfigureClientAuth(&tls.Config{}, "", "off"); err != nil {
+		t.Errorf("off without CA: %v", err)
+	}
+	if err := ConfigureClientAuth(&tls.Config{}, "/ca.pem", "off"); err == nil {
+		t.Error("a CA with client auth off should be rejected as a likely typo")
+	}
+	if err := ConfigureClientAuth(&tls.Config{}, "", "require"); err == nil {
+		t.Error("require without a CA should fail")
+	}
+	if err := ConfigureClientAuth(&tls.Config{}, "/ca.pem", "sometimes"); err == nil {
+		t.Error("unknown mode should fail")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("not pem"), 0600)
+	if err := ConfigureClientAuth(&tls.Config{}, empty, "optional"); err == nil {
+		t.Error("CA file without certificates should fail")
+	}
+}
+
+func TestClientAuthOptional(t *testing.T) {
+	ca, clientCert := newCA(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c := Verified(r); c != nil {
+			w.Write([]byte(c.Subject.CommonName))
+		}
+	}))
+	srv.TLS = &tls.Config{}
+	if err := ConfigureClientAuth(srv.TLS, caFile, "optional"); err != nil {
+		t.Fatal(err)
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(certs ...tls.Certificate) string {
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		tr.TLSClientConfig.Certificates = certs
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		buf := make([]byte, 64)
+		n, _ := resp.Body.Read(buf)
+		return string(buf[:n])
+	}
+	if got := get(clientCert); got != "ingest-bot" {
+		t.Errorf("with client cert, Verified CN = %q", got)
+	}
+	if got := get(); got != "" {
+		t.Errorf("without client cert, Verified CN = %q, want none", got)
+	}
+}
@@ -0,0 +1,157 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicyDefaults(t *testing.T) {
+	p, err := ParsePolicy("", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != PolicyDefault || p.MinVersion != tls.VersionTLS12 || p.KeyType != KeyECDSA {
+		t.Errorf("policy = %+v", p)
+	}
+	if p.CipherSuites != nil || p.Curves != nil {
+		t.Error("default policy should leave ciphers and curves to Go")
+	}
+}
+
+func TestParsePolicyFIPS(t *testing.T) {
+	p, err := ParsePolicy("FIPS", "1.2", "", "", "rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.CipherSuites) != len(fipsCiphers) || len(p.Curves) != len(fipsCurves) || p.KeyType != KeyRSA {
+		t.Errorf("policy = %+v", p)
+	}
+	if _, err := ParsePolicy("fips", "", "", "X25519", ""); err == nil {
+		t.Error("X25519 should be rejected under fips")
+	}
+	if _, err := ParsePolicy("fips", "", "TLS_CHACHA20_POLY1305_SHA256", "", ""); err == nil {
+		t.Error("ChaCha20 should be rejected under fips")
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, tc := range [][5]string{
+		{"strict", "", "", "", ""},
+		{"", "1.1", "", "", ""},
+		{"", "", "TLS_RSA_WITH_RC4_128_SHA", "", ""},
+		{"", "", "", "P999", ""},
+		{"", "", "", "", "ed25519"},
+	} {
+		if _, err := ParsePolicy(tc[0], tc[1], tc[2], tc[3], tc[4]); err == nil {
+			t.Errorf("ParsePolicy%q should fail", tc)
+		}
+	}
+}
+
+func TestGenerateOrLoadKeyType(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ec, _ := ParsePolicy("fips", "1.3", "", "P-256", "ecdsa")
+	cfg, err := GenerateOrLoad(dir, Options{}, ec, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MinVersion != tls.VersionTLS13 || len(cfg.CurvePreferences) != 1 {
+		t.Errorf("policy not applied: min=%x curves=%v", cfg.MinVersion, cfg.CurvePreferences)
+	}
+
+	rsaPolicy, _ := ParsePolicy("", "", "", "", "rsa")
+	cfg, err = GenerateOrLoad(dir, Options{}, rsaPolicy, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := parseLeaf(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keyMatches(leaf, rsaPolicy) {
+		t.Errorf("switching key type should regenerate the cert, got %T", leaf.PublicKey)
+	}
+}
+
+func TestGenerateOrLoadRefreshSANs(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy, _ := ParsePolicy("", "", "", "", "ecdsa")
+	var rotations []Rotation
+	opts := Options{
+		Hostnames:   []string{"captainslog.home.arpa"},
+		RefreshSANs: true,
+		OnRotate:    func(r Rotation) { rotations = append(rotations, r) },
+	}
+	if _, err := GenerateOrLoad(dir, opts, policy, logger); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing to replace the first time, nor when nothing changed.
+	if _, err := GenerateOrLoad(dir, opts, policy, logger); err != nil {
+		t.Fatal(err)
+	}
+	if len(rotations) != 0 {
+		t.Fatalf("rotations = %+v, want none", rotations)
+	}
+
+	// A name the cert lacks is left alone without RefreshSANs...
+	opts.Hostnames = append(opts.Hostnames, "mic.home.arpa")
+	opts.RefreshSANs = false
+	if _, err := GenerateOrLoad(dir, opts, policy, logger); err != nil {
+		t.Fatal(err)
+	}
+	if len(rotations) != 0 {
+		t.Fatalf("rotated without RefreshSANs: %+v", rotations)
+	}
+
+	// ...and regenerates the cert with it.
+	opts.RefreshSANs = true
+	cfg, err := GenerateOrLoad(dir, opts, policy, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotations) != 1 {
+		t.Fatalf("rotations = %+v, want one", rotations)
+	}
+	rot := rotations[0]
+	if rot.Reason != "missing SANs" || len(rot.Missing) != 1 || rot.Missing[0] != "mic.home.arpa" {
+		t.Errorf("rotation = %+v", rot)
+	}
+	if rot.Fingerprint == "" || rot.PreviousFingerprint == "" || rot.Fingerprint == rot.PreviousFingerprint {
+		t.Errorf("fingerprints %q → %q", rot.PreviousFingerprint, rot.Fingerprint)
+	}
+	leaf, err := parseLeaf(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("mic.home.arpa"); err != nil {
+		t.Error(err)
+	}
+	if Fingerprint(cfg.Certificates[0].Certificate[0]) != rot.Fingerprint {
+		t.Error("rotation fingerprint is not the served cert's")
+	}
+}
+
+func TestMissingSANs(t *testing.T) {
+	leaf := &x509.Certificate{
+		DNSNames:    []string{"localhost", "captainslog.local"},
+		IPAddresses: []net.IP{net.ParseIP("192.168.1.10")},
+	}
+	ips := []net.IP{net.ParseIP("192.168.1.10"), net.ParseIP("10.0.0.7"), net.ParseIP("fe80::1")}
+	got := missingSANs(leaf, []string{"captainslog.local", "new.home.arpa", ""}, ips)
+	want := []string{"new.home.arpa", "10.0.0.7"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("missingSANs = %v, want %v", got, want)
+	}
+}
+
+func parseLeaf(cfg *tls.Config) (*x509.Certificate, error) {
+	return x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+}
@@ -4,12 +4,16 @@
 package tls
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"log/slog"
@@ -17,46 +21,99 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// Options are what GenerateOrLoad needs besides the crypto policy.
+type Options struct {
+	Hostnames []string // DNS names besides localhost
+
+	// RefreshSANs regenerates an existing certificate that doesn't name
+	// all of Hostnames and every current LAN IP. The cert bakes in the
+	// IPs it was made with, so after a DHCP change browsers reject it for
+	// the new address.
+	RefreshSANs bool
+
+	// LocalCA issues the certificate from a local CA, created once in the
+	// cert dir, instead of signing it with its own key. Devices that
+	// install the CA trust every certificate it issues, so a renewed or
+	// regenerated one needs nothing done. An existing self-signed
+	// certificate is kept until it needs replacing anyway.
+	LocalCA bool
+
+	// OnRotate, if set, is called when an existing certificate is
+	// replaced, so the change can be announced: browsers that trusted the
+	// old one have to be told to trust the new one.
+	OnRotate func(Rotation)
+}
+
+// Rotation describes a certificate that GenerateOrLoad replaced.
+type Rotation struct {
+	Reason              string   // "expiring", "invalid", "key type", "missing SANs" or "CA changed"
+	Missing             []string // names and IPs the old cert lacked, for "missing SANs"
+	PreviousFingerprint string   // SHA-256 of the old cert, "" if it couldn't be read
+	Fingerprint         string   // SHA-256 of the new cert
+	Expires             time.Time
+	Retrust             bool // devices must trust the new cert: no CA they already trust issued it
+}
+
 // GenerateOrLoad creates or loads a self-signed TLS certificate.
 // Certs are stored in certDir for persistence across restarts.
-// The certificate covers localhost, the provided hostnames, and all
-// local network IPs.
-func GenerateOrLoad(certDir string, hostnames []string, logger *slog.Logger) (*tls.Config, error) {
+// The certificate covers localhost, opts.Hostnames, and all local network
+// IPs. Its key type follows policy, and the returned config has the
+// policy applied.
+func GenerateOrLoad(certDir string, opts Options, policy Policy, logger *slog.Logger) (*tls.Config, error) {
 	certFile := filepath.Join(certDir, "captainslog.crt")
 	keyFile := filepath.Join(certDir, "captainslog.key")
+	hostnames := opts.Hostnames
 
 	// Check if cert already exists and is valid
+	var rotation *Rotation
 	if _, err := os.Stat(certFile); err == nil {
 		if _, err := os.Stat(keyFile); err == nil {
+			rotation = &Rotation{Reason: "invalid"}
 			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 			if err == nil {
-				// Check expiry
+				rotation.PreviousFingerprint = Fingerprint(cert.Certificate[0])
 				leaf, err := x509.ParseCertificate(cert.Certificate[0])
-				if err == nil && time.Now().Before(leaf.NotAfter.Add(-24*time.Hour)) {
-					logger.Info("loaded existing TLS certificate", "expires", leaf.NotAfter)
-					return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+				var missing []string
+				if err == nil && opts.RefreshSANs {
+					missing = missingSANs(leaf, hostnames, localIPs())
+				}
+				switch {
+				case err != nil:
+				case !time.Now().Before(leaf.NotAfter.Add(-24 * time.Hour)):
+					rotation.Reason = "expiring"
+				// WHY check the key type? Switching CAPTAINSLOG_TLS_KEY_TYPE must
+				// take effect on the next start, not when the old cert expires.
+				case !keyMatches(leaf, policy):
+					rotation.Reason = "key type"
+				case len(missing) > 0:
+					rotation.Reason = "missing SANs"
+					rotation.Missing = missing
+				case opts.LocalCA && issuedByOtherCA(leaf, certDir):
+					rotation.Reason = "CA changed"
+				default:
+					logger.Info("loaded existing TLS certificate", "expires", leaf.NotAfter, "key_type", policy.KeyType)
+					return serverConfig(cert, policy), nil
 				}
 			}
-			logger.Info("existing certificate expired or invalid, regenerating")
+			logger.Info("regenerating TLS certificate", "reason", rotation.Reason, "missing", rotation.Missing)
 		}
 	}
 
-	// Generate new self-signed certificate
 	if err := os.MkdirAll(certDir, 0700); err != nil {
 		return nil, fmt.Errorf("create cert dir: %w", err)
 	}
-
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	privateKey, keyUsage, err := newKey(policy)
 	if err != nil {
-		return nil, fmt.Errorf("generate key: %w", err)
+		return nil, err
 	}
 
-	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	serialNumber, err := newSerial()
 	if err != nil {
-		return nil, fmt.Errorf("generate serial: %w", err)
+		return nil, err
 	}
 
 	template := &x509.Certificate{
@@ -67,7 +124,7 @@ func GenerateOrLoad(certDir string, hostnames []string, logger *slog.Logger) (*t
 		},
 		NotBefore:             time.Now(),
 		NotAfter:              time.Now().Add(365 * 24 * time.Hour), // 1 year
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		KeyUsage:              keyUsage,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 	}
@@ -77,56 +134,170 @@ func GenerateOrLoad(certDir string, hostnames []string, logger *slog.Logger) (*t
 	template.DNSNames = append(template.DNSNames, hostnames...)
 	template.IPAddresses = append(template.IPAddresses, net.ParseIP("127.0.0.1"), net.ParseIP("::1"))
 
-	// Add all local IPs
+	template.IPAddresses = append(template.IPAddresses, localIPs()...)
+
+	// Self-signed unless there is a local CA to issue it.
+	parent, signer := template, privateKey
+	retrust := true
+	if opts.LocalCA {
+		ca, caKey, created, err := loadOrCreateCA(certDir, policy, logger)
+		if err != nil {
+			return nil, err
+		}
+		template.Subject.Organization = []string{"Captain's Log"}
+		parent, signer = ca, caKey
+		retrust = created
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, privateKey.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+	if err := writePair(certFile, keyFile, certDER, privateKey); err != nil {
+		return nil, err
+	}
+
+	logger.Info("generated new TLS certificate",
+		"cert", certFile,
+		"issuer", parent.Subject.CommonName,
+		"hostnames", template.DNSNames,
+		"expires", template.NotAfter,
+		"key_type", policy.KeyType,
+	)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load generated cert: %w", err)
+	}
+
+	if rotation != nil {
+		rotation.Fingerprint = Fingerprint(certDER)
+		rotation.Expires = template.NotAfter
+		rotation.Retrust = retrust
+		if retrust {
+			logger.Warn("TLS certificate changed — browsers and clients that trusted the old one must trust the new one",
+				"reason", rotation.Reason, "fingerprint", rotation.Fingerprint, "previous", rotation.PreviousFingerprint)
+		} else {
+			logger.Info("TLS certificate renewed by the local CA — devices that trust the CA need do nothing",
+				"reason", rotation.Reason, "fingerprint", rotation.Fingerprint)
+		}
+		if opts.OnRotate != nil {
+			opts.OnRotate(*rotation)
+		}
+	}
+	return serverConfig(cert, policy), nil
+}
+
+// Fingerprint is the SHA-256 of a DER certificate, as colon-separated hex
+// the way browsers show it.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	h := strings.ToUpper(hex.EncodeToString(sum[:]))
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(h); i += 2 {
+		parts = append(parts, h[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// localIPs are the addresses of this machine's interfaces, loopback aside.
+func localIPs() []net.IP {
+	var ips []net.IP
 	addrs, _ := net.InterfaceAddrs()
 	for _, addr := range addrs {
 		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
-			template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			ips = append(ips, ipNet.IP)
 		}
 	}
+	return ips
+}
 
-	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("create certificate: %w", err)
+// missingSANs lists the hostnames and IPs leaf isn't valid for. Names the
+// cert has but no longer needs (an old lease) don't matter.
+func missingSANs(leaf *x509.Certificate, hostnames []string, ips []net.IP) []string {
+	var missing []string
+	for _, h := range hostnames {
+		if h != "" && leaf.VerifyHostname(h) != nil {
+			missing = append(missing, h)
+		}
 	}
+	for _, ip := range ips {
+		// Link-local IPv6 addresses need a zone to be dialled, so nobody
+		// reaches the server by them and browsers never check them.
+		if ip.IsLinkLocalUnicast() && ip.To4() == nil {
+			continue
+		}
+		if leaf.VerifyHostname(ip.String()) != nil {
+			missing = append(missing, ip.String())
+		}
+	}
+	return missing
+}
 
-	// Write cert
-	certOut, err := os.Create(certFile)
+// newKey makes a key of the type policy asks for, with the key usage a
+// certificate for it needs.
+func newKey(policy Policy) (crypto.Signer, x509.KeyUsage, error) {
+	var privateKey crypto.Signer
+	var err error
+	keyUsage := x509.KeyUsageDigitalSignature
+	if policy.KeyType == KeyRSA {
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaBits)
+		// RSA key exchange (non-ECDHE suites) encrypts with the cert key.
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	} else {
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("write cert: %w", err)
+		return nil, 0, fmt.Errorf("generate key: %w", err)
 	}
-	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
-		certOut.Close()
-		return nil, fmt.Errorf("encode cert PEM: %w", err)
+	return privateKey, keyUsage, nil
+}
+
+func newSerial() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial: %w", err)
 	}
-	certOut.Close()
+	return serialNumber, nil
+}
 
-	// Write key
-	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+// writePair saves a certificate and its key as PEM, the key readable only
+// by the owner.
+func writePair(certFile, keyFile string, certDER []byte, privateKey crypto.Signer) error {
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("write key: %w", err)
+		return fmt.Errorf("marshal private key: %w", err)
 	}
-	keyBytes, err := x509.MarshalECPrivateKey(privateKey)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("write cert: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file; a key must never be
+	// left readable by others.
+	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		keyOut.Close()
-		return nil, fmt.Errorf("marshal EC private key: %w", err)
+		return fmt.Errorf("write key: %w", err)
 	}
-	if err := pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}); err != nil {
-		keyOut.Close()
-		return nil, fmt.Errorf("encode key PEM: %w", err)
+	defer keyOut.Close()
+	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}); err != nil {
+		return fmt.Errorf("encode key PEM: %w", err)
 	}
-	keyOut.Close()
+	return keyOut.Close()
+}
 
-	logger.Info("generated new self-signed TLS certificate",
-		"cert", certFile,
-		"hostnames", template.DNSNames,
-		"expires", template.NotAfter,
-	)
+func serverConfig(cert tls.Certificate, policy Policy) *tls.Config {
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	policy.Apply(cfg)
+	return cfg
+}
 
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return nil, fmt.Errorf("load generated cert: %w", err)
+// keyMatches reports whether leaf's key is the type policy asks for and,
+// for RSA, at least rsaBits long.
+func keyMatches(leaf *x509.Certificate, policy Policy) bool {
+	switch pub := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return policy.KeyType == KeyRSA && pub.N.BitLen() >= rsaBits
+	case *ecdsa.PublicKey:
+		return policy.KeyType == KeyECDSA
 	}
-
-	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	return false
 }
@@ -0,0 +1,257 @@
+// Package tunnel exposes the server on a temporary public URL through
+// cloudflared or ngrok — for letting a remote colleague into a session
+// without touching the router.
+//
+// The tunnel client runs as a child process: `cloudflared tunnel --url`
+// (a free "quick tunnel" on trycloudflare.com, no account needed) or
+// `ngrok http` (needs `ngrok config add-authtoken` once). The public URL is
+// read from the client's log output. WHY exec instead of the providers' Go
+// SDKs? Either SDK would add dozens of modules to a binary with three
+// dependencies, for a feature most installs never turn on.
+//
+// Tunnels are temporary by design: each closes after its TTL, and never
+// outlives the server.
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported providers.
+const (
+	Cloudflared = "cloudflared"
+	Ngrok       = "ngrok"
+)
+
+// ErrUnknownProvider is returned for a provider other than the above.
+var ErrUnknownProvider = errors.New("unknown tunnel provider (want cloudflared or ngrok)")
+
+// startTimeout bounds how long the client may take to print its URL.
+const startTimeout = 30 * time.Second
+
+var (
+	cloudflaredURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+	ngrokURL       = regexp.MustCompile(`"?url"?[=:]"?(https://[^\s"]+)`)
+)
+
+// Options describes a tunnel.
+type Options struct {
+	Provider string        // Cloudflared or Ngrok
+	Target   string        // local server URL, e.g. http://127.0.0.1:8090
+	TTL      time.Duration // close after this long; zero keeps it open until Close
+	Binary   string        // client executable (default: the provider name on $PATH)
+}
+
+// args returns the client command line for o.
+func (o Options) args() ([]string, error) {
+	switch o.Provider {
+	case Cloudflared:
+		args := []string{"tunnel", "--no-autoupdate", "--url", o.Target}
+		if strings.HasPrefix(o.Target, "https://") {
+			// The local certificate is self-signed.
+			args = append(args, "--no-tls-verify")
+		}
+		return args, nil
+	case Ngrok:
+		return []string{"http", o.Target, "--log", "stdout", "--log-format", "logfmt"}, nil
+	}
+	return nil, ErrUnknownProvider
+}
+
+// parseURL extracts the public URL from one line of client output.
+func parseURL(provider, line string) string {
+	switch provider {
+	case Cloudflared:
+		return cloudflaredURL.FindString(line)
+	case Ngrok:
+		if m := ngrokURL.FindStringSubmatch(line); m != nil && strings.Contains(line, "started tunnel") {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// Status describes the current tunnel.
+type Status struct {
+	Active    bool      `json:"active"`
+	Provider  string    `json:"provider,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// Tunnel is one running tunnel client.
+type Tunnel struct {
+	opts    Options
+	cmd     *exec.Cmd
+	url     string
+	started time.Time
+	done    chan struct{} // closed when the client exits
+	timer   *time.Timer
+}
+
+// Start launches the tunnel client and waits for its public URL.
+func Start(ctx context.Context, opts Options, logger *slog.Logger) (*Tunnel, error) {
+	args, err := opts.args()
+	if err != nil {
+		return nil, err
+	}
+	bin := opts.Binary
+	if bin == "" {
+		bin = opts.Provider
+	}
+	cmd := exec.Command(bin, args...)
+	// cloudflared logs to stderr, ngrok (as configured) to stdout; read both.
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	// Don't hang on a grandchild that inherited the pipe.
+	cmd.WaitDelay = 2 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", opts.Provider, err)
+	}
+
+	t := &Tunnel{opts: opts, cmd: cmd, done: make(chan struct{})}
+	found := make(chan string, 1)
+	go func() {
+		sc := bufio.NewScanner(pr)
+		for sc.Scan() {
+			if u := parseURL(opts.Provider, sc.Text()); u != "" {
+				select {
+				case found <- u:
+				default:
+				}
+			}
+		}
+		// Keep draining so the client never blocks on a full pipe.
+		io.Copy(io.Discard, pr)
+	}()
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		logger.Info("tunnel closed", "provider", opts.Provider, "error", err)
+		close(t.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	select {
+	case t.url = <-found:
+	case <-t.done:
+		return nil, fmt.Errorf("%s exited before reporting a public URL", opts.Provider)
+	case <-ctx.Done():
+		t.Close()
+		return nil, fmt.Errorf("%s did not report a public URL: %w", opts.Provider, ctx.Err())
+	}
+	t.started = time.Now()
+	if opts.TTL > 0 {
+		t.timer = time.AfterFunc(opts.TTL, func() {
+			logger.Info("tunnel TTL reached", "provider", opts.Provider, "ttl", opts.TTL)
+			t.Close()
+		})
+	}
+	logger.Info("tunnel open", "provider", opts.Provider, "url", t.url, "ttl", opts.TTL)
+	return t, nil
+}
+
+// URL returns the public URL.
+func (t *Tunnel) URL() string { return t.url }
+
+// Done is closed when the tunnel client has exited.
+func (t *Tunnel) Done() <-chan struct{} { return t.done }
+
+// Status reports the tunnel's state.
+func (t *Tunnel) Status() Status {
+	select {
+	case <-t.done:
+		return Status{}
+	default:
+	}
+	st := Status{Active: true, Provider: t.opts.Provider, URL: t.url, StartedAt: t.started}
+	if t.opts.TTL > 0 {
+		st.ExpiresAt = t.started.Add(t.opts.TTL)
+	}
+	return st
+}
+
+// Close stops the client and waits for it to exit.
+func (t *Tunnel) Close() error {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+	if err := t.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	<-t.done
+	return nil
+}
+
+// Manager holds at most one tunnel, for starting and stopping at runtime.
+type Manager struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu  sync.Mutex
+	cur *Tunnel
+}
+
+// NewManager returns a Manager that starts tunnels with opts.
+func NewManager(opts Options, logger *slog.Logger) *Manager {
+	return &Manager{opts: opts, logger: logger}
+}
+
+// Start opens a tunnel, or returns the status of the one already open.
+func (m *Manager) Start(ctx context.Context) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cur != nil {
+		if st := m.cur.Status(); st.Active {
+			return st, nil
+		}
+	}
+	t, err := Start(ctx, m.opts, m.logger)
+	if err != nil {
+		return Status{}, err
+	}
+	m.cur = t
+	return t.Status(), nil
+}
+
+// Stop closes the open tunnel, if any.
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	t := m.cur
+	m.cur = nil
+	m.mu.Unlock()
+	if t == nil {
+		return nil
+	}
+	return t.Close()
+}
+
+// Status reports the open tunnel, if any. Provider is always set.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var st Status
+	if m.cur != nil {
+		st = m.cur.Status()
+	}
+	st.Provider = m.opts.Provider
+	return st
+}
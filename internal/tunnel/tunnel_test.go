@@ -0,0 +1,133 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// fakeClient writes a shell script that prints output and then sleeps,
+// standing in for cloudflared or ngrok.
+func fakeClient(t *testing.T, output string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "client")
+	script := "#!/bin/sh\ncat <<'EOF' >&2\n" + output + "\nEOF\nexec sleep 30\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		provider, line, want string
+	}{
+		{Cloudflared, "2026-03-01T10:00:00Z INF |  https://brave-otter-lake.trycloudflare.com  |", "https://brave-otter-lake.trycloudflare.com"},
+		{Cloudflared, "2026-03-01T10:00:00Z INF Requesting new quick Tunnel on trycloudflare.com...", ""},
+		{Ngrok, `t=2026-03-01T10:00:00+0000 lvl=info msg="started tunnel" obj=tunnels name=command_line addr=http://127.0.0.1:8090 url=https://a1b2.ngrok-free.app`, "https://a1b2.ngrok-free.app"},
+		{Ngrok, `t=2026-03-01T10:00:00+0000 lvl=info msg="client session established" obj=tunnels.session url=https://connect.ngrok-agent.com`, ""},
+	}
+	for _, tt := range tests {
+		if got := parseURL(tt.provider, tt.line); got != tt.want {
+			t.Errorf("parseURL(%s, %q) = %q, want %q", tt.provider, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args, _ := Options{Provider: Cloudflared, Target: "https://127.0.0.1:8090"}.args()
+	if got := strings.Join(args, " "); got != "tunnel --no-autoupdate --url https://127.0.0.1:8090 --no-tls-verify" {
+		t.Errorf("cloudflared args = %q", got)
+	}
+	if _, err := (Options{Provider: "frp"}).args(); err != ErrUnknownProvider {
+		t.Errorf("unknown provider err = %v", err)
+	}
+}
+
+func TestStartAndClose(t *testing.T) {
+	bin := fakeClient(t, "INF Requesting new quick Tunnel\nINF |  https://brave-otter-lake.trycloudflare.com  |")
+	tn, err := Start(context.Background(), Options{Provider: Cloudflared, Target: "http://127.0.0.1:8090", Binary: bin, TTL: time.Hour}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := tn.Status()
+	if !st.Active || st.URL != "https://brave-otter-lake.trycloudflare.com" || st.ExpiresAt.Sub(st.StartedAt) != time.Hour {
+		t.Errorf("status = %+v", st)
+	}
+	if err := tn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if tn.Status().Active {
+		t.Error("still active after Close")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	bin := fakeClient(t, "https://brave-otter-lake.trycloudflare.com")
+	tn, err := Start(context.Background(), Options{Provider: Cloudflared, Binary: bin, TTL: 50 * time.Millisecond}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("tunnel outlived its TTL")
+	}
+}
+
+func TestStartFailures(t *testing.T) {
+	if _, err := Start(context.Background(), Options{Provider: Cloudflared, Binary: filepath.Join(t.TempDir(), "missing")}, testLogger()); err == nil {
+		t.Error("missing binary: no error")
+	}
+
+	// Exits without a URL (e.g. ngrok without an authtoken).
+	if runtime.GOOS != "windows" {
+		path := filepath.Join(t.TempDir(), "client")
+		os.WriteFile(path, []byte("#!/bin/sh\necho 'ERROR: authentication failed' >&2\nexit 1\n"), 0755)
+		_, err := Start(context.Background(), Options{Provider: Ngrok, Binary: path}, testLogger())
+		if err == nil || !strings.Contains(err.Error(), "exited") {
+			t.Errorf("early exit err = %v", err)
+		}
+	}
+
+	// Never prints a URL.
+	bin := fakeClient(t, "INF still connecting")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Start(ctx, Options{Provider: Cloudflared, Binary: bin}, testLogger()); err == nil {
+		t.Error("no URL: no error")
+	}
+}
+
+func TestManager(t *testing.T) {
+	bin := fakeClient(t, "https://brave-otter-lake.trycloudflare.com")
+	m := NewManager(Options{Provider: Cloudflared, Binary: bin}, testLogger())
+	if st := m.Status(); st.Active || st.Provider != Cloudflared {
+		t.Errorf("initial status = %+v", st)
+	}
+	first, err := m.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := m.Start(context.Background())
+	if !second.StartedAt.Equal(first.StartedAt) {
+		t.Error("second Start opened another tunnel")
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Status().Active {
+		t.Error("active after Stop")
+	}
+}
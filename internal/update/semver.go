@@ -0,0 +1,115 @@
+package update
+
+import (
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version (https://semver.org).
+type version struct {
+	major, minor, patch int
+	pre                 []string // dot-separated pre-release identifiers
+	valid               bool
+}
+
+// parseVersion parses "v1.2.3", "1.2.3-beta.1", "1.2.3+build" and the
+// shorthand "1.2" / "1". Anything else is returned with valid=false.
+func parseVersion(s string) version {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i] // build metadata never affects precedence
+	}
+	var v version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if i == len(s)-1 {
+			return version{}
+		}
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return version{}
+	}
+	nums := [3]int{}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return version{}
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	v.valid = true
+	return v
+}
+
+// Prerelease reports whether s has a pre-release suffix (e.g. "0.3.0-beta.1").
+func Prerelease(s string) bool {
+	v := parseVersion(s)
+	return v.valid && len(v.pre) > 0
+}
+
+// Compare returns -1, 0, or +1 as a is older than, equal to, or newer than b,
+// using semver precedence: 0.10.0 > 0.9.0, and 1.0.0 > 1.0.0-rc.1.
+// Unparseable versions sort before all valid ones so a garbage tag can never
+// be offered as an update.
+func Compare(a, b string) int {
+	va, vb := parseVersion(a), parseVersion(b)
+	switch {
+	case !va.valid && !vb.valid:
+		return strings.Compare(a, b)
+	case !va.valid:
+		return -1
+	case !vb.valid:
+		return 1
+	}
+	for _, d := range [3]int{va.major - vb.major, va.minor - vb.minor, va.patch - vb.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	return comparePre(va.pre, vb.pre)
+}
+
+// comparePre compares pre-release identifiers per semver §11.
+func comparePre(a, b []string) int {
+	// A version without a pre-release has higher precedence.
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return sign(na - nb)
+			}
+		case errA == nil: // numeric identifiers sort before alphanumeric
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
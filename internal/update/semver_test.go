@@ -0,0 +1,43 @@
+package update
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.2.0", "0.2.0", 0},
+		{"v0.2.0", "0.2.0", 0},
+		{"0.3.0", "0.2.0", 1},
+		{"0.2.0", "0.3.0", -1}, // downgrade is not an update
+		{"0.10.0", "0.9.0", 1}, // numeric, not lexical
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"nightly", "0.1.0", -1},
+		{"0.1.0", "garbage", 1},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPrerelease(t *testing.T) {
+	for v, want := range map[string]bool{
+		"0.3.0":        false,
+		"0.3.0-beta.1": true,
+		"v1.0.0-rc1":   true,
+		"garbage-1":    false,
+	} {
+		if got := Prerelease(v); got != want {
+			t.Errorf("Prerelease(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
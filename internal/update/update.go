@@ -0,0 +1,364 @@
+// Package update checks GitHub for new Captain's Log releases in the
+// background and caches the result on disk.
+//
+// WHY background + persisted? The old /api/version handler called GitHub on
+// demand and cached in memory only — every restart (and every hour) cost a
+// request, a slow or offline network stalled the page load, and GitHub's
+// 60 req/hour anonymous limit was shared by every install behind one NAT.
+// The checker runs off the request path, remembers its result and ETag
+// across restarts, and backs off exponentially while offline.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRepo is the GitHub repository checked for releases.
+const DefaultRepo = "ryan-winkler/captainslog-whisper"
+
+// Release channels.
+const (
+	ChannelStable = "stable" // full releases only
+	ChannelBeta   = "beta"   // full releases and pre-releases
+)
+
+const (
+	defaultAPIBase  = "https://api.github.com"
+	defaultInterval = 6 * time.Hour
+	minBackoff      = time.Minute
+	maxNotesBytes   = 64 << 10 // release notes are markdown; cap what we keep
+	maxReleases     = 10       // newest releases kept for the changelog
+)
+
+// ValidChannel reports whether ch is a known release channel.
+func ValidChannel(ch string) bool {
+	return ch == ChannelStable || ch == ChannelBeta
+}
+
+// Release describes a published GitHub release.
+type Release struct {
+	Version     string    `json:"version"` // tag without the leading "v"
+	Tag         string    `json:"tag"`
+	URL         string    `json:"url"`
+	Notes       string    `json:"notes,omitempty"` // markdown release body
+	PublishedAt time.Time `json:"published_at"`
+	Prerelease  bool      `json:"prerelease"`
+}
+
+// Status is a point-in-time view of the checker, for /api/version.
+type Status struct {
+	Current         string    `json:"current"`
+	Channel         string    `json:"channel"`
+	Latest          *Release  `json:"latest,omitempty"`
+	Changelog       []Release `json:"changelog,omitempty"` // releases newer than Current, newest first
+	UpdateAvailable bool      `json:"update_available"`
+	CheckedAt       time.Time `json:"checked_at,omitempty"`
+	NextCheck       time.Time `json:"next_check,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+	Disabled        bool      `json:"disabled"`
+}
+
+// cache is the on-disk state. It survives restarts so a fresh process
+// doesn't immediately re-query GitHub.
+type cache struct {
+	Channel   string    `json:"channel"`
+	Releases  []Release `json:"releases,omitempty"` // newest first
+	ETag      string    `json:"etag,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	NextCheck time.Time `json:"next_check"`
+	Failures  int       `json:"failures"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Config configures a Checker.
+type Config struct {
+	Current   string        // running version, e.g. "0.2.0"
+	Repo      string        // owner/name (default: DefaultRepo)
+	Channel   string        // ChannelStable (default) or ChannelBeta
+	CachePath string        // JSON cache file; empty disables persistence
+	Interval  time.Duration // time between successful checks (default: 6h)
+	Disabled  bool          // air-gapped installs: never contact GitHub
+	APIBase   string        // GitHub API base URL (default: https://api.github.com)
+	Client    *http.Client  // default: 10s timeout
+	Logger    *slog.Logger
+}
+
+// Checker periodically fetches the latest release.
+type Checker struct {
+	cfg Config
+
+	mu    sync.RWMutex
+	state cache
+}
+
+// New creates a Checker and loads any persisted cache.
+func New(cfg Config) *Checker {
+	if cfg.Repo == "" {
+		cfg.Repo = DefaultRepo
+	}
+	if !ValidChannel(cfg.Channel) {
+		cfg.Channel = ChannelStable
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.APIBase == "" {
+		cfg.APIBase = defaultAPIBase
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	c := &Checker{cfg: cfg}
+	c.load()
+	return c
+}
+
+// Start runs the check loop until ctx is cancelled. It checks immediately
+// if the cached result is due, then sleeps until the next scheduled check.
+// When the checker is disabled, Start returns immediately.
+func (c *Checker) Start(ctx context.Context) {
+	if c.cfg.Disabled {
+		c.cfg.Logger.Info("update check disabled")
+		return
+	}
+	for {
+		c.mu.RLock()
+		wait := time.Until(c.state.NextCheck)
+		c.mu.RUnlock()
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if err := c.Check(ctx); err != nil && ctx.Err() == nil {
+			c.cfg.Logger.Debug("update check failed", "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Check queries GitHub once, updates the cache, and schedules the next check:
+// after Interval on success, or after an exponential backoff on failure.
+func (c *Checker) Check(ctx context.Context) error {
+	if c.cfg.Disabled {
+		return errors.New("update check disabled")
+	}
+
+	c.mu.RLock()
+	etag := c.state.ETag
+	c.mu.RUnlock()
+
+	releases, newETag, err := c.fetch(ctx, etag)
+	now := time.Now()
+
+	c.mu.Lock()
+	if err != nil {
+		c.state.Failures++
+		c.state.LastError = err.Error()
+		c.state.NextCheck = now.Add(backoff(c.state.Failures, c.cfg.Interval))
+	} else {
+		if releases != nil { // nil means 304 Not Modified — keep cached releases
+			c.state.Releases = releases
+			c.state.ETag = newETag
+		}
+		c.state.CheckedAt = now
+		c.state.Failures = 0
+		c.state.LastError = ""
+		c.state.NextCheck = now.Add(c.cfg.Interval)
+	}
+	c.state.Channel = c.cfg.Channel
+	snapshot := c.state
+	c.mu.Unlock()
+
+	c.save(snapshot)
+	return err
+}
+
+// Status returns the current update status.
+func (c *Checker) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := Status{
+		Current:   c.cfg.Current,
+		Channel:   c.cfg.Channel,
+		CheckedAt: c.state.CheckedAt,
+		LastError: c.state.LastError,
+		Disabled:  c.cfg.Disabled,
+	}
+	if !c.cfg.Disabled {
+		s.NextCheck = c.state.NextCheck
+	}
+	if len(c.state.Releases) > 0 {
+		latest := c.state.Releases[0]
+		s.Latest = &latest
+		// WHY Compare, not !=? A dev build or a stable user who tried a beta
+		// is ahead of the latest release — that's not an update.
+		s.UpdateAvailable = Compare(latest.Version, c.cfg.Current) > 0
+	}
+	for _, r := range c.state.Releases {
+		if Compare(r.Version, c.cfg.Current) <= 0 {
+			break
+		}
+		s.Changelog = append(s.Changelog, r)
+	}
+	return s
+}
+
+// fetch requests recent releases and returns those on the configured
+// channel, newest first. A nil slice with nil error means the server answered
+// 304 Not Modified for the given ETag.
+//
+// WHY the list endpoint? /releases/latest never returns pre-releases, and
+// the changelog needs every release between the running version and latest.
+func (c *Checker) fetch(ctx context.Context, etag string) ([]Release, string, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=30", strings.TrimRight(c.cfg.APIBase, "/"), c.cfg.Repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "captainslog/"+c.cfg.Current)
+	// WHY ETag? Conditional requests that return 304 don't count against
+	// GitHub's anonymous rate limit.
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		io.Copy(io.Discard, resp.Body)
+		return nil, etag, nil
+	default:
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+		return nil, "", fmt.Errorf("github returned %d", resp.StatusCode)
+	}
+
+	var list []struct {
+		TagName     string    `json:"tag_name"`
+		HTMLURL     string    `json:"html_url"`
+		Body        string    `json:"body"`
+		PublishedAt time.Time `json:"published_at"`
+		Prerelease  bool      `json:"prerelease"`
+		Draft       bool      `json:"draft"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("decode releases: %w", err)
+	}
+
+	releases := []Release{}
+	for _, gh := range list {
+		if gh.Draft || gh.TagName == "" {
+			continue
+		}
+		v := strings.TrimPrefix(gh.TagName, "v")
+		pre := gh.Prerelease || Prerelease(v)
+		if pre && c.cfg.Channel != ChannelBeta {
+			continue
+		}
+		notes := gh.Body
+		if len(notes) > maxNotesBytes {
+			notes = notes[:maxNotesBytes]
+		}
+		releases = append(releases, Release{
+			Version:     v,
+			Tag:         gh.TagName,
+			URL:         gh.HTMLURL,
+			Notes:       notes,
+			PublishedAt: gh.PublishedAt,
+			Prerelease:  pre,
+		})
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return Compare(releases[i].Version, releases[j].Version) > 0
+	})
+	if len(releases) > maxReleases {
+		releases = releases[:maxReleases]
+	}
+	return releases, resp.Header.Get("ETag"), nil
+}
+
+// backoff returns the delay after n consecutive failures: 1m, 2m, 4m, ...
+// capped at max so an offline install still checks at the normal cadence.
+func backoff(n int, max time.Duration) time.Duration {
+	d := minBackoff
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (c *Checker) load() {
+	if c.cfg.CachePath == "" {
+		return
+	}
+	data, err := os.ReadFile(c.cfg.CachePath)
+	if err != nil {
+		return
+	}
+	var state cache
+	if err := json.Unmarshal(data, &state); err != nil {
+		c.cfg.Logger.Warn("ignoring corrupt update cache", "path", c.cfg.CachePath, "error", err)
+		return
+	}
+	// A cache from another channel (or an older cache format) lists the
+	// wrong releases — discard it so Start checks straight away.
+	if state.Channel != c.cfg.Channel {
+		return
+	}
+	c.state = state
+}
+
+// save writes the cache atomically (temp file + rename) so a crash mid-write
+// never leaves a truncated file behind.
+func (c *Checker) save(state cache) {
+	if c.cfg.CachePath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := c.cfg.CachePath + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(c.cfg.CachePath), 0755); err != nil {
+		return
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		c.cfg.Logger.Warn("failed to write update cache", "path", tmp, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, c.cfg.CachePath); err != nil {
+		c.cfg.Logger.Warn("failed to write update cache", "path", c.cfg.CachePath, "error", err)
+	}
+}
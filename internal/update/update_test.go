@@ -0,0 +1,225 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGitHub serves the given releases as a list and honours If-None-Match.
+func fakeGitHub(t *testing.T, calls *atomic.Int32, releases ...ghRelease) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/repos/"+DefaultRepo+"/releases" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		var list []map[string]any
+		for _, rel := range releases {
+			list = append(list, map[string]any{
+				"tag_name":     rel.tag,
+				"html_url":     "https://example.com/" + rel.tag,
+				"body":         "## Changes in " + rel.tag,
+				"published_at": "2026-01-02T03:04:05Z",
+				"prerelease":   rel.prerelease,
+				"draft":        rel.draft,
+			})
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type ghRelease struct {
+	tag        string
+	prerelease bool
+	draft      bool
+}
+
+func TestCheckFetchesRelease(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls, ghRelease{tag: "v0.3.0"})
+
+	c := New(Config{Current: "0.2.0", APIBase: srv.URL})
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	s := c.Status()
+	if s.Latest == nil || s.Latest.Version != "0.3.0" || s.Latest.Notes == "" {
+		t.Fatalf("latest = %+v", s.Latest)
+	}
+	if !s.UpdateAvailable {
+		t.Error("UpdateAvailable should be true")
+	}
+	if s.CheckedAt.IsZero() || s.NextCheck.Before(time.Now()) {
+		t.Errorf("checked_at = %v, next_check = %v", s.CheckedAt, s.NextCheck)
+	}
+}
+
+func TestCheckNotModifiedKeepsRelease(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls, ghRelease{tag: "v0.3.0"})
+
+	c := New(Config{Current: "0.2.0", APIBase: srv.URL})
+	c.Check(context.Background())
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("second Check: %v", err)
+	}
+	if s := c.Status(); s.Latest == nil || s.Latest.Version != "0.3.0" {
+		t.Errorf("release lost after 304: %+v", s.Latest)
+	}
+}
+
+func TestCachePersistsAcrossRestarts(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls, ghRelease{tag: "v0.3.0"})
+	path := filepath.Join(t.TempDir(), "update.json")
+
+	New(Config{Current: "0.2.0", APIBase: srv.URL, CachePath: path}).Check(context.Background())
+
+	// A new process sees the cached release without calling GitHub.
+	c := New(Config{Current: "0.2.0", APIBase: srv.URL, CachePath: path})
+	if s := c.Status(); s.Latest == nil || s.Latest.Version != "0.3.0" {
+		t.Fatalf("cached latest = %+v", s.Latest)
+	}
+
+	// Start must not re-check while the cache is fresh.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c.Start(ctx)
+	if calls.Load() != 1 {
+		t.Errorf("GitHub called %d times, want 1", calls.Load())
+	}
+}
+
+func TestCheckFailureBacksOff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	c := New(Config{Current: "0.2.0", APIBase: srv.URL})
+	for i := 0; i < 3; i++ {
+		if err := c.Check(context.Background()); err == nil {
+			t.Fatal("Check should fail on 403")
+		}
+	}
+	s := c.Status()
+	if s.LastError == "" {
+		t.Error("LastError should be set")
+	}
+	// Third failure: 4 minutes.
+	if d := time.Until(s.NextCheck); d < 3*time.Minute || d > 5*time.Minute {
+		t.Errorf("next check in %v, want ~4m", d)
+	}
+}
+
+func TestBackoffCapped(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.n, time.Hour); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDisabledNeverCallsGitHub(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls, ghRelease{tag: "v0.3.0"})
+
+	c := New(Config{Current: "0.2.0", APIBase: srv.URL, Disabled: true})
+	c.Start(context.Background()) // returns immediately
+	if err := c.Check(context.Background()); err == nil {
+		t.Error("Check should refuse when disabled")
+	}
+	if calls.Load() != 0 {
+		t.Errorf("GitHub called %d times, want 0", calls.Load())
+	}
+	if !c.Status().Disabled {
+		t.Error("Status should report disabled")
+	}
+}
+
+func TestStableChannelSkipsPrereleases(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls,
+		ghRelease{tag: "v0.4.0-beta.1", prerelease: true},
+		ghRelease{tag: "v0.5.0", draft: true},
+		ghRelease{tag: "v0.3.0"},
+		ghRelease{tag: "v0.2.1"},
+		ghRelease{tag: "v0.2.0"},
+	)
+
+	c := New(Config{Current: "0.2.0", APIBase: srv.URL})
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	s := c.Status()
+	if s.Channel != ChannelStable || s.Latest == nil || s.Latest.Version != "0.3.0" {
+		t.Fatalf("channel = %q, latest = %+v", s.Channel, s.Latest)
+	}
+	if len(s.Changelog) != 2 || s.Changelog[0].Version != "0.3.0" || s.Changelog[1].Version != "0.2.1" {
+		t.Errorf("changelog = %+v, want 0.3.0, 0.2.1", s.Changelog)
+	}
+}
+
+func TestBetaChannelIncludesPrereleases(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls,
+		ghRelease{tag: "v0.3.0"},
+		ghRelease{tag: "v0.4.0-beta.1", prerelease: true},
+	)
+
+	c := New(Config{Current: "0.3.0", Channel: ChannelBeta, APIBase: srv.URL})
+	c.Check(context.Background())
+	s := c.Status()
+	if s.Latest == nil || s.Latest.Version != "0.4.0-beta.1" || !s.Latest.Prerelease {
+		t.Fatalf("latest = %+v, want 0.4.0-beta.1", s.Latest)
+	}
+	if !s.UpdateAvailable {
+		t.Error("beta should be offered as an update on the beta channel")
+	}
+}
+
+func TestNewerLocalVersionIsNotAnUpdate(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls, ghRelease{tag: "v0.3.0"})
+
+	c := New(Config{Current: "0.4.0-beta.1", APIBase: srv.URL})
+	c.Check(context.Background())
+	if s := c.Status(); s.UpdateAvailable || len(s.Changelog) != 0 {
+		t.Errorf("update_available = %v, changelog = %v; a downgrade is not an update", s.UpdateAvailable, s.Changelog)
+	}
+}
+
+func TestChannelChangeDiscardsCache(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeGitHub(t, &calls, ghRelease{tag: "v0.3.0"})
+	path := filepath.Join(t.TempDir(), "update.json")
+
+	New(Config{Current: "0.2.0", APIBase: srv.URL, CachePath: path}).Check(context.Background())
+
+	c := New(Config{Current: "0.2.0", Channel: ChannelBeta, APIBase: srv.URL, CachePath: path})
+	if s := c.Status(); s.Latest != nil {
+		t.Errorf("beta checker reused stable cache: %+v", s.Latest)
+	}
+}
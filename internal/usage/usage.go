@@ -0,0 +1,299 @@
+// Package usage counts transcription work per caller and per client IP,
+// rolled up by day, so it is easy to see which device is keeping the GPU
+// busy.
+//
+// A caller is whoever authenticated: an API key by its name, "token" for
+// CAPTAINSLOG_AUTH_TOKEN, "cert:<CN>" for a client certificate, or
+// "anonymous" when auth is off. Totals are kept in memory and saved as
+// JSON every minute or so and on shutdown; a crash loses at most that.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/auth"
+	"github.com/ryan-winkler/captainslog-whisper/internal/httputil"
+)
+
+// Retention is how long daily totals are kept.
+const Retention = 90 * 24 * time.Hour
+
+// dateLayout names a day, in the server's local time zone.
+const dateLayout = "2006-01-02"
+
+// Anonymous is the caller recorded when auth is off.
+const Anonymous = "anonymous"
+
+// Grouping of Query results.
+const (
+	ByKey = "key" // by caller
+	ByIP  = "ip"  // by client IP
+)
+
+// ErrInvalid is returned by Query for a bad grouping or range.
+var ErrInvalid = errors.New("invalid usage query")
+
+// Totals is the work done for one caller or IP.
+type Totals struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`        // answered with a 4xx or 5xx
+	AudioSeconds float64 `json:"audio_seconds"` // as reported by the backend; 0 when it doesn't say
+	Bytes        int64   `json:"bytes"`         // uploaded
+}
+
+func (t *Totals) add(o Totals) {
+	t.Requests += o.Requests
+	t.Errors += o.Errors
+	t.AudioSeconds += o.AudioSeconds
+	t.Bytes += o.Bytes
+}
+
+// day is one day's totals as saved.
+type day struct {
+	Keys map[string]*Totals `json:"keys"`
+	IPs  map[string]*Totals `json:"ips"`
+}
+
+// Day is one day of a Query.
+type Day struct {
+	Date  string            `json:"date"`
+	Usage map[string]Totals `json:"usage"`
+}
+
+// Tracker records usage and answers queries. Safe for concurrent use.
+type Tracker struct {
+	path string
+	now  func() time.Time
+
+	mu    sync.Mutex
+	days  map[string]*day
+	dirty bool
+}
+
+// Open loads the totals at path. A missing file is a tracker with none.
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{path: path, now: time.Now, days: map[string]*day{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.days); err != nil {
+		return nil, fmt.Errorf("parse usage %s: %w", filepath.Base(path), err)
+	}
+	return t, nil
+}
+
+// Record adds one request by caller from ip.
+func (t *Tracker) Record(caller, ip string, u Totals) {
+	if caller == "" {
+		caller = Anonymous
+	}
+	date := t.now().Format(dateLayout)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.days[date]
+	if d == nil {
+		d = &day{}
+		t.days[date] = d
+	}
+	d.Keys = addTo(d.Keys, caller, u)
+	d.IPs = addTo(d.IPs, ip, u)
+	t.dirty = true
+}
+
+func addTo(m map[string]*Totals, name string, u Totals) map[string]*Totals {
+	if m == nil {
+		m = map[string]*Totals{}
+	}
+	if m[name] == nil {
+		m[name] = &Totals{}
+	}
+	m[name].add(u)
+	return m
+}
+
+// Query returns the days from from to to (inclusive, "2006-01-02") that
+// have usage, oldest first, grouped by ByKey or ByIP, with the totals over
+// the whole range.
+func (t *Tracker) Query(from, to, by string) ([]Day, map[string]Totals, error) {
+	if by != ByKey && by != ByIP {
+		return nil, nil, fmt.Errorf("%w: group by %q or %q", ErrInvalid, ByKey, ByIP)
+	}
+	for _, s := range []string{from, to} {
+		if _, err := time.Parse(dateLayout, s); err != nil {
+			return nil, nil, fmt.Errorf("%w: dates are YYYY-MM-DD, not %q", ErrInvalid, s)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	days := []Day{}
+	totals := map[string]Totals{}
+	for date, d := range t.days {
+		// The layout sorts as text.
+		if date < from || date > to {
+			continue
+		}
+		group := d.Keys
+		if by == ByIP {
+			group = d.IPs
+		}
+		if len(group) == 0 {
+			continue
+		}
+		out := Day{Date: date, Usage: make(map[string]Totals, len(group))}
+		for name, u := range group {
+			out.Usage[name] = rounded(*u)
+			sum := totals[name]
+			sum.add(*u)
+			totals[name] = sum
+		}
+		days = append(days, out)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	for name, u := range totals {
+		totals[name] = rounded(u)
+	}
+	return days, totals, nil
+}
+
+// rounded keeps audio seconds to a tenth, so the JSON doesn't show float
+// noise from adding thousands of durations.
+func rounded(u Totals) Totals {
+	u.AudioSeconds = math.Round(u.AudioSeconds*10) / 10
+	return u
+}
+
+// Flush saves the totals if anything changed since the last save, first
+// dropping days older than Retention.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.dirty {
+		return nil
+	}
+	oldest := t.now().Add(-Retention).Format(dateLayout)
+	for date := range t.days {
+		if date < oldest {
+			delete(t.days, date)
+		}
+	}
+	data, err := json.MarshalIndent(t.days, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return fmt.Errorf("create usage dir: %w", err)
+	}
+	// Temp file + rename: a crash mid-write keeps the previous totals.
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write usage: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace usage: %w", err)
+	}
+	t.dirty = false
+	return nil
+}
+
+// Run flushes every interval until ctx is done, then once more.
+func (t *Tracker) Run(ctx context.Context, every time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				logger.Warn("could not save usage", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				logger.Warn("could not save usage", "error", err)
+			}
+		}
+	}
+}
+
+// Wrap records each POST to next: one request, its upload size, an
+// error if it is answered with a 4xx or 5xx, and the audio seconds the
+// handler reports with AddAudio. The caller is taken from auth.Caller, so
+// Wrap goes inside the auth check and refused requests aren't counted.
+func (t *Tracker) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// GETs on the same routes (listing jobs) are no work.
+		if r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		m := &meter{}
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r.WithContext(context.WithValue(r.Context(), meterKey{}, m)))
+
+		u := Totals{Requests: 1, Bytes: body.n, AudioSeconds: float64(m.millis.Load()) / 1000}
+		if rw.status >= 400 {
+			u.Errors = 1
+		}
+		t.Record(auth.Caller(r.Context()), httputil.ClientIP(r.RemoteAddr), u)
+	}
+}
+
+type meterKey struct{}
+
+// meter collects the audio seconds of one request, in milliseconds so it
+// can be added to atomically by concurrent chunk workers.
+type meter struct{ millis atomic.Int64 }
+
+// AddAudio adds seconds of transcribed audio to the request whose context
+// ctx is. It does nothing outside Wrap.
+func AddAudio(ctx context.Context, seconds float64) {
+	if m, ok := ctx.Value(meterKey{}).(*meter); ok && seconds > 0 {
+		m.millis.Add(int64(math.Round(seconds * 1000)))
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// statusWriter remembers the status code written.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,148 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/auth"
+)
+
+func newTracker(t *testing.T, now *time.Time) *Tracker {
+	t.Helper()
+	tr, err := Open(filepath.Join(t.TempDir(), "usage.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.now = func() time.Time { return *now }
+	return tr
+}
+
+func TestRecordQuery(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	tr := newTracker(t, &now)
+	tr.Record("kitchen", "192.168.1.20", Totals{Requests: 1, AudioSeconds: 30, Bytes: 1000})
+	tr.Record("kitchen", "192.168.1.20", Totals{Requests: 1, Errors: 1, Bytes: 500})
+	tr.Record("", "192.168.1.30", Totals{Requests: 1, AudioSeconds: 5})
+	now = now.AddDate(0, 0, 1)
+	tr.Record("kitchen", "192.168.1.21", Totals{Requests: 1, AudioSeconds: 10.04})
+
+	days, totals, err := tr.Query("2026-03-01", "2026-03-02", ByKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days[0].Date != "2026-03-01" || days[1].Date != "2026-03-02" {
+		t.Fatalf("days = %+v", days)
+	}
+	if got := days[0].Usage["kitchen"]; got != (Totals{Requests: 2, Errors: 1, AudioSeconds: 30, Bytes: 1500}) {
+		t.Errorf("kitchen on day 1 = %+v", got)
+	}
+	if got := days[0].Usage[Anonymous]; got.Requests != 1 {
+		t.Errorf("anonymous on day 1 = %+v", got)
+	}
+	if got := totals["kitchen"]; got.Requests != 3 || got.AudioSeconds != 40 {
+		t.Errorf("kitchen total = %+v", got)
+	}
+
+	days, totals, err = tr.Query("2026-03-02", "2026-03-31", ByIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || len(totals) != 1 || totals["192.168.1.21"].Requests != 1 {
+		t.Errorf("by ip = %+v, %+v", days, totals)
+	}
+}
+
+func TestQuery_Invalid(t *testing.T) {
+	now := time.Now()
+	tr := newTracker(t, &now)
+	for _, q := range [][3]string{
+		{"2026-03-01", "2026-03-02", "device"},
+		{"yesterday", "2026-03-02", ByKey},
+		{"2026-03-01", "", ByIP},
+	} {
+		if _, _, err := tr.Query(q[0], q[1], q[2]); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Query%v: err = %v, want ErrInvalid", q, err)
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	tr := newTracker(t, &now)
+	tr.Record("token", "10.0.0.1", Totals{Requests: 1})
+	now = now.Add(Retention + 24*time.Hour)
+	tr.Record("token", "10.0.0.1", Totals{Requests: 1, Bytes: 7})
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(tr.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Reloaded, without the day past Retention.
+	again, err := Open(tr.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	days, _, err := again.Query("2000-01-01", "2100-01-01", ByKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Usage["token"].Bytes != 7 {
+		t.Errorf("reloaded days = %+v", days)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	now := time.Now()
+	tr := newTracker(t, &now)
+	h := tr.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		AddAudio(r.Context(), 1.5)
+		AddAudio(r.Context(), 2)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	do := func(method, target string, caller string) {
+		req := httptest.NewRequest(method, target, strings.NewReader("0123456789"))
+		req.RemoteAddr = "192.168.1.9:52000"
+		if caller != "" {
+			req = req.WithContext(auth.WithCaller(req.Context(), caller))
+		}
+		h(httptest.NewRecorder(), req)
+	}
+	do(http.MethodPost, "/", "phone")
+	do(http.MethodPost, "/?fail=1", "phone")
+	do(http.MethodGet, "/", "phone") // not counted
+	do(http.MethodPost, "/", "")
+
+	today := now.Format(dateLayout)
+	_, totals, _ := tr.Query(today, today, ByKey)
+	if got := totals["phone"]; got != (Totals{Requests: 2, Errors: 1, AudioSeconds: 7, Bytes: 20}) {
+		t.Errorf("phone = %+v", got)
+	}
+	if got := totals[Anonymous]; got.Requests != 1 {
+		t.Errorf("anonymous = %+v", got)
+	}
+	_, totals, _ = tr.Query(today, today, ByIP)
+	if got := totals["192.168.1.9"]; got.Requests != 3 {
+		t.Errorf("by ip = %+v", totals)
+	}
+}
+
+func TestAddAudio_OutsideWrap(t *testing.T) {
+	AddAudio(context.Background(), 3) // must not panic
+}
@@ -0,0 +1,151 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Attach modes: what happens to the source recording of a saved note.
+const (
+	AttachOff  = ""     // the recording stays where it is; no embed
+	AttachCopy = "copy" // a copy goes into the attachments folder
+	AttachMove = "move" // the recording itself goes into the attachments folder
+)
+
+// DefaultAttachmentsDir is the attachments folder inside the vault when
+// none is configured.
+const DefaultAttachmentsDir = "attachments"
+
+// ValidAttachMode reports whether mode is AttachOff, AttachCopy or AttachMove.
+func ValidAttachMode(mode string) bool {
+	return mode == AttachOff || mode == AttachCopy || mode == AttachMove
+}
+
+// Attach copies (or, with move, moves) the audio file src into subdir of
+// the vault and returns its path there. An existing attachment of the same
+// name is never overwritten: the copy gets a " 2", " 3"… suffix, since
+// Obsidian resolves embeds by file name.
+func (v *Vault) Attach(src, subdir string, move bool) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	if subdir == "" {
+		subdir = DefaultAttachmentsDir
+	}
+	if !filepath.IsLocal(subdir) {
+		return "", fmt.Errorf("attachments folder %q must be a relative path inside the vault", subdir)
+	}
+	dir := filepath.Join(v.dir, subdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create attachments dir: %w", err)
+	}
+	dst, err := reserve(dir, filepath.Base(src))
+	if err != nil {
+		return "", err
+	}
+	if move {
+		err = os.Rename(src, dst)
+		if err == nil {
+			v.logger.Info("recording moved to vault", "file", dst)
+			return dst, nil
+		}
+		var linkErr *os.LinkError
+		if !errors.As(err, &linkErr) || !errors.Is(linkErr.Err, syscall.EXDEV) {
+			os.Remove(dst)
+			return "", fmt.Errorf("move recording: %w", err)
+		}
+		// Another filesystem: copy, then remove the original.
+	}
+	if err := copyInto(dst, src); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	if move {
+		if err := os.Remove(src); err != nil {
+			v.logger.Warn("recording copied to vault but not removed", "file", src, "error", err)
+		}
+	}
+	v.logger.Info("recording attached to vault", "file", dst, "move", move)
+	return dst, nil
+}
+
+// reserve creates an empty file for name in dir, adding " 2", " 3"… before
+// the extension until the name is free, and returns its path.
+func reserve(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; i < 1000; i++ {
+		path := filepath.Join(dir, name)
+		if i > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s %d%s", stem, i, ext))
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("create attachment: %w", err)
+		}
+		f.Close()
+		return path, nil
+	}
+	return "", fmt.Errorf("no free attachment name for %s", name)
+}
+
+func copyInto(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open recording: %w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("write attachment: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy recording: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("write attachment: %w", err)
+	}
+	return nil
+}
+
+// Embed is the Obsidian embed of an attachment: a player for audio.
+func Embed(path string) string {
+	return "![[" + filepath.Base(path) + "]]"
+}
+
+// InsertEmbed adds the embed of attachment to the note after the
+// transcription the save added (or at the note's end, if that is past
+// it), and counts it in as part of the transcription's entry.
+func InsertEmbed(note, attachment string, added *Added) error {
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	data, err := os.ReadFile(note)
+	if err != nil {
+		return fmt.Errorf("read note: %w", err)
+	}
+	at := added.End
+	if at < 0 || at > int64(len(data)) {
+		at = int64(len(data))
+	}
+	embed := "\n" + Embed(attachment) + "\n"
+	if at < int64(len(data)) && data[at] != '\n' {
+		embed += "\n"
+	}
+	out := append(append(slices.Clip(data[:at]), embed...), data[at:]...)
+	if err := WriteFileAtomic(note, out); err != nil {
+		return fmt.Errorf("write embed: %w", err)
+	}
+	added.End = at + int64(len(embed))
+	added.span += int64(len(embed))
+	return nil
+}
@@ -0,0 +1,54 @@
+package vault
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttach(t *testing.T) {
+	dir, rec := t.TempDir(), t.TempDir()
+	v := New(dir, "", "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	src := filepath.Join(rec, "rec.webm")
+	os.WriteFile(src, []byte("audio"), 0o644)
+
+	first, err := v.Attach(src, "", false)
+	if err != nil || first != filepath.Join(dir, "attachments", "rec.webm") {
+		t.Fatalf("copy = %q, %v", first, err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Error("copy removed the source")
+	}
+	second, err := v.Attach(src, "Audio/2026", true)
+	if err != nil || second != filepath.Join(dir, "Audio", "2026", "rec.webm") {
+		t.Fatalf("move = %q, %v", second, err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("move kept the source")
+	}
+
+	// Same name again: a new name, the first attachment untouched.
+	os.WriteFile(src, []byte("other"), 0o644)
+	third, err := v.Attach(src, "", false)
+	if err != nil || filepath.Base(third) != "rec 2.webm" {
+		t.Fatalf("third = %q, %v", third, err)
+	}
+	if data, _ := os.ReadFile(first); string(data) != "audio" {
+		t.Errorf("first attachment = %q", data)
+	}
+	if _, err := v.Attach(src, "../out", false); err == nil {
+		t.Error("attachments folder outside the vault accepted")
+	}
+
+	note, added, _ := v.SaveEntry("Hello", "en")
+	if err := InsertEmbed(note, third, &added); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(note)
+	if !strings.HasSuffix(string(data), "Hello\n\n![[rec 2.webm]]\n") {
+		t.Errorf("note = %q", data)
+	}
+}
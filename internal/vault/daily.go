@@ -0,0 +1,456 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/stardate"
+)
+
+// Vault modes: where Save puts a transcription.
+const (
+	ModeFiles = ""      // a file of its own per transcription
+	ModeDaily = "daily" // appended to the day's daily note
+)
+
+// ValidMode reports whether mode is ModeFiles or ModeDaily.
+func ValidMode(mode string) bool {
+	return mode == ModeFiles || mode == ModeDaily
+}
+
+// DefaultDailyEntry is the entry template when none is configured.
+const DefaultDailyEntry = "\n### {{.Time}} · Stardate {{.Stardate}}\n\n{{.Text}}\n"
+
+// DefaultDailyFormat is the daily note name when neither the settings nor
+// Obsidian's Daily notes plugin give one — the plugin's own default.
+const DefaultDailyFormat = "YYYY-MM-DD"
+
+// DailyNote makes Save append to the day's note, found the way Obsidian's
+// Daily notes plugin finds it, instead of writing a file per transcription.
+// Empty fields fall back to the plugin's settings in the vault
+// (.obsidian/daily-notes.json).
+type DailyNote struct {
+	Folder string // inside the vault
+	Format string // file name, in Moment.js tokens as Obsidian writes them ("YYYY-MM-DD")
+	Entry  string // text/template of one entry; "" = DefaultDailyEntry
+	Order  string // DailyOrderBottom ("" too) or DailyOrderTop, as in MigrateOptions
+	TOC    bool   // link each entry's heading from a list under the note's heading
+}
+
+// ValidDailyOrder reports whether order is "", DailyOrderBottom or
+// DailyOrderTop.
+func ValidDailyOrder(order string) bool {
+	return order == "" || order == DailyOrderBottom || order == DailyOrderTop
+}
+
+// DailyEntry is what an entry template can use.
+type DailyEntry struct {
+	Text     string // the transcription
+	Title    string // "" unless described (see WithDescriber)
+	Summary  string // "" unless described
+	Language string // language code, "" if unknown
+	Stardate string // e.g. "103021.4"
+	Date     string // 2006-01-02
+	Time     string // 15:04
+	Tags     string // the note's tags as #tag words, space-separated
+}
+
+// ParseDailyEntry checks an entry template.
+func ParseDailyEntry(entry string) error {
+	if entry == "" {
+		return nil
+	}
+	_, err := template.New("entry").Option("missingkey=error").Parse(entry)
+	if err != nil {
+		return fmt.Errorf("daily note entry template: %w", err)
+	}
+	return nil
+}
+
+// WithDailyNote makes Save append to the daily note. Returns v for
+// chaining.
+func (v *Vault) WithDailyNote(d *DailyNote) *Vault {
+	if v != nil {
+		v.daily = d
+	}
+	return v
+}
+
+// dailyMu serialises appends, and everything else that reads a note and
+// writes it back (refine, embed, undo): two of them at once on the same
+// note would lose one's change.
+var dailyMu sync.Mutex
+
+// obsidianDaily is the part of .obsidian/daily-notes.json we follow.
+type obsidianDaily struct {
+	Folder   string `json:"folder"`
+	Format   string `json:"format"`
+	Template string `json:"template"` // note to create the day's note from, without .md
+}
+
+// saveDaily adds an entry to the day's note — at the end, or with
+// DailyOrderTop under its heading — creating the note (from the plugin's
+// template, if it has one) when there is none yet. It returns the note as
+// it was before, so undo can put it back.
+func (v *Vault) saveDaily(n Transcription, tags []string) (string, Added, error) {
+	var plugin obsidianDaily
+	if data, err := os.ReadFile(filepath.Join(v.dir, ".obsidian", "daily-notes.json")); err == nil {
+		// Non-fatal: a broken plugin file leaves the defaults.
+		json.Unmarshal(data, &plugin)
+	}
+	folder, format, entry := v.daily.Folder, v.daily.Format, v.daily.Entry
+	if folder == "" {
+		folder = plugin.Folder
+	}
+	if format == "" {
+		format = plugin.Format
+	}
+	if format == "" {
+		format = DefaultDailyFormat
+	}
+	if entry == "" {
+		entry = DefaultDailyEntry
+	}
+
+	now := time.Now()
+	// The format may hold folders of its own ("YYYY/MM/YYYY-MM-DD").
+	rel := filepath.Join(filepath.FromSlash(strings.Trim(folder, "/")), filepath.FromSlash(formatMoment(format, now))+".md")
+	if !filepath.IsLocal(rel) {
+		return "", Added{}, fmt.Errorf("daily note %q must be inside the vault", rel)
+	}
+	path := filepath.Join(v.dir, rel)
+
+	tmpl, err := template.New("entry").Option("missingkey=error").Parse(entry)
+	if err != nil {
+		return "", Added{}, fmt.Errorf("daily note entry template: %w", err)
+	}
+	hashTags := make([]string, len(tags))
+	for i, t := range tags {
+		hashTags[i] = "#" + t
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, DailyEntry{
+		Text:     strings.TrimSpace(n.Text),
+		Title:    n.Title,
+		Summary:  n.Summary,
+		Language: n.Language,
+		Stardate: stardate.FromTime(now),
+		Date:     now.Format("2006-01-02"),
+		Time:     now.Format("15:04"),
+		Tags:     strings.Join(hashTags, " "),
+	})
+	if err != nil {
+		return "", Added{}, fmt.Errorf("daily note entry template: %w", err)
+	}
+	if t := strings.TrimSpace(n.Translation); t != "" {
+		b.WriteString("\n" + t + "\n")
+	}
+
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", Added{}, fmt.Errorf("create daily note folder: %w", err)
+	}
+	note, err := os.ReadFile(path)
+	before := note
+	if errors.Is(err, os.ErrNotExist) {
+		note = []byte(v.newDailyNote(plugin.Template, format, now))
+		before = nil
+	} else if err != nil {
+		return "", Added{}, fmt.Errorf("read daily note: %w", err)
+	}
+	link := ""
+	if v.daily.TOC {
+		if h := entryHeading(b.String()); h != "" {
+			link = fmt.Sprintf("- [[#%s|%s]]\n", h, now.Format("15:04"))
+		}
+	}
+	top := v.daily.Order == DailyOrderTop
+	data, end := addDailyEntry(string(note), b.String(), link, top)
+
+	sum, err := WriteVerified(path, []byte(data))
+	v.monitor.Record(path, len(data), sum, err)
+	if err != nil {
+		return "", Added{}, fmt.Errorf("write daily note: %w", err)
+	}
+	v.logger.Info("transcription added to daily note", "file", path)
+	added := Added{Before: before, End: int64(end), daily: true, top: top, tail: int64(len(data) - end)}
+	added.span = int64(b.Len())
+	if added.span > added.End {
+		added.span = added.End // the leading blank lines trimmed off at the top
+	}
+	return path, added, nil
+}
+
+// addDailyEntry puts text into note — at the end, or on top under the
+// note's heading and table of contents — and link, if any, into the table
+// of contents, starting one under the heading if the note has none. It
+// returns the note and where text ends in it.
+func addDailyEntry(note, text, link string, top bool) (string, int) {
+	head, tocStart, tocEnd := dailyHead(note)
+	at := len(note)
+	if top {
+		at = tocEnd
+	}
+	if at > 0 && note[at-1] != '\n' {
+		text = "\n" + text
+	}
+	rest := note[at:]
+	if rest != "" && !strings.HasPrefix(rest, "\n") {
+		// A blank line between the entry and the older one below it.
+		rest = "\n" + rest
+	}
+	pos := tocEnd
+	switch {
+	case link == "":
+	case tocStart == tocEnd:
+		pos = head
+		if head > 0 {
+			link = "\n" + link
+		}
+	case top:
+		pos = tocStart
+	}
+
+	out := note[:pos] + link + note[pos:at] + text
+	end := len(out)
+	out += rest
+	if trimmed := strings.TrimLeft(out, "\n"); !strings.HasPrefix(note, "\n") {
+		// The entry's leading blank lines separate it from what's above;
+		// at the top of a note they'd just be blank.
+		end -= len(out) - len(trimmed)
+		out = trimmed
+	}
+	return out, end
+}
+
+// dailyHead finds where a daily note's own start — its frontmatter and a
+// "# " heading right after it — ends, and its table of contents: the
+// "- [[#" lines that follow, as WithDailyNote's TOC and a daily migration
+// write them. Without one, tocStart and tocEnd are both head.
+func dailyHead(note string) (head, tocStart, tocEnd int) {
+	if strings.HasPrefix(note, "---\n") {
+		if end := strings.Index(note[3:], "\n---\n"); end >= 0 {
+			head = 3 + end + len("\n---\n")
+		}
+	}
+	// line returns the line starting at i, without its newline, and the
+	// start of the next one.
+	line := func(i int) (string, int) {
+		if end := strings.IndexByte(note[i:], '\n'); end >= 0 {
+			return note[i : i+end], i + end + 1
+		}
+		return note[i:], len(note)
+	}
+	skipBlank := func(i int) int {
+		for i < len(note) {
+			l, next := line(i)
+			if strings.TrimSpace(l) != "" {
+				break
+			}
+			i = next
+		}
+		return i
+	}
+	if i := skipBlank(head); i < len(note) {
+		if l, next := line(i); strings.HasPrefix(l, "# ") {
+			head = next
+		}
+	}
+	tocStart, tocEnd = skipBlank(head), skipBlank(head)
+	for tocEnd < len(note) {
+		l, next := line(tocEnd)
+		if !strings.HasPrefix(l, "- [[#") {
+			break
+		}
+		tocEnd = next
+	}
+	if tocEnd == tocStart {
+		return head, head, head
+	}
+	return head, tocStart, tocEnd
+}
+
+// entryHeading is the text of an entry's first Markdown heading, without
+// the characters an Obsidian link can't hold, or "" if it has none.
+func entryHeading(entry string) string {
+	for _, l := range strings.Split(entry, "\n") {
+		if m := markdownHeading.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+			return strings.Map(func(r rune) rune {
+				if strings.ContainsRune("#|[]^", r) {
+					return -1
+				}
+				return r
+			}, m[1])
+		}
+	}
+	return ""
+}
+
+// markdownHeading matches a Markdown heading line: "### 12:52 · Stardate …".
+var markdownHeading = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*$`)
+
+// templateVar matches the variables of Obsidian's core Templates plugin:
+// {{title}}, {{date}}, {{time}}, {{date:FORMAT}} and {{time:FORMAT}}.
+var templateVar = regexp.MustCompile(`\{\{\s*(title|date|time)\s*(?::([^}]*))?\}\}`)
+
+// newDailyNote is the start of a new daily note: the plugin's template
+// note with its variables filled in, as Obsidian would create it, or
+// nothing.
+func (v *Vault) newDailyNote(tmplNote, format string, now time.Time) string {
+	if tmplNote == "" {
+		return ""
+	}
+	rel := filepath.FromSlash(strings.TrimSuffix(tmplNote, ".md") + ".md")
+	if !filepath.IsLocal(rel) {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(v.dir, rel))
+	if err != nil {
+		// Non-fatal: Obsidian itself creates an empty note then.
+		v.logger.Warn("daily note template not found", "template", tmplNote, "error", err)
+		return ""
+	}
+	title := filepath.Base(formatMoment(format, now))
+	return templateVar.ReplaceAllStringFunc(string(data), func(m string) string {
+		parts := templateVar.FindStringSubmatch(m)
+		switch {
+		case parts[1] == "title":
+			return title
+		case parts[2] != "":
+			return formatMoment(strings.TrimSpace(parts[2]), now)
+		case parts[1] == "date":
+			return formatMoment(format, now)
+		default:
+			return formatMoment("HH:mm", now)
+		}
+	})
+}
+
+// momentTokens are the Moment.js format tokens formatMoment knows, longest
+// first so "YYYY" wins over "YY".
+var momentTokens = []string{
+	"YYYY", "YY", "MMMM", "MMM", "MM", "M", "DDDD", "DDD", "Do", "DD", "D",
+	"dddd", "ddd", "dd", "d", "HH", "H", "hh", "h", "mm", "m", "ss", "s",
+	"A", "a", "WW", "W", "ww", "w", "Q",
+}
+
+// formatMoment formats t with a Moment.js format string, as Obsidian's
+// settings use. Text in [brackets] is literal; characters that aren't
+// tokens are kept.
+func formatMoment(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				b.WriteString(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		tok := ""
+		for _, m := range momentTokens {
+			if strings.HasPrefix(format[i:], m) {
+				tok = m
+				break
+			}
+		}
+		if tok == "" {
+			b.WriteByte(format[i])
+			i++
+			continue
+		}
+		b.WriteString(momentToken(tok, t))
+		i += len(tok)
+	}
+	return b.String()
+}
+
+func momentToken(tok string, t time.Time) string {
+	_, week := t.ISOWeek()
+	switch tok {
+	case "YYYY":
+		return t.Format("2006")
+	case "YY":
+		return t.Format("06")
+	case "MMMM":
+		return t.Format("January")
+	case "MMM":
+		return t.Format("Jan")
+	case "MM":
+		return t.Format("01")
+	case "M":
+		return t.Format("1")
+	case "DDDD":
+		return fmt.Sprintf("%03d", t.YearDay())
+	case "DDD":
+		return fmt.Sprint(t.YearDay())
+	case "Do":
+		return ordinal(t.Day())
+	case "DD":
+		return t.Format("02")
+	case "D":
+		return t.Format("2")
+	case "dddd":
+		return t.Format("Monday")
+	case "ddd":
+		return t.Format("Mon")
+	case "dd":
+		return t.Format("Mon")[:2]
+	case "d":
+		return fmt.Sprint(int(t.Weekday()))
+	case "HH":
+		return t.Format("15")
+	case "H":
+		return fmt.Sprint(t.Hour())
+	case "hh":
+		return t.Format("03")
+	case "h":
+		return t.Format("3")
+	case "mm":
+		return t.Format("04")
+	case "m":
+		return fmt.Sprint(t.Minute())
+	case "ss":
+		return t.Format("05")
+	case "s":
+		return fmt.Sprint(t.Second())
+	case "A":
+		return t.Format("PM")
+	case "a":
+		return t.Format("pm")
+	// Week numbers are ISO weeks, whatever the locale (Moment's ww and w
+	// follow the locale's first day of the week).
+	case "WW", "ww":
+		return fmt.Sprintf("%02d", week)
+	case "W", "w":
+		return fmt.Sprint(week)
+	case "Q":
+		return fmt.Sprint((int(t.Month())-1)/3 + 1)
+	}
+	return tok
+}
+
+// ordinal is n with its English suffix: 1st, 2nd, 3rd, 11th, 22nd.
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
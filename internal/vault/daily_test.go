@@ -0,0 +1,253 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveDaily(t *testing.T) {
+	dir := t.TempDir()
+	v := New(dir, "", "", slog.Default()).WithDailyNote(&DailyNote{Folder: "Journal", Entry: "- {{.Language}}: {{.Text}} {{.Tags}}\n"})
+
+	file, added, err := v.SaveEntry("first thought", "en", "meeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "Journal", time.Now().Format("2006-01-02")+".md")
+	if file != want || added.Before != nil {
+		t.Fatalf("SaveEntry = %q, %q; want %q, nil", file, added.Before, want)
+	}
+	// The user's own text, without a trailing newline.
+	f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("my own line")
+	f.Close()
+
+	_, added, err = v.SaveEntry("second thought", "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(file)
+	wantText := "- en: first thought #dictation #auto-generated #meeting\nmy own line\n- de: second thought #dictation #auto-generated\n"
+	if string(got) != wantText {
+		t.Errorf("note =\n%s\nwant\n%s", got, wantText)
+	}
+	if string(added.Before) != "- en: first thought #dictation #auto-generated #meeting\nmy own line" || added.End != int64(len(wantText)) {
+		t.Errorf("added = %q, %d", added.Before, added.End)
+	}
+}
+
+func TestSaveDailyObsidianSettings(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".obsidian"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".obsidian", "daily-notes.json"),
+		[]byte(`{"folder":"Daily/","format":"YYYY/[Week] ww/dddd","template":"Templates/Day"}`), 0o644)
+	os.MkdirAll(filepath.Join(dir, "Templates"), 0o755)
+	os.WriteFile(filepath.Join(dir, "Templates", "Day.md"), []byte("# {{title}} ({{date:YYYY}})\n"), 0o644)
+
+	v := New(dir, "", "", slog.Default()).WithDailyNote(&DailyNote{})
+	file, _, err := v.SaveEntry("hello", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	_, week := now.ISOWeek()
+	want := filepath.Join(dir, "Daily", now.Format("2006"), fmt.Sprintf("Week %02d", week), now.Format("Monday")+".md")
+	if file != want {
+		t.Fatalf("file = %q, want %q", file, want)
+	}
+	got, _ := os.ReadFile(file)
+	if !strings.HasPrefix(string(got), "# "+now.Format("Monday")+" ("+now.Format("2006")+")\n") {
+		t.Errorf("note does not start from the template:\n%s", got)
+	}
+	if !strings.Contains(string(got), "Stardate ") || !strings.HasSuffix(string(got), "\nhello\n") {
+		t.Errorf("default entry missing:\n%s", got)
+	}
+}
+
+func TestSaveDailyTopWithTOC(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, time.Now().Format("2006-01-02")+".md")
+	start := "---\ntags: [log]\n---\n# Today\n\nplans\n"
+	os.WriteFile(file, []byte(start), 0o644)
+	v := New(dir, "", "", slog.Default()).WithDailyNote(&DailyNote{Entry: "\n### {{.Text}}\n", Order: DailyOrderTop, TOC: true})
+	clock := regexp.MustCompile(`\|\d\d:\d\d\]\]`)
+	var u UndoLog
+
+	if _, _, err := v.SaveEntry("a", "en"); err != nil {
+		t.Fatal(err)
+	}
+	afterA, _ := os.ReadFile(file)
+	_, added, err := v.SaveEntry("b", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.RecordEdit(file, "b", added.Before)
+	got, _ := os.ReadFile(file)
+	want := "---\ntags: [log]\n---\n# Today\n\n- [[#b|t]]\n- [[#a|t]]\n\n### b\n\n### a\n\nplans\n"
+	if s := clock.ReplaceAllString(string(got), "|t]]"); s != want {
+		t.Errorf("note =\n%s\nwant\n%s", s, want)
+	}
+	if !strings.HasSuffix(string(got[:added.End]), "### b\n") {
+		t.Errorf("entry ends at %d: %q", added.End, got[:added.End])
+	}
+
+	// Undo takes the link out with the entry.
+	if _, err := u.Undo(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(file); string(got) != string(afterA) {
+		t.Errorf("note after undo =\n%s\nwant\n%s", got, afterA)
+	}
+	if s := clock.ReplaceAllString(string(afterA), "|t]]"); s != "---\ntags: [log]\n---\n# Today\n\n- [[#a|t]]\n\n### a\n\nplans\n" {
+		t.Errorf("first entry =\n%s", s)
+	}
+}
+
+// A repeated short draft: refining the older entry must not touch the
+// newer one, above it or below.
+func TestRefineDailyEntry(t *testing.T) {
+	for _, order := range []string{DailyOrderBottom, DailyOrderTop} {
+		dir := t.TempDir()
+		v := New(dir, "", "", slog.Default()).WithDailyNote(&DailyNote{Entry: "- {{.Text}}\n", Order: order})
+		file, older, _ := v.SaveEntry("yes", "en")
+		if err := InsertEmbed(file, filepath.Join(dir, "a.webm"), &older); err != nil {
+			t.Fatal(err)
+		}
+		v.SaveEntry("yes", "en")
+
+		if ok, err := Refine(file, "yes", "", older, Transcription{Text: "Yes."}); !ok || err != nil {
+			t.Fatalf("%s: Refine = %v, %v", order, ok, err)
+		}
+		got, _ := os.ReadFile(file)
+		want := "- Yes.\n\n![[a.webm]]\n- yes\n"
+		if order == DailyOrderTop {
+			want = "- yes\n\n- Yes.\n\n![[a.webm]]\n"
+		}
+		if string(got) != want {
+			t.Errorf("%s: note = %q, want %q", order, got, want)
+		}
+	}
+}
+
+func TestAddDailyEntry(t *testing.T) {
+	for _, c := range []struct {
+		note, link string
+		top        bool
+		want       string
+	}{
+		{"", "", true, "### e\n"},
+		{"", "- [[#e|t]]\n", false, "- [[#e|t]]\n\n### e\n"},
+		{"old", "", true, "### e\n\nold"},
+		{"# Day\n\n- [[#d|t]]\n\n### d\n", "- [[#e|t]]\n", false, "# Day\n\n- [[#d|t]]\n- [[#e|t]]\n\n### d\n\n### e\n"},
+		{"# Day", "", true, "# Day\n\n### e\n"},
+	} {
+		got, end := addDailyEntry(c.note, "\n### e\n", c.link, c.top)
+		if got != c.want || !strings.HasSuffix(got[:end], "### e\n") {
+			t.Errorf("addDailyEntry(%q, %q, top %v) = %q, %d; want %q", c.note, c.link, c.top, got, end, c.want)
+		}
+	}
+}
+
+func TestSaveDailyOutsideVault(t *testing.T) {
+	v := New(t.TempDir(), "", "", slog.Default()).WithDailyNote(&DailyNote{Folder: "../elsewhere"})
+	if _, _, err := v.SaveEntry("hello", "en"); err == nil {
+		t.Error("saved outside the vault")
+	}
+}
+
+func TestUndoDaily(t *testing.T) {
+	dir := t.TempDir()
+	v := New(dir, "", "", slog.Default()).WithDailyNote(&DailyNote{Entry: "- {{.Text}}\n"})
+	var u UndoLog
+
+	file, added, _ := v.SaveEntry("keep", "en")
+	u.RecordEdit(file, "a", added.Before)
+	file, added, _ = v.SaveEntry("oops", "en")
+	u.RecordEdit(file, "b", added.Before)
+	if _, err := u.Undo(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(file); string(got) != "- keep\n" {
+		t.Errorf("note after undo = %q", got)
+	}
+
+	// The first entry of the day takes the note with it.
+	other := New(t.TempDir(), "", "", slog.Default()).WithDailyNote(&DailyNote{})
+	file, added, _ = other.SaveEntry("only", "en")
+	u.RecordEdit(file, "c", added.Before)
+	if _, err := u.Undo(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+		t.Error("new daily note still exists after undo")
+	}
+}
+
+// An entry added while undo is checking the note must not be lost.
+func TestUndoDailyWaitsForSave(t *testing.T) {
+	dir := t.TempDir()
+	v := New(dir, "", "", slog.Default()).WithDailyNote(&DailyNote{Entry: "- {{.Text}}\n"})
+	var u UndoLog
+	v.SaveEntry("keep", "en")
+	file, added, _ := v.SaveEntry("oops", "en")
+	u.RecordEdit(file, "b", added.Before)
+
+	dailyMu.Lock() // a save in progress
+	done := make(chan error)
+	go func() {
+		_, err := u.Undo(time.Minute)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("- meanwhile\n")
+	f.Close()
+	dailyMu.Unlock()
+
+	if err := <-done; !errors.Is(err, ErrNoteChanged) {
+		t.Errorf("undo = %v, want ErrNoteChanged", err)
+	}
+	if got, _ := os.ReadFile(file); !strings.HasSuffix(string(got), "- meanwhile\n") {
+		t.Errorf("note = %q", got)
+	}
+}
+
+func TestParseDailyEntry(t *testing.T) {
+	if err := ParseDailyEntry("{{.Text}}"); err != nil {
+		t.Error(err)
+	}
+	if err := ParseDailyEntry("{{.Text"); err == nil {
+		t.Error("unclosed action accepted")
+	}
+}
+
+func TestFormatMoment(t *testing.T) {
+	at := time.Date(2024, time.March, 2, 14, 5, 9, 0, time.UTC)
+	for format, want := range map[string]string{
+		"YYYY-MM-DD":         "2024-03-02",
+		"DD.MM.YY":           "02.03.24",
+		"dddd, MMMM Do YYYY": "Saturday, March 2nd 2024",
+		"ddd D MMM":          "Sat 2 Mar",
+		"[Day] DDDD, [Q]Q":   "Day 062, Q1",
+		"YYYY/MM/YYYY-MM-DD": "2024/03/2024-03-02",
+		"hh:mm A":            "02:05 PM",
+		"HH:mm:ss":           "14:05:09",
+		"gggg":               "gggg",
+	} {
+		if got := formatMoment(format, at); got != want {
+			t.Errorf("formatMoment(%q) = %q, want %q", format, got, want)
+		}
+	}
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 22: "22nd", 31: "31st"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package vault — full document read/modify/write.
+// Scan reads capped previews for the history list; Document loads a whole
+// vault file so tools (re-processing, migration) can rewrite frontmatter
+// without disturbing the body or unknown keys.
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Document is a vault markdown file split into frontmatter and body.
+// Frontmatter lines keep their original order and formatting; only keys
+// changed through Set are rewritten.
+type Document struct {
+	Path string
+	Body string
+
+	front    []frontLine
+	hasFront bool
+}
+
+type frontLine struct {
+	key string // "" for lines that aren't key: value (comments, blank lines)
+	raw string // with the key's continuation lines ("  - a"), if any
+}
+
+// ReadDocument loads the file at path.
+func ReadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	return ParseDocument(path, data), nil
+}
+
+// ParseDocument splits data into frontmatter and body. Files without a
+// leading "---" block are treated as all body.
+func ParseDocument(path string, data []byte) *Document {
+	d := &Document{Path: path}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	if !strings.HasPrefix(text, "---\n") {
+		d.Body = text
+		return d
+	}
+	rest := text[len("---\n"):]
+	end := strings.Index(rest, "\n---\n")
+	closing := len("\n---\n")
+	if end < 0 {
+		if strings.HasSuffix(rest, "\n---") {
+			end, closing = len(rest)-len("\n---"), len("\n---")
+		} else {
+			d.Body = text // unterminated frontmatter — leave the file alone
+			return d
+		}
+	}
+	d.hasFront = true
+	for _, line := range strings.Split(rest[:end], "\n") {
+		key := frontKey(line)
+		if n := len(d.front); key == "" && continues(line) && n > 0 && d.front[n-1].key != "" {
+			// A block list or multi-line value belongs to its key: Set and
+			// Delete replace them together.
+			d.front[n-1].raw += "\n" + line
+			continue
+		}
+		d.front = append(d.front, frontLine{key: key, raw: line})
+	}
+	d.Body = strings.TrimPrefix(rest[end+closing:], "\n")
+	return d
+}
+
+// continues reports whether a frontmatter line continues the key above:
+// a list item ("- a", "  - a") or an indented line.
+func continues(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t' || line[0] == '-')
+}
+
+func frontKey(line string) string {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || line[0] == '-' {
+		return ""
+	}
+	idx := strings.Index(line, ":")
+	if idx <= 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[:idx])
+}
+
+// Get returns the raw value of a frontmatter key, or "". A block list
+// ("tags:" over "  - a" lines) comes back in the inline form, "[a, b]".
+func (d *Document) Get(key string) string {
+	for _, l := range d.front {
+		if l.key == key {
+			first, more, _ := strings.Cut(l.raw, "\n")
+			_, v, _ := strings.Cut(first, ":")
+			if v = strings.TrimSpace(v); v != "" || more == "" {
+				return v
+			}
+			if items, ok := blockList(more); ok {
+				return "[" + strings.Join(items, ", ") + "]"
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// blockList returns the items of a block list's lines, or false if they
+// aren't all "- item".
+func blockList(lines string) ([]string, bool) {
+	var items []string
+	for _, l := range strings.Split(lines, "\n") {
+		item, ok := strings.CutPrefix(strings.TrimSpace(l), "-")
+		if !ok {
+			return nil, false
+		}
+		items = append(items, strings.TrimSpace(item))
+	}
+	return items, true
+}
+
+// Set replaces the value of key, or appends it if missing. value is written
+// verbatim — callers quote strings that need it (see Quote).
+func (d *Document) Set(key, value string) {
+	line := key + ": " + value
+	for i, l := range d.front {
+		if l.key == key {
+			d.front[i].raw = line
+			return
+		}
+	}
+	d.front = append(d.front, frontLine{key: key, raw: line})
+	d.hasFront = true
+}
+
+// Delete removes key from the frontmatter.
+func (d *Document) Delete(key string) {
+	out := d.front[:0]
+	for _, l := range d.front {
+		if l.key != key {
+			out = append(out, l)
+		}
+	}
+	d.front = out
+}
+
+// Keys returns frontmatter keys in file order.
+func (d *Document) Keys() []string {
+	var keys []string
+	for _, l := range d.front {
+		if l.key != "" {
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Tags parses the tags list, inline ("tags: [a, b]") or a block list.
+func (d *Document) Tags() []string {
+	return ParseTags(d.Get("tags"))
+}
+
+// HasTag reports whether the document is tagged with tag (case-insensitive).
+func (d *Document) HasTag(tag string) bool {
+	for _, t := range d.Tags() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTags writes tags in the inline list form, or as a block list if
+// that is how the note had them (as Obsidian's properties editor writes
+// them), indented the same way.
+func (d *Document) SetTags(tags []string) {
+	for i, l := range d.front {
+		if l.key != "tags" {
+			continue
+		}
+		first, more, _ := strings.Cut(l.raw, "\n")
+		if _, v, _ := strings.Cut(first, ":"); strings.TrimSpace(v) != "" || more == "" {
+			break
+		}
+		if _, ok := blockList(more); !ok || len(tags) == 0 {
+			break
+		}
+		indent, _, _ := strings.Cut(more, "-")
+		var b strings.Builder
+		for _, t := range tags {
+			b.WriteString("\n" + indent + "- " + t)
+		}
+		d.front[i].raw = "tags:" + b.String()
+		return
+	}
+	d.Set("tags", FormatTags(tags))
+}
+
+// Time returns the frontmatter date, falling back to the file's mod time.
+func (d *Document) Time() time.Time {
+	if ts := normalizeTimestamp(d.Get("date")); ts != "" {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t
+		}
+	}
+	if info, err := os.Stat(d.Path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// Bytes renders the document back to markdown.
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	if d.hasFront {
+		b.WriteString("---\n")
+		for _, l := range d.front {
+			b.WriteString(l.raw)
+			b.WriteByte('\n')
+		}
+		b.WriteString("---\n\n")
+	}
+	b.WriteString(d.Body)
+	return b.Bytes()
+}
+
+// Save writes the document back to Path atomically (temp file + rename), so
+// a crash or full disk never leaves a half-written note in the vault.
+func (d *Document) Save() error {
+	return WriteFileAtomic(d.Path, d.Bytes())
+}
+
+// WriteFileAtomic writes data to path via a temp file in the same directory.
+// The temp file is synced before the rename, so after a crash or power loss
+// path holds either the old content or the new, never a truncated mix; a
+// leftover temp file is removed by CleanTemp.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write temp: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("sync temp: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("close temp: %w", err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	os.Chmod(tmp.Name(), mode)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rename: %w", err)
+	}
+	// Persist the rename itself. Not every platform can sync a directory
+	// (Windows can't), so failure here is not an error.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// Quote renders s as a double-quoted YAML scalar on a single line.
+func Quote(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Unquote is the inverse of Quote. Other values are returned as they are.
+func Unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	r := strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+	return r.Replace(s[1 : len(s)-1])
+}
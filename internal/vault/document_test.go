@@ -0,0 +1,135 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleDoc = `---
+title: Dictation
+date: 2026-02-21T11:44:58
+language: en
+tags: [dictation, auto-generated]
+custom: keep me
+---
+
+Hello world.
+Second line.
+`
+
+func TestParseDocumentRoundTrip(t *testing.T) {
+	d := ParseDocument("x.md", []byte(sampleDoc))
+	if got := string(d.Bytes()); got != sampleDoc {
+		t.Errorf("round trip changed the file:\n%s", got)
+	}
+	if d.Get("title") != "Dictation" || d.Get("language") != "en" {
+		t.Errorf("title = %q, language = %q", d.Get("title"), d.Get("language"))
+	}
+	if d.Body != "Hello world.\nSecond line.\n" {
+		t.Errorf("body = %q", d.Body)
+	}
+}
+
+func TestDocumentSetAndDelete(t *testing.T) {
+	d := ParseDocument("x.md", []byte(sampleDoc))
+	d.Set("title", "Meeting")
+	d.Set("summary", Quote("A \"quick\"\nchat"))
+	d.Delete("custom")
+
+	out := string(d.Bytes())
+	if !strings.Contains(out, "title: Meeting\n") {
+		t.Errorf("title not replaced:\n%s", out)
+	}
+	if !strings.Contains(out, `summary: "A \"quick\" chat"`) {
+		t.Errorf("summary not appended/quoted:\n%s", out)
+	}
+	if strings.Contains(out, "custom:") {
+		t.Errorf("custom not deleted:\n%s", out)
+	}
+	if got := Unquote(d.Get("summary")); got != `A "quick" chat` {
+		t.Errorf("Unquote = %q", got)
+	}
+	if strings.Join(d.Keys(), ",") != "title,date,language,tags,summary" {
+		t.Errorf("keys = %v", d.Keys())
+	}
+}
+
+func TestDocumentTags(t *testing.T) {
+	d := ParseDocument("x.md", []byte(sampleDoc))
+	if tags := d.Tags(); len(tags) != 2 || tags[1] != "auto-generated" {
+		t.Errorf("tags = %v", tags)
+	}
+	if !d.HasTag("Dictation") || d.HasTag("meeting") {
+		t.Error("HasTag mismatch")
+	}
+	d.SetTags(append(d.Tags(), "meeting"))
+	if d.Get("tags") != "[dictation, auto-generated, meeting]" {
+		t.Errorf("tags line = %q", d.Get("tags"))
+	}
+}
+
+func TestDocumentBlockListTags(t *testing.T) {
+	src := "---\ntitle: Note\ntags:\n  - dictation\n  - work\nlanguage: en\n---\n\nBody\n"
+	d := ParseDocument("x.md", []byte(src))
+	if string(d.Bytes()) != src {
+		t.Errorf("round trip = %q", d.Bytes())
+	}
+	if d.Get("tags") != "[dictation, work]" || !d.HasTag("dictation") {
+		t.Errorf("tags = %q", d.Get("tags"))
+	}
+	d.SetTags(append(d.Tags(), "meeting"))
+	want := "---\ntitle: Note\ntags:\n  - dictation\n  - work\n  - meeting\nlanguage: en\n---\n\nBody\n"
+	if got := string(d.Bytes()); got != want {
+		t.Errorf("after SetTags = %q, want %q", got, want)
+	}
+	d.Delete("tags")
+	if got := string(d.Bytes()); strings.Contains(got, "- ") {
+		t.Errorf("list items left after Delete: %q", got)
+	}
+}
+
+func TestDocumentWithoutFrontmatter(t *testing.T) {
+	d := ParseDocument("x.md", []byte("just text\n"))
+	if d.Body != "just text\n" || len(d.Keys()) != 0 {
+		t.Fatalf("body = %q, keys = %v", d.Body, d.Keys())
+	}
+	d.Set("summary", Quote("short"))
+	if got := string(d.Bytes()); got != "---\nsummary: \"short\"\n---\n\njust text\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDocumentTime(t *testing.T) {
+	d := ParseDocument("x.md", []byte(sampleDoc))
+	want := time.Date(2026, 2, 21, 11, 44, 58, 0, time.UTC)
+	if !d.Time().Equal(want) {
+		t.Errorf("Time = %v, want %v", d.Time(), want)
+	}
+}
+
+func TestDocumentSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.md")
+	os.WriteFile(path, []byte(sampleDoc), 0600)
+
+	d, err := ReadDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Set("language", "fr")
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "language: fr") {
+		t.Errorf("saved file = %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600 preserved", info.Mode().Perm())
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*.tmp")); len(matches) != 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
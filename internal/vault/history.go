@@ -50,6 +50,31 @@ type Entry struct {
 
 	// Title from frontmatter (e.g. "Dictation").
 	Title string `json:"title,omitempty"`
+
+	// Summary from frontmatter, written at save time (see WithDescriber)
+	// or by the summarize re-processing pipeline.
+	Summary string `json:"summary,omitempty"`
+
+	// Visibility from frontmatter: private (default), shared, or public.
+	Visibility string `json:"visibility"`
+
+	// Pinned from frontmatter (pinned: true): kept at the top of the history.
+	Pinned bool `json:"pinned,omitempty"`
+
+	// Tags from frontmatter (tags: [a, b]).
+	Tags []string `json:"tags,omitempty"`
+
+	// ID and Segments come from the transcript index, not the note: set
+	// by the server when the note is indexed, so the UI can fetch its
+	// segments from /api/transcripts/{id}/segments on demand.
+	ID       string `json:"id,omitempty"`
+	Segments int    `json:"segments,omitempty"`
+
+	// Words, reading time (seconds) and speech rate (words per minute)
+	// come from the transcript index too.
+	Words          int     `json:"words,omitempty"`
+	ReadingSeconds int     `json:"reading_seconds,omitempty"`
+	WPM            float64 `json:"wpm,omitempty"`
 }
 
 // ExpandDir resolves ~/ to the user's home directory and returns the
@@ -130,6 +155,13 @@ func Scan(dir string, maxEntries int, logger *slog.Logger) ([]Entry, error) {
 	return entries, nil
 }
 
+// ReadEntry parses one vault note into a history entry, with the same
+// preview limits as Scan. Used to page through the transcript index without
+// reading the whole directory.
+func ReadEntry(path string) (Entry, error) {
+	return parseVaultFile(path)
+}
+
 // parseVaultFile reads a single .md file with YAML frontmatter.
 // Expected format:
 //
@@ -160,6 +192,7 @@ func parseVaultFile(path string) (Entry, error) {
 	state := 0
 	var bodyBuilder strings.Builder
 	bodyLineCount := 0
+	lastKey := "" // for block-list items under "tags:"
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -174,6 +207,12 @@ func parseVaultFile(path string) (Entry, error) {
 				state = 2
 				continue
 			}
+			if key := frontKey(line); key != "" {
+				lastKey = key
+			} else if item, ok := strings.CutPrefix(strings.TrimSpace(line), "-"); ok && lastKey == "tags" {
+				entry.Tags = append(entry.Tags, ParseTags(item)...)
+				continue
+			}
 			parseFrontmatterLine(line, &entry)
 		case 2:
 			bodyLineCount++
@@ -217,6 +256,7 @@ done:
 
 	// Normalize timestamp to RFC3339
 	entry.Timestamp = normalizeTimestamp(entry.Timestamp)
+	entry.Visibility = ParseVisibility(entry.Visibility)
 
 	return entry, nil
 }
@@ -231,11 +271,19 @@ func parseFrontmatterLine(line string, entry *Entry) {
 	val := strings.TrimSpace(line[idx+1:])
 	switch key {
 	case "title":
-		entry.Title = val
+		entry.Title = Unquote(val)
+	case "summary":
+		entry.Summary = Unquote(val)
 	case "date":
 		entry.Timestamp = val
 	case "language":
 		entry.Language = val
+	case "visibility":
+		entry.Visibility = val
+	case "pinned":
+		entry.Pinned = ParsePinned(val)
+	case "tags":
+		entry.Tags = ParseTags(val)
 	}
 }
 
@@ -157,6 +157,22 @@ func TestParseVaultFileValid(t *testing.T) {
 	}
 }
 
+func TestParseVaultFileBlockListTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.md")
+	os.WriteFile(path, []byte("---\ndate: 2026-02-21T11:44:58\ntags:\n  - dictation\n  - \"work\"\nlanguage: en\n---\n\nHello world.\n"), 0644)
+
+	entry, err := parseVaultFile(path)
+	if err != nil {
+		t.Fatalf("parseVaultFile failed: %v", err)
+	}
+	if strings.Join(entry.Tags, ",") != "dictation,work" {
+		t.Errorf("Tags = %v, want [dictation work]", entry.Tags)
+	}
+	if entry.Language != "en" {
+		t.Errorf("Language = %q, want en", entry.Language)
+	}
+}
+
 func TestParseVaultFileMissingDate(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "nodate.md")
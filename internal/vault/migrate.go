@@ -0,0 +1,615 @@
+// Package vault — layout migration.
+// Converts a vault between the per-entry layout (one file per dictation, the
+// current default) and the older daily-aggregate layout (one file per day
+// with "## HH:MM:SS (lang)" sections), renames files to a new template,
+// moves them to a new directory, and rewrites frontmatter.
+//
+// Safety model: originals are never deleted. Apply moves them into a backup
+// directory inside the source vault and records every file it created in a
+// manifest, so Rollback can put the vault back exactly as it was.
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Vault layouts.
+const (
+	LayoutEntry = "entry" // one file per dictation (vault.Save)
+	LayoutDaily = "daily" // one file per day, sections per dictation
+)
+
+// Default filename templates per layout. Placeholders: {title}, {date}
+// (formatted with MigrateOptions.DateFormat), {time} (15-04-05), {lang}.
+const (
+	DefaultEntryTemplate = "{title} {date} {time}"
+	DefaultDailyTemplate = "{date}"
+)
+
+// Where each day's dictations go in a daily file.
+const (
+	DailyOrderBottom = "bottom" // oldest first: each new entry goes at the end (default)
+	DailyOrderTop    = "top"    // newest first: each new entry goes right under the heading
+)
+
+// ManifestName is the file Apply writes inside the backup directory.
+const ManifestName = "manifest.json"
+
+// MigrateOptions configures a migration.
+type MigrateOptions struct {
+	SourceDir  string
+	DestDir    string            // default: SourceDir
+	Layout     string            // LayoutEntry or LayoutDaily
+	Template   string            // default: per-layout template
+	DateFormat string            // Go layout for {date}; default 2006-01-02
+	Title      string            // title for notes without one; default "Dictation"
+	Set        map[string]string // frontmatter keys to set (raw YAML values)
+	Drop       []string          // frontmatter keys to remove
+	DailyOrder string            // DailyOrderBottom or DailyOrderTop (daily layout only)
+	DailyTOC   bool              // list the day's entries under the heading (daily layout only)
+}
+
+// Note is one dictation, independent of the layout it was stored in.
+type Note struct {
+	Time     time.Time
+	Title    string
+	Language string
+	Tags     []string
+	Extra    []string // other frontmatter lines, verbatim (per-entry only)
+	Text     string
+	Source   string
+}
+
+// MigrationOutput is one file a migration will write.
+type MigrationOutput struct {
+	Path    string   `json:"path"`
+	Sources []string `json:"sources"`
+	data    []byte
+}
+
+// MigrationPlan is the full set of changes, computed without touching disk.
+type MigrationPlan struct {
+	Options  MigrateOptions    `json:"-"`
+	Sources  []string          `json:"sources"`
+	Outputs  []MigrationOutput `json:"outputs"`
+	Notes    int               `json:"notes"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+// dailyHeading matches a daily-aggregate section: "## 12:52:05 (en)".
+var dailyHeading = regexp.MustCompile(`^##\s+(\d{1,2}:\d{2}(?::\d{2})?)(?:\s+\(([^)]*)\))?\s*$`)
+
+// dailyTitle matches the daily file heading: "# 🎙️ Dictation — 2026-02-20".
+var dailyTitle = regexp.MustCompile(`^#\s+(?:\S+\s+)?(.+?)\s+—\s+\S+\s*$`)
+
+// ReadNotes parses a vault file in either layout.
+func ReadNotes(path string) ([]Note, error) {
+	doc, err := ReadDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	if isDaily(doc.Body) {
+		return splitDaily(doc), nil
+	}
+	text := strings.TrimSpace(doc.Body)
+	if text == "" {
+		return nil, nil
+	}
+	n := Note{
+		Time:     doc.Time(),
+		Title:    doc.Get("title"),
+		Language: doc.Get("language"),
+		Tags:     doc.Tags(),
+		Text:     text,
+		Source:   path,
+	}
+	// Keep unknown keys (summary, custom user keys) with their continuation lines.
+	keep := false
+	for _, l := range doc.front {
+		if l.key != "" {
+			switch l.key {
+			case "title", "date", "language", "tags":
+				keep = false
+			default:
+				keep = true
+			}
+		}
+		if keep {
+			n.Extra = append(n.Extra, l.raw)
+		}
+	}
+	return []Note{n}, nil
+}
+
+func isDaily(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if dailyHeading.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitDaily(doc *Document) []Note {
+	day := doc.Time()
+	title := ""
+	var notes []Note
+	var cur *Note
+	var text []string
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		body := strings.TrimSpace(strings.Join(text, "\n"))
+		body = strings.TrimSpace(strings.TrimSuffix(body, "---"))
+		if body != "" {
+			cur.Text = body
+			notes = append(notes, *cur)
+		}
+		cur, text = nil, nil
+	}
+
+	for _, line := range strings.Split(doc.Body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if m := dailyHeading.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			clock := m[1]
+			if strings.Count(clock, ":") == 1 {
+				clock += ":00"
+			}
+			t, err := time.Parse("15:04:05", clock)
+			if err != nil {
+				t = time.Time{}
+			}
+			ts := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, day.Location())
+			cur = &Note{Time: ts, Title: title, Language: m[2], Tags: doc.Tags(), Source: doc.Path}
+			continue
+		}
+		if cur == nil {
+			if m := dailyTitle.FindStringSubmatch(trimmed); m != nil {
+				title = m[1]
+			}
+			continue
+		}
+		text = append(text, line)
+	}
+	flush()
+	return notes
+}
+
+// PlanMigration reads every .md file in opts.SourceDir and computes the
+// files the migration would write. Nothing on disk changes.
+func PlanMigration(opts MigrateOptions) (*MigrationPlan, error) {
+	if opts.SourceDir == "" {
+		return nil, errors.New("source directory is required")
+	}
+	opts.SourceDir = ExpandDir(opts.SourceDir)
+	if opts.DestDir == "" {
+		opts.DestDir = opts.SourceDir
+	}
+	opts.DestDir = ExpandDir(opts.DestDir)
+	switch opts.Layout {
+	case LayoutEntry, LayoutDaily:
+	default:
+		return nil, fmt.Errorf("unknown layout %q (want %q or %q)", opts.Layout, LayoutEntry, LayoutDaily)
+	}
+	if opts.Template == "" {
+		opts.Template = DefaultEntryTemplate
+		if opts.Layout == LayoutDaily {
+			opts.Template = DefaultDailyTemplate
+		}
+	}
+	switch opts.DailyOrder {
+	case "":
+		opts.DailyOrder = DailyOrderBottom
+	case DailyOrderBottom, DailyOrderTop:
+	default:
+		return nil, fmt.Errorf("unknown daily order %q (want %q or %q)", opts.DailyOrder, DailyOrderBottom, DailyOrderTop)
+	}
+	if strings.ContainsAny(opts.Template, `/\`) {
+		return nil, fmt.Errorf("template %q must be a file name, not a path", opts.Template)
+	}
+	if opts.DateFormat == "" {
+		opts.DateFormat = "2006-01-02"
+	}
+	if opts.Title == "" {
+		opts.Title = "Dictation"
+	}
+
+	sources, err := filepath.Glob(filepath.Join(opts.SourceDir, "*.md"))
+	if err != nil {
+		return nil, fmt.Errorf("glob vault dir: %w", err)
+	}
+	sort.Strings(sources)
+
+	plan := &MigrationPlan{Options: opts}
+	var notes []Note
+	for _, path := range sources {
+		ns, err := ReadNotes(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(ns) == 0 {
+			plan.Warnings = append(plan.Warnings, filepath.Base(path)+": no dictation text — left in place")
+			continue
+		}
+		plan.Sources = append(plan.Sources, path)
+		notes = append(notes, ns...)
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Time.Before(notes[j].Time) })
+	plan.Notes = len(notes)
+
+	if opts.Layout == LayoutEntry {
+		for _, n := range notes {
+			plan.add(opts.render(n, DefaultEntryTemplate), opts.entryBytes(n), n.Source)
+		}
+	} else {
+		var dropped int
+		for _, day := range groupByDay(notes) {
+			var srcs []string
+			for _, n := range day {
+				srcs = append(srcs, n.Source)
+				if len(n.Extra) > 0 {
+					dropped++
+				}
+			}
+			plan.add(opts.render(day[0], DefaultDailyTemplate), opts.dailyBytes(day), srcs...)
+		}
+		if dropped > 0 {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"%d note(s) have extra frontmatter (e.g. summary) that the daily layout can't hold — kept only in the backup", dropped))
+		}
+	}
+
+	// Refuse to overwrite anything that isn't itself being migrated.
+	moving := map[string]bool{}
+	for _, s := range plan.Sources {
+		moving[s] = true
+	}
+	for _, o := range plan.Outputs {
+		if _, err := os.Stat(o.Path); err == nil && !moving[o.Path] {
+			return nil, fmt.Errorf("%s already exists and is not part of the migration", o.Path)
+		}
+	}
+	return plan, nil
+}
+
+// add appends an output, suffixing " (2)", " (3)"… on name collisions.
+func (p *MigrationPlan) add(name string, data []byte, sources ...string) {
+	base := name
+	for i := 2; ; i++ {
+		taken := false
+		for _, o := range p.Outputs {
+			if strings.EqualFold(filepath.Base(o.Path), base+".md") {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			break
+		}
+		base = fmt.Sprintf("%s (%d)", name, i)
+	}
+	p.Outputs = append(p.Outputs, MigrationOutput{
+		Path:    filepath.Join(p.Options.DestDir, base+".md"),
+		Sources: dedupe(sources),
+		data:    data,
+	})
+}
+
+func dedupe(in []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func groupByDay(notes []Note) [][]Note {
+	var days [][]Note
+	for _, n := range notes {
+		if len(days) > 0 {
+			last := days[len(days)-1]
+			y1, m1, d1 := last[0].Time.Date()
+			y2, m2, d2 := n.Time.Date()
+			if y1 == y2 && m1 == m2 && d1 == d2 {
+				days[len(days)-1] = append(last, n)
+				continue
+			}
+		}
+		days = append(days, []Note{n})
+	}
+	return days
+}
+
+// render expands the filename template for n. fallback is used only if the
+// template expands to nothing.
+func (o MigrateOptions) render(n Note, fallback string) string {
+	title := n.Title
+	if title == "" {
+		title = o.Title
+	}
+	lang := n.Language
+	if lang == "" {
+		lang = "und"
+	}
+	r := strings.NewReplacer(
+		"{title}", safeName(title),
+		"{date}", safeName(n.Time.Format(o.DateFormat)),
+		"{time}", n.Time.Format("15-04-05"),
+		"{lang}", safeName(lang),
+	)
+	name := strings.TrimSpace(r.Replace(o.Template))
+	if name == "" {
+		name = strings.TrimSpace(r.Replace(fallback))
+	}
+	return name
+}
+
+func (o MigrateOptions) entryBytes(n Note) []byte {
+	title := n.Title
+	if title == "" {
+		title = o.Title
+	}
+	tags := n.Tags
+	if len(tags) == 0 {
+		tags = DefaultTags
+	}
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", title)
+	fmt.Fprintf(&b, "date: %s\n", n.Time.Format("2006-01-02T15:04:05"))
+	if n.Language != "" && n.Language != "und" {
+		fmt.Fprintf(&b, "language: %s\n", n.Language)
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(tags, ", "))
+	for _, l := range n.Extra {
+		b.WriteString(l + "\n")
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(n.Text + "\n")
+	return o.rewrite(b.String())
+}
+
+func (o MigrateOptions) dailyBytes(day []Note) []byte {
+	date := day[0].Time.Format("2006-01-02")
+	title := day[0].Title
+	if title == "" {
+		title = o.Title
+	}
+	var tags []string
+	seen := map[string]bool{}
+	for _, n := range day {
+		for _, t := range n.Tags {
+			if !seen[strings.ToLower(t)] {
+				seen[strings.ToLower(t)] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		tags = DefaultTags
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(tags, ", "))
+	fmt.Fprintf(&b, "date: %s\n", date)
+	b.WriteString("---\n\n")
+	fmt.Fprintf(&b, "# 🎙️ %s — %s\n", title, date)
+
+	// day is oldest first; DailyOrderTop reads like a feed instead.
+	ordered := day
+	if o.DailyOrder == DailyOrderTop {
+		ordered = make([]Note, len(day))
+		for i, n := range day {
+			ordered[len(day)-1-i] = n
+		}
+	}
+	headings := make([]string, len(ordered))
+	for i, n := range ordered {
+		headings[i] = n.Time.Format("15:04:05")
+		if n.Language != "" && n.Language != "und" {
+			headings[i] += " (" + n.Language + ")"
+		}
+	}
+	if o.DailyTOC {
+		// WHY wiki links? [[#heading]] jumps to the section in Obsidian and
+		// Logseq. The list sits before the first "## " heading, where
+		// ReadNotes ignores everything but the title.
+		b.WriteString("\n")
+		for _, h := range headings {
+			fmt.Fprintf(&b, "- [[#%s|%s]]\n", h, h[:8])
+		}
+	}
+	for i, n := range ordered {
+		if i > 0 {
+			b.WriteString("\n---\n")
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", headings[i], n.Text)
+	}
+	return o.rewrite(b.String())
+}
+
+// rewrite applies Set/Drop to rendered frontmatter.
+func (o MigrateOptions) rewrite(content string) []byte {
+	if len(o.Set) == 0 && len(o.Drop) == 0 {
+		return []byte(content)
+	}
+	doc := ParseDocument("", []byte(content))
+	for _, k := range o.Drop {
+		doc.Delete(k)
+	}
+	keys := make([]string, 0, len(o.Set))
+	for k := range o.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		doc.Set(k, o.Set[k])
+	}
+	return doc.Bytes()
+}
+
+// Manifest records what Apply did so Rollback can undo it.
+type Manifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	SourceDir string          `json:"source_dir"`
+	DestDir   string          `json:"dest_dir"`
+	BackupDir string          `json:"backup_dir"`
+	Originals []MovedFile     `json:"originals"`
+	Created   []CreatedFile   `json:"created"`
+	Complete  bool            `json:"complete"`
+	Options   json.RawMessage `json:"options,omitempty"`
+}
+
+// MovedFile is an original moved into the backup directory.
+type MovedFile struct {
+	Path   string `json:"path"`
+	Backup string `json:"backup"`
+}
+
+// CreatedFile is a file written by the migration, with its content hash so
+// Rollback never deletes a file the user has since edited.
+type CreatedFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Apply executes the plan: originals move to a backup directory inside the
+// source vault, then every output is written. Any failure rolls back
+// automatically. Returns the manifest path for a later Rollback.
+func (p *MigrationPlan) Apply() (string, error) {
+	if len(p.Sources) == 0 {
+		return "", errors.New("nothing to migrate")
+	}
+	now := time.Now()
+	m := &Manifest{
+		CreatedAt: now.UTC(),
+		SourceDir: p.Options.SourceDir,
+		DestDir:   p.Options.DestDir,
+		// WHY a dot-directory? Obsidian and Logseq ignore hidden folders, and
+		// Scan only globs the top level, so backups never show up as notes.
+		BackupDir: filepath.Join(p.Options.SourceDir, ".migrate-"+now.Format("20060102-150405")),
+	}
+	m.Options, _ = json.Marshal(p.Options)
+	manifestPath := filepath.Join(m.BackupDir, ManifestName)
+
+	if err := os.Mkdir(m.BackupDir, 0755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+	if err := os.MkdirAll(p.Options.DestDir, 0755); err != nil {
+		return "", fmt.Errorf("create destination: %w", err)
+	}
+
+	fail := func(err error) (string, error) {
+		if rbErr := m.rollback(); rbErr != nil {
+			return manifestPath, fmt.Errorf("%w (automatic rollback incomplete: %v)", err, rbErr)
+		}
+		os.RemoveAll(m.BackupDir)
+		return "", err
+	}
+
+	for _, src := range p.Sources {
+		backup := filepath.Join(m.BackupDir, filepath.Base(src))
+		if err := os.Rename(src, backup); err != nil {
+			return fail(fmt.Errorf("back up %s: %w", filepath.Base(src), err))
+		}
+		m.Originals = append(m.Originals, MovedFile{Path: src, Backup: backup})
+		if err := m.save(manifestPath); err != nil {
+			return fail(err)
+		}
+	}
+	for _, o := range p.Outputs {
+		if err := WriteFileAtomic(o.Path, o.data); err != nil {
+			return fail(fmt.Errorf("write %s: %w", filepath.Base(o.Path), err))
+		}
+		m.Created = append(m.Created, CreatedFile{Path: o.Path, SHA256: hashBytes(o.data)})
+		if err := m.save(manifestPath); err != nil {
+			return fail(err)
+		}
+	}
+	m.Complete = true
+	if err := m.save(manifestPath); err != nil {
+		return fail(err)
+	}
+	return manifestPath, nil
+}
+
+// Rollback undoes a migration from its manifest. Files the user has edited
+// since the migration are left in place and reported; everything else is
+// restored. The backup directory is removed only on a complete rollback.
+func Rollback(manifestPath string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parse manifest: %w", err)
+	}
+	if err := m.rollback(); err != nil {
+		return err
+	}
+	return os.RemoveAll(m.BackupDir)
+}
+
+func (m *Manifest) rollback() error {
+	var errs []error
+	for i := len(m.Created) - 1; i >= 0; i-- {
+		c := m.Created[i]
+		data, err := os.ReadFile(c.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if hashBytes(data) != c.SHA256 {
+			errs = append(errs, fmt.Errorf("%s was modified after the migration — left in place", c.Path))
+			continue
+		}
+		if err := os.Remove(c.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, o := range m.Originals {
+		if _, err := os.Stat(o.Path); err == nil {
+			errs = append(errs, fmt.Errorf("%s exists — not restoring over it (original kept at %s)", o.Path, o.Backup))
+			continue
+		}
+		if err := os.Rename(o.Backup, o.Path); err != nil {
+			errs = append(errs, fmt.Errorf("restore %s: %w", filepath.Base(o.Path), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manifest) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,228 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const dailySample = "---\ntags: [dictation, auto-generated]\ndate: 2026-02-20\n---\n\n# 🎙️ Dictation — 2026-02-20\n\n## 12:52:05 (en)\n\nHello there.\n\n---\n\n## 14:06:46 (fr)\n\nBonjour.\n"
+
+const entrySample = "---\ntitle: Dictation\ndate: 2026-02-20T16:00:00\nlanguage: en\ntags: [dictation, auto-generated]\nsummary: \"A note\"\n---\n\nLater note.\n"
+
+func listMD(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	var names []string
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReadNotesDaily(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "2026-02-20.md")
+	os.WriteFile(path, []byte(dailySample), 0644)
+
+	notes, err := ReadNotes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("got %d notes, want 2", len(notes))
+	}
+	if notes[0].Text != "Hello there." || notes[0].Language != "en" || notes[0].Title != "Dictation" {
+		t.Errorf("note 0 = %+v", notes[0])
+	}
+	if got := notes[1].Time.Format("2006-01-02T15:04:05"); got != "2026-02-20T14:06:46" {
+		t.Errorf("note 1 time = %s", got)
+	}
+}
+
+func TestMigrateDailyToEntry(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2026-02-20.md"), []byte(dailySample), 0644)
+
+	plan, err := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutEntry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Notes != 2 || len(plan.Outputs) != 2 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if _, err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Dictation 2026-02-20 12-52-05.md", "Dictation 2026-02-20 14-06-46.md"}
+	if got := listMD(t, dir); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	entries, _ := Scan(dir, 10, testLogger())
+	if len(entries) != 2 || entries[0].Text != "Bonjour." || entries[0].Language != "fr" {
+		t.Errorf("scan after migrate = %+v", entries)
+	}
+}
+
+func TestMigrateEntryToDailyRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2026-02-20.md"), []byte(dailySample), 0644)
+	os.WriteFile(filepath.Join(dir, "Dictation 2026-02-20 16-00-00.md"), []byte(entrySample), 0644)
+
+	plan, err := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutDaily})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Outputs) != 1 || len(plan.Warnings) != 1 {
+		t.Fatalf("outputs = %d, warnings = %v", len(plan.Outputs), plan.Warnings)
+	}
+	if _, err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listMD(t, dir); len(got) != 1 || got[0] != "2026-02-20.md" {
+		t.Fatalf("files = %v", got)
+	}
+	notes, _ := ReadNotes(filepath.Join(dir, "2026-02-20.md"))
+	if len(notes) != 3 || notes[2].Text != "Later note." {
+		t.Errorf("notes = %+v", notes)
+	}
+}
+
+func TestMigrateDailyOrderAndTOC(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2026-02-20.md"), []byte(dailySample), 0644)
+	os.WriteFile(filepath.Join(dir, "Dictation 2026-02-20 16-00-00.md"), []byte(entrySample), 0644)
+
+	if _, err := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutDaily, DailyOrder: "middle"}); err == nil {
+		t.Error("unknown daily order accepted")
+	}
+	plan, err := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutDaily, DailyOrder: DailyOrderTop, DailyTOC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(plan.Outputs[0].data)
+	want := "# 🎙️ Dictation — 2026-02-20\n\n" +
+		"- [[#16:00:00 (en)|16:00:00]]\n- [[#14:06:46 (fr)|14:06:46]]\n- [[#12:52:05 (en)|12:52:05]]\n\n" +
+		"## 16:00:00 (en)\n\nLater note.\n\n---\n\n## 14:06:46 (fr)\n\nBonjour.\n\n---\n\n## 12:52:05 (en)\n\nHello there.\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("daily file =\n%s\nwant it to end with\n%s", got, want)
+	}
+
+	// Newest-first with a TOC still reads back as the same notes.
+	if _, err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	notes, _ := ReadNotes(filepath.Join(dir, "2026-02-20.md"))
+	if len(notes) != 3 || notes[0].Text != "Later note." || notes[2].Text != "Hello there." || notes[2].Title != "Dictation" {
+		t.Errorf("notes = %+v", notes)
+	}
+}
+
+func TestMigrateTemplateDestAndFrontmatter(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "new")
+	os.WriteFile(filepath.Join(src, "Dictation 2026-02-20 16-00-00.md"), []byte(entrySample), 0644)
+
+	plan, err := PlanMigration(MigrateOptions{
+		SourceDir:  src,
+		DestDir:    dst,
+		Layout:     LayoutEntry,
+		Template:   "{date} {time} {lang}",
+		DateFormat: "20060102",
+		Set:        map[string]string{"source": "captainslog"},
+		Drop:       []string{"summary"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listMD(t, src); len(got) != 0 {
+		t.Errorf("source still has %v", got)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "20260220 16-00-00 en.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "source: captainslog\n") || strings.Contains(string(data), "summary:") {
+		t.Errorf("frontmatter not rewritten:\n%s", data)
+	}
+}
+
+func TestMigrateNameCollision(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.md"), []byte(entrySample), 0644)
+	os.WriteFile(filepath.Join(dir, "b.md"), []byte(entrySample), 0644)
+
+	plan, err := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutEntry, Template: "{title}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(plan.Outputs[0].Path) != "Dictation.md" || filepath.Base(plan.Outputs[1].Path) != "Dictation (2).md" {
+		t.Errorf("outputs = %v, %v", plan.Outputs[0].Path, plan.Outputs[1].Path)
+	}
+}
+
+func TestMigrateRefusesToOverwrite(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(src, "x.md"), []byte(entrySample), 0644)
+	os.WriteFile(filepath.Join(dst, "Dictation 2026-02-20 16-00-00.md"), []byte("mine"), 0644)
+
+	if _, err := PlanMigration(MigrateOptions{SourceDir: src, DestDir: dst, Layout: LayoutEntry}); err == nil {
+		t.Error("expected an error for an existing unrelated file")
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2026-02-20.md"), []byte(dailySample), 0644)
+	before := listMD(t, dir)
+
+	plan, _ := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutEntry})
+	manifest, err := plan.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Rollback(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if got := listMD(t, dir); strings.Join(got, "|") != strings.Join(before, "|") {
+		t.Errorf("after rollback files = %v, want %v", got, before)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "2026-02-20.md"))
+	if string(data) != dailySample {
+		t.Error("original content not restored")
+	}
+	if _, err := os.Stat(filepath.Dir(manifest)); !os.IsNotExist(err) {
+		t.Error("backup dir should be removed after a full rollback")
+	}
+}
+
+func TestMigrateRollbackKeepsEditedFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "x.md"), []byte(entrySample), 0644)
+
+	plan, _ := PlanMigration(MigrateOptions{SourceDir: dir, Layout: LayoutEntry})
+	manifest, err := plan.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := plan.Outputs[0].Path
+	os.WriteFile(edited, []byte("user edit"), 0644)
+
+	if err := Rollback(manifest); err == nil {
+		t.Fatal("expected an error reporting the edited file")
+	}
+	if data, _ := os.ReadFile(edited); string(data) != "user edit" {
+		t.Error("edited file was removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x.md")); err != nil {
+		t.Error("original should still be restored")
+	}
+	if _, err := os.Stat(manifest); err != nil {
+		t.Error("manifest should be kept after a partial rollback")
+	}
+}
@@ -0,0 +1,39 @@
+// Package vault — pinned notes.
+// A `pinned: true` frontmatter line keeps a note at the top of the history,
+// so important logs don't scroll away under the daily memos. The pin lives
+// in the note rather than the transcript index: it survives an index
+// rebuild, and it can be seen and edited in Obsidian like any other key.
+package vault
+
+import "strings"
+
+// ParsePinned reads a pinned: frontmatter value. Anything but true/yes is
+// unpinned.
+func ParsePinned(v string) bool {
+	v = strings.ToLower(strings.Trim(strings.TrimSpace(v), `"'`))
+	return v == "true" || v == "yes"
+}
+
+// Pinned reports whether the document is pinned.
+func (d *Document) Pinned() bool {
+	return ParsePinned(d.Get("pinned"))
+}
+
+// SetPinned pins or unpins the note at path. Unpinning removes the key
+// rather than writing false, leaving notes that were never pinned as they
+// were.
+func SetPinned(path string, pinned bool) error {
+	doc, err := ReadDocument(path)
+	if err != nil {
+		return err
+	}
+	if doc.Pinned() == pinned {
+		return nil
+	}
+	if pinned {
+		doc.Set("pinned", "true")
+	} else {
+		doc.Delete("pinned")
+	}
+	return doc.Save()
+}
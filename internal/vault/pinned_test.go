@@ -0,0 +1,39 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetPinned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.md")
+	orig := "---\ntitle: Dictation\ndate: 2026-02-21T11:44:58\n---\n\nKeep this one.\n"
+	os.WriteFile(path, []byte(orig), 0644)
+
+	if err := SetPinned(path, true); err != nil {
+		t.Fatal(err)
+	}
+	e, err := ReadEntry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Pinned || e.Text != "Keep this one." {
+		t.Errorf("after pin = %+v", e)
+	}
+
+	if err := SetPinned(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != orig {
+		t.Errorf("unpin should restore the note, got:\n%s", data)
+	}
+}
+
+func TestParsePinned(t *testing.T) {
+	for in, want := range map[string]bool{"true": true, " Yes ": true, `"true"`: true, "false": false, "": false, "1": false} {
+		if got := ParsePinned(in); got != want {
+			t.Errorf("ParsePinned(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
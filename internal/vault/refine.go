@@ -0,0 +1,72 @@
+// Package vault — refined transcriptions.
+// Two-pass dictation saves a fast model's draft at once and replaces it
+// when the large model's transcription of the same recording is ready.
+// Only the text and model are replaced: a note's title, tags and template
+// stay as they were saved.
+package vault
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// Refine replaces the draft text of a transcription in note with n.Text,
+// a "model" frontmatter key naming draftModel with n.Model, and the
+// note's sidecar, if it has one, with n's segments and words. added is
+// what the save of the draft returned: in a daily note only its own entry
+// is searched, wherever newer entries have put it since. It reports
+// false, and changes nothing, when the note no longer holds draft there:
+// it was edited since, and the edit wins.
+func Refine(note, draft, draftModel string, added Added, n Transcription) (bool, error) {
+	draft, refined := strings.TrimSpace(draft), strings.TrimSpace(n.Text)
+	// Held like a daily save: an entry added meanwhile must not be
+	// overwritten by the rewrite.
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	data, err := os.ReadFile(note)
+	if err != nil {
+		return false, err
+	}
+	content := string(data)
+	lo, hi := 0, len(content)
+	if added.daily {
+		hi = int(added.End)
+		if added.top {
+			hi = len(content) - int(added.tail)
+		}
+		lo = hi - int(added.span)
+		if lo < 0 || hi > len(content) {
+			return false, nil
+		}
+	}
+	at := strings.LastIndex(content[lo:hi], draft)
+	if draft == "" || at < 0 {
+		return false, nil
+	}
+	at += lo
+	data = []byte(content[:at] + refined + content[at+len(draft):])
+	if doc := ParseDocument(note, data); draftModel != "" && Unquote(doc.Get("model")) == draftModel {
+		doc.Set("model", n.Model)
+		data = doc.Bytes()
+	}
+	if _, err := WriteVerified(note, data); err != nil {
+		return false, err
+	}
+
+	side, err := os.ReadFile(SidecarPath(note))
+	if err != nil {
+		// No sidecar, or an unreadable one: the note is what matters.
+		return true, nil
+	}
+	var old Sidecar
+	created := time.Now()
+	if json.Unmarshal(side, &old) == nil && !old.CreatedAt.IsZero() {
+		created = old.CreatedAt
+	}
+	if n.Language == "" {
+		n.Language = old.Language
+	}
+	return true, writeSidecar(note, newSidecar(note, n, created))
+}
@@ -0,0 +1,109 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WHY a sidecar? A note holds text; Whisper's verbose output also times
+// every segment and word and labels speakers. Markdown has no good place
+// for that, and without it the timing is lost once the session ends. A
+// JSON file beside the note keeps it for players, subtitle tools and
+// scripts, and the note links to it.
+
+// Word is one word of a transcription with its timing, as in Whisper's
+// verbose_json with word timestamps.
+type Word struct {
+	Word        string  `json:"word"`
+	Start       float64 `json:"start"` // seconds
+	End         float64 `json:"end"`   // seconds
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// Sidecar is the JSON file written beside a note with WithSidecar.
+type Sidecar struct {
+	Note      string    `json:"note"` // the note's file name
+	Text      string    `json:"text"`
+	Language  string    `json:"language,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Duration  float64   `json:"duration,omitempty"` // seconds, end of the last segment or word
+	Speakers  []string  `json:"speakers,omitempty"` // diarization labels, in order of first appearance
+	Segments  []Segment `json:"segments"`
+	Words     []Word    `json:"words,omitempty"`
+}
+
+// WithSidecar makes Save write each note's segments, word timings, model
+// and speakers to a JSON file beside it, linked from the note. Daily notes
+// get none: they hold many transcriptions. Returns v for chaining.
+func (v *Vault) WithSidecar(on bool) *Vault {
+	if v != nil {
+		v.sidecar = on
+	}
+	return v
+}
+
+// SidecarPath is where the sidecar of note goes: the note's path with
+// .json in place of .md.
+func SidecarPath(note string) string {
+	return strings.TrimSuffix(note, filepath.Ext(note)) + ".json"
+}
+
+// RemoveSidecar deletes the sidecar of note, if it has one.
+func RemoveSidecar(note string) error {
+	err := os.Remove(SidecarPath(note))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// newSidecar collects what a transcription's sidecar holds.
+func newSidecar(note string, n Transcription, created time.Time) Sidecar {
+	sc := Sidecar{
+		Note:      filepath.Base(note),
+		Text:      strings.TrimSpace(n.Text),
+		Language:  n.Language,
+		Model:     n.Model,
+		CreatedAt: created.UTC(),
+		Segments:  n.Segments,
+		Words:     n.Words,
+	}
+	if sc.Segments == nil {
+		sc.Segments = []Segment{}
+	}
+	seen := map[string]bool{}
+	for _, seg := range n.Segments {
+		sc.Duration = max(sc.Duration, seg.End)
+		if seg.Speaker != "" && !seen[seg.Speaker] {
+			seen[seg.Speaker] = true
+			sc.Speakers = append(sc.Speakers, seg.Speaker)
+		}
+	}
+	for _, w := range n.Words {
+		sc.Duration = max(sc.Duration, w.End)
+	}
+	return sc
+}
+
+// writeSidecar writes the sidecar of note.
+func writeSidecar(note string, sc Sidecar) error {
+	data, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := WriteVerified(SidecarPath(note), append(data, '\n')); err != nil {
+		return fmt.Errorf("write sidecar: %w", err)
+	}
+	return nil
+}
+
+// sidecarLink is the line the built-in note format links the sidecar
+// with. Angle brackets keep a file name with spaces one link target.
+func sidecarLink(name string) string {
+	return fmt.Sprintf("[Segments and timing](<%s>)", name)
+}
@@ -0,0 +1,113 @@
+package vault
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveSidecar(t *testing.T) {
+	dir := t.TempDir()
+	v := New(dir, "", "", slog.Default()).WithSidecar(true)
+	file, _, err := v.SaveTranscription(Transcription{
+		Text:     "hello there world",
+		Language: "en",
+		Model:    "large-v3",
+		Segments: []Segment{{Start: 0, End: 1.5, Text: "hello there", Speaker: "SPEAKER_01"}, {Start: 2, End: 3, Text: "world", Speaker: "SPEAKER_00"}},
+		Words:    []Word{{Word: "hello", Start: 0, End: 0.5}, {Word: "world", Start: 2, End: 3.2, Probability: 0.9}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	side := SidecarPath(file)
+	if filepath.Dir(side) != dir || !strings.HasSuffix(side, ".json") {
+		t.Fatalf("sidecar path = %s", side)
+	}
+	data, err := os.ReadFile(side)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sc Sidecar
+	if err := json.Unmarshal(data, &sc); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Note != filepath.Base(file) || sc.Model != "large-v3" || sc.Duration != 3.2 || len(sc.Segments) != 2 || len(sc.Words) != 2 {
+		t.Errorf("sidecar = %+v", sc)
+	}
+	if strings.Join(sc.Speakers, ",") != "SPEAKER_01,SPEAKER_00" {
+		t.Errorf("speakers = %v, want first-appearance order", sc.Speakers)
+	}
+	note, _ := os.ReadFile(file)
+	if link := sidecarLink(filepath.Base(side)); !strings.Contains(string(note), link) {
+		t.Errorf("note lacks %q:\n%s", link, note)
+	}
+
+	// Undo takes the sidecar with the note.
+	u := &UndoLog{}
+	u.Record(file, "")
+	if _, err := u.Undo(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(side); !os.IsNotExist(err) {
+		t.Error("sidecar left behind after undo")
+	}
+}
+
+func TestSaveWithoutSidecar(t *testing.T) {
+	dir := t.TempDir()
+	file, err := New(dir, "", "", slog.Default()).Save("plain", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(SidecarPath(file)); !os.IsNotExist(err) {
+		t.Error("sidecar written without WithSidecar")
+	}
+	if note, _ := os.ReadFile(file); strings.Contains(string(note), "Segments and timing") {
+		t.Errorf("note links a sidecar it doesn't have:\n%s", note)
+	}
+}
+
+func TestRefine(t *testing.T) {
+	dir := t.TempDir()
+	v := New(dir, "", "", slog.Default()).WithSidecar(true)
+	file, added, err := v.SaveTranscription(Transcription{Text: "send teh report", Language: "en", Model: "base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := Refine(file, "send teh report", "base", added, Transcription{Text: "Send the report.", Model: "large-v3",
+		Segments: []Segment{{Start: 0, End: 1.2, Text: "Send the report."}}})
+	if err != nil || !ok {
+		t.Fatalf("Refine = %v, %v", ok, err)
+	}
+	data, _ := os.ReadFile(file)
+	if !strings.Contains(string(data), "Send the report.") || strings.Contains(string(data), "teh") {
+		t.Errorf("note:\n%s", data)
+	}
+	var sc Sidecar
+	side, _ := os.ReadFile(SidecarPath(file))
+	if err := json.Unmarshal(side, &sc); err != nil || sc.Model != "large-v3" || sc.Language != "en" || sc.Text != "Send the report." || sc.Duration != 1.2 {
+		t.Errorf("sidecar = %+v, %v", sc, err)
+	}
+
+	doc := ParseDocument(file, []byte("---\nmodel: base\n---\n\nsend teh report\n"))
+	doc.Save()
+	if ok, _ := Refine(file, "send teh report", "base", Added{}, Transcription{Text: "Send the report.", Model: "large-v3"}); !ok {
+		t.Fatal("frontmatter note not refined")
+	}
+	if doc, _ := ReadDocument(file); doc.Get("model") != "large-v3" || strings.TrimSpace(doc.Body) != "Send the report." {
+		t.Errorf("frontmatter note = %q, body %q", doc.Get("model"), doc.Body)
+	}
+
+	// An edited note keeps the edit.
+	os.WriteFile(file, []byte("my own words\n"), 0o644)
+	if ok, err := Refine(file, "Send the report.", "large-v3", Added{}, Transcription{Text: "other"}); ok || err != nil {
+		t.Errorf("edited note: %v, %v", ok, err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "my own words\n" {
+		t.Errorf("edited note changed: %q", data)
+	}
+}
@@ -0,0 +1,86 @@
+package vault
+
+import "strings"
+
+// DefaultTags are the frontmatter tags of a saved dictation when none are
+// configured.
+var DefaultTags = []string{"dictation", "auto-generated"}
+
+// ParseTags parses the inline list form of a tags value: "[a, b]".
+func ParseTags(v string) []string {
+	v = strings.TrimSpace(v)
+	v = strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+	var tags []string
+	for _, t := range strings.Split(v, ",") {
+		t = strings.Trim(strings.TrimSpace(t), `"'`)
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// FormatTags writes tags in the inline list form ParseTags reads.
+func FormatTags(tags []string) string {
+	return "[" + strings.Join(tags, ", ") + "]"
+}
+
+// CleanTags makes user-supplied tags safe for the inline list: a leading
+// '#' is dropped, spaces become '-', characters that would break the list
+// or YAML ([ ] , " ' : and controls) are removed, and duplicates (ignoring
+// case) and empty tags are left out. Order is kept.
+func CleanTags(tags []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.TrimLeft(strings.TrimSpace(t), "#")
+		t = strings.Map(func(r rune) rune {
+			switch {
+			case r == ' ' || r == '\t':
+				return '-'
+			case strings.ContainsRune(`[],"':`, r), r < 0x20:
+				return -1
+			}
+			return r
+		}, t)
+		t = strings.Trim(t, "-")
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// AddTags adds tags to the frontmatter of the note at path. Tags it
+// already has (ignoring case) are not repeated; a note with all of them
+// is left untouched.
+func AddTags(path string, tags []string) error {
+	doc, err := ReadDocument(path)
+	if err != nil {
+		return err
+	}
+	have := doc.Tags()
+	merged := have
+	for _, t := range CleanTags(tags) {
+		if !doc.HasTag(t) {
+			merged = append(merged, t)
+		}
+	}
+	if len(merged) == len(have) {
+		return nil
+	}
+	doc.SetTags(merged)
+	return doc.Save()
+}
+
+// HasTag reports whether the entry is tagged with tag (case-insensitive).
+func (e Entry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
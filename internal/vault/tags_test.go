@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanTags(t *testing.T) {
+	got := CleanTags([]string{" #Meeting ", "project x", "a,b", "[x]", "meeting", "", "#", "key: val"})
+	want := "Meeting|project-x|ab|x|key-val"
+	if strings.Join(got, "|") != want {
+		t.Errorf("CleanTags = %q, want %q", strings.Join(got, "|"), want)
+	}
+	if got := ParseTags(FormatTags([]string{"a", "b-c"})); strings.Join(got, "|") != "a|b-c" {
+		t.Errorf("round trip = %v", got)
+	}
+}
+
+func TestSaveTags(t *testing.T) {
+	dir := t.TempDir()
+	v := New(dir, "", "", slog.Default()).WithTags([]string{"journal", "voice"})
+	file, err := v.Save("Standup notes", "en", "work", "Journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(file)
+	if !strings.Contains(string(data), "tags: [journal, voice, work]\n") {
+		t.Errorf("note = %s", data)
+	}
+	entries, _ := Scan(dir, 10, slog.Default())
+	if len(entries) != 1 || !entries[0].HasTag("WORK") || entries[0].HasTag("dictation") {
+		t.Errorf("scanned entry tags = %+v", entries)
+	}
+
+	// No configured tags: the defaults.
+	file, _ = New(filepath.Join(dir, "b"), "", "", slog.Default()).WithTags(nil).Save("x y", "")
+	data, _ = os.ReadFile(file)
+	if !strings.Contains(string(data), "tags: [dictation, auto-generated]\n") {
+		t.Errorf("default note = %s", data)
+	}
+}
+
+func TestAddTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.md")
+	orig := "---\ntitle: Call\ntags: [dictation, Urgent]\n---\n\nThe invoice is urgent.\n"
+	os.WriteFile(path, []byte(orig), 0644)
+
+	if err := AddTags(path, []string{"urgent", "Acme Corp"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "---\ntitle: Call\ntags: [dictation, Urgent, Acme-Corp]\n---\n\nThe invoice is urgent.\n"; string(data) != want {
+		t.Errorf("note =\n%s\nwant\n%s", data, want)
+	}
+	info, _ := os.Stat(path)
+	if err := AddTags(path, []string{"acme-corp"}); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.Stat(path); !after.ModTime().Equal(info.ModTime()) {
+		t.Error("a note with all the tags should not be rewritten")
+	}
+}
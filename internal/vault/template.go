@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// NoteData is what a note template can use.
+type NoteData struct {
+	Text     string    // the transcription
+	Title    string    // the note's title, else the file title setting, e.g. "Dictation"
+	Summary  string    // one-line summary, "" if none (see WithDescriber)
+	Language string    // language code, "" if unknown
+	Model    string    // Whisper model that transcribed it, "" if unknown
+	Stardate string    // e.g. "103021.4"
+	Date     string    // 2006-01-02
+	Time     string    // 15:04:05
+	DateTime string    // 2006-01-02T15:04:05, as in the default frontmatter
+	Tags     string    // inline list for the frontmatter: [dictation, meeting]
+	TagList  []string  // the same tags, to range over
+	Segments []Segment // empty for text-only saves
+	Sidecar  string    // file name of the JSON sidecar, "" if none (see WithSidecar)
+	// Translation is the text side by side with its translation, as
+	// markdown, "" if none (the language-learning mode).
+	Translation string
+}
+
+// Segment is a timed piece of a transcription, as a template sees it.
+type Segment struct {
+	Start   float64 `json:"start"` // seconds
+	End     float64 `json:"end"`   // seconds
+	Text    string  `json:"text"`
+	Speaker string  `json:"speaker,omitempty"`
+}
+
+// templateFuncs are available in note templates: clock formats seconds as
+// mm:ss (h:mm:ss from an hour on), for segment timestamps.
+var templateFuncs = template.FuncMap{
+	"clock": func(sec float64) string {
+		t := int(max(sec, 0))
+		if t >= 3600 {
+			return fmt.Sprintf("%d:%02d:%02d", t/3600, t/60%60, t%60)
+		}
+		return fmt.Sprintf("%02d:%02d", t/60, t%60)
+	},
+}
+
+// WithTemplate makes Save write notes from the template file at path (a
+// text/template over NoteData), relative to the vault unless absolute.
+// Empty keeps the built-in format. Returns v for chaining.
+func (v *Vault) WithTemplate(path string) *Vault {
+	if v != nil {
+		v.template = path
+	}
+	return v
+}
+
+// templatePath is where the template file is.
+func templatePath(dir, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("note template %q must be inside the vault or an absolute path", path)
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// parseTemplate reads and parses the note template at path.
+func parseTemplate(dir, path string) (*template.Template, error) {
+	file, err := templatePath(dir, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read note template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(file)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("note template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// CheckTemplate reports whether the note template at path, relative to
+// the vault dir unless absolute, can be read and parsed.
+func CheckTemplate(dir, path string) error {
+	if path == "" {
+		return nil
+	}
+	_, err := parseTemplate(dir, path)
+	return err
+}
+
+// render writes a note from the template. The template is read on every
+// save, so edits to it apply without a restart.
+func (v *Vault) render(data NoteData) ([]byte, error) {
+	tmpl, err := parseTemplate(v.dir, v.template)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("note template: %w", err)
+	}
+	return []byte(b.String()), nil
+}
+
+// defaultNote is the built-in note: frontmatter with title, date,
+// language, summary and tags, then the text, its translation and a link to
+// the sidecar, if any.
+func defaultNote(data NoteData) []byte {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString(fmt.Sprintf("title: %s\n", yamlValue(data.Title)))
+	b.WriteString(fmt.Sprintf("date: %s\n", data.DateTime))
+	if data.Language != "" {
+		b.WriteString(fmt.Sprintf("language: %s\n", data.Language))
+	}
+	if data.Summary != "" {
+		b.WriteString("summary: " + Quote(data.Summary) + "\n")
+	}
+	b.WriteString("tags: " + data.Tags + "\n")
+	b.WriteString("---\n\n")
+	b.WriteString(data.Text)
+	b.WriteString("\n")
+	if data.Translation != "" {
+		b.WriteString("\n" + data.Translation + "\n")
+	}
+	if data.Sidecar != "" {
+		b.WriteString("\n" + sidecarLink(data.Sidecar) + "\n")
+	}
+	return []byte(b.String())
+}
+
+// yamlValue is s as a YAML scalar: as is when that is safe, quoted when
+// it holds characters YAML would read as syntax (an LLM's title may).
+func yamlValue(s string) string {
+	if s == "" || strings.ContainsAny(s, ":#{}[]&*!|>\"%@`,\n") || strings.TrimSpace(s) != s || strings.HasPrefix(s, "'") || strings.HasPrefix(s, "-") {
+		return Quote(s)
+	}
+	return s
+}
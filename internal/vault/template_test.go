@@ -0,0 +1,86 @@
+package vault
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveTemplate(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "Templates"), 0o755)
+	os.WriteFile(filepath.Join(dir, "Templates", "Zettel.md"), []byte(`---
+id: {{.Date}}
+model: {{.Model}}
+tags: {{.Tags}}
+---
+# {{.Title}} · Stardate {{.Stardate}}
+
+{{.Text}}
+{{range .Segments}}
+- [{{clock .Start}}] {{.Text}}{{end}}
+`), 0o644)
+
+	v := New(dir, "", "Zettel", slog.Default()).WithTemplate("Templates/Zettel.md")
+	file, _, err := v.SaveTranscription(Transcription{
+		Text:     " hello world ",
+		Language: "en",
+		Model:    "large-v3",
+		Segments: []Segment{{Start: 0, End: 2, Text: "hello"}, {Start: 3725, End: 3726, Text: "world"}},
+		Tags:     []string{"meeting"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(file)
+	for _, want := range []string{
+		"model: large-v3\n",
+		"tags: [dictation, auto-generated, meeting]\n",
+		"# Zettel · Stardate ",
+		"\n\nhello world\n",
+		"- [00:00] hello\n- [1:02:05] world\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("note lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestSaveTemplateBroken(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "bad.md"), []byte("{{.Nope}}"), 0o644)
+	for _, tmpl := range []string{"bad.md", "missing.md", "../outside.md"} {
+		v := New(dir, "", "", slog.Default()).WithTemplate(tmpl)
+		file, err := v.Save("kept anyway", "en")
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl, err)
+		}
+		got, _ := os.ReadFile(file)
+		if !strings.HasPrefix(string(got), "---\ntitle: Dictation\n") || !strings.HasSuffix(string(got), "\nkept anyway\n") {
+			t.Errorf("%s: not saved in the built-in format:\n%s", tmpl, got)
+		}
+		os.Remove(file)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ok.md"), []byte("{{.Text}}"), 0o644)
+	os.WriteFile(filepath.Join(dir, "bad.md"), []byte("{{.Text"), 0o644)
+	if err := CheckTemplate(dir, ""); err != nil {
+		t.Error(err)
+	}
+	if err := CheckTemplate(dir, "ok.md"); err != nil {
+		t.Error(err)
+	}
+	if err := CheckTemplate(dir, filepath.Join(dir, "ok.md")); err != nil {
+		t.Errorf("absolute path: %v", err)
+	}
+	for _, path := range []string{"bad.md", "missing.md", "../ok.md"} {
+		if CheckTemplate(dir, path) == nil {
+			t.Errorf("CheckTemplate(%q) = nil", path)
+		}
+	}
+}
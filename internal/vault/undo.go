@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Errors returned by UndoLog.Undo.
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrUndoExpired   = errors.New("undo window has passed")
+	ErrNoteChanged   = errors.New("note changed since it was saved")
+)
+
+// Saved is a note written by a save, as remembered for undo.
+type Saved struct {
+	File string    `json:"file"`
+	ID   string    `json:"id,omitempty"` // transcript index entry
+	At   time.Time `json:"saved_at"`
+
+	before []byte // the daily note before the entry; nil if the save wrote the whole file
+	size   int64
+	mod    time.Time
+}
+
+// UndoLog remembers the most recent save so an accidental recording can be
+// taken back for a short while, before it settles into the vault. Only the
+// last save can be undone, once. Safe for concurrent use.
+type UndoLog struct {
+	mu   sync.Mutex
+	last *Saved
+}
+
+// Record remembers file, just written, as the save to undo.
+func (u *UndoLog) Record(file, id string) {
+	u.RecordEdit(file, id, nil)
+}
+
+// RecordEdit remembers an entry added to file, which held before, as the
+// save to undo. Undo puts before back rather than deleting the file; a nil
+// before is Record.
+func (u *UndoLog) RecordEdit(file, id string, before []byte) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.last = &Saved{File: file, ID: id, At: time.Now(), before: before, size: info.Size(), mod: info.ModTime()}
+}
+
+// Rewritten records that the server rewrote file, which had been as
+// before, itself (a refined transcript): if it is the save to undo and
+// the user hadn't changed it, Undo still takes it back.
+func (u *UndoLog) Rewritten(file string, before os.FileInfo) {
+	info, err := os.Stat(file)
+	if err != nil || before == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.last != nil && u.last.File == file && before.Size() == u.last.size && before.ModTime().Equal(u.last.mod) {
+		u.last.size, u.last.mod = info.Size(), info.ModTime()
+	}
+}
+
+// Last returns the save that Undo would take back within window.
+func (u *UndoLog) Last(window time.Duration) (Saved, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.lastLocked(window)
+}
+
+func (u *UndoLog) lastLocked(window time.Duration) (Saved, error) {
+	if u.last == nil {
+		return Saved{}, ErrNothingToUndo
+	}
+	if time.Since(u.last.At) > window {
+		return Saved{}, ErrUndoExpired
+	}
+	return *u.last, nil
+}
+
+// Undo deletes the last saved note — or, for an entry added to a daily
+// note, puts the note back as it was before it — if it was saved within window
+// and hasn't changed since. WHY refuse a changed note? Once the user (or
+// Obsidian sync) has edited it, deleting it would lose their work, not
+// undo ours.
+func (u *UndoLog) Undo(window time.Duration) (Saved, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	s, err := u.lastLocked(window)
+	if err != nil {
+		return Saved{}, err
+	}
+	// Held from the check to the write, as by a daily save: an entry added
+	// in between would be lost with ours.
+	dailyMu.Lock()
+	defer dailyMu.Unlock()
+	info, err := os.Stat(s.File)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// Already gone: nothing left to delete, but the index entry is.
+	case err != nil:
+		return Saved{}, err
+	case info.Size() != s.size || !info.ModTime().Equal(s.mod):
+		return Saved{}, ErrNoteChanged
+	case s.before != nil:
+		if err := WriteFileAtomic(s.File, s.before); err != nil {
+			return Saved{}, fmt.Errorf("remove entry: %w", err)
+		}
+	default:
+		if err := os.Remove(s.File); err != nil {
+			return Saved{}, fmt.Errorf("remove note: %w", err)
+		}
+		RemoveSidecar(s.File)
+	}
+	u.last = nil
+	return s, nil
+}
@@ -0,0 +1,74 @@
+package vault
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUndo(t *testing.T) {
+	v := New(t.TempDir(), "", "", slog.Default())
+	var u UndoLog
+	if _, err := u.Undo(time.Minute); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("empty log err = %v", err)
+	}
+
+	file, _ := v.Save("oops, pocket recording", "en")
+	u.Record(file, "abc")
+	if s, err := u.Last(time.Minute); err != nil || s.File != file || s.ID != "abc" {
+		t.Errorf("Last = %+v, %v", s, err)
+	}
+	if _, err := u.Undo(0); !errors.Is(err, ErrUndoExpired) {
+		t.Errorf("zero window err = %v", err)
+	}
+	s, err := u.Undo(time.Minute)
+	if err != nil || s.ID != "abc" {
+		t.Fatalf("Undo = %+v, %v", s, err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("note still exists after undo")
+	}
+	if _, err := u.Undo(time.Minute); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("second undo err = %v", err)
+	}
+}
+
+func TestUndoChangedNote(t *testing.T) {
+	v := New(t.TempDir(), "", "", slog.Default())
+	var u UndoLog
+	file, _ := v.Save("keep me", "en")
+	u.Record(file, "abc")
+	os.WriteFile(file, []byte("edited in Obsidian, much longer now\n"), 0o644)
+	if _, err := u.Undo(time.Minute); !errors.Is(err, ErrNoteChanged) {
+		t.Errorf("err = %v, want ErrNoteChanged", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Error("changed note was deleted")
+	}
+}
+
+func TestUndoRewritten(t *testing.T) {
+	v := New(t.TempDir(), "", "", slog.Default())
+	var u UndoLog
+	file, _ := v.Save("draft text", "en")
+	u.Record(file, "abc")
+	before, _ := os.Stat(file)
+	os.WriteFile(file, []byte("refined text, a little longer"), 0o644)
+	u.Rewritten(file, before)
+	if _, err := u.Undo(time.Minute); err != nil {
+		t.Fatalf("undo after rewrite: %v", err)
+	}
+
+	// A note the user changed first stays changed.
+	file, _ = v.Save("draft text", "en")
+	u.Record(file, "def")
+	os.WriteFile(file, []byte("edited"), 0o644)
+	before, _ = os.Stat(file)
+	os.WriteFile(file, []byte("refined"), 0o644)
+	u.Rewritten(file, before)
+	if _, err := u.Undo(time.Minute); !errors.Is(err, ErrNoteChanged) {
+		t.Errorf("undo of an edited note: %v", err)
+	}
+}
@@ -1,14 +1,18 @@
 // Package vault provides optional autosave of transcriptions to a local directory.
-// Each transcription is saved as its own file for compatibility with Obsidian, Logseq, and other PKM tools.
+// Each transcription is saved as its own file for compatibility with Obsidian, Logseq, and other PKM tools,
+// or appended to the day's Obsidian daily note.
 package vault
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/stardate"
 )
 
 // Vault manages saving transcriptions to a local directory.
@@ -16,6 +20,12 @@ type Vault struct {
 	dir        string
 	dateFormat string
 	fileTitle  string
+	tags       []string
+	monitor    *SaveMonitor
+	daily      *DailyNote
+	template   string // note template file; "" = built-in format
+	sidecar    bool   // write a JSON sidecar beside each note (see WithSidecar)
+	describe   Describer
 	logger     *slog.Logger
 }
 
@@ -30,18 +40,124 @@ func New(dir, dateFormat, fileTitle string, logger *slog.Logger) *Vault {
 	if fileTitle == "" {
 		fileTitle = "Dictation"
 	}
-	return &Vault{dir: dir, dateFormat: dateFormat, fileTitle: fileTitle, logger: logger}
+	return &Vault{dir: dir, dateFormat: dateFormat, fileTitle: fileTitle, tags: DefaultTags, logger: logger}
+}
+
+// WithTags sets the tags of every saved note, in place of DefaultTags.
+// Empty tags keep the defaults. Returns v for chaining.
+func (v *Vault) WithTags(tags []string) *Vault {
+	if v != nil && len(tags) > 0 {
+		v.tags = tags
+	}
+	return v
+}
+
+// WithMonitor records every save in m. Returns v for chaining.
+func (v *Vault) WithMonitor(m *SaveMonitor) *Vault {
+	if v != nil {
+		v.monitor = m
+	}
+	return v
+}
+
+// Describer writes a title, tags and a summary for a transcription,
+// usually by asking an LLM (see reprocess.Describe).
+type Describer func(ctx context.Context, text string) (Description, error)
+
+// Description is what a Describer writes.
+type Description struct {
+	Title   string
+	Summary string
+	Tags    []string
+}
+
+// describeTimeout bounds a Describer: a slow LLM delays the save, and a
+// stuck one must not lose it.
+const describeTimeout = 60 * time.Second
+
+// WithDescriber makes Save ask d for the note's title, tags and summary,
+// for transcriptions that don't bring their own. If d fails, the note is
+// saved without them. Returns v for chaining.
+func (v *Vault) WithDescriber(d Describer) *Vault {
+	if v != nil {
+		v.describe = d
+	}
+	return v
+}
+
+// Transcription is a transcription to save, with what a note template
+// can show beyond the text.
+type Transcription struct {
+	Text     string
+	Language string
+	Model    string
+	Title    string // "" = the file title setting
+	Summary  string // one line for the frontmatter, "" for none
+	Segments []Segment
+	Words    []Word   // word timings, for the sidecar
+	Tags     []string // added to the vault's tags for this note only
+	// Translation is markdown written below the text: the transcript
+	// side by side with its translation (see internal/bilingual).
+	Translation string
 }
 
 // Save writes a transcription to its own file.
 // Filename: {fileTitle} {date} {time}.md — one file per transcription.
-func (v *Vault) Save(text, language string) (string, error) {
-	if v == nil || text == "" {
-		return "", nil
+// extraTags are added to the vault's tags for this note only. The note is
+// written atomically and read back to verify it (see WriteVerified).
+// With WithDailyNote it appends to the day's note instead (see SaveEntry).
+func (v *Vault) Save(text, language string, extraTags ...string) (string, error) {
+	file, _, err := v.SaveEntry(text, language, extraTags...)
+	return file, err
+}
+
+// Added is what a save did to its note, so it can be undone (see
+// UndoLog.RecordEdit), the recording embedded after the transcription
+// (see InsertEmbed) and the transcription refined (see Refine).
+type Added struct {
+	Before []byte // the daily note before the entry; nil when the save created the file
+	End    int64  // where the transcription ends in the file
+
+	// Where a daily note's entry is once the note has grown: its length,
+	// and with DailyOrderTop the bytes after it, which newer entries,
+	// added above, don't move.
+	daily, top bool
+	span, tail int64
+}
+
+// SaveEntry is Save, also returning what it added to the file.
+func (v *Vault) SaveEntry(text, language string, extraTags ...string) (string, Added, error) {
+	return v.SaveTranscription(Transcription{Text: text, Language: language, Tags: extraTags})
+}
+
+// SaveTranscription is SaveEntry for a Transcription. With WithTemplate the note is written
+// from the template; if the template can't be read or fails, the note is
+// written in the built-in format rather than lost.
+func (v *Vault) SaveTranscription(n Transcription) (string, Added, error) {
+	if v == nil || n.Text == "" {
+		return "", Added{}, nil
 	}
 
 	if err := os.MkdirAll(v.dir, 0755); err != nil {
-		return "", fmt.Errorf("create vault dir: %w", err)
+		err = fmt.Errorf("create vault dir: %w", err)
+		v.monitor.Record(v.dir, 0, "", err)
+		return "", Added{}, err
+	}
+	if v.describe != nil && n.Title == "" && n.Summary == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+		d, err := v.describe(ctx, n.Text)
+		cancel()
+		if err != nil {
+			// Non-fatal: the dictation matters more than its metadata.
+			v.logger.Warn("note not described — saved without title, tags and summary", "error", err)
+		} else {
+			n.Title, n.Summary = d.Title, d.Summary
+			n.Tags = append(append([]string{}, n.Tags...), d.Tags...)
+		}
+	}
+	tags := CleanTags(append(append([]string{}, v.tags...), n.Tags...))
+	if v.daily != nil {
+		return v.saveDaily(n, tags)
 	}
 
 	now := time.Now()
@@ -49,32 +165,72 @@ func (v *Vault) Save(text, language string) (string, error) {
 	timeStr := now.Format("15-04-05")
 
 	// Sanitize file title for filesystem safety
-	safeTitle := strings.Map(func(r rune) rune {
-		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' {
-			return '-'
-		}
-		return r
-	}, v.fileTitle)
+	safeTitle := safeName(v.fileTitle)
 
 	filename := filepath.Join(v.dir, fmt.Sprintf("%s %s %s.md", safeTitle, date, timeStr))
 
-	// Build compact markdown content
-	var b strings.Builder
-	b.WriteString("---\n")
-	b.WriteString(fmt.Sprintf("title: %s\n", safeTitle))
-	b.WriteString(fmt.Sprintf("date: %s\n", now.Format("2006-01-02T15:04:05")))
-	if language != "" && language != "und" {
-		b.WriteString(fmt.Sprintf("language: %s\n", language))
+	language := n.Language
+	if language == "und" {
+		language = ""
+	}
+	title := safeTitle
+	if n.Title != "" {
+		title = n.Title
+	}
+	data := NoteData{
+		Text:        strings.TrimSpace(n.Text),
+		Title:       title,
+		Summary:     n.Summary,
+		Language:    language,
+		Model:       n.Model,
+		Stardate:    stardate.FromTime(now),
+		Date:        now.Format("2006-01-02"),
+		Time:        now.Format("15:04:05"),
+		DateTime:    now.Format("2006-01-02T15:04:05"),
+		Tags:        FormatTags(tags),
+		TagList:     tags,
+		Segments:    n.Segments,
+		Translation: strings.TrimSpace(n.Translation),
+	}
+	if v.sidecar {
+		// Non-fatal: the note is what matters; it just goes without a link.
+		if err := writeSidecar(filename, newSidecar(filename, n, now)); err != nil {
+			v.logger.Warn("sidecar not written", "file", filename, "error", err)
+		} else {
+			data.Sidecar = filepath.Base(SidecarPath(filename))
+		}
+	}
+	content := defaultNote(data)
+	if v.template != "" {
+		rendered, err := v.render(data)
+		if err != nil {
+			// Non-fatal: a broken template must not cost the user the dictation.
+			v.logger.Warn("note template failed — saved in the built-in format", "template", v.template, "error", err)
+		} else {
+			content = rendered
+		}
 	}
-	b.WriteString("tags: [dictation, auto-generated]\n")
-	b.WriteString("---\n\n")
-	b.WriteString(strings.TrimSpace(text))
-	b.WriteString("\n")
 
-	if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
-		return "", fmt.Errorf("write file: %w", err)
+	sum, err := WriteVerified(filename, content)
+	v.monitor.Record(filename, len(content), sum, err)
+	if err != nil {
+		if data.Sidecar != "" {
+			RemoveSidecar(filename)
+		}
+		return "", Added{}, fmt.Errorf("write file: %w", err)
 	}
 
 	v.logger.Info("transcription saved", "file", filename)
-	return filename, nil
+	return filename, Added{End: int64(len(content))}, nil
+}
+
+// safeName replaces characters that are invalid in file names on common
+// filesystems (Windows is the strictest) with '-'.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' {
+			return '-'
+		}
+		return r
+	}, s)
 }
@@ -76,6 +76,18 @@ func TestSaveCreatesFile(t *testing.T) {
 	}
 }
 
+func TestSaveTranslation(t *testing.T) {
+	v := New(t.TempDir(), "", "", slog.Default())
+	file, _, err := v.SaveTranscription(Transcription{Text: "Hola.", Language: "es", Translation: "## Translation (English)\n\n| es | English |\n|---|---|\n| Hola. | Hello. |\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(file)
+	if !strings.HasSuffix(string(content), "\nHola.\n\n## Translation (English)\n\n| es | English |\n|---|---|\n| Hola. | Hello. |\n") {
+		t.Errorf("note:\n%s", content)
+	}
+}
+
 func TestSaveCreatesIndividualFiles(t *testing.T) {
 	dir := t.TempDir()
 	v := New(dir, "2006-01-02", "Dictation", slog.Default())
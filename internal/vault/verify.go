@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrVerify is returned when a note read back differs from what was written.
+var ErrVerify = errors.New("note read back differs from what was written")
+
+// WriteVerified writes data to path atomically (see WriteFileAtomic), then
+// reads it back and compares checksums. It returns the SHA-256 of data.
+func WriteVerified(path string, data []byte) (string, error) {
+	want := sha256.Sum256(data)
+	if err := WriteFileAtomic(path, data); err != nil {
+		return "", err
+	}
+	back, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read back: %w", err)
+	}
+	if sha256.Sum256(back) != want {
+		return "", fmt.Errorf("%w: %s (%d of %d bytes)", ErrVerify, filepath.Base(path), len(back), len(data))
+	}
+	return hex.EncodeToString(want[:]), nil
+}
+
+// SaveStatus is the outcome of one note save.
+type SaveStatus struct {
+	File   string    `json:"file"`
+	At     time.Time `json:"at"`
+	OK     bool      `json:"ok"`
+	Bytes  int       `json:"bytes,omitempty"`
+	SHA256 string    `json:"sha256,omitempty"` // of the verified content
+	Error  string    `json:"error,omitempty"`
+}
+
+// SaveMonitor keeps the last save and counts failures, for /healthz. Its
+// zero value is ready to use; a nil SaveMonitor records nothing. Safe for
+// concurrent use.
+type SaveMonitor struct {
+	mu       sync.Mutex
+	last     *SaveStatus
+	saves    int
+	failures int
+}
+
+// Record notes a save of size bytes to file: its checksum, or the error.
+func (m *SaveMonitor) Record(file string, size int, sum string, err error) {
+	if m == nil {
+		return
+	}
+	st := SaveStatus{File: file, At: time.Now().UTC(), OK: err == nil, Bytes: size, SHA256: sum}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = &st
+	m.saves++
+	if err != nil {
+		m.failures++
+	}
+}
+
+// Last returns the last save, if any, with the number of saves and
+// failures since the process started.
+func (m *SaveMonitor) Last() (st SaveStatus, saves, failures int, ok bool) {
+	if m == nil {
+		return SaveStatus{}, 0, 0, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return SaveStatus{}, m.saves, m.failures, false
+	}
+	return *m.last, m.saves, m.failures, true
+}
+
+// tempPattern matches the temp files of WriteFileAtomic: ".<name>.<digits>.tmp".
+var tempPattern = regexp.MustCompile(`^\..+\.[0-9]+\.tmp$`)
+
+// CleanTemp removes temp files an interrupted WriteFileAtomic left in the
+// vault — the note they were for still has its old content, or doesn't
+// exist. Hidden folders (.obsidian, .trash) are not searched. It returns
+// the files removed.
+func CleanTemp(dir string, logger *slog.Logger) []string {
+	var removed []string
+	if dir == "" {
+		return nil
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !tempPattern.MatchString(d.Name()) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			logger.Warn("leftover vault temp file not removed", "file", path, "error", err)
+			return nil
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	return removed
+}
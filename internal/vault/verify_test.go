@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveMonitor(t *testing.T) {
+	var m SaveMonitor
+	if _, _, _, ok := m.Last(); ok {
+		t.Error("Last before any save reported a save")
+	}
+	dir := t.TempDir()
+	file, err := New(dir, "", "", slog.New(slog.NewTextHandler(io.Discard, nil))).WithMonitor(&m).Save("checked", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, saves, failures, ok := m.Last()
+	if !ok || !last.OK || last.File != file || len(last.SHA256) != 64 || saves != 1 || failures != 0 {
+		t.Errorf("after save: %+v saves=%d failures=%d", last, saves, failures)
+	}
+
+	// A vault that can't be created is a recorded failure.
+	blocker := filepath.Join(dir, "file")
+	os.WriteFile(blocker, nil, 0o644)
+	if _, err := New(filepath.Join(blocker, "vault"), "", "", slog.Default()).WithMonitor(&m).Save("x", ""); err == nil {
+		t.Fatal("save under a file succeeded")
+	}
+	if last, _, failures, _ := m.Last(); last.OK || last.Error == "" || failures != 1 {
+		t.Errorf("after failure: %+v failures=%d", last, failures)
+	}
+}
+
+func TestCleanTemp(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "Calls"), 0o755)
+	os.MkdirAll(filepath.Join(dir, ".obsidian"), 0o755)
+	leftovers := []string{
+		filepath.Join(dir, ".Dictation 2026-10-16 09-00-00.md.123456.tmp"),
+		filepath.Join(dir, "Calls", ".call.md.42.tmp"),
+	}
+	keep := []string{
+		filepath.Join(dir, "Dictation.md"),
+		filepath.Join(dir, "notes.tmp"),
+		filepath.Join(dir, ".obsidian", ".workspace.json.1.tmp"),
+	}
+	for _, f := range append(leftovers, keep...) {
+		os.WriteFile(f, []byte("x"), 0o644)
+	}
+	removed := CleanTemp(dir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if strings.Join(removed, "|") != strings.Join(leftovers, "|") {
+		t.Errorf("removed %v, want %v", removed, leftovers)
+	}
+	for _, f := range keep {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s removed", f)
+		}
+	}
+}
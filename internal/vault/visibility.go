@@ -0,0 +1,74 @@
+// Package vault — privacy levels.
+// A note's `visibility:` frontmatter decides who may see it outside the
+// owner's own UI. Anything that exposes notes to someone else (share links,
+// feeds, exports) must filter through VisibleTo with the right audience.
+//
+// The default is private, and unknown values are treated as private: a typo
+// in frontmatter must never widen who can read a log.
+package vault
+
+import "strings"
+
+// Visibility levels, narrowest first.
+const (
+	VisibilityPrivate = "private" // owner only
+	VisibilityShared  = "shared"  // people the owner shares with (links, team)
+	VisibilityPublic  = "public"  // anyone (public feeds)
+)
+
+// Audiences for VisibleTo. AudienceOwner sees everything.
+const (
+	AudienceOwner  = "owner"
+	AudienceShared = VisibilityShared
+	AudiencePublic = VisibilityPublic
+)
+
+var visibilityRank = map[string]int{
+	VisibilityPrivate: 0,
+	VisibilityShared:  1,
+	VisibilityPublic:  2,
+}
+
+// ParseVisibility normalises a frontmatter value. Empty or unrecognised
+// values are private.
+func ParseVisibility(v string) string {
+	v = strings.ToLower(strings.Trim(strings.TrimSpace(v), `"'`))
+	if _, ok := visibilityRank[v]; ok {
+		return v
+	}
+	return VisibilityPrivate
+}
+
+// ValidAudience reports whether a is a known audience.
+func ValidAudience(a string) bool {
+	return a == AudienceOwner || a == AudienceShared || a == AudiencePublic
+}
+
+// VisibleTo reports whether a note with the given visibility may be shown
+// to audience. Unknown audiences see nothing.
+func VisibleTo(visibility, audience string) bool {
+	if audience == AudienceOwner {
+		return true
+	}
+	need, ok := visibilityRank[audience]
+	if !ok {
+		return false
+	}
+	return visibilityRank[ParseVisibility(visibility)] >= need
+}
+
+// Visibility returns the document's privacy level.
+func (d *Document) Visibility() string {
+	return ParseVisibility(d.Get("visibility"))
+}
+
+// FilterVisible returns the entries audience may see, preserving order.
+func FilterVisible(entries []Entry, audience string) []Entry {
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if VisibleTo(e.Visibility, audience) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVisibility(t *testing.T) {
+	tests := map[string]string{
+		"":         VisibilityPrivate,
+		"public":   VisibilityPublic,
+		" Shared ": VisibilityShared,
+		`"public"`: VisibilityPublic,
+		"publc":    VisibilityPrivate, // typo fails closed
+		"everyone": VisibilityPrivate,
+		"PRIVATE":  VisibilityPrivate,
+	}
+	for in, want := range tests {
+		if got := ParseVisibility(in); got != want {
+			t.Errorf("ParseVisibility(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	tests := []struct {
+		visibility, audience string
+		want                 bool
+	}{
+		{"private", AudienceOwner, true},
+		{"private", AudienceShared, false},
+		{"private", AudiencePublic, false},
+		{"shared", AudienceShared, true},
+		{"shared", AudiencePublic, false},
+		{"public", AudienceShared, true},
+		{"public", AudiencePublic, true},
+		{"", AudienceShared, false},
+		{"public", "bogus", false},
+	}
+	for _, tt := range tests {
+		if got := VisibleTo(tt.visibility, tt.audience); got != tt.want {
+			t.Errorf("VisibleTo(%q, %q) = %v, want %v", tt.visibility, tt.audience, got, tt.want)
+		}
+	}
+}
+
+func TestScanVisibility(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, vis string) {
+		content := "---\ntitle: Dictation\ndate: 2026-02-21T11:44:58\n"
+		if vis != "" {
+			content += "visibility: " + vis + "\n"
+		}
+		os.WriteFile(filepath.Join(dir, name), []byte(content+"---\n\nSome text here.\n"), 0644)
+	}
+	write("a.md", "")
+	write("b.md", "shared")
+	write("c.md", "public")
+
+	entries, err := Scan(dir, 10, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		got[filepath.Base(e.File)] = e.Visibility
+	}
+	if got["a.md"] != "private" || got["b.md"] != "shared" || got["c.md"] != "public" {
+		t.Errorf("visibility = %v", got)
+	}
+	if n := len(FilterVisible(entries, AudienceShared)); n != 2 {
+		t.Errorf("shared audience sees %d, want 2", n)
+	}
+	if n := len(FilterVisible(entries, AudiencePublic)); n != 1 {
+		t.Errorf("public audience sees %d, want 1", n)
+	}
+}
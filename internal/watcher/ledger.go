@@ -0,0 +1,139 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ledgerMax caps the ledger; the oldest records go first.
+const ledgerMax = 10000
+
+// Print identifies a file's content for the ledger.
+type Print struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// Fingerprint reads the file at path and returns its Print.
+func Fingerprint(path string) (Print, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Print{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Print{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return Print{}, fmt.Errorf("hash %s: %w", filepath.Base(path), err)
+	}
+	return Print{Path: path, Size: info.Size(), ModTime: info.ModTime().UTC(), SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ledgerRecord is a file a watcher has transcribed.
+type ledgerRecord struct {
+	Print
+	Watcher string    `json:"watcher,omitempty"`
+	At      time.Time `json:"processed_at"`
+}
+
+// Ledger remembers which files the watchers have transcribed, on disk, so
+// a restart doesn't transcribe them again. A file counts as done when its
+// path, size and modification time match a record, or when the same
+// watcher has transcribed the same content before (a re-synced or renamed
+// copy). A nil Ledger remembers nothing. Safe for concurrent use.
+type Ledger struct {
+	path string
+
+	mu      sync.Mutex
+	records []ledgerRecord
+}
+
+// OpenLedger loads the ledger at path. A missing file is an empty ledger.
+func OpenLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read watcher ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.records); err != nil {
+		return nil, fmt.Errorf("parse watcher ledger %s: %w", filepath.Base(path), err)
+	}
+	return l, nil
+}
+
+// Has reports whether p was transcribed before, by watcher.
+func (l *Ledger) Has(p Print, watcher string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range l.records {
+		if r.Path == p.Path && r.Size == p.Size && r.ModTime.Equal(p.ModTime) {
+			return true
+		}
+		if r.SHA256 == p.SHA256 && r.Watcher == watcher {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records p as transcribed by watcher and saves the ledger.
+func (l *Ledger) Add(p Print, watcher string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A file at the same path replaces its old record.
+	kept := l.records[:0]
+	for _, r := range l.records {
+		if r.Path != p.Path {
+			kept = append(kept, r)
+		}
+	}
+	l.records = append(kept, ledgerRecord{Print: p, Watcher: watcher, At: time.Now().UTC()})
+	if len(l.records) > ledgerMax {
+		sort.SliceStable(l.records, func(i, j int) bool { return l.records[i].At.Before(l.records[j].At) })
+		l.records = l.records[len(l.records)-ledgerMax:]
+	}
+	return l.saveLocked()
+}
+
+// saveLocked writes the ledger via temp file + rename. Caller holds l.mu.
+func (l *Ledger) saveLocked() error {
+	data, err := json.Marshal(l.records)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("create ledger dir: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write watcher ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace watcher ledger: %w", err)
+	}
+	return nil
+}
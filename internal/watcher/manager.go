@@ -0,0 +1,279 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ResponseFormats are the response_format values a watcher may ask of
+// Whisper.
+var ResponseFormats = []string{"json", "verbose_json", "text", "srt", "vtt"}
+
+// ErrInvalid wraps every error from Validate.
+var ErrInvalid = errors.New("invalid watcher config")
+
+// idPattern keeps IDs usable in URLs: /api/watchers/<id>.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Config is one watched folder with its own settings. Empty fields fall
+// back to the global settings.
+type Config struct {
+	ID             string   `json:"id"`
+	Dir            string   `json:"dir"`
+	Language       string   `json:"language,omitempty"`        // "" = the global language
+	VaultSubdir    string   `json:"vault_subdir,omitempty"`    // folder inside the vault; "" = the vault itself
+	ResponseFormat string   `json:"response_format,omitempty"` // one of ResponseFormats; "" = json
+	Paused         bool     `json:"paused,omitempty"`          // configured but not watching
+	Recursive      bool     `json:"recursive,omitempty"`       // also watch subfolders, mirrored in the vault
+	Tags           []string `json:"tags,omitempty"`            // frontmatter tags of its notes; none = DefaultTags
+}
+
+// Validate checks a set of configs: unique URL-safe IDs, distinct
+// folders (none inside a recursive watcher's tree), vault subfolders that stay inside the vault, and known
+// response formats.
+func Validate(cfgs []Config) error {
+	ids := map[string]bool{}
+	dirs := map[string]string{}
+	for _, c := range cfgs {
+		if !idPattern.MatchString(c.ID) {
+			return fmt.Errorf("%w: id %q must be lowercase letters, digits, - or _", ErrInvalid, c.ID)
+		}
+		if ids[c.ID] {
+			return fmt.Errorf("%w: duplicate id %q", ErrInvalid, c.ID)
+		}
+		ids[c.ID] = true
+		if strings.TrimSpace(c.Dir) == "" {
+			return fmt.Errorf("%w: watcher %q has no dir", ErrInvalid, c.ID)
+		}
+		dir := filepath.Clean(c.Dir)
+		if other, ok := dirs[dir]; ok {
+			// WHY refuse? Both would transcribe every file, twice.
+			return fmt.Errorf("%w: watchers %q and %q watch the same folder", ErrInvalid, other, c.ID)
+		}
+		dirs[dir] = c.ID
+		if sub := c.VaultSubdir; sub != "" {
+			if !filepath.IsLocal(sub) {
+				return fmt.Errorf("%w: watcher %q vault_subdir %q must be a relative path inside the vault", ErrInvalid, c.ID, sub)
+			}
+		}
+		if c.ResponseFormat != "" && !contains(ResponseFormats, c.ResponseFormat) {
+			return fmt.Errorf("%w: watcher %q response_format %q (want one of %s)",
+				ErrInvalid, c.ID, c.ResponseFormat, strings.Join(ResponseFormats, ", "))
+		}
+	}
+	for _, c := range cfgs {
+		if !c.Recursive {
+			continue
+		}
+		for dir, other := range dirs {
+			if other != c.ID && Within(filepath.Clean(c.Dir), dir) {
+				return fmt.Errorf("%w: watcher %q watches a subfolder of recursive watcher %q", ErrInvalid, other, c.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// Within reports whether dir is inside the tree rooted at root.
+func Within(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Info is a configured watcher and its state.
+type Info struct {
+	Config
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"` // why it isn't running, if it should be
+}
+
+// managed is one running (or failed) watcher and what it was built from.
+type managed struct {
+	cfg      Config
+	resolved resolved
+	w        *Watcher
+	err      error
+}
+
+// resolved is a Config with the global fallbacks filled in. A watcher is
+// rebuilt when this changes.
+type resolved struct {
+	dir, vaultDir, language, format string
+	tags                            string
+	paused, recursive               bool
+}
+
+// Manager runs a set of watchers, one per configured folder, and merges
+// their events into one SSE stream. Safe for concurrent use.
+type Manager struct {
+	whisperURL string
+	logger     *slog.Logger
+	opts       []Option
+
+	mu       sync.Mutex
+	watchers map[string]*managed
+
+	// WHY a second lock? Apply holds mu while starting a watcher, and
+	// Start publishes "started" to these clients.
+	clientsMu sync.Mutex
+	clients   map[chan Event]struct{}
+}
+
+// NewManager returns a Manager whose watchers send to whisperURL, each
+// built with opts (transport, spool, privacy, notify hooks).
+func NewManager(whisperURL string, logger *slog.Logger, opts ...Option) *Manager {
+	return &Manager{
+		whisperURL: whisperURL,
+		logger:     logger,
+		opts:       opts,
+		watchers:   map[string]*managed{},
+		clients:    map[chan Event]struct{}{},
+	}
+}
+
+// Apply makes the running watchers match cfgs: removed ones stop, new
+// ones start, and changed ones — including a changed vault or language
+// they fall back to — restart. A watcher that fails to start is kept with
+// its error (see List) rather than failing the rest.
+func (m *Manager) Apply(cfgs []Config, vaultDir, language string) error {
+	if err := Validate(cfgs); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	want := map[string]bool{}
+	for _, c := range cfgs {
+		want[c.ID] = true
+		r := resolved{
+			dir:       c.Dir,
+			vaultDir:  vaultDir,
+			language:  language,
+			format:    c.ResponseFormat,
+			tags:      strings.Join(c.Tags, ","),
+			paused:    c.Paused,
+			recursive: c.Recursive,
+		}
+		if vaultDir != "" && c.VaultSubdir != "" {
+			r.vaultDir = filepath.Join(vaultDir, c.VaultSubdir)
+		}
+		if c.Language != "" {
+			r.language = c.Language
+		}
+		if cur, ok := m.watchers[c.ID]; ok {
+			if cur.resolved == r {
+				cur.cfg = c
+				continue
+			}
+			cur.w.Stop()
+		}
+		mw := &managed{cfg: c, resolved: r}
+		opts := append(append([]Option{}, m.opts...), WithID(c.ID), WithResponseFormat(r.format), WithTags(c.Tags), WithRecursive(c.Recursive), WithNotify(m.publish))
+		mw.w = New(r.dir, m.whisperURL, r.vaultDir, r.language, m.logger, opts...)
+		if !c.Paused {
+			if mw.err = mw.w.Start(); mw.err != nil {
+				m.logger.Error("folder watcher failed to start", "watcher", c.ID, "dir", c.Dir, "error", mw.err)
+			}
+		}
+		m.watchers[c.ID] = mw
+	}
+	for id, mw := range m.watchers {
+		if !want[id] {
+			mw.w.Stop()
+			delete(m.watchers, id)
+		}
+	}
+	return nil
+}
+
+// List returns every configured watcher with its status, by ID.
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Info, 0, len(m.watchers))
+	for _, mw := range m.watchers {
+		out = append(out, mw.info())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Get returns the watcher with id.
+func (m *Manager) Get(id string) (Info, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mw, ok := m.watchers[id]
+	if !ok {
+		return Info{}, false
+	}
+	return mw.info(), true
+}
+
+func (mw *managed) info() Info {
+	i := Info{Config: mw.cfg, Status: mw.w.Status()}
+	if mw.err != nil {
+		i.Error = mw.err.Error()
+	}
+	return i
+}
+
+// Stop stops every watcher.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, mw := range m.watchers {
+		mw.w.Stop()
+	}
+}
+
+// publish forwards an event from any watcher to the Manager's SSE clients.
+func (m *Manager) publish(ev Event) {
+	m.clientsMu.Lock()
+	defer m.clientsMu.Unlock()
+	for ch := range m.clients {
+		select {
+		case ch <- ev:
+		default:
+			// Client buffer full — skip rather than block
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the events of every watcher.
+func (m *Manager) Subscribe() chan Event {
+	ch := make(chan Event, 16)
+	m.clientsMu.Lock()
+	m.clients[ch] = struct{}{}
+	m.clientsMu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes an SSE client.
+func (m *Manager) Unsubscribe(ch chan Event) {
+	m.clientsMu.Lock()
+	delete(m.clients, ch)
+	m.clientsMu.Unlock()
+	close(ch)
+}
+
+// SSEHandler streams the events of every watcher; each carries its
+// watcher's ID.
+func (m *Manager) SSEHandler() http.HandlerFunc {
+	return sseHandler(m.Subscribe, m.Unsubscribe)
+}
@@ -8,22 +8,36 @@
 package watcher
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/audio"
+	"github.com/ryan-winkler/captainslog-whisper/internal/events"
+	"github.com/ryan-winkler/captainslog-whisper/internal/redact"
+	"github.com/ryan-winkler/captainslog-whisper/internal/spool"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
 )
 
+// DefaultTags are the frontmatter tags of a note saved by the watcher
+// when none are configured.
+var DefaultTags = []string{"auto-transcription", "folder-watch"}
+
 // audioExtensions are the file types we auto-transcribe.
 var audioExtensions = map[string]bool{
 	".wav":  true,
@@ -38,36 +52,217 @@ var audioExtensions = map[string]bool{
 }
 
 // Event represents a watcher event sent to SSE clients.
+// SchemaVersion follows events.SchemaVersion: fields are only ever added
+// within a major version.
 type Event struct {
-	Type      string `json:"type"`      // "transcription", "error", "started"
-	Filename  string `json:"filename"`
+	SchemaVersion string `json:"schema_version"`
+	Type      string `json:"type"`      // "started", "stopped", "processing", "transcription", "error"
+	Filename  string `json:"filename"`  // relative to the watched folder
 	Text      string `json:"text,omitempty"`
+	TextHash  string `json:"text_sha256,omitempty"` // set in privacy mode, when Text is truncated
+	SavedTo   string `json:"saved_to,omitempty"` // vault file, if saved
+	Language  string `json:"language,omitempty"` // asked of Whisper, on transcriptions
+	Watcher   string `json:"watcher,omitempty"`  // ID of the watcher; empty for the watch_dir one
 	Error     string `json:"error,omitempty"`
 	Timestamp string `json:"timestamp"`
 }
 
-// Watcher monitors a directory for new audio files.
+// ErrBusy is returned by Start while the watcher runs, and by Configure
+// while it runs or still has files in flight.
+var ErrBusy = errors.New("folder watcher is busy")
+
+// Status is a snapshot of the watcher. Counts are since the process
+// started, across stops and starts.
+type Status struct {
+	Running   bool       `json:"running"`
+	Dir       string     `json:"dir"`
+	Recursive bool       `json:"recursive"`
+	Dirs      int        `json:"dirs"` // folders watched now: 1, or the whole tree when recursive
+	StartedAt *time.Time `json:"started_at,omitempty"` // of the current run
+	InFlight  int        `json:"in_flight"`            // files being transcribed now
+	Completed int        `json:"completed"`
+	Failed    int        `json:"failed"`
+	Skipped   int        `json:"skipped"` // already transcribed before, per the ledger
+	Clients   int        `json:"clients"` // SSE subscribers
+	LastEvent *Event     `json:"last_event,omitempty"`
+}
+
+// Watcher monitors a directory for new audio files. It can be stopped and
+// started again; SSE subscribers stay connected across runs.
 type Watcher struct {
 	dir        string
 	whisperURL string
 	vaultDir   string
 	language   string
+	recursive  bool // also watch every subfolder, including new ones
 	logger     *slog.Logger
 	client     *http.Client
 
-	// SSE clients
+	// SSE clients, and the run state below
 	mu       sync.Mutex
 	clients  map[chan Event]struct{}
-	stopCh   chan struct{}
+	stopCh   chan struct{} // nil when stopped
 	fsw      *fsnotify.Watcher
 
+	startedAt time.Time
+	inFlight  int
+	completed int
+	failed    int
+	skipped   int
+	lastEvent *Event
+
 	// Track files we've already processed (avoid duplicates)
 	processed map[string]bool
+	ledger    *Ledger // the same across restarts of the process; nil = none
+	monitor   *vault.SaveMonitor // records note saves; nil = none
+
+	id             string       // set on events (see WithID)
+	responseFormat string       // asked of Whisper; see ResponseFormats
+	tags           []string     // frontmatter tags of saved notes
+	notify         []func(Event) // optional hooks for outbound integrations
+
+	// privacyPreview >= 0 enables privacy mode: event text is cut to this
+	// many runes and fingerprinted. -1 (default) sends full text.
+	privacyPreview int
+
+	spoolMemory int64  // request body bytes kept in RAM before spilling to disk
+	spoolDir    string // "" = os.TempDir()
+
+	converter *audio.Converter     // ffmpeg for WithPreprocess; nil = files go as they are
+	audioOpts func() audio.Options // preprocessing asked for, per file
+
+	transcriber http.HandlerFunc       // answers the transcription requests; nil = w.client to whisperURL
+	describer   func() vault.Describer // titles, tags and summarizes notes; nil, or returning nil, = none
+}
+
+// Option configures optional Watcher behaviour.
+type Option func(*Watcher)
+
+// WithTransport sets the RoundTripper used for Whisper backend calls.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(w *Watcher) { w.client.Transport = rt }
+}
+
+// WithNotify calls fn for every event broadcast to SSE clients, so other
+// integrations (webhooks) see the same events. fn must not block. Each
+// WithNotify adds a hook.
+func WithNotify(fn func(Event)) Option {
+	return func(w *Watcher) { w.notify = append(w.notify, fn) }
+}
+
+// WithID tags every event with id, so events from several watchers can
+// share a stream.
+func WithID(id string) Option {
+	return func(w *Watcher) { w.id = id }
+}
+
+// WithTags sets the frontmatter tags of saved notes, in place of
+// DefaultTags. Empty tags keep the defaults.
+func WithTags(tags []string) Option {
+	return func(w *Watcher) {
+		if tags = vault.CleanTags(tags); len(tags) > 0 {
+			w.tags = tags
+		}
+	}
+}
+
+// WithLedger skips files the ledger says were transcribed before, and
+// records the ones transcribed now.
+func WithLedger(l *Ledger) Option {
+	return func(w *Watcher) { w.ledger = l }
+}
+
+// WithSaveMonitor records every note the watcher saves in m.
+func WithSaveMonitor(m *vault.SaveMonitor) Option {
+	return func(w *Watcher) { w.monitor = m }
+}
+
+// WithRecursive watches every subfolder of the directory too, adding
+// new ones as they appear (see SetRecursive).
+func WithRecursive(on bool) Option {
+	return func(w *Watcher) { w.recursive = on }
+}
+
+// WithResponseFormat sets the response_format asked of Whisper (one of
+// ResponseFormats). The text formats (text, srt, vtt) are saved as they
+// come; the JSON ones are saved as their "text". Default: json.
+func WithResponseFormat(format string) Option {
+	return func(w *Watcher) {
+		if format != "" {
+			w.responseFormat = format
+		}
+	}
+}
+
+// WithPrivacy truncates event text to preview runes and adds its hash, so
+// full transcripts never leave the process via SSE or webhooks.
+func WithPrivacy(preview int) Option {
+	return func(w *Watcher) { w.privacyPreview = max(preview, 0) }
+}
+
+// WithSpool bounds the memory used to build each upload: beyond memory
+// bytes the multipart body is spooled to a temp file in dir.
+func WithSpool(memory int64, dir string) Option {
+	return func(w *Watcher) {
+		w.spoolMemory = memory
+		w.spoolDir = dir
+	}
+}
+
+// WithPreprocess converts each file with conv before it is sent, as opts
+// says at the time (see internal/audio). A nil conv turns it off.
+func WithPreprocess(conv *audio.Converter, opts func() audio.Options) Option {
+	return func(w *Watcher) {
+		w.converter = conv
+		w.audioOpts = opts
+	}
+}
+
+// WithTranscriber hands each transcription request to h, the Whisper
+// proxy's Transcribe in practice, instead of posting it to the backend
+// itself. Files then queue for backend slots, are retried, fail over and
+// get the model for their language like any other upload — ten files
+// dropped in at once don't become ten parallel requests to one GPU. The
+// proxy preprocesses the audio too, so WithPreprocess is left out.
+func WithTranscriber(h http.HandlerFunc) Option {
+	return func(w *Watcher) { w.transcriber = h }
+}
+
+// WithDescriber has the Describer d returns write each saved note's
+// title, tags and summary, as vault.Vault.WithDescriber does for the
+// other notes. d is asked per file, so the setting applies at once; it
+// returns nil while describing is off.
+func WithDescriber(d func() vault.Describer) Option {
+	return func(w *Watcher) { w.describer = d }
+}
+
+// describeTimeout bounds a Describer: a slow LLM delays the note, and a
+// stuck one must not lose it.
+const describeTimeout = 60 * time.Second
+
+// describe asks the Describer for text's title, tags and summary. A
+// failure is logged and describes nothing: the transcript matters more.
+func (w *Watcher) describe(text string) vault.Description {
+	if w.describer == nil {
+		return vault.Description{}
+	}
+	d := w.describer()
+	if d == nil {
+		return vault.Description{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+	desc, err := d(ctx, text)
+	if err != nil {
+		w.logger.Warn("note not described — saved without title, tags and summary", "error", err)
+		return vault.Description{}
+	}
+	return desc
 }
 
 // New creates a Watcher for the given directory.
-func New(dir, whisperURL, vaultDir, language string, logger *slog.Logger) *Watcher {
-	return &Watcher{
+func New(dir, whisperURL, vaultDir, language string, logger *slog.Logger, opts ...Option) *Watcher {
+	w := &Watcher{
 		dir:        dir,
 		whisperURL: strings.TrimRight(whisperURL, "/"),
 		vaultDir:   vaultDir,
@@ -75,13 +270,51 @@ func New(dir, whisperURL, vaultDir, language string, logger *slog.Logger) *Watch
 		logger:     logger,
 		client:     &http.Client{Timeout: 600 * time.Second}, // Long timeout for transcription
 		clients:    make(map[chan Event]struct{}),
-		stopCh:     make(chan struct{}),
 		processed:  make(map[string]bool),
+		privacyPreview: -1,
+		responseFormat: "json",
+		tags:           DefaultTags,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Configure changes the directory, vault and language for the next Start.
+// It returns ErrBusy while the watcher runs or files are still in flight,
+// since those read the current values.
+func (w *Watcher) Configure(dir, vaultDir, language string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopCh != nil || w.inFlight > 0 {
+		return ErrBusy
+	}
+	w.dir, w.vaultDir, w.language = dir, vaultDir, language
+	return nil
+}
+
+// SetRecursive turns recursive watching on or off for the next Start.
+// Recursive watchers pick up audio anywhere under the directory, and
+// mirror its subfolders in the vault. It returns ErrBusy like Configure.
+func (w *Watcher) SetRecursive(on bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopCh != nil || w.inFlight > 0 {
+		return ErrBusy
 	}
+	w.recursive = on
+	return nil
 }
 
-// Start begins watching the directory. Call Stop() to clean up.
+// Start begins watching the directory. Call Stop() to clean up. Starting
+// a running watcher returns ErrBusy.
 func (w *Watcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopCh != nil {
+		return ErrBusy
+	}
 	if w.dir == "" {
 		return fmt.Errorf("watch directory is empty")
 	}
@@ -95,26 +328,103 @@ func (w *Watcher) Start() error {
 	if err != nil {
 		return fmt.Errorf("create fsnotify watcher: %w", err)
 	}
-	w.fsw = fsw
-
 	if err := fsw.Add(w.dir); err != nil {
 		fsw.Close()
 		return fmt.Errorf("watch dir %s: %w", w.dir, err)
 	}
+	if w.recursive {
+		w.addSubdirs(fsw, w.dir, nil)
+	}
+	w.fsw = fsw
+	w.stopCh = make(chan struct{})
+	w.startedAt = time.Now()
 
-	w.logger.Info("folder watcher started", "dir", w.dir)
-	w.broadcast(Event{Type: "started", Timestamp: time.Now().Format(time.RFC3339)})
+	w.logger.Info("folder watcher started", "dir", w.dir, "recursive", w.recursive, "dirs", len(fsw.WatchList()))
+	w.broadcastLocked(Event{Type: "started", Timestamp: w.startedAt.Format(time.RFC3339)})
 
-	go w.loop()
+	go w.loop(fsw, w.stopCh, w.recursive)
 	return nil
 }
 
-// Stop shuts down the watcher.
+// addSubdirs adds every folder below root to fsw. Hidden folders (.git, .stfolder, .Trashes…) are skipped. If found
+// is not nil, it is called with each audio file on the way: files written
+// into a new folder before its watch was added raise no event of their own.
+// A folder that can't be watched is logged and skipped — most likely the
+// inotify watch limit (fs.inotify.max_user_watches) was reached.
+func (w *Watcher) addSubdirs(fsw *fsnotify.Watcher, root string, found func(path string)) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			w.logger.Warn("folder watcher skipped a path", "path", path, "error", err)
+			return nil
+		}
+		if !d.IsDir() {
+			if found != nil && audioExtensions[strings.ToLower(filepath.Ext(path))] {
+				found(path)
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := fsw.Add(path); err != nil {
+			w.logger.Warn("folder watcher could not watch subfolder", "dir", path, "error", err)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// Stop shuts down the watcher. Files already being transcribed finish.
+// Stopping a stopped watcher does nothing.
 func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopCh == nil {
+		return
+	}
 	close(w.stopCh)
-	if w.fsw != nil {
-		w.fsw.Close()
+	w.fsw.Close()
+	w.stopCh, w.fsw = nil, nil
+
+	w.logger.Info("folder watcher stopped", "dir", w.dir)
+	w.broadcastLocked(Event{Type: "stopped", Timestamp: time.Now().Format(time.RFC3339)})
+}
+
+// Running reports whether the watcher is watching its directory.
+func (w *Watcher) Running() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopCh != nil
+}
+
+// Status returns a snapshot of the watcher.
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := Status{
+		Running:   w.stopCh != nil,
+		Dir:       w.dir,
+		Recursive: w.recursive,
+		InFlight:  w.inFlight,
+		Completed: w.completed,
+		Failed:    w.failed,
+		Skipped:   w.skipped,
+		Clients:   len(w.clients),
+	}
+	if st.Running {
+		t := w.startedAt
+		st.StartedAt = &t
+		// Folders removed from the tree drop out of the list by themselves.
+		st.Dirs = len(w.fsw.WatchList())
 	}
+	if w.lastEvent != nil {
+		ev := *w.lastEvent
+		st.LastEvent = &ev
+	}
+	return st
 }
 
 // Subscribe returns a channel that receives watcher events.
@@ -137,6 +447,28 @@ func (w *Watcher) Unsubscribe(ch chan Event) {
 func (w *Watcher) broadcast(ev Event) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	w.broadcastLocked(ev)
+}
+
+// broadcastLocked is broadcast with w.mu held. It also keeps the counts
+// for Status.
+func (w *Watcher) broadcastLocked(ev Event) {
+	ev.SchemaVersion = events.SchemaVersion
+	ev.Watcher = w.id
+	if w.privacyPreview >= 0 && ev.Text != "" {
+		ev.TextHash = redact.Hash(ev.Text)
+		ev.Text = redact.Truncate(ev.Text, w.privacyPreview)
+	}
+	switch ev.Type {
+	case "transcription":
+		w.completed++
+	case "error":
+		w.failed++
+	}
+	w.lastEvent = &ev
+	for _, fn := range w.notify {
+		fn(ev)
+	}
 	for ch := range w.clients {
 		select {
 		case ch <- ev:
@@ -146,7 +478,7 @@ func (w *Watcher) broadcast(ev Event) {
 	}
 }
 
-func (w *Watcher) loop() {
+func (w *Watcher) loop(fsw *fsnotify.Watcher, stopCh chan struct{}, recursive bool) {
 	// Debounce: wait for file to be fully written before processing
 	pending := make(map[string]time.Time)
 	ticker := time.NewTicker(2 * time.Second)
@@ -154,10 +486,10 @@ func (w *Watcher) loop() {
 
 	for {
 		select {
-		case <-w.stopCh:
+		case <-stopCh:
 			return
 
-		case event, ok := <-w.fsw.Events:
+		case event, ok := <-fsw.Events:
 			if !ok {
 				return
 			}
@@ -165,6 +497,19 @@ func (w *Watcher) loop() {
 			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
 				continue
 			}
+			if recursive && event.Op&fsnotify.Create != 0 && !strings.HasPrefix(filepath.Base(event.Name), ".") {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					// A new folder (a phone sync's new day, say): watch it
+					// and everything already in it.
+					if err := fsw.Add(event.Name); err != nil {
+						w.logger.Warn("folder watcher could not watch subfolder", "dir", event.Name, "error", err)
+						continue
+					}
+					now := time.Now()
+					w.addSubdirs(fsw, event.Name, func(path string) { pending[path] = now })
+					continue
+				}
+			}
 			ext := strings.ToLower(filepath.Ext(event.Name))
 			if !audioExtensions[ext] {
 				continue
@@ -172,7 +517,7 @@ func (w *Watcher) loop() {
 			// Debounce: update the pending timestamp
 			pending[event.Name] = time.Now()
 
-		case err, ok := <-w.fsw.Errors:
+		case err, ok := <-fsw.Errors:
 			if !ok {
 				return
 			}
@@ -187,10 +532,16 @@ func (w *Watcher) loop() {
 				}
 				delete(pending, path)
 
-				if w.processed[path] {
+				w.mu.Lock()
+				// WHY check stopCh? Stop may have won the race with this
+				// tick; a stopped watcher must not start new work.
+				if w.processed[path] || w.stopCh != stopCh {
+					w.mu.Unlock()
 					continue
 				}
 				w.processed[path] = true
+				w.inFlight++
+				w.mu.Unlock()
 
 				go w.processFile(path)
 			}
@@ -199,7 +550,33 @@ func (w *Watcher) loop() {
 }
 
 func (w *Watcher) processFile(path string) {
+	defer func() {
+		w.mu.Lock()
+		w.inFlight--
+		w.mu.Unlock()
+	}()
+	// WHY relative? In a recursive watcher the same name can turn up in
+	// several subfolders; the relative path tells them apart, and the
+	// vault note goes in the matching subfolder.
 	filename := filepath.Base(path)
+	if rel, err := filepath.Rel(w.dir, path); err == nil {
+		filename = filepath.ToSlash(rel)
+	}
+	// WHY fingerprint before transcribing? A restart forgets w.processed;
+	// the ledger remembers, and a file seen before costs no backend call.
+	var fp Print
+	if w.ledger != nil {
+		var err error
+		if fp, err = Fingerprint(path); err != nil {
+			w.logger.Warn("file not fingerprinted — transcribing without the ledger", "file", filename, "error", err)
+		} else if w.ledger.Has(fp, w.id) {
+			w.logger.Info("already transcribed, skipped", "file", filename)
+			w.mu.Lock()
+			w.skipped++
+			w.mu.Unlock()
+			return
+		}
+	}
 	w.logger.Info("auto-transcribing", "file", filename)
 
 	w.broadcast(Event{
@@ -221,19 +598,43 @@ func (w *Watcher) processFile(path string) {
 	}
 
 	w.logger.Info("transcription complete", "file", filename, "chars", len(text))
+	if fp.SHA256 != "" {
+		if err := w.ledger.Add(fp, w.id); err != nil {
+			w.logger.Warn("watcher ledger not saved", "file", filename, "error", err)
+		}
+	}
 
 	// Save to vault if configured
+	var savedTo string
 	if w.vaultDir != "" && text != "" {
-		vaultPath := filepath.Join(w.vaultDir, strings.TrimSuffix(filename, filepath.Ext(filename))+".md")
-		content := fmt.Sprintf("---\ntitle: %s\ndate: %s\ntags: [auto-transcription, folder-watch]\n---\n\n%s\n",
-			strings.TrimSuffix(filename, filepath.Ext(filename)),
+		name := filepath.FromSlash(filename)
+		vaultPath := filepath.Join(w.vaultDir, strings.TrimSuffix(name, filepath.Ext(name))+".md")
+		if err := os.MkdirAll(filepath.Dir(vaultPath), 0755); err != nil {
+			w.logger.Error("vault dir not created", "dir", filepath.Dir(vaultPath), "error", err)
+		}
+		title := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+		desc := w.describe(text)
+		if desc.Title != "" {
+			title = vault.Quote(desc.Title)
+		}
+		var summary string
+		if desc.Summary != "" {
+			summary = "summary: " + vault.Quote(desc.Summary) + "\n"
+		}
+		content := fmt.Sprintf("---\ntitle: %s\ndate: %s\n%stags: %s\n---\n\n%s\n",
+			title,
 			time.Now().Format(time.RFC3339),
+			summary,
+			vault.FormatTags(vault.CleanTags(append(slices.Clip(w.tags), desc.Tags...))),
 			text,
 		)
-		if err := os.WriteFile(vaultPath, []byte(content), 0644); err != nil {
+		sum, err := vault.WriteVerified(vaultPath, []byte(content))
+		w.monitor.Record(vaultPath, len(content), sum, err)
+		if err != nil {
 			w.logger.Error("vault save failed", "file", vaultPath, "error", err)
 		} else {
 			w.logger.Info("saved to vault", "file", vaultPath)
+			savedTo = vaultPath
 		}
 	}
 
@@ -241,45 +642,77 @@ func (w *Watcher) processFile(path string) {
 		Type:      "transcription",
 		Filename:  filename,
 		Text:      text,
+		SavedTo:   savedTo,
+		Language:  w.language,
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
 
 func (w *Watcher) transcribe(audioPath string) (string, error) {
-	// Read audio file
-	audioData, err := os.ReadFile(audioPath)
+	name := filepath.Base(audioPath)
+	if w.converter.Available() && w.audioOpts != nil {
+		if o := w.audioOpts(); o.Enabled() {
+			wav, err := w.converter.ConvertFile(context.Background(), audioPath, o)
+			if err != nil {
+				// Non-fatal: the backend may read what ffmpeg couldn't.
+				w.logger.Warn("audio preprocessing failed, sending the file as-is", "file", name, "error", err)
+			} else {
+				defer os.Remove(wav)
+				audioPath = wav
+				name = strings.TrimSuffix(name, filepath.Ext(name)) + ".wav"
+			}
+		}
+	}
+
+	// Stream the audio file — a long recording must never sit in RAM whole.
+	src, err := os.Open(audioPath)
 	if err != nil {
 		return "", fmt.Errorf("read audio: %w", err)
 	}
+	defer src.Close()
 
-	// Build multipart form request (same as browser upload)
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
+	// Build multipart form request (same as browser upload). The spool keeps
+	// it in memory when small and in a temp file when not, and gives the
+	// request a known Content-Length.
+	buf := spool.New(w.spoolMemory, w.spoolDir)
+	defer buf.Close()
+	writer := multipart.NewWriter(buf)
 
-	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
+	part, err := writer.CreateFormFile("file", name)
 	if err != nil {
 		return "", fmt.Errorf("create form file: %w", err)
 	}
-	if _, err := io.Copy(part, bytes.NewReader(audioData)); err != nil {
+	if _, err := io.Copy(part, src); err != nil {
 		return "", fmt.Errorf("copy audio data: %w", err)
 	}
 
-	writer.WriteField("response_format", "json")
+	writer.WriteField("response_format", w.responseFormat)
 	if w.language != "" && w.language != "und" {
 		writer.WriteField("language", w.language)
 	}
-	writer.Close()
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("finish form: %w", err)
+	}
 
 	// Send to Whisper backend
+	body, err := buf.Reader()
+	if err != nil {
+		return "", fmt.Errorf("read spooled body: %w", err)
+	}
 	url := w.whisperURL + "/v1/audio/transcriptions"
-	req, err := http.NewRequest(http.MethodPost, url, &buf)
+	req, err := http.NewRequest(http.MethodPost, url, body)
 	if err != nil {
 		return "", fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	resp, err := w.client.Do(req)
-	if err != nil {
+	req.ContentLength = buf.Size()
+
+	var resp *http.Response
+	if w.transcriber != nil {
+		rec := httptest.NewRecorder()
+		w.transcriber(rec, req)
+		resp = rec.Result()
+	} else if resp, err = w.client.Do(req); err != nil {
 		return "", fmt.Errorf("whisper request: %w", err)
 	}
 	defer resp.Body.Close()
@@ -289,6 +722,14 @@ func (w *Watcher) transcribe(audioPath string) (string, error) {
 		return "", fmt.Errorf("whisper returned %d: %s", resp.StatusCode, string(body))
 	}
 
+	if !strings.Contains(w.responseFormat, "json") {
+		// text, srt, vtt: the body is the transcript.
+		text, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+		if err != nil {
+			return "", fmt.Errorf("read response: %w", err)
+		}
+		return strings.TrimSpace(string(text)), nil
+	}
 	var result struct {
 		Text string `json:"text"`
 	}
@@ -301,6 +742,11 @@ func (w *Watcher) transcribe(audioPath string) (string, error) {
 
 // SSEHandler returns an HTTP handler for Server-Sent Events.
 func (w *Watcher) SSEHandler() http.HandlerFunc {
+	return sseHandler(w.Subscribe, w.Unsubscribe)
+}
+
+// sseHandler streams the events of one subscription per request.
+func sseHandler(subscribe func() chan Event, unsubscribe func(chan Event)) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
 		flusher, ok := rw.(http.Flusher)
 		if !ok {
@@ -312,8 +758,8 @@ func (w *Watcher) SSEHandler() http.HandlerFunc {
 		rw.Header().Set("Cache-Control", "no-cache")
 		rw.Header().Set("Connection", "keep-alive")
 
-		ch := w.Subscribe()
-		defer w.Unsubscribe(ch)
+		ch := subscribe()
+		defer unsubscribe(ch)
 
 		// Send initial connected event
 		fmt.Fprintf(rw, "data: {\"type\":\"connected\"}\n\n")
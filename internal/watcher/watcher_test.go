@@ -0,0 +1,364 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryan-winkler/captainslog-whisper/internal/proxy"
+	"github.com/ryan-winkler/captainslog-whisper/internal/vault"
+)
+
+func next(t *testing.T, ch chan Event, want string) Event {
+	t.Helper()
+	for {
+		select {
+		case ev := <-ch:
+			if ev.Type == want {
+				return ev
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no %q event", want)
+		}
+	}
+}
+
+func TestStartStopRestart(t *testing.T) {
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"text": " hello from the folder "}`))
+	}))
+	defer whisper.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := New("", whisper.URL, "", "en", logger)
+	if err := w.Start(); err == nil {
+		t.Fatal("Start with no dir succeeded")
+	}
+	ch := w.Subscribe()
+	defer w.Unsubscribe(ch)
+
+	first, vaultDir := t.TempDir(), t.TempDir()
+	if err := w.Configure(first, vaultDir, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	next(t, ch, "started")
+	if err := w.Start(); !errors.Is(err, ErrBusy) {
+		t.Errorf("second Start err = %v, want ErrBusy", err)
+	}
+	if err := w.Configure(t.TempDir(), vaultDir, "en"); !errors.Is(err, ErrBusy) {
+		t.Errorf("Configure while running err = %v, want ErrBusy", err)
+	}
+	if st := w.Status(); !st.Running || st.Dir != first || st.StartedAt == nil || st.Clients != 1 {
+		t.Errorf("running status = %+v", st)
+	}
+
+	w.Stop()
+	w.Stop() // no-op
+	next(t, ch, "stopped")
+	if st := w.Status(); st.Running || st.StartedAt != nil {
+		t.Errorf("stopped status = %+v", st)
+	}
+
+	// Restart on another folder; a new file there is transcribed and saved.
+	second := t.TempDir()
+	if err := w.Configure(second, vaultDir, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	next(t, ch, "started")
+	if err := os.WriteFile(filepath.Join(second, "memo.wav"), []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ev := next(t, ch, "transcription")
+	if ev.Text != "hello from the folder" || ev.SavedTo != filepath.Join(vaultDir, "memo.md") {
+		t.Errorf("transcription event = %+v", ev)
+	}
+	w.Stop()
+	if st := w.Status(); st.Completed != 1 || st.Failed != 0 || st.LastEvent == nil {
+		t.Errorf("final status = %+v", st)
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"text": "deep"}`))
+	}))
+	defer whisper.Close()
+
+	dir, vaultDir := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2026-10", "16"), 0o755)
+	os.MkdirAll(filepath.Join(dir, ".hidden"), 0o755)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := New(dir, whisper.URL, vaultDir, "en", logger, WithRecursive(true))
+	ch := w.Subscribe()
+	defer w.Unsubscribe(ch)
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if err := w.SetRecursive(false); !errors.Is(err, ErrBusy) {
+		t.Errorf("SetRecursive while running err = %v, want ErrBusy", err)
+	}
+	if st := w.Status(); !st.Recursive || st.Dirs != 3 {
+		t.Errorf("status = %+v, want 3 dirs", st)
+	}
+
+	// An existing subfolder.
+	os.WriteFile(filepath.Join(dir, "2026-10", "16", "a.m4a"), []byte("x"), 0o644)
+	ev := next(t, ch, "transcription")
+	if ev.Filename != "2026-10/16/a.m4a" || ev.SavedTo != filepath.Join(vaultDir, "2026-10", "16", "a.md") {
+		t.Errorf("event = %+v", ev)
+	}
+
+	// A folder moved in with a file already in it.
+	outside := filepath.Join(t.TempDir(), "2026-11")
+	os.Mkdir(outside, 0o755)
+	os.WriteFile(filepath.Join(outside, "b.wav"), []byte("RIFF"), 0o644)
+	if err := os.Rename(outside, filepath.Join(dir, "2026-11")); err != nil {
+		t.Fatal(err)
+	}
+	ev = next(t, ch, "transcription")
+	if ev.Filename != "2026-11/b.wav" {
+		t.Errorf("event = %+v", ev)
+	}
+	if st := w.Status(); st.Dirs != 4 {
+		t.Errorf("dirs = %d, want 4", st.Dirs)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	calls := 0
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"text": "once"}`))
+	}))
+	defer whisper.Close()
+
+	dir, ledgerPath := t.TempDir(), filepath.Join(t.TempDir(), "ledger.json")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	run := func(write func()) Status {
+		t.Helper()
+		ledger, err := OpenLedger(ledgerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := New(dir, whisper.URL, "", "en", logger, WithLedger(ledger))
+		ch := w.Subscribe()
+		defer w.Unsubscribe(ch)
+		if err := w.Start(); err != nil {
+			t.Fatal(err)
+		}
+		write()
+		deadline := time.Now().Add(10 * time.Second)
+		for st := w.Status(); st.Completed+st.Skipped == 0 && time.Now().Before(deadline); st = w.Status() {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Stop()
+		return w.Status()
+	}
+
+	memo := filepath.Join(dir, "memo.wav")
+	if st := run(func() { os.WriteFile(memo, []byte("RIFF one"), 0o644) }); st.Completed != 1 {
+		t.Fatalf("first run = %+v", st)
+	}
+	// After a "restart", rewriting the same file, or a copy under another
+	// name, is skipped.
+	if st := run(func() { os.WriteFile(memo, []byte("RIFF one"), 0o644) }); st.Skipped != 1 || st.Completed != 0 {
+		t.Errorf("rewritten file = %+v", st)
+	}
+	if st := run(func() { os.WriteFile(filepath.Join(dir, "copy.wav"), []byte("RIFF one"), 0o644) }); st.Skipped != 1 {
+		t.Errorf("copy = %+v", st)
+	}
+	// New content is transcribed.
+	if st := run(func() { os.WriteFile(memo, []byte("RIFF two"), 0o644) }); st.Completed != 1 {
+		t.Errorf("changed file = %+v", st)
+	}
+	if calls != 2 {
+		t.Errorf("whisper called %d times, want 2", calls)
+	}
+}
+
+func TestTranscriber(t *testing.T) {
+	var lang, data string
+	h := func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		lang = r.FormValue("language")
+		if f, _, err := r.FormFile("file"); err == nil {
+			b, _ := io.ReadAll(f)
+			data = string(b)
+		}
+		w.Write([]byte(`{"text": " through the proxy "}`))
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// The backend URL is never dialled.
+	w := New(t.TempDir(), "http://127.0.0.1:1", "", "fr", logger, WithTranscriber(h))
+	memo := filepath.Join(t.TempDir(), "memo.wav")
+	os.WriteFile(memo, []byte("RIFF memo"), 0o644)
+	text, err := w.transcribe(memo)
+	if err != nil || text != "through the proxy" || lang != "fr" || data != "RIFF memo" {
+		t.Errorf("transcribe = %q, %v; handler got language %q, file %q", text, err, lang, data)
+	}
+
+	failing := New(t.TempDir(), "", "", "", logger, WithTranscriber(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "transcription backend unavailable"}`, http.StatusBadGateway)
+	}))
+	if _, err := failing.transcribe(memo); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("failing handler err = %v", err)
+	}
+}
+
+// TestTranscriber_LanguageModels verifies that a watch folder's language
+// picks the model mapped to it when the watcher goes through the proxy.
+func TestTranscriber_LanguageModels(t *testing.T) {
+	var model string
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		model = r.FormValue("model")
+		w.Write([]byte(`{"text": "konnichiwa", "segments": []}`))
+	}))
+	defer whisper.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := proxy.New(whisper.URL, logger, proxy.WithLanguageModels(func() map[string]string {
+		return map[string]string{"ja": "large-v3", "en": "distil-large-v3"}
+	}))
+	w := New(t.TempDir(), whisper.URL, "", "ja", logger, WithTranscriber(p.Transcribe))
+	memo := filepath.Join(t.TempDir(), "memo.wav")
+	os.WriteFile(memo, []byte("RIFF memo"), 0o644)
+	if text, err := w.transcribe(memo); err != nil || text != "konnichiwa" || model != "large-v3" {
+		t.Errorf("transcribe = %q, %v; backend got model %q", text, err, model)
+	}
+}
+
+func TestDescriber(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"text": "The budget is approved."}`))
+	}
+	describer := func() vault.Describer {
+		return func(ctx context.Context, text string) (vault.Description, error) {
+			return vault.Description{Title: "Budget approved", Summary: "The budget went through.", Tags: []string{"finance"}}, nil
+		}
+	}
+	dir, vaultDir := t.TempDir(), t.TempDir()
+	w := New(dir, "", vaultDir, "en", logger, WithTranscriber(h), WithDescriber(describer))
+	memo := filepath.Join(dir, "memo.wav")
+	os.WriteFile(memo, []byte("RIFF memo"), 0o644)
+	w.inFlight++
+	w.processFile(memo)
+
+	note, err := os.ReadFile(filepath.Join(vaultDir, "memo.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`title: "Budget approved"`, `summary: "The budget went through."`, "finance", "folder-watch", "The budget is approved."} {
+		if !strings.Contains(string(note), want) {
+			t.Errorf("note lacks %q:\n%s", want, note)
+		}
+	}
+
+	// Off: the file name is the title, and there is no summary.
+	w = New(dir, "", vaultDir, "en", logger, WithTranscriber(h), WithDescriber(func() vault.Describer { return nil }))
+	w.inFlight++
+	w.processFile(memo)
+	note, _ = os.ReadFile(filepath.Join(vaultDir, "memo.md"))
+	if !strings.Contains(string(note), "title: memo\n") || strings.Contains(string(note), "summary:") {
+		t.Errorf("undescribed note:\n%s", note)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ok := []Config{{ID: "memos", Dir: "/a", Recursive: true}, {ID: "calls", Dir: "/ab", VaultSubdir: "Calls/2026", ResponseFormat: "srt"}}
+	if err := Validate(ok); err != nil {
+		t.Errorf("valid configs: %v", err)
+	}
+	for name, cfgs := range map[string][]Config{
+		"bad id":       {{ID: "Memos!", Dir: "/a"}},
+		"duplicate id": {{ID: "a", Dir: "/a"}, {ID: "a", Dir: "/b"}},
+		"no dir":       {{ID: "a"}},
+		"same dir":     {{ID: "a", Dir: "/a"}, {ID: "b", Dir: "/a/"}},
+		"escaping sub": {{ID: "a", Dir: "/a", VaultSubdir: "../elsewhere"}},
+		"absolute sub": {{ID: "a", Dir: "/a", VaultSubdir: "/etc"}},
+		"bad format":   {{ID: "a", Dir: "/a", ResponseFormat: "docx"}},
+		"nested":       {{ID: "a", Dir: "/a", Recursive: true}, {ID: "b", Dir: "/a/2026"}},
+	} {
+		if err := Validate(cfgs); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	var formats []string
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		formats = append(formats, r.FormValue("response_format")+"/"+r.FormValue("language"))
+		if r.FormValue("response_format") == "text" {
+			w.Write([]byte("plain words\n"))
+			return
+		}
+		w.Write([]byte(`{"text": "json words"}`))
+	}))
+	defer whisper.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := NewManager(whisper.URL, logger)
+	defer m.Stop()
+	ch := m.Subscribe()
+	defer m.Unsubscribe(ch)
+
+	vault := t.TempDir()
+	memos, calls := t.TempDir(), t.TempDir()
+	cfgs := []Config{
+		{ID: "memos", Dir: memos},
+		{ID: "calls", Dir: calls, Language: "de", VaultSubdir: "Calls", ResponseFormat: "text", Tags: []string{"calls", "#work"}},
+		{ID: "later", Dir: t.TempDir(), Paused: true},
+	}
+	if err := m.Apply(cfgs, vault, "en"); err != nil {
+		t.Fatal(err)
+	}
+	list := m.List()
+	if len(list) != 3 || list[0].ID != "calls" || !list[0].Status.Running || list[1].Status.Running {
+		t.Fatalf("List = %+v", list)
+	}
+
+	os.WriteFile(filepath.Join(calls, "call.wav"), []byte("RIFF"), 0o644)
+	ev := next(t, ch, "transcription")
+	if ev.Watcher != "calls" || ev.Text != "plain words" || ev.SavedTo != filepath.Join(vault, "Calls", "call.md") {
+		t.Errorf("event = %+v", ev)
+	}
+	if note, _ := os.ReadFile(ev.SavedTo); !strings.Contains(string(note), "tags: [calls, work]\n") {
+		t.Errorf("note = %s", note)
+	}
+	if len(formats) != 1 || formats[0] != "text/de" {
+		t.Errorf("whisper saw %v, want [text/de]", formats)
+	}
+
+	// Unchanged watchers keep running; removed ones stop.
+	memosWatcher := m.watchers["memos"].w
+	if err := m.Apply(cfgs[:1], vault, "en"); err != nil {
+		t.Fatal(err)
+	}
+	if m.watchers["memos"].w != memosWatcher || len(m.List()) != 1 {
+		t.Error("Apply restarted an unchanged watcher or kept a removed one")
+	}
+	// A new global language restarts watchers that fall back to it.
+	if err := m.Apply(cfgs[:1], vault, "fr"); err != nil {
+		t.Fatal(err)
+	}
+	if m.watchers["memos"].w == memosWatcher || memosWatcher.Running() {
+		t.Error("language change did not restart the watcher")
+	}
+}